@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/db"
+	"github.com/hello-api/internal/service"
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/mongo"
+)
+
+// selfCheckTimeout bounds -check and the preflight check, so neither a deploy
+// step nor a start can hang on an unreachable MongoDB
+const selfCheckTimeout = 8 * time.Second
+
+// configCheck loads one part of the environment configuration, as main does at startup
+type configCheck struct {
+	name string
+	load func() error
+}
+
+var configChecks = []configCheck{
+	{"logging", func() error { _, err := logging.FromEnv(); return err }},
+	{"slow request logging", func() error { _, err := logging.SlowRequestsFromEnv(); return err }},
+	{"MongoDB", func() error { _, err := mongo.LoadConfig(); return err }},
+	{"market schedule", func() error { _, err := service.LoadMarketSchedule(); return err }},
+	{"Telegram", func() error { _, err := service.LoadTelegramConfig(); return err }},
+	{"outbound HTTP", func() error { _, err := service.LoadOutboundHTTPConfig(); return err }},
+	{"email", func() error { _, err := service.LoadEmailConfig(); return err }},
+	{"notification concurrency", func() error { _, err := service.LoadNotificationMaxInFlight(); return err }},
+	{"live connections", func() error { _, err := service.LoadLiveLimits(); return err }},
+	{"tick filter", func() error { _, err := service.LoadTickFilterConfig(); return err }},
+	{"alert cache", func() error { _, err := service.LoadAlertCacheRefresh(); return err }},
+	{"users", func() error { _, err := service.LoadUniqueEmail(); return err }},
+	{"notifications", func() error { _, err := service.LoadNotificationMaxPerHour(); return err }},
+	{"status", func() error { _, err := service.LoadFeedStaleAfter(); return err }},
+	{"evaluation sampling", func() error { _, err := service.LoadEvaluationSamplingConfig(); return err }},
+	{"alert archive", func() error { _, err := service.LoadAlertArchiveConfig(); return err }},
+	{"alert dates", func() error { _, err := service.LoadAlertDateBounds(); return err }},
+	{"feature flags", func() error { _, err := service.LoadFeatureFlags(); return err }},
+	{"evaluator debug", func() error { _, err := service.LoadEvaluatorDebugMaxEntries(); return err }},
+}
+
+// preflightError lists every problem a check found, so they can all be fixed
+// in one go rather than one failed start at a time
+type preflightError struct {
+	problems []string
+}
+
+func (e *preflightError) Error() string {
+	var b strings.Builder
+	b.WriteString("preflight check failed:")
+	for i, problem := range e.problems {
+		fmt.Fprintf(&b, "\n  %d. %s", i+1, problem)
+	}
+	return b.String()
+}
+
+// configProblems loads every environment setting and returns the problems found
+func configProblems() []string {
+	var problems []string
+	for _, check := range configChecks {
+		if err := check.load(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", check.name, err))
+		}
+	}
+	return problems
+}
+
+// preflightCheck runs before serving: it loads every environment setting,
+// rejects a JWT_SECRET too short to be safe and, on the mongo backend, pings
+// MongoDB. Every problem is reported together in a *preflightError.
+func preflightCheck(ctx context.Context) error {
+	problems := configProblems()
+	// Without a secret token authentication is off, which is allowed
+	if os.Getenv("JWT_SECRET") != "" {
+		if err := common.CheckJWTSecret(); err != nil {
+			problems = append(problems, fmt.Sprintf("auth: %v", err))
+		}
+	}
+	if db.UsesMongo() {
+		if client, err := mongo.Connect(ctx); err != nil {
+			problems = append(problems, fmt.Sprintf("MongoDB: %v", err))
+		} else {
+			client.Disconnect(context.Background())
+		}
+	}
+	if len(problems) > 0 {
+		return &preflightError{problems: problems}
+	}
+	return nil
+}
+
+// runSelfCheck verifies the configuration end to end without serving: every
+// environment setting, the JWT secret and, on the mongo backend, the
+// connection, the indexes and a write/read/delete round trip. It prints the
+// problems as a numbered list and returns the process exit code.
+func runSelfCheck() int {
+	ctx, cancel := context.WithTimeout(context.Background(), selfCheckTimeout)
+	defer cancel()
+
+	problems := configProblems()
+	if err := common.CheckJWTSecret(); err != nil {
+		problems = append(problems, fmt.Sprintf("auth: %v", err))
+	}
+	if db.UsesMongo() {
+		problems = append(problems, checkMongo(ctx)...)
+	} else {
+		fmt.Printf("DB_BACKEND=%s, skipping the MongoDB checks\n", db.Backend())
+	}
+
+	if len(problems) > 0 {
+		fmt.Println((&preflightError{problems: problems}).Error())
+		return 1
+	}
+	fmt.Println("Self-check passed")
+	return 0
+}
+
+// checkMongo connects on its own client, so a failure is reported rather than
+// fatal, and returns the problems found
+func checkMongo(ctx context.Context) []string {
+	client, err := mongo.Connect(ctx)
+	if err != nil {
+		return []string{fmt.Sprintf("MongoDB: %v", err)}
+	}
+	defer client.Disconnect(context.Background())
+	database := mongo.CreateDatabase(client)
+
+	var failures []string
+	uniqueEmail, _ := service.LoadUniqueEmail()
+	missing, err := db.MissingIndexes(ctx, database, uniqueEmail)
+	if err != nil {
+		failures = append(failures, fmt.Sprintf("MongoDB indexes: %v", err))
+	}
+	for _, index := range missing {
+		failures = append(failures, fmt.Sprintf("MongoDB indexes: %s is missing; starting the API creates it", index))
+	}
+	if err := db.RoundTrip(ctx, database); err != nil {
+		failures = append(failures, fmt.Sprintf("MongoDB round trip: %v", err))
+	}
+	return failures
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Every problem of a misconfigured environment is reported in one error,
+// numbered, rather than only the first
+func TestPreflightCheckReportsEveryProblem(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "settings",
+			env: map[string]string{
+				"DB_BACKEND":                 "memory",
+				"LOG_LEVEL":                  "loud",
+				"MARKET_TIMEZONE":            "Mars/Olympus_Mons",
+				"NOTIFICATION_MAX_IN_FLIGHT": "0",
+				"JWT_SECRET":                 "too-short",
+			},
+			want: []string{"1. logging:", "2. market schedule:", "3. notification concurrency:", "4. auth:"},
+		},
+		{
+			name: "unreachable MongoDB",
+			env: map[string]string{
+				"DB_BACKEND": "mongo",
+				"MONGO_URI":  "mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=200&connectTimeoutMS=200",
+				"LOG_LEVEL":  "loud",
+			},
+			want: []string{"1. logging:", "2. MongoDB:"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			err := preflightCheck(ctx)
+			var preflight *preflightError
+			if !errors.As(err, &preflight) {
+				t.Fatalf("got %v, want a *preflightError", err)
+			}
+			if len(preflight.problems) != len(tc.want) {
+				t.Errorf("got %d problems, want %d:\n%v", len(preflight.problems), len(tc.want), err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error lacks %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		t.Setenv("DB_BACKEND", "memory")
+		if err := preflightCheck(context.Background()); err != nil {
+			t.Errorf("got %v for the default configuration", err)
+		}
+	})
+}
+
+// captureStdout returns what run prints to standard output
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	run()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// -check requires a JWT secret, unlike the preflight check, and lists every
+// problem numbered with a non-zero exit code
+func TestRunSelfCheck(t *testing.T) {
+	t.Setenv("DB_BACKEND", "memory")
+	for _, tc := range []struct {
+		name     string
+		env      map[string]string
+		wantCode int
+		want     []string
+	}{
+		{name: "no JWT secret", env: map[string]string{"JWT_SECRET": ""}, wantCode: 1,
+			want: []string{"preflight check failed:", "1. auth: JWT_SECRET is not set"}},
+		{name: "bad settings", env: map[string]string{"JWT_SECRET": "too-short", "ALERT_SHADOW_RULES": "sideways"}, wantCode: 1,
+			want: []string{"1. feature flags:", "2. auth: JWT_SECRET is 9 bytes"}},
+		{name: "valid", env: map[string]string{"JWT_SECRET": strings.Repeat("s", 32)},
+			want: []string{"skipping the MongoDB checks", "Self-check passed"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+			var code int
+			out := captureStdout(t, func() { code = runSelfCheck() })
+			if code != tc.wantCode {
+				t.Errorf("got exit code %d, want %d:\n%s", code, tc.wantCode, out)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output lacks %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
@@ -2,23 +2,38 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 
+	"github.com/hello-api/internal/common"
 	"github.com/hello-api/internal/db"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
 	"github.com/hello-api/internal/router"
+	"github.com/hello-api/internal/service"
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/tracing"
 )
 
 func main() {
+	migrateOnly := flag.Bool("migrate", false, "apply pending MongoDB migrations and exit")
+	selfCheck := flag.Bool("check", false, "verify the configuration, MongoDB, its indexes and the JWT secret, then exit")
+	flag.Parse()
+
 	// Load environment variables
 	env := os.Getenv("ENV")
 	if env == "" {
 		env = "dev" // Default to development environment
-		log.Println("ENV not set, defaulting to dev")
+		slog.Info("ENV not set, defaulting to dev")
 	}
 
 	var envFile string
@@ -32,34 +47,253 @@ func main() {
 	}
 
 	if err := godotenv.Load(envFile); err != nil {
-		log.Printf("Warning: Error loading env file: %v", err)
-		log.Println("Continuing with default or existing environment variables")
+		slog.Warn("Could not load the env file, continuing with the existing environment", "file", envFile, "error", err)
+	}
+	if *selfCheck {
+		os.Exit(runSelfCheck())
+	}
+	// Fail fast, with every problem listed, rather than deep into serving
+	preflightCtx, cancelPreflight := context.WithTimeout(context.Background(), selfCheckTimeout)
+	err := preflightCheck(preflightCtx)
+	cancelPreflight()
+	if err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+
+	// Structured logging configured by LOG_LEVEL and LOG_FORMAT; the standard
+	// log package is routed through it as well
+	logger, err := logging.FromEnv()
+	if err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	// Requests slower than SLOW_REQUEST_THRESHOLD are logged at Warn level and
+	// the slowest routes of the day are kept for /admin/debug/slow-routes
+	slowRequests, err := logging.SlowRequestsFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
 	}
 
 	// MongoDB URI is now hardcoded in the ConnectMongo function
 
-	// Connect to MongoDB
-	mongoClient := db.GetClient()
+	// Tracing is a no-op unless OTEL_TRACES_EXPORTER is set
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
 	defer func() {
-		if err := mongoClient.Disconnect(context.Background()); err != nil {
-			log.Fatalf("Error disconnecting MongoDB: %v", err)
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("Failed to flush traces", "error", err)
 		}
 	}()
 
+	// Connect to MongoDB unless running on the in-memory backend
+	if db.UsesMongo() {
+		mongoClient := db.GetClient()
+		defer func() {
+			if err := mongoClient.Disconnect(context.Background()); err != nil {
+				log.Fatalf("Error disconnecting MongoDB: %v", err)
+			}
+		}()
+
+		// Apply migrations in -migrate mode, or before serving when MIGRATE_ON_START is set
+		if *migrateOnly || os.Getenv("MIGRATE_ON_START") == "true" {
+			if err := db.RunMigrations(context.Background()); err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+		} else if pending, err := db.PendingMigrations(context.Background()); err != nil {
+			logger.Warn("Could not check pending migrations", "error", err)
+		} else if len(pending) > 0 {
+			logger.Warn("Pending migrations; run with -migrate or set MIGRATE_ON_START=true", "pending", len(pending))
+		}
+		if *migrateOnly {
+			logger.Info("Migrations complete")
+			return
+		}
+
+		if err := db.EnsureIndexes(context.Background()); err != nil {
+			log.Fatalf("Failed to ensure indexes: %v", err)
+		}
+
+		// Watch connectivity so requests fail fast with 503 while MongoDB is unreachable
+		supervisorCtx, stopSupervisor := context.WithCancel(context.Background())
+		defer stopSupervisor()
+		go db.NewSupervisor(db.PingMongo, db.DefaultPingInterval, db.DefaultPingTimeout).Run(supervisorCtx)
+	} else if *migrateOnly {
+		log.Fatalf("-migrate requires the mongo backend (DB_BACKEND=%s)", db.Backend())
+	}
+
+	// Deliver queued webhook, Telegram and email notifications in the background
+	var notificationRepository domain.NotificationRepository
+	if db.UsesMongo() {
+		notificationRepository = repository.NewMongoNotificationRepository(db.NotificationOutbox())
+	} else {
+		notificationRepository = repository.NewMemoryNotificationRepository()
+	}
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	defer stopWorker()
+
+	// Trading hours alerts are evaluated in; the DSE schedule unless MARKET_* overrides it
+	schedule, err := service.LoadMarketSchedule()
+	if err != nil {
+		log.Fatalf("Invalid market schedule: %v", err)
+	}
+
+	// Telegram messages are sent when TELEGRAM_BOT_TOKEN is set
+	telegram, err := service.LoadTelegramConfig()
+	if err != nil {
+		log.Fatalf("Invalid Telegram configuration: %v", err)
+	}
+	// Telegram calls share an outbound client retrying 429, 5xx and network
+	// errors, tuned by OUTBOUND_HTTP_*. Webhooks are sent once per outbox
+	// attempt, since the worker already retries them.
+	outboundCfg, err := service.LoadOutboundHTTPConfig()
+	if err != nil {
+		log.Fatalf("Invalid outbound HTTP configuration: %v", err)
+	}
+	outbound := service.NewOutboundHTTPClient(outboundCfg)
+	senders := service.NotificationSenders{
+		dto.NotificationChannelWebhook: service.NewHTTPWebhookSender(service.NewOutboxHTTPClient(outboundCfg)),
+	}
+	var telegramBot *service.TelegramBot
+	if telegram.Enabled() {
+		telegramBot = service.NewTelegramBot(telegram, schedule.Location, outbound)
+		senders[dto.NotificationChannelTelegram] = telegramBot
+	}
+	// Emails are sent when SMTP_HOST is set
+	email, err := service.LoadEmailConfig()
+	if err != nil {
+		log.Fatalf("Invalid email configuration: %v", err)
+	}
+	if email.Enabled() {
+		senders[dto.NotificationChannelEmail] = service.NewEmailSender(service.NewSMTPTransport(email), email, schedule.Location)
+	}
+	workerCfg := service.DefaultNotificationWorkerConfig()
+	// Deliveries in progress at once, so a market-wide move cannot flood providers
+	if workerCfg.MaxInFlight, err = service.LoadNotificationMaxInFlight(); err != nil {
+		log.Fatalf("Invalid notification concurrency: %v", err)
+	}
+	worker := service.NewNotificationWorker(notificationRepository, senders, workerCfg)
+	go worker.Run(workerCtx)
+
+	// Live events pushed to WebSocket clients, within the connection limits
+	liveLimits, err := service.LoadLiveLimits()
+	if err != nil {
+		log.Fatalf("Invalid live connection configuration: %v", err)
+	}
+	events := service.NewBroadcaster(liveLimits, service.DefaultSubscriberQueue, service.DefaultEventHistory)
+
+	// Sanity checks on ingested price ticks
+	tickFilter, err := service.LoadTickFilterConfig()
+	if err != nil {
+		log.Fatalf("Invalid tick filter configuration: %v", err)
+	}
+
+	// How often the in-memory index of active alerts is reloaded
+	alertCacheRefresh, err := service.LoadAlertCacheRefresh()
+	if err != nil {
+		log.Fatalf("Invalid alert cache configuration: %v", err)
+	}
+
+	// Whether two users may share an email; enforced with a unique index on MongoDB
+	uniqueEmail, err := service.LoadUniqueEmail()
+	if err != nil {
+		log.Fatalf("Invalid user configuration: %v", err)
+	}
+	if uniqueEmail && db.UsesMongo() {
+		if err := db.EnsureUserEmailIndex(context.Background()); err != nil {
+			log.Fatalf("Failed to enforce unique emails: %v", err)
+		}
+	}
+
+	// How many Telegram messages and emails a user gets per hour before the rest
+	// are summarized
+	maxPerHour, err := service.LoadNotificationMaxPerHour()
+	if err != nil {
+		log.Fatalf("Invalid notification configuration: %v", err)
+	}
+
+	// How long without a tick before /status reports ingestion as stalled
+	feedStaleAfter, err := service.LoadFeedStaleAfter()
+	if err != nil {
+		log.Fatalf("Invalid status configuration: %v", err)
+	}
+	evaluationSampling, err := service.LoadEvaluationSamplingConfig()
+	if err != nil {
+		log.Fatalf("Invalid evaluation sampling configuration: %v", err)
+	}
+	alertArchive, err := service.LoadAlertArchiveConfig()
+	if err != nil {
+		log.Fatalf("Invalid alert archive configuration: %v", err)
+	}
+	alertDates, err := service.LoadAlertDateBounds()
+	if err != nil {
+		log.Fatalf("Invalid alert date bounds: %v", err)
+	}
+	evaluatorDebugMaxEntries, err := service.LoadEvaluatorDebugMaxEntries()
+	if err != nil {
+		log.Fatalf("Invalid evaluator debug configuration: %v", err)
+	}
+	// Feature flags set by the environment; admins may override them at runtime
+	flagEnv, err := service.LoadFeatureFlags()
+	if err != nil {
+		log.Fatalf("Invalid feature flag configuration: %v", err)
+	}
+
 	// Initialize routes
-	r := router.InitializeRoutes()
+	r := router.InitializeRoutes(workerCtx, router.Config{
+		Logger:                   logger,
+		SlowRequests:             slowRequests,
+		Notifications:            notificationRepository,
+		Events:                   events,
+		Schedule:                 schedule,
+		TickFilter:               tickFilter,
+		AlertCacheRefresh:        alertCacheRefresh,
+		UniqueEmail:              uniqueEmail,
+		Telegram:                 telegram,
+		TelegramBot:              telegramBot,
+		EmailEnabled:             email.Enabled(),
+		MaxNotificationsPerHour:  maxPerHour,
+		FeedStaleAfter:           feedStaleAfter,
+		EvaluationSampling:       evaluationSampling,
+		AlertArchive:             alertArchive,
+		FlagEnv:                  flagEnv,
+		AlertDates:               alertDates,
+		EvaluatorDebugMaxEntries: evaluatorDebugMaxEntries,
+	})
 
 	// Set up the server
 	server := &http.Server{
-		Addr:         ":8080",
-		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+		Addr:        ":8080",
+		Handler:     r,
+		ReadTimeout: 15 * time.Second,
+		// Above the longest request budget, so slow requests end with a TIMEOUT
+		// envelope rather than a dropped connection
+		WriteTimeout: common.LongRequestTimeout + 15*time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
-	log.Println("Starting server on port 8080")
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Server error: %v", err)
+	go func() {
+		logger.Info("Starting server", "addr", server.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	logger.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down the server cleanly", "error", err)
+	}
+	// Shutdown does not track upgraded connections; ending the subscriptions
+	// makes every WebSocket send a going-away close frame
+	if err := events.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to close live connections", "error", err)
 	}
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hello-api/pkg/httpclient"
+
+	"datafeed/pkg/alert"
+	"datafeed/pkg/auth"
+	"datafeed/pkg/config"
+	"datafeed/pkg/forwarder"
+	"datafeed/pkg/logging"
+	"datafeed/pkg/market"
+	"datafeed/pkg/pipeline"
+	"datafeed/pkg/signalr"
+)
+
+// checkTimeout bounds `datafeed check` and the preflight check, login retries included
+const checkTimeout = 9 * time.Second
+
+// preflightError lists every problem a check found, so they can all be fixed
+// in one go rather than one failed start at a time
+type preflightError struct {
+	problems []string
+}
+
+func (e *preflightError) Error() string {
+	var b strings.Builder
+	b.WriteString("preflight check failed:")
+	for i, problem := range e.problems {
+		fmt.Fprintf(&b, "\n  %d. %s", i+1, problem)
+	}
+	return b.String()
+}
+
+// preflightCheck runs before the feed starts: it validates the settings and
+// logs in, reporting every problem together in a *preflightError. The token
+// of a successful login is returned even when a setting is invalid.
+func preflightCheck(ctx context.Context, cfg *config.Config) (string, error) {
+	problems := checkConfig(cfg)
+
+	// A login without a URL can only fail, and that is already reported
+	var token string
+	if cfg.LoginURL != "" {
+		var err error
+		if token, err = auth.LoginContext(ctx, cfg); err != nil {
+			problems = append(problems, fmt.Sprintf("login: %v", err))
+		}
+	}
+	if len(problems) > 0 {
+		return token, &preflightError{problems: problems}
+	}
+	return token, nil
+}
+
+// runCheck verifies the configuration end to end without starting the feed:
+// the preflight check, a SignalR negotiate and, when prices are forwarded, the
+// API's readiness. It prints the problems as a numbered list and returns the
+// exit code.
+func runCheck(path string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return reportCheck([]string{fmt.Sprintf("config: %v", err)})
+	}
+	var problems []string
+	token, err := preflightCheck(ctx, cfg)
+	var preflight *preflightError
+	if errors.As(err, &preflight) {
+		problems = preflight.problems
+	}
+
+	// Without a login there is no token to negotiate with
+	if token != "" {
+		if err := signalr.Negotiate(ctx, &http.Client{}, cfg.SignalRURL, token); err != nil {
+			problems = append(problems, fmt.Sprintf("signalr negotiate: %v", err))
+		}
+	}
+
+	if cfg.APIURL == "" {
+		fmt.Println("ℹ️ api_url is not set, prices are not forwarded; skipping the API probe")
+	} else if err := forwarder.New(cfg.APIURL, cfg.APISecret, httpclient.New(cfg.HTTPClient())).Ready(ctx); err != nil {
+		problems = append(problems, fmt.Sprintf("api_url: %v", err))
+	}
+	return reportCheck(problems)
+}
+
+// checkConfig applies the validation main does at startup and returns the problems found
+func checkConfig(cfg *config.Config) []string {
+	var failures []string
+	fail := func(setting string, err error) {
+		failures = append(failures, fmt.Sprintf("%s: %v", setting, err))
+	}
+
+	for _, required := range []struct{ setting, value string }{
+		{"login_url", cfg.LoginURL},
+		{"signalr_url", cfg.SignalRURL},
+		{"username", cfg.Username},
+		{"password", cfg.Password},
+	} {
+		if required.value == "" {
+			fail(required.setting, errors.New("is not set"))
+		}
+	}
+	// Forwarded prices are signed, and the API refuses them unsigned
+	if cfg.APIURL != "" && cfg.APISecret == "" {
+		fail("api_secret", errors.New("is not set while api_url is"))
+	}
+	if err := logging.CheckOutput(cfg.LogOutput, cfg.LogFile); err != nil {
+		fail("log_output", err)
+	}
+	if _, err := signalr.NewSubscriptionEncoder(cfg.SubscriptionProtocol); err != nil {
+		fail("subscription_protocol", err)
+	}
+	if _, err := signalr.ParseTransferFormat(cfg.TransferFormat); err != nil {
+		fail("transfer_format", err)
+	}
+	if len(cfg.DecodePipelines) > 0 {
+		if _, err := signalr.NewDecodePipelines(cfg.DecodePipelines); err != nil {
+			fail("decode_pipelines", err)
+		}
+	}
+	if len(cfg.SharePriceFields) > 0 {
+		if _, err := market.NewSharePriceLayout(cfg.SharePriceFields); err != nil {
+			fail("share_price_fields", err)
+		}
+	}
+	if cfg.MessageTemplate != "" {
+		if _, err := alert.ParseTemplate(cfg.MessageTemplate); err != nil {
+			fail("message_template", err)
+		}
+	}
+	if _, err := alert.FromConfig(cfg.Alerts); err != nil {
+		fail("alerts", err)
+	}
+	if _, err := alert.ParseOverflowPolicy(cfg.EvaluationOverflowPolicy); err != nil {
+		fail("evaluation_overflow_policy", err)
+	}
+	if _, err := pipeline.ParsePolicy(cfg.PipelineOverflowPolicy); err != nil {
+		fail("pipeline_overflow_policy", err)
+	}
+	return failures
+}
+
+// reportCheck prints the problems as a numbered list and returns the exit code
+func reportCheck(problems []string) int {
+	if len(problems) > 0 {
+		fmt.Printf("❌ %v\n", &preflightError{problems: problems})
+		return 1
+	}
+	fmt.Println("✅ Check passed")
+	return 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"datafeed/pkg/config"
+)
+
+// loginServer answers logins with a token, or with 401 when refused
+func loginServer(t *testing.T, refused bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if refused {
+			http.Error(w, "bad credentials", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":{"accessToken":"token-1"}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Every problem of a misconfigured feed, settings and login alike, is reported
+// in one error rather than only the first
+func TestPreflightCheckReportsEveryProblem(t *testing.T) {
+	refused := loginServer(t, true)
+	cfg := &config.Config{
+		LoginURL:                 refused.URL,
+		Username:                 "alice",
+		APIURL:                   "http://localhost:8080/v1",
+		SubscriptionProtocol:     "carrier-pigeon",
+		EvaluationOverflowPolicy: "shrug",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	token, err := preflightCheck(ctx, cfg)
+	var preflight *preflightError
+	if !errors.As(err, &preflight) {
+		t.Fatalf("got %v, want a *preflightError", err)
+	}
+	if token != "" {
+		t.Errorf("got token %q from a refused login", token)
+	}
+	want := []string{"1. signalr_url: is not set", "2. password: is not set", "3. api_secret: is not set while api_url is",
+		"4. subscription_protocol:", "5. evaluation_overflow_policy:", "6. login:"}
+	if len(preflight.problems) != len(want) {
+		t.Errorf("got %d problems, want %d:\n%v", len(preflight.problems), len(want), err)
+	}
+	for _, problem := range want {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("error lacks %q:\n%v", problem, err)
+		}
+	}
+}
+
+// A login that works returns its token even while a setting is invalid
+func TestPreflightCheckToken(t *testing.T) {
+	cfg := &config.Config{
+		LoginURL:   loginServer(t, false).URL,
+		SignalRURL: "wss://example.com/hub",
+		Username:   "alice",
+		Password:   "secret",
+	}
+	token, err := preflightCheck(context.Background(), cfg)
+	if err != nil || token != "token-1" {
+		t.Fatalf("got %q, %v, want token-1", token, err)
+	}
+
+	cfg.PipelineOverflowPolicy = "shrug"
+	token, err = preflightCheck(context.Background(), cfg)
+	var preflight *preflightError
+	if !errors.As(err, &preflight) || len(preflight.problems) != 1 || token != "token-1" {
+		t.Errorf("got %q, %v, want token-1 and the one problem", token, err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"datafeed/pkg/devserver"
+)
+
+// Serves a mock exchange feed for running the datafeed locally. Point the
+// config at it:
+//
+//	login_url: "http://localhost:5005/login"
+//	signalr_url: "http://localhost:5005/hub"
+//
+// and push prices with
+//
+//	curl -d '{"symbol":"GP","price":101.5,"volume":1000}' localhost:5005/inject
+func main() {
+	addr := flag.String("addr", "localhost:5005", "address to serve the login, hub and /inject on")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server, err := devserver.New(ctx)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	httpServer := &http.Server{Addr: *addr, Handler: server}
+	go func() {
+		<-ctx.Done()
+		httpServer.Close()
+	}()
+
+	log.Printf("🧪 Mock feed on http://%s (login %s, hub %s, inject %s)", *addr, devserver.LoginPath, devserver.HubPath, devserver.InjectPath)
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("❌ %v", err)
+	}
+}
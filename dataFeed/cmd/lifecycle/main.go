@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"datafeed/pkg/signalr"
+)
+
+// Prints the connection lifecycle events recorded to lifecycle_log and its
+// rotated backups, e.g. every
+// disconnect of the last night:
+//
+//	./run.sh lifecycle -file lifecycle.jsonl -since 12h -kind disconnect
+func main() {
+	file := flag.String("file", "lifecycle.jsonl", "lifecycle log written by the datafeed (lifecycle_log)")
+	since := flag.Duration("since", 0, "only show events of this last period, e.g. 12h (0 shows all)")
+	kind := flag.String("kind", "", "only show events of this kind: connect, connect_failed, disconnect, reconnect_attempt or give_up")
+	flag.Parse()
+	log.SetFlags(0)
+
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+	events, err := signalr.NewFileLifecycleStore(*file).Query(from, time.Time{})
+	if err != nil {
+		log.Printf("❌ %v", err)
+		os.Exit(1)
+	}
+
+	shown := 0
+	for _, event := range events {
+		if *kind != "" && string(event.Kind) != *kind {
+			continue
+		}
+		shown++
+		switch event.Kind {
+		case signalr.LifecycleDisconnect:
+			log.Printf("%s 🔴 disconnect: %s", event.Time.Format(time.RFC3339), event.Reason)
+		case signalr.LifecycleConnectFailed:
+			log.Printf("%s 🟠 connect failed (attempt #%d): %s", event.Time.Format(time.RFC3339), event.Attempt, event.Reason)
+		case signalr.LifecycleReconnectAttempt:
+			log.Printf("%s 🟡 reconnect attempt #%d after %v", event.Time.Format(time.RFC3339), event.Attempt,
+				time.Duration(event.BackoffMs)*time.Millisecond)
+		case signalr.LifecycleGiveUp:
+			log.Printf("%s 🛑 gave up after %d attempts: %s", event.Time.Format(time.RFC3339), event.Attempt, event.Reason)
+		default:
+			log.Printf("%s 🟢 %s", event.Time.Format(time.RFC3339), event.Kind)
+		}
+	}
+	log.Printf("%d events", shown)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"datafeed/pkg/metrics"
+	"datafeed/pkg/signalr"
+)
+
+// diagnostics is what the stats endpoint serves and SIGUSR1 dumps to the log
+type diagnostics struct {
+	At time.Time `json:"at"`
+	// Connection is Client.GetConnectionStats with errors, statuses and
+	// durations as text
+	Connection map[string]interface{} `json:"connection"`
+	// History is the latest connection lifecycle events, oldest first
+	History []signalr.LifecycleEvent `json:"history"`
+	// Gauges are the dataFeed metrics, such as the depth of each stage
+	Gauges map[string]int64 `json:"gauges"`
+}
+
+// collectDiagnostics snapshots the client and the metrics registry
+func collectDiagnostics(client *signalr.Client, registry *metrics.Registry) diagnostics {
+	connection := make(map[string]interface{})
+	for key, value := range client.GetConnectionStats() {
+		switch v := value.(type) {
+		case error:
+			connection[key] = v.Error()
+		case signalr.ConnectionStatus:
+			connection[key] = v.String()
+		case time.Duration:
+			connection[key] = v.String()
+		default:
+			connection[key] = value
+		}
+	}
+	gauges := make(map[string]int64)
+	for _, sample := range registry.Snapshot() {
+		gauges[sample.Name] = sample.Value
+	}
+	return diagnostics{
+		At:         time.Now(),
+		Connection: connection,
+		History:    client.History(),
+		Gauges:     gauges,
+	}
+}
+
+// logDiagnostics dumps the diagnostics to the log as indented JSON
+func logDiagnostics(d diagnostics) {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		log.Printf("⚠️ Failed to encode diagnostics: %v", err)
+		return
+	}
+	log.Printf("🩺 Diagnostics:\n%s", data)
+}
+
+// dumpOnSignal logs the diagnostics collect returns on every SIGUSR1 until
+// the returned function is called
+func dumpOnSignal(collect func() diagnostics) func() {
+	dumpChan := make(chan os.Signal, 1)
+	signal.Notify(dumpChan, syscall.SIGUSR1)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-dumpChan:
+				logDiagnostics(collect())
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(dumpChan)
+		close(done)
+	}
+}
+
+// statsHandler serves GET /stats with the diagnostics collect returns
+func statsHandler(collect func() diagnostics) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(collect()); err != nil {
+			log.Printf("⚠️ Failed to write stats: %v", err)
+		}
+	})
+	return mux
+}
+
+// serveStats serves statsHandler on addr; the returned function shuts the
+// server down
+func serveStats(addr string, collect func() diagnostics) func(ctx context.Context) error {
+	server := &http.Server{Addr: addr, Handler: statsHandler(collect), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("⚠️ Stats endpoint stopped: %v", err)
+		}
+	}()
+	return server.Shutdown
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"datafeed/pkg/config"
+	"datafeed/pkg/metrics"
+	"datafeed/pkg/signalr"
+)
+
+// syncBuffer is a log output the test reads while the dump goroutine writes
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// testDiagnostics collects the diagnostics of a client that never connected
+// and a registry with one stage depth
+func testDiagnostics() func() diagnostics {
+	client := signalr.NewClient(&config.Config{}, "token")
+	registry := metrics.NewRegistry()
+	registry.Gauge("pipeline_evaluate_depth").Set(3)
+	return func() diagnostics { return collectDiagnostics(client, registry) }
+}
+
+// GET /stats serves the connection stats as text and the gauges; other
+// methods get 405 with the allowed one
+func TestStatsHandler(t *testing.T) {
+	handler := statsHandler(testDiagnostics())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d %s, want 200 JSON", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var got diagnostics
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("undecodable stats %q: %v", rec.Body.String(), err)
+	}
+	if got.Connection["status"] != "disconnected" {
+		t.Errorf("got status %v, want disconnected", got.Connection["status"])
+	}
+	if _, ok := got.Connection["heartbeatP50"].(string); !ok {
+		t.Errorf("got heartbeatP50 %v, want a duration as text", got.Connection["heartbeatP50"])
+	}
+	if got.Gauges["pipeline_evaluate_depth"] != 3 {
+		t.Errorf("got gauges %v, want pipeline_evaluate_depth 3", got.Gauges)
+	}
+	if time.Since(got.At) > time.Minute {
+		t.Errorf("got stats at %s, want now", got.At)
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/stats", strings.NewReader("{}")))
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodGet {
+			t.Errorf("%s got %d allowing %q, want 405 allowing GET", method, rec.Code, rec.Header().Get("Allow"))
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("got %d for another path, want 404", rec.Code)
+	}
+}
+
+// SIGUSR1 dumps the diagnostics to the log as JSON
+func TestDumpOnSignal(t *testing.T) {
+	var out syncBuffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	stop := dumpOnSignal(testDiagnostics())
+	defer stop()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), `"pipeline_evaluate_depth": 3`) {
+		if time.Now().After(deadline) {
+			t.Fatalf("got log %q, want the diagnostics dumped", out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(out.String(), "🩺 Diagnostics:") || !strings.Contains(out.String(), `"status": "disconnected"`) {
+		t.Errorf("got log %q, want the diagnostics header and the connection status", out.String())
+	}
+}
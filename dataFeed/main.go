@@ -1,18 +1,43 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/hello-api/pkg/httpclient"
+
+	"datafeed/pkg/alert"
 	"datafeed/pkg/auth"
 	"datafeed/pkg/config"
+	"datafeed/pkg/forwarder"
+	"datafeed/pkg/logging"
+	"datafeed/pkg/market"
+	"datafeed/pkg/metrics"
+	"datafeed/pkg/orchestrator"
+	"datafeed/pkg/pipeline"
+	"datafeed/pkg/shutdown"
 	"datafeed/pkg/signalr"
 )
 
+// defaultSubscriptionEscalateAfter applies when subscription_escalate_after is unset
+const defaultSubscriptionEscalateAfter = 10 * time.Minute
+
 func main() {
+	// `datafeed check` verifies the configuration, login and hub, then exits
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(runCheck("config.yaml"))
+	}
+	// `datafeed replay --forward` backfills the API from a raw frame log
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:]))
+	}
+
 	log.Println("Starting data feed service...")
 
 	// Load configuration
@@ -21,22 +46,106 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Authenticate and get token
+	// Validate every setting and log in before anything starts, so a bad
+	// deploy fails at once with all of its problems listed
 	log.Println("Authenticating...")
-	token, err := auth.Login(cfg)
+	preflightCtx, cancelPreflight := context.WithTimeout(context.Background(), checkTimeout)
+	token, err := preflightCheck(preflightCtx, cfg)
+	cancelPreflight()
 	if err != nil {
-		log.Fatalf("Login failed: %v", err)
+		log.Fatalf("Refusing to start: %v", err)
 	}
 	log.Println("Authentication successful")
 
+	// Send all component logs to the configured destination
+	logOutput, err := logging.Configure(cfg.LogOutput, cfg.LogFile, cfg.LogMaxSizeMB, cfg.LogMaxBackups)
+	if err != nil {
+		log.Fatalf("Invalid log output: %v", err)
+	}
+	defer logOutput.Close()
+	if cfg.LogOutput == logging.OutputFile {
+		log.Printf("📝 Logging to %s", cfg.LogFile)
+	}
+
+	// Hub messages are negotiated as JSON text or MessagePack binary
+	if _, err := signalr.ParseTransferFormat(cfg.TransferFormat); err != nil {
+		log.Fatalf("Invalid transfer_format: %v", err)
+	}
+
 	// Create and connect SignalR client with enhanced error handling
 	client := signalr.NewClient(cfg, token)
+	log.Printf("📦 Negotiating the %s transfer format", client.TransferFormat())
+
+	// Continue the connection stats of the previous run
+	var statsStore signalr.StatsStore
+	if cfg.StatsFile != "" {
+		statsStore = signalr.NewFileStatsStore(cfg.StatsFile)
+		if stats, err := statsStore.Load(); err != nil {
+			log.Printf("⚠️ Could not load connection stats: %v", err)
+		} else if stats != nil {
+			client.RestoreStats(*stats)
+		}
+	}
+
+	// Lay out subscription arguments for the server's protocol version
+	encoder, err := signalr.NewSubscriptionEncoder(cfg.SubscriptionProtocol)
+	if err != nil {
+		log.Fatalf("Invalid subscription_protocol: %v", err)
+	}
+	client.SetSubscriptionEncoder(encoder)
+	log.Printf("📨 Subscribing with protocol %s arguments", encoder)
 
 	// Register custom handler for special character method names
 	client.RegisterCustomHandler("MarketStatusUpdated^^DSE~", func(msg signalr.Message) {
 		log.Printf("🎯 SPECIAL CHAR METHOD: MarketStatusUpdated^^DSE~ received: %v", msg.Data)
 	})
 
+	// Optionally record the exact frames sent by the server
+	if cfg.RawFrameLog != "" {
+		frameLog, err := os.OpenFile(cfg.RawFrameLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalf("Failed to open raw frame log: %v", err)
+		}
+		defer frameLog.Close()
+		client.SetRawFrameTap(signalr.NewWriterFrameTap(frameLog))
+		log.Printf("📝 Writing raw frames to %s", cfg.RawFrameLog)
+	}
+
+	// Optionally keep the connection lifecycle for postmortems
+	var lifecycle *signalr.LifecycleRecorder
+	if cfg.LifecycleLog != "" {
+		store := signalr.NewFileLifecycleStore(cfg.LifecycleLog)
+		if cfg.LifecycleLogMaxSizeKB > 0 {
+			backups := cfg.LifecycleLogMaxBackups
+			if backups <= 0 {
+				backups = 3
+			}
+			store = signalr.NewRotatingFileLifecycleStore(cfg.LifecycleLog, int64(cfg.LifecycleLogMaxSizeKB)<<10, backups)
+		}
+		lifecycle = signalr.NewLifecycleRecorder(store)
+		client.SetLifecycleRecorder(lifecycle)
+		log.Printf("📝 Recording connection lifecycle events to %s", cfg.LifecycleLog)
+	}
+
+	// Once reconnecting is given up the feed stays down; page an operator
+	var failureWebhook *signalr.FailureWebhook
+	if cfg.FailureWebhookURL != "" {
+		failureWebhook = signalr.NewFailureWebhook(cfg.FailureWebhookURL, httpclient.New(cfg.HTTPClient()))
+		log.Println("📟 Posting a notice to the failure webhook if the feed fails")
+	}
+	client.OnFailed(func(attempts int, lastErr error) {
+		log.Printf("🛑 FEED FAILED - gave up after %d reconnect attempts, last error: %v", attempts, lastErr)
+		if failureWebhook == nil {
+			return
+		}
+		notice := signalr.NewFailureNotice(cfg.SignalRURL, attempts, lastErr, time.Now())
+		go func() {
+			if err := failureWebhook.Notify(context.Background(), notice); err != nil {
+				log.Printf("⚠️ Failed to post the failure notice: %v", err)
+			}
+		}()
+	})
+
 	// Add handlers for connection events
 	client.RegisterCustomHandler("ConnectionEvent", func(msg signalr.Message) {
 		log.Printf("🔗 CONNECTION EVENT: %v", msg.Data)
@@ -67,19 +176,138 @@ func main() {
 
 	log.Println("✅ SignalR connected successfully")
 
-	// Create a message processor
-	processor := signalr.NewMessageProcessor()
+	// Track subscriptions that keep failing in the background
+	subscriptionHealth := signalr.NewSubscriptionHealth()
+	go func() {
+		for clientErr := range client.Errors() {
+			subscriptionHealth.Record(clientErr)
+		}
+	}()
+	escalateAfter := cfg.SubscriptionEscalateAfter
+	if escalateAfter <= 0 {
+		escalateAfter = defaultSubscriptionEscalateAfter
+	}
 
-	// Process messages in a goroutine
+	// Connected is not the same as subscribed: data only flows once the hub
+	// has accepted the subscriptions
 	go func() {
-		log.Println("Starting message processor...")
-		for msg := range client.Messages() {
-			log.Printf("📨 Received message: Method=%s", msg.Method)
-			processor.Process(msg)
+		select {
+		case <-client.SubscriptionsReady():
+			log.Println("✅ Subscriptions confirmed, market data is live")
+		case <-time.After(30 * time.Second):
+			log.Println("⚠️ Subscriptions not confirmed within 30s; market data may not be flowing")
 		}
-		log.Println("Message processor stopped")
 	}()
 
+	// Create a message processor
+	processor := signalr.NewMessageProcessor()
+	processor.SetTransferFormat(client.TransferFormat())
+	if len(cfg.DecodePipelines) > 0 {
+		pipelines, err := signalr.NewDecodePipelines(cfg.DecodePipelines)
+		if err != nil {
+			log.Fatalf("Invalid decode_pipelines: %v", err)
+		}
+		processor.SetDecodePipelines(pipelines)
+		log.Printf("🧩 Decoding share prices with pipelines %v", pipelines)
+	}
+	if len(cfg.SharePriceFields) > 0 {
+		layout, err := market.NewSharePriceLayout(cfg.SharePriceFields)
+		if err != nil {
+			log.Fatalf("Invalid share_price_fields: %v", err)
+		}
+		processor.SetSharePriceLayout(layout)
+		log.Printf("🧩 Parsing share price records as %s", layout)
+	}
+	if cfg.MaxDecompressedSize > 0 {
+		processor.SetMaxDecompressedSize(cfg.MaxDecompressedSize)
+	}
+	if cfg.CorrectClockSkew {
+		processor.SetClockSkewSource(client.ClockSkew)
+		log.Println("🕒 Correcting tick times by the estimated server clock skew")
+	}
+	// Feed lag is measured against exchange timestamps, which are on the server's clock
+	processor.SetFeedLagSkewSource(client.ClockSkew)
+
+	// Optionally log the symbols the feed sends, to help pick alert symbols
+	if cfg.SymbolDiscoveryWindow > 0 {
+		discovery := market.NewSymbolDiscovery(cfg.SymbolDiscoveryWindow)
+		processor.EnableDiscovery(discovery)
+		go logDiscoveredSymbols(discovery)
+		log.Printf("🔎 Symbol discovery enabled, reporting every %s", cfg.SymbolDiscoveryWindow)
+	}
+
+	// Evaluate configured alerts against parsed market events
+	notifier := alert.NewLogNotifier()
+	if cfg.MessageTemplate != "" {
+		if err := notifier.SetTemplate(cfg.MessageTemplate); err != nil {
+			log.Fatalf("Invalid message_template: %v", err)
+		}
+	}
+	// The orchestrator delivers the evaluator's triggers to the notifier
+	evaluator := alert.NewEvaluator(nil)
+	alerts, err := alert.FromConfig(cfg.Alerts)
+	if err != nil {
+		log.Fatalf("Invalid alert configuration: %v", err)
+	}
+	evaluator.SetAlerts(alerts)
+	evaluator.SetAllowStaleThresholds(cfg.AllowStaleThresholds)
+
+	// Continue from the latest ticks seen by the previous run
+	var watermarkStore *alert.FileWatermarkStore
+	if cfg.WatermarksFile != "" {
+		watermarkStore = alert.NewFileWatermarkStore(cfg.WatermarksFile)
+		if watermarks, err := watermarkStore.Load(); err != nil {
+			log.Printf("⚠️ Could not load tick watermarks: %v", err)
+		} else {
+			evaluator.RestoreWatermarks(watermarks)
+		}
+	}
+	if cfg.PriceEpsilon > 0 {
+		evaluator.SetPriceEpsilon(cfg.PriceEpsilon)
+	}
+	evaluator.SetMinMove(cfg.MinMove)
+
+	// Ticks wait for the evaluator in a bounded queue so a burst cannot grow memory
+	overflowPolicy, err := alert.ParseOverflowPolicy(cfg.EvaluationOverflowPolicy)
+	if err != nil {
+		log.Fatalf("Invalid evaluation_overflow_policy: %v", err)
+	}
+	// Messages wait for the processor in their own bounded queue, so a slow
+	// processor shows up as queue depth instead of stalling the SignalR read loop
+	pipelinePolicy, err := pipeline.ParsePolicy(cfg.PipelineOverflowPolicy)
+	if err != nil {
+		log.Fatalf("Invalid pipeline_overflow_policy: %v", err)
+	}
+	barIntervals := alert.BarIntervals(alerts)
+	for _, interval := range barIntervals {
+		log.Printf("📊 Aggregating %s bars for bar alerts", interval)
+	}
+
+	// Feed messages → parsed events → alert evaluation → notifications
+	flowConfig := orchestrator.Config{
+		Messages: pipeline.Config{
+			Name:      "pipeline",
+			QueueSize: cfg.PipelineQueueSize,
+			Policy:    pipelinePolicy,
+			MaxAge:    cfg.PipelineMaxAge,
+			Workers:   cfg.PipelineWorkers,
+		},
+		TickQueueSize:      cfg.EvaluationQueueSize,
+		TickOverflowPolicy: overflowPolicy,
+		BarIntervals:       barIntervals,
+	}
+	// The API evaluates its users' alerts against the prices forwarded to it
+	var priceStream *forwarder.Stream
+	if cfg.APIURL != "" {
+		priceStream = forwarder.NewStream(forwarder.New(cfg.APIURL, cfg.APISecret, httpclient.New(cfg.HTTPClient())), 0, 0)
+		flowConfig.Forwarder = priceStream
+		log.Printf("📤 Forwarding share prices to %s", cfg.APIURL)
+	}
+	flow := orchestrator.New(client, processor, evaluator, notifier, flowConfig)
+	pipeline.RegisterChannelDepth(metrics.Default, "signalr", client.Messages())
+	flow.RegisterGauges(metrics.Default)
+	flow.Start()
+
 	// Monitor connection status and statistics with enhanced logging
 	go func() {
 		ticker := time.NewTicker(15 * time.Second) // More frequent monitoring
@@ -87,7 +315,23 @@ func main() {
 
 		for {
 			<-ticker.C
+			saveStats(statsStore, client)
+			saveWatermarks(watermarkStore, evaluator)
+			if skipped := evaluator.StaleSkipped(); len(skipped) > 0 {
+				log.Printf("⏪ Stale ticks skipped: %v", skipped)
+			}
+			if skipped := evaluator.MinMoveSkipped(); len(skipped) > 0 {
+				log.Printf("🤏 Ticks below the minimum move skipped: %v", skipped)
+			}
+			flowStats := flow.Stats()
+			logPipelineStats(flowStats.Messages)
+			logQueueStats(flowStats.Ticks)
+			logForwardStats(priceStream)
+			logStageDepths(metrics.Default, "signalr", "pipeline", "evaluation")
 			stats := client.GetConnectionStats()
+			lastMessageAt, _ := stats["lastMessageAt"].(time.Time)
+			logSubscriptionHealth(subscriptionHealth.Failing(time.Now(), lastMessageAt, escalateAfter), client.ErrorsDropped())
+			logHeartbeatLatency(client)
 			status := stats["status"]
 			attempts := stats["reconnectAttempts"]
 			subscriptions := stats["subscriptions"]
@@ -105,6 +349,11 @@ func main() {
 				}
 			case signalr.ConnectionStatusConnecting:
 				log.Printf("🟡 CONNECTING - Attempts: %v", attempts)
+			case signalr.ConnectionStatusFailed:
+				log.Printf("🛑 FAILED - Gave up after %v attempts, Subscriptions: %v", attempts, subscriptions)
+				if lastErr := client.LastError(); lastErr != nil {
+					log.Printf("   Last error: %v", lastErr)
+				}
 			default:
 				log.Printf("❓ UNKNOWN STATUS: %v - Attempts: %v, Subscriptions: %v", status, attempts, subscriptions)
 			}
@@ -114,6 +363,15 @@ func main() {
 	// Setup token refresh
 	go refreshTokenPeriodically(cfg, client)
 
+	// Connection stats, lifecycle history and stage depths, on demand
+	collect := func() diagnostics { return collectDiagnostics(client, metrics.Default) }
+	var stopStats func(ctx context.Context) error
+	if cfg.StatsAddr != "" {
+		stopStats = serveStats(cfg.StatsAddr, collect)
+		log.Printf("📈 Serving connection stats on http://%s/stats", cfg.StatsAddr)
+	}
+	stopDumps := dumpOnSignal(collect)
+
 	// Setup signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -122,12 +380,155 @@ func main() {
 	log.Println("Application running. Press Ctrl+C to exit.")
 	<-sigChan
 
-	// Graceful shutdown
+	// Graceful shutdown, bounded by the configured grace period
 	log.Println("Shutting down...")
-	client.Close()
+	coordinator := shutdown.NewCoordinator(cfg.ShutdownTimeout)
+	stopDumps()
+	if stopStats != nil {
+		coordinator.Register("stats endpoint", stopStats)
+	}
+	// Stop looking for silent symbols, stop the inflow and let the queues
+	// drain, and only then close the message channel
+	flow.RegisterShutdown(coordinator)
+	coordinator.Register("signalr client", func(ctx context.Context) error {
+		client.Close()
+		return nil
+	})
+	if lifecycle != nil {
+		coordinator.Register("lifecycle log", func(ctx context.Context) error {
+			lifecycle.Close()
+			return nil
+		})
+	}
+	if statsStore != nil {
+		// Saved after closing so the recorded status is the final one
+		coordinator.Register("connection stats", func(ctx context.Context) error {
+			return statsStore.Save(client.SnapshotStats())
+		})
+	}
+
+	if watermarkStore != nil {
+		coordinator.Register("tick watermarks", func(ctx context.Context) error {
+			return watermarkStore.Save(evaluator.Watermarks())
+		})
+	}
+
+	report := coordinator.Shutdown()
+	if !report.Clean() {
+		log.Printf("⚠️ Forcing exit - timed out: %v, skipped: %v, failed: %v", report.TimedOut, report.Skipped, report.Failed)
+		os.Exit(1)
+	}
 	log.Println("Application terminated")
 }
 
+// logSubscriptionHealth escalates subscriptions that have kept failing
+func logSubscriptionHealth(failing []signalr.SubscriptionFailure, errorsDropped int64) {
+	for _, failure := range failing {
+		log.Printf("🚨 UNHEALTHY - %s failing for %s (%d failures, last: %v)",
+			failure.Method, time.Since(failure.Since).Round(time.Second), failure.Failures, failure.LastErr)
+	}
+	if len(failing) > 0 && errorsDropped > 0 {
+		log.Printf("   %d client errors were dropped unread", errorsDropped)
+	}
+}
+
+// logHeartbeatLatency reports the heartbeat round-trip percentiles and the
+// server clock skew once they are known
+func logHeartbeatLatency(client *signalr.Client) {
+	latency := client.HeartbeatLatency()
+	if latency.Count == 0 {
+		return
+	}
+	log.Printf("💓 Heartbeat RTT over %s: p50 ≤%s p95 ≤%s p99 ≤%s (%d pings)",
+		signalr.LatencyWindow, latency.P50, latency.P95, latency.P99, latency.Count)
+	if skew, ok := client.ClockSkew(); ok {
+		log.Printf("   Server clock skew: %s", skew.Round(time.Millisecond))
+	}
+}
+
+// logPipelineStats reports the message queue backlog, warning when messages
+// were dropped or expired
+func logPipelineStats(stats pipeline.Stats) {
+	if stats.Dropped > 0 || stats.Expired > 0 {
+		log.Printf("⚠️ Message queue: depth %d/%d (peak %d), dropped %d, expired %d messages (%s)",
+			stats.Depth, stats.Capacity, stats.HighWater, stats.Dropped, stats.Expired, stats.Policy)
+		return
+	}
+	log.Printf("📥 Message queue: depth %d/%d (peak %d), processed %d messages",
+		stats.Depth, stats.Capacity, stats.HighWater, stats.Processed)
+}
+
+// logStageDepths reports the backlog of each stage, in flow order from the
+// SignalR buffer to the alert evaluator, to show where messages pile up
+func logStageDepths(registry *metrics.Registry, stages ...string) {
+	depths := make([]string, 0, len(stages))
+	for _, stage := range stages {
+		depth, _ := registry.Value(pipeline.DepthGauge(stage))
+		depths = append(depths, fmt.Sprintf("%s=%d", stage, depth))
+	}
+	log.Printf("📊 Stage depths: %s", strings.Join(depths, " "))
+}
+
+// logQueueStats reports the evaluation queue backlog, warning when ticks were dropped
+func logQueueStats(stats alert.QueueStats) {
+	if stats.Dropped > 0 {
+		log.Printf("⚠️ Alert queue: depth %d/%d (peak %d), dropped %d ticks (%s)",
+			stats.Depth, stats.Capacity, stats.HighWater, stats.Dropped, stats.Policy)
+		return
+	}
+	log.Printf("📥 Alert queue: depth %d/%d (peak %d), processed %d ticks",
+		stats.Depth, stats.Capacity, stats.HighWater, stats.Processed)
+}
+
+// logForwardStats reports the prices forwarded to the API when forwarding is
+// configured, warning when some were dropped or refused
+func logForwardStats(stream *forwarder.Stream) {
+	if stream == nil {
+		return
+	}
+	stats := stream.Stats()
+	if stats.Dropped > 0 || stats.Failed > 0 {
+		log.Printf("⚠️ API forwarding: %d pending, %d forwarded, %d failed, %d dropped prices",
+			stats.Pending, stats.Forwarded, stats.Failed, stats.Dropped)
+		return
+	}
+	log.Printf("📤 API forwarding: %d pending, %d forwarded prices", stats.Pending, stats.Forwarded)
+}
+
+// saveStats persists the client's connection stats when a store is configured
+func saveStats(store signalr.StatsStore, client *signalr.Client) {
+	if store == nil {
+		return
+	}
+	if err := store.Save(client.SnapshotStats()); err != nil {
+		log.Printf("⚠️ Failed to save connection stats: %v", err)
+	}
+}
+
+// saveWatermarks persists the evaluator's tick watermarks when a store is configured
+func saveWatermarks(store *alert.FileWatermarkStore, evaluator *alert.Evaluator) {
+	if store == nil {
+		return
+	}
+	if err := store.Save(evaluator.Watermarks()); err != nil {
+		log.Printf("⚠️ Failed to save tick watermarks: %v", err)
+	}
+}
+
+// logDiscoveredSymbols logs the symbols seen over each discovery window
+func logDiscoveredSymbols(discovery *market.SymbolDiscovery) {
+	ticker := time.NewTicker(discovery.Window())
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		symbols := discovery.Symbols(now)
+		log.Printf("🔎 %d symbols seen in the last %s:", len(symbols), discovery.Window())
+		for _, s := range symbols {
+			log.Printf("   %s (raw %q, %d ticks, last %s)", s.Symbol, s.RawForms, s.Ticks, s.LastSeen.Format("15:04:05"))
+		}
+	}
+}
+
 // refreshTokenPeriodically refreshes the authentication token periodically
 func refreshTokenPeriodically(cfg *config.Config, client *signalr.Client) {
 	// Refresh token every 50 minutes (assuming a 1-hour token lifetime)
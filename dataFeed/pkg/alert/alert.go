@@ -0,0 +1,114 @@
+// Package alert evaluates user alerts against parsed market feed events
+package alert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"datafeed/pkg/config"
+	"datafeed/pkg/market"
+)
+
+// Rule identifies the condition an alert watches for
+type Rule string
+
+const (
+	// RuleHalt fires when the watched symbol enters a trading halt
+	RuleHalt Rule = "halt"
+
+	// RuleNoUpdate fires when the watched symbol has received no tick for Silence
+	RuleNoUpdate Rule = "no_update"
+
+	// Price rules fire when a tick reaches Price, matching the API's alert rules
+	RuleAbove Rule = "above"
+	RuleBelow Rule = "below"
+
+	// Bar rules fire when a completed OHLC bar of the alert's interval crosses Price
+	RuleBarCloseAbove Rule = "bar_close_above"
+	RuleBarCloseBelow Rule = "bar_close_below"
+	RuleBarHighAbove  Rule = "bar_high_above"
+	RuleBarLowBelow   Rule = "bar_low_below"
+)
+
+// IsPriceRule returns true for rules evaluated against individual ticks
+func (r Rule) IsPriceRule() bool {
+	return r == RuleAbove || r == RuleBelow
+}
+
+// IsBarRule returns true for rules evaluated against completed bars
+func (r Rule) IsBarRule() bool {
+	switch r {
+	case RuleBarCloseAbove, RuleBarCloseBelow, RuleBarHighAbove, RuleBarLowBelow:
+		return true
+	}
+	return false
+}
+
+// Alert is an alert definition as seen by the evaluator
+type Alert struct {
+	ID     string
+	UserID string
+	Symbol string
+	Rule   Rule
+	// Price is the threshold for price based rules
+	Price float64
+	// Interval is the bar interval targeted by bar rules
+	Interval time.Duration
+	// Template overrides the notifier's message template for this alert
+	Template string
+	// MinMove overrides the evaluator's minimum move for this above/below alert
+	MinMove float64
+	// Silence is how long without a tick fires a no_update alert
+	Silence time.Duration
+}
+
+// Trigger is produced when an alert's condition is met
+type Trigger struct {
+	Alert  Alert
+	Symbol string
+	Price  float64
+	Reason string
+	At     time.Time
+}
+
+// FromConfig converts locally configured alerts into evaluator alerts.
+// It fails on an alert whose message template does not parse.
+func FromConfig(cfgs []config.AlertConfig) ([]Alert, error) {
+	alerts := make([]Alert, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		if Rule(strings.ToLower(cfg.Rule)) == RuleNoUpdate && cfg.Silence <= 0 {
+			return nil, fmt.Errorf("alert %s: no_update alerts need a positive silence", cfg.ID)
+		}
+		if cfg.Template != "" {
+			if _, err := ParseTemplate(cfg.Template); err != nil {
+				return nil, fmt.Errorf("alert %s: %w", cfg.ID, err)
+			}
+		}
+		alerts = append(alerts, Alert{
+			ID:       cfg.ID,
+			UserID:   cfg.UserID,
+			Symbol:   market.NormalizeSymbol(cfg.Symbol),
+			Rule:     Rule(strings.ToLower(cfg.Rule)),
+			Price:    cfg.Price,
+			Interval: cfg.Interval,
+			Template: cfg.Template,
+			MinMove:  cfg.MinMove,
+			Silence:  cfg.Silence,
+		})
+	}
+	return alerts, nil
+}
+
+// BarIntervals returns the distinct bar intervals targeted by the alerts
+func BarIntervals(alerts []Alert) []time.Duration {
+	seen := make(map[time.Duration]bool)
+	var intervals []time.Duration
+	for _, a := range alerts {
+		if a.Rule.IsBarRule() && a.Interval > 0 && !seen[a.Interval] {
+			seen[a.Interval] = true
+			intervals = append(intervals, a.Interval)
+		}
+	}
+	return intervals
+}
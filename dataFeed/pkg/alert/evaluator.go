@@ -0,0 +1,524 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"datafeed/pkg/logging"
+	"datafeed/pkg/market"
+)
+
+// Evaluator matches parsed feed events against the loaded alerts
+type Evaluator struct {
+	notifier Notifier
+	logger   *log.Logger
+	now      func() time.Time
+	// Tolerance applied when comparing prices against thresholds
+	epsilon float64
+
+	mu sync.Mutex
+	// Alerts indexed by normalized symbol
+	alerts map[string][]Alert
+	// Halt state per symbol and for the market as a whole
+	halted       map[string]bool
+	marketHalted bool
+	// Whether the last evaluated tick or bar satisfied each price or bar alert,
+	// for edge triggering
+	satisfied map[string]bool
+	// Time of the latest tick accepted per symbol; older ticks are stale
+	watermarks map[string]time.Time
+	// Stale ticks skipped per symbol
+	staleSkipped map[string]uint64
+	// Whether stale ticks still get plain threshold checks (see SetAllowStaleThresholds)
+	allowStaleThresholds bool
+	// Minimum price move for a tick to be evaluated (see SetMinMove)
+	minMove float64
+	// Last price each above/below alert was evaluated at
+	lastEvaluated map[string]float64
+	// Ticks skipped per symbol for moving less than the minimum
+	minMoveSkipped map[string]uint64
+	// When a tick was last received per symbol, for no_update alerts
+	lastSeen map[string]time.Time
+	// Closed state per symbol and for the market; no_update alerts wait while
+	// their symbol is closed or halted
+	closed       map[string]bool
+	marketClosed bool
+}
+
+// DefaultSilenceCheckInterval is how often RunSilenceChecks looks for silent symbols
+const DefaultSilenceCheckInterval = 10 * time.Second
+
+// NewEvaluator creates an evaluator that delivers triggers to notifier
+func NewEvaluator(notifier Notifier) *Evaluator {
+	return &Evaluator{
+		notifier: notifier,
+		logger:   logging.New("[AlertEvaluator] "),
+		now:      time.Now,
+		alerts:   make(map[string][]Alert),
+		halted:   make(map[string]bool),
+
+		epsilon:      DefaultPriceEpsilon,
+		satisfied:    make(map[string]bool),
+		watermarks:   make(map[string]time.Time),
+		staleSkipped: make(map[string]uint64),
+
+		lastEvaluated:  make(map[string]float64),
+		minMoveSkipped: make(map[string]uint64),
+
+		lastSeen: make(map[string]time.Time),
+		closed:   make(map[string]bool),
+	}
+}
+
+// SetClock replaces the evaluator's clock, e.g. with a virtual one in replays
+func (e *Evaluator) SetClock(now func() time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.now = now
+}
+
+// SetMinMove makes above/below alerts skip ticks that moved less than minMove
+// from the last price they were evaluated at, so sub-cent jitter around a
+// threshold is ignored. An alert's own MinMove takes precedence; 0 disables it.
+func (e *Evaluator) SetMinMove(minMove float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.minMove = minMove
+}
+
+// MinMoveSkipped returns the number of ticks skipped per symbol for moving less
+// than the minimum, counted once per tick
+func (e *Evaluator) MinMoveSkipped() map[string]uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	skipped := make(map[string]uint64, len(e.minMoveSkipped))
+	for symbol, n := range e.minMoveSkipped {
+		skipped[symbol] = n
+	}
+	return skipped
+}
+
+// SetAllowStaleThresholds lets ticks older than the symbol's latest accepted tick
+// (e.g. a batch re-sent after an outage) still fire above/below alerts that are
+// armed. A stale tick never re-arms an alert, so it cannot make one fire twice.
+// By default stale ticks are skipped entirely.
+func (e *Evaluator) SetAllowStaleThresholds(allow bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.allowStaleThresholds = allow
+}
+
+// Watermarks returns the time of the latest accepted tick per symbol
+func (e *Evaluator) Watermarks() map[string]time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	watermarks := make(map[string]time.Time, len(e.watermarks))
+	for symbol, at := range e.watermarks {
+		watermarks[symbol] = at
+	}
+	return watermarks
+}
+
+// RestoreWatermarks seeds the latest accepted tick times, e.g. from a previous run,
+// keeping whichever is newer per symbol
+func (e *Evaluator) RestoreWatermarks(watermarks map[string]time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for symbol, at := range watermarks {
+		symbol = market.NormalizeSymbol(symbol)
+		if at.After(e.watermarks[symbol]) {
+			e.watermarks[symbol] = at
+		}
+	}
+}
+
+// StaleSkipped returns the number of stale ticks skipped per symbol
+func (e *Evaluator) StaleSkipped() map[string]uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	skipped := make(map[string]uint64, len(e.staleSkipped))
+	for symbol, n := range e.staleSkipped {
+		skipped[symbol] = n
+	}
+	return skipped
+}
+
+// SetPriceEpsilon sets the tolerance used when comparing prices with thresholds
+func (e *Evaluator) SetPriceEpsilon(epsilon float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.epsilon = epsilon
+}
+
+// SetAlerts replaces the set of alerts being evaluated
+func (e *Evaluator) SetAlerts(alerts []Alert) {
+	index := make(map[string][]Alert)
+	for _, a := range alerts {
+		symbol := market.NormalizeSymbol(a.Symbol)
+		a.Symbol = symbol
+		index[symbol] = append(index[symbol], a)
+	}
+
+	e.mu.Lock()
+	e.alerts = index
+	// Symbols not heard from yet are silent from the moment they are watched
+	now := e.now()
+	for _, a := range alerts {
+		symbol := market.NormalizeSymbol(a.Symbol)
+		if a.Rule == RuleNoUpdate && e.lastSeen[symbol].IsZero() {
+			e.lastSeen[symbol] = now
+		}
+	}
+	e.mu.Unlock()
+
+	e.logger.Printf("Loaded %d alerts across %d symbols", len(alerts), len(index))
+}
+
+// EvaluateMarketStatus applies a market status event and fires halt alerts for
+// every watched symbol that enters a halted state. A symbol leaving the halt
+// re-arms its alerts so the next halt fires again.
+func (e *Evaluator) EvaluateMarketStatus(status market.MarketStatus) []Trigger {
+	e.mu.Lock()
+
+	var affected []string
+	wasHalted := make(map[string]bool)
+	wasPaused := make(map[string]bool)
+	closed := status.State == market.TradingStateClosed
+	if status.Symbol == "" {
+		// A market-wide event affects every watched symbol
+		for symbol := range e.alerts {
+			affected = append(affected, symbol)
+			wasHalted[symbol] = e.isHaltedLocked(symbol)
+			wasPaused[symbol] = e.isPausedLocked(symbol)
+		}
+		e.marketHalted = status.Halted()
+		e.marketClosed = closed
+	} else {
+		symbol := market.NormalizeSymbol(status.Symbol)
+		affected = append(affected, symbol)
+		wasHalted[symbol] = e.isHaltedLocked(symbol)
+		wasPaused[symbol] = e.isPausedLocked(symbol)
+		e.halted[symbol] = status.Halted()
+		e.closed[symbol] = closed
+	}
+
+	var triggers []Trigger
+	now := e.now()
+	for _, symbol := range affected {
+		// Silence is counted again from the moment trading resumes
+		if wasPaused[symbol] && !e.isPausedLocked(symbol) && e.lastSeen[symbol].Before(now) {
+			e.lastSeen[symbol] = now
+		}
+		if wasHalted[symbol] || !e.isHaltedLocked(symbol) {
+			continue
+		}
+		reason := fmt.Sprintf("trading halted (%s)", status.RawState)
+		if status.Symbol == "" {
+			reason = fmt.Sprintf("market-wide trading halt (%s)", status.RawState)
+		}
+		for _, a := range e.alerts[symbol] {
+			if a.Rule != RuleHalt {
+				continue
+			}
+			triggers = append(triggers, Trigger{
+				Alert:  a,
+				Symbol: symbol,
+				Reason: reason,
+				At:     now,
+			})
+		}
+	}
+	e.mu.Unlock()
+
+	e.dispatch(triggers)
+	return triggers
+}
+
+// EvaluateBar evaluates bar rules of the bar's interval against a completed bar.
+// An alert fires when its condition becomes true; it stays quiet while consecutive
+// bars keep satisfying it and re-arms once a bar no longer does.
+func (e *Evaluator) EvaluateBar(bar market.Bar) []Trigger {
+	symbol := market.NormalizeSymbol(bar.Symbol)
+
+	e.mu.Lock()
+	var triggers []Trigger
+	now := e.now()
+	for _, a := range e.alerts[symbol] {
+		if !a.Rule.IsBarRule() || a.Interval != bar.Interval {
+			continue
+		}
+		observed, satisfied := barCondition(a, bar, e.epsilon)
+		wasSatisfied := e.satisfied[a.ID]
+		e.satisfied[a.ID] = satisfied
+		if !satisfied || wasSatisfied {
+			continue
+		}
+		triggers = append(triggers, Trigger{
+			Alert:  a,
+			Symbol: symbol,
+			Price:  observed,
+			Reason: fmt.Sprintf("%s bar starting %s: %s %.2f (threshold %.2f)",
+				bar.Interval, bar.Start.Format("15:04"), a.Rule, observed, a.Price),
+			At: now,
+		})
+	}
+	e.mu.Unlock()
+
+	e.dispatch(triggers)
+	return triggers
+}
+
+// EvaluatePrice evaluates above/below rules against a single tick, firing when
+// the price reaches the threshold and re-arming once it moves back. Ticks older
+// than the latest accepted tick of the symbol are stale and skipped, and so are
+// ticks within the minimum move of the price an alert was last evaluated at.
+func (e *Evaluator) EvaluatePrice(tick market.SharePrice) []Trigger {
+	symbol := market.NormalizeSymbol(tick.Symbol)
+
+	e.mu.Lock()
+	// Any tick, stale or not, shows the symbol is updating and re-arms its
+	// no_update alerts
+	e.lastSeen[symbol] = e.now()
+	for _, a := range e.alerts[symbol] {
+		if a.Rule == RuleNoUpdate {
+			e.satisfied[a.ID] = false
+		}
+	}
+	stale := !tick.Time.IsZero() && tick.Time.Before(e.watermarks[symbol])
+	if stale {
+		e.staleSkipped[symbol]++
+		if !e.allowStaleThresholds {
+			e.mu.Unlock()
+			return nil
+		}
+	} else if tick.Time.After(e.watermarks[symbol]) {
+		e.watermarks[symbol] = tick.Time
+	}
+
+	var triggers []Trigger
+	now := e.now()
+	skipped := false
+	for _, a := range e.alerts[symbol] {
+		if !a.Rule.IsPriceRule() {
+			continue
+		}
+		if e.belowMinMoveLocked(a, tick.Price) {
+			skipped = true
+			continue
+		}
+		e.lastEvaluated[a.ID] = tick.Price
+
+		var satisfied bool
+		switch a.Rule {
+		case RuleAbove:
+			satisfied = AtOrAbove(tick.Price, a.Price, e.epsilon)
+		case RuleBelow:
+			satisfied = AtOrBelow(tick.Price, a.Price, e.epsilon)
+		}
+		wasSatisfied := e.satisfied[a.ID]
+		// A stale tick may fire an armed alert but never re-arms one
+		if !stale || satisfied {
+			e.satisfied[a.ID] = satisfied
+		}
+		if !satisfied || wasSatisfied {
+			continue
+		}
+		triggers = append(triggers, Trigger{
+			Alert:  a,
+			Symbol: symbol,
+			Price:  tick.Price,
+			Reason: fmt.Sprintf("price %.2f is %s %.2f", tick.Price, a.Rule, a.Price),
+			At:     now,
+		})
+	}
+	if skipped {
+		e.minMoveSkipped[symbol]++
+	}
+	e.mu.Unlock()
+
+	e.dispatch(triggers)
+	return triggers
+}
+
+// belowMinMoveLocked reports whether price is within the alert's minimum move of
+// the last price it was evaluated at; it assumes e.mu is held
+func (e *Evaluator) belowMinMoveLocked(a Alert, price float64) bool {
+	minMove := e.minMove
+	if a.MinMove > 0 {
+		minMove = a.MinMove
+	}
+	last, ok := e.lastEvaluated[a.ID]
+	if minMove <= 0 || !ok {
+		return false
+	}
+	return math.Abs(price-last) < minMove-e.epsilon
+}
+
+// barCondition returns the bar value the rule looks at and whether it meets the threshold
+func barCondition(a Alert, bar market.Bar, epsilon float64) (float64, bool) {
+	switch a.Rule {
+	case RuleBarCloseAbove:
+		return bar.Close, AtOrAbove(bar.Close, a.Price, epsilon)
+	case RuleBarCloseBelow:
+		return bar.Close, AtOrBelow(bar.Close, a.Price, epsilon)
+	case RuleBarHighAbove:
+		return bar.High, AtOrAbove(bar.High, a.Price, epsilon)
+	case RuleBarLowBelow:
+		return bar.Low, AtOrBelow(bar.Low, a.Price, epsilon)
+	}
+	return 0, false
+}
+
+// CheckSilence fires no_update alerts whose symbol has received no tick for the
+// alert's Silence. An alert fires once per silence and re-arms with the next
+// tick. Symbols that are closed or halted are not checked.
+func (e *Evaluator) CheckSilence() []Trigger {
+	e.mu.Lock()
+	symbols := make([]string, 0, len(e.alerts))
+	for symbol := range e.alerts {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	var triggers []Trigger
+	now := e.now()
+	for _, symbol := range symbols {
+		if e.isPausedLocked(symbol) {
+			continue
+		}
+		lastSeen := e.lastSeen[symbol]
+		for _, a := range e.alerts[symbol] {
+			if a.Rule != RuleNoUpdate || a.Silence <= 0 {
+				continue
+			}
+			silent := now.Sub(lastSeen) >= a.Silence
+			wasSatisfied := e.satisfied[a.ID]
+			e.satisfied[a.ID] = silent
+			if !silent || wasSatisfied {
+				continue
+			}
+			triggers = append(triggers, Trigger{
+				Alert:  a,
+				Symbol: symbol,
+				Reason: fmt.Sprintf("no update for %s (last tick received %s)",
+					now.Sub(lastSeen).Round(time.Second), lastSeen.Format("15:04:05")),
+				At: now,
+			})
+		}
+	}
+	e.mu.Unlock()
+
+	e.dispatch(triggers)
+	return triggers
+}
+
+// RunSilenceChecks calls CheckSilence every interval until ctx is cancelled
+func (e *Evaluator) RunSilenceChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.CheckSilence()
+		}
+	}
+}
+
+// IsHalted returns whether the symbol is currently halted, either directly or
+// through a market-wide halt
+func (e *Evaluator) IsHalted(symbol string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.isHaltedLocked(market.NormalizeSymbol(symbol))
+}
+
+// isHaltedLocked assumes e.mu is held
+func (e *Evaluator) isHaltedLocked(symbol string) bool {
+	return e.marketHalted || e.halted[symbol]
+}
+
+// isPausedLocked reports whether the symbol is closed or halted, directly or
+// market-wide; it assumes e.mu is held
+func (e *Evaluator) isPausedLocked(symbol string) bool {
+	return e.isHaltedLocked(symbol) || e.marketClosed || e.closed[symbol]
+}
+
+// dispatch hands triggers to the notifier outside of the evaluator lock
+func (e *Evaluator) dispatch(triggers []Trigger) {
+	if e.notifier == nil {
+		return
+	}
+	for _, trigger := range triggers {
+		if err := e.notifier.Notify(trigger); err != nil {
+			e.logger.Printf("Failed to notify alert %s: %v", trigger.Alert.ID, err)
+		}
+	}
+}
+
+// SubmitBatch replays ticks in order against a dry-run copy of the evaluator and
+// returns every trigger that would fire, in firing order. Bar rules see the bars
+// completed by the batch, and the bars still open after the last tick are closed
+// at the end of it, so the final bar is evaluated too. The live evaluator state
+// and notifier are left untouched, and trigger times are the tick times, so
+// historical data can be backtested.
+func (e *Evaluator) SubmitBatch(ticks []market.SharePrice) []Trigger {
+	// Copy the alerts in symbol order so the replay is deterministic
+	e.mu.Lock()
+	symbols := make([]string, 0, len(e.alerts))
+	for symbol := range e.alerts {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	var alerts []Alert
+	for _, symbol := range symbols {
+		alerts = append(alerts, e.alerts[symbol]...)
+	}
+	epsilon := e.epsilon
+	allowStale := e.allowStaleThresholds
+	minMove := e.minMove
+	e.mu.Unlock()
+
+	var current time.Time
+	dry := NewEvaluator(nil)
+	dry.logger = log.New(io.Discard, "", 0)
+	dry.now = func() time.Time { return current }
+	dry.epsilon = epsilon
+	dry.allowStaleThresholds = allowStale
+	dry.minMove = minMove
+	dry.SetAlerts(alerts)
+
+	var triggers []Trigger
+	var aggregators []*market.BarAggregator
+	for _, interval := range BarIntervals(alerts) {
+		aggregators = append(aggregators, market.NewBarAggregator(interval, func(bar market.Bar) {
+			triggers = append(triggers, dry.EvaluateBar(bar)...)
+		}))
+	}
+
+	var latest time.Time
+	for _, tick := range ticks {
+		current = tick.Time
+		if tick.Time.After(latest) {
+			latest = tick.Time
+		}
+		for _, aggregator := range aggregators {
+			aggregator.Add(tick)
+		}
+		triggers = append(triggers, dry.EvaluatePrice(tick)...)
+	}
+
+	// Close the bars the batch left open at the end of the latest tick's interval
+	for _, aggregator := range aggregators {
+		aggregator.Flush(latest.Truncate(aggregator.Interval()).Add(aggregator.Interval()))
+	}
+	return triggers
+}
@@ -0,0 +1,262 @@
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"datafeed/pkg/market"
+)
+
+var testStart = time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+
+// firedPrices returns the tick prices of triggers
+func firedPrices(triggers []Trigger) []float64 {
+	var prices []float64
+	for _, trigger := range triggers {
+		prices = append(prices, trigger.Price)
+	}
+	return prices
+}
+
+// Ticks older than the symbol's newest are skipped by default; with stale
+// thresholds allowed they can fire an armed alert but never re-arm one
+func TestStaleTicks(t *testing.T) {
+	at := func(minute int) time.Time { return testStart.Add(time.Duration(minute) * time.Minute) }
+	ticks := []market.SharePrice{
+		{Symbol: "ACME", Price: 95, Time: at(1)},
+		{Symbol: "ACME", Price: 101, Time: at(3)},
+		// Stale: would re-arm the alert if it were accepted
+		{Symbol: "ACME", Price: 95, Time: at(2)},
+		{Symbol: "ACME", Price: 102, Time: at(4)},
+		{Symbol: "ACME", Price: 99, Time: at(5)},
+		// Stale: above the threshold while the alert is armed
+		{Symbol: "ACME", Price: 103, Time: at(2)},
+		{Symbol: "ACME", Price: 104, Time: at(6)},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		allowStale bool
+		want       []float64
+	}{
+		{name: "stale ticks skipped", allowStale: false, want: []float64{101, 104}},
+		{name: "stale thresholds allowed", allowStale: true, want: []float64{101, 103}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			evaluator := NewEvaluator(nil)
+			evaluator.SetAlerts([]Alert{{ID: "acme-above-100", Symbol: "ACME", Rule: RuleAbove, Price: 100}})
+			evaluator.SetAllowStaleThresholds(tc.allowStale)
+
+			if got := firedPrices(evaluator.SubmitBatch(ticks)); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("triggers at %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// Jitter smaller than the minimum move is not evaluated, a move of at least
+// the minimum is, and an alert's min_move overrides the global one
+func TestMinMove(t *testing.T) {
+	prices := []float64{
+		99.99,
+		// Sub-cent jitter across the 100.00 threshold
+		100.004, 99.996, 100.003,
+		// A real move up, then back down and up again
+		100.10, 99.90, 100.20,
+	}
+	var ticks []market.SharePrice
+	for i, price := range prices {
+		ticks = append(ticks, market.SharePrice{Symbol: "ACME", Price: price, Time: testStart.Add(time.Duration(i) * time.Second)})
+	}
+
+	for _, tc := range []struct {
+		name      string
+		minMove   float64
+		alertMove float64
+		want      []float64
+		skipped   uint64
+	}{
+		// Without a filter the jitter fires twice, and the real move finds the alert already fired
+		{name: "no minimum move", want: []float64{100.004, 100.003, 100.20}},
+		{name: "global min_move", minMove: 0.05, want: []float64{100.10, 100.20}, skipped: 3},
+		{name: "alert min_move over global", minMove: 1, alertMove: 0.05, want: []float64{100.10, 100.20}, skipped: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			evaluator := NewEvaluator(nil)
+			evaluator.SetAlerts([]Alert{{ID: "acme-above-100", Symbol: "ACME", Rule: RuleAbove, Price: 100, MinMove: tc.alertMove}})
+			evaluator.SetMinMove(tc.minMove)
+
+			var got []float64
+			for _, tick := range ticks {
+				got = append(got, firedPrices(evaluator.EvaluatePrice(tick))...)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("triggers at %v, want %v", got, tc.want)
+			}
+			if skipped := evaluator.MinMoveSkipped()["ACME"]; skipped != tc.skipped {
+				t.Errorf("%d ticks skipped, want %d", skipped, tc.skipped)
+			}
+		})
+	}
+}
+
+// A no_update alert fires once its symbol is silent past the threshold, stays
+// quiet while the symbol updates or the market is closed, and re-arms with
+// the next tick
+func TestNoUpdate(t *testing.T) {
+	now := testStart
+	evaluator := NewEvaluator(nil)
+	evaluator.SetClock(func() time.Time { return now })
+	evaluator.SetAlerts([]Alert{
+		{ID: "gp-silent", Symbol: "GP", Rule: RuleNoUpdate, Silence: 5 * time.Minute},
+		{ID: "acme-silent", Symbol: "ACME", Rule: RuleNoUpdate, Silence: 5 * time.Minute},
+	})
+
+	// step advances the clock a minute, delivers a tick for each updating
+	// symbol and checks what the silence check fires
+	minute := 0
+	want := map[int][]string{5: {"gp-silent"}, 12: {"gp-silent"}, 30: {"gp-silent"}}
+	step := func(updating ...string) {
+		t.Helper()
+		minute++
+		now = now.Add(time.Minute)
+		for _, symbol := range updating {
+			evaluator.EvaluatePrice(market.SharePrice{Symbol: symbol, Price: 100, Time: now})
+		}
+		var fired []string
+		for _, trigger := range evaluator.CheckSilence() {
+			fired = append(fired, trigger.Alert.ID)
+		}
+		if fmt.Sprint(fired) != fmt.Sprint(want[minute]) {
+			t.Errorf("minute %d: fired %v, want %v", minute, fired, want[minute])
+		}
+	}
+
+	// GP goes silent and fires at minute 5, once; ACME keeps updating
+	for minute < 6 {
+		step("ACME")
+	}
+	// A GP tick re-arms the alert; silent again, it fires 5 minutes later
+	step("ACME", "GP")
+	for minute < 15 {
+		step("ACME")
+	}
+	// While the market is closed nothing fires, and silence restarts on reopening
+	evaluator.EvaluateMarketStatus(market.MarketStatus{State: market.TradingStateClosed, RawState: "Closed"})
+	for minute < 25 {
+		step()
+	}
+	evaluator.EvaluateMarketStatus(market.MarketStatus{State: market.TradingStateOpen, RawState: "Open"})
+	for minute < 32 {
+		step("ACME")
+	}
+}
+
+// A halt alert fires when its symbol enters a halt, once per halt, and is
+// re-armed when the halt is cleared; a market-wide halt counts for every symbol
+func TestHalt(t *testing.T) {
+	evaluator := NewEvaluator(nil)
+	evaluator.SetAlerts([]Alert{
+		{ID: "gp-halt", Symbol: "GP", Rule: RuleHalt},
+		{ID: "gp-above", Symbol: "GP", Rule: RuleAbove, Price: 100},
+		{ID: "acme-halt", Symbol: "ACME", Rule: RuleHalt},
+	})
+	status := func(symbol, raw string) market.MarketStatus {
+		return market.MarketStatus{Symbol: symbol, State: market.NormalizeTradingState(raw), RawState: raw}
+	}
+
+	for _, step := range []struct {
+		status market.MarketStatus
+		halted bool
+		fired  []string
+	}{
+		{status: status("gp", "Halted"), halted: true, fired: []string{"gp-halt"}},
+		{status: status("GP", "Suspended"), halted: true},
+		{status: status("GP", "Unhalted"), halted: false},
+		{status: status("GP", "Halted"), halted: true, fired: []string{"gp-halt"}},
+		{status: status("GP", "Open"), halted: false},
+		{status: status("", "Halted"), halted: true, fired: []string{"acme-halt", "gp-halt"}},
+		{status: status("", "Open"), halted: false},
+	} {
+		var fired []string
+		for _, trigger := range evaluator.EvaluateMarketStatus(step.status) {
+			fired = append(fired, trigger.Alert.ID)
+		}
+		sort.Strings(fired)
+		if fmt.Sprint(fired) != fmt.Sprint(step.fired) {
+			t.Errorf("%q for %q fired %v, want %v", step.status.RawState, step.status.Symbol, fired, step.fired)
+		}
+		if evaluator.IsHalted("GP") != step.halted {
+			t.Errorf("after %q for %q GP halted is %v, want %v", step.status.RawState, step.status.Symbol, !step.halted, step.halted)
+		}
+	}
+}
+
+// A bar-close alert fires when the bar that closes above its threshold is
+// completed, not on the ticks inside it
+func TestBarCloseAlert(t *testing.T) {
+	evaluator := NewEvaluator(nil)
+	evaluator.SetAlerts([]Alert{{ID: "gp-close-above", Symbol: "GP", Rule: RuleBarCloseAbove, Price: 352, Interval: time.Minute}})
+	var fired []Trigger
+	aggregator := market.NewBarAggregator(time.Minute, func(bar market.Bar) {
+		fired = append(fired, evaluator.EvaluateBar(bar)...)
+	})
+	at := func(seconds int) time.Time { return testStart.Add(time.Duration(seconds) * time.Second) }
+
+	// The first minute only touches 355; the second one closes at 353
+	for _, tick := range []market.SharePrice{
+		{Symbol: "GP", Price: 350, Time: at(0)},
+		{Symbol: "GP", Price: 355, Time: at(30)},
+		{Symbol: "GP", Price: 351, Time: at(59)},
+		{Symbol: "GP", Price: 351, Time: at(60)},
+		{Symbol: "GP", Price: 353, Time: at(119)},
+	} {
+		aggregator.Add(tick)
+		if len(fired) > 0 {
+			t.Fatalf("fired %+v before the second bar completed", fired)
+		}
+	}
+	aggregator.Add(market.SharePrice{Symbol: "GP", Price: 349, Time: at(120)})
+	if len(fired) != 1 || fired[0].Price != 353 {
+		t.Fatalf("fired %+v, want one trigger at the 353 close", fired)
+	}
+	// The bar still open closes below the threshold, which re-arms the alert
+	aggregator.Flush(at(180))
+	if len(fired) != 1 {
+		t.Errorf("fired %+v on a close below the threshold", fired[1:])
+	}
+}
+
+// A batch fires tick and bar triggers in firing order, and closes the bars left
+// open after its last tick so the final bar is evaluated too
+func TestSubmitBatchFinalBar(t *testing.T) {
+	evaluator := NewEvaluator(nil)
+	evaluator.SetAlerts([]Alert{
+		{ID: "gp-above", Symbol: "GP", Rule: RuleAbove, Price: 352},
+		{ID: "gp-close-above", Symbol: "GP", Rule: RuleBarCloseAbove, Price: 352, Interval: time.Minute},
+		{ID: "batbc-low-below", Symbol: "BATBC", Rule: RuleBarLowBelow, Price: 500, Interval: time.Minute},
+	})
+	at := func(seconds int) time.Time { return testStart.Add(time.Duration(seconds) * time.Second) }
+	ticks := []market.SharePrice{
+		{Symbol: "GP", Price: 350, Time: at(0)},
+		{Symbol: "BATBC", Price: 510, Time: at(10)},
+		{Symbol: "GP", Price: 355, Time: at(30)},
+		{Symbol: "GP", Price: 351, Time: at(59)},
+		// Completes the first GP bar, which closes below the threshold
+		{Symbol: "GP", Price: 351, Time: at(60)},
+		{Symbol: "BATBC", Price: 495, Time: at(70)},
+		{Symbol: "GP", Price: 354, Time: at(100)},
+	}
+
+	var got []string
+	for _, trigger := range evaluator.SubmitBatch(ticks) {
+		got = append(got, fmt.Sprintf("%s@%v", trigger.Alert.ID, trigger.Price))
+	}
+	// Both second bars are only completed by the end of the batch
+	want := []string{"gp-above@355", "gp-above@354", "batbc-low-below@495", "gp-close-above@354"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("triggers %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package alert
+
+import (
+	"log"
+	"sync"
+	"text/template"
+
+	"datafeed/pkg/logging"
+)
+
+// Notifier delivers triggered alerts to their owners
+type Notifier interface {
+	Notify(trigger Trigger) error
+}
+
+// LogNotifier is a Notifier that only logs triggers
+type LogNotifier struct {
+	logger *log.Logger
+
+	mu       sync.Mutex
+	template *template.Template
+	// Parsed per-alert templates, keyed by their text
+	alertTemplates map[string]*template.Template
+}
+
+// NewLogNotifier creates a notifier that writes triggers to stdout
+func NewLogNotifier() *LogNotifier {
+	tmpl, err := ParseTemplate(DefaultMessageTemplate)
+	if err != nil {
+		panic(err)
+	}
+	return &LogNotifier{
+		logger:         logging.New("[AlertNotifier] "),
+		template:       tmpl,
+		alertTemplates: make(map[string]*template.Template),
+	}
+}
+
+// SetTemplate replaces the message template used for alerts without their own.
+// An invalid template is rejected and the current one kept.
+func (n *LogNotifier) SetTemplate(text string) error {
+	tmpl, err := ParseTemplate(text)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.template = tmpl
+	n.mu.Unlock()
+	return nil
+}
+
+// Notify logs the trigger's rendered message
+func (n *LogNotifier) Notify(trigger Trigger) error {
+	message, err := n.Message(trigger)
+	if err != nil {
+		return err
+	}
+	n.logger.Printf("🔔 Alert %s (user %s): %s", trigger.Alert.ID, trigger.Alert.UserID, message)
+	return nil
+}
+
+// Message renders the trigger with the alert's template, or the notifier's
+func (n *LogNotifier) Message(trigger Trigger) (string, error) {
+	n.mu.Lock()
+	tmpl := n.template
+	if text := trigger.Alert.Template; text != "" {
+		custom, ok := n.alertTemplates[text]
+		if !ok {
+			var err error
+			if custom, err = ParseTemplate(text); err != nil {
+				n.mu.Unlock()
+				return "", err
+			}
+			n.alertTemplates[text] = custom
+		}
+		tmpl = custom
+	}
+	n.mu.Unlock()
+
+	return render(tmpl, NewMessageData(trigger))
+}
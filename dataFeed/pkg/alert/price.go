@@ -0,0 +1,18 @@
+package alert
+
+// DefaultPriceEpsilon is the default tolerance for price comparisons. Feed prices
+// are quoted to two decimals, so anything closer than this to a threshold is the
+// threshold itself, only off by float representation.
+const DefaultPriceEpsilon = 1e-6
+
+// AtOrAbove reports whether price has reached threshold, treating values within
+// epsilon of the threshold as equal to it
+func AtOrAbove(price, threshold, epsilon float64) bool {
+	return price >= threshold-epsilon
+}
+
+// AtOrBelow reports whether price has fallen to threshold, treating values within
+// epsilon of the threshold as equal to it
+func AtOrBelow(price, threshold, epsilon float64) bool {
+	return price <= threshold+epsilon
+}
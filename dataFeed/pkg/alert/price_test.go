@@ -0,0 +1,62 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"datafeed/pkg/market"
+)
+
+// Prices within the tolerance of a threshold count as the threshold, on both
+// sides; prices a cent away do not
+func TestPriceTolerance(t *testing.T) {
+	const threshold = 100.00
+	for _, tc := range []struct {
+		price        float64
+		above, below bool
+	}{
+		{price: 99.99999999, above: true, below: true},
+		{price: 100.00000001, above: true, below: true},
+		{price: 100, above: true, below: true},
+		{price: 0.1 + 0.2 + 99.7, above: true, below: true},
+		{price: 99.99, above: false, below: true},
+		{price: 100.01, above: true, below: false},
+	} {
+		if got := AtOrAbove(tc.price, threshold, DefaultPriceEpsilon); got != tc.above {
+			t.Errorf("AtOrAbove(%v, %v) = %v, want %v", tc.price, threshold, got, tc.above)
+		}
+		if got := AtOrBelow(tc.price, threshold, DefaultPriceEpsilon); got != tc.below {
+			t.Errorf("AtOrBelow(%v, %v) = %v, want %v", tc.price, threshold, got, tc.below)
+		}
+	}
+	// Without a tolerance the float noise decides
+	if AtOrAbove(99.99999999, threshold, 0) {
+		t.Error("99.99999999 reached 100 with no tolerance")
+	}
+}
+
+// The evaluator fires an above alert on a price a hair under the threshold
+// and a below alert on one a hair over it
+func TestEvaluatorPriceTolerance(t *testing.T) {
+	for _, tc := range []struct {
+		rule  Rule
+		price float64
+	}{
+		{RuleAbove, 99.99999999},
+		{RuleBelow, 100.00000001},
+	} {
+		evaluator := NewEvaluator(nil)
+		evaluator.SetAlerts([]Alert{{ID: "gp", Symbol: "GP", Rule: tc.rule, Price: 100.00}})
+		triggers := evaluator.EvaluatePrice(market.SharePrice{Symbol: "GP", Price: tc.price, Time: testStart})
+		if len(triggers) != 1 {
+			t.Errorf("%s 100.00 at %v fired %d triggers, want 1", tc.rule, tc.price, len(triggers))
+		}
+
+		evaluator = NewEvaluator(nil)
+		evaluator.SetPriceEpsilon(0)
+		evaluator.SetAlerts([]Alert{{ID: "gp", Symbol: "GP", Rule: tc.rule, Price: 100.00}})
+		if triggers := evaluator.EvaluatePrice(market.SharePrice{Symbol: "GP", Price: tc.price, Time: testStart.Add(time.Second)}); len(triggers) != 0 {
+			t.Errorf("%s 100.00 at %v fired with no tolerance", tc.rule, tc.price)
+		}
+	}
+}
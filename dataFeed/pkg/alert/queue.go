@@ -0,0 +1,175 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"datafeed/pkg/market"
+)
+
+// OverflowPolicy decides what happens to a tick arriving at a full queue
+type OverflowPolicy string
+
+const (
+	// OverflowDropOldest discards the oldest queued tick to make room; the default,
+	// since the newest price matters most for thresholds
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	// OverflowDropNewest discards the arriving tick
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowBlock makes the producer wait for room, pushing back on the feed
+	OverflowBlock OverflowPolicy = "block"
+)
+
+// DefaultQueueSize is the queue capacity used when none is configured
+const DefaultQueueSize = 1000
+
+// ParseOverflowPolicy validates a configured policy; empty means OverflowDropOldest
+func ParseOverflowPolicy(raw string) (OverflowPolicy, error) {
+	switch policy := OverflowPolicy(raw); policy {
+	case "":
+		return OverflowDropOldest, nil
+	case OverflowDropOldest, OverflowDropNewest, OverflowBlock:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown overflow policy %q (use drop_oldest, drop_newest or block)", raw)
+}
+
+// QueueStats is a snapshot of the queue's backlog and counters
+type QueueStats struct {
+	Depth     int
+	Capacity  int
+	HighWater int // deepest backlog seen
+	Enqueued  uint64
+	Processed uint64
+	Dropped   uint64
+	Policy    OverflowPolicy
+}
+
+// TickQueue is a bounded queue in front of the evaluator, so a burst of ticks
+// cannot grow memory without limit. A single consumer runs the handler.
+type TickQueue struct {
+	capacity int
+	policy   OverflowPolicy
+	handle   func(market.SharePrice)
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	items    []market.SharePrice
+	closed   bool
+	done     chan struct{}
+
+	highWater int
+	enqueued  uint64
+	processed uint64
+	dropped   uint64
+}
+
+// NewTickQueue creates a queue of the given capacity calling handle for every tick
+func NewTickQueue(capacity int, policy OverflowPolicy, handle func(market.SharePrice)) *TickQueue {
+	if capacity <= 0 {
+		capacity = DefaultQueueSize
+	}
+	if policy == "" {
+		policy = OverflowDropOldest
+	}
+	q := &TickQueue{
+		capacity: capacity,
+		policy:   policy,
+		handle:   handle,
+		items:    make([]market.SharePrice, 0, capacity),
+		done:     make(chan struct{}),
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	return q
+}
+
+// Push queues a tick, applying the overflow policy when the queue is full.
+// It returns false when the tick itself was not queued.
+func (q *TickQueue) Push(tick market.SharePrice) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for !q.closed && len(q.items) >= q.capacity {
+		switch q.policy {
+		case OverflowDropNewest:
+			q.dropped++
+			return false
+		case OverflowDropOldest:
+			q.items = q.items[1:]
+			q.dropped++
+		default:
+			q.notFull.Wait()
+		}
+	}
+	if q.closed {
+		q.dropped++
+		return false
+	}
+
+	q.items = append(q.items, tick)
+	q.enqueued++
+	if len(q.items) > q.highWater {
+		q.highWater = len(q.items)
+	}
+	q.notEmpty.Signal()
+	return true
+}
+
+// Run hands queued ticks to the handler until the queue is stopped and drained
+func (q *TickQueue) Run() {
+	defer close(q.done)
+	for {
+		q.mu.Lock()
+		for len(q.items) == 0 && !q.closed {
+			q.notEmpty.Wait()
+		}
+		if len(q.items) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		tick := q.items[0]
+		q.items = q.items[1:]
+		q.notFull.Signal()
+		q.mu.Unlock()
+
+		q.handle(tick)
+
+		q.mu.Lock()
+		q.processed++
+		q.mu.Unlock()
+	}
+}
+
+// Stop refuses further ticks and waits until Run has drained the backlog or ctx is done
+func (q *TickQueue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the current backlog and counters
+func (q *TickQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QueueStats{
+		Depth:     len(q.items),
+		Capacity:  q.capacity,
+		HighWater: q.highWater,
+		Enqueued:  q.enqueued,
+		Processed: q.processed,
+		Dropped:   q.dropped,
+		Policy:    q.policy,
+	}
+}
@@ -0,0 +1,74 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"datafeed/pkg/market"
+)
+
+// A burst into a queue whose consumer has not started is handled according
+// to the overflow policy, and the counters agree
+func TestTickQueueOverflow(t *testing.T) {
+	const capacity, burst = 4, 10
+	for _, tc := range []struct {
+		policy    OverflowPolicy
+		processed []float64
+		dropped   uint64
+	}{
+		{policy: OverflowDropNewest, processed: []float64{1, 2, 3, 4}, dropped: 6},
+		{policy: OverflowDropOldest, processed: []float64{7, 8, 9, 10}, dropped: 6},
+		{policy: OverflowBlock, processed: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, dropped: 0},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			var seen []float64
+			queue := NewTickQueue(capacity, tc.policy, func(tick market.SharePrice) {
+				seen = append(seen, tick.Price)
+			})
+
+			pushed := make(chan struct{})
+			go func() {
+				defer close(pushed)
+				for i := 1; i <= burst; i++ {
+					queue.Push(market.SharePrice{Symbol: "ACME", Price: float64(i)})
+				}
+			}()
+
+			if tc.policy == OverflowBlock {
+				// The producer must be held back at capacity until the consumer starts
+				select {
+				case <-pushed:
+					t.Fatal("producer was not blocked by a full queue")
+				case <-time.After(100 * time.Millisecond):
+				}
+			} else {
+				<-pushed
+			}
+
+			if stats := queue.Stats(); stats.Depth != capacity || stats.HighWater != capacity {
+				t.Fatalf("depth %d and peak %d while saturated, want %d", stats.Depth, stats.HighWater, capacity)
+			}
+
+			go queue.Run()
+			<-pushed
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := queue.Stop(ctx); err != nil {
+				t.Fatalf("queue did not drain: %v", err)
+			}
+
+			if fmt.Sprint(seen) != fmt.Sprint(tc.processed) {
+				t.Errorf("processed %v, want %v", seen, tc.processed)
+			}
+			stats := queue.Stats()
+			if stats.Dropped != tc.dropped || stats.Processed != uint64(len(tc.processed)) || stats.Depth != 0 {
+				t.Errorf("got %+v, want dropped %d, processed %d, depth 0", stats, tc.dropped, len(tc.processed))
+			}
+			if queue.Push(market.SharePrice{Symbol: "ACME", Price: 99}) {
+				t.Error("stopped queue accepted a tick")
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package alert
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// DefaultMessageTemplate is used for alerts without a template of their own
+const DefaultMessageTemplate = "{{.Symbol}}: {{.Reason}} (alert {{.AlertID}})"
+
+// maxMessageLength bounds a rendered message so a template cannot flood the notifier
+const maxMessageLength = 1024
+
+// MessageData is what a message template can refer to, e.g.
+// "{{.Symbol}} hit {{.Price}} (rule {{.Rule}})"
+type MessageData struct {
+	AlertID   string
+	UserID    string
+	Symbol    string
+	Rule      Rule
+	Price     float64 // observed price
+	Threshold float64 // the alert's configured price
+	Interval  time.Duration
+	Reason    string
+	At        time.Time
+}
+
+// sampleMessageData exercises every field when validating a template
+var sampleMessageData = MessageData{
+	AlertID: "sample", UserID: "sample", Symbol: "GP", Rule: RuleAbove,
+	Price: 100.5, Threshold: 100, Interval: time.Minute, Reason: "price 100.50 is above 100.00",
+	At: time.Unix(0, 0).UTC(),
+}
+
+// ParseTemplate parses a message template and checks it renders, so a template
+// referring to unknown fields is rejected when it is configured rather than when
+// an alert fires
+func ParseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message template: %w", err)
+	}
+	if _, err := render(tmpl, sampleMessageData); err != nil {
+		return nil, fmt.Errorf("invalid message template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// NewMessageData describes a trigger for a message template
+func NewMessageData(trigger Trigger) MessageData {
+	return MessageData{
+		AlertID:   trigger.Alert.ID,
+		UserID:    trigger.Alert.UserID,
+		Symbol:    trigger.Symbol,
+		Rule:      trigger.Alert.Rule,
+		Price:     trigger.Price,
+		Threshold: trigger.Alert.Price,
+		Interval:  trigger.Alert.Interval,
+		Reason:    trigger.Reason,
+		At:        trigger.At,
+	}
+}
+
+func render(tmpl *template.Template, data MessageData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	message := buf.String()
+	if len(message) > maxMessageLength {
+		message = message[:maxMessageLength] + "…"
+	}
+	return message, nil
+}
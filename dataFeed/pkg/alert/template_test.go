@@ -0,0 +1,66 @@
+package alert
+
+import (
+	"strings"
+	"testing"
+
+	"datafeed/pkg/config"
+)
+
+// An alert's own template wins over the notifier's, which wins over the default
+func TestMessage(t *testing.T) {
+	trigger := Trigger{
+		Alert:  Alert{ID: "gp-above", UserID: "alice", Rule: RuleAbove, Price: 350},
+		Symbol: "GP",
+		Price:  351.5,
+		Reason: "price 351.50 is above 350.00",
+	}
+	custom := trigger
+	custom.Alert.Template = "{{.UserID}}: {{.Symbol}} {{.Rule}} {{.Threshold}}"
+
+	notifier := NewLogNotifier()
+	if got, err := notifier.Message(trigger); err != nil || got != "GP: price 351.50 is above 350.00 (alert gp-above)" {
+		t.Errorf("default template rendered %q (%v)", got, err)
+	}
+	if err := notifier.SetTemplate(`{{.Symbol}} hit {{printf "%.2f" .Price}}`); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := notifier.Message(trigger); err != nil || got != "GP hit 351.50" {
+		t.Errorf("message_template rendered %q (%v), want \"GP hit 351.50\"", got, err)
+	}
+	if got, err := notifier.Message(custom); err != nil || got != "alice: GP above 350" {
+		t.Errorf("alert template rendered %q (%v), want \"alice: GP above 350\"", got, err)
+	}
+
+	long := trigger
+	long.Alert.Template = strings.Repeat("{{.Symbol}}", maxMessageLength)
+	if got, _ := notifier.Message(long); len(got) != maxMessageLength+len("…") {
+		t.Errorf("rendered %d bytes, want the message cut at %d", len(got), maxMessageLength)
+	}
+}
+
+// A malformed template or one referring to an unknown field is rejected when
+// configured, and the notifier keeps its current template
+func TestMalformedTemplate(t *testing.T) {
+	for _, text := range []string{
+		"{{.Symbol",
+		"{{.Volume}}",
+		"{{.Symbol | nosuchfunc}}",
+	} {
+		t.Run(text, func(t *testing.T) {
+			notifier := NewLogNotifier()
+			if err := notifier.SetTemplate(text); err == nil {
+				t.Fatal("template was accepted")
+			}
+			got, err := notifier.Message(Trigger{Alert: Alert{ID: "a"}, Symbol: "GP", Reason: "r"})
+			if err != nil || got != "GP: r (alert a)" {
+				t.Errorf("rendered %q (%v) after the rejected template, want the default", got, err)
+			}
+
+			_, err = FromConfig([]config.AlertConfig{{ID: "gp", Symbol: "GP", Rule: "above", Price: 1, Template: text}})
+			if err == nil || !strings.HasPrefix(err.Error(), "alert gp: invalid message template") {
+				t.Errorf("got %v, want alert gp rejected", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileWatermarkStore keeps the evaluator's latest accepted tick time per symbol
+// in a small JSON file, so stale ticks are recognised across restarts
+type FileWatermarkStore struct {
+	path string
+}
+
+// NewFileWatermarkStore creates a store backed by the file at path
+func NewFileWatermarkStore(path string) *FileWatermarkStore {
+	return &FileWatermarkStore{path: path}
+}
+
+// Load reads the watermarks; it returns nil without an error when nothing was saved yet
+func (s *FileWatermarkStore) Load() (map[string]time.Time, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watermarks file: %w", err)
+	}
+
+	var watermarks map[string]time.Time
+	if err := json.Unmarshal(data, &watermarks); err != nil {
+		return nil, fmt.Errorf("failed to parse watermarks file %s: %w", s.path, err)
+	}
+	return watermarks, nil
+}
+
+// Save writes the watermarks atomically, so a crash never leaves a truncated file
+func (s *FileWatermarkStore) Save(watermarks map[string]time.Time) error {
+	data, err := json.MarshalIndent(watermarks, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary watermarks file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write watermarks file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write watermarks file: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,62 @@
+package alert
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"datafeed/pkg/market"
+)
+
+// Watermarks saved before a restart make the restarted evaluator skip a batch
+// re-sent out of order, which would otherwise fire against superseded prices
+func TestWatermarksPersisted(t *testing.T) {
+	at := func(minute int) time.Time { return testStart.Add(time.Duration(minute) * time.Minute) }
+	alerts := []Alert{{ID: "acme-above-100", Symbol: "ACME", Rule: RuleAbove, Price: 100}}
+	store := NewFileWatermarkStore(filepath.Join(t.TempDir(), "watermarks.json"))
+	if watermarks, err := store.Load(); watermarks != nil || err != nil {
+		t.Fatalf("got %v (%v) before the first save, want nothing", watermarks, err)
+	}
+
+	before := NewEvaluator(nil)
+	before.SetAlerts(alerts)
+	before.EvaluatePrice(market.SharePrice{Symbol: "ACME", Price: 95, Time: at(1)})
+	before.EvaluatePrice(market.SharePrice{Symbol: "acme", Price: 99, Time: at(5)})
+	if err := store.Save(before.Watermarks()); err != nil {
+		t.Fatal(err)
+	}
+
+	watermarks, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watermarks) != 1 || !watermarks["ACME"].Equal(at(5)) {
+		t.Fatalf("loaded %v, want ACME at %v", watermarks, at(5))
+	}
+	after := NewEvaluator(nil)
+	after.SetAlerts(alerts)
+	after.RestoreWatermarks(watermarks)
+
+	var fired []Trigger
+	for _, tick := range []market.SharePrice{
+		{Symbol: "ACME", Price: 101, Time: at(3)},
+		{Symbol: "ACME", Price: 102, Time: at(2)},
+		{Symbol: "ACME", Price: 103, Time: at(4)},
+	} {
+		fired = append(fired, after.EvaluatePrice(tick)...)
+	}
+	if len(fired) != 0 {
+		t.Errorf("the re-sent batch fired at %v", firedPrices(fired))
+	}
+	if skipped := after.StaleSkipped()["ACME"]; skipped != 3 {
+		t.Errorf("skipped %d stale ticks, want 3", skipped)
+	}
+
+	// A newer tick is evaluated and moves the watermark on
+	if fired := after.EvaluatePrice(market.SharePrice{Symbol: "ACME", Price: 104, Time: at(6)}); len(fired) != 1 {
+		t.Errorf("the tick after the watermark fired %d triggers, want 1", len(fired))
+	}
+	if got := after.Watermarks()["ACME"]; !got.Equal(at(6)) {
+		t.Errorf("watermark at %v, want %v", got, at(6))
+	}
+}
@@ -3,22 +3,37 @@ package auth
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 
+	"github.com/hello-api/pkg/httpclient"
+
 	"datafeed/pkg/config"
 )
 
-// Login authenticates to the remote service and returns a token
+// Login authenticates to the remote service and returns a token. Network
+// errors, 429 and 5xx responses are retried as configured by the http_* settings.
 func Login(cfg *config.Config) (string, error) {
+	return LoginContext(context.Background(), cfg)
+}
+
+// LoginContext is Login giving up, retries included, once ctx is done
+func LoginContext(ctx context.Context, cfg *config.Config) (string, error) {
 	payload := map[string]string{
 		"loginId":  cfg.Username,
 		"password": cfg.Password,
 		"deviceId": "d72dc7b5-14d2-4896-83e4-cfc7a3fd625f", // Replace with actual device ID if needed
 	}
 	body, _ := json.Marshal(payload)
-	resp, err := http.Post(cfg.LoginURL, "application/json", bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.LoginURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := newClient(cfg).Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -45,3 +60,20 @@ func Login(cfg *config.Config) (string, error) {
 	}
 	return "", errors.New("token not found in login response")
 }
+
+// newClient creates the outbound client for a login, logging attempts that
+// are retried
+func newClient(cfg *config.Config) *httpclient.Client {
+	clientCfg := cfg.HTTPClient()
+	clientCfg.Hooks.OnAttempt = func(attempt httpclient.Attempt) {
+		if attempt.Outcome != httpclient.OutcomeRetried {
+			return
+		}
+		if attempt.Err != nil {
+			log.Printf("⚠️ Login attempt %d to %s failed: %v, retrying", attempt.Attempt, attempt.Host, attempt.Err)
+		} else {
+			log.Printf("⚠️ Login attempt %d to %s responded %d, retrying", attempt.Attempt, attempt.Host, attempt.Status)
+		}
+	}
+	return httpclient.New(clientCfg)
+}
@@ -0,0 +1,82 @@
+// Package backoff provides the reconnect backoff shared by the feed clients
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Defaults shared by the clients
+const (
+	DefaultInitial    = 2 * time.Second
+	DefaultMultiplier = 1.5
+	DefaultJitter     = 0.1
+)
+
+// Strategy is an exponential backoff: the first delay is Initial, every further
+// delay is Multiplier times the previous one, capped at Max. Jitter spreads each
+// delay randomly by up to ±Jitter of its value so clients do not reconnect in
+// lockstep. A Strategy is not safe for concurrent use.
+type Strategy struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+
+	current  time.Duration
+	attempts int
+	random   func() float64
+}
+
+// New creates a strategy
+func New(initial, max time.Duration, multiplier, jitter float64) *Strategy {
+	return &Strategy{
+		Initial:    initial,
+		Max:        max,
+		Multiplier: multiplier,
+		Jitter:     jitter,
+		random:     rand.Float64,
+	}
+}
+
+// Next returns the delay before the next attempt and advances the strategy
+func (s *Strategy) Next() time.Duration {
+	if s.attempts == 0 {
+		s.current = s.Initial
+	} else {
+		s.current = time.Duration(float64(s.current) * s.Multiplier)
+	}
+	if s.Max > 0 && s.current > s.Max {
+		s.current = s.Max
+	}
+	s.attempts++
+
+	return s.jittered(s.current)
+}
+
+// Reset starts over from the initial delay, e.g. after a successful connection
+func (s *Strategy) Reset() {
+	s.current = 0
+	s.attempts = 0
+}
+
+// Attempts returns the number of delays handed out since the last reset
+func (s *Strategy) Attempts() int {
+	return s.attempts
+}
+
+// jittered spreads d by up to ±Jitter, never exceeding Max
+func (s *Strategy) jittered(d time.Duration) time.Duration {
+	if s.Jitter <= 0 || s.random == nil {
+		return d
+	}
+	spread := (s.random()*2 - 1) * s.Jitter
+	d = time.Duration(float64(d) * (1 + spread))
+	if s.Max > 0 && d > s.Max {
+		d = s.Max
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
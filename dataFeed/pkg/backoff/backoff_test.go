@@ -0,0 +1,59 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+// Delays grow by the multiplier up to the cap, and a reset starts over
+func TestNext(t *testing.T) {
+	s := New(2*time.Second, 10*time.Second, 1.5, 0)
+	want := []time.Duration{2 * time.Second, 3 * time.Second, 4500 * time.Millisecond, 6750 * time.Millisecond, 10 * time.Second, 10 * time.Second}
+	for i, delay := range want {
+		if got := s.Next(); got != delay {
+			t.Errorf("attempt %d: got %v, want %v", i+1, got, delay)
+		}
+	}
+	if s.Attempts() != len(want) {
+		t.Errorf("got %d attempts, want %d", s.Attempts(), len(want))
+	}
+
+	s.Reset()
+	if s.Attempts() != 0 {
+		t.Errorf("got %d attempts after a reset, want 0", s.Attempts())
+	}
+	if got := s.Next(); got != 2*time.Second {
+		t.Errorf("got %v after a reset, want the initial 2s", got)
+	}
+}
+
+// Jitter spreads a delay by up to ±Jitter of it, never past the cap
+func TestJitter(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		random float64
+		max    time.Duration
+		want   time.Duration
+	}{
+		{name: "lowest", random: 0, max: time.Minute, want: 9 * time.Second},
+		{name: "middle", random: 0.5, max: time.Minute, want: 10 * time.Second},
+		{name: "highest", random: 1, max: time.Minute, want: 11 * time.Second},
+		{name: "capped", random: 1, max: 10 * time.Second, want: 10 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := New(10*time.Second, tc.max, 2, 0.1)
+			s.random = func() float64 { return tc.random }
+			if got := s.Next(); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// With the real source every delay stays within the spread
+	s := New(time.Second, 0, 1, DefaultJitter)
+	for i := 0; i < 1000; i++ {
+		if got := s.Next(); got < 900*time.Millisecond || got > 1100*time.Millisecond {
+			t.Fatalf("got %v, want within ±10%% of 1s", got)
+		}
+	}
+}
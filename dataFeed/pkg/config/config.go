@@ -3,7 +3,9 @@ package config
 
 import (
 	"io/ioutil"
+	"time"
 
+	"github.com/hello-api/pkg/httpclient"
 	"gopkg.in/yaml.v2"
 )
 
@@ -13,6 +15,152 @@ type Config struct {
 	SignalRURL string `yaml:"signalr_url"`
 	Username   string `yaml:"username"`
 	Password   string `yaml:"password"`
+
+	// ShutdownTimeout is the grace period components get to drain on exit (e.g. "10s")
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// StatsFile, when set, persists connection stats across restarts
+	StatsFile string `yaml:"stats_file"`
+	// MaxReconnectAttempts is how many reconnects follow a drop before the
+	// client gives up and reports the feed failed (default 20)
+	MaxReconnectAttempts int `yaml:"max_reconnect_attempts"`
+	// APIURL is the base URL of the alerts API that live share prices are
+	// forwarded to when set, and that `datafeed replay --forward` backfills
+	// (e.g. "http://localhost:8080/v1")
+	APIURL string `yaml:"api_url"`
+	// APISecret signs forwarded requests; it is the API's WEBHOOK_SECRET_DATAFEED
+	APISecret string `yaml:"api_secret"`
+	// FailureWebhookURL, when set, receives a JSON notice once the client has
+	// given up reconnecting, so an operator can be paged
+	FailureWebhookURL string `yaml:"failure_webhook_url"`
+	// SubscriptionEscalateAfter is how long a subscription may keep failing
+	// before the status report flags the feed unhealthy (default 10m)
+	SubscriptionEscalateAfter time.Duration `yaml:"subscription_escalate_after"`
+
+	// LogOutput is where the datafeed logs go: stdout (default) or file
+	LogOutput string `yaml:"log_output"`
+	// LogFile is the log file of the file output
+	LogFile string `yaml:"log_file"`
+	// LogMaxSizeMB is the size a log file is rotated at (default 100)
+	LogMaxSizeMB int `yaml:"log_max_size_mb"`
+	// LogMaxBackups is how many rotated log files are kept (default 5)
+	LogMaxBackups int `yaml:"log_max_backups"`
+
+	// RawFrameLog, when set, is a file receiving every raw hub frame for debugging
+	RawFrameLog string `yaml:"raw_frame_log"`
+	// LifecycleLog, when set, is a file receiving every connect, disconnect,
+	// reconnect attempt and give-up as JSON lines, for postmortems
+	LifecycleLog string `yaml:"lifecycle_log"`
+	// LifecycleLogMaxSizeKB rotates the lifecycle log at this size (0 never
+	// rotates), keeping LifecycleLogMaxBackups rotated files (default 3)
+	LifecycleLogMaxSizeKB  int `yaml:"lifecycle_log_max_size_kb"`
+	LifecycleLogMaxBackups int `yaml:"lifecycle_log_max_backups"`
+	// ConnectionHistorySize is how many lifecycle events are kept in memory
+	// for the stats endpoint and the SIGUSR1 dump (default 100)
+	ConnectionHistorySize int `yaml:"connection_history_size"`
+	// StatsAddr, when set, serves GET /stats with the connection stats,
+	// lifecycle history and stage depths on this address (e.g. "127.0.0.1:9102")
+	StatsAddr string `yaml:"stats_addr"`
+
+	// SymbolDiscoveryWindow, when set, logs every distinct symbol seen by the
+	// feed over this window (e.g. "10m"), to help pick symbols for alerts
+	SymbolDiscoveryWindow time.Duration `yaml:"symbol_discovery_window"`
+
+	// Alerts watched locally by the datafeed evaluator
+	Alerts []AlertConfig `yaml:"alerts"`
+	// PriceEpsilon is the tolerance for price threshold comparisons (default 1e-6)
+	PriceEpsilon float64 `yaml:"price_epsilon"`
+	// MinMove skips ticks that moved less than this from the last price an
+	// above/below alert was evaluated at (0 evaluates every tick)
+	MinMove float64 `yaml:"min_move"`
+	// WatermarksFile, when set, persists the latest accepted tick time per symbol
+	// so ticks re-sent after a restart are recognised as stale
+	WatermarksFile string `yaml:"watermarks_file"`
+	// AllowStaleThresholds lets stale ticks still fire armed above/below alerts
+	AllowStaleThresholds bool `yaml:"allow_stale_thresholds"`
+	// EvaluationQueueSize bounds the ticks waiting for the evaluator (default 1000)
+	EvaluationQueueSize int `yaml:"evaluation_queue_size"`
+	// EvaluationOverflowPolicy is drop_oldest (default), drop_newest or block
+	EvaluationOverflowPolicy string `yaml:"evaluation_overflow_policy"`
+	// Messages from the SignalR client wait for the processor in a bounded
+	// queue. PipelineQueueSize bounds it (default 1000),
+	// PipelineOverflowPolicy is drop_oldest (default), drop_newest or block,
+	// PipelineMaxAge drops messages that waited longer (0 keeps them) and
+	// PipelineWorkers is the number of concurrent processors (default 1).
+	PipelineQueueSize      int           `yaml:"pipeline_queue_size"`
+	PipelineOverflowPolicy string        `yaml:"pipeline_overflow_policy"`
+	PipelineMaxAge         time.Duration `yaml:"pipeline_max_age"`
+	PipelineWorkers        int           `yaml:"pipeline_workers"`
+	// MessageTemplate is the text/template rendering alert notifications,
+	// unless an alert sets its own
+	MessageTemplate string `yaml:"message_template"`
+	// DecodePipelines are the stage sequences share price payloads are decoded
+	// with, tried in order (e.g. [[base64, brotli]]); empty keeps the defaults
+	DecodePipelines [][]string `yaml:"decode_pipelines"`
+	// SharePriceFields maps the index of each field in a tilde-delimited share
+	// price record to its name (symbol, price, volume, time); empty keeps the
+	// DSE layout symbol~price~volume
+	SharePriceFields map[int]string `yaml:"share_price_fields"`
+	// MaxMessageSize bounds the raw arguments of a SignalR message in bytes;
+	// larger messages are dropped (default 16MB)
+	MaxMessageSize int `yaml:"max_message_size"`
+	// MaxDecompressedSize bounds what a share price payload may decode to in
+	// bytes; larger payloads are dropped (default 64MB)
+	MaxDecompressedSize int64 `yaml:"max_decompressed_size"`
+
+	// SubscriptionProtocol is the server protocol version subscription
+	// arguments are laid out for: v1 (default) or v2
+	SubscriptionProtocol string `yaml:"subscription_protocol"`
+
+	// TransferFormat is the hub message encoding negotiated with the server:
+	// text (JSON, default) or binary (MessagePack)
+	TransferFormat string `yaml:"transfer_format"`
+
+	// CorrectClockSkew shifts tick times by the estimated server clock skew,
+	// once the server's pings carry timestamps
+	CorrectClockSkew bool `yaml:"correct_clock_skew"`
+
+	// Outbound HTTP calls retry network errors, 429 and 5xx responses.
+	// HTTPTimeout bounds each attempt (default 10s), HTTPMaxAttempts is the
+	// total tries (default 3) and HTTPMaxBackoff caps the wait between them
+	// (default 5s).
+	HTTPTimeout     time.Duration `yaml:"http_timeout"`
+	HTTPMaxAttempts int           `yaml:"http_max_attempts"`
+	HTTPMaxBackoff  time.Duration `yaml:"http_max_backoff"`
+}
+
+// HTTPClient returns the outbound HTTP client configuration; unset fields keep
+// the shared defaults
+func (c *Config) HTTPClient() httpclient.Config {
+	cfg := httpclient.DefaultConfig()
+	if c.HTTPTimeout > 0 {
+		cfg.Timeout = c.HTTPTimeout
+	}
+	if c.HTTPMaxAttempts > 0 {
+		cfg.MaxAttempts = c.HTTPMaxAttempts
+	}
+	if c.HTTPMaxBackoff > 0 {
+		cfg.MaxBackoff = c.HTTPMaxBackoff
+	}
+	return cfg
+}
+
+// AlertConfig describes an alert evaluated by the datafeed
+type AlertConfig struct {
+	ID     string `yaml:"id"`
+	UserID string `yaml:"user_id"`
+	Symbol string `yaml:"symbol"`
+	Rule   string `yaml:"rule"`
+	// Threshold for price rules
+	Price float64 `yaml:"price"`
+	// Bar interval for bar rules (e.g. "1m", "5m")
+	Interval time.Duration `yaml:"interval"`
+	// Template overrides message_template for this alert
+	Template string `yaml:"template"`
+	// MinMove overrides min_move for this alert
+	MinMove float64 `yaml:"min_move"`
+	// Silence without ticks after which a no_update alert fires (e.g. "10m")
+	Silence time.Duration `yaml:"silence"`
 }
 
 // Load loads configuration from a YAML file
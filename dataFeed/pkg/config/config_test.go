@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig writes a config file into a temporary directory and returns its path
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// share_price_fields is a map keyed by field index, in either YAML style; a
+// key that is not an index fails the load
+func TestLoadSharePriceFields(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		yaml    string
+		want    map[int]string
+		wantErr bool
+	}{
+		{name: "unset", yaml: "api_url: http://localhost:8080\n"},
+		{name: "empty", yaml: "share_price_fields: {}\n", want: map[int]string{}},
+		{
+			name: "block",
+			yaml: "share_price_fields:\n  0: symbol\n  2: price\n  5: volume\n  7: time\n",
+			want: map[int]string{0: "symbol", 2: "price", 5: "volume", 7: "time"},
+		},
+		{
+			name: "flow",
+			yaml: "share_price_fields: {1: symbol, 0: price}\n",
+			want: map[int]string{0: "price", 1: "symbol"},
+		},
+		{name: "quoted index", yaml: "share_price_fields:\n  \"0\": symbol\n", wantErr: true},
+		{name: "named key", yaml: "share_price_fields:\n  symbol: 0\n", wantErr: true},
+		{name: "list", yaml: "share_price_fields: [symbol, price]\n", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, tc.yaml))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", cfg.SharePriceFields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (cfg.SharePriceFields == nil) != (tc.want == nil) || len(cfg.SharePriceFields) != len(tc.want) {
+				t.Fatalf("got %v, want %v", cfg.SharePriceFields, tc.want)
+			}
+			for index, name := range tc.want {
+				if cfg.SharePriceFields[index] != name {
+					t.Errorf("got field %d %q, want %q", index, cfg.SharePriceFields[index], name)
+				}
+			}
+		})
+	}
+}
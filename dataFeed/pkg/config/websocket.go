@@ -1,8 +1,18 @@
 package config
 
+import "time"
+
 // WebSocketConfig contains configuration for the WebSocket connection
 type WebSocketConfig struct {
 	URL      string            `yaml:"url"`      // WebSocket server URL
 	Headers  map[string]string `yaml:"headers"`  // Additional headers to include in the connection
 	Protocol string            `yaml:"protocol"` // WebSocket subprotocol (if any)
+
+	// AppPingInterval is how often an application {"type":"ping"} heartbeat
+	// is sent, on top of the protocol ping frames; 0 disables it
+	AppPingInterval time.Duration `yaml:"app_ping_interval"`
+	// AppPongTimeout is how long a heartbeat may go without its {"type":"pong"}
+	// before the connection is dropped and reconnected; defaults to twice the
+	// interval
+	AppPongTimeout time.Duration `yaml:"app_pong_timeout"`
 }
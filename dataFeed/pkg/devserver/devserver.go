@@ -0,0 +1,133 @@
+// Package devserver is a mock of the exchange feed for local runs and tests.
+// It accepts any login, serves a SignalR hub that takes the datafeed's
+// subscriptions, and pushes the prices it is given to every connected client.
+package devserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/philippseith/signalr"
+)
+
+// Token is the access token every login gets
+const Token = "devserver-token"
+
+// Paths served by Server
+const (
+	LoginPath  = "/login"
+	HubPath    = "/hub"
+	InjectPath = "/inject"
+)
+
+// Price is a share price to push to the connected clients
+type Price struct {
+	Symbol string  `json:"symbol"`
+	Price  float64 `json:"price"`
+	Volume float64 `json:"volume"`
+}
+
+// Record is the share price record the exchange sends for p
+func (p Price) Record() string {
+	return p.Symbol + "~" + strconv.FormatFloat(p.Price, 'f', 2, 64) + "~" + strconv.FormatFloat(p.Volume, 'f', -1, 64)
+}
+
+// Server serves the login, the hub and the price injection endpoint
+type Server struct {
+	hub        signalr.Server
+	mux        *http.ServeMux
+	subscribed chan struct{}
+	once       sync.Once
+}
+
+// feedHub takes the subscriptions the datafeed makes on connect
+type feedHub struct {
+	signalr.Hub
+	server *Server
+}
+
+func (h *feedHub) SubscribeToMarketStatusUpdatedEvent(exchange string) {
+	h.server.markSubscribed()
+}
+
+// SubscribeToSharePriceUpdatedEvent takes the share price subscription in the
+// positional layout the datafeed sends
+func (h *feedHub) SubscribeToSharePriceUpdatedEvent(page, exchange, a, b, c, d, e, f, g, i, j, k interface{}) {
+	h.server.markSubscribed()
+}
+
+// quietLogger discards the hub's own logs
+type quietLogger struct{}
+
+func (quietLogger) Log(...interface{}) error { return nil }
+
+// New returns a Server whose hub runs until ctx is done
+func New(ctx context.Context) (*Server, error) {
+	s := &Server{mux: http.NewServeMux(), subscribed: make(chan struct{})}
+	hub, err := signalr.NewServer(ctx,
+		signalr.HubFactory(func() signalr.HubInterface { return &feedHub{server: s} }),
+		signalr.Logger(quietLogger{}, false),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("devserver: %w", err)
+	}
+	s.hub = hub
+	hub.MapHTTP(signalr.WithHTTPServeMux(s.mux), HubPath)
+	s.mux.HandleFunc(LoginPath, s.login)
+	s.mux.HandleFunc(InjectPath, s.inject)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Subscribed is closed once a client has made its first subscription, after
+// which it receives what is sent
+func (s *Server) Subscribed() <-chan struct{} {
+	return s.subscribed
+}
+
+func (s *Server) markSubscribed() {
+	s.once.Do(func() { close(s.subscribed) })
+}
+
+// SendSharePrice pushes p to every connected client
+func (s *Server) SendSharePrice(p Price) {
+	s.Send("SharePriceUpdated", p.Record())
+}
+
+// Send invokes method with args on every connected client
+func (s *Server) Send(method string, args ...interface{}) {
+	s.hub.HubClients().All().Send(method, args...)
+}
+
+// login answers any login the way the exchange does
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"accessToken": Token}})
+}
+
+// inject pushes the share price in the request body, e.g.
+// {"symbol":"GP","price":101.5,"volume":1000}
+func (s *Server) inject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var p Price
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || p.Symbol == "" || p.Price <= 0 {
+		http.Error(w, "want a JSON price with a symbol and a positive price", http.StatusBadRequest)
+		return
+	}
+	s.SendSharePrice(p)
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,100 @@
+package devserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"datafeed/pkg/auth"
+	"datafeed/pkg/config"
+	"datafeed/pkg/signalr"
+)
+
+func startServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	server, err := New(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return server, httpServer.URL
+}
+
+// The datafeed logs in, subscribes, and receives the injected price as the
+// exchange's share price record
+func TestInjectedPriceReachesClient(t *testing.T) {
+	server, url := startServer(t)
+	cfg := &config.Config{LoginURL: url + LoginPath, SignalRURL: url + HubPath}
+	token, err := auth.Login(cfg)
+	if err != nil || token != Token {
+		t.Fatalf("got token %q, %v; want %q", token, err, Token)
+	}
+	client := signalr.NewClient(cfg, token)
+	t.Cleanup(client.Close)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	select {
+	case <-server.Subscribed():
+	case <-time.After(10 * time.Second):
+		t.Fatal("the client never subscribed")
+	}
+
+	resp, err := http.Post(url+InjectPath, "application/json", strings.NewReader(`{"symbol":"GP","price":101.5,"volume":1000}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("inject got %s, want 202", resp.Status)
+	}
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case msg := <-client.Messages():
+			if msg.Method != "SharePriceUpdated" {
+				continue
+			}
+			if msg.Data != "GP~101.50~1000" {
+				t.Errorf("got record %v, want GP~101.50~1000", msg.Data)
+			}
+			return
+		case <-deadline:
+			t.Fatal("the injected price never arrived")
+		}
+	}
+}
+
+func TestInjectRejectsBadPrices(t *testing.T) {
+	_, url := startServer(t)
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"not json", http.MethodPost, `GP~101`, http.StatusBadRequest},
+		{"no symbol", http.MethodPost, `{"price":101}`, http.StatusBadRequest},
+		{"zero price", http.MethodPost, `{"symbol":"GP"}`, http.StatusBadRequest},
+		{"get", http.MethodGet, ``, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, url+InjectPath, strings.NewReader(tt.body))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("got %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package forwarder
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"datafeed/pkg/market"
+	"datafeed/pkg/signalr"
+)
+
+// BackfillReport summarises a capture replayed to the API
+type BackfillReport struct {
+	Frames      int
+	Prices      int
+	Batches     int
+	Accepted    int
+	Duplicates  int
+	Quarantined int
+	ShadowFired int
+	// Days are the symbol trading dates the API recomputed, in first-seen order
+	Days []RecomputedDay
+}
+
+// Backfill replays a raw frame capture, as written by raw_frame_log, through
+// processor and forwards the parsed share prices to the API as backfill,
+// batchSize at a time (at most MaxBatch). Every price keeps the time its frame
+// was recorded at, so the API files it under the right trading date. The API
+// skips prices it already has, so a capture can be replayed again after a
+// failure. processor must not be used for anything else.
+func Backfill(ctx context.Context, capture io.Reader, processor *signalr.MessageProcessor, forwarder *Forwarder, batchSize int) (*BackfillReport, error) {
+	if batchSize <= 0 || batchSize > MaxBatch {
+		batchSize = MaxBatch
+	}
+	report := &BackfillReport{}
+	seenDays := make(map[RecomputedDay]bool)
+
+	var frameTime time.Time
+	processor.SetClock(func() time.Time { return frameTime })
+	var pending []market.SharePrice
+	processor.OnSharePrice(func(price market.SharePrice) {
+		pending = append(pending, price)
+	})
+
+	flush := func(all bool) error {
+		for len(pending) >= batchSize || (all && len(pending) > 0) {
+			batch := pending[:min(batchSize, len(pending))]
+			result, err := forwarder.Forward(ctx, batch, true)
+			if err != nil {
+				return err
+			}
+			pending = pending[len(batch):]
+			report.Batches++
+			report.Accepted += result.Accepted
+			report.Duplicates += result.Duplicates
+			report.Quarantined += len(result.Quarantined)
+			report.ShadowFired += result.ShadowFired
+			for _, day := range result.Recomputed {
+				if !seenDays[day] {
+					seenDays[day] = true
+					report.Days = append(report.Days, day)
+				}
+			}
+		}
+		return nil
+	}
+
+	err := signalr.ReadRawFrames(capture, func(frame signalr.RawFrame) error {
+		report.Frames++
+		frameTime = frame.Time
+		before := len(pending)
+		processor.Process(frame.Message())
+		report.Prices += len(pending) - before
+		return flush(false)
+	})
+	if err != nil {
+		return report, err
+	}
+	return report, flush(true)
+}
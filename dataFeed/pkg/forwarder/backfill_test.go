@@ -0,0 +1,63 @@
+package forwarder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"datafeed/pkg/signalr"
+)
+
+// A capture is forwarded signed, flagged as backfill and stamped with the
+// recorded frame times, and replaying it again stores nothing twice
+func TestBackfill(t *testing.T) {
+	start := time.Date(2024, 3, 4, 4, 0, 0, 0, time.UTC)
+	frame := func(offset time.Duration, method string, arg string) string {
+		line, _ := json.Marshal(signalr.RawFrame{Time: start.Add(offset), Method: method, Args: []interface{}{arg}})
+		return string(line)
+	}
+	capture := strings.Join([]string{
+		frame(0, "SharePriceUpdated", "GP~350~100"),
+		frame(time.Minute, "MarketStatusUpdated^^DSE~", `{"symbol":"BATBC","status":"Halted"}`),
+		frame(2*time.Minute, "sharePriceUpdated", "GP~352~50|SQ~10~20"),
+		frame(3*time.Minute, "Ping", ""),
+		frame(4*time.Minute, "SharePriceUpdated", "GP~349~10"),
+	}, "\n") + "\n"
+
+	api, fwd := newFakePricesAPI(t, "backfill-secret")
+	var reports []*BackfillReport
+	for run := 0; run < 2; run++ {
+		report, err := Backfill(context.Background(), strings.NewReader(capture), signalr.NewMessageProcessor(), fwd, 2)
+		if err != nil {
+			t.Fatalf("run %d: %v", run+1, err)
+		}
+		reports = append(reports, report)
+	}
+
+	first, second := reports[0], reports[1]
+	if first.Frames != 5 || first.Prices != 4 || first.Batches != 2 || first.Accepted != 4 || first.Duplicates != 0 {
+		t.Errorf("first run got %+v, want 5 frames, 4 prices in 2 batches, all stored", *first)
+	}
+	if second.Accepted != 0 || second.Duplicates != 4 {
+		t.Errorf("second run got %+v, want the 4 prices already stored", *second)
+	}
+	if len(first.Days) != 1 || first.Days[0] != (RecomputedDay{Symbol: "GP", TradingDate: "2024-03-04"}) {
+		t.Errorf("recomputed days %v, want GP on 2024-03-04 once", first.Days)
+	}
+	api.checkFailures(t)
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for i, backfill := range api.backfill {
+		if !backfill {
+			t.Errorf("request %d not flagged as backfill", i+1)
+		}
+	}
+	want := []time.Time{start, start.Add(2 * time.Minute), start.Add(4 * time.Minute)}
+	if fmt.Sprint(api.times["GP"]) != fmt.Sprint(want) || fmt.Sprint(api.times["SQ"]) != fmt.Sprint(want[1:2]) {
+		t.Errorf("stored GP at %v and SQ at %v, want the recorded frame times", api.times["GP"], api.times["SQ"])
+	}
+}
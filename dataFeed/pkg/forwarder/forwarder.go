@@ -0,0 +1,171 @@
+// Package forwarder posts parsed share prices to the alerts API
+package forwarder
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hello-api/pkg/httpclient"
+
+	"datafeed/pkg/market"
+)
+
+// MaxBatch is the most ticks the API accepts in one request
+const MaxBatch = 5000
+
+// Headers of the API's webhook signature, see the API's common.VerifySignature
+const (
+	signatureHeader          = "X-Signature"
+	signatureTimestampHeader = "X-Signature-Timestamp"
+)
+
+// tick is a share price as the API's POST /prices takes it. The API rejects
+// prices with more than two decimal places, so they are sent rounded to the
+// paisa.
+type tick struct {
+	Symbol string      `json:"symbol"`
+	Price  json.Number `json:"price"`
+	Volume float64     `json:"volume"`
+	Time   time.Time   `json:"time"`
+	// ExchangeTime and FeedLagMs are sent when the record carried an exchange
+	// timestamp; the API adds its ingest lag to FeedLagMs
+	ExchangeTime *time.Time `json:"exchangeTime,omitempty"`
+	FeedLagMs    *int64     `json:"feedLagMs,omitempty"`
+}
+
+type ingestRequest struct {
+	Ticks    []tick `json:"ticks"`
+	Backfill bool   `json:"backfill,omitempty"`
+}
+
+// Result is the API's summary of a forwarded batch
+type Result struct {
+	Accepted    int               `json:"accepted"`
+	Quarantined []json.RawMessage `json:"quarantined"`
+	Fired       int               `json:"fired"`
+	Duplicates  int               `json:"duplicates"`
+	ShadowFired int               `json:"shadowFired"`
+	Recomputed  []RecomputedDay   `json:"recomputed"`
+}
+
+// RecomputedDay is a symbol's trading date whose statistics a backfill rebuilt
+type RecomputedDay struct {
+	Symbol      string `json:"symbol"`
+	TradingDate string `json:"tradingDate"`
+}
+
+// Forwarder posts share prices to the API's POST /prices, signed with the
+// datafeed webhook secret
+type Forwarder struct {
+	base   string
+	url    string
+	secret []byte
+	client *httpclient.Client
+}
+
+// New creates a forwarder to the API at apiURL using client, which retries
+// transient failures
+func New(apiURL, secret string, client *httpclient.Client) *Forwarder {
+	base := strings.TrimRight(apiURL, "/")
+	return &Forwarder{base: base, url: base + "/prices", secret: []byte(secret), client: client}
+}
+
+// Ready checks that the API answers its readiness probe, GET /readyz
+func (f *Forwarder) Ready(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.base+"/readyz", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("API readiness: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API readiness: got %s", resp.Status)
+	}
+	return nil
+}
+
+// Forward posts up to MaxBatch prices in one request. backfill marks prices
+// recorded earlier, which the API stores once, recomputes the day statistics
+// for and evaluates in shadow mode only.
+func (f *Forwarder) Forward(ctx context.Context, prices []market.SharePrice, backfill bool) (*Result, error) {
+	if len(prices) > MaxBatch {
+		return nil, fmt.Errorf("%d prices exceed the batch limit of %d", len(prices), MaxBatch)
+	}
+	body := ingestRequest{Ticks: make([]tick, len(prices)), Backfill: backfill}
+	for i, price := range prices {
+		body.Ticks[i] = tick{
+			Symbol: price.Symbol,
+			Price:  json.Number(strconv.FormatFloat(price.Price, 'f', 2, 64)),
+			Volume: price.Volume,
+			Time:   price.Time.UTC(),
+		}
+		if !price.ExchangeTime.IsZero() {
+			exchangeTime := price.ExchangeTime.UTC()
+			feedLag := price.FeedLag.Milliseconds()
+			body.Ticks[i].ExchangeTime = &exchangeTime
+			body.Ticks[i].FeedLagMs = &feedLag
+		}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signatureTimestampHeader, timestamp)
+	req.Header.Set(signatureHeader, "sha256="+sign(f.secret, timestamp, payload))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("forward prices: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("forward prices: %w", err)
+	}
+	var envelope struct {
+		Data  *Result `json:"data"`
+		Error *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return nil, fmt.Errorf("forward prices: API responded %s with an unreadable body", resp.Status)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || envelope.Data == nil {
+		if envelope.Error != nil {
+			return nil, fmt.Errorf("forward prices: API responded %s: %s %s", resp.Status, envelope.Error.Code, envelope.Error.Message)
+		}
+		return nil, fmt.Errorf("forward prices: API responded %s", resp.Status)
+	}
+	return envelope.Data, nil
+}
+
+// sign is the hex HMAC-SHA256 of "<timestamp>.<body>", as the API verifies it
+func sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,197 @@
+package forwarder
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hello-api/pkg/httpclient"
+
+	"datafeed/pkg/market"
+)
+
+// fakePricesAPI stores forwarded ticks once per symbol, time, price and
+// volume, like the API's POST /prices, and records what was wrong with each
+// request
+type fakePricesAPI struct {
+	secret string
+
+	mu       sync.Mutex
+	stored   map[string]bool
+	times    map[string][]time.Time
+	bodies   [][]byte
+	backfill []bool
+	failures []string
+}
+
+func newFakePricesAPI(t *testing.T, secret string) (*fakePricesAPI, *Forwarder) {
+	t.Helper()
+	api := &fakePricesAPI{secret: secret, stored: make(map[string]bool), times: make(map[string][]time.Time)}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	return api, New(server.URL, secret, httpclient.New(httpclient.DefaultConfig()))
+}
+
+func (a *fakePricesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	mac := hmac.New(sha256.New, []byte(a.secret))
+	mac.Write([]byte(r.Header.Get(signatureTimestampHeader) + "."))
+	mac.Write(body)
+	var req struct {
+		Ticks []struct {
+			Symbol string    `json:"symbol"`
+			Price  float64   `json:"price"`
+			Volume float64   `json:"volume"`
+			Time   time.Time `json:"time"`
+		} `json:"ticks"`
+		Backfill bool `json:"backfill"`
+	}
+	decodeErr := json.Unmarshal(body, &req)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.bodies = append(a.bodies, body)
+	a.backfill = append(a.backfill, req.Backfill)
+	switch {
+	case r.URL.Path != "/prices":
+		a.failures = append(a.failures, "posted to "+r.URL.Path)
+	case r.Header.Get(signatureHeader) != "sha256="+hex.EncodeToString(mac.Sum(nil)):
+		a.failures = append(a.failures, "request signature does not verify")
+	case decodeErr != nil:
+		a.failures = append(a.failures, "undecodable body: "+decodeErr.Error())
+	}
+
+	accepted, duplicates := 0, 0
+	for _, tick := range req.Ticks {
+		key := fmt.Sprintf("%s|%s|%v|%v", tick.Symbol, tick.Time.Format(time.RFC3339Nano), tick.Price, tick.Volume)
+		if a.stored[key] {
+			duplicates++
+			continue
+		}
+		a.stored[key] = true
+		a.times[tick.Symbol] = append(a.times[tick.Symbol], tick.Time)
+		accepted++
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, `{"success":true,"data":{"accepted":%d,"duplicates":%d,"quarantined":[],"recomputed":[{"symbol":"GP","tradingDate":"2024-03-04"}]}}`, accepted, duplicates)
+}
+
+// checkFailures reports every request the fake API found fault with
+func (a *fakePricesAPI) checkFailures(t *testing.T) {
+	t.Helper()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, failure := range a.failures {
+		t.Error(failure)
+	}
+}
+
+// Prices are posted signed, rounded to the paisa, and carry the feed lag in
+// milliseconds next to the exchange time only when they have one
+func TestForward(t *testing.T) {
+	api, fwd := newFakePricesAPI(t, "forward-secret")
+	now := time.Date(2024, 3, 4, 8, 32, 52, 0, time.UTC)
+	prices := []market.SharePrice{
+		{Symbol: "GP", Price: 350.456, Volume: 1200, Time: now, ExchangeTime: now.Add(-47 * time.Second), FeedLag: 47 * time.Second},
+		{Symbol: "SQ", Price: 12.3, Volume: 10, Time: now, ExchangeTime: now.Add(-1500 * time.Millisecond), FeedLag: 1500 * time.Millisecond},
+		{Symbol: "ACI", Price: 210, Volume: 5, Time: now, ExchangeTime: now.Add(4 * time.Second)},
+		{Symbol: "BEXIMCO", Price: 115, Volume: 3, Time: now},
+	}
+
+	result, err := fwd.Forward(context.Background(), prices, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Accepted != len(prices) {
+		t.Errorf("%d accepted, want %d", result.Accepted, len(prices))
+	}
+	api.checkFailures(t)
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.bodies) != 1 || api.backfill[0] {
+		t.Fatalf("got %d requests, backfill %v; want one live request", len(api.bodies), api.backfill)
+	}
+	var sent struct {
+		Ticks []struct {
+			Symbol       string      `json:"symbol"`
+			Price        json.Number `json:"price"`
+			ExchangeTime *time.Time  `json:"exchangeTime"`
+			FeedLagMs    *int64      `json:"feedLagMs"`
+		} `json:"ticks"`
+	}
+	if err := json.Unmarshal(api.bodies[0], &sent); err != nil {
+		t.Fatal(err)
+	}
+	lags := make(map[string]string)
+	for _, tick := range sent.Ticks {
+		switch {
+		case tick.FeedLagMs == nil && tick.ExchangeTime == nil:
+			lags[tick.Symbol] = "none"
+		case tick.FeedLagMs != nil && tick.ExchangeTime != nil:
+			lags[tick.Symbol] = strconv.FormatInt(*tick.FeedLagMs, 10)
+		default:
+			lags[tick.Symbol] = "half"
+		}
+	}
+	want := map[string]string{"GP": "47000", "SQ": "1500", "ACI": "0", "BEXIMCO": "none"}
+	if fmt.Sprint(lags) != fmt.Sprint(want) {
+		t.Errorf("feed lags %v, want %v", lags, want)
+	}
+	if sent.Ticks[0].Price != "350.46" {
+		t.Errorf("GP sent at %s, want 350.46", sent.Ticks[0].Price)
+	}
+}
+
+func TestForwardRejectsOversizedBatch(t *testing.T) {
+	api, fwd := newFakePricesAPI(t, "forward-secret")
+	if _, err := fwd.Forward(context.Background(), make([]market.SharePrice, MaxBatch+1), false); err == nil {
+		t.Fatal("oversized batch was forwarded")
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.bodies) != 0 {
+		t.Errorf("%d requests sent for an oversized batch", len(api.bodies))
+	}
+}
+
+// Ready passes only when the API's readiness probe answers 200
+func TestReady(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "ready", status: http.StatusOK},
+		{name: "database down", status: http.StatusServiceUnavailable, wantErr: true},
+		{name: "not the API", status: http.StatusNotFound, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var path string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				w.WriteHeader(tc.status)
+			}))
+			t.Cleanup(server.Close)
+			client := httpclient.New(httpclient.Config{Timeout: time.Second, MaxAttempts: 1})
+			err := New(server.URL+"/v1/", "secret", client).Ready(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got %v, want an error %t", err, tc.wantErr)
+			}
+			if path != "/v1/readyz" {
+				t.Errorf("probed %s, want /v1/readyz", path)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package forwarder
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"datafeed/pkg/logging"
+	"datafeed/pkg/market"
+)
+
+// Stream defaults used when NewStream is given zero values
+const (
+	DefaultStreamCapacity = MaxBatch
+	DefaultStreamInterval = time.Second
+)
+
+// StreamStats counts the prices a Stream has handled
+type StreamStats struct {
+	Pending   int
+	Forwarded uint64
+	// Failed prices were in a batch the API did not accept; they are not retried
+	Failed  uint64
+	Dropped uint64
+}
+
+// Stream forwards live share prices to the API in batches, one request per
+// interval at most, so a slow API holds up neither the feed nor the alert
+// evaluation. Once capacity prices are pending the oldest is dropped for the
+// next, since the API only keeps the latest price of a symbol anyway.
+type Stream struct {
+	forwarder *Forwarder
+	capacity  int
+	interval  time.Duration
+	logger    *log.Logger
+
+	mu      sync.Mutex
+	pending []market.SharePrice
+	closed  bool
+	stats   StreamStats
+
+	// ctx bounds the requests in flight; Stop cancels it when its own deadline passes
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewStream creates a Stream sending through forwarder; 0 capacity and
+// interval mean DefaultStreamCapacity and DefaultStreamInterval
+func NewStream(forwarder *Forwarder, capacity int, interval time.Duration) *Stream {
+	if capacity <= 0 {
+		capacity = DefaultStreamCapacity
+	}
+	if interval <= 0 {
+		interval = DefaultStreamInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Stream{
+		forwarder: forwarder,
+		capacity:  capacity,
+		interval:  interval,
+		logger:    logging.New("[Forwarder] "),
+		ctx:       ctx,
+		cancel:    cancel,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Push queues a price for the next batch; prices pushed after Stop are dropped
+func (s *Stream) Push(tick market.SharePrice) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		s.stats.Dropped++
+		return
+	}
+	if len(s.pending) >= s.capacity {
+		s.pending = s.pending[1:]
+		s.stats.Dropped++
+	}
+	s.pending = append(s.pending, tick)
+}
+
+// Run forwards the pending prices every interval until Stop, then forwards
+// what is left
+func (s *Stream) Run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stop:
+			for s.flush() {
+			}
+			return
+		}
+	}
+}
+
+// flush forwards up to MaxBatch pending prices and reports whether there were any
+func (s *Stream) flush() bool {
+	s.mu.Lock()
+	n := min(len(s.pending), MaxBatch)
+	batch := s.pending[:n:n]
+	s.pending = s.pending[n:]
+	s.mu.Unlock()
+	if n == 0 {
+		return false
+	}
+
+	result, err := s.forwarder.Forward(s.ctx, batch, false)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.stats.Failed += uint64(n)
+		s.logger.Printf("⚠️ Failed to forward %d prices: %v", n, err)
+		return true
+	}
+	s.stats.Forwarded += uint64(n)
+	if len(result.Quarantined) > 0 {
+		s.logger.Printf("⚠️ The API quarantined %d of %d forwarded prices", len(result.Quarantined), n)
+	}
+	return true
+}
+
+// Stop refuses further prices and waits until Run has forwarded the backlog
+// or ctx is done, in which case the request in flight is abandoned
+func (s *Stream) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.stopOnce.Do(func() { close(s.stop) })
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+// Stats returns the backlog and counters
+func (s *Stream) Stats() StreamStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.Pending = len(s.pending)
+	return stats
+}
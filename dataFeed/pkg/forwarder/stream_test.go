@@ -0,0 +1,79 @@
+package forwarder
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"datafeed/pkg/market"
+)
+
+// Prices pushed to a running stream reach the API in batches of at most
+// MaxBatch, and those still pending when it stops are sent before Stop returns
+func TestStream(t *testing.T) {
+	api, fwd := newFakePricesAPI(t, "stream-secret")
+	stream := NewStream(fwd, 2*MaxBatch, time.Hour)
+	go stream.Run()
+
+	start := time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)
+	total := MaxBatch + 10
+	for i := 0; i < total; i++ {
+		stream.Push(market.SharePrice{Symbol: "GP", Price: 350, Volume: 1, Time: start.Add(time.Duration(i) * time.Millisecond)})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := stream.Stop(ctx); err != nil {
+		t.Fatalf("stream did not drain: %v", err)
+	}
+	stream.Push(market.SharePrice{Symbol: "GP", Price: 351, Time: start})
+	api.checkFailures(t)
+
+	stats := stream.Stats()
+	if stats.Forwarded != uint64(total) || stats.Failed != 0 || stats.Dropped != 1 || stats.Pending != 0 {
+		t.Errorf("got %+v, want %d forwarded and the price pushed after Stop dropped", stats, total)
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.bodies) != 2 || len(api.times["GP"]) != total {
+		t.Errorf("got %d requests storing %d prices, want 2 storing %d", len(api.bodies), len(api.times["GP"]), total)
+	}
+	for i, backfill := range api.backfill {
+		if backfill {
+			t.Errorf("request %d was sent as backfill", i)
+		}
+	}
+}
+
+// A full stream drops its oldest prices, and a batch the API refuses is
+// counted as failed rather than retried
+func TestStreamOverflowAndFailure(t *testing.T) {
+	_, fwd := newFakePricesAPI(t, "stream-secret")
+	down := New("http://127.0.0.1:1", "stream-secret", fwd.client)
+	for _, tc := range []struct {
+		name          string
+		forwarder     *Forwarder
+		wantForwarded uint64
+		wantFailed    uint64
+	}{
+		{name: "API up", forwarder: fwd, wantForwarded: 3},
+		{name: "API down", forwarder: down, wantFailed: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stream := NewStream(tc.forwarder, 3, time.Hour)
+			start := time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)
+			for i := 0; i < 5; i++ {
+				stream.Push(market.SharePrice{Symbol: "SQ", Price: 12, Time: start.Add(time.Duration(i) * time.Second)})
+			}
+			go stream.Run()
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := stream.Stop(ctx); err != nil {
+				t.Fatalf("stream did not drain: %v", err)
+			}
+			stats := stream.Stats()
+			if stats.Dropped != 2 || stats.Forwarded != tc.wantForwarded || stats.Failed != tc.wantFailed {
+				t.Errorf("got %+v, want 2 dropped, %d forwarded and %d failed", stats, tc.wantForwarded, tc.wantFailed)
+			}
+		})
+	}
+}
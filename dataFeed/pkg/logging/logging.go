@@ -0,0 +1,106 @@
+// Package logging provides the output shared by all datafeed loggers
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// Outputs of the log_output setting
+const (
+	OutputStdout = "stdout"
+	OutputFile   = "file"
+)
+
+// Defaults of the log file rotation
+const (
+	DefaultMaxSizeMB  = 100
+	DefaultMaxBackups = 5
+)
+
+// output is where every logger created by New writes. Loggers are created by
+// the components before or after Configure; either way they follow it.
+var output = &switchWriter{w: os.Stdout}
+
+// switchWriter forwards writes to a destination that can be swapped at runtime
+type switchWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+func (s *switchWriter) set(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w = w
+}
+
+// New creates a logger with the standard flags writing to the shared output
+func New(prefix string) *log.Logger {
+	return log.New(output, prefix, log.LstdFlags)
+}
+
+// SetOutput sends the shared output, and the standard logger, to w
+func SetOutput(w io.Writer) {
+	output.set(w)
+	log.SetOutput(output)
+}
+
+// Configure sends all logs to the destination of the log_output setting:
+// stdout (the default) or a file rotated once it reaches maxSizeMB, keeping
+// maxBackups rotated files. The returned closer closes the file.
+func Configure(destination, path string, maxSizeMB, maxBackups int) (io.Closer, error) {
+	switch destination {
+	case "", OutputStdout:
+		SetOutput(os.Stdout)
+		return nopCloser{}, nil
+	case OutputFile:
+		if path == "" {
+			return nil, fmt.Errorf("log_output file needs log_file")
+		}
+		if maxSizeMB <= 0 {
+			maxSizeMB = DefaultMaxSizeMB
+		}
+		if maxBackups <= 0 {
+			maxBackups = DefaultMaxBackups
+		}
+		file, err := OpenRotatingFile(path, int64(maxSizeMB)<<20, maxBackups)
+		if err != nil {
+			return nil, err
+		}
+		SetOutput(file)
+		return file, nil
+	}
+	return nil, fmt.Errorf("unknown log_output %q (known: %s, %s)", destination, OutputStdout, OutputFile)
+}
+
+// CheckOutput validates the settings Configure takes without switching the
+// output: the destination is known and a log file can be opened for appending
+func CheckOutput(destination, path string) error {
+	switch destination {
+	case "", OutputStdout:
+		return nil
+	case OutputFile:
+		if path == "" {
+			return fmt.Errorf("log_output file needs log_file")
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		return file.Close()
+	}
+	return fmt.Errorf("unknown log_output %q (known: %s, %s)", destination, OutputStdout, OutputFile)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
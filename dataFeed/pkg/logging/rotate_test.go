@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Lines logged through the shared output land in the rotating file and its
+// backups, no file grows past the limit and the oldest backup is dropped
+func TestRotatingFile(t *testing.T) {
+	const (
+		maxSize    = 1024
+		maxBackups = 2
+		lines      = 80
+	)
+	path := filepath.Join(t.TempDir(), "datafeed.log")
+	file, err := OpenRotatingFile(path, maxSize, maxBackups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetOutput(file)
+	defer SetOutput(os.Stdout)
+
+	// Each line is about 45 bytes, so the file rotates every 20 or so lines
+	// and only the newest lines survive in the current file and two backups
+	prefixes := []string{"[SignalR] ", "[SignalR Receiver] ", "[MsgProcessor] "}
+	for i := 0; i < lines; i++ {
+		New(prefixes[i%len(prefixes)]).Printf("line %03d", i)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	kept := 0
+	found := make(map[string]int)
+	var sizes []int64
+	for i := 0; ; i++ {
+		name := path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", path, i)
+		}
+		f, err := os.Open(name)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var size int64
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			size += int64(len(line)) + 1
+			kept++
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(line, prefix) {
+					found[prefix]++
+				}
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, size)
+	}
+
+	if len(sizes) != maxBackups+1 {
+		t.Fatalf("got %d files, want the log file and %d backups", len(sizes), maxBackups)
+	}
+	for i, size := range sizes {
+		if size > maxSize {
+			t.Errorf("file %d is %d bytes, past the %d byte limit", i, size, maxSize)
+		}
+	}
+	if kept >= lines {
+		t.Errorf("all %d lines kept, want the oldest backup dropped", kept)
+	}
+	for _, prefix := range prefixes {
+		if found[prefix] == 0 {
+			t.Errorf("no %q lines in the log files", prefix)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package market
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Bar is an OHLC bar for one symbol over one interval
+type Bar struct {
+	Symbol   string
+	Interval time.Duration
+	Start    time.Time
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	Volume   float64
+	Ticks    int
+}
+
+// End returns the exclusive end time of the bar
+func (b Bar) End() time.Time {
+	return b.Start.Add(b.Interval)
+}
+
+// BarHandler receives completed bars
+type BarHandler func(bar Bar)
+
+// BarAggregator rolls ticks into OHLC bars per symbol. A bar is emitted once a tick
+// for the same symbol arrives in a later interval, or when Flush is called.
+type BarAggregator struct {
+	interval time.Duration
+	onBar    BarHandler
+
+	mu   sync.Mutex
+	open map[string]*Bar
+	// Start of the last emitted bar per symbol, to drop late ticks
+	emitted map[string]time.Time
+}
+
+// NewBarAggregator creates an aggregator emitting completed bars of the given interval
+func NewBarAggregator(interval time.Duration, onBar BarHandler) *BarAggregator {
+	return &BarAggregator{
+		interval: interval,
+		onBar:    onBar,
+		open:     make(map[string]*Bar),
+		emitted:  make(map[string]time.Time),
+	}
+}
+
+// Interval returns the bar interval
+func (a *BarAggregator) Interval() time.Duration {
+	return a.interval
+}
+
+// Add folds a tick into its symbol's current bar, emitting the previous bar if the
+// tick starts a new interval. Ticks older than the current bar are ignored.
+func (a *BarAggregator) Add(tick SharePrice) {
+	start := tick.Time.Truncate(a.interval)
+
+	a.mu.Lock()
+	if last, emitted := a.emitted[tick.Symbol]; emitted && !start.After(last) {
+		// Late tick for an interval that was already emitted
+		a.mu.Unlock()
+		return
+	}
+
+	var completed *Bar
+	bar, ok := a.open[tick.Symbol]
+	switch {
+	case ok && start.Before(bar.Start):
+		// Late tick for an interval older than the open bar
+		a.mu.Unlock()
+		return
+	case ok && start.Equal(bar.Start):
+		if tick.Price > bar.High {
+			bar.High = tick.Price
+		}
+		if tick.Price < bar.Low {
+			bar.Low = tick.Price
+		}
+		bar.Close = tick.Price
+		bar.Volume += tick.Volume
+		bar.Ticks++
+		a.mu.Unlock()
+		return
+	case ok:
+		done := *bar
+		completed = &done
+		a.emitted[tick.Symbol] = done.Start
+	}
+	a.open[tick.Symbol] = &Bar{
+		Symbol:   tick.Symbol,
+		Interval: a.interval,
+		Start:    start,
+		Open:     tick.Price,
+		High:     tick.Price,
+		Low:      tick.Price,
+		Close:    tick.Price,
+		Volume:   tick.Volume,
+		Ticks:    1,
+	}
+	a.mu.Unlock()
+
+	if completed != nil && a.onBar != nil {
+		a.onBar(*completed)
+	}
+}
+
+// Flush emits every bar whose interval ended at or before now, e.g. on a timer or at shutdown
+func (a *BarAggregator) Flush(now time.Time) {
+	a.mu.Lock()
+	var completed []Bar
+	for symbol, bar := range a.open {
+		if !bar.End().After(now) {
+			completed = append(completed, *bar)
+			a.emitted[symbol] = bar.Start
+			delete(a.open, symbol)
+		}
+	}
+	a.mu.Unlock()
+
+	if a.onBar == nil {
+		return
+	}
+	// Emit in time then symbol order, so flushes are deterministic
+	sort.Slice(completed, func(i, j int) bool {
+		if !completed[i].Start.Equal(completed[j].Start) {
+			return completed[i].Start.Before(completed[j].Start)
+		}
+		return completed[i].Symbol < completed[j].Symbol
+	})
+	for _, bar := range completed {
+		a.onBar(bar)
+	}
+}
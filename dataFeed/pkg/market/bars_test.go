@@ -0,0 +1,51 @@
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+// Ticks are rolled into one bar per interval; the bar is emitted when a tick
+// of a later interval arrives, late ticks are dropped, and Flush emits only
+// the bars whose interval has ended
+func TestBarAggregator(t *testing.T) {
+	start := time.Date(2024, 3, 4, 4, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	var bars []Bar
+	aggregator := NewBarAggregator(time.Minute, func(bar Bar) { bars = append(bars, bar) })
+
+	for _, tick := range []SharePrice{
+		{Symbol: "GP", Price: 350, Volume: 10, Time: at(5)},
+		{Symbol: "GP", Price: 353, Volume: 5, Time: at(20)},
+		{Symbol: "BATBC", Price: 512, Volume: 1, Time: at(30)},
+		{Symbol: "GP", Price: 348, Volume: 1, Time: at(40)},
+		{Symbol: "GP", Price: 351, Volume: 4, Time: at(59)},
+		// First tick of the next minute closes the GP bar
+		{Symbol: "GP", Price: 352, Volume: 2, Time: at(61)},
+		// Late for the closed bar
+		{Symbol: "GP", Price: 400, Volume: 100, Time: at(50)},
+	} {
+		aggregator.Add(tick)
+	}
+
+	want := Bar{Symbol: "GP", Interval: time.Minute, Start: start, Open: 350, High: 353, Low: 348, Close: 351, Volume: 20, Ticks: 4}
+	if len(bars) != 1 || bars[0] != want {
+		t.Fatalf("emitted %+v, want %+v", bars, want)
+	}
+
+	// BATBC's minute has ended, GP's second one has not
+	aggregator.Flush(at(90))
+	if len(bars) != 2 || bars[1].Symbol != "BATBC" || bars[1].Close != 512 {
+		t.Fatalf("flush emitted %+v, want the BATBC bar only", bars[1:])
+	}
+	aggregator.Flush(at(120))
+	if len(bars) != 3 || bars[2].Symbol != "GP" || !bars[2].Start.Equal(at(60)) || bars[2].Ticks != 1 {
+		t.Fatalf("flush emitted %+v, want the second GP bar", bars[2:])
+	}
+	// A tick for a flushed interval is late
+	aggregator.Add(SharePrice{Symbol: "BATBC", Price: 1, Time: at(45)})
+	aggregator.Flush(at(600))
+	if len(bars) != 3 {
+		t.Errorf("late tick produced %+v", bars[3:])
+	}
+}
@@ -0,0 +1,88 @@
+package market
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DiscoveredSymbol is a symbol seen in the feed, with the raw forms it arrived in
+type DiscoveredSymbol struct {
+	// Symbol is the canonical form alerts should use
+	Symbol    string
+	RawForms  []string
+	Ticks     uint64
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// SymbolDiscovery records the distinct symbols seen in the feed over a sliding
+// window, so users can pick valid symbols for alerts
+type SymbolDiscovery struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	symbols map[string]*DiscoveredSymbol
+}
+
+// NewSymbolDiscovery creates a discovery keeping symbols seen within window.
+// A zero window keeps every symbol seen since start.
+func NewSymbolDiscovery(window time.Duration) *SymbolDiscovery {
+	return &SymbolDiscovery{
+		window:  window,
+		symbols: make(map[string]*DiscoveredSymbol),
+	}
+}
+
+// Window returns how long a symbol is kept after it was last seen
+func (d *SymbolDiscovery) Window() time.Duration {
+	return d.window
+}
+
+// Observe records the symbol of a tick
+func (d *SymbolDiscovery) Observe(tick SharePrice) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.symbols[tick.Symbol]
+	if !ok {
+		entry = &DiscoveredSymbol{Symbol: tick.Symbol, FirstSeen: tick.Time}
+		d.symbols[tick.Symbol] = entry
+	}
+	entry.Ticks++
+	if tick.Time.After(entry.LastSeen) {
+		entry.LastSeen = tick.Time
+	}
+
+	raw := tick.RawSymbol
+	if raw == "" {
+		raw = tick.Symbol
+	}
+	for _, form := range entry.RawForms {
+		if form == raw {
+			return
+		}
+	}
+	entry.RawForms = append(entry.RawForms, raw)
+	sort.Strings(entry.RawForms)
+}
+
+// Symbols returns the symbols seen within the window before now, sorted by
+// canonical form. Symbols that fell out of the window are forgotten.
+func (d *SymbolDiscovery) Symbols(now time.Time) []DiscoveredSymbol {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	symbols := make([]DiscoveredSymbol, 0, len(d.symbols))
+	for symbol, entry := range d.symbols {
+		if d.window > 0 && now.Sub(entry.LastSeen) > d.window {
+			delete(d.symbols, symbol)
+			continue
+		}
+		copied := *entry
+		copied.RawForms = append([]string(nil), entry.RawForms...)
+		symbols = append(symbols, copied)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].Symbol < symbols[j].Symbol })
+	return symbols
+}
@@ -0,0 +1,193 @@
+package market
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SharePrice is a single price tick for a symbol
+type SharePrice struct {
+	Symbol string
+	// RawSymbol is the symbol exactly as sent by the feed, before normalization
+	RawSymbol string
+	Price     float64
+	Volume    float64
+	Time      time.Time
+	// ExchangeTime is the time the exchange stamped the price with, when the
+	// record carries one; zero otherwise
+	ExchangeTime time.Time
+	// FeedLag is how long after ExchangeTime the tick was processed, on the
+	// server's clock; zero without an ExchangeTime
+	FeedLag time.Duration
+}
+
+// SharePriceLayout gives the position of each field in a tilde-delimited record.
+// A negative index means the field is not present in the feed.
+type SharePriceLayout struct {
+	Symbol int
+	Price  int
+	Volume int
+	// Time is the exchange timestamp of the price, see ParseExchangeTime
+	Time int
+}
+
+// DefaultSharePriceLayout is the record layout of the DSE share price feed
+var DefaultSharePriceLayout = SharePriceLayout{
+	Symbol: 0,
+	Price:  1,
+	Volume: 2,
+	Time:   -1,
+}
+
+// Share price field names used in a configured layout
+const (
+	FieldSymbol = "symbol"
+	FieldPrice  = "price"
+	FieldVolume = "volume"
+	FieldTime   = "time"
+)
+
+// NewSharePriceLayout builds a layout from a mapping of field index to field
+// name, such as {0: symbol, 3: price}. Symbol and price are required; volume
+// and time are left out of the layout when not mapped.
+func NewSharePriceLayout(fields map[int]string) (SharePriceLayout, error) {
+	layout := SharePriceLayout{Symbol: -1, Price: -1, Volume: -1, Time: -1}
+	for index, name := range fields {
+		if index < 0 {
+			return SharePriceLayout{}, fmt.Errorf("field %q has negative index %d", name, index)
+		}
+		var target *int
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case FieldSymbol:
+			target = &layout.Symbol
+		case FieldPrice:
+			target = &layout.Price
+		case FieldVolume:
+			target = &layout.Volume
+		case FieldTime:
+			target = &layout.Time
+		default:
+			return SharePriceLayout{}, fmt.Errorf("unknown field %q at index %d (use symbol, price, volume or time)", name, index)
+		}
+		if *target >= 0 {
+			return SharePriceLayout{}, fmt.Errorf("field %q is mapped to both index %d and %d", name, *target, index)
+		}
+		*target = index
+	}
+	if layout.Symbol < 0 {
+		return SharePriceLayout{}, fmt.Errorf("no index is mapped to %s", FieldSymbol)
+	}
+	if layout.Price < 0 {
+		return SharePriceLayout{}, fmt.Errorf("no index is mapped to %s", FieldPrice)
+	}
+	return layout, nil
+}
+
+// String lists the mapped fields by index, e.g. "symbol=0 price=1 volume=2"
+func (l SharePriceLayout) String() string {
+	s := fmt.Sprintf("symbol=%d price=%d", l.Symbol, l.Price)
+	if l.Volume >= 0 {
+		s += fmt.Sprintf(" volume=%d", l.Volume)
+	}
+	if l.Time >= 0 {
+		s += fmt.Sprintf(" time=%d", l.Time)
+	}
+	return s
+}
+
+// ParseSharePrices parses a decompressed SharePriceUpdated payload.
+// Records are separated by newlines or '|', fields within a record by '~'.
+// Records that cannot be parsed are skipped and reported in the returned error.
+func ParseSharePrices(data string, layout SharePriceLayout, receivedAt time.Time) ([]SharePrice, error) {
+	records := strings.FieldsFunc(data, func(r rune) bool {
+		return r == '\n' || r == '|'
+	})
+
+	var prices []SharePrice
+	var failed int
+	var firstErr error
+	for _, record := range records {
+		record = strings.TrimSpace(record)
+		if record == "" {
+			continue
+		}
+		price, err := ParseSharePrice(record, layout, receivedAt)
+		if err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		prices = append(prices, price)
+	}
+
+	if failed > 0 {
+		return prices, fmt.Errorf("skipped %d unparseable records, first: %w", failed, firstErr)
+	}
+	return prices, nil
+}
+
+// ParseSharePrice parses a single tilde-delimited share price record
+func ParseSharePrice(record string, layout SharePriceLayout, receivedAt time.Time) (SharePrice, error) {
+	fields := strings.Split(record, "~")
+
+	symbol, ok := field(fields, layout.Symbol)
+	if !ok || symbol == "" {
+		return SharePrice{}, fmt.Errorf("record %q has no symbol at index %d", record, layout.Symbol)
+	}
+	rawPrice, ok := field(fields, layout.Price)
+	if !ok {
+		return SharePrice{}, fmt.Errorf("record %q has no price at index %d", record, layout.Price)
+	}
+	price, err := strconv.ParseFloat(rawPrice, 64)
+	if err != nil {
+		return SharePrice{}, fmt.Errorf("record %q has invalid price %q: %w", record, rawPrice, err)
+	}
+
+	tick := SharePrice{
+		Symbol:    NormalizeSymbol(symbol),
+		RawSymbol: symbol,
+		Price:     price,
+		Time:      receivedAt,
+	}
+	if rawVolume, ok := field(fields, layout.Volume); ok && rawVolume != "" {
+		if volume, err := strconv.ParseFloat(rawVolume, 64); err == nil {
+			tick.Volume = volume
+		}
+	}
+	// A missing or unreadable timestamp leaves the tick without an exchange
+	// time rather than dropping it
+	if rawTime, ok := field(fields, layout.Time); ok && rawTime != "" {
+		if exchangeTime, err := ParseExchangeTime(rawTime); err == nil {
+			tick.ExchangeTime = exchangeTime
+		}
+	}
+	return tick, nil
+}
+
+// ParseExchangeTime reads an exchange timestamp: RFC 3339, or Unix seconds or
+// milliseconds, told apart by magnitude
+func ParseExchangeTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return t.UTC(), nil
+	}
+	epoch, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || epoch <= 0 {
+		return time.Time{}, fmt.Errorf("invalid exchange time %q", raw)
+	}
+	// Seconds pass 1e12 in the year 33658; milliseconds did in 2001
+	if epoch >= 1e12 {
+		return time.UnixMilli(epoch).UTC(), nil
+	}
+	return time.Unix(epoch, 0).UTC(), nil
+}
+
+func field(fields []string, index int) (string, bool) {
+	if index < 0 || index >= len(fields) {
+		return "", false
+	}
+	return strings.TrimSpace(fields[index]), true
+}
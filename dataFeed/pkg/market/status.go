@@ -0,0 +1,179 @@
+// Package market provides parsed representations of the market feed payloads
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TradingState is the normalized trading state of a market or a symbol
+type TradingState string
+
+const (
+	TradingStateUnknown TradingState = "unknown"
+	TradingStateOpen    TradingState = "open"
+	TradingStateClosed  TradingState = "closed"
+	TradingStateHalted  TradingState = "halted"
+)
+
+// MarketStatus is a single market status event.
+// Symbol is empty for market-wide events.
+type MarketStatus struct {
+	Exchange string
+	Symbol   string
+	State    TradingState
+	RawState string
+}
+
+// Halted returns true if the event puts its market or symbol in a halted state
+func (s MarketStatus) Halted() bool {
+	return s.State == TradingStateHalted
+}
+
+// Key names accepted for each field, compared case-insensitively
+var (
+	symbolKeys   = []string{"symbol", "code", "tradingcode", "scrip", "instrument"}
+	stateKeys    = []string{"status", "state", "tradingstatus", "marketstatus"}
+	exchangeKeys = []string{"exchange", "market"}
+)
+
+// ParseMarketStatus parses a MarketStatusUpdated payload.
+// The payload may be a JSON object, a JSON array of per-symbol objects,
+// or a bare state string (e.g. "Open", "Halted") applying to the whole market.
+func ParseMarketStatus(data string) ([]MarketStatus, error) {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return nil, fmt.Errorf("empty market status payload")
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+		// Not JSON: treat the payload as a market-wide state string
+		return []MarketStatus{{State: NormalizeTradingState(data), RawState: data}}, nil
+	}
+
+	switch v := decoded.(type) {
+	case string:
+		return []MarketStatus{{State: NormalizeTradingState(v), RawState: v}}, nil
+	case map[string]interface{}:
+		// An object may carry a list of per-symbol entries next to the market-wide state
+		if entries, ok := lookup(v, "symbols", "instruments", "data").([]interface{}); ok {
+			statuses := parseEntries(entries)
+			if state, ok := lookup(v, stateKeys...).(string); ok {
+				statuses = append([]MarketStatus{{
+					Exchange: stringField(v, exchangeKeys...),
+					State:    NormalizeTradingState(state),
+					RawState: state,
+				}}, statuses...)
+			}
+			return statuses, nil
+		}
+		status, ok := parseEntry(v)
+		if !ok {
+			return nil, fmt.Errorf("market status object has no state field")
+		}
+		return []MarketStatus{status}, nil
+	case []interface{}:
+		return parseEntries(v), nil
+	default:
+		return nil, fmt.Errorf("unsupported market status payload type %T", decoded)
+	}
+}
+
+// haltStates are the feed states that halt trading, matched exactly so that a
+// state such as "unhalted" is not taken for a halt
+var haltStates = map[string]bool{
+	"halt":              true,
+	"halted":            true,
+	"trading halt":      true,
+	"trading halted":    true,
+	"trading_halted":    true,
+	"suspend":           true,
+	"suspended":         true,
+	"trading suspended": true,
+	"trading_suspended": true,
+}
+
+// resumeStates are the feed states that lift a halt
+var resumeStates = map[string]bool{
+	"unhalted":    true,
+	"resumed":     true,
+	"unsuspended": true,
+}
+
+// NormalizeTradingState maps the feed's free-text states onto TradingState
+func NormalizeTradingState(raw string) TradingState {
+	state := strings.ToLower(strings.TrimSpace(raw))
+	switch {
+	case haltStates[state]:
+		return TradingStateHalted
+	case resumeStates[state]:
+		return TradingStateOpen
+	case strings.Contains(state, "close"):
+		return TradingStateClosed
+	case strings.Contains(state, "open"), state == "trading", state == "active":
+		return TradingStateOpen
+	default:
+		return TradingStateUnknown
+	}
+}
+
+// NormalizeSymbol returns the canonical form of a symbol used for matching
+func NormalizeSymbol(symbol string) string {
+	return strings.ToUpper(strings.TrimSpace(symbol))
+}
+
+func parseEntries(entries []interface{}) []MarketStatus {
+	statuses := make([]MarketStatus, 0, len(entries))
+	for _, entry := range entries {
+		obj, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if status, ok := parseEntry(obj); ok {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+func parseEntry(obj map[string]interface{}) (MarketStatus, bool) {
+	state, ok := lookup(obj, stateKeys...).(string)
+	if !ok {
+		// Some feeds only send a boolean halt flag per symbol
+		halted, isBool := lookup(obj, "halted", "ishalted").(bool)
+		if !isBool {
+			return MarketStatus{}, false
+		}
+		state = "open"
+		if halted {
+			state = "halted"
+		}
+	}
+	return MarketStatus{
+		Exchange: stringField(obj, exchangeKeys...),
+		Symbol:   NormalizeSymbol(stringField(obj, symbolKeys...)),
+		State:    NormalizeTradingState(state),
+		RawState: state,
+	}, true
+}
+
+// lookup returns the value of the first key present in obj, ignoring key case
+func lookup(obj map[string]interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		for k, v := range obj {
+			if strings.EqualFold(k, key) {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+func stringField(obj map[string]interface{}, keys ...string) string {
+	if s, ok := lookup(obj, keys...).(string); ok {
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package market
+
+import "testing"
+
+func TestNormalizeTradingState(t *testing.T) {
+	for raw, want := range map[string]TradingState{
+		"Halted":            TradingStateHalted,
+		" HALT ":            TradingStateHalted,
+		"Trading Suspended": TradingStateHalted,
+		"suspended":         TradingStateHalted,
+		"Unhalted":          TradingStateOpen,
+		"Resumed":           TradingStateOpen,
+		"Open":              TradingStateOpen,
+		"trading":           TradingStateOpen,
+		"Closed":            TradingStateClosed,
+		"halt pending":      TradingStateUnknown,
+		"":                  TradingStateUnknown,
+	} {
+		if got := NormalizeTradingState(raw); got != want {
+			t.Errorf("NormalizeTradingState(%q) = %s, want %s", raw, got, want)
+		}
+	}
+}
+
+func TestParseMarketStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		payload string
+		want    []MarketStatus
+	}{
+		{
+			name:    "bare state",
+			payload: "Halted",
+			want:    []MarketStatus{{State: TradingStateHalted, RawState: "Halted"}},
+		},
+		{
+			name:    "symbol object",
+			payload: `{"Code":" gp ","Status":"Unhalted","Exchange":"DSE"}`,
+			want:    []MarketStatus{{Exchange: "DSE", Symbol: "GP", State: TradingStateOpen, RawState: "Unhalted"}},
+		},
+		{
+			name:    "halt flags",
+			payload: `[{"symbol":"GP","halted":true},{"symbol":"BATBC","isHalted":false},"ignored"]`,
+			want: []MarketStatus{
+				{Symbol: "GP", State: TradingStateHalted, RawState: "halted"},
+				{Symbol: "BATBC", State: TradingStateOpen, RawState: "open"},
+			},
+		},
+		{
+			name:    "market state with symbols",
+			payload: `{"market":"DSE","state":"Open","symbols":[{"scrip":"ACI","status":"Suspended"}]}`,
+			want: []MarketStatus{
+				{Exchange: "DSE", State: TradingStateOpen, RawState: "Open"},
+				{Symbol: "ACI", State: TradingStateHalted, RawState: "Suspended"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseMarketStatus(tc.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("status %d is %+v, want %+v", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+
+	for _, payload := range []string{"", " ", `{"symbol":"GP"}`, "42"} {
+		if _, err := ParseMarketStatus(payload); err == nil {
+			t.Errorf("payload %q was accepted", payload)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Package metrics keeps named gauges of the datafeed's internal state, such as
+// queue depths, for the periodic status log
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Gauge is a value that goes up and down
+type Gauge struct {
+	value atomic.Int64
+}
+
+// Set replaces the gauge's value
+func (g *Gauge) Set(v int64) { g.value.Store(v) }
+
+// Add changes the gauge's value by delta
+func (g *Gauge) Add(delta int64) { g.value.Add(delta) }
+
+// Value returns the gauge's current value
+func (g *Gauge) Value() int64 { return g.value.Load() }
+
+// Sample is a gauge's value at snapshot time
+type Sample struct {
+	Name  string
+	Value int64
+}
+
+// Registry holds named gauges. Names may carry Prometheus-style labels, e.g.
+// pipeline_depth{stage="signalr"}.
+type Registry struct {
+	mu     sync.Mutex
+	gauges map[string]*Gauge
+	funcs  map[string]func() int64
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{gauges: make(map[string]*Gauge), funcs: make(map[string]func() int64)}
+}
+
+// Default is the registry the datafeed's components register with
+var Default = NewRegistry()
+
+// Gauge returns the gauge with the name, creating it on first use
+func (r *Registry) Gauge(name string) *Gauge {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.gauges[name]
+	if !ok {
+		g = &Gauge{}
+		r.gauges[name] = g
+	}
+	return g
+}
+
+// GaugeFunc registers a gauge whose value is read from fn at snapshot time,
+// replacing any earlier one with the name. fn must be safe to call concurrently.
+func (r *Registry) GaugeFunc(name string, fn func() int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.funcs[name] = fn
+}
+
+// Snapshot returns every gauge's current value, sorted by name
+func (r *Registry) Snapshot() []Sample {
+	r.mu.Lock()
+	samples := make([]Sample, 0, len(r.gauges)+len(r.funcs))
+	for name, g := range r.gauges {
+		samples = append(samples, Sample{Name: name, Value: g.Value()})
+	}
+	funcs := make(map[string]func() int64, len(r.funcs))
+	for name, fn := range r.funcs {
+		funcs[name] = fn
+	}
+	r.mu.Unlock()
+
+	// Read outside the lock, so a gauge func may take locks of its own
+	for name, fn := range funcs {
+		samples = append(samples, Sample{Name: name, Value: fn()})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Name < samples[j].Name })
+	return samples
+}
+
+// Value returns the current value of the named gauge and whether it exists
+func (r *Registry) Value(name string) (int64, bool) {
+	r.mu.Lock()
+	g, ok := r.gauges[name]
+	fn, isFunc := r.funcs[name]
+	r.mu.Unlock()
+	switch {
+	case ok:
+		return g.Value(), true
+	case isFunc:
+		return fn(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,331 @@
+// Package orchestrator wires a market feed through message parsing and alert
+// evaluation to notification, and stops the stages in flow order so what is
+// buffered is still evaluated and notified
+package orchestrator
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"datafeed/pkg/alert"
+	"datafeed/pkg/logging"
+	"datafeed/pkg/market"
+	"datafeed/pkg/metrics"
+	"datafeed/pkg/pipeline"
+	"datafeed/pkg/shutdown"
+	"datafeed/pkg/signalr"
+)
+
+// Feed delivers hub messages, such as *signalr.Client
+type Feed interface {
+	Messages() <-chan signalr.Message
+	// StopReceiving refuses further messages, leaving those already buffered
+	// on Messages to be drained
+	StopReceiving()
+}
+
+// MessageProcessor parses hub messages into market events, such as
+// *signalr.MessageProcessor
+type MessageProcessor interface {
+	Process(msg signalr.Message)
+	OnSharePrice(handler signalr.SharePriceHandler)
+	OnMarketStatus(handler signalr.MarketStatusHandler)
+}
+
+// Evaluator turns market events into alert triggers, such as *alert.Evaluator.
+// The orchestrator delivers the triggers it returns, so an *alert.Evaluator
+// should be created without a notifier of its own.
+type Evaluator interface {
+	EvaluatePrice(tick market.SharePrice) []alert.Trigger
+	EvaluateBar(bar market.Bar) []alert.Trigger
+	EvaluateMarketStatus(status market.MarketStatus) []alert.Trigger
+	CheckSilence() []alert.Trigger
+}
+
+// Forwarder receives every parsed share price besides the evaluator, such as
+// *forwarder.Stream sending them on to the API
+type Forwarder interface {
+	Push(tick market.SharePrice)
+	Run()
+	// Stop refuses further prices and waits until those pushed are sent
+	Stop(ctx context.Context) error
+}
+
+// Config sizes the queues between the stages
+type Config struct {
+	// Messages configures the queue and workers between the feed and the processor
+	Messages pipeline.Config
+	// TickQueueSize and TickOverflowPolicy configure the queue in front of the
+	// evaluator; 0 and "" mean alert.DefaultQueueSize and drop_oldest
+	TickQueueSize      int
+	TickOverflowPolicy alert.OverflowPolicy
+	// BarIntervals are the intervals ticks are rolled into bars for, see alert.BarIntervals
+	BarIntervals []time.Duration
+	// SilenceCheckInterval is how often no_update alerts are checked;
+	// 0 means alert.DefaultSilenceCheckInterval
+	SilenceCheckInterval time.Duration
+	// BarFlushInterval is how often bars whose interval has ended are closed
+	// when no later tick closes them; 0 means DefaultBarFlushInterval
+	BarFlushInterval time.Duration
+	// Forwarder, when set, gets every parsed share price as the evaluator does
+	Forwarder Forwarder
+}
+
+// DefaultBarFlushInterval is how often ended bars are flushed when none is configured
+const DefaultBarFlushInterval = time.Second
+
+// Stats is a snapshot of every stage's backlog and the notifications sent
+type Stats struct {
+	Messages     pipeline.Stats
+	Ticks        alert.QueueStats
+	Notified     uint64
+	NotifyFailed uint64
+}
+
+// Orchestrator runs feed messages through the processor, parsed ticks through
+// a bounded queue into the evaluator, and triggers into the notifier:
+//
+//	feed → message queue → processor → tick queue → bars → evaluator → notifier
+//	                                 ↘ forwarder (when configured)
+//
+// Market status events go from the processor straight to the evaluator, and
+// no_update alerts are checked on a timer. Bars are closed by the next tick of
+// their symbol or, for a symbol that stops trading, on a timer.
+type Orchestrator struct {
+	feed      Feed
+	processor MessageProcessor
+	evaluator Evaluator
+	notifier  alert.Notifier
+	cfg       Config
+	logger    *log.Logger
+
+	messages    *pipeline.Stage[signalr.Message]
+	ticks       *alert.TickQueue
+	aggregators []*market.BarAggregator
+
+	silenceOnce sync.Once
+	stopSilence context.CancelFunc
+	silenceCtx  context.Context
+	silenceDone chan struct{}
+
+	flushOnce sync.Once
+	stopFlush context.CancelFunc
+	flushCtx  context.Context
+	flushDone chan struct{}
+
+	notified     atomic.Uint64
+	notifyFailed atomic.Uint64
+}
+
+// New wires the stages; nothing runs until Start
+func New(feed Feed, processor MessageProcessor, evaluator Evaluator, notifier alert.Notifier, cfg Config) *Orchestrator {
+	if cfg.TickQueueSize <= 0 {
+		cfg.TickQueueSize = alert.DefaultQueueSize
+	}
+	if cfg.TickOverflowPolicy == "" {
+		cfg.TickOverflowPolicy = alert.OverflowDropOldest
+	}
+	if cfg.SilenceCheckInterval <= 0 {
+		cfg.SilenceCheckInterval = alert.DefaultSilenceCheckInterval
+	}
+	if cfg.BarFlushInterval <= 0 {
+		cfg.BarFlushInterval = DefaultBarFlushInterval
+	}
+	silenceCtx, stopSilence := context.WithCancel(context.Background())
+	flushCtx, stopFlush := context.WithCancel(context.Background())
+	o := &Orchestrator{
+		feed:        feed,
+		processor:   processor,
+		evaluator:   evaluator,
+		notifier:    notifier,
+		cfg:         cfg,
+		logger:      logging.New("[Orchestrator] "),
+		silenceCtx:  silenceCtx,
+		stopSilence: stopSilence,
+		silenceDone: make(chan struct{}),
+		flushCtx:    flushCtx,
+		stopFlush:   stopFlush,
+		flushDone:   make(chan struct{}),
+	}
+
+	// Roll ticks into OHLC bars for every interval targeted by bar alerts
+	for _, interval := range cfg.BarIntervals {
+		o.aggregators = append(o.aggregators, market.NewBarAggregator(interval, func(bar market.Bar) {
+			o.notify(o.evaluator.EvaluateBar(bar))
+		}))
+	}
+	o.ticks = alert.NewTickQueue(cfg.TickQueueSize, cfg.TickOverflowPolicy, func(tick market.SharePrice) {
+		for _, aggregator := range o.aggregators {
+			aggregator.Add(tick)
+		}
+		o.notify(o.evaluator.EvaluatePrice(tick))
+	})
+	processor.OnSharePrice(func(tick market.SharePrice) {
+		o.ticks.Push(tick)
+		if cfg.Forwarder != nil {
+			cfg.Forwarder.Push(tick)
+		}
+	})
+	processor.OnMarketStatus(func(status market.MarketStatus) {
+		o.notify(o.evaluator.EvaluateMarketStatus(status))
+	})
+	o.messages = pipeline.NewStage(cfg.Messages, feed.Messages(), func(msg signalr.Message) {
+		o.logger.Printf("📨 Received message: Method=%s", msg.Method)
+		o.processor.Process(msg)
+	})
+	return o
+}
+
+// Start runs the stages and the no_update checks in the background; call it once
+func (o *Orchestrator) Start() {
+	go o.ticks.Run()
+	go func() {
+		stats := o.messages.Stats()
+		o.logger.Printf("Starting message processor (%d workers, queue %d, %s)...", stats.Workers, stats.Capacity, stats.Policy)
+		o.messages.Run()
+		o.logger.Printf("Message processor stopped after %d messages", o.messages.Stats().Processed)
+	}()
+	go o.runSilenceChecks()
+	go o.runBarFlushes()
+	if o.cfg.Forwarder != nil {
+		go o.cfg.Forwarder.Run()
+	}
+}
+
+// runSilenceChecks notifies no_update triggers every SilenceCheckInterval
+// until StopSilenceChecks
+func (o *Orchestrator) runSilenceChecks() {
+	defer close(o.silenceDone)
+	ticker := time.NewTicker(o.cfg.SilenceCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.silenceCtx.Done():
+			return
+		case <-ticker.C:
+			o.notify(o.evaluator.CheckSilence())
+		}
+	}
+}
+
+// runBarFlushes closes the bars whose interval has ended every
+// BarFlushInterval until StopBarFlushes
+func (o *Orchestrator) runBarFlushes() {
+	defer close(o.flushDone)
+	ticker := time.NewTicker(o.cfg.BarFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.flushCtx.Done():
+			return
+		case now := <-ticker.C:
+			o.flushBars(now)
+		}
+	}
+}
+
+// flushBars emits, and so evaluates, every bar whose interval ended by now
+func (o *Orchestrator) flushBars(now time.Time) {
+	for _, aggregator := range o.aggregators {
+		aggregator.Flush(now)
+	}
+}
+
+// notify hands triggers to the notifier; a failed notification is logged and
+// the next one still sent
+func (o *Orchestrator) notify(triggers []alert.Trigger) {
+	for _, trigger := range triggers {
+		if err := o.notifier.Notify(trigger); err != nil {
+			o.notifyFailed.Add(1)
+			o.logger.Printf("Failed to notify alert %s: %v", trigger.Alert.ID, err)
+			continue
+		}
+		o.notified.Add(1)
+	}
+}
+
+// StopSilenceChecks stops the no_update checks and waits for one in progress
+func (o *Orchestrator) StopSilenceChecks(ctx context.Context) error {
+	o.silenceOnce.Do(o.stopSilence)
+	select {
+	case <-o.silenceDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StopIntake stops the feed, leaving its buffered messages to the processor
+func (o *Orchestrator) StopIntake(ctx context.Context) error {
+	o.feed.StopReceiving()
+	return nil
+}
+
+// StopBarFlushes stops the bar flush timer, waiting for a flush in progress,
+// then flushes the bars that have ended since. Bars still open are dropped
+// rather than evaluated half complete.
+func (o *Orchestrator) StopBarFlushes(ctx context.Context) error {
+	o.flushOnce.Do(o.stopFlush)
+	select {
+	case <-o.flushDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	o.flushBars(time.Now())
+	return nil
+}
+
+// StopForwarding waits until the forwarder has sent the prices pushed to it
+func (o *Orchestrator) StopForwarding(ctx context.Context) error {
+	if o.cfg.Forwarder == nil {
+		return nil
+	}
+	return o.cfg.Forwarder.Stop(ctx)
+}
+
+// Stop shuts the stages down in flow order within ctx: the no_update checks,
+// so stopping cannot fire no_update alerts, then the feed, then the message
+// queue and the tick queue once each has drained into the next, the
+// forwarder once the message queue has, and last the bars the drained ticks
+// ended
+func (o *Orchestrator) Stop(ctx context.Context) error {
+	for _, stop := range []shutdown.StopFunc{o.StopSilenceChecks, o.StopIntake, o.messages.Stop, o.ticks.Stop, o.StopForwarding, o.StopBarFlushes} {
+		if err := stop(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterShutdown registers the steps of Stop with the coordinator, so its
+// report names the stage that did not drain
+func (o *Orchestrator) RegisterShutdown(coordinator *shutdown.Coordinator) {
+	coordinator.Register("no_update checks", o.StopSilenceChecks)
+	coordinator.Register("feed intake", o.StopIntake)
+	coordinator.Register("message processor", o.messages.Stop)
+	coordinator.Register("alert evaluation queue", o.ticks.Stop)
+	if o.cfg.Forwarder != nil {
+		coordinator.Register("api forwarder", o.StopForwarding)
+	}
+	coordinator.Register("bar flush", o.StopBarFlushes)
+}
+
+// RegisterGauges exposes the depth of the message and tick queues
+func (o *Orchestrator) RegisterGauges(registry *metrics.Registry) {
+	o.messages.RegisterGauges(registry)
+	registry.GaugeFunc(pipeline.DepthGauge("evaluation"), func() int64 { return int64(o.ticks.Stats().Depth) })
+}
+
+// Stats returns the current backlog of each stage and the notification counters
+func (o *Orchestrator) Stats() Stats {
+	return Stats{
+		Messages:     o.messages.Stats(),
+		Ticks:        o.ticks.Stats(),
+		Notified:     o.notified.Load(),
+		NotifyFailed: o.notifyFailed.Load(),
+	}
+}
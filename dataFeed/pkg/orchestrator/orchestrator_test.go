@@ -0,0 +1,215 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"datafeed/pkg/alert"
+	"datafeed/pkg/market"
+	"datafeed/pkg/pipeline"
+	"datafeed/pkg/signalr"
+)
+
+// testFeed is a Feed whose messages are sent by the test
+type testFeed struct {
+	messages chan signalr.Message
+	stopOnce sync.Once
+}
+
+func (f *testFeed) Messages() <-chan signalr.Message { return f.messages }
+
+func (f *testFeed) StopReceiving() {
+	f.stopOnce.Do(func() { close(f.messages) })
+}
+
+// testNotifier records the alerts notified and fails those whose id starts
+// with "broken"
+type testNotifier struct {
+	mu       sync.Mutex
+	notified map[string]int
+}
+
+func (n *testNotifier) Notify(trigger alert.Trigger) error {
+	if strings.HasPrefix(trigger.Alert.ID, "broken") {
+		return fmt.Errorf("notifier is down for %s", trigger.Alert.ID)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notified[trigger.Alert.ID]++
+	return nil
+}
+
+// Share prices and a market status go from the feed through the processor
+// and evaluator to the notifier, and stopping with messages still queued
+// evaluates and notifies every one of them
+func TestOrchestratorDrainsOnStop(t *testing.T) {
+	evaluator := alert.NewEvaluator(nil)
+	evaluator.SetAlerts([]alert.Alert{
+		{ID: "gp-350", Symbol: "GP", Rule: alert.RuleAbove, Price: 350},
+		{ID: "broken-gp-300", Symbol: "GP", Rule: alert.RuleAbove, Price: 300},
+		{ID: "sq-10", Symbol: "SQ", Rule: alert.RuleAbove, Price: 10},
+		{ID: "batbc-halt", Symbol: "BATBC", Rule: alert.RuleHalt},
+		{ID: "aci-silent", Symbol: "ACI", Rule: alert.RuleNoUpdate, Silence: 30 * time.Millisecond},
+	})
+	feed := &testFeed{messages: make(chan signalr.Message, 100)}
+	notifier := &testNotifier{notified: make(map[string]int)}
+	flow := New(feed, signalr.NewMessageProcessor(), evaluator, notifier, Config{
+		Messages:             pipeline.Config{Name: "test"},
+		SilenceCheckInterval: 10 * time.Millisecond,
+	})
+	flow.Start()
+
+	sent := 0
+	send := func(method, data string) {
+		feed.messages <- signalr.Message{Method: method, Data: data}
+		sent++
+	}
+	send("SharePriceUpdated", "GP~349~100")
+	send("SharePriceUpdated", "GP~351~100")
+	send("SharePriceUpdated", "GP~352~100")
+	send("MarketStatusUpdated^^DSE~", `{"symbol":"BATBC","status":"Halted"}`)
+	// ACI never updates, so its no_update alert fires once
+	time.Sleep(100 * time.Millisecond)
+	// SQ crosses 10 on every other tick; stopping right away leaves most of
+	// them queued for the drain
+	for i := 0; i < 50; i++ {
+		price := 9
+		if i%2 == 0 {
+			price = 11
+		}
+		send("SharePriceUpdated", fmt.Sprintf("SQ~%d~100", price))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := flow.Stop(ctx); err != nil {
+		t.Fatalf("orchestrator did not drain: %v", err)
+	}
+
+	stats := flow.Stats()
+	if stats.Messages.Processed != uint64(sent) || stats.Ticks.Processed != uint64(sent-1) {
+		t.Errorf("%d messages sent, %d processed and %d ticks evaluated", sent, stats.Messages.Processed, stats.Ticks.Processed)
+	}
+	want := map[string]int{"gp-350": 1, "sq-10": 25, "batbc-halt": 1, "aci-silent": 1}
+	notifier.mu.Lock()
+	if fmt.Sprint(notifier.notified) != fmt.Sprint(want) {
+		t.Errorf("notified %v, want %v", notifier.notified, want)
+	}
+	notifier.mu.Unlock()
+	if stats.Notified != 28 || stats.NotifyFailed != 1 {
+		t.Errorf("%d notified and %d failed, want 28 and 1", stats.Notified, stats.NotifyFailed)
+	}
+}
+
+// Bars of a symbol that stops trading are closed by the flush timer while
+// running, and on Stop once their interval has ended
+func TestOrchestratorFlushesBars(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	for _, tc := range []struct {
+		name  string
+		flush time.Duration
+		// stopAfter is how long after the ticks the orchestrator is stopped
+		stopAfter time.Duration
+	}{
+		{name: "flush timer", flush: 10 * time.Millisecond},
+		{name: "stop", flush: time.Hour, stopAfter: 2 * interval},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			evaluator := alert.NewEvaluator(nil)
+			evaluator.SetAlerts([]alert.Alert{{ID: "gp-close-above", Symbol: "GP", Rule: alert.RuleBarCloseAbove, Price: 350, Interval: interval}})
+			feed := &testFeed{messages: make(chan signalr.Message, 10)}
+			notifier := &testNotifier{notified: make(map[string]int)}
+			flow := New(feed, signalr.NewMessageProcessor(), evaluator, notifier, Config{
+				Messages:         pipeline.Config{Name: "test"},
+				BarIntervals:     []time.Duration{interval},
+				BarFlushInterval: tc.flush,
+			})
+			flow.Start()
+			feed.messages <- signalr.Message{Method: "SharePriceUpdated", Data: "GP~351~100"}
+
+			notified := func() int {
+				notifier.mu.Lock()
+				defer notifier.mu.Unlock()
+				return notifier.notified["gp-close-above"]
+			}
+			if tc.stopAfter > 0 {
+				time.Sleep(tc.stopAfter)
+				if n := notified(); n != 0 {
+					t.Fatalf("bar notified %d times before Stop with the timer idle", n)
+				}
+			} else {
+				deadline := time.Now().Add(time.Second)
+				for notified() == 0 && time.Now().Before(deadline) {
+					time.Sleep(5 * time.Millisecond)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := flow.Stop(ctx); err != nil {
+				t.Fatalf("orchestrator did not stop: %v", err)
+			}
+			if n := notified(); n != 1 {
+				t.Errorf("bar-close alert notified %d times, want once", n)
+			}
+		})
+	}
+}
+
+// testForwarder records the prices pushed to it and whether it ran and stopped
+type testForwarder struct {
+	mu      sync.Mutex
+	symbols []string
+	ran     chan struct{}
+	stopped bool
+}
+
+func (f *testForwarder) Push(tick market.SharePrice) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.symbols = append(f.symbols, tick.Symbol)
+}
+
+func (f *testForwarder) Run() { close(f.ran) }
+
+func (f *testForwarder) Stop(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+	return nil
+}
+
+// A configured forwarder runs with the flow, gets every parsed share price
+// but no market status, and is stopped once the messages have drained
+func TestOrchestratorForwardsPrices(t *testing.T) {
+	feed := &testFeed{messages: make(chan signalr.Message, 10)}
+	forwarder := &testForwarder{ran: make(chan struct{})}
+	flow := New(feed, signalr.NewMessageProcessor(), alert.NewEvaluator(nil), &testNotifier{notified: make(map[string]int)}, Config{
+		Messages:  pipeline.Config{Name: "test"},
+		Forwarder: forwarder,
+	})
+	flow.Start()
+	feed.messages <- signalr.Message{Method: "SharePriceUpdated", Data: "GP~351~100"}
+	feed.messages <- signalr.Message{Method: "MarketStatusUpdated^^DSE~", Data: `{"symbol":"BATBC","status":"Halted"}`}
+	feed.messages <- signalr.Message{Method: "SharePriceUpdated", Data: "SQ~11~100"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := flow.Stop(ctx); err != nil {
+		t.Fatalf("orchestrator did not stop: %v", err)
+	}
+	select {
+	case <-forwarder.ran:
+	case <-time.After(time.Second):
+		t.Error("the forwarder was never run")
+	}
+	forwarder.mu.Lock()
+	defer forwarder.mu.Unlock()
+	if !forwarder.stopped || fmt.Sprint(forwarder.symbols) != "[GP SQ]" {
+		t.Errorf("got stopped %t with %v forwarded, want stopped with [GP SQ]", forwarder.stopped, forwarder.symbols)
+	}
+}
@@ -0,0 +1,280 @@
+// Package pipeline decouples a producer channel from slow processing with a
+// bounded queue and a pool of workers, so a slow consumer shows up as queue
+// depth and drops instead of stalling the producer
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"datafeed/pkg/metrics"
+)
+
+// Policy decides what happens to an item arriving at a full queue
+type Policy string
+
+const (
+	// DropOldest discards the oldest queued item to make room; the default
+	DropOldest Policy = "drop_oldest"
+	// DropNewest discards the arriving item
+	DropNewest Policy = "drop_newest"
+	// Block stops reading the source until there is room, pushing back on the producer
+	Block Policy = "block"
+)
+
+// DefaultQueueSize is the queue capacity used when none is configured
+const DefaultQueueSize = 1000
+
+// ParsePolicy validates a configured policy; empty means DropOldest
+func ParsePolicy(raw string) (Policy, error) {
+	switch policy := Policy(raw); policy {
+	case "":
+		return DropOldest, nil
+	case DropOldest, DropNewest, Block:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown overflow policy %q (use drop_oldest, drop_newest or block)", raw)
+}
+
+// Config describes a stage
+type Config struct {
+	// Name labels the stage's gauges
+	Name string
+	// QueueSize bounds the items waiting for a worker (default DefaultQueueSize)
+	QueueSize int
+	Policy    Policy
+	// MaxAge drops items that waited longer than this before a worker got to
+	// them; zero keeps every item
+	MaxAge time.Duration
+	// Workers process items concurrently (default 1). With more than one,
+	// items may finish out of order.
+	Workers int
+}
+
+// Stats is a snapshot of a stage's backlog and counters
+type Stats struct {
+	Depth     int
+	Capacity  int
+	HighWater int // deepest backlog seen
+	Enqueued  uint64
+	Processed uint64
+	// Dropped counts items refused or discarded by the overflow policy, or
+	// arriving after Stop
+	Dropped uint64
+	// Expired counts items discarded for waiting longer than MaxAge
+	Expired uint64
+	Workers int
+	Policy  Policy
+}
+
+type queued[T any] struct {
+	item T
+	at   time.Time
+}
+
+// Stage reads a source channel into a bounded queue served by workers
+type Stage[T any] struct {
+	cfg     Config
+	source  <-chan T
+	process func(T)
+	now     func() time.Time
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	items    []queued[T]
+	closed   bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	workers  sync.WaitGroup
+	done     chan struct{}
+
+	highWater int
+	enqueued  uint64
+	processed uint64
+	dropped   uint64
+	expired   uint64
+}
+
+// NewStage creates a stage calling process for every item read from source
+func NewStage[T any](cfg Config, source <-chan T, process func(T)) *Stage[T] {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.Policy == "" {
+		cfg.Policy = DropOldest
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	s := &Stage[T]{
+		cfg:     cfg,
+		source:  source,
+		process: process,
+		now:     time.Now,
+		items:   make([]queued[T], 0, cfg.QueueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	s.notEmpty = sync.NewCond(&s.mu)
+	s.notFull = sync.NewCond(&s.mu)
+	return s
+}
+
+// Run reads the source and processes the queue until the source is closed or
+// Stop is called, then drains what is buffered and returns
+func (s *Stage[T]) Run() {
+	defer close(s.done)
+	s.workers.Add(s.cfg.Workers)
+	for i := 0; i < s.cfg.Workers; i++ {
+		go s.work()
+	}
+	s.read()
+
+	s.mu.Lock()
+	s.closed = true
+	s.notEmpty.Broadcast()
+	s.notFull.Broadcast()
+	s.mu.Unlock()
+	s.workers.Wait()
+}
+
+// read moves items from the source into the queue. Once stopped it takes
+// what the source still buffers without waiting for more.
+func (s *Stage[T]) read() {
+	for {
+		select {
+		case item, ok := <-s.source:
+			if !ok {
+				return
+			}
+			s.push(item)
+		case <-s.stop:
+			for {
+				select {
+				case item, ok := <-s.source:
+					if !ok {
+						return
+					}
+					s.push(item)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// push queues an item, applying the overflow policy when the queue is full
+func (s *Stage[T]) push(item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for !s.closed && len(s.items) >= s.cfg.QueueSize {
+		switch s.cfg.Policy {
+		case DropNewest:
+			s.dropped++
+			return
+		case DropOldest:
+			s.items = s.items[1:]
+			s.dropped++
+		default:
+			s.notFull.Wait()
+		}
+	}
+	if s.closed {
+		s.dropped++
+		return
+	}
+
+	s.items = append(s.items, queued[T]{item: item, at: s.now()})
+	s.enqueued++
+	if len(s.items) > s.highWater {
+		s.highWater = len(s.items)
+	}
+	s.notEmpty.Signal()
+}
+
+// work processes queued items until the queue is closed and empty
+func (s *Stage[T]) work() {
+	defer s.workers.Done()
+	for {
+		s.mu.Lock()
+		for len(s.items) == 0 && !s.closed {
+			s.notEmpty.Wait()
+		}
+		if len(s.items) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		next := s.items[0]
+		s.items = s.items[1:]
+		s.notFull.Signal()
+		if s.cfg.MaxAge > 0 && s.now().Sub(next.at) > s.cfg.MaxAge {
+			s.expired++
+			s.mu.Unlock()
+			continue
+		}
+		s.mu.Unlock()
+
+		s.process(next.item)
+
+		s.mu.Lock()
+		s.processed++
+		s.mu.Unlock()
+	}
+}
+
+// Stop stops reading once the source's buffered items are queued, and waits
+// until the workers have drained the queue or ctx is done. Stop the producer
+// first so nothing arrives after the drain.
+func (s *Stage[T]) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the current backlog and counters
+func (s *Stage[T]) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{
+		Depth:     len(s.items),
+		Capacity:  s.cfg.QueueSize,
+		HighWater: s.highWater,
+		Enqueued:  s.enqueued,
+		Processed: s.processed,
+		Dropped:   s.dropped,
+		Expired:   s.expired,
+		Workers:   s.cfg.Workers,
+		Policy:    s.cfg.Policy,
+	}
+}
+
+// RegisterGauges publishes the stage's depth and drop counts to registry as
+// pipeline_depth, pipeline_dropped and pipeline_expired labelled with the
+// stage name
+func (s *Stage[T]) RegisterGauges(registry *metrics.Registry) {
+	label := fmt.Sprintf("{stage=%q}", s.cfg.Name)
+	registry.GaugeFunc(DepthGauge(s.cfg.Name), func() int64 { return int64(s.Stats().Depth) })
+	registry.GaugeFunc("pipeline_dropped"+label, func() int64 { return int64(s.Stats().Dropped) })
+	registry.GaugeFunc("pipeline_expired"+label, func() int64 { return int64(s.Stats().Expired) })
+}
+
+// RegisterChannelDepth publishes the backlog of a channel feeding a stage, such
+// as the client's message buffer, as pipeline_depth labelled with name
+func RegisterChannelDepth[T any](registry *metrics.Registry, name string, ch <-chan T) {
+	registry.GaugeFunc(DepthGauge(name), func() int64 { return int64(len(ch)) })
+}
+
+// DepthGauge names the depth gauge of the named stage
+func DepthGauge(stage string) string {
+	return fmt.Sprintf("pipeline_depth{stage=%q}", stage)
+}
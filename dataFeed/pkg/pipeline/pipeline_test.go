@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"datafeed/pkg/metrics"
+)
+
+// A held-up processor leaves the stage's queue to its overflow policy, and
+// the counters and gauges agree
+func TestStageOverflow(t *testing.T) {
+	const capacity, burst = 4, 10
+	for _, tc := range []struct {
+		policy    Policy
+		processed []int
+		dropped   uint64
+	}{
+		// The first message is already with the held-up worker, the queue
+		// keeps four of the other nine
+		{policy: DropOldest, processed: []int{1, 7, 8, 9, 10}, dropped: 5},
+		{policy: DropNewest, processed: []int{1, 2, 3, 4, 5}, dropped: 5},
+		{policy: Block, processed: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, dropped: 0},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			source := make(chan int)
+			started := make(chan struct{}, burst)
+			release := make(chan struct{})
+			var mu sync.Mutex
+			var seen []int
+			stage := NewStage(Config{Name: "test", QueueSize: capacity, Policy: tc.policy}, source, func(n int) {
+				started <- struct{}{}
+				<-release
+				mu.Lock()
+				seen = append(seen, n)
+				mu.Unlock()
+			})
+			registry := metrics.NewRegistry()
+			stage.RegisterGauges(registry)
+			go stage.Run()
+
+			source <- 1
+			<-started
+			sent := make(chan struct{})
+			go func() {
+				defer close(sent)
+				for i := 2; i <= burst; i++ {
+					source <- i
+				}
+			}()
+
+			if tc.policy == Block {
+				// The source must be held back at capacity until the worker is free
+				select {
+				case <-sent:
+					t.Fatal("source was not blocked by a full queue")
+				case <-time.After(100 * time.Millisecond):
+				}
+			} else {
+				select {
+				case <-sent:
+				case <-time.After(time.Second):
+					t.Fatal("source was blocked by a held-up processor")
+				}
+				// The last message read may still be on its way into the queue
+				deadline := time.Now().Add(time.Second)
+				for stage.Stats().Dropped < tc.dropped && time.Now().Before(deadline) {
+					time.Sleep(time.Millisecond)
+				}
+			}
+
+			depth, _ := registry.Value(DepthGauge("test"))
+			if depth != int64(capacity) || stage.Stats().HighWater != capacity {
+				t.Errorf("depth gauge %d and peak %d while held up, want %d", depth, stage.Stats().HighWater, capacity)
+			}
+			if gauge, _ := registry.Value(`pipeline_dropped{stage="test"}`); gauge != int64(tc.dropped) {
+				t.Errorf("dropped gauge %d, want %d", gauge, tc.dropped)
+			}
+
+			close(release)
+			<-sent
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := stage.Stop(ctx); err != nil {
+				t.Fatalf("stage did not drain: %v", err)
+			}
+
+			if fmt.Sprint(seen) != fmt.Sprint(tc.processed) {
+				t.Errorf("processed %v, want %v", seen, tc.processed)
+			}
+			stats := stage.Stats()
+			if stats.Dropped != tc.dropped || stats.Processed != uint64(len(tc.processed)) || stats.Depth != 0 {
+				t.Errorf("got %+v, want dropped %d, processed %d, depth 0", stats, tc.dropped, len(tc.processed))
+			}
+			if depth, _ := registry.Value(DepthGauge("test")); depth != 0 {
+				t.Errorf("depth gauge %d after the drain, want 0", depth)
+			}
+		})
+	}
+}
+
+// Messages queued behind a processor slower than max age are expired instead
+// of processed
+func TestStageMaxAge(t *testing.T) {
+	const maxAge = 20 * time.Millisecond
+	source := make(chan int, 4)
+	var seen []int
+	stage := NewStage(Config{Name: "test", QueueSize: 4, MaxAge: maxAge}, source, func(n int) {
+		seen = append(seen, n)
+		if n == 1 {
+			time.Sleep(5 * maxAge)
+		}
+	})
+	for i := 1; i <= 4; i++ {
+		source <- i
+	}
+	close(source)
+	stage.Run()
+
+	stats := stage.Stats()
+	if fmt.Sprint(seen) != "[1]" || stats.Expired != 3 || stats.Processed != 1 {
+		t.Errorf("processed %v with %+v, want only message 1 processed and 3 expired", seen, stats)
+	}
+}
+
+// Several workers process messages at the same time
+func TestStageWorkers(t *testing.T) {
+	const workers = 4
+	source := make(chan int, workers)
+	var inFlight sync.WaitGroup
+	inFlight.Add(workers)
+	allIn := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		close(allIn)
+	}()
+	stage := NewStage(Config{Name: "test", Workers: workers}, source, func(int) {
+		inFlight.Done()
+		<-allIn
+	})
+	go stage.Run()
+	for i := 1; i <= workers; i++ {
+		source <- i
+	}
+
+	select {
+	case <-allIn:
+	case <-time.After(time.Second):
+		t.Fatalf("%d messages were never processed at the same time", workers)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := stage.Stop(ctx); err != nil {
+		t.Fatalf("stage did not drain: %v", err)
+	}
+	if stats := stage.Stats(); stats.Processed != workers || stats.Workers != workers {
+		t.Errorf("got %+v, want %d messages processed by %d workers", stats, workers, workers)
+	}
+}
@@ -0,0 +1,96 @@
+// Package shutdown coordinates a bounded, graceful shutdown of datafeed components
+package shutdown
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"datafeed/pkg/logging"
+)
+
+// DefaultTimeout is the grace period used when none is configured
+const DefaultTimeout = 10 * time.Second
+
+// StopFunc stops a component. It should return once the component has drained,
+// or give up when ctx is done.
+type StopFunc func(ctx context.Context) error
+
+type component struct {
+	name string
+	stop StopFunc
+}
+
+// Report describes how each component behaved during shutdown
+type Report struct {
+	Stopped  []string         // components that finished within the grace period
+	Failed   map[string]error // components that finished with an error
+	TimedOut []string         // components still running when the grace period expired
+	Skipped  []string         // components never stopped because the grace period expired first
+}
+
+// Clean returns true if every component stopped without error in time
+func (r Report) Clean() bool {
+	return len(r.Failed) == 0 && len(r.TimedOut) == 0 && len(r.Skipped) == 0
+}
+
+// Coordinator stops registered components in order within a shared grace period
+type Coordinator struct {
+	timeout    time.Duration
+	components []component
+	logger     *log.Logger
+}
+
+// NewCoordinator creates a coordinator with the given grace period
+func NewCoordinator(timeout time.Duration) *Coordinator {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Coordinator{
+		timeout: timeout,
+		logger:  logging.New("[Shutdown] "),
+	}
+}
+
+// Register adds a component; components are stopped in registration order
+func (c *Coordinator) Register(name string, stop StopFunc) {
+	c.components = append(c.components, component{name: name, stop: stop})
+}
+
+// Shutdown stops every component, giving up on whatever has not finished when
+// the grace period expires. It never blocks longer than the grace period.
+func (c *Coordinator) Shutdown() Report {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	report := Report{Failed: make(map[string]error)}
+	c.logger.Printf("Stopping %d components (grace period %v)", len(c.components), c.timeout)
+
+	for i, comp := range c.components {
+		done := make(chan error, 1)
+		go func(comp component) {
+			done <- comp.stop(ctx)
+		}(comp)
+
+		select {
+		case err := <-done:
+			if err != nil {
+				c.logger.Printf("❌ %s stopped with error: %v", comp.name, err)
+				report.Failed[comp.name] = err
+				continue
+			}
+			c.logger.Printf("✅ %s stopped", comp.name)
+			report.Stopped = append(report.Stopped, comp.name)
+		case <-ctx.Done():
+			c.logger.Printf("⏱️ %s did not stop within the grace period", comp.name)
+			report.TimedOut = append(report.TimedOut, comp.name)
+			for _, rest := range c.components[i+1:] {
+				c.logger.Printf("⏭️ %s skipped: grace period expired", rest.name)
+				report.Skipped = append(report.Skipped, rest.name)
+			}
+			return report
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,69 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func newQuietCoordinator(timeout time.Duration) *Coordinator {
+	c := NewCoordinator(timeout)
+	c.logger = log.New(io.Discard, "", 0)
+	return c
+}
+
+// A component that hangs past the grace period does not hold up shutdown; it
+// is reported timed out and the components after it skipped
+func TestShutdownHangingComponent(t *testing.T) {
+	const grace = 100 * time.Millisecond
+	c := newQuietCoordinator(grace)
+	release := make(chan struct{})
+	defer close(release)
+
+	c.Register("feed", func(ctx context.Context) error { return nil })
+	c.Register("forwarder", func(ctx context.Context) error { return errors.New("flush failed") })
+	c.Register("evaluator", func(ctx context.Context) error {
+		// Ignores ctx, as a stuck component would
+		<-release
+		return nil
+	})
+	c.Register("metrics", func(ctx context.Context) error { return nil })
+
+	start := time.Now()
+	report := c.Shutdown()
+	if elapsed := time.Since(start); elapsed > grace+time.Second {
+		t.Errorf("shutdown took %v with a %v grace period", elapsed, grace)
+	}
+	if got := fmt.Sprint(report.Stopped, report.TimedOut, report.Skipped); got != "[feed] [evaluator] [metrics]" {
+		t.Errorf("stopped, timed out and skipped %s, want [feed] [evaluator] [metrics]", got)
+	}
+	if err := report.Failed["forwarder"]; err == nil || len(report.Failed) != 1 {
+		t.Errorf("failed components are %v, want the forwarder", report.Failed)
+	}
+	if report.Clean() {
+		t.Error("report with a hung component is clean")
+	}
+}
+
+func TestShutdownClean(t *testing.T) {
+	c := newQuietCoordinator(0)
+	if c.timeout != DefaultTimeout {
+		t.Errorf("grace period is %v, want the default %v", c.timeout, DefaultTimeout)
+	}
+	var order []string
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		c.Register(name, func(ctx context.Context) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+	report := c.Shutdown()
+	if !report.Clean() || fmt.Sprint(order) != "[a b c]" || fmt.Sprint(report.Stopped) != "[a b c]" {
+		t.Errorf("stopped %v as %+v, want a, b and c in order and a clean report", order, report)
+	}
+}
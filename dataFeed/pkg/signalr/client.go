@@ -3,17 +3,20 @@ package signalr
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/philippseith/signalr"
 
+	"datafeed/pkg/backoff"
 	"datafeed/pkg/config"
+	"datafeed/pkg/logging"
 )
 
 // Message represents a SignalR message
@@ -34,37 +37,129 @@ const (
 	ConnectionStatusConnected
 	// ConnectionStatusReconnecting indicates the client is reconnecting
 	ConnectionStatusReconnecting
+	// ConnectionStatusFailed indicates the client gave up reconnecting; only an
+	// explicit Connect leaves it
+	ConnectionStatusFailed
 )
 
+// String returns a human-readable name for the connection status
+func (s ConnectionStatus) String() string {
+	switch s {
+	case ConnectionStatusDisconnected:
+		return "disconnected"
+	case ConnectionStatusConnecting:
+		return "connecting"
+	case ConnectionStatusConnected:
+		return "connected"
+	case ConnectionStatusReconnecting:
+		return "reconnecting"
+	case ConnectionStatusFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// HubClient is the subset of signalr.Client used by Client.
+// It exists so the underlying hub connection can be replaced in tests.
+type HubClient interface {
+	Start()
+	Stop()
+	Send(method string, arguments ...interface{}) <-chan error
+	// WaitForState reports on the channel once the client reaches the state,
+	// or the error that stopped it
+	WaitForState(ctx context.Context, waitFor signalr.ClientState) <-chan error
+}
+
+// HubConnector creates the underlying hub client for a single connection
+// attempt, negotiating the given transfer format
+type HubConnector func(ctx context.Context, hubURL, token string, format TransferFormat, receiver interface{}) (HubClient, error)
+
+// ClientHooks lets the embedding application observe connection lifecycle transitions.
+// Hooks are called synchronously and must not block or call back into the Client.
+type ClientHooks struct {
+	// OnStatusChange is called whenever the connection status changes
+	OnStatusChange func(from, to ConnectionStatus)
+	// OnReconnectAttempt is called before waiting out the backoff of a reconnect attempt
+	OnReconnectAttempt func(attempt int, delay time.Duration)
+	// OnResubscribeVerified is called once resubscribe verification settles, with the
+	// number of resubscribe attempts made and whether the server acknowledged them
+	OnResubscribeVerified func(attempts int, ok bool)
+	// OnFailed is called once the client gives up reconnecting, with the attempts
+	// made and the error of the last one
+	OnFailed func(attempts int, lastErr error)
+}
+
 // ClientConfig holds configuration options for the SignalR client
 type ClientConfig struct {
-	// Connection settings
+	// Connection settings; ConnectionTimeout bounds the wait for the hub handshake
 	ConnectionTimeout    time.Duration
 	ReconnectDelay       time.Duration
 	MaxReconnectDelay    time.Duration
 	MaxReconnectAttempts int
+	// ReconnectJitter spreads each reconnect delay by up to this fraction
+	ReconnectJitter float64
 
 	// Message handling
 	MessageBufferSize int
 	EnableHeartbeat   bool
 	HeartbeatInterval time.Duration
+	// MaxMessageSize bounds the total bytes of a message's string arguments;
+	// larger messages are dropped before they are logged or queued
+	MaxMessageSize int
+
+	// Resubscribe verification after a reconnect: wait up to ResubscribeTimeout for a
+	// subscription acknowledgement or first data message, resubscribing up to
+	// ResubscribeRetries more times. A zero timeout disables verification.
+	ResubscribeTimeout time.Duration
+	ResubscribeRetries int
+
+	// HistorySize is how many lifecycle events History keeps (default 100)
+	HistorySize int
+
+	// TransferFormat is the hub message encoding negotiated on every
+	// connection (default text)
+	TransferFormat TransferFormat
 
 	// HTTP settings
 	UserAgent         string
 	AdditionalHeaders map[string]string
 	HTTPTimeout       time.Duration
+
+	// Injection seams (nil means the production default)
+	Clock     Clock
+	Connector HubConnector
+	Hooks     ClientHooks
 }
 
+// DefaultConnectionTimeout bounds the wait for the hub handshake when the
+// configuration leaves it unset
+const DefaultConnectionTimeout = 30 * time.Second
+
+// DefaultMaxReconnectAttempts is how many reconnects are attempted after a
+// drop before the client fails
+const DefaultMaxReconnectAttempts = 20
+
+// DefaultMaxMessageSize bounds the raw arguments of a message, well above the
+// largest market snapshot the feed sends
+const DefaultMaxMessageSize = 16 << 20
+
 // DefaultClientConfig returns a default client configuration
 func DefaultClientConfig() *ClientConfig {
 	return &ClientConfig{
-		ConnectionTimeout:    30 * time.Second,
+		ConnectionTimeout:    DefaultConnectionTimeout,
 		ReconnectDelay:       2 * time.Second,
 		MaxReconnectDelay:    2 * time.Minute,
-		MaxReconnectAttempts: 20,
+		MaxReconnectAttempts: DefaultMaxReconnectAttempts,
+		ReconnectJitter:      backoff.DefaultJitter,
 		MessageBufferSize:    100,
 		EnableHeartbeat:      true,
 		HeartbeatInterval:    30 * time.Second,
+		MaxMessageSize:       DefaultMaxMessageSize,
+		ResubscribeTimeout:   15 * time.Second,
+		ResubscribeRetries:   2,
+		HistorySize:          DefaultHistorySize,
+		TransferFormat:       DefaultTransferFormat,
 		UserAgent:            "Go-SignalR-Client/1.0",
 		HTTPTimeout:          30 * time.Second,
 		AdditionalHeaders:    make(map[string]string),
@@ -75,28 +170,111 @@ func DefaultClientConfig() *ClientConfig {
 type Client struct {
 	hubURL       string
 	token        string
-	client       signalr.Client
 	messagesChan chan Message
 	logger       *log.Logger
 	ctx          context.Context
 	cancel       context.CancelFunc
 	receiver     *MessageReceiver
 
+	// The hub client of the current connection, nil between connections, and
+	// the number of clients found still running when a new one was connected
+	hubMu            sync.Mutex
+	client           HubClient
+	lingeringStopped int
+
 	// Connection management
 	connMu        sync.Mutex
 	connStatus    ConnectionStatus
 	connError     error
 	reconnectChan chan struct{}
+	// Closed once the subscriptions of the current connection are confirmed;
+	// replaced when the connection drops (guarded by connMu)
+	subscriptionsReady chan struct{}
+	subscribed         bool
 
 	// Reconnection settings
-	baseReconnectDelay   time.Duration
-	maxReconnectDelay    time.Duration
+	backoff              *backoff.Strategy // guarded by connMu
 	maxReconnectAttempts int
 	reconnectAttempts    int
 
+	// Statistics that persist across restarts (guarded by connMu)
+	lastMessageAt        time.Time
+	cumulativeReconnects int
+	restoredStatus       string
+
 	// Subscriptions to reapply on reconnection
 	subscriptionsMu sync.RWMutex
 	subscriptions   map[string][]interface{}
+	// handled are the subscriptions whose handler was registered with them
+	handled map[string]bool
+
+	// connectionTimeout bounds how long Connect waits for the hub handshake
+	connectionTimeout time.Duration
+
+	// encoder lays out subscription arguments for the server's protocol
+	// version, see SetSubscriptionEncoder
+	encoder SubscriptionEncoder
+
+	// Resubscribe verification settings and waiters for the next inbound activity
+	resubscribeTimeout time.Duration
+	resubscribeRetries int
+	activityMu         sync.Mutex
+	activityWaiters    []chan struct{}
+
+	// Connection lifecycle events for postmortems, see SetLifecycleRecorder,
+	// and the latest of them in memory, see History
+	lifecycle *LifecycleRecorder
+	history   *lifecycleHistory
+
+	// Background failures for the embedding application, see Errors
+	errors        chan ClientError
+	errorsDropped atomic.Int64
+
+	// Heartbeat round trips and the server clock skew of the current
+	// connection, see HeartbeatLatency and ClockSkew
+	latency   LatencyHistogram
+	skewNanos atomic.Int64
+	skewKnown atomic.Bool
+
+	// transferFormat is negotiated on every connection
+	transferFormat TransferFormat
+
+	// Injected dependencies
+	clock     Clock
+	connector HubConnector
+	hooks     ClientHooks
+
+	// The connection monitor and heartbeat outlive connections and start once
+	monitorOnce   sync.Once
+	heartbeatOnce sync.Once
+
+	// Shutdown happens in two steps, see StopReceiving and Close
+	stopOnce  sync.Once
+	closeOnce sync.Once
+}
+
+// hub returns the hub client of the current connection, nil between connections
+func (c *Client) hub() HubClient {
+	c.hubMu.Lock()
+	defer c.hubMu.Unlock()
+	return c.client
+}
+
+// detachHub forgets the hub client of the current connection and returns it
+func (c *Client) detachHub() HubClient {
+	c.hubMu.Lock()
+	defer c.hubMu.Unlock()
+	previous := c.client
+	c.client = nil
+	return previous
+}
+
+// stopHub stops the hub client of the current connection, if any
+func (c *Client) stopHub() {
+	if previous := c.detachHub(); previous != nil {
+		c.logger.Println("Stopping SignalR client")
+		previous.Stop()
+	}
 }
 
 // Messages returns the channel that receives SignalR messages
@@ -111,6 +289,29 @@ func (c *Client) Status() ConnectionStatus {
 	return c.connStatus
 }
 
+// SubscriptionsReady returns a channel that is closed once the data subscriptions
+// of the current connection are confirmed, which can be later than the transport
+// reporting connected. After the connection drops a new channel is handed out
+// for the next connection.
+func (c *Client) SubscriptionsReady() <-chan struct{} {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.subscriptionsReady
+}
+
+// markSubscriptionsReady signals that the current connection's subscriptions are confirmed
+func (c *Client) markSubscriptionsReady() {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	if c.subscribed || c.connStatus != ConnectionStatusConnected {
+		return
+	}
+	c.subscribed = true
+	close(c.subscriptionsReady)
+	c.logger.Println("✅ Subscriptions confirmed")
+}
+
 // LastError returns the last connection error
 func (c *Client) LastError() error {
 	c.connMu.Lock()
@@ -120,10 +321,21 @@ func (c *Client) LastError() error {
 
 // Subscribe subscribes to a SignalR event with the provided arguments
 func (c *Client) Subscribe(method string, args ...interface{}) error {
+	return c.subscribe(1, method, args...)
+}
+
+// subscribe sends a subscription without waiting for the result; a failure is
+// reported on Errors as the given attempt
+func (c *Client) subscribe(attempt int, method string, args ...interface{}) error {
 	if c.Status() != ConnectionStatusConnected {
 		return fmt.Errorf("not connected (status: %v)", c.Status())
 	}
 
+	hub := c.hub()
+	if hub == nil {
+		return fmt.Errorf("not connected (no hub client)")
+	}
+
 	// Store subscription for reconnect
 	c.storeSubscription(method, args...)
 
@@ -133,18 +345,83 @@ func (c *Client) Subscribe(method string, args ...interface{}) error {
 	// Use Invoke as per documentation
 	go func() {
 		c.logger.Printf("Starting Invoke for method: %s", method)
-		result := <-c.client.Send(method, args...)
+		result := <-hub.Send(method, args...)
 		c.logger.Printf("Subscription result for %s: %v", method, result)
 
 		// Check for errors in the result
 		if result != nil {
 			c.logger.Printf("Subscription completed with result: %v", result)
+			c.reportError(ClientErrorSubscribe, method, attempt, result)
 		}
 	}()
 
 	return nil
 }
 
+// SubscribeWithHandler registers handler for messages of method and subscribes
+// to method with args. When the subscription fails the previous handler, if
+// any, is restored. Unsubscribe removes both.
+func (c *Client) SubscribeWithHandler(method string, handler MessageHandler, args ...interface{}) error {
+	if c.receiver == nil {
+		return fmt.Errorf("no receiver to register a handler for %s", method)
+	}
+	// Registered first, so the first message of the subscription finds it
+	previous, hadPrevious := c.receiver.swapHandler(method, handler)
+	if err := c.Subscribe(method, args...); err != nil {
+		if hadPrevious {
+			c.receiver.swapHandler(method, previous)
+		} else {
+			c.receiver.UnregisterHandler(method)
+		}
+		return err
+	}
+	c.subscriptionsMu.Lock()
+	c.handled[method] = true
+	c.subscriptionsMu.Unlock()
+	c.logger.Printf("Registered handler and subscription for method: %s", method)
+	return nil
+}
+
+// Unsubscribe forgets a subscription so it is no longer reapplied after a
+// reconnect, and unregisters its handler when it was made with
+// SubscribeWithHandler. The hub itself is not told, so messages may still
+// arrive; without a handler they go to the general channel.
+func (c *Client) Unsubscribe(method string) {
+	c.subscriptionsMu.Lock()
+	delete(c.subscriptions, method)
+	handled := c.handled[method]
+	delete(c.handled, method)
+	c.subscriptionsMu.Unlock()
+
+	if handled && c.receiver != nil {
+		c.receiver.UnregisterHandler(method)
+	}
+	c.logger.Printf("Unsubscribed from method: %s", method)
+}
+
+// subscribeAndWait subscribes and waits up to timeout for the hub to accept the invocation
+func (c *Client) subscribeAndWait(timeout time.Duration, method string, args ...interface{}) error {
+	if c.Status() != ConnectionStatusConnected {
+		return fmt.Errorf("not connected (status: %v)", c.Status())
+	}
+	hub := c.hub()
+	if hub == nil {
+		return fmt.Errorf("not connected (no hub client)")
+	}
+	c.storeSubscription(method, args...)
+
+	c.logger.Printf("Subscribing to method %s with %d arguments", method, len(args))
+	select {
+	case err := <-hub.Send(method, args...):
+		return err
+	case <-time.After(timeout):
+		// A response bound like the heartbeat's, in real time even under a replay clock
+		return fmt.Errorf("no response to %s within %v", method, timeout)
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
 // storeSubscription stores a subscription for reapplication after reconnect
 func (c *Client) storeSubscription(method string, args ...interface{}) {
 	c.subscriptionsMu.Lock()
@@ -160,6 +437,10 @@ func (c *Client) storeSubscription(method string, args ...interface{}) {
 // MessageHandler is a function type for handling SignalR messages
 type MessageHandler func(msg Message)
 
+// RawFrameTap observes the arguments of a server invocation exactly as delivered
+// by the hub, before any routing, decompression or parsing
+type RawFrameTap func(method string, args []interface{})
+
 // MessageReceiver implements signalr.Receiver for handling server callbacks
 // The key is to embed signalr.Hub and implement the Receive method to catch all calls
 type MessageReceiver struct {
@@ -171,6 +452,82 @@ type MessageReceiver struct {
 	// Handler registry
 	handlersMu sync.RWMutex
 	handlers   map[string]MessageHandler
+
+	// Optional raw frame tap
+	tapMu sync.RWMutex
+	tap   RawFrameTap
+
+	// Inbound delivery stops once stopped is closed; sendMu lets shutdown wait
+	// for deliveries already in flight
+	sendMu    sync.RWMutex
+	accepting bool
+	stopped   chan struct{}
+
+	// Messages whose raw arguments exceed maxMessageSize are dropped and
+	// counted; the first drop is logged
+	maxMessageSize int
+	oversized      atomic.Int64
+	oversizedOnce  sync.Once
+}
+
+// admit reports whether a message's raw arguments fit within maxMessageSize.
+// Oversized messages are counted and only the first is logged, so a flood of
+// them cannot flood the log too.
+func (r *MessageReceiver) admit(method string, args ...interface{}) bool {
+	if r.maxMessageSize <= 0 {
+		return true
+	}
+	size := 0
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			size += len(v)
+		case []byte:
+			size += len(v)
+		}
+	}
+	if size <= r.maxMessageSize {
+		return true
+	}
+	r.oversized.Add(1)
+	r.oversizedOnce.Do(func() {
+		r.logger.Printf("⚠️ Dropping oversized %s message: %d bytes exceeds the %d byte limit (further drops are only counted)", method, size, r.maxMessageSize)
+	})
+	return false
+}
+
+// OversizedDropped returns the number of messages dropped for exceeding the
+// message size limit
+func (c *Client) OversizedDropped() int64 {
+	return c.receiver.oversized.Load()
+}
+
+// deliver queues a message for the consumer of Messages. It returns false when
+// the message was dropped because the client is shutting down.
+func (r *MessageReceiver) deliver(msg Message) bool {
+	r.sendMu.RLock()
+	defer r.sendMu.RUnlock()
+
+	if !r.accepting {
+		r.logger.Printf("Dropping %s message: client is shutting down", msg.Method)
+		return false
+	}
+	select {
+	case r.messagesChan <- msg:
+		return true
+	case <-r.stopped:
+		r.logger.Printf("Dropping %s message: client is shutting down", msg.Method)
+		return false
+	}
+}
+
+// stopReceiving refuses further deliveries and waits for those in flight, so
+// nothing is added to the channel afterwards
+func (r *MessageReceiver) stopReceiving() {
+	close(r.stopped)
+	r.sendMu.Lock()
+	r.accepting = false
+	r.sendMu.Unlock()
 }
 
 // The SignalR library will call Receive for ANY method that doesn't exist on the receiver
@@ -187,9 +544,74 @@ func (r *MessageReceiver) RegisterHandler(methodName string, handler MessageHand
 	r.handlers[lowerMethod] = handler
 }
 
+// SetRawFrameTap installs a tap called with every raw invocation; nil removes it
+func (r *MessageReceiver) SetRawFrameTap(tap RawFrameTap) {
+	r.tapMu.Lock()
+	defer r.tapMu.Unlock()
+	r.tap = tap
+}
+
+// tapRaw hands the raw arguments to the tap. A panicking tap is logged and
+// never interrupts normal routing.
+func (r *MessageReceiver) tapRaw(method string, args ...interface{}) {
+	r.tapMu.RLock()
+	tap := r.tap
+	r.tapMu.RUnlock()
+	if tap == nil {
+		return
+	}
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			r.logger.Printf("Raw frame tap panicked for method %s: %v", method, rec)
+		}
+	}()
+	tap(method, args)
+}
+
+// markActivity tells the client that the server delivered a data message or
+// subscription acknowledgement
+func (r *MessageReceiver) markActivity() {
+	if r.client != nil {
+		r.client.notifyActivity()
+	}
+}
+
+// UnregisterHandler removes the custom handler of a method name
+func (r *MessageReceiver) UnregisterHandler(methodName string) {
+	r.handlersMu.Lock()
+	defer r.handlersMu.Unlock()
+	delete(r.handlers, strings.ToLower(methodName))
+}
+
+// swapHandler registers handler for a method name and returns the one it replaced
+func (r *MessageReceiver) swapHandler(methodName string, handler MessageHandler) (MessageHandler, bool) {
+	r.handlersMu.Lock()
+	defer r.handlersMu.Unlock()
+	lowerMethod := strings.ToLower(methodName)
+	previous, ok := r.handlers[lowerMethod]
+	r.handlers[lowerMethod] = handler
+	return previous, ok
+}
+
 // Receive handles incoming SignalR messages and sends them to the message channel
 // This is the core function that gets called by the SignalR library for all server-to-client methods
 func (r *MessageReceiver) Receive(method string, args ...interface{}) {
+	if !r.admit(method, args...) {
+		return
+	}
+	r.tapRaw(method, args...)
+	switch strings.ToLower(method) {
+	case "error", "connectionevent":
+	default:
+		r.markActivity()
+	}
+	if strings.EqualFold(method, "ping") && len(args) > 0 && r.client != nil {
+		if serverTime, ok := parseServerTime(args[0]); ok {
+			r.client.observeServerTime(serverTime, time.Now())
+		}
+	}
+
 	// Log every received message with details for debugging
 	if r.logger != nil {
 		r.logger.Printf("===> ENTRY POINT: Receive method called with method=%s and %d arguments", method, len(args))
@@ -238,7 +660,7 @@ func (r *MessageReceiver) Receive(method string, args ...interface{}) {
 		if len(args) > 0 {
 			if str, ok := args[0].(string); ok {
 				r.logger.Printf("Routing to SharePriceUpdated handler")
-				r.SharePriceUpdated(str)
+				r.forwardSharePrice(str)
 				return
 			}
 		}
@@ -246,7 +668,7 @@ func (r *MessageReceiver) Receive(method string, args ...interface{}) {
 		if len(args) > 0 {
 			if str, ok := args[0].(string); ok {
 				r.logger.Printf("Routing to MarketStatusUpdated^^DSE~ handler")
-				r.MarketStatusUpdated__DSE_(str)
+				r.forwardMarketStatus(str)
 				return
 			}
 		}
@@ -254,14 +676,24 @@ func (r *MessageReceiver) Receive(method string, args ...interface{}) {
 
 	// For non-routed messages or if routing failed, send to the general channel
 	r.logger.Printf("No specific handler found for method: %s, using general handler", method)
-	r.messagesChan <- Message{
+	r.deliver(Message{
 		Method: method,
 		Data:   args,
-	}
+	})
 }
 
 // SharePriceUpdated is called when the server sends a SharePriceUpdated event
 func (r *MessageReceiver) SharePriceUpdated(data string) {
+	if !r.admit("SharePriceUpdated", data) {
+		return
+	}
+	r.tapRaw("SharePriceUpdated", data)
+	r.markActivity()
+	r.forwardSharePrice(data)
+}
+
+// forwardSharePrice sends share price data to the message channel
+func (r *MessageReceiver) forwardSharePrice(data string) {
 	r.logger.Printf("SharePriceUpdated specific handler called with data length: %d", len(data))
 	if len(data) < 100 {
 		r.logger.Printf("Data content: %s", data)
@@ -270,14 +702,24 @@ func (r *MessageReceiver) SharePriceUpdated(data string) {
 	}
 
 	// Send the processed message to the channel
-	r.messagesChan <- Message{
+	r.deliver(Message{
 		Method: "SharePriceUpdated",
 		Data:   data,
-	}
+	})
 }
 
 // MarketStatusUpdated^^DSE~ is called when the server sends a MarketStatusUpdated event
 func (r *MessageReceiver) MarketStatusUpdated__DSE_(data string) {
+	if !r.admit("MarketStatusUpdated^^DSE~", data) {
+		return
+	}
+	r.tapRaw("MarketStatusUpdated^^DSE~", data)
+	r.markActivity()
+	r.forwardMarketStatus(data)
+}
+
+// forwardMarketStatus sends market status data to the message channel
+func (r *MessageReceiver) forwardMarketStatus(data string) {
 	r.logger.Printf("MarketStatusUpdated^^DSE~ handler called with data length: %d", len(data))
 	if len(data) < 100 {
 		r.logger.Printf("Market status data content: %s", data)
@@ -286,15 +728,16 @@ func (r *MessageReceiver) MarketStatusUpdated__DSE_(data string) {
 	}
 
 	// Send the processed message to the channel
-	r.messagesChan <- Message{
+	r.deliver(Message{
 		Method: "MarketStatusUpdated^^DSE~",
 		Data:   data,
-	}
+	})
 }
 
 // SubscribeToSharePriceUpdatedEvent handles subscription responses
 func (r *MessageReceiver) SubscribeToSharePriceUpdatedEvent(result interface{}) {
 	r.logger.Printf("Subscription result received: %v", result)
+	r.markActivity()
 
 	// No need to forward this to the messagesChan
 	// as it's just a confirmation of the subscription
@@ -304,10 +747,10 @@ func (r *MessageReceiver) SubscribeToSharePriceUpdatedEvent(result interface{})
 func (r *MessageReceiver) HandleError(errorMessage string) {
 	r.logger.Printf("Error received from server: %s", errorMessage)
 
-	r.messagesChan <- Message{
+	r.deliver(Message{
 		Method: "Error",
 		Data:   errorMessage,
-	}
+	})
 
 	// Notify the client of the error
 	if r.client != nil {
@@ -332,17 +775,31 @@ func (r *MessageReceiver) HandleConnectionEvent(eventType string, data interface
 	}
 
 	// Forward the event to the message channel
-	r.messagesChan <- Message{
+	r.deliver(Message{
 		Method: "ConnectionEvent",
 		Data: map[string]interface{}{
 			"type": eventType,
 			"data": data,
 		},
-	}
+	})
 }
 
 // NewClient creates a new SignalR client
 func NewClient(cfg *config.Config, token string) *Client {
+	maxMessageSize := cfg.MaxMessageSize
+	if maxMessageSize <= 0 {
+		maxMessageSize = DefaultMaxMessageSize
+	}
+	maxReconnectAttempts := cfg.MaxReconnectAttempts
+	if maxReconnectAttempts <= 0 {
+		maxReconnectAttempts = DefaultMaxReconnectAttempts
+	}
+	// An unknown transfer_format is rejected at startup; fall back to the default
+	transferFormat, err := ParseTransferFormat(cfg.TransferFormat)
+	if err != nil {
+		transferFormat = DefaultTransferFormat
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	messagesChan := make(chan Message, 100)
 
@@ -350,23 +807,36 @@ func NewClient(cfg *config.Config, token string) *Client {
 		hubURL:               cfg.SignalRURL,
 		token:                token,
 		messagesChan:         messagesChan,
-		logger:               log.New(os.Stdout, "[_________SignalR_________] ", log.LstdFlags),
+		logger:               logging.New("[_________SignalR_________] "),
 		ctx:                  ctx,
 		cancel:               cancel,
 		reconnectChan:        make(chan struct{}, 1),
 		connStatus:           ConnectionStatusDisconnected,
-		baseReconnectDelay:   2 * time.Second,
-		maxReconnectDelay:    2 * time.Minute,
-		maxReconnectAttempts: 20,
+		subscriptionsReady:   make(chan struct{}),
+		backoff:              backoff.New(backoff.DefaultInitial, 2*time.Minute, backoff.DefaultMultiplier, backoff.DefaultJitter),
+		maxReconnectAttempts: maxReconnectAttempts,
 		subscriptions:        make(map[string][]interface{}),
+		handled:              make(map[string]bool),
+		errors:               make(chan ClientError, clientErrorBuffer),
+		connectionTimeout:    DefaultConnectionTimeout,
+		resubscribeTimeout:   15 * time.Second,
+		resubscribeRetries:   2,
+		history:              newLifecycleHistory(cfg.ConnectionHistorySize),
+		transferFormat:       transferFormat,
+		clock:                realClock{},
+		connector:            newHTTPHubClient,
 	}
 
 	// Create message receiver with proper handlers map and client reference
 	client.receiver = &MessageReceiver{
 		messagesChan: messagesChan,
-		logger:       log.New(os.Stdout, "[***********SignalR Receiver***********] ", log.LstdFlags),
+		logger:       logging.New("[***********SignalR Receiver***********] "),
 		client:       client,
 		handlers:     make(map[string]MessageHandler),
+		accepting:    true,
+		stopped:      make(chan struct{}),
+
+		maxMessageSize: maxMessageSize,
 	}
 
 	return client
@@ -385,23 +855,53 @@ func NewClientWithConfig(cfg *config.Config, token string, clientCfg *ClientConf
 		hubURL:               cfg.SignalRURL,
 		token:                token,
 		messagesChan:         messagesChan,
-		logger:               log.New(os.Stdout, "[_________SignalR_________] ", log.LstdFlags),
+		logger:               logging.New("[_________SignalR_________] "),
 		ctx:                  ctx,
 		cancel:               cancel,
 		reconnectChan:        make(chan struct{}, 1),
 		connStatus:           ConnectionStatusDisconnected,
-		baseReconnectDelay:   clientCfg.ReconnectDelay,
-		maxReconnectDelay:    clientCfg.MaxReconnectDelay,
+		subscriptionsReady:   make(chan struct{}),
+		backoff:              backoff.New(clientCfg.ReconnectDelay, clientCfg.MaxReconnectDelay, backoff.DefaultMultiplier, clientCfg.ReconnectJitter),
 		maxReconnectAttempts: clientCfg.MaxReconnectAttempts,
 		subscriptions:        make(map[string][]interface{}),
+		handled:              make(map[string]bool),
+		errors:               make(chan ClientError, clientErrorBuffer),
+		connectionTimeout:    clientCfg.ConnectionTimeout,
+		resubscribeTimeout:   clientCfg.ResubscribeTimeout,
+		resubscribeRetries:   clientCfg.ResubscribeRetries,
+		history:              newLifecycleHistory(clientCfg.HistorySize),
+		transferFormat:       clientCfg.TransferFormat,
+		clock:                clientCfg.Clock,
+		connector:            clientCfg.Connector,
+		hooks:                clientCfg.Hooks,
+	}
+	if client.clock == nil {
+		client.clock = realClock{}
+	}
+	if client.connector == nil {
+		client.connector = newHTTPHubClient
+	}
+	if client.transferFormat == "" {
+		client.transferFormat = DefaultTransferFormat
+	}
+	if client.connectionTimeout <= 0 {
+		client.connectionTimeout = DefaultConnectionTimeout
+	}
+	maxMessageSize := clientCfg.MaxMessageSize
+	if maxMessageSize <= 0 {
+		maxMessageSize = DefaultMaxMessageSize
 	}
 
 	// Create message receiver with proper handlers map and client reference
 	client.receiver = &MessageReceiver{
 		messagesChan: messagesChan,
-		logger:       log.New(os.Stdout, "[***********SignalR Receiver***********] ", log.LstdFlags),
+		logger:       logging.New("[***********SignalR Receiver***********] "),
 		client:       client,
 		handlers:     make(map[string]MessageHandler),
+		accepting:    true,
+		stopped:      make(chan struct{}),
+
+		maxMessageSize: maxMessageSize,
 	}
 
 	return client
@@ -413,9 +913,13 @@ func (c *Client) handleConnected() {
 	defer c.connMu.Unlock()
 
 	wasReconnecting := c.connStatus == ConnectionStatusReconnecting
-	c.connStatus = ConnectionStatusConnected
+	c.setStatusLocked(ConnectionStatusConnected)
 	c.reconnectAttempts = 0
+	c.backoff.Reset()
 	c.connError = nil
+	// A new connection may reach another server, with its own latency and clock
+	c.resetLatency()
+	c.recordLifecycle(LifecycleEvent{Kind: LifecycleConnect, Status: ConnectionStatusConnected.String()})
 
 	c.logger.Printf("SignalR connection established")
 
@@ -428,17 +932,22 @@ func (c *Client) handleConnected() {
 // handleDisconnected updates the connection state when disconnected
 func (c *Client) handleDisconnected(err error) {
 	c.connMu.Lock()
-	if c.connStatus == ConnectionStatusDisconnected {
-		// Already disconnected
+	if c.connStatus == ConnectionStatusDisconnected || c.connStatus == ConnectionStatusFailed {
+		// Already disconnected, or given up on
 		c.connMu.Unlock()
 		return
 	}
 
-	c.connStatus = ConnectionStatusDisconnected
+	c.setStatusLocked(ConnectionStatusDisconnected)
 	c.connError = err
 	c.connMu.Unlock()
 
 	c.logger.Printf("SignalR disconnected: %v", err)
+	event := LifecycleEvent{Kind: LifecycleDisconnect, Status: ConnectionStatusDisconnected.String()}
+	if err != nil {
+		event.Reason = err.Error()
+	}
+	c.recordLifecycle(event)
 
 	// Trigger reconnection if not explicitly closed
 	select {
@@ -460,7 +969,7 @@ func (c *Client) handleReconnecting() {
 	c.connMu.Lock()
 	defer c.connMu.Unlock()
 
-	c.connStatus = ConnectionStatusReconnecting
+	c.setStatusLocked(ConnectionStatusReconnecting)
 	c.logger.Printf("SignalR reconnecting...")
 }
 
@@ -487,22 +996,106 @@ func (c *Client) Connect() error {
 		return nil
 	}
 
-	// Update status
-	c.connStatus = ConnectionStatusConnecting
+	// Update status, remembering whether this connect resumes a dropped connection
+	resuming := c.connStatus == ConnectionStatusReconnecting
+	c.setStatusLocked(ConnectionStatusConnecting)
 	c.connMu.Unlock()
 
-	c.logger.Println("Connecting to SignalR hub:", c.hubURL)
+	// A client left running by an earlier connection would deliver every
+	// message a second time
+	if lingering := c.detachHub(); lingering != nil {
+		c.hubMu.Lock()
+		c.lingeringStopped++
+		c.hubMu.Unlock()
+		c.logger.Println("⚠️ Stopping a SignalR client still running from an earlier connection")
+		lingering.Stop()
+	}
+
+	c.logger.Printf("Connecting to SignalR hub: %s (%s transfer format)", c.hubURL, c.transferFormat)
+
+	// Create the hub client through the connector so it can be replaced in tests
+	hubClient, err := c.connector(c.ctx, c.hubURL, c.currentToken(), c.transferFormat, c.receiver)
+	if err != nil {
+		c.handleConnectionError(err)
+		return err
+	}
+	c.hubMu.Lock()
+	c.client = hubClient
+	c.hubMu.Unlock()
+
+	// Start the client. Start returns before the hub handshake, so the
+	// connection is only reported once the client confirms it.
+	hubClient.Start()
+	c.logger.Println("SignalR client started, waiting for the hub handshake")
+	if err := c.awaitHandshake(hubClient); err != nil {
+		c.logger.Printf("❌ SignalR handshake failed: %v", err)
+		c.detachHub()
+		hubClient.Stop()
+		c.handleHandshakeFailed(err, resuming)
+		return err
+	}
+
+	c.handleConnected()
+
+	// Start connection monitor
+	c.monitorOnce.Do(func() { go c.monitorConnection() })
+
+	// Start heartbeat to detect broken connections
+	c.heartbeatOnce.Do(c.startHeartbeat)
+
+	// Restore the previous subscriptions when resuming, otherwise subscribe to the defaults
+	if resuming && c.subscriptionCount() > 0 {
+		go c.reapplySubscriptions()
+	} else {
+		c.SubscribeToDefaultEvents()
+	}
+
+	return nil
+}
 
+// awaitHandshake waits up to the connection timeout for the hub client to
+// complete the handshake
+func (c *Client) awaitHandshake(hubClient HubClient) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.connectionTimeout)
+	defer cancel()
+	if err := <-hubClient.WaitForState(ctx, signalr.ClientConnected); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("hub handshake not completed within %v", c.connectionTimeout)
+		}
+		return fmt.Errorf("hub handshake: %w", err)
+	}
+	return nil
+}
+
+// handleHandshakeFailed records a connection that never completed its
+// handshake. A failed reconnect stays reconnecting, so the next successful
+// attempt still restores the subscriptions.
+func (c *Client) handleHandshakeFailed(err error, resuming bool) {
+	c.connMu.Lock()
+	status := ConnectionStatusDisconnected
+	if resuming {
+		status = ConnectionStatusReconnecting
+	}
+	c.setStatusLocked(status)
+	c.connError = err
+	attempt := c.reconnectAttempts
+	c.connMu.Unlock()
+	c.recordLifecycle(LifecycleEvent{Kind: LifecycleConnectFailed, Status: status.String(), Attempt: attempt, Reason: err.Error()})
+}
+
+// newHTTPHubClient is the default HubConnector: it negotiates an HTTP connection
+// to the hub and builds a signalr client on top of it
+func newHTTPHubClient(ctx context.Context, hubURL, token string, format TransferFormat, receiver interface{}) (HubClient, error) {
 	// Create HTTP connection with configurable options
 	// Use a timeout for the initial connection
-	creationCtx, creationCancel := context.WithTimeout(c.ctx, 10*time.Second)
+	creationCtx, creationCancel := context.WithTimeout(ctx, 10*time.Second)
 	defer creationCancel()
 
 	// Configurable HTTP connection with proper headers
-	conn, err := signalr.NewHTTPConnection(creationCtx, c.hubURL,
+	conn, err := signalr.NewHTTPConnection(creationCtx, hubURL,
 		signalr.WithHTTPHeaders(func() http.Header {
 			h := make(http.Header)
-			h.Set("Authorization", "Bearer "+c.token)
+			h.Set("Authorization", "Bearer "+token)
 			h.Set("User-Agent", "Go-SignalR-Client/1.0")
 			h.Set("Accept", "application/json")
 			h.Set("Content-Type", "application/json")
@@ -522,47 +1115,53 @@ func (c *Client) Connect() error {
 		}),
 	)
 	if err != nil {
-		c.handleConnectionError(err)
-		return fmt.Errorf("failed to create HTTP connection: %w", err)
+		return nil, fmt.Errorf("failed to create HTTP connection: %w", err)
 	}
 
 	// Create signalr client with receiver that can handle special character method names
-	c.client, err = signalr.NewClient(
-		c.ctx,
+	client, err := signalr.NewClient(
+		ctx,
 		signalr.WithConnection(conn),
-		signalr.TransferFormat(signalr.TransferFormatText), // Use text format for JSON
-		signalr.WithReceiver(c.receiver),                   // Use our receiver with Hub embedding
+		signalr.TransferFormat(format.hubFormat()), // JSON text or MessagePack binary
+		signalr.WithReceiver(receiver),             // Use our receiver with Hub embedding
 	)
 	if err != nil {
-		c.handleConnectionError(err)
-		return fmt.Errorf("failed to create SignalR client: %w", err)
+		return nil, fmt.Errorf("failed to create SignalR client: %w", err)
 	}
 
-	// Start the client
-	c.client.Start()
-	c.logger.Println("SignalR client started")
-
-	// Handle connection state manually since we don't have callbacks
-	c.handleConnected()
-
-	// Start connection monitor
-	go c.monitorConnection()
-
-	// Start heartbeat to detect broken connections
-	c.startHeartbeat()
+	return client, nil
+}
 
-	// Subscribe to default events automatically after connection
-	c.SubscribeToDefaultEvents()
+// currentToken returns the token to authenticate the next connection with
+func (c *Client) currentToken() string {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.token
+}
 
-	return nil
+// setStatusLocked changes the connection status and notifies the status hook.
+// This function assumes connMu is already held
+func (c *Client) setStatusLocked(status ConnectionStatus) {
+	previous := c.connStatus
+	c.connStatus = status
+	if status != ConnectionStatusConnected && c.subscribed {
+		// The next connection has to confirm its subscriptions again
+		c.subscriptionsReady = make(chan struct{})
+		c.subscribed = false
+	}
+	if previous != status && c.hooks.OnStatusChange != nil {
+		c.hooks.OnStatusChange(previous, status)
+	}
 }
 
 // handleConnectionError processes connection errors
 func (c *Client) handleConnectionError(err error) {
 	c.connMu.Lock()
-	c.connStatus = ConnectionStatusDisconnected
+	c.setStatusLocked(ConnectionStatusDisconnected)
 	c.connError = err
+	attempt := c.reconnectAttempts
 	c.connMu.Unlock()
+	c.recordLifecycle(LifecycleEvent{Kind: LifecycleConnectFailed, Status: ConnectionStatusDisconnected.String(), Attempt: attempt, Reason: err.Error()})
 }
 
 // SubscribeToDefaultEvents subscribes to all the required events
@@ -571,7 +1170,7 @@ func (c *Client) SubscribeToDefaultEvents() {
 	c.logger.Println("Subscribing to default events...")
 
 	// Wait a moment for the connection to stabilize
-	time.Sleep(2 * time.Second)
+	<-c.clock.After(2 * time.Second)
 
 	// Subscribe to market status updates with retry logic
 	go func() {
@@ -581,18 +1180,21 @@ func (c *Client) SubscribeToDefaultEvents() {
 
 			if c.Status() != ConnectionStatusConnected {
 				c.logger.Printf("Not connected, skipping subscription attempt %d", attempt)
-				time.Sleep(5 * time.Second)
+				c.reportError(ClientErrorSubscribe, "SubscribeToMarketStatusUpdatedEvent", attempt, fmt.Errorf("not connected (status: %v)", c.Status()))
+				<-c.clock.After(5 * time.Second)
 				continue
 			}
 
-			if err := c.Subscribe("SubscribeToMarketStatusUpdatedEvent", "DSE"); err != nil {
+			if err := c.subscribeAndWait(c.subscribeTimeout(), "SubscribeToMarketStatusUpdatedEvent", "DSE"); err != nil {
 				c.logger.Printf("Warning: market status subscription failed (attempt %d): %v", attempt, err)
+				c.reportError(ClientErrorSubscribe, "SubscribeToMarketStatusUpdatedEvent", attempt, err)
 				if attempt < maxRetries {
-					time.Sleep(5 * time.Second)
+					<-c.clock.After(5 * time.Second)
 					continue
 				}
 			} else {
 				c.logger.Println("✅ Successfully subscribed to market status updates")
+				c.markSubscriptionsReady()
 				break
 			}
 		}
@@ -603,7 +1205,7 @@ func (c *Client) SubscribeToDefaultEvents() {
 	/*
 		go func() {
 			c.logger.Println("Subscribing to share price updates...")
-			if err := c.Subscribe("SubscribeToSharePriceUpdatedEvent", "500$1$$Asc", "DSE", nil, "", "", "", []interface{}{}, "", nil, false, nil); err != nil {
+			if err := c.SubscribeToSharePrices(DefaultSharePriceSubscription()); err != nil {
 				c.logger.Printf("Warning: share price subscription failed: %v", err)
 			} else {
 				c.logger.Println("Successfully subscribed to share price updates")
@@ -644,28 +1246,29 @@ func (c *Client) attemptReconnect() {
 
 	// Check if we've exceeded the maximum number of attempts
 	if c.reconnectAttempts >= c.maxReconnectAttempts {
-		c.logger.Printf("Giving up on reconnection after %d attempts", c.reconnectAttempts)
-		c.connMu.Unlock()
+		c.giveUpLocked()
 		return
 	}
 
 	// Calculate backoff time
 	c.reconnectAttempts++
+	c.cumulativeReconnects++
 	attempt := c.reconnectAttempts
-	backoff := time.Duration(float64(c.baseReconnectDelay) * (1.5 * float64(attempt-1)))
-	if backoff > c.maxReconnectDelay {
-		backoff = c.maxReconnectDelay
-	}
+	delay := c.backoff.Next()
 
-	c.connStatus = ConnectionStatusReconnecting
+	c.setStatusLocked(ConnectionStatusReconnecting)
 	c.connMu.Unlock()
 
 	// Log the reconnection attempt
-	c.logger.Printf("Reconnection attempt #%d after %v", attempt, backoff)
+	c.logger.Printf("Reconnection attempt #%d after %v", attempt, delay)
+	c.recordLifecycle(LifecycleEvent{Kind: LifecycleReconnectAttempt, Status: ConnectionStatusReconnecting.String(), Attempt: attempt, BackoffMs: delay.Milliseconds()})
+	if c.hooks.OnReconnectAttempt != nil {
+		c.hooks.OnReconnectAttempt(attempt, delay)
+	}
 
 	// Wait for backoff period
 	select {
-	case <-time.After(backoff):
+	case <-c.clock.After(delay):
 		break
 	case <-c.ctx.Done():
 		return
@@ -674,14 +1277,13 @@ func (c *Client) attemptReconnect() {
 	// Attempt reconnection
 	c.logger.Println("Executing reconnection attempt")
 
-	// Close existing client if any
-	if c.client != nil {
-		c.client = nil
-	}
+	// Stop the dropped connection's client before connecting a new one
+	c.stopHub()
 
 	// Reconnect
 	if err := c.Connect(); err != nil {
 		c.logger.Printf("Reconnection attempt #%d failed: %v", attempt, err)
+		c.reportError(ClientErrorReconnect, "", attempt, err)
 
 		// Schedule another attempt
 		select {
@@ -693,21 +1295,132 @@ func (c *Client) attemptReconnect() {
 	}
 }
 
-// reapplySubscriptions reapplies all stored subscriptions after reconnection
+// giveUpLocked moves the client to the terminal failed state once the reconnect
+// attempts are exhausted and reports it. It is called with connMu held and
+// releases it.
+func (c *Client) giveUpLocked() {
+	attempts := c.reconnectAttempts
+	lastErr := c.connError
+	c.setStatusLocked(ConnectionStatusFailed)
+	c.connMu.Unlock()
+
+	c.logger.Printf("🛑 Giving up on reconnection after %d attempts, last error: %v", attempts, lastErr)
+	err := fmt.Errorf("giving up after %d attempts", attempts)
+	c.reportError(ClientErrorReconnect, "", attempts, err)
+	c.recordLifecycle(LifecycleEvent{Kind: LifecycleGiveUp, Status: ConnectionStatusFailed.String(), Attempt: attempts, Reason: err.Error()})
+	if c.hooks.OnFailed != nil {
+		c.hooks.OnFailed(attempts, lastErr)
+	}
+}
+
+// OnFailed registers fn to be called once the client gives up reconnecting,
+// replacing ClientConfig.Hooks.OnFailed. It must be called before Connect and,
+// like the other hooks, must not block.
+func (c *Client) OnFailed(fn func(attempts int, lastErr error)) {
+	c.hooks.OnFailed = fn
+}
+
+// reapplySubscriptions reapplies all stored subscriptions after reconnection and,
+// when enabled, verifies the server acknowledged them
 func (c *Client) reapplySubscriptions() {
+	// Copy the subscriptions: Subscribe stores them again under the write lock
 	c.subscriptionsMu.RLock()
-	defer c.subscriptionsMu.RUnlock()
+	subscriptions := make(map[string][]interface{}, len(c.subscriptions))
+	for method, args := range c.subscriptions {
+		subscriptions[method] = args
+	}
+	c.subscriptionsMu.RUnlock()
 
-	c.logger.Printf("Reapplying %d stored subscriptions", len(c.subscriptions))
+	if c.resubscribeTimeout <= 0 {
+		c.resubscribe(subscriptions, 1)
+		c.markSubscriptionsReady()
+		return
+	}
 
-	for method, args := range c.subscriptions {
+	maxAttempts := c.resubscribeRetries + 1
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		// Register before sending so an immediate acknowledgement is not missed
+		activity := c.awaitActivity()
+		c.resubscribe(subscriptions, attempt)
+
+		select {
+		case <-activity:
+			c.logger.Printf("✅ Resubscription verified on attempt %d", attempt)
+			c.markSubscriptionsReady()
+			if c.hooks.OnResubscribeVerified != nil {
+				c.hooks.OnResubscribeVerified(attempt, true)
+			}
+			return
+		case <-c.clock.After(c.resubscribeTimeout):
+			c.logger.Printf("⚠️ No acknowledgement or data within %v after resubscribe attempt %d/%d",
+				c.resubscribeTimeout, attempt, maxAttempts)
+			for method := range subscriptions {
+				c.reportError(ClientErrorSubscribe, method, attempt, fmt.Errorf("not acknowledged within %v", c.resubscribeTimeout))
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+
+	c.logger.Printf("❌ Resubscription not verified after %d attempts; connection may be delivering nothing", maxAttempts)
+	if c.hooks.OnResubscribeVerified != nil {
+		c.hooks.OnResubscribeVerified(maxAttempts, false)
+	}
+}
+
+// resubscribe sends every given subscription once, as the given attempt
+func (c *Client) resubscribe(subscriptions map[string][]interface{}, attempt int) {
+	c.logger.Printf("Reapplying %d stored subscriptions", len(subscriptions))
+
+	for method, args := range subscriptions {
 		c.logger.Printf("Resubscribing to %s with %d arguments", method, len(args))
-		if err := c.Subscribe(method, args...); err != nil {
+		if err := c.subscribe(attempt, method, args...); err != nil {
 			c.logger.Printf("Error resubscribing to %s: %v", method, err)
+			c.reportError(ClientErrorSubscribe, method, attempt, err)
 		}
 	}
 }
 
+// subscribeTimeout bounds how long a subscription may wait for the hub to accept it
+func (c *Client) subscribeTimeout() time.Duration {
+	if c.resubscribeTimeout > 0 {
+		return c.resubscribeTimeout
+	}
+	return 15 * time.Second
+}
+
+// subscriptionCount returns the number of stored subscriptions
+func (c *Client) subscriptionCount() int {
+	c.subscriptionsMu.RLock()
+	defer c.subscriptionsMu.RUnlock()
+	return len(c.subscriptions)
+}
+
+// awaitActivity returns a channel closed on the next inbound data message or acknowledgement
+func (c *Client) awaitActivity() <-chan struct{} {
+	ch := make(chan struct{})
+	c.activityMu.Lock()
+	c.activityWaiters = append(c.activityWaiters, ch)
+	c.activityMu.Unlock()
+	return ch
+}
+
+// notifyActivity records the message time and releases everyone waiting for inbound activity
+func (c *Client) notifyActivity() {
+	c.connMu.Lock()
+	c.lastMessageAt = c.clock.Now()
+	c.connMu.Unlock()
+
+	c.activityMu.Lock()
+	waiters := c.activityWaiters
+	c.activityWaiters = nil
+	c.activityMu.Unlock()
+
+	for _, ch := range waiters {
+		close(ch)
+	}
+}
+
 // UpdateToken updates the authentication token and reconnects if necessary
 func (c *Client) UpdateToken(newToken string) error {
 	c.connMu.Lock()
@@ -723,10 +1436,7 @@ func (c *Client) UpdateToken(newToken string) error {
 		c.logger.Println("Token changed, reconnecting with new token...")
 
 		// Close existing connection
-		if c.client != nil {
-			c.client.Stop()
-			c.client = nil
-		}
+		c.stopHub()
 
 		// Trigger reconnection
 		select {
@@ -746,35 +1456,44 @@ func (c *Client) isConnected() bool {
 
 // Close cleanly closes the SignalR connection
 func (c *Client) Close() {
-	c.logger.Println("Closing SignalR client")
+	c.closeOnce.Do(func() {
+		c.logger.Println("Closing SignalR client")
+		c.StopReceiving()
+
+		// Close message channel last; StopReceiving guarantees no sender is left
+		close(c.messagesChan)
+		c.logger.Println("SignalR client closed")
+	})
+}
 
-	// Cancel context to stop all operations
-	if c.cancel != nil {
-		c.cancel()
-	}
+// StopReceiving stops the hub connection and refuses further inbound messages,
+// leaving those already buffered on Messages for the consumer to drain before
+// Close closes the channel
+func (c *Client) StopReceiving() {
+	c.stopOnce.Do(func() {
+		// Cancel context to stop all operations
+		if c.cancel != nil {
+			c.cancel()
+		}
 
-	// Update status
-	c.connMu.Lock()
-	c.connStatus = ConnectionStatusDisconnected
-	c.connMu.Unlock()
+		// Update status
+		c.connMu.Lock()
+		c.setStatusLocked(ConnectionStatusDisconnected)
+		c.connMu.Unlock()
 
-	// Close the client if it exists
-	if c.client != nil {
-		// Try to send close message gracefully
-		c.logger.Println("Stopping SignalR client")
-		c.client.Stop()
-		c.client = nil
-	}
+		c.receiver.stopReceiving()
 
-	// Close message channel last
-	close(c.messagesChan)
-	c.logger.Println("SignalR client closed")
+		// Close the client if it exists
+		c.stopHub()
+	})
 }
 
 // startHeartbeat starts a heartbeat to detect broken connections
 func (c *Client) startHeartbeat() {
 	c.logger.Println("Starting connection heartbeat")
 
+	// Consecutive failed pings, reported as the attempt of each failure
+	var failures atomic.Int32
 	ticker := time.NewTicker(30 * time.Second)
 	go func() {
 		defer ticker.Stop()
@@ -787,17 +1506,24 @@ func (c *Client) startHeartbeat() {
 					continue
 				}
 
+				hub := c.hub()
+				if hub == nil {
+					continue
+				}
+
 				// Send a ping to check the connection
 				c.logger.Println("Sending heartbeat ping")
 				go func() {
 					// Try to invoke a ping method
 					// Create a channel to receive the result with timeout
-					resultChan := c.client.Send("ping")
+					sentAt := time.Now()
+					resultChan := hub.Send("ping")
 
 					select {
 					case result := <-resultChan:
 						if result != nil {
 							c.logger.Printf("WARNING: Heartbeat ping failed: %v", result)
+							c.reportError(ClientErrorHeartbeat, "ping", int(failures.Add(1)), result)
 
 							// The connection might be broken
 							c.logger.Println("Heartbeat failed, triggering reconnection")
@@ -806,11 +1532,14 @@ func (c *Client) startHeartbeat() {
 							default:
 							}
 						} else {
+							failures.Store(0)
+							c.recordRoundTrip(time.Since(sentAt))
 							c.logger.Println("Heartbeat ping successful")
 						}
 					case <-time.After(10 * time.Second):
 						// Ping timeout - connection might be broken
 						c.logger.Println("Heartbeat ping timeout, triggering reconnection")
+						c.reportError(ClientErrorHeartbeat, "ping", int(failures.Add(1)), errors.New("no response within 10s"))
 						select {
 						case c.reconnectChan <- struct{}{}:
 						default:
@@ -834,6 +1563,14 @@ func (c *Client) RegisterCustomHandler(methodName string, handler MessageHandler
 	}
 }
 
+// SetRawFrameTap installs a tap receiving every raw server invocation before
+// processing. The tap runs on the receive path and must not block.
+func (c *Client) SetRawFrameTap(tap RawFrameTap) {
+	if c.receiver != nil {
+		c.receiver.SetRawFrameTap(tap)
+	}
+}
+
 // GetConnectionStats returns connection statistics
 func (c *Client) GetConnectionStats() map[string]interface{} {
 	c.connMu.Lock()
@@ -844,24 +1581,75 @@ func (c *Client) GetConnectionStats() map[string]interface{} {
 		"reconnectAttempts": c.reconnectAttempts,
 		"lastError":         c.connError,
 		"subscriptions":     len(c.subscriptions),
+		"subscribed":        c.subscribed,
+
+		"lastMessageAt":        c.lastMessageAt,
+		"cumulativeReconnects": c.cumulativeReconnects,
+		"previousRunStatus":    c.restoredStatus,
+		"oversizedDropped":     c.receiver.oversized.Load(),
+	}
+	c.hubMu.Lock()
+	stats["lingeringClientsStopped"] = c.lingeringStopped
+	c.hubMu.Unlock()
+	stats["errorsDropped"] = c.errorsDropped.Load()
+	latency := c.HeartbeatLatency()
+	stats["heartbeatSamples"] = latency.Count
+	stats["heartbeatP50"] = latency.P50
+	stats["heartbeatP95"] = latency.P95
+	stats["heartbeatP99"] = latency.P99
+	if skew, ok := c.ClockSkew(); ok {
+		stats["clockSkew"] = skew
 	}
 
 	return stats
 }
 
+// RestoreStats seeds the statistics from a previous run, so monitoring stays
+// continuous across restarts
+func (c *Client) RestoreStats(stats PersistedStats) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	c.cumulativeReconnects = stats.CumulativeReconnects
+	if stats.LastMessageAt.After(c.lastMessageAt) {
+		c.lastMessageAt = stats.LastMessageAt
+	}
+	c.restoredStatus = stats.LastStatus
+	c.logger.Printf("Restored connection stats: last status %s, last message %v, %d reconnects",
+		stats.LastStatus, stats.LastMessageAt, stats.CumulativeReconnects)
+}
+
+// SnapshotStats returns the statistics to persist
+func (c *Client) SnapshotStats() PersistedStats {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	return PersistedStats{
+		LastStatus:           c.connStatus.String(),
+		LastMessageAt:        c.lastMessageAt,
+		CumulativeReconnects: c.cumulativeReconnects,
+		SavedAt:              c.clock.Now(),
+	}
+}
+
 // Ping sends a ping message to test the connection
 func (c *Client) Ping() error {
 	if c.Status() != ConnectionStatusConnected {
 		return fmt.Errorf("not connected")
 	}
+	hub := c.hub()
+	if hub == nil {
+		return fmt.Errorf("not connected")
+	}
 
 	c.logger.Println("Sending ping to server")
 	go func() {
-		result := <-c.client.Send("ping")
+		result := <-hub.Send("ping")
 		if result == nil {
 			c.logger.Println("Ping successful")
 		} else {
 			c.logger.Printf("Ping failed: %v", result)
+			c.reportError(ClientErrorInvoke, "ping", 1, result)
 		}
 	}()
 
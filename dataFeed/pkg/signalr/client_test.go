@@ -0,0 +1,759 @@
+package signalr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/philippseith/signalr"
+
+	"datafeed/pkg/backoff"
+	"datafeed/pkg/config"
+)
+
+// fakeClock is a Clock whose timers fire immediately while advancing virtual time
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	ch <- now
+	return ch
+}
+
+// handshaken completes the hub handshake as soon as it is awaited
+type handshaken struct{}
+
+func (handshaken) WaitForState(ctx context.Context, waitFor signalr.ClientState) <-chan error {
+	ch := make(chan error)
+	close(ch)
+	return ch
+}
+
+// acceptingHub is a HubClient that accepts every invocation
+type acceptingHub struct{ handshaken }
+
+func (acceptingHub) Start() {}
+
+func (acceptingHub) Stop() {}
+
+func (acceptingHub) Send(method string, arguments ...interface{}) <-chan error {
+	ch := make(chan error, 1)
+	ch <- nil
+	return ch
+}
+
+// rejectingHub rejects every invocation
+type rejectingHub struct{ handshaken }
+
+func (rejectingHub) Start() {}
+
+func (rejectingHub) Stop() {}
+
+func (rejectingHub) Send(method string, arguments ...interface{}) <-chan error {
+	ch := make(chan error, 1)
+	ch <- fmt.Errorf("test: %s rejected", method)
+	return ch
+}
+
+// recordingHub accepts every invocation and records its method and arguments
+type recordingHub struct {
+	handshaken
+	sends chan []interface{}
+}
+
+func (h *recordingHub) Start() {}
+
+func (h *recordingHub) Stop() {}
+
+func (h *recordingHub) Send(method string, arguments ...interface{}) <-chan error {
+	h.sends <- append([]interface{}{method}, arguments...)
+	ch := make(chan error, 1)
+	ch <- nil
+	return ch
+}
+
+// lifecycleHub records when it is started and stopped
+type lifecycleHub struct {
+	acceptingHub
+	id     int
+	record func(event string)
+}
+
+func (h *lifecycleHub) Start() { h.record(fmt.Sprintf("start %d", h.id)) }
+
+func (h *lifecycleHub) Stop() { h.record(fmt.Sprintf("stop %d", h.id)) }
+
+// newTestClient returns a quiet client for clientCfg, closed with the test
+func newTestClient(t *testing.T, clientCfg *ClientConfig) *Client {
+	t.Helper()
+	return newTestClientAt(t, "test://hub", clientCfg)
+}
+
+func newTestClientAt(t *testing.T, hubURL string, clientCfg *ClientConfig) *Client {
+	t.Helper()
+	client := NewClientWithConfig(&config.Config{SignalRURL: hubURL}, "test-token", clientCfg)
+	client.logger = log.New(io.Discard, "", 0)
+	client.receiver.logger = client.logger
+	t.Cleanup(client.Close)
+	return client
+}
+
+// connectedClient returns a test client attached to hub without the connector
+func connectedClient(t *testing.T, clientCfg *ClientConfig, hub HubClient) *Client {
+	t.Helper()
+	client := newTestClient(t, clientCfg)
+	client.client = hub
+	client.handleConnected()
+	return client
+}
+
+// waitForStatus fails the test when the client does not reach status within 5s
+func waitForStatus(t *testing.T, client *Client, status ConnectionStatus) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Status() != status {
+		if time.Now().After(deadline) {
+			t.Fatalf("status %v after 5s, want %v", client.Status(), status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// isReady reports whether the client's SubscriptionsReady has fired
+func isReady(client *Client) bool {
+	select {
+	case <-client.SubscriptionsReady():
+		return true
+	default:
+		return false
+	}
+}
+
+// A dropped connection is retried with the backoff delays on the virtual
+// clock until a connect succeeds or the attempts run out
+func TestReconnect(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		failures    int
+		maxAttempts int
+		baseDelay   time.Duration
+		maxDelay    time.Duration
+	}{
+		{name: "reconnects after failures", failures: 3, maxAttempts: 20, baseDelay: 2 * time.Second, maxDelay: 2 * time.Minute},
+		{name: "delays capped", failures: 8, maxAttempts: 20, baseDelay: 2 * time.Second, maxDelay: 10 * time.Second},
+		{name: "gives up", failures: 5, maxAttempts: 3, baseDelay: 2 * time.Second, maxDelay: 2 * time.Minute},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				mu             sync.Mutex
+				dropped        bool
+				statuses       []ConnectionStatus
+				attempts       int
+				delays         []time.Duration
+				connects       int
+				failed         int
+				failedAttempts int
+			)
+			done := make(chan struct{})
+			var doneOnce sync.Once
+			finish := func() { doneOnce.Do(func() { close(done) }) }
+
+			clientCfg := DefaultClientConfig()
+			clientCfg.ReconnectDelay = tc.baseDelay
+			clientCfg.MaxReconnectDelay = tc.maxDelay
+			clientCfg.ReconnectJitter = 0
+			clientCfg.MaxReconnectAttempts = tc.maxAttempts
+			clientCfg.Clock = newFakeClock()
+			clientCfg.Connector = func(ctx context.Context, hubURL, token string, format TransferFormat, receiver interface{}) (HubClient, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				connects++
+				// The first call is the initial connect; the next failures calls fail
+				if connects > 1 && connects <= tc.failures+1 {
+					return nil, fmt.Errorf("test: scripted failure %d", connects-1)
+				}
+				return acceptingHub{}, nil
+			}
+			clientCfg.Hooks = ClientHooks{
+				OnStatusChange: func(from, to ConnectionStatus) {
+					mu.Lock()
+					defer mu.Unlock()
+					statuses = append(statuses, to)
+					if dropped && to == ConnectionStatusConnected {
+						finish()
+					}
+				},
+				OnFailed: func(n int, lastErr error) {
+					mu.Lock()
+					defer mu.Unlock()
+					failed++
+					failedAttempts = n
+					finish()
+				},
+				OnReconnectAttempt: func(attempt int, delay time.Duration) {
+					mu.Lock()
+					defer mu.Unlock()
+					attempts = attempt
+					delays = append(delays, delay)
+				},
+			}
+			client := newTestClient(t, clientCfg)
+
+			if err := client.Connect(); err != nil {
+				t.Fatalf("initial connect: %v", err)
+			}
+			mu.Lock()
+			dropped = true
+			mu.Unlock()
+			client.handleDisconnected(errors.New("test: simulated drop"))
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("reconnect did not settle within 5s")
+			}
+
+			wantAttempts, wantFinal, wantFailed := tc.failures+1, ConnectionStatusConnected, 0
+			if wantAttempts > tc.maxAttempts {
+				wantAttempts, wantFinal, wantFailed = tc.maxAttempts, ConnectionStatusFailed, 1
+			}
+			final := client.Status()
+			mu.Lock()
+			defer mu.Unlock()
+			if attempts != wantAttempts {
+				t.Errorf("%d reconnect attempts, want %d", attempts, wantAttempts)
+			}
+			if final != wantFinal {
+				t.Errorf("final status %v, want %v (statuses %v)", final, wantFinal, statuses)
+			}
+			if failed != wantFailed || (failed > 0 && failedAttempts != tc.maxAttempts) {
+				t.Errorf("OnFailed called %d times reporting %d attempts, want %d reporting %d", failed, failedAttempts, wantFailed, tc.maxAttempts)
+			}
+			strategy := backoff.New(tc.baseDelay, tc.maxDelay, backoff.DefaultMultiplier, 0)
+			for i, delay := range delays {
+				if want := strategy.Next(); delay != want {
+					t.Errorf("attempt %d waited %v, want %v", i+1, delay, want)
+				}
+				if delay > tc.maxDelay {
+					t.Errorf("attempt %d waited %v, past the %v cap", i+1, delay, tc.maxDelay)
+				}
+			}
+		})
+	}
+}
+
+// ackingHub acknowledges a resubscription by pushing a data message through
+// the receiver, as the real hub does once the subscription is live
+type ackingHub struct {
+	handshaken
+	mu           sync.Mutex
+	receiver     *MessageReceiver
+	sends        int
+	ackOnAttempt int
+}
+
+func (h *ackingHub) Start() {}
+
+func (h *ackingHub) Stop() {}
+
+func (h *ackingHub) Send(method string, arguments ...interface{}) <-chan error {
+	h.mu.Lock()
+	h.sends++
+	ack := h.sends == h.ackOnAttempt
+	h.mu.Unlock()
+
+	if ack {
+		h.receiver.MarketStatusUpdated__DSE_("Open")
+	}
+	ch := make(chan error, 1)
+	ch <- nil
+	return ch
+}
+
+// Resubscriptions are verified by data arriving, retried until it does and
+// reported as failed once the retries run out
+func TestResubscribeVerification(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		ackOnAttempt int
+		timeout      time.Duration
+		sends        int
+		verified     bool
+	}{
+		{name: "ack on first attempt", ackOnAttempt: 1, timeout: 200 * time.Millisecond, sends: 1, verified: true},
+		{name: "ack after one retry", ackOnAttempt: 2, timeout: 200 * time.Millisecond, sends: 2, verified: true},
+		{name: "ack timeout", ackOnAttempt: 0, timeout: 100 * time.Millisecond, sends: 3, verified: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			const retries = 2
+			type outcome struct {
+				attempts int
+				verified bool
+			}
+			done := make(chan outcome, 1)
+			clientCfg := DefaultClientConfig()
+			clientCfg.ResubscribeTimeout = tc.timeout
+			clientCfg.ResubscribeRetries = retries
+			clientCfg.Hooks = ClientHooks{
+				OnResubscribeVerified: func(attempts int, ok bool) { done <- outcome{attempts, ok} },
+			}
+			client := newTestClient(t, clientCfg)
+			// Drain forwarded messages so acknowledgements never block on the channel
+			go func() {
+				for range client.Messages() {
+				}
+			}()
+
+			hub := &ackingHub{receiver: client.receiver, ackOnAttempt: tc.ackOnAttempt}
+			client.client = hub
+			client.handleConnected()
+			client.storeSubscription("SubscribeToMarketStatusUpdatedEvent", "DSE")
+			go client.reapplySubscriptions()
+
+			var got outcome
+			select {
+			case got = <-done:
+			case <-time.After(time.Duration(retries+2) * tc.timeout):
+				t.Fatal("verification did not settle")
+			}
+			hub.mu.Lock()
+			sends := hub.sends
+			hub.mu.Unlock()
+			if sends != tc.sends || got.attempts != tc.sends || got.verified != tc.verified {
+				t.Errorf("%d sends, %d attempts, verified %v; want %d, %d, %v", sends, got.attempts, got.verified, tc.sends, tc.sends, tc.verified)
+			}
+			if isReady(client) != tc.verified {
+				t.Errorf("SubscriptionsReady fired %v, want %v", isReady(client), tc.verified)
+			}
+		})
+	}
+}
+
+// gatedHub hands every invocation to the test, which decides when and how it
+// completes
+type gatedHub struct {
+	handshaken
+	invocations chan chan error
+	stopped     chan struct{}
+	stopOnce    sync.Once
+}
+
+func (h *gatedHub) Start() {}
+
+func (h *gatedHub) Stop() {
+	h.stopOnce.Do(func() { close(h.stopped) })
+}
+
+func (h *gatedHub) Send(method string, arguments ...interface{}) <-chan error {
+	ch := make(chan error, 1)
+	select {
+	case h.invocations <- ch:
+	case <-h.stopped:
+		ch <- errors.New("test: hub stopped")
+	}
+	return ch
+}
+
+// SubscriptionsReady fires only once the hub accepted the default
+// subscription, and no longer reports ready after a drop
+func TestSubscriptionsReady(t *testing.T) {
+	for _, rejected := range []int{0, 2} {
+		t.Run(fmt.Sprintf("%d rejections", rejected), func(t *testing.T) {
+			hub := &gatedHub{invocations: make(chan chan error), stopped: make(chan struct{})}
+			clientCfg := DefaultClientConfig()
+			clientCfg.Clock = newFakeClock()
+			clientCfg.Connector = func(ctx context.Context, hubURL, token string, format TransferFormat, receiver interface{}) (HubClient, error) {
+				return hub, nil
+			}
+			client := newTestClient(t, clientCfg)
+			if err := client.Connect(); err != nil {
+				t.Fatalf("initial connect: %v", err)
+			}
+
+			deadline := time.After(5 * time.Second)
+			for sends := 1; ; sends++ {
+				var invocation chan error
+				select {
+				case invocation = <-hub.invocations:
+				case <-deadline:
+					t.Fatalf("no subscription %d within 5s", sends)
+				}
+				if isReady(client) {
+					t.Fatalf("ready before the hub accepted send %d", sends)
+				}
+				if sends <= rejected {
+					invocation <- fmt.Errorf("test: scripted rejection %d", sends)
+					continue
+				}
+				invocation <- nil
+				break
+			}
+
+			select {
+			case <-client.SubscriptionsReady():
+			case <-deadline:
+				t.Fatal("not ready after the hub accepted the subscription")
+			}
+			client.handleDisconnected(errors.New("test: simulated drop"))
+			if isReady(client) {
+				t.Error("still ready after the connection dropped")
+			}
+		})
+	}
+}
+
+// Messages arriving while the client shuts down in the order main uses are
+// either refused or processed exactly once
+func TestShutdownWhileReceiving(t *testing.T) {
+	const senders, perSender, stopAfter = 4, 500, 300
+	clientCfg := DefaultClientConfig()
+	clientCfg.MessageBufferSize = 8
+	client := newTestClient(t, clientCfg)
+
+	seen := make(map[string]int)
+	startShutdown := make(chan struct{})
+	var startOnce sync.Once
+	dispatcher := NewDispatcher(client.Messages(), func(msg Message) {
+		seen[msg.Data.(string)]++
+		if len(seen) >= stopAfter {
+			startOnce.Do(func() { close(startShutdown) })
+		}
+	})
+	go dispatcher.Run()
+
+	var accepted, dropped atomic.Int64
+	var wg sync.WaitGroup
+	for s := 0; s < senders; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			for i := 0; i < perSender; i++ {
+				msg := Message{Method: "SharePriceUpdated", Data: fmt.Sprintf("%d-%d", s, i)}
+				if client.receiver.deliver(msg) {
+					accepted.Add(1)
+				} else {
+					dropped.Add(1)
+				}
+			}
+		}(s)
+	}
+
+	select {
+	case <-startShutdown:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%d messages were not processed within 5s", stopAfter)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client.StopReceiving()
+	if err := dispatcher.Stop(ctx); err != nil {
+		t.Fatalf("dispatcher did not drain: %v", err)
+	}
+	client.Close()
+	wg.Wait()
+
+	duplicates := 0
+	for _, count := range seen {
+		duplicates += count - 1
+	}
+	if int64(len(seen)) != accepted.Load() {
+		t.Errorf("%d messages accepted but %d processed", accepted.Load(), len(seen))
+	}
+	if duplicates != 0 {
+		t.Errorf("%d messages processed more than once", duplicates)
+	}
+	if accepted.Load()+dropped.Load() != senders*perSender {
+		t.Errorf("%d sent but %d accepted and %d dropped", senders*perSender, accepted.Load(), dropped.Load())
+	}
+}
+
+// SubscribeWithHandler routes the method's messages to the handler until
+// Unsubscribe, and a failed subscription leaves the handlers as they were
+func TestSubscribeWithHandler(t *testing.T) {
+	const method = "SubscribeToNewsEvent"
+	hub := &recordingHub{sends: make(chan []interface{}, 1)}
+	client := connectedClient(t, DefaultClientConfig(), hub)
+	stored := func() bool {
+		client.subscriptionsMu.RLock()
+		defer client.subscriptionsMu.RUnlock()
+		_, ok := client.subscriptions[method]
+		return ok
+	}
+
+	var handled atomic.Int32
+	if err := client.SubscribeWithHandler(method, func(msg Message) { handled.Add(1) }, "DSE"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case sent := <-hub.sends:
+		if !reflect.DeepEqual(sent, []interface{}{method, "DSE"}) {
+			t.Errorf("hub got %v, want %s DSE", sent, method)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not sent to the hub")
+	}
+	if !stored() {
+		t.Error("subscription not kept for reconnects")
+	}
+
+	client.receiver.Receive(method, "payload")
+	if handled.Load() != 1 {
+		t.Errorf("handler got %d messages, want 1", handled.Load())
+	}
+	client.Unsubscribe(method)
+	client.receiver.Receive(method, "payload")
+	if handled.Load() != 1 {
+		t.Error("handler still called after Unsubscribe")
+	}
+	select {
+	case <-client.Messages():
+	default:
+		t.Error("message after Unsubscribe was not forwarded to the general channel")
+	}
+	if stored() {
+		t.Error("subscription still kept after Unsubscribe")
+	}
+
+	// A failed subscription, first without and then over an existing handler
+	client.handleDisconnected(errors.New("test: simulated drop"))
+	if err := client.SubscribeWithHandler(method, func(msg Message) {}, "DSE"); err == nil {
+		t.Fatal("subscription while disconnected succeeded")
+	}
+	client.receiver.handlersMu.RLock()
+	_, kept := client.receiver.handlers[strings.ToLower(method)]
+	client.receiver.handlersMu.RUnlock()
+	if kept {
+		t.Error("failed subscription left its handler behind")
+	}
+
+	var previous atomic.Int32
+	client.RegisterCustomHandler(method, func(msg Message) { previous.Add(1) })
+	if err := client.SubscribeWithHandler(method, func(msg Message) {}, "DSE"); err == nil {
+		t.Fatal("subscription while disconnected succeeded")
+	}
+	client.receiver.Receive(method, "payload")
+	if previous.Load() != 1 {
+		t.Error("failed subscription did not restore the previous handler")
+	}
+}
+
+// Oversized messages are dropped before the raw frame tap on both receiver
+// paths, counted, and logged once
+func TestMessageSizeLimit(t *testing.T) {
+	sizes := []int{100, 1024, 1025, 64 << 10}
+	clientCfg := DefaultClientConfig()
+	clientCfg.MaxMessageSize = 1024
+	clientCfg.MessageBufferSize = 2 * len(sizes)
+	client := newTestClient(t, clientCfg)
+	var logged strings.Builder
+	client.logger = log.New(&logged, "", 0)
+	client.receiver.logger = client.logger
+
+	tapped := 0
+	client.receiver.SetRawFrameTap(func(method string, args []interface{}) { tapped++ })
+	for _, size := range sizes {
+		payload := strings.Repeat("x", size)
+		client.receiver.SharePriceUpdated(payload)
+		client.receiver.Receive("NewsUpdated", payload)
+	}
+
+	delivered := 0
+drain:
+	for {
+		select {
+		case <-client.Messages():
+			delivered++
+		default:
+			break drain
+		}
+	}
+	if delivered != 4 || tapped != 4 {
+		t.Errorf("%d delivered and %d tapped, want 4 and 4", delivered, tapped)
+	}
+	if dropped := client.OversizedDropped(); dropped != 4 {
+		t.Errorf("%d oversized drops counted, want 4", dropped)
+	}
+	if warnings := strings.Count(logged.String(), "Dropping oversized"); warnings != 1 {
+		t.Errorf("oversized drops logged %d times, want once", warnings)
+	}
+	if stats := client.GetConnectionStats(); stats["oversizedDropped"] != int64(4) {
+		t.Errorf("connection stats report %v oversized drops, want 4", stats["oversizedDropped"])
+	}
+}
+
+// Every hub client is stopped before the next one starts, across reconnects
+// and a Connect that finds the previous client still attached
+func TestHubClientLifecycle(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	reconnected := make(chan struct{}, 1)
+
+	clientCfg := DefaultClientConfig()
+	clientCfg.ReconnectJitter = 0
+	clientCfg.Clock = newFakeClock()
+	connects := 0
+	clientCfg.Connector = func(ctx context.Context, hubURL, token string, format TransferFormat, receiver interface{}) (HubClient, error) {
+		connects++
+		return &lifecycleHub{id: connects, record: record}, nil
+	}
+	clientCfg.Hooks = ClientHooks{
+		OnReconnectAttempt: func(attempt int, delay time.Duration) {
+			select {
+			case reconnected <- struct{}{}:
+			default:
+			}
+		},
+	}
+	client := newTestClient(t, clientCfg)
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("initial connect: %v", err)
+	}
+	client.handleDisconnected(errors.New("test: simulated drop"))
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reconnect attempt within 5s")
+	}
+	waitForStatus(t, client, ConnectionStatusConnected)
+
+	// A status change without a reconnect leaves the hub client attached
+	client.connMu.Lock()
+	client.setStatusLocked(ConnectionStatusDisconnected)
+	client.connMu.Unlock()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("second connect: %v", err)
+	}
+	client.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"start 1", "stop 1", "start 2", "stop 2", "start 3", "stop 3"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("hub client events %v, want %v", events, want)
+	}
+	if lingering := client.GetConnectionStats()["lingeringClientsStopped"]; lingering != 1 {
+		t.Errorf("%v lingering clients stopped, want 1", lingering)
+	}
+}
+
+// handshakeHub answers the handshake with a scripted outcome
+type handshakeHub struct {
+	outcome string
+	stopped atomic.Bool
+}
+
+func (h *handshakeHub) Start() {}
+
+func (h *handshakeHub) Stop() { h.stopped.Store(true) }
+
+func (h *handshakeHub) Send(method string, arguments ...interface{}) <-chan error {
+	ch := make(chan error, 1)
+	ch <- nil
+	return ch
+}
+
+func (h *handshakeHub) WaitForState(ctx context.Context, waitFor signalr.ClientState) <-chan error {
+	ch := make(chan error, 1)
+	switch h.outcome {
+	case "rejected":
+		ch <- errors.New("test: handshake rejected")
+	case "timeout":
+		go func() {
+			<-ctx.Done()
+			ch <- ctx.Err()
+		}()
+		return ch
+	}
+	close(ch)
+	return ch
+}
+
+// Connected is reported only once the hub handshake completed; a rejected or
+// timed out handshake stops the hub client and leaves the client disconnected
+func TestHandshake(t *testing.T) {
+	for _, tc := range []struct {
+		outcome string
+		final   ConnectionStatus
+	}{
+		{"complete", ConnectionStatusConnected},
+		{"rejected", ConnectionStatusDisconnected},
+		{"timeout", ConnectionStatusDisconnected},
+	} {
+		t.Run(tc.outcome, func(t *testing.T) {
+			hub := &handshakeHub{outcome: tc.outcome}
+			var mu sync.Mutex
+			var statuses []ConnectionStatus
+			clientCfg := DefaultClientConfig()
+			clientCfg.ConnectionTimeout = 200 * time.Millisecond
+			clientCfg.EnableHeartbeat = false
+			clientCfg.Clock = newFakeClock()
+			clientCfg.Connector = func(ctx context.Context, hubURL, token string, format TransferFormat, receiver interface{}) (HubClient, error) {
+				return hub, nil
+			}
+			clientCfg.Hooks = ClientHooks{
+				OnStatusChange: func(from, to ConnectionStatus) {
+					mu.Lock()
+					defer mu.Unlock()
+					statuses = append(statuses, to)
+				},
+			}
+			client := newTestClient(t, clientCfg)
+
+			err := client.Connect()
+			completed := tc.outcome == "complete"
+			if completed != (err == nil) {
+				t.Errorf("Connect returned %v", err)
+			}
+			if final := client.Status(); final != tc.final {
+				t.Errorf("final status %v, want %v", final, tc.final)
+			}
+			if completed {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, status := range statuses {
+				if status == ConnectionStatusConnected {
+					t.Errorf("reported connected before the handshake: %v", statuses)
+				}
+			}
+			if !hub.stopped.Load() {
+				t.Error("hub client left running after the handshake failed")
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package signalr
+
+import "time"
+
+// Clock abstracts the time source used by the reconnect loop so it can be
+// driven deterministically (see ReconnectHarness)
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the wall-clock implementation of Clock
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,168 @@
+package signalr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// DecodeStage transforms a share price payload on its way to the parser,
+// e.g. by base64 decoding or decompressing it. Its output must not exceed limit
+// bytes; a stage that would produce more fails with ErrPayloadTooLarge.
+type DecodeStage func(input []byte, limit int64) ([]byte, error)
+
+// DefaultMaxDecompressedSize bounds what a payload may decode to, far above the
+// few hundred KB of a full market snapshot
+const DefaultMaxDecompressedSize = 64 << 20
+
+// ErrPayloadTooLarge is returned when a payload decodes to more than the limit
+var ErrPayloadTooLarge = errors.New("payload exceeds the size limit")
+
+// decodeStages are the stages pipelines are composed of, by name
+var decodeStages = map[string]DecodeStage{
+	"base64":    decodeBase64,
+	"brotli":    decodeBrotli,
+	"gzip":      decodeGzip,
+	"json_data": decodeJSONData,
+}
+
+// DecodeStageNames lists the stage names pipelines may use
+func DecodeStageNames() []string {
+	return []string{"base64", "brotli", "gzip", "json_data"}
+}
+
+// DecodePipeline is a sequence of named stages run in order. A pipeline with
+// no stages passes the payload through as plain text.
+type DecodePipeline struct {
+	names  []string
+	stages []DecodeStage
+}
+
+// NewDecodePipeline builds a pipeline from stage names such as
+// ["base64", "brotli"]
+func NewDecodePipeline(names []string) (DecodePipeline, error) {
+	var pipeline DecodePipeline
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		stage, ok := decodeStages[name]
+		if !ok {
+			return DecodePipeline{}, fmt.Errorf("unknown decode stage %q (known: %s)", name, strings.Join(DecodeStageNames(), ", "))
+		}
+		pipeline.names = append(pipeline.names, name)
+		pipeline.stages = append(pipeline.stages, stage)
+	}
+	return pipeline, nil
+}
+
+// NewDecodePipelines builds the pipelines of the decode_pipelines setting
+func NewDecodePipelines(specs [][]string) ([]DecodePipeline, error) {
+	pipelines := make([]DecodePipeline, 0, len(specs))
+	for i, names := range specs {
+		pipeline, err := NewDecodePipeline(names)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %d: %w", i+1, err)
+		}
+		pipelines = append(pipelines, pipeline)
+	}
+	return pipelines, nil
+}
+
+// DefaultDecodePipelines are tried when no pipelines are configured: raw
+// brotli, then base64 and brotli, then plain text
+func DefaultDecodePipelines() []DecodePipeline {
+	return []DecodePipeline{
+		{names: []string{"brotli"}, stages: []DecodeStage{decodeBrotli}},
+		{names: []string{"base64", "brotli"}, stages: []DecodeStage{decodeBase64, decodeBrotli}},
+		{},
+	}
+}
+
+// Decode runs the stages in order and stops at the first that fails. No stage
+// may produce more than limit bytes.
+func (p DecodePipeline) Decode(input []byte, limit int64) ([]byte, error) {
+	data := input
+	for i, stage := range p.stages {
+		decoded, err := stage(data, limit)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.names[i], err)
+		}
+		data = decoded
+	}
+	return data, nil
+}
+
+// String names the pipeline for logs, e.g. "base64+brotli"
+func (p DecodePipeline) String() string {
+	if len(p.names) == 0 {
+		return "plain"
+	}
+	return strings.Join(p.names, "+")
+}
+
+func decodeBase64(input []byte, limit int64) ([]byte, error) {
+	size := base64.StdEncoding.DecodedLen(len(input))
+	if int64(size) > limit {
+		return nil, fmt.Errorf("base64 decode of %d bytes: %w", size, ErrPayloadTooLarge)
+	}
+	decoded := make([]byte, size)
+	n, err := base64.StdEncoding.Decode(decoded, bytes.TrimSpace(input))
+	if err != nil {
+		return nil, fmt.Errorf("base64 decode error: %w", err)
+	}
+	return decoded[:n], nil
+}
+
+func decodeBrotli(input []byte, limit int64) ([]byte, error) {
+	decompressed, err := readLimited(brotli.NewReader(bytes.NewReader(input)), limit)
+	if err != nil {
+		return nil, fmt.Errorf("brotli decompression error: %w", err)
+	}
+	return decompressed, nil
+}
+
+func decodeGzip(input []byte, limit int64) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(input))
+	if err != nil {
+		return nil, fmt.Errorf("gzip decompression error: %w", err)
+	}
+	defer reader.Close()
+	decompressed, err := readLimited(reader, limit)
+	if err != nil {
+		return nil, fmt.Errorf("gzip decompression error: %w", err)
+	}
+	return decompressed, nil
+}
+
+// readLimited reads a decompressor to the end, stopping one byte past limit so
+// a decompression bomb never inflates further
+func readLimited(reader io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("more than %d bytes: %w", limit, ErrPayloadTooLarge)
+	}
+	return data, nil
+}
+
+// decodeJSONData unwraps the string "data" field of a JSON object
+func decodeJSONData(input []byte, limit int64) ([]byte, error) {
+	var wrapper struct {
+		Data *string `json:"data"`
+	}
+	if err := json.Unmarshal(input, &wrapper); err != nil {
+		return nil, fmt.Errorf("json decode error: %w", err)
+	}
+	if wrapper.Data == nil {
+		return nil, fmt.Errorf("json object has no string data field")
+	}
+	return []byte(*wrapper.Data), nil
+}
@@ -0,0 +1,122 @@
+package signalr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+
+	"datafeed/pkg/market"
+)
+
+const testFrame = "GP~350.5~1200|BATBC~512~40"
+
+func compressBrotli(data string) string {
+	var compressed bytes.Buffer
+	writer := brotli.NewWriter(&compressed)
+	writer.Write([]byte(data))
+	writer.Close()
+	return compressed.String()
+}
+
+// processFrame runs data through a processor set up by configure and returns
+// the share prices it parsed
+func processFrame(configure func(*MessageProcessor), data interface{}) []market.SharePrice {
+	processor := NewMessageProcessor()
+	if configure != nil {
+		configure(processor)
+	}
+	var ticks []market.SharePrice
+	processor.OnSharePrice(func(price market.SharePrice) { ticks = append(ticks, price) })
+	processor.Process(Message{Method: "SharePriceUpdated", Data: data})
+	return ticks
+}
+
+// Every composed pipeline decodes its own fixture and only that
+func TestDecodePipelines(t *testing.T) {
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	wrapped, _ := json.Marshal(map[string]string{"data": testFrame})
+	gz.Write(wrapped)
+	gz.Close()
+
+	fixtures := map[string]string{
+		"base64":    base64.StdEncoding.EncodeToString([]byte(testFrame)),
+		"gzip+json": gzipped.String(),
+		"brotli":    compressBrotli(testFrame),
+	}
+	for _, tc := range []struct {
+		name      string
+		pipelines [][]string
+		// ticks expected per fixture checked
+		want map[string]int
+	}{
+		{name: "base64 only", pipelines: [][]string{{"base64"}},
+			want: map[string]int{"base64": 2, "gzip+json": 0, "brotli": 0}},
+		{name: "gzip then json", pipelines: [][]string{{"gzip", "json_data"}},
+			want: map[string]int{"base64": 0, "gzip+json": 2, "brotli": 0}},
+		// The plain text fallback does not check gzip streams, whose short
+		// payloads are stored almost verbatim
+		{name: "default pipelines", pipelines: nil,
+			want: map[string]int{"base64": 0, "brotli": 2}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pipelines, err := NewDecodePipelines(tc.pipelines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for fixture, want := range tc.want {
+				ticks := processFrame(func(p *MessageProcessor) { p.SetDecodePipelines(pipelines) }, fixtures[fixture])
+				if len(ticks) != want {
+					t.Errorf("%s fixture decoded into %d ticks, want %d", fixture, len(ticks), want)
+					continue
+				}
+				if len(ticks) > 0 && (ticks[0].Symbol != "GP" || ticks[0].Price != 350.5) {
+					t.Errorf("%s fixture decoded into %+v", fixture, ticks[0])
+				}
+			}
+		})
+	}
+}
+
+func TestNewDecodePipeline(t *testing.T) {
+	pipeline, err := NewDecodePipeline([]string{" Base64 ", "brotli"})
+	if err != nil || pipeline.String() != "base64+brotli" {
+		t.Errorf("got %s (%v), want base64+brotli", pipeline, err)
+	}
+	if _, err := NewDecodePipeline([]string{"base64", "lz4"}); err == nil {
+		t.Error("unknown stage lz4 was accepted")
+	}
+	if _, err := NewDecodePipelines([][]string{{"gzip"}, {"zstd"}}); err == nil || !strings.Contains(err.Error(), "pipeline 2") {
+		t.Errorf("got %v, want the second pipeline rejected", err)
+	}
+}
+
+// A decompression bomb is dropped mid-decompression, normal frames still
+// decode, and every stage enforces the limit on its own output
+func TestMaxDecompressedSize(t *testing.T) {
+	processor := NewMessageProcessor()
+	processor.SetMaxDecompressedSize(1 << 20)
+	var ticks []market.SharePrice
+	processor.OnSharePrice(func(price market.SharePrice) { ticks = append(ticks, price) })
+
+	bomb := compressBrotli(strings.Repeat("GP~350.5~1200|", 1<<20)) // 14 MB inflated
+	processor.Process(Message{Method: "SharePriceUpdated", Data: bomb})
+	if len(ticks) != 0 || processor.OversizedDropped() != 1 {
+		t.Errorf("bomb produced %d ticks and %d oversized drops, want 0 and 1", len(ticks), processor.OversizedDropped())
+	}
+	processor.Process(Message{Method: "SharePriceUpdated", Data: compressBrotli(testFrame)})
+	if len(ticks) != 2 || processor.OversizedDropped() != 1 {
+		t.Errorf("normal frame produced %d ticks and %d oversized drops, want 2 and 1", len(ticks), processor.OversizedDropped())
+	}
+
+	pipeline, _ := NewDecodePipeline([]string{"base64"})
+	if _, err := pipeline.Decode([]byte(strings.Repeat("QUJD", 64)), 16); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("base64 stage past the limit returned %v, want ErrPayloadTooLarge", err)
+	}
+}
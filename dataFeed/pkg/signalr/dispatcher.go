@@ -0,0 +1,85 @@
+package signalr
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Dispatcher feeds the client's messages to a processing function on one
+// goroutine and stops on request without losing buffered messages
+type Dispatcher struct {
+	messages <-chan Message
+	process  func(Message)
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	processed uint64
+}
+
+// NewDispatcher creates a dispatcher calling process for every message
+func NewDispatcher(messages <-chan Message, process func(Message)) *Dispatcher {
+	return &Dispatcher{
+		messages: messages,
+		process:  process,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run processes messages until the channel is closed or Stop is called. Once
+// stopped it first drains the messages already buffered.
+func (d *Dispatcher) Run() {
+	defer close(d.done)
+	for {
+		select {
+		case msg, ok := <-d.messages:
+			if !ok {
+				return
+			}
+			d.handle(msg)
+		case <-d.stop:
+			d.drain()
+			return
+		}
+	}
+}
+
+// drain processes whatever is buffered without waiting for more
+func (d *Dispatcher) drain() {
+	for {
+		select {
+		case msg, ok := <-d.messages:
+			if !ok {
+				return
+			}
+			d.handle(msg)
+		default:
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) handle(msg Message) {
+	d.process(msg)
+	atomic.AddUint64(&d.processed, 1)
+}
+
+// Stop asks Run to drain and return, and waits until it has or ctx is done.
+// Stop the producer first (see Client.StopReceiving) so the drain is complete.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.stopOnce.Do(func() { close(d.stop) })
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Processed returns the number of messages processed so far
+func (d *Dispatcher) Processed() uint64 {
+	return atomic.LoadUint64(&d.processed)
+}
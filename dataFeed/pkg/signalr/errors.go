@@ -0,0 +1,62 @@
+package signalr
+
+import (
+	"fmt"
+	"time"
+)
+
+// ClientErrorKind names the operation a ClientError comes from
+type ClientErrorKind string
+
+const (
+	ClientErrorSubscribe ClientErrorKind = "subscribe"
+	ClientErrorHeartbeat ClientErrorKind = "heartbeat"
+	ClientErrorInvoke    ClientErrorKind = "invoke"
+	ClientErrorReconnect ClientErrorKind = "reconnect"
+)
+
+// clientErrorBuffer is how many errors Errors holds for a slow consumer
+// before further errors are dropped
+const clientErrorBuffer = 64
+
+// ClientError is a failure of a background client operation
+type ClientError struct {
+	Kind ClientErrorKind
+	// Method is the hub method invoked, empty for reconnects
+	Method string
+	// Attempt numbers retries of the same operation, starting at 1
+	Attempt int
+	Err     error
+	Time    time.Time
+}
+
+func (e ClientError) Error() string {
+	if e.Method == "" {
+		return fmt.Sprintf("%s attempt %d: %v", e.Kind, e.Attempt, e.Err)
+	}
+	return fmt.Sprintf("%s %s attempt %d: %v", e.Kind, e.Method, e.Attempt, e.Err)
+}
+
+func (e ClientError) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns the channel background failures are reported on. It is never
+// closed; errors that find it full are dropped and counted by ErrorsDropped.
+func (c *Client) Errors() <-chan ClientError {
+	return c.errors
+}
+
+// ErrorsDropped returns the number of errors dropped because Errors was full
+func (c *Client) ErrorsDropped() int64 {
+	return c.errorsDropped.Load()
+}
+
+// reportError publishes a background failure without blocking the operation
+func (c *Client) reportError(kind ClientErrorKind, method string, attempt int, err error) {
+	select {
+	case c.errors <- ClientError{Kind: kind, Method: method, Attempt: attempt, Err: err, Time: c.clock.Now()}:
+	default:
+		c.errorsDropped.Add(1)
+	}
+}
@@ -0,0 +1,97 @@
+package signalr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Failed subscriptions, pings and reconnects are reported on Errors, dropped
+// once nobody reads them, and escalated by SubscriptionHealth until data arrives
+func TestClientErrors(t *testing.T) {
+	const escalateAfter = 10 * time.Minute
+	clock := newFakeClock()
+	var connects atomic.Int32
+
+	clientCfg := DefaultClientConfig()
+	clientCfg.ReconnectJitter = 0
+	clientCfg.MaxReconnectAttempts = 2
+	clientCfg.Clock = clock
+	clientCfg.Connector = func(ctx context.Context, hubURL, token string, format TransferFormat, receiver interface{}) (HubClient, error) {
+		if connects.Add(1) > 1 {
+			return nil, errors.New("test: hub unreachable")
+		}
+		return rejectingHub{}, nil
+	}
+	client := newTestClient(t, clientCfg)
+
+	health := NewSubscriptionHealth()
+	var reported []string
+	expect := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			select {
+			case clientErr := <-client.Errors():
+				health.Record(clientErr)
+				reported = append(reported, fmt.Sprintf("%s %s %d", clientErr.Kind, clientErr.Method, clientErr.Attempt))
+			case <-time.After(2 * time.Second):
+				t.Fatalf("expected %d more errors after %q", n-i, reported)
+			}
+		}
+	}
+
+	// The default subscription retries three times
+	if err := client.Connect(); err != nil {
+		t.Fatalf("initial connect: %v", err)
+	}
+	expect(3)
+	if err := client.Subscribe("SubscribeToNewsEvent", "DSE"); err != nil {
+		t.Fatal(err)
+	}
+	expect(1)
+	if err := client.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	expect(1)
+	// Two failed reconnects, then giving up
+	client.handleDisconnected(errors.New("test: simulated drop"))
+	expect(3)
+
+	want := []string{
+		"subscribe SubscribeToMarketStatusUpdatedEvent 1",
+		"subscribe SubscribeToMarketStatusUpdatedEvent 2",
+		"subscribe SubscribeToMarketStatusUpdatedEvent 3",
+		"subscribe SubscribeToNewsEvent 1",
+		"invoke ping 1",
+		"reconnect  1",
+		"reconnect  2",
+		"reconnect  2",
+	}
+	if !reflect.DeepEqual(reported, want) {
+		t.Errorf("errors %q, want %q", reported, want)
+	}
+
+	// Nobody reads from here on
+	for i := 0; i < clientErrorBuffer+5; i++ {
+		client.reportError(ClientErrorInvoke, "flood", i+1, errors.New("test: flood"))
+	}
+	if dropped := client.ErrorsDropped(); dropped != 5 {
+		t.Errorf("%d errors dropped, want 5", dropped)
+	}
+
+	failing := health.Failing(clock.Now().Add(escalateAfter), time.Time{}, escalateAfter)
+	var escalated []string
+	for _, failure := range failing {
+		escalated = append(escalated, failure.Method)
+	}
+	if !reflect.DeepEqual(escalated, []string{"SubscribeToMarketStatusUpdatedEvent", "SubscribeToNewsEvent"}) || failing[0].Failures != 3 {
+		t.Errorf("escalated %+v, want both subscriptions, the default one with 3 failures", failing)
+	}
+	if recovered := health.Failing(clock.Now().Add(escalateAfter), clock.Now().Add(time.Second), escalateAfter); len(recovered) != 0 {
+		t.Errorf("subscriptions still failing after data arrived: %+v", recovered)
+	}
+}
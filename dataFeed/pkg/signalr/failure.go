@@ -0,0 +1,69 @@
+package signalr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hello-api/pkg/httpclient"
+)
+
+// FailureEvent is the event name of the notice posted when the feed fails
+const FailureEvent = "datafeed_failed"
+
+// FailureNotice tells an operator the client gave up reconnecting
+type FailureNotice struct {
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+	HubURL   string    `json:"hubUrl"`
+	Attempts int       `json:"attempts"`
+	// LastError is the error of the last reconnect attempt
+	LastError string `json:"lastError,omitempty"`
+}
+
+// NewFailureNotice describes a client that gave up after attempts, failing last with lastErr
+func NewFailureNotice(hubURL string, attempts int, lastErr error, at time.Time) FailureNotice {
+	notice := FailureNotice{Event: FailureEvent, Time: at.UTC(), HubURL: hubURL, Attempts: attempts}
+	if lastErr != nil {
+		notice.LastError = lastErr.Error()
+	}
+	return notice
+}
+
+// FailureWebhook posts failure notices as JSON to an operator webhook, such
+// as a paging service's incoming webhook
+type FailureWebhook struct {
+	url    string
+	client *httpclient.Client
+}
+
+// NewFailureWebhook creates a webhook posting to url with client, which
+// retries transient failures
+func NewFailureWebhook(url string, client *httpclient.Client) *FailureWebhook {
+	return &FailureWebhook{url: url, client: client}
+}
+
+// Notify posts the notice, failing on any non-2xx response
+func (w *FailureWebhook) Notify(ctx context.Context, notice FailureNotice) error {
+	body, err := json.Marshal(notice)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failure webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failure webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package signalr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hello-api/pkg/httpclient"
+)
+
+// Once its reconnects run out the client fails once, the operator is notified
+// through the failure webhook, and it stays down until an explicit Connect
+func TestFeedFailure(t *testing.T) {
+	const maxAttempts = 3
+	var (
+		mu              sync.Mutex
+		connects        int
+		failing         bool
+		webhookRequests int
+		notice          FailureNotice
+		failedCalls     int
+		failedAttempts  int
+		failedErr       error
+	)
+	noticed := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		webhookRequests++
+		if webhookRequests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&notice); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		close(noticed)
+	}))
+	defer server.Close()
+
+	webhookCfg := httpclient.DefaultConfig()
+	webhookCfg.InitialBackoff = time.Millisecond
+	webhook := NewFailureWebhook(server.URL, httpclient.New(webhookCfg))
+	webhookErr := make(chan error, 1)
+
+	clientCfg := DefaultClientConfig()
+	clientCfg.ReconnectDelay = time.Second
+	clientCfg.MaxReconnectDelay = time.Minute
+	clientCfg.ReconnectJitter = 0
+	clientCfg.MaxReconnectAttempts = maxAttempts
+	clientCfg.Clock = newFakeClock()
+	clientCfg.Connector = func(ctx context.Context, hubURL, token string, format TransferFormat, receiver interface{}) (HubClient, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		connects++
+		if failing {
+			return nil, fmt.Errorf("test: scripted failure %d", connects-1)
+		}
+		return acceptingHub{}, nil
+	}
+	client := newTestClient(t, clientCfg)
+	client.OnFailed(func(attempts int, lastErr error) {
+		mu.Lock()
+		failedCalls++
+		failedAttempts = attempts
+		failedErr = lastErr
+		mu.Unlock()
+		notice := NewFailureNotice("test://hub", attempts, lastErr, time.Now())
+		go func() { webhookErr <- webhook.Notify(context.Background(), notice) }()
+	})
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("initial connect: %v", err)
+	}
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	client.handleDisconnected(errors.New("test: simulated drop"))
+
+	select {
+	case err := <-webhookErr:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("failure webhook was not notified within 5s")
+	}
+	<-noticed
+	if status := client.Status(); status != ConnectionStatusFailed {
+		t.Errorf("status %v once the attempts ran out, want %v", status, ConnectionStatusFailed)
+	}
+
+	// A failed client stays down until it is told to connect
+	mu.Lock()
+	before := connects
+	failing = false
+	mu.Unlock()
+	client.handleDisconnected(errors.New("test: drop after failing"))
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	if connects != before {
+		t.Errorf("failed client reconnected %d times on its own", connects-before)
+	}
+	mu.Unlock()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("explicit connect: %v", err)
+	}
+	if status := client.Status(); status != ConnectionStatusConnected {
+		t.Errorf("status %v after an explicit Connect, want connected", status)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if failedCalls != 1 || failedAttempts != maxAttempts || failedErr == nil {
+		t.Errorf("OnFailed called %d times with %d attempts and %v, want once with %d and the last error", failedCalls, failedAttempts, failedErr, maxAttempts)
+	}
+	if webhookRequests != 2 {
+		t.Errorf("%d webhook posts, want the notice retried once after a 503", webhookRequests)
+	}
+	if notice.Event != FailureEvent || notice.Attempts != maxAttempts || notice.LastError == "" {
+		t.Errorf("notice %+v", notice)
+	}
+}
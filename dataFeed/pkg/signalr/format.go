@@ -0,0 +1,77 @@
+package signalr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/philippseith/signalr"
+)
+
+// TransferFormat is how hub messages are encoded on the wire: JSON text or
+// MessagePack binary. It is chosen in the handshake of every connection.
+type TransferFormat string
+
+const (
+	// TransferFormatText encodes hub messages as JSON
+	TransferFormatText TransferFormat = "text"
+	// TransferFormatBinary encodes hub messages as MessagePack, so payloads
+	// may arrive as raw bytes rather than base64 text
+	TransferFormatBinary TransferFormat = "binary"
+)
+
+// DefaultTransferFormat is the format of the current server
+const DefaultTransferFormat = TransferFormatText
+
+// transferFormatAliases maps the names transfer_format accepts to a format
+var transferFormatAliases = map[string]TransferFormat{
+	"text":        TransferFormatText,
+	"json":        TransferFormatText,
+	"binary":      TransferFormatBinary,
+	"messagepack": TransferFormatBinary,
+	"msgpack":     TransferFormatBinary,
+}
+
+// TransferFormats lists the names a transfer format may be parsed from
+func TransferFormats() []string {
+	return []string{"text", "json", "binary", "messagepack", "msgpack"}
+}
+
+// ParseTransferFormat returns the format named by the transfer_format
+// setting; an empty name selects DefaultTransferFormat
+func ParseTransferFormat(name string) (TransferFormat, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return DefaultTransferFormat, nil
+	}
+	format, ok := transferFormatAliases[name]
+	if !ok {
+		return "", fmt.Errorf("unknown transfer format %q (known: %s)", name, strings.Join(TransferFormats(), ", "))
+	}
+	return format, nil
+}
+
+// Binary reports whether hub messages are MessagePack encoded
+func (f TransferFormat) Binary() bool {
+	return f == TransferFormatBinary
+}
+
+func (f TransferFormat) String() string {
+	if f == "" {
+		return string(DefaultTransferFormat)
+	}
+	return string(f)
+}
+
+// hubFormat is the library's name for the format, as given to
+// signalr.TransferFormat when the hub client is built
+func (f TransferFormat) hubFormat() signalr.TransferFormatType {
+	if f.Binary() {
+		return signalr.TransferFormatBinary
+	}
+	return signalr.TransferFormatText
+}
+
+// TransferFormat returns the hub message encoding the client negotiates
+func (c *Client) TransferFormat() TransferFormat {
+	return c.transferFormat
+}
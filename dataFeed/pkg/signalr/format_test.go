@@ -0,0 +1,115 @@
+package signalr
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philippseith/signalr"
+
+	"datafeed/pkg/market"
+)
+
+// recordHub sends every client a share price record as raw bytes once it connects
+type recordHub struct {
+	signalr.Hub
+	record []byte
+}
+
+func (h *recordHub) OnConnected(string) {
+	h.Clients().Caller().Send("SharePriceUpdated", h.record)
+}
+
+// quietLogger discards the hub server's logs
+type quietLogger struct{}
+
+func (quietLogger) Log(...interface{}) error { return nil }
+
+// The configured transfer format is negotiated with a real hub through the
+// default connector; raw bytes arrive intact only under MessagePack, as base64
+// text under JSON
+func TestTransferFormat(t *testing.T) {
+	const record = "GP~351~100"
+	for _, tc := range []struct {
+		format  TransferFormat
+		payload string
+		prices  int
+		symbol  string
+	}{
+		{TransferFormatText, base64.StdEncoding.EncodeToString([]byte(record)), 0, strings.ToUpper(base64.StdEncoding.EncodeToString([]byte("BATBC")))},
+		{TransferFormatBinary, record, 1, "BATBC"},
+	} {
+		t.Run(tc.format.String(), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			server, err := signalr.NewServer(ctx,
+				signalr.HubFactory(func() signalr.HubInterface { return &recordHub{record: []byte(record)} }),
+				signalr.Logger(quietLogger{}, false),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mux := http.NewServeMux()
+			server.MapHTTP(signalr.WithHTTPServeMux(mux), "/hub")
+			httpServer := httptest.NewServer(mux)
+			defer httpServer.Close()
+
+			var negotiated TransferFormat
+			clientCfg := DefaultClientConfig()
+			clientCfg.TransferFormat = tc.format
+			clientCfg.ConnectionTimeout = 5 * time.Second
+			clientCfg.Connector = func(ctx context.Context, hubURL, token string, format TransferFormat, receiver interface{}) (HubClient, error) {
+				negotiated = format
+				return newHTTPHubClient(ctx, hubURL, token, format, receiver)
+			}
+			client := newTestClientAt(t, httpServer.URL+"/hub", clientCfg)
+			if err := client.Connect(); err != nil {
+				t.Fatalf("connect: %v", err)
+			}
+			if negotiated != tc.format {
+				t.Errorf("connector asked for %s", negotiated)
+			}
+
+			var msg Message
+			select {
+			case msg = <-client.Messages():
+			case <-time.After(5 * time.Second):
+				t.Fatal("no share price received within 5s")
+			}
+			if payload, _ := msg.Data.(string); payload != tc.payload {
+				t.Errorf("payload %q, want %q", payload, tc.payload)
+			}
+
+			prices, symbol := 0, ""
+			processor := NewMessageProcessor()
+			processor.logger = client.logger
+			processor.SetTransferFormat(client.TransferFormat())
+			processor.OnSharePrice(func(market.SharePrice) { prices++ })
+			processor.OnMarketStatus(func(status market.MarketStatus) { symbol = status.Symbol })
+			processor.Process(msg)
+			// A market status shaped as MessagePack decodes it
+			processor.Process(Message{
+				Method: "MarketStatusUpdated^^DSE~",
+				Data:   []interface{}{map[interface{}]interface{}{"symbol": []byte("BATBC"), "status": "Halted"}},
+			})
+			if prices != tc.prices || symbol != tc.symbol {
+				t.Errorf("%d ticks and market status symbol %q, want %d and %q", prices, symbol, tc.prices, tc.symbol)
+			}
+		})
+	}
+}
+
+func TestParseTransferFormat(t *testing.T) {
+	for name, want := range map[string]TransferFormat{"": TransferFormatText, "JSON": TransferFormatText, "messagepack": TransferFormatBinary} {
+		if got, err := ParseTransferFormat(name); err != nil || got != want {
+			t.Errorf("transfer_format %q selected %s (%v), want %s", name, got, err, want)
+		}
+	}
+	if _, err := ParseTransferFormat("protobuf"); err == nil {
+		t.Error("unknown transfer format protobuf was accepted")
+	}
+}
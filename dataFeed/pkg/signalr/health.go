@@ -0,0 +1,67 @@
+package signalr
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SubscriptionFailure describes a subscription that keeps failing
+type SubscriptionFailure struct {
+	Method   string
+	Since    time.Time
+	Last     time.Time
+	Failures int
+	LastErr  error
+}
+
+// SubscriptionHealth tracks subscriptions that keep failing, from the errors
+// a Client reports on Errors, until data flows again
+type SubscriptionHealth struct {
+	mu      sync.Mutex
+	failing map[string]*SubscriptionFailure
+}
+
+// NewSubscriptionHealth creates an empty subscription health tracker
+func NewSubscriptionHealth() *SubscriptionHealth {
+	return &SubscriptionHealth{failing: make(map[string]*SubscriptionFailure)}
+}
+
+// Record notes a subscription failure; errors of other kinds are ignored
+func (h *SubscriptionHealth) Record(err ClientError) {
+	if err.Kind != ClientErrorSubscribe {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	failure, ok := h.failing[err.Method]
+	if !ok {
+		failure = &SubscriptionFailure{Method: err.Method, Since: err.Time}
+		h.failing[err.Method] = failure
+	}
+	failure.Failures++
+	failure.Last = err.Time
+	failure.LastErr = err.Err
+}
+
+// Failing returns the subscriptions that have been failing for at least after,
+// by method. Subscriptions whose last failure came before lastMessageAt are
+// forgotten: data arriving since means they recovered.
+func (h *SubscriptionHealth) Failing(now, lastMessageAt time.Time, after time.Duration) []SubscriptionFailure {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var failing []SubscriptionFailure
+	for method, failure := range h.failing {
+		if lastMessageAt.After(failure.Last) {
+			delete(h.failing, method)
+			continue
+		}
+		if now.Sub(failure.Since) >= after {
+			failing = append(failing, *failure)
+		}
+	}
+	sort.Slice(failing, func(i, j int) bool { return failing[i].Method < failing[j].Method })
+	return failing
+}
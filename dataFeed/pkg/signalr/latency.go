@@ -0,0 +1,206 @@
+package signalr
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the round-trip histogram buckets; a
+// last bucket counts everything slower
+var latencyBounds = [...]time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+const (
+	// LatencyWindow is how far back the round-trip percentiles look
+	LatencyWindow = 15 * time.Minute
+	latencySlots  = 15
+	latencySlot   = LatencyWindow / latencySlots
+)
+
+// latencySlice counts the round trips of one slot of the window
+type latencySlice struct {
+	// epoch is the slot number since the Unix epoch the counts belong to
+	epoch  atomic.Int64
+	counts [len(latencyBounds) + 1]atomic.Int64
+}
+
+// LatencyHistogram counts round-trip durations in fixed buckets over a sliding
+// window of one-minute slots. Recording is a few atomic operations with no
+// lock; a sample recorded while its slot is being recycled may be lost, which
+// is fine for percentiles.
+type LatencyHistogram struct {
+	slots [latencySlots]latencySlice
+}
+
+// LatencySummary is the round-trip percentiles over the window. Percentiles
+// are bucket upper bounds; samples slower than the last bound report it.
+type LatencySummary struct {
+	Count int64
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+// Record counts a round trip observed at now
+func (h *LatencyHistogram) Record(d time.Duration, now time.Time) {
+	epoch := now.UnixNano() / int64(latencySlot)
+	slot := &h.slots[epoch%latencySlots]
+	if old := slot.epoch.Load(); old != epoch && slot.epoch.CompareAndSwap(old, epoch) {
+		for i := range slot.counts {
+			slot.counts[i].Store(0)
+		}
+	}
+	slot.counts[latencyBucket(d)].Add(1)
+}
+
+// Summary returns the percentiles of the round trips within the window ending at now
+func (h *LatencyHistogram) Summary(now time.Time) LatencySummary {
+	current := now.UnixNano() / int64(latencySlot)
+	var counts [len(latencyBounds) + 1]int64
+	var total int64
+	for i := range h.slots {
+		slot := &h.slots[i]
+		if epoch := slot.epoch.Load(); epoch <= current-latencySlots || epoch > current {
+			continue
+		}
+		for b := range slot.counts {
+			n := slot.counts[b].Load()
+			counts[b] += n
+			total += n
+		}
+	}
+	if total == 0 {
+		return LatencySummary{}
+	}
+	return LatencySummary{
+		Count: total,
+		P50:   latencyPercentile(counts[:], total, 0.50),
+		P95:   latencyPercentile(counts[:], total, 0.95),
+		P99:   latencyPercentile(counts[:], total, 0.99),
+	}
+}
+
+// Reset forgets every recorded round trip
+func (h *LatencyHistogram) Reset() {
+	for i := range h.slots {
+		h.slots[i].epoch.Store(math.MinInt64)
+		for b := range h.slots[i].counts {
+			h.slots[i].counts[b].Store(0)
+		}
+	}
+}
+
+func latencyBucket(d time.Duration) int {
+	for i, bound := range latencyBounds {
+		if d <= bound {
+			return i
+		}
+	}
+	return len(latencyBounds)
+}
+
+func latencyPercentile(counts []int64, total int64, q float64) time.Duration {
+	rank := int64(math.Ceil(q * float64(total)))
+	var seen int64
+	for i, n := range counts {
+		seen += n
+		if seen >= rank {
+			if i == len(latencyBounds) {
+				break
+			}
+			return latencyBounds[i]
+		}
+	}
+	return latencyBounds[len(latencyBounds)-1]
+}
+
+// skewSmoothing weighs a new clock skew sample against the running estimate
+const skewSmoothing = 0.2
+
+// ClockSkew returns how far the server's clock is ahead of ours, and false
+// while no ping of the current connection has carried a server timestamp.
+// Adding it to a local time estimates the exchange's time.
+func (c *Client) ClockSkew() (time.Duration, bool) {
+	if !c.skewKnown.Load() {
+		return 0, false
+	}
+	return time.Duration(c.skewNanos.Load()), true
+}
+
+// HeartbeatLatency returns the heartbeat round-trip percentiles of the current connection
+func (c *Client) HeartbeatLatency() LatencySummary {
+	return c.latency.Summary(time.Now())
+}
+
+// recordRoundTrip counts a successful heartbeat round trip
+func (c *Client) recordRoundTrip(d time.Duration) {
+	c.latency.Record(d, time.Now())
+}
+
+// observeServerTime updates the clock skew estimate from a server timestamp
+// received at receivedAt. The server stamped it about half a round trip
+// earlier, taken from the heartbeat median.
+func (c *Client) observeServerTime(serverTime, receivedAt time.Time) {
+	oneWay := c.HeartbeatLatency().P50 / 2
+	sample := serverTime.Add(oneWay).Sub(receivedAt)
+	if !c.skewKnown.Load() {
+		c.skewNanos.Store(int64(sample))
+		c.skewKnown.Store(true)
+		return
+	}
+	current := time.Duration(c.skewNanos.Load())
+	c.skewNanos.Store(int64(current + time.Duration(skewSmoothing*float64(sample-current))))
+}
+
+// resetLatency forgets the round trips and clock skew of the previous connection
+func (c *Client) resetLatency() {
+	c.latency.Reset()
+	c.skewKnown.Store(false)
+	c.skewNanos.Store(0)
+}
+
+// parseServerTime reads a server timestamp from a ping argument: Unix seconds
+// or milliseconds, as a number or a string, or an RFC 3339 string
+func parseServerTime(arg interface{}) (time.Time, bool) {
+	var value float64
+	switch v := arg.(type) {
+	case float64:
+		value = v
+	case int64:
+		value = float64(v)
+	case string:
+		v = strings.TrimSpace(v)
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		value = parsed
+	default:
+		return time.Time{}, false
+	}
+	switch {
+	case value <= 0:
+		return time.Time{}, false
+	case value >= 1e12:
+		return time.UnixMilli(int64(value)), true
+	}
+	sec, frac := math.Modf(value)
+	return time.Unix(int64(sec), int64(frac*1e9)), true
+}
@@ -0,0 +1,103 @@
+package signalr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"datafeed/pkg/market"
+)
+
+// Heartbeat round trips feed the latency percentiles, timestamped pings the
+// clock skew that corrects tick times, and a reconnect starts both afresh
+func TestHeartbeatLatency(t *testing.T) {
+	const serverAhead = 2 * time.Second
+	reconnected := make(chan struct{}, 1)
+	clientCfg := DefaultClientConfig()
+	clientCfg.ReconnectJitter = 0
+	clientCfg.Clock = newFakeClock()
+	clientCfg.Connector = func(ctx context.Context, hubURL, token string, format TransferFormat, receiver interface{}) (HubClient, error) {
+		return acceptingHub{}, nil
+	}
+	clientCfg.Hooks = ClientHooks{
+		OnReconnectAttempt: func(attempt int, delay time.Duration) {
+			select {
+			case reconnected <- struct{}{}:
+			default:
+			}
+		},
+	}
+	client := newTestClient(t, clientCfg)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("initial connect: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		switch {
+		case i < 90:
+			client.recordRoundTrip(20 * time.Millisecond)
+		case i < 96:
+			client.recordRoundTrip(200 * time.Millisecond)
+		default:
+			client.recordRoundTrip(3 * time.Second)
+		}
+	}
+	want := LatencySummary{Count: 100, P50: 25 * time.Millisecond, P95: 250 * time.Millisecond, P99: 5 * time.Second}
+	if got := client.HeartbeatLatency(); got != want {
+		t.Errorf("round trips %+v, want %+v", got, want)
+	}
+	if p95, _ := client.GetConnectionStats()["heartbeatP95"].(time.Duration); p95 != want.P95 {
+		t.Errorf("connection stats report p95 %v, want %v", p95, want.P95)
+	}
+
+	// One ping stamped in Unix milliseconds, one in RFC 3339
+	client.receiver.Receive("Ping", float64(time.Now().Add(serverAhead).UnixMilli()))
+	client.receiver.Receive("Ping", time.Now().Add(serverAhead).Format(time.RFC3339Nano))
+	skew, ok := client.ClockSkew()
+	if !ok || skew.Round(100*time.Millisecond) != serverAhead {
+		t.Errorf("clock skew %v (known %v), want %v", skew, ok, serverAhead)
+	}
+
+	processor := NewMessageProcessor()
+	processor.logger = client.logger
+	processor.SetClockSkewSource(client.ClockSkew)
+	var tick market.SharePrice
+	processor.OnSharePrice(func(price market.SharePrice) { tick = price })
+	processor.processDecompressedData("GP~100~5")
+	if ahead := time.Until(tick.Time).Round(100 * time.Millisecond); ahead != serverAhead {
+		t.Errorf("tick time %v ahead of the local clock, want %v", ahead, serverAhead)
+	}
+
+	client.handleDisconnected(errors.New("test: simulated drop"))
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reconnect attempt within 5s")
+	}
+	waitForStatus(t, client, ConnectionStatusConnected)
+	if got := client.HeartbeatLatency(); got != (LatencySummary{}) {
+		t.Errorf("reconnect kept round trips %+v", got)
+	}
+	if _, ok := client.ClockSkew(); ok {
+		t.Error("reconnect kept the clock skew")
+	}
+}
+
+func TestLatencyHistogramWindow(t *testing.T) {
+	var h LatencyHistogram
+	start := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	h.Record(40*time.Millisecond, start)
+	h.Record(40*time.Millisecond, start.Add(time.Minute))
+	if got := h.Summary(start.Add(time.Minute)); got.Count != 2 || got.P50 != 50*time.Millisecond {
+		t.Errorf("summary %+v, want 2 round trips at p50 50ms", got)
+	}
+	// Past the window the oldest slot no longer counts
+	if got := h.Summary(start.Add(LatencyWindow)); got.Count != 1 {
+		t.Errorf("%d round trips a window later, want 1", got.Count)
+	}
+	h.Reset()
+	if got := h.Summary(start.Add(time.Minute)); got != (LatencySummary{}) {
+		t.Errorf("summary %+v after Reset", got)
+	}
+}
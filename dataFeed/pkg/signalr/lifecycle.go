@@ -0,0 +1,292 @@
+package signalr
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"datafeed/pkg/logging"
+)
+
+// LifecycleEventKind names a connection lifecycle transition
+type LifecycleEventKind string
+
+const (
+	LifecycleConnect          LifecycleEventKind = "connect"
+	LifecycleConnectFailed    LifecycleEventKind = "connect_failed"
+	LifecycleDisconnect       LifecycleEventKind = "disconnect"
+	LifecycleReconnectAttempt LifecycleEventKind = "reconnect_attempt"
+	LifecycleGiveUp           LifecycleEventKind = "give_up"
+)
+
+// lifecycleBuffer is how many events wait for the store before further
+// events are dropped
+const lifecycleBuffer = 256
+
+// DefaultHistorySize is how many lifecycle events Client.History keeps when
+// the configuration leaves it unset
+const DefaultHistorySize = 100
+
+// LifecycleEvent is one connection lifecycle transition
+type LifecycleEvent struct {
+	Time time.Time          `json:"time"`
+	Kind LifecycleEventKind `json:"kind"`
+	// Status is the connection status the event left the client in
+	Status string `json:"status,omitempty"`
+	// Reason is the disconnect, connect or give-up error
+	Reason string `json:"reason,omitempty"`
+	// Attempt numbers reconnect attempts since the last connection, starting at 1
+	Attempt int `json:"attempt,omitempty"`
+	// BackoffMs is the delay before a reconnect attempt
+	BackoffMs int64 `json:"backoffMs,omitempty"`
+}
+
+// LifecycleStore persists lifecycle events
+type LifecycleStore interface {
+	Append(events ...LifecycleEvent) error
+	// Query returns the events from from up to to, oldest first; a zero
+	// bound leaves that side open
+	Query(from, to time.Time) ([]LifecycleEvent, error)
+}
+
+// FileLifecycleStore appends events to a file as JSON lines, which survive
+// restarts and can be read with the lifecycle command or any JSON tool
+type FileLifecycleStore struct {
+	path string
+	// maxSize, when positive, rotates the file before it grows past this many
+	// bytes, keeping maxBackups rotated files
+	maxSize    int64
+	maxBackups int
+	mu         sync.Mutex
+}
+
+// NewFileLifecycleStore creates a store backed by the file at path
+func NewFileLifecycleStore(path string) *FileLifecycleStore {
+	return &FileLifecycleStore{path: path}
+}
+
+// NewRotatingFileLifecycleStore creates a store backed by the file at path
+// that is rotated to path.1, path.2 and so on before it grows past maxSize
+// bytes; backups beyond maxBackups are removed
+func NewRotatingFileLifecycleStore(path string, maxSize int64, maxBackups int) *FileLifecycleStore {
+	return &FileLifecycleStore{path: path, maxSize: maxSize, maxBackups: maxBackups}
+}
+
+// Append writes the events at the end of the file
+func (s *FileLifecycleStore) Append(events ...LifecycleEvent) error {
+	var data []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxSize > 0 {
+		if info, err := os.Stat(s.path); err == nil && info.Size() > 0 && info.Size()+int64(len(data)) > s.maxSize {
+			if err := logging.RotateFile(s.path, s.maxBackups); err != nil {
+				return fmt.Errorf("failed to rotate lifecycle log: %w", err)
+			}
+		}
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open lifecycle log: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write lifecycle log: %w", err)
+	}
+	return f.Close()
+}
+
+// Query reads the rotated backups, oldest first, then the file; lines that do
+// not parse, such as one cut short by a crash, are skipped
+func (s *FileLifecycleStore) Query(from, to time.Time) ([]LifecycleEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths := []string{s.path}
+	for i := 1; ; i++ {
+		backup := logging.BackupPath(s.path, i)
+		if _, err := os.Stat(backup); err != nil {
+			break
+		}
+		paths = append([]string{backup}, paths...)
+	}
+
+	var events []LifecycleEvent
+	for _, path := range paths {
+		read, err := queryLifecycleFile(path, from, to)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, read...)
+	}
+	return events, nil
+}
+
+// queryLifecycleFile reads the events between from and to from one file
+func queryLifecycleFile(path string, from, to time.Time) ([]LifecycleEvent, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lifecycle log: %w", err)
+	}
+	defer f.Close()
+
+	var events []LifecycleEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event LifecycleEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if (!from.IsZero() && event.Time.Before(from)) || (!to.IsZero() && event.Time.After(to)) {
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lifecycle log: %w", err)
+	}
+	return events, nil
+}
+
+// LifecycleRecorder hands the connection lifecycle events of a Client to a
+// store. Events are written in the background, so a slow disk never holds up
+// a reconnect; events that find the buffer full are dropped and counted.
+type LifecycleRecorder struct {
+	store   LifecycleStore
+	dropped atomic.Int64
+	done    chan struct{}
+
+	// mu guards sending on events against Close closing it
+	mu     sync.RWMutex
+	events chan LifecycleEvent
+	closed bool
+}
+
+// NewLifecycleRecorder starts a recorder writing to store; Close flushes it
+func NewLifecycleRecorder(store LifecycleStore) *LifecycleRecorder {
+	r := &LifecycleRecorder{
+		store:  store,
+		events: make(chan LifecycleEvent, lifecycleBuffer),
+		done:   make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (r *LifecycleRecorder) run() {
+	defer close(r.done)
+	for event := range r.events {
+		if err := r.store.Append(event); err != nil {
+			log.Printf("⚠️ Failed to record %s lifecycle event: %v", event.Kind, err)
+		}
+	}
+}
+
+// Record queues an event without blocking. It is safe on a nil recorder and
+// after Close, when the event is dropped.
+func (r *LifecycleRecorder) Record(event LifecycleEvent) {
+	if r == nil {
+		return
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		r.dropped.Add(1)
+		return
+	}
+	select {
+	case r.events <- event:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of events dropped because the buffer was full or
+// the recorder closed
+func (r *LifecycleRecorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Close writes the queued events and stops the recorder
+func (r *LifecycleRecorder) Close() {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.events)
+	}
+	r.mu.Unlock()
+	<-r.done
+}
+
+// SetLifecycleRecorder records the client's connects, disconnects, reconnect
+// attempts and giving up. It must be called before Connect.
+func (c *Client) SetLifecycleRecorder(recorder *LifecycleRecorder) {
+	c.lifecycle = recorder
+}
+
+// recordLifecycle stamps an event with the client's clock, keeps it in the
+// history and records it
+func (c *Client) recordLifecycle(event LifecycleEvent) {
+	event.Time = c.clock.Now()
+	c.history.add(event)
+	if c.lifecycle == nil {
+		return
+	}
+	c.lifecycle.Record(event)
+}
+
+// History returns the most recent connection lifecycle events, oldest first.
+// It is kept in memory whether or not a lifecycle log is configured.
+func (c *Client) History() []LifecycleEvent {
+	return c.history.events()
+}
+
+// lifecycleHistory keeps the latest lifecycle events in a ring
+type lifecycleHistory struct {
+	mu   sync.Mutex
+	ring []LifecycleEvent
+	next int
+	full bool
+}
+
+func newLifecycleHistory(size int) *lifecycleHistory {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	return &lifecycleHistory{ring: make([]LifecycleEvent, size)}
+}
+
+func (h *lifecycleHistory) add(event LifecycleEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ring[h.next] = event
+	h.next = (h.next + 1) % len(h.ring)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+func (h *lifecycleHistory) events() []LifecycleEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]LifecycleEvent(nil), h.ring[:h.next]...)
+	}
+	return append(append([]LifecycleEvent(nil), h.ring[h.next:]...), h.ring[:h.next]...)
+}
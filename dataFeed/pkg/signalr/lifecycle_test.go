@@ -0,0 +1,219 @@
+package signalr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"datafeed/pkg/logging"
+)
+
+// notifyingStore signals every event it appends to the store it wraps
+type notifyingStore struct {
+	LifecycleStore
+	appended chan LifecycleEventKind
+}
+
+func (s *notifyingStore) Append(events ...LifecycleEvent) error {
+	err := s.LifecycleStore.Append(events...)
+	for _, event := range events {
+		s.appended <- event.Kind
+	}
+	return err
+}
+
+// describeLifecycleEvent formats an event as "kind", "disconnect <reason>",
+// "connect_failed <n> <reason>", "reconnect_attempt <n> <backoff>" or
+// "give_up <attempts>"
+func describeLifecycleEvent(event LifecycleEvent) string {
+	switch event.Kind {
+	case LifecycleDisconnect:
+		return fmt.Sprintf("%s %s", event.Kind, event.Reason)
+	case LifecycleConnectFailed:
+		return fmt.Sprintf("%s %d %s", event.Kind, event.Attempt, event.Reason)
+	case LifecycleReconnectAttempt:
+		return fmt.Sprintf("%s %d %v", event.Kind, event.Attempt, time.Duration(event.BackoffMs)*time.Millisecond)
+	case LifecycleGiveUp:
+		return fmt.Sprintf("%s %d", event.Kind, event.Attempt)
+	}
+	return string(event.Kind)
+}
+
+// A connect, a drop and the reconnect attempts that follow are persisted to
+// the lifecycle log and kept in the client's history, whether the client
+// reconnects or gives up, and a size-bounded log rotates without losing events
+func TestLifecycleEvents(t *testing.T) {
+	statuses := map[string]string{
+		"connect":           "connected",
+		"disconnect":        "disconnected",
+		"connect_failed":    "disconnected",
+		"reconnect_attempt": "reconnecting",
+		"give_up":           "failed",
+	}
+
+	for _, tc := range []struct {
+		name        string
+		failures    int
+		maxAttempts int
+		maxSize     int64
+		historySize int
+		want        []string
+		// history is how many of the latest events the client keeps
+		history int
+		rotated bool
+	}{
+		{
+			name: "reconnects", failures: 1, maxAttempts: 5,
+			want: []string{
+				"connect",
+				"disconnect test: simulated drop",
+				"reconnect_attempt 1 2s",
+				"connect_failed 1 test: scripted failure 1",
+				"reconnect_attempt 2 3s",
+				"connect",
+			},
+			history: 6,
+		},
+		{
+			name: "gives up", failures: 2, maxAttempts: 2,
+			want: []string{
+				"connect",
+				"disconnect test: simulated drop",
+				"reconnect_attempt 1 2s",
+				"connect_failed 1 test: scripted failure 1",
+				"reconnect_attempt 2 3s",
+				"connect_failed 2 test: scripted failure 2",
+				"give_up 2",
+			},
+			history: 7,
+		},
+		{
+			name: "rotates", failures: 2, maxAttempts: 5, maxSize: 300, historySize: 3,
+			want: []string{
+				"connect",
+				"disconnect test: simulated drop",
+				"reconnect_attempt 1 2s",
+				"connect_failed 1 test: scripted failure 1",
+				"reconnect_attempt 2 3s",
+				"connect_failed 2 test: scripted failure 2",
+				"reconnect_attempt 3 4.5s",
+				"connect",
+			},
+			history: 3,
+			rotated: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "lifecycle.jsonl")
+			clientCfg := DefaultClientConfig()
+			clientCfg.ReconnectDelay = 2 * time.Second
+			clientCfg.MaxReconnectDelay = time.Minute
+			clientCfg.ReconnectJitter = 0
+			clientCfg.MaxReconnectAttempts = tc.maxAttempts
+			clientCfg.HistorySize = tc.historySize
+			clientCfg.Clock = newFakeClock()
+			var connects atomic.Int32
+			clientCfg.Connector = func(ctx context.Context, hubURL, token string, format TransferFormat, receiver interface{}) (HubClient, error) {
+				n := int(connects.Add(1))
+				if n > 1 && n <= tc.failures+1 {
+					return nil, fmt.Errorf("test: scripted failure %d", n-1)
+				}
+				return acceptingHub{}, nil
+			}
+
+			fileStore := NewFileLifecycleStore(path)
+			if tc.maxSize > 0 {
+				fileStore = NewRotatingFileLifecycleStore(path, tc.maxSize, 10)
+			}
+			store := &notifyingStore{LifecycleStore: fileStore, appended: make(chan LifecycleEventKind, lifecycleBuffer)}
+			recorder := NewLifecycleRecorder(store)
+			client := newTestClient(t, clientCfg)
+			client.SetLifecycleRecorder(recorder)
+
+			if err := client.Connect(); err != nil {
+				t.Fatalf("initial connect: %v", err)
+			}
+			client.handleDisconnected(errors.New("test: simulated drop"))
+
+			// Settled once the second connect or the give-up is stored
+			connected := 0
+			for settled := false; !settled; {
+				select {
+				case kind := <-store.appended:
+					if kind == LifecycleConnect {
+						connected++
+					}
+					settled = connected == 2 || kind == LifecycleGiveUp
+				case <-time.After(5 * time.Second):
+					t.Fatal("events did not settle within 5s")
+				}
+			}
+			recorder.Close()
+
+			events, err := NewFileLifecycleStore(path).Query(time.Time{}, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for i, event := range events {
+				if i > 0 && event.Time.Before(events[i-1].Time) {
+					t.Errorf("event %d at %v is older than the one before", i, event.Time)
+				}
+				got = append(got, describeLifecycleEvent(event))
+				kind := strings.Fields(got[i])[0]
+				if event.Status != statuses[kind] {
+					t.Errorf("%s recorded status %q, want %s", kind, event.Status, statuses[kind])
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("stored %v, want %v", got, tc.want)
+			}
+			if dropped := recorder.Dropped(); dropped != 0 {
+				t.Errorf("%d events dropped", dropped)
+			}
+
+			var history []string
+			for _, event := range client.History() {
+				history = append(history, describeLifecycleEvent(event))
+			}
+			if latest := tc.want[len(tc.want)-tc.history:]; !reflect.DeepEqual(history, latest) {
+				t.Errorf("history %v, want %v", history, latest)
+			}
+
+			files := 1
+			for ; ; files++ {
+				if _, err := os.Stat(logging.BackupPath(path, files)); err != nil {
+					break
+				}
+			}
+			if tc.rotated != (files > 1) {
+				t.Errorf("events read from %d files, want rotation %v", files, tc.rotated)
+			}
+		})
+	}
+}
+
+// Query keeps the events between its bounds, oldest first
+func TestFileLifecycleStoreQuery(t *testing.T) {
+	store := NewFileLifecycleStore(filepath.Join(t.TempDir(), "lifecycle.jsonl"))
+	start := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		if err := store.Append(LifecycleEvent{Time: start.Add(time.Duration(i) * time.Minute), Kind: LifecycleConnect}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := store.Query(start.Add(time.Minute), start.Add(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || !events[0].Time.Equal(start.Add(time.Minute)) || !events[1].Time.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("queried %+v, want the events at minutes 1 and 2", events)
+	}
+}
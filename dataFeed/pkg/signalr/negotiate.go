@@ -0,0 +1,62 @@
+package signalr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// negotiateResponse is the part of the hub's negotiate reply Negotiate checks.
+// A hub behind a service redirects with URL and AccessToken instead of a
+// connection id.
+type negotiateResponse struct {
+	ConnectionID string `json:"connectionId"`
+	URL          string `json:"url"`
+	AccessToken  string `json:"accessToken"`
+	Error        string `json:"error"`
+}
+
+// Negotiate sends the hub's negotiate request with token and checks the hub
+// offers a connection, without opening one. It checks the hub URL and the
+// token before the full client is started.
+func Negotiate(ctx context.Context, client *http.Client, hubURL, token string) error {
+	negotiateURL, err := url.Parse(hubURL)
+	if err != nil {
+		return fmt.Errorf("invalid hub URL %q: %w", hubURL, err)
+	}
+	negotiateURL.Path = strings.TrimSuffix(negotiateURL.Path, "/") + "/negotiate"
+	query := negotiateURL.Query()
+	query.Set("negotiateVersion", "1")
+	negotiateURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, negotiateURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("negotiate request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("negotiate responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var negotiated negotiateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&negotiated); err != nil {
+		return fmt.Errorf("malformed negotiate response: %w", err)
+	}
+	if negotiated.Error != "" {
+		return fmt.Errorf("hub refused the connection: %s", negotiated.Error)
+	}
+	if negotiated.ConnectionID == "" && negotiated.URL == "" {
+		return fmt.Errorf("negotiate response has neither a connection id nor a redirect URL")
+	}
+	return nil
+}
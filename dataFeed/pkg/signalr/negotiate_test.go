@@ -0,0 +1,56 @@
+package signalr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Negotiate posts to the hub's negotiate path with the token and accepts a
+// connection id or a redirect; refusals are reported with the hub's reason
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "connection", status: http.StatusOK, body: `{"connectionId":"abc"}`},
+		{name: "redirect", status: http.StatusOK, body: `{"url":"https://hub.example.com","accessToken":"t"}`},
+		{name: "unauthorized", status: http.StatusUnauthorized, body: "bad token\n", wantErr: "negotiate responded 401 Unauthorized: bad token"},
+		{name: "refused", status: http.StatusOK, body: `{"error":"hub is full"}`, wantErr: "hub refused the connection: hub is full"},
+		{name: "no connection", status: http.StatusOK, body: `{}`, wantErr: "neither a connection id nor a redirect URL"},
+		{name: "malformed", status: http.StatusOK, body: `<html>`, wantErr: "malformed negotiate response"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/hubs/market/negotiate" || r.URL.Query().Get("negotiateVersion") != "1" {
+					t.Errorf("got %s %s, want POST /hubs/market/negotiate?negotiateVersion=1", r.Method, r.URL)
+				}
+				if got := r.Header.Get("Authorization"); got != "Bearer token" {
+					t.Errorf("got Authorization %q, want the bearer token", got)
+				}
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			err := Negotiate(context.Background(), server.Client(), server.URL+"/hubs/market/", "token")
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("got %v, want the hub accepted", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	if err := Negotiate(context.Background(), http.DefaultClient, "http://127.0.0.1:1/hub", "token"); err == nil || !strings.Contains(err.Error(), "negotiate request failed") {
+		t.Errorf("got %v for an unreachable hub, want the request failure", err)
+	}
+}
@@ -1,34 +1,185 @@
 package signalr
 
 import (
-	"bytes"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	"github.com/andybalholm/brotli"
+	"datafeed/pkg/logging"
+	"datafeed/pkg/market"
 )
 
+// MarketStatusHandler receives parsed market status events
+type MarketStatusHandler func(status market.MarketStatus)
+
+// SharePriceHandler receives parsed share price ticks
+type SharePriceHandler func(price market.SharePrice)
+
 // MessageProcessor handles processing and parsing of SignalR messages
 type MessageProcessor struct {
 	logger *log.Logger
+
+	// Handlers for parsed events
+	marketStatusHandlers []MarketStatusHandler
+	sharePriceHandlers   []SharePriceHandler
+
+	// Records the symbols seen when discovery mode is enabled
+	discovery *market.SymbolDiscovery
+
+	// Share price payloads are decoded by the first of these pipelines that succeeds
+	decodePipelines []DecodePipeline
+	// sharePriceLayout gives the position of each field in a share price record
+	sharePriceLayout market.SharePriceLayout
+	// maxDecompressedSize bounds what a payload may decode to
+	maxDecompressedSize int64
+	// oversized counts payloads dropped for decoding past maxDecompressedSize
+	oversized atomic.Int64
+
+	// clockSkew, when set, estimates how far the server's clock is ahead of ours
+	clockSkew func() (time.Duration, bool)
+	// feedLagSkew, when set, is the same estimate for measuring feed lag,
+	// whether or not tick times are corrected by it
+	feedLagSkew func() (time.Duration, bool)
+	// now is the local clock ticks are stamped with
+	now func() time.Time
+
+	// transferFormat is how the hub encodes message arguments
+	transferFormat TransferFormat
 }
 
 // NewMessageProcessor creates a new message processor
 func NewMessageProcessor() *MessageProcessor {
 	return &MessageProcessor{
-		logger: log.New(os.Stdout, "[MsgProcessor] ", log.LstdFlags),
+		logger:              logging.New("[MsgProcessor] "),
+		decodePipelines:     DefaultDecodePipelines(),
+		sharePriceLayout:    market.DefaultSharePriceLayout,
+		maxDecompressedSize: DefaultMaxDecompressedSize,
+		now:                 time.Now,
+		transferFormat:      DefaultTransferFormat,
+	}
+}
+
+// SetMaxDecompressedSize bounds what a payload may decode to; payloads that
+// would decode to more are dropped. It must be called before messages are
+// processed.
+func (p *MessageProcessor) SetMaxDecompressedSize(limit int64) {
+	if limit <= 0 {
+		limit = DefaultMaxDecompressedSize
+	}
+	p.maxDecompressedSize = limit
+}
+
+// OversizedDropped returns the number of payloads dropped for decoding past
+// the decompressed size limit
+func (p *MessageProcessor) OversizedDropped() int64 {
+	return p.oversized.Load()
+}
+
+// SetClockSkewSource corrects tick times, which are the local receive time,
+// by the skew source reports while it has an estimate, such as Client.ClockSkew.
+// It must be called before messages are processed.
+func (p *MessageProcessor) SetClockSkewSource(source func() (time.Duration, bool)) {
+	p.clockSkew = source
+}
+
+// SetClock replaces the clock ticks are stamped with, e.g. with the recorded
+// receive time of a replayed capture. It must be called before messages are
+// processed; the clock is read once per message.
+func (p *MessageProcessor) SetClock(now func() time.Time) {
+	p.now = now
+}
+
+// SetFeedLagSkewSource measures the feed lag of ticks carrying an exchange
+// time on the server's clock, by the skew source reports while it has an
+// estimate, such as Client.ClockSkew. It must be called before messages are
+// processed.
+func (p *MessageProcessor) SetFeedLagSkewSource(source func() (time.Duration, bool)) {
+	p.feedLagSkew = source
+}
+
+// receivedAt is the time of a tick received at now, on the server's clock
+// when its skew is known
+func (p *MessageProcessor) receivedAt(now time.Time) time.Time {
+	if p.clockSkew != nil {
+		if skew, ok := p.clockSkew(); ok {
+			return now.Add(skew)
+		}
+	}
+	return now
+}
+
+// feedLag is how long after its exchange time a tick received at now was
+// processed. now is moved onto the server's clock by the skew estimate, where
+// known, so the lag is bounded by what the estimate leaves over; a tick
+// stamped ahead of that is taken as not late at all.
+func (p *MessageProcessor) feedLag(now, exchangeTime time.Time) time.Duration {
+	if p.feedLagSkew != nil {
+		if skew, ok := p.feedLagSkew(); ok {
+			now = now.Add(skew)
+		}
+	}
+	return max(now.Sub(exchangeTime), 0)
+}
+
+// SetDecodePipelines replaces the default decode pipelines; they are tried in
+// order until one decodes the payload. It must be called before messages are
+// processed.
+func (p *MessageProcessor) SetDecodePipelines(pipelines []DecodePipeline) {
+	if len(pipelines) == 0 {
+		pipelines = DefaultDecodePipelines()
 	}
+	p.decodePipelines = pipelines
+}
+
+// SetTransferFormat tells the processor how the hub encodes message arguments.
+// Under the binary format they arrive as MessagePack values, such as raw bytes
+// and maps with untyped keys, rather than JSON ones. It must be called before
+// messages are processed.
+func (p *MessageProcessor) SetTransferFormat(format TransferFormat) {
+	if format == "" {
+		format = DefaultTransferFormat
+	}
+	p.transferFormat = format
+}
+
+// SetSharePriceLayout replaces the default share price record layout. It must
+// be called before messages are processed.
+func (p *MessageProcessor) SetSharePriceLayout(layout market.SharePriceLayout) {
+	p.sharePriceLayout = layout
+}
+
+// OnMarketStatus registers a handler for parsed market status events.
+// Handlers must be registered before messages are processed.
+func (p *MessageProcessor) OnMarketStatus(handler MarketStatusHandler) {
+	p.marketStatusHandlers = append(p.marketStatusHandlers, handler)
+}
+
+// OnSharePrice registers a handler for parsed share price ticks.
+// Handlers must be registered before messages are processed.
+func (p *MessageProcessor) OnSharePrice(handler SharePriceHandler) {
+	p.sharePriceHandlers = append(p.sharePriceHandlers, handler)
+}
+
+// EnableDiscovery records the symbol of every parsed tick in discovery.
+// It must be called before messages are processed.
+func (p *MessageProcessor) EnableDiscovery(discovery *market.SymbolDiscovery) {
+	p.discovery = discovery
 }
 
 // Process processes a SignalR message
 func (p *MessageProcessor) Process(msg Message) {
 	p.logger.Printf("Processing message: method=%s with data type: %T", msg.Method, msg.Data)
 
+	// MessagePack arguments take the shapes JSON ones do, so the handlers
+	// below need not tell the formats apart
+	if p.transferFormat.Binary() {
+		msg.Data = fromBinaryArgument(msg.Data)
+	}
+
 	// Log more details about the data
 	if args, ok := msg.Data.([]interface{}); ok {
 		p.logger.Printf("Message contains %d arguments", len(args))
@@ -52,6 +203,35 @@ func (p *MessageProcessor) Process(msg Message) {
 	}
 }
 
+// fromBinaryArgument converts a MessagePack decoded argument to the value JSON
+// decoding gives: raw bytes become text and maps get string keys
+func fromBinaryArgument(arg interface{}) interface{} {
+	switch v := arg.(type) {
+	case []byte:
+		return string(v)
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, item := range v {
+			converted[i] = fromBinaryArgument(item)
+		}
+		return converted
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = fromBinaryArgument(item)
+		}
+		return converted
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[key] = fromBinaryArgument(item)
+		}
+		return converted
+	default:
+		return arg
+	}
+}
+
 // processSharePriceUpdate handles share price update messages
 func (p *MessageProcessor) processSharePriceUpdate(data interface{}) {
 	p.logger.Printf("Processing share price update with data type: %T", data)
@@ -109,50 +289,32 @@ func (p *MessageProcessor) processDataString(dataStr string) {
 	p.decompressAndProcess(dataStr)
 }
 
-// decompressAndProcess attempts to decompress data and process it
+// decompressAndProcess decodes data with the first pipeline that succeeds and
+// processes the result
 func (p *MessageProcessor) decompressAndProcess(data string) {
-	// Try multiple decompression strategies
-
-	// Strategy 1: Direct decompression
-	if decompressed, err := p.decompressBrotli(data); err == nil {
-		p.logger.Printf("Decompression succeeded, processing data...")
-		p.processDecompressedData(decompressed)
-		return
-	}
-
-	// Strategy 2: Base64 decode first, then decompress
-	if decoded, err := base64.StdEncoding.DecodeString(data); err == nil {
-		if decompressed, err := p.decompressBrotliBytes(decoded); err == nil {
-			p.logger.Printf("Base64+Brotli decompression succeeded, processing data...")
-			p.processDecompressedData(string(decompressed))
+	var failures []string
+	for _, pipeline := range p.decodePipelines {
+		decoded, err := pipeline.Decode([]byte(data), p.maxDecompressedSize)
+		if errors.Is(err, ErrPayloadTooLarge) {
+			// Other pipelines would inflate the same payload again
+			p.oversized.Add(1)
+			p.logger.Printf("⚠️ Dropping payload: the %s pipeline decoded it past %d bytes", pipeline, p.maxDecompressedSize)
 			return
 		}
-	}
-
-	// Strategy 3: Check if data is already in the expected format (not compressed)
-	if strings.Contains(data, "~") {
-		p.processDecompressedData(data)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		// Plain text is only share price data when it is delimited
+		if len(pipeline.stages) == 0 && !strings.Contains(string(decoded), "~") {
+			failures = append(failures, "plain: not delimited share price data")
+			continue
+		}
+		p.logger.Printf("Decoded with the %s pipeline, processing data...", pipeline)
+		p.processDecompressedData(string(decoded))
 		return
 	}
-}
-
-// decompressBrotli decompresses Brotli-compressed data
-func (p *MessageProcessor) decompressBrotli(input string) (string, error) {
-	decompressed, err := p.decompressBrotliBytes([]byte(input))
-	if err != nil {
-		return "", err
-	}
-	return string(decompressed), nil
-}
-
-// decompressBrotliBytes decompresses Brotli-compressed bytes
-func (p *MessageProcessor) decompressBrotliBytes(input []byte) ([]byte, error) {
-	br := brotli.NewReader(bytes.NewReader(input))
-	decompressed, err := io.ReadAll(br)
-	if err != nil {
-		return nil, fmt.Errorf("brotli decompression error: %w", err)
-	}
-	return decompressed, nil
+	p.logger.Printf("No decode pipeline accepted the payload: %s", strings.Join(failures, "; "))
 }
 
 // processDecompressedData processes the final decompressed data
@@ -167,6 +329,23 @@ func (p *MessageProcessor) processDecompressedData(data string) {
 			p.logger.Printf("First few fields: [%s, %s, %s, ...]",
 				fields[0], fields[1], fields[2])
 		}
+
+		now := p.now()
+		prices, err := market.ParseSharePrices(data, p.sharePriceLayout, p.receivedAt(now))
+		if err != nil {
+			p.logger.Printf("Failed to parse share prices: %v", err)
+		}
+		for _, price := range prices {
+			if !price.ExchangeTime.IsZero() {
+				price.FeedLag = p.feedLag(now, price.ExchangeTime)
+			}
+			if p.discovery != nil {
+				p.discovery.Observe(price)
+			}
+			for _, handler := range p.sharePriceHandlers {
+				handler(price)
+			}
+		}
 	} else {
 		// Try to parse as JSON
 		var jsonObj interface{}
@@ -212,6 +391,20 @@ func (p *MessageProcessor) processMarketStatusUpdate(data interface{}) {
 		if err := json.Unmarshal([]byte(dataStr), &marketStatus); err == nil {
 			p.logger.Printf("Parsed market status: %v", marketStatus)
 		}
+
+		statuses, err := market.ParseMarketStatus(dataStr)
+		if err != nil {
+			p.logger.Printf("Failed to parse market status: %v", err)
+			return
+		}
+		for _, status := range statuses {
+			if status.Halted() {
+				p.logger.Printf("⛔ Trading halt reported (symbol=%q, state=%s)", status.Symbol, status.RawState)
+			}
+			for _, handler := range p.marketStatusHandlers {
+				handler(status)
+			}
+		}
 	}
 }
 
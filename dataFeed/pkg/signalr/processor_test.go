@@ -0,0 +1,152 @@
+package signalr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"datafeed/pkg/market"
+)
+
+// Share prices feed symbol discovery, which groups raw forms by normalized symbol
+func TestDiscovery(t *testing.T) {
+	discovery := market.NewSymbolDiscovery(10 * time.Minute)
+	processor := NewMessageProcessor()
+	processor.EnableDiscovery(discovery)
+	for _, frame := range []string{
+		"GP~350.5~1200|BATBC~512~40",
+		"gp~351~300\nSquarePharma~210.2~75",
+		" BATBC ~513~10|not-a-price~x~1",
+	} {
+		processor.Process(Message{Method: "SharePriceUpdated", Data: frame})
+	}
+
+	want := map[string]string{
+		"BATBC":        `["BATBC"]`,
+		"GP":           `["GP" "gp"]`,
+		"SQUAREPHARMA": `["SquarePharma"]`,
+	}
+	symbols := discovery.Symbols(time.Now())
+	if len(symbols) != len(want) {
+		t.Fatalf("discovered %d symbols, want %v", len(symbols), want)
+	}
+	for _, s := range symbols {
+		if forms := fmt.Sprintf("%q", s.RawForms); forms != want[s.Symbol] {
+			t.Errorf("%s: raw forms %s, want %s", s.Symbol, forms, want[s.Symbol])
+		}
+	}
+}
+
+// A record is read under the configured share_price_fields mapping
+func TestSharePriceLayout(t *testing.T) {
+	const record = "GP~350.5~1200~BATBC~512~40"
+	for _, tc := range []struct {
+		name   string
+		fields map[int]string
+		want   market.SharePrice
+	}{
+		{
+			name:   "dse",
+			fields: map[int]string{0: "symbol", 1: "price", 2: "volume"},
+			want:   market.SharePrice{Symbol: "GP", RawSymbol: "GP", Price: 350.5, Volume: 1200},
+		},
+		{
+			name:   "reordered",
+			fields: map[int]string{3: "symbol", 5: "price", 4: "volume"},
+			want:   market.SharePrice{Symbol: "BATBC", RawSymbol: "BATBC", Price: 40, Volume: 512},
+		},
+		{
+			name:   "no volume",
+			fields: map[int]string{1: "Price", 0: "Symbol"},
+			want:   market.SharePrice{Symbol: "GP", RawSymbol: "GP", Price: 350.5},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			layout, err := market.NewSharePriceLayout(tc.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ticks := processFrame(func(p *MessageProcessor) { p.SetSharePriceLayout(layout) }, record)
+			if len(ticks) != 1 {
+				t.Fatalf("processor delivered %d prices, want 1", len(ticks))
+			}
+			ticks[0].Time = time.Time{}
+			if ticks[0] != tc.want {
+				t.Errorf("processor delivered %+v, want %+v", ticks[0], tc.want)
+			}
+		})
+	}
+
+	for _, fields := range []map[int]string{
+		{1: "price", 2: "volume"},
+		{0: "symbol"},
+		{0: "symbol", 1: "price", 2: "symbol"},
+		{0: "symbol", 1: "price", 2: "change"},
+		{0: "symbol", -1: "price"},
+	} {
+		if _, err := market.NewSharePriceLayout(fields); err == nil {
+			t.Errorf("share_price_fields %v was accepted", fields)
+		}
+	}
+}
+
+// Feed lag is measured on the server's clock from the exchange time, never
+// negative; records with a bad or missing exchange time keep none
+func TestFeedLag(t *testing.T) {
+	// Our clock; the server's runs 2s ahead of it
+	now := time.Date(2024, 3, 4, 8, 32, 52, 0, time.UTC)
+	const skew = 2 * time.Second
+	server := now.Add(skew)
+	records := strings.Join([]string{
+		"GP~350.5~1200~" + server.Add(-47*time.Second).Format(time.RFC3339),
+		"SQ~12.3~10~" + strconv.FormatInt(server.Add(-1500*time.Millisecond).UnixMilli(), 10),
+		"BATBC~512~40~" + strconv.FormatInt(server.Add(-3*time.Second).Unix(), 10),
+		"ACI~210~5~" + server.Add(4*time.Second).Format(time.RFC3339),
+		"RENATA~700~1~yesterday",
+		"BEXIMCO~115~3",
+	}, "|")
+	layout, err := market.NewSharePriceLayout(map[int]string{0: "symbol", 1: "price", 2: "volume", 3: "time"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		skewKnown bool
+		// feed lag by symbol; absent symbols have no exchange time
+		want map[string]time.Duration
+	}{
+		{
+			name:      "skew estimated",
+			skewKnown: true,
+			want:      map[string]time.Duration{"GP": 47 * time.Second, "SQ": 1500 * time.Millisecond, "BATBC": 3 * time.Second, "ACI": 0},
+		},
+		{
+			// Without an estimate the skew is taken for lag, down to zero
+			name: "no skew estimate",
+			want: map[string]time.Duration{"GP": 45 * time.Second, "SQ": 0, "BATBC": time.Second, "ACI": 0},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			prices := processFrame(func(p *MessageProcessor) {
+				p.SetSharePriceLayout(layout)
+				p.SetClock(func() time.Time { return now })
+				p.SetFeedLagSkewSource(func() (time.Duration, bool) { return skew, tc.skewKnown })
+			}, records)
+			if len(prices) != 6 {
+				t.Fatalf("got %d prices, want 6, the bad timestamps included", len(prices))
+			}
+			for _, price := range prices {
+				lag, stamped := tc.want[price.Symbol]
+				if stamped == price.ExchangeTime.IsZero() {
+					t.Errorf("%s has exchange time %v", price.Symbol, price.ExchangeTime)
+				}
+				if price.FeedLag != lag {
+					t.Errorf("%s lags %v, want %v", price.Symbol, price.FeedLag, lag)
+				}
+			}
+		})
+	}
+}
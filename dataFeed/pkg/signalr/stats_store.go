@@ -0,0 +1,75 @@
+package signalr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PersistedStats are the connection statistics kept across restarts
+type PersistedStats struct {
+	LastStatus           string    `json:"lastStatus"`
+	LastMessageAt        time.Time `json:"lastMessageAt"`
+	CumulativeReconnects int       `json:"cumulativeReconnects"`
+	SavedAt              time.Time `json:"savedAt"`
+}
+
+// StatsStore loads and saves persisted connection statistics
+type StatsStore interface {
+	// Load returns nil stats without an error when nothing was saved yet
+	Load() (*PersistedStats, error)
+	Save(stats PersistedStats) error
+}
+
+// FileStatsStore keeps the statistics in a small JSON file
+type FileStatsStore struct {
+	path string
+}
+
+// NewFileStatsStore creates a store backed by the file at path
+func NewFileStatsStore(path string) *FileStatsStore {
+	return &FileStatsStore{path: path}
+}
+
+// Load reads the statistics file
+func (s *FileStatsStore) Load() (*PersistedStats, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stats file: %w", err)
+	}
+
+	var stats PersistedStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("failed to parse stats file %s: %w", s.path, err)
+	}
+	return &stats, nil
+}
+
+// Save writes the statistics atomically, so a crash never leaves a truncated file
+func (s *FileStatsStore) Save(stats PersistedStats) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary stats file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write stats file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write stats file: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
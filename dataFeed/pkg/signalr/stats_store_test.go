@@ -0,0 +1,89 @@
+package signalr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Stats saved by one run are restored into the next client, which keeps
+// counting reconnects from there
+func TestStatsStore(t *testing.T) {
+	store := NewFileStatsStore(filepath.Join(t.TempDir(), "stats.json"))
+	if stats, err := store.Load(); stats != nil || err != nil {
+		t.Fatalf("got %+v (%v) before the first save, want nil stats and no error", stats, err)
+	}
+
+	clock := newFakeClock()
+	clientCfg := DefaultClientConfig()
+	clientCfg.Clock = clock
+	previous := newTestClient(t, clientCfg)
+	previous.notifyActivity()
+	previous.cumulativeReconnects = 3
+	if err := store.Save(previous.SnapshotStats()); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PersistedStats{
+		LastStatus:           previous.Status().String(),
+		LastMessageAt:        clock.Now(),
+		CumulativeReconnects: 3,
+		SavedAt:              clock.Now(),
+	}
+	if !saved.LastMessageAt.Equal(want.LastMessageAt) || !saved.SavedAt.Equal(want.SavedAt) ||
+		saved.LastStatus != want.LastStatus || saved.CumulativeReconnects != want.CumulativeReconnects {
+		t.Fatalf("loaded %+v, want %+v", saved, want)
+	}
+
+	next := newTestClient(t, DefaultClientConfig())
+	next.RestoreStats(*saved)
+	next.cumulativeReconnects++
+	stats := next.GetConnectionStats()
+	if got := stats["cumulativeReconnects"]; got != 4 {
+		t.Errorf("cumulativeReconnects %v after one more reconnect, want 4", got)
+	}
+	if got, _ := stats["lastMessageAt"].(time.Time); !got.Equal(want.LastMessageAt) {
+		t.Errorf("lastMessageAt %v, want %v", got, want.LastMessageAt)
+	}
+	if got := stats["previousRunStatus"]; got != want.LastStatus {
+		t.Errorf("previousRunStatus %v, want %s", got, want.LastStatus)
+	}
+
+	// A message received since start is newer than the restored one and kept
+	next.notifyActivity()
+	received := next.SnapshotStats().LastMessageAt
+	next.RestoreStats(*saved)
+	if got := next.SnapshotStats().LastMessageAt; !got.Equal(received) {
+		t.Errorf("lastMessageAt %v after restoring older stats, want %v", got, received)
+	}
+}
+
+// A corrupt stats file is reported, and saving over it replaces it whole
+func TestStatsStoreCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stats.json")
+	if err := os.WriteFile(path, []byte(`{"lastStatus": "Conn`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := NewFileStatsStore(path)
+	if _, err := store.Load(); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("got %v, want a parse error naming %s", err, path)
+	}
+
+	if err := store.Save(PersistedStats{LastStatus: "Connected", CumulativeReconnects: 1}); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := store.Load()
+	if err != nil || stats.LastStatus != "Connected" || stats.CumulativeReconnects != 1 {
+		t.Errorf("got %+v (%v) after saving over the corrupt file", stats, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("left %d files in the stats directory, want only the stats file", len(entries))
+	}
+}
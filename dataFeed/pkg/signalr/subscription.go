@@ -0,0 +1,124 @@
+package signalr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SharePriceSubscribeMethod is the hub method subscribing to share price updates
+const SharePriceSubscribeMethod = "SubscribeToSharePriceUpdatedEvent"
+
+// DefaultSubscriptionProtocol is the argument layout of the current server
+const DefaultSubscriptionProtocol = "v1"
+
+// SharePriceSubscription describes a share price subscription independently
+// of the argument layout the server expects
+type SharePriceSubscription struct {
+	Exchange  string
+	PageSize  int
+	Page      int
+	SortBy    string
+	SortOrder string // Asc or Desc
+	// Symbols limits the updates to these symbols; empty means all
+	Symbols []string
+}
+
+// DefaultSharePriceSubscription is the whole DSE board, 500 rows a page
+func DefaultSharePriceSubscription() SharePriceSubscription {
+	return SharePriceSubscription{Exchange: "DSE", PageSize: 500, Page: 1, SortOrder: "Asc"}
+}
+
+// SubscriptionEncoder builds subscription arguments in the layout of one
+// server protocol version. The zero value encodes the default version.
+type SubscriptionEncoder struct {
+	version    string
+	sharePrice func(SharePriceSubscription) []interface{}
+}
+
+// subscriptionEncoders are the supported layouts, by protocol version
+var subscriptionEncoders = map[string]func(SharePriceSubscription) []interface{}{
+	"v1": sharePriceArgsV1,
+	"v2": sharePriceArgsV2,
+}
+
+// SubscriptionProtocols lists the protocol versions an encoder may be built for
+func SubscriptionProtocols() []string {
+	return []string{"v1", "v2"}
+}
+
+// NewSubscriptionEncoder returns the encoder of a protocol version such as
+// "v1"; an empty version selects DefaultSubscriptionProtocol
+func NewSubscriptionEncoder(version string) (SubscriptionEncoder, error) {
+	version = strings.ToLower(strings.TrimSpace(version))
+	if version == "" {
+		version = DefaultSubscriptionProtocol
+	}
+	encode, ok := subscriptionEncoders[version]
+	if !ok {
+		return SubscriptionEncoder{}, fmt.Errorf("unknown subscription protocol %q (known: %s)", version, strings.Join(SubscriptionProtocols(), ", "))
+	}
+	return SubscriptionEncoder{version: version, sharePrice: encode}, nil
+}
+
+// Version returns the protocol version the encoder writes
+func (e SubscriptionEncoder) Version() string {
+	if e.sharePrice == nil {
+		return DefaultSubscriptionProtocol
+	}
+	return e.version
+}
+
+func (e SubscriptionEncoder) String() string {
+	return e.Version()
+}
+
+// SharePriceArgs returns the arguments of SharePriceSubscribeMethod
+func (e SubscriptionEncoder) SharePriceArgs(sub SharePriceSubscription) []interface{} {
+	if e.sharePrice == nil {
+		return sharePriceArgsV1(sub)
+	}
+	return e.sharePrice(sub)
+}
+
+// sharePriceArgsV1 is the positional layout: paging packed as
+// "size$page$sortBy$order", the exchange, then filters of which only the
+// symbol list is used
+func sharePriceArgsV1(sub SharePriceSubscription) []interface{} {
+	paging := strconv.Itoa(sub.PageSize) + "$" + strconv.Itoa(sub.Page) + "$" + sub.SortBy + "$" + sub.SortOrder
+	symbols := make([]interface{}, 0, len(sub.Symbols))
+	for _, symbol := range sub.Symbols {
+		symbols = append(symbols, symbol)
+	}
+	return []interface{}{paging, sub.Exchange, nil, "", "", "", symbols, "", nil, false, nil}
+}
+
+// sharePriceArgsV2 is the named layout: a single request object, so fields can
+// be added without shifting the others
+func sharePriceArgsV2(sub SharePriceSubscription) []interface{} {
+	symbols := sub.Symbols
+	if symbols == nil {
+		symbols = []string{}
+	}
+	return []interface{}{map[string]interface{}{
+		"exchange":   sub.Exchange,
+		"pageSize":   sub.PageSize,
+		"pageNumber": sub.Page,
+		"sortBy":     sub.SortBy,
+		"sortOrder":  sub.SortOrder,
+		"symbols":    symbols,
+	}}
+}
+
+// SetSubscriptionEncoder selects the argument layout of the server's protocol
+// version for subscriptions made with SubscribeToSharePrices. It must be
+// called before Connect.
+func (c *Client) SetSubscriptionEncoder(encoder SubscriptionEncoder) {
+	c.encoder = encoder
+}
+
+// SubscribeToSharePrices subscribes to share price updates, with arguments
+// laid out for the configured protocol version
+func (c *Client) SubscribeToSharePrices(sub SharePriceSubscription) error {
+	return c.Subscribe(SharePriceSubscribeMethod, c.encoder.SharePriceArgs(sub)...)
+}
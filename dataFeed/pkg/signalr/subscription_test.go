@@ -0,0 +1,72 @@
+package signalr
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Each protocol version sends and keeps the share price subscription in its
+// own argument layout
+func TestSubscribeToSharePrices(t *testing.T) {
+	sub := DefaultSharePriceSubscription()
+	sub.Symbols = []string{"GP", "BATBC"}
+	want := map[string][]interface{}{
+		"v1": {"500$1$$Asc", "DSE", nil, "", "", "", []interface{}{"GP", "BATBC"}, "", nil, false, nil},
+		"v2": {map[string]interface{}{
+			"exchange":   "DSE",
+			"pageSize":   500,
+			"pageNumber": 1,
+			"sortBy":     "",
+			"sortOrder":  "Asc",
+			"symbols":    []string{"GP", "BATBC"},
+		}},
+	}
+
+	for _, version := range SubscriptionProtocols() {
+		t.Run(version, func(t *testing.T) {
+			encoder, err := NewSubscriptionEncoder(version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hub := &recordingHub{sends: make(chan []interface{}, 1)}
+			client := newTestClient(t, DefaultClientConfig())
+			client.SetSubscriptionEncoder(encoder)
+			client.client = hub
+			client.handleConnected()
+
+			if err := client.SubscribeToSharePrices(sub); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case sent := <-hub.sends:
+				if sent[0] != SharePriceSubscribeMethod || !reflect.DeepEqual(sent[1:], want[version]) {
+					t.Errorf("hub got %v, want %s%v", sent, SharePriceSubscribeMethod, want[version])
+				}
+			case <-time.After(time.Second):
+				t.Fatal("subscription was not sent to the hub")
+			}
+			client.subscriptionsMu.RLock()
+			stored := client.subscriptions[SharePriceSubscribeMethod]
+			client.subscriptionsMu.RUnlock()
+			if !reflect.DeepEqual(stored, want[version]) {
+				t.Errorf("kept %v for resubscription, want %v", stored, want[version])
+			}
+		})
+	}
+}
+
+func TestNewSubscriptionEncoder(t *testing.T) {
+	if encoder, err := NewSubscriptionEncoder(""); err != nil || encoder.Version() != "v1" {
+		t.Errorf("empty protocol selected %v (%v), want v1", encoder, err)
+	}
+	if encoder, err := NewSubscriptionEncoder(" V2 "); err != nil || encoder.Version() != "v2" {
+		t.Errorf("\" V2 \" selected %v (%v), want v2", encoder, err)
+	}
+	if _, err := NewSubscriptionEncoder("v3"); err == nil {
+		t.Error("unknown protocol v3 was accepted")
+	}
+	if version := (SubscriptionEncoder{}).Version(); version != DefaultSubscriptionProtocol {
+		t.Errorf("zero encoder writes %s, want %s", version, DefaultSubscriptionProtocol)
+	}
+}
@@ -0,0 +1,80 @@
+package signalr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RawFrame is one line written by a writer tap
+type RawFrame struct {
+	Time   time.Time     `json:"time"`
+	Method string        `json:"method"`
+	Args   []interface{} `json:"args"`
+}
+
+// NewWriterFrameTap returns a tap writing each raw frame to w as a JSON line.
+// Strings keep their exact bytes (JSON escaped) and byte slices are base64 encoded.
+func NewWriterFrameTap(w io.Writer) RawFrameTap {
+	var mu sync.Mutex
+	return func(method string, args []interface{}) {
+		line, err := json.Marshal(RawFrame{Time: time.Now(), Method: method, Args: args})
+		if err != nil {
+			log.Printf("Raw frame tap: failed to encode %s frame: %v", method, err)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			log.Printf("Raw frame tap: failed to write %s frame: %v", method, err)
+		}
+	}
+}
+
+// maxRawFrameLine bounds a line of a raw frame log, past the message size limit
+const maxRawFrameLine = 64 << 20
+
+// ReadRawFrames passes each frame of a raw frame log to fn, in file order. It
+// stops at the first line that is not a frame, naming its line number, or at
+// the first error of fn.
+func ReadRawFrames(r io.Reader, fn func(RawFrame) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxRawFrameLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var frame RawFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return fmt.Errorf("raw frame log line %d: %w", line, err)
+		}
+		if err := fn(frame); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Message returns the message the client delivered for the frame: share price
+// and market status payloads on their own, other methods with all their
+// arguments
+func (f RawFrame) Message() Message {
+	method := map[string]string{
+		"sharepriceupdated":         "SharePriceUpdated",
+		"marketstatusupdated^^dse~": "MarketStatusUpdated^^DSE~",
+	}[strings.ToLower(f.Method)]
+	if method != "" && len(f.Args) > 0 {
+		if data, ok := f.Args[0].(string); ok {
+			return Message{Method: method, Data: data}
+		}
+	}
+	return Message{Method: f.Method, Data: f.Args}
+}
@@ -0,0 +1,77 @@
+package signalr
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// The tap sees the raw argument of a SharePriceUpdated message before it is
+// delivered, whichever way the hub invokes it, and a panicking tap does not
+// stop the delivery
+func TestRawFrameTap(t *testing.T) {
+	const raw = "G\x1bbrotli-bytes~350.5"
+	client := newTestClient(t, DefaultClientConfig())
+	var tapped []string
+	client.SetRawFrameTap(func(method string, args []interface{}) {
+		tapped = append(tapped, fmt.Sprintf("%s%q", method, args))
+		if method == "Panic" {
+			panic("tap failed")
+		}
+	})
+
+	client.receiver.SharePriceUpdated(raw)
+	client.receiver.Receive("sharePriceUpdated", raw)
+	client.receiver.Receive("Panic", raw)
+
+	want := []string{
+		fmt.Sprintf("SharePriceUpdated[%q]", raw),
+		fmt.Sprintf("sharePriceUpdated[%q]", raw),
+		fmt.Sprintf("Panic[%q]", raw),
+	}
+	if fmt.Sprint(tapped) != fmt.Sprint(want) {
+		t.Errorf("tapped %v, want %v", tapped, want)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case msg := <-client.Messages():
+			if i < 2 && msg.Data != raw {
+				t.Errorf("message %d carries %v, want the raw payload", i, msg.Data)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message %d was not delivered", i)
+		}
+	}
+
+	client.SetRawFrameTap(nil)
+	client.receiver.SharePriceUpdated(raw)
+	if len(tapped) != 3 {
+		t.Errorf("removed tap still saw %v", tapped[3:])
+	}
+}
+
+// A writer tap's log reads back into the messages the client delivered
+func TestWriterFrameTap(t *testing.T) {
+	var log bytes.Buffer
+	tap := NewWriterFrameTap(&log)
+	tap("SharePriceUpdated", []interface{}{"GP~350~10"})
+	tap("MarketStatusUpdated^^DSE~", []interface{}{`{"status":"Open"}`})
+	tap("Ping", []interface{}{"1700000000000", float64(2)})
+
+	var messages []Message
+	if err := ReadRawFrames(&log, func(frame RawFrame) error {
+		messages = append(messages, frame.Message())
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := `[{SharePriceUpdated GP~350~10} {MarketStatusUpdated^^DSE~ {"status":"Open"}} {Ping [1700000000000 2]}]`
+	if got := fmt.Sprint(messages); got != want {
+		t.Errorf("read back %s, want %s", got, want)
+	}
+
+	if err := ReadRawFrames(bytes.NewBufferString("{\"method\":\"Ping\"}\nnot json\n"), func(RawFrame) error { return nil }); err == nil {
+		t.Error("a malformed line was accepted")
+	}
+}
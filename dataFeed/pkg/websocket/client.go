@@ -4,19 +4,33 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
-	"os"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 
+	"datafeed/pkg/backoff"
 	"datafeed/pkg/config"
+	"datafeed/pkg/logging"
 )
 
+// Application heartbeat message types, answered by the server independently
+// of the WebSocket ping and pong control frames
+const (
+	AppPingType = "ping"
+	AppPongType = "pong"
+)
+
+// ErrNotAcknowledged is returned by Subscribe when the server does not
+// acknowledge the subscription in time
+var ErrNotAcknowledged = errors.New("subscription not acknowledged")
+
 // Message represents a WebSocket message
 type Message struct {
 	Type string          `json:"type,omitempty"`
@@ -39,20 +53,41 @@ type Client struct {
 	// Connection state
 	mu          sync.Mutex
 	isConnected bool
+	// monitoring is set while monitorConnection runs, so reconnects do not
+	// start another one
+	monitoring bool
 
 	// Context for cancellation
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	// Handlers for specific message types
-	handlers map[string][]func([]byte)
+	// Handlers for specific message types, guarded by their own lock so
+	// dispatch never waits on connection state changes
+	handlersMu sync.RWMutex
+	handlers   map[string][]func([]byte)
+	// decodeErrors receives payloads OnJSON handlers could not decode
+	decodeErrors func(messageType string, err error)
+
+	// Subscribe calls waiting for an acknowledgement, by ack message type
+	// and oldest first
+	ackMu      sync.Mutex
+	ackWaiters map[string][]chan struct{}
 
 	// Logging
 	logger *log.Logger
 
 	// Reconnection settings
-	reconnectWait time.Duration
-	maxRetries    int
+	backoff    *backoff.Strategy
+	maxRetries int
+
+	// Application heartbeat; appPingInterval 0 disables it
+	appPingInterval time.Duration
+	appPongTimeout  time.Duration
+	heartbeatMu     sync.Mutex
+	// pingSentAt is when the oldest unanswered app ping was sent; zero when
+	// every ping has been answered
+	pingSentAt time.Time
+	lastPong   time.Time
 }
 
 // NewClient creates a new WebSocket client
@@ -60,17 +95,24 @@ func NewClient(cfg *config.WebSocketConfig, token string) *Client {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	client := &Client{
-		url:           cfg.URL,
-		token:         token,
-		headers:       make(http.Header),
-		sendChan:      make(chan []byte, 100),
-		receiveChan:   make(chan Message, 100),
-		handlers:      make(map[string][]func([]byte)),
-		ctx:           ctx,
-		cancel:        cancel,
-		logger:        log.New(os.Stdout, "[WebSocket] ", log.LstdFlags),
-		reconnectWait: 2 * time.Second,
-		maxRetries:    10,
+		url:         cfg.URL,
+		token:       token,
+		headers:     make(http.Header),
+		sendChan:    make(chan []byte, 100),
+		receiveChan: make(chan Message, 100),
+		handlers:    make(map[string][]func([]byte)),
+		ackWaiters:  make(map[string][]chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+		logger:      logging.New("[WebSocket] "),
+		backoff:     backoff.New(backoff.DefaultInitial, 60*time.Second, backoff.DefaultMultiplier, backoff.DefaultJitter),
+		maxRetries:  10,
+
+		appPingInterval: cfg.AppPingInterval,
+		appPongTimeout:  cfg.AppPongTimeout,
+	}
+	if client.appPongTimeout <= 0 {
+		client.appPongTimeout = 2 * client.appPingInterval
 	}
 
 	// Set default headers
@@ -121,27 +163,85 @@ func (c *Client) Connect() error {
 	c.isConnected = true
 	c.logger.Printf("Connected to WebSocket server")
 
-	// Start goroutines for reading and writing
-	go c.readPump()
-	go c.writePump()
+	// A fresh connection starts without an unanswered heartbeat
+	c.heartbeatMu.Lock()
+	c.pingSentAt = time.Time{}
+	c.heartbeatMu.Unlock()
+
+	// Start goroutines for reading and writing; they stop with this
+	// connection, done is closed when its read pump exits
+	done := make(chan struct{})
+	go c.readPump(conn, done)
+	go c.writePump(conn, done)
+	if c.appPingInterval > 0 {
+		go c.appHeartbeat(conn, done)
+	}
 
 	// Start connection monitor for automatic reconnection
-	go c.monitorConnection()
+	if !c.monitoring {
+		c.monitoring = true
+		go c.monitorConnection()
+	}
 
 	return nil
 }
 
 // On registers a handler function for a specific message type
 func (c *Client) On(messageType string, handler func(data []byte)) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.handlersMu.Lock()
+	c.handlers[messageType] = append(c.handlers[messageType], handler)
+	c.handlersMu.Unlock()
 
-	if c.handlers[messageType] == nil {
-		c.handlers[messageType] = make([]func([]byte), 0)
+	c.logger.Printf("Registered handler for message type: %s", messageType)
+}
+
+// OnJSON registers a handler that receives the message data decoded into a
+// fresh value of target's type, e.g. OnJSON("tick", &Tick{}, ...) passes a new
+// *Tick on every message. target itself is never written to. Data that cannot
+// be decoded is reported to the sink set with OnDecodeError instead.
+func (c *Client) OnJSON(messageType string, target interface{}, handler func(interface{})) {
+	targetType := reflect.TypeOf(target)
+	if targetType == nil {
+		panic("websocket: OnJSON target must not be nil")
+	}
+	isPointer := targetType.Kind() == reflect.Ptr
+	if isPointer {
+		targetType = targetType.Elem()
 	}
 
-	c.handlers[messageType] = append(c.handlers[messageType], handler)
-	c.logger.Printf("Registered handler for message type: %s", messageType)
+	c.On(messageType, func(data []byte) {
+		value := reflect.New(targetType)
+		if err := json.Unmarshal(data, value.Interface()); err != nil {
+			c.reportDecodeError(messageType, fmt.Errorf("failed to decode %s data into %s: %w", messageType, targetType, err))
+			return
+		}
+		if isPointer {
+			handler(value.Interface())
+		} else {
+			handler(value.Elem().Interface())
+		}
+	})
+}
+
+// OnDecodeError sets the sink OnJSON handlers report undecodable data to;
+// without one the error is logged
+func (c *Client) OnDecodeError(sink func(messageType string, err error)) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.decodeErrors = sink
+}
+
+// reportDecodeError passes err to the decode error sink, or logs it
+func (c *Client) reportDecodeError(messageType string, err error) {
+	c.handlersMu.RLock()
+	sink := c.decodeErrors
+	c.handlersMu.RUnlock()
+
+	if sink == nil {
+		c.logger.Printf("%v", err)
+		return
+	}
+	sink(messageType, err)
 }
 
 // Send sends a message to the WebSocket server
@@ -168,6 +268,144 @@ func (c *Client) SendJSON(v interface{}) error {
 	return c.Send(data)
 }
 
+// Subscribe sends msg as JSON and waits up to timeout for a server message of
+// ackType, so the caller knows the subscription is live. Concurrent calls
+// waiting for the same ackType are acknowledged in the order they were sent.
+// A missing acknowledgement returns an error wrapping ErrNotAcknowledged.
+func (c *Client) Subscribe(msg interface{}, ackType string, timeout time.Duration) error {
+	// Wait before sending, so an acknowledgement arriving at once is not missed
+	ack := c.awaitAck(ackType)
+	defer c.stopAwaitingAck(ackType, ack)
+
+	if err := c.SendJSON(msg); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ack:
+		c.logger.Printf("Subscription acknowledged with %s", ackType)
+		return nil
+	case <-timer.C:
+		// An acknowledgement racing the timer still counts
+		select {
+		case <-ack:
+			return nil
+		default:
+		}
+		return fmt.Errorf("no %s message within %v: %w", ackType, timeout, ErrNotAcknowledged)
+	case <-c.ctx.Done():
+		return fmt.Errorf("client closed while waiting for %s: %w", ackType, ErrNotAcknowledged)
+	}
+}
+
+// awaitAck registers a waiter for the next message of ackType
+func (c *Client) awaitAck(ackType string) chan struct{} {
+	ack := make(chan struct{})
+	c.ackMu.Lock()
+	c.ackWaiters[ackType] = append(c.ackWaiters[ackType], ack)
+	c.ackMu.Unlock()
+	return ack
+}
+
+// stopAwaitingAck removes a waiter that was not acknowledged
+func (c *Client) stopAwaitingAck(ackType string, ack chan struct{}) {
+	c.ackMu.Lock()
+	defer c.ackMu.Unlock()
+
+	waiters := c.ackWaiters[ackType]
+	for i, waiter := range waiters {
+		if waiter == ack {
+			c.ackWaiters[ackType] = append(waiters[:i:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(c.ackWaiters[ackType]) == 0 {
+		delete(c.ackWaiters, ackType)
+	}
+}
+
+// acknowledge releases the oldest Subscribe call waiting for messageType
+func (c *Client) acknowledge(messageType string) {
+	c.ackMu.Lock()
+	defer c.ackMu.Unlock()
+
+	waiters := c.ackWaiters[messageType]
+	if len(waiters) == 0 {
+		return
+	}
+	close(waiters[0])
+	if len(waiters) == 1 {
+		delete(c.ackWaiters, messageType)
+	} else {
+		c.ackWaiters[messageType] = waiters[1:]
+	}
+}
+
+// SendAppPing sends an application {"type":"ping"} heartbeat. Until the
+// server answers with {"type":"pong"} the ping stays outstanding, and further
+// pings do not restart the pong timeout.
+func (c *Client) SendAppPing() error {
+	c.heartbeatMu.Lock()
+	if c.pingSentAt.IsZero() {
+		c.pingSentAt = time.Now()
+	}
+	c.heartbeatMu.Unlock()
+
+	return c.SendJSON(Message{Type: AppPingType})
+}
+
+// LastAppPong returns when the last application pong arrived; zero when none has
+func (c *Client) LastAppPong() time.Time {
+	c.heartbeatMu.Lock()
+	defer c.heartbeatMu.Unlock()
+
+	return c.lastPong
+}
+
+// recordAppPong answers the outstanding app ping
+func (c *Client) recordAppPong() {
+	c.heartbeatMu.Lock()
+	c.pingSentAt = time.Time{}
+	c.lastPong = time.Now()
+	c.heartbeatMu.Unlock()
+}
+
+// appPongOverdue reports whether an app ping has gone unanswered past the timeout
+func (c *Client) appPongOverdue(now time.Time) bool {
+	c.heartbeatMu.Lock()
+	defer c.heartbeatMu.Unlock()
+
+	return !c.pingSentAt.IsZero() && now.Sub(c.pingSentAt) >= c.appPongTimeout
+}
+
+// appHeartbeat sends app pings on conn every appPingInterval. When a ping goes
+// unanswered for appPongTimeout it closes conn, so the read pump ends and
+// monitorConnection reconnects.
+func (c *Client) appHeartbeat(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(c.appPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+			if c.appPongOverdue(time.Now()) {
+				c.logger.Printf("No pong within %v, dropping the connection", c.appPongTimeout)
+				conn.Close()
+				return
+			}
+			if err := c.SendAppPing(); err != nil {
+				c.logger.Printf("App ping error: %v", err)
+			}
+		}
+	}
+}
+
 // Receive returns a channel that receives WebSocket messages
 func (c *Client) Receive() <-chan Message {
 	return c.receiveChan
@@ -185,9 +423,10 @@ func (c *Client) Close() {
 
 	// Close the connection
 	if c.conn != nil {
-		// Send close message
-		err := c.conn.WriteMessage(websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		// Send close message; unlike WriteMessage, WriteControl may run while
+		// the write pump is still writing
+		err := c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
 		if err != nil {
 			c.logger.Printf("Error sending close message: %v", err)
 		}
@@ -213,17 +452,19 @@ func (c *Client) IsConnected() bool {
 	return c.isConnected
 }
 
-// readPump pumps messages from the WebSocket connection to the receiveChan
-func (c *Client) readPump() {
+// readPump pumps messages from conn to the receiveChan and closes done when
+// the connection ends
+func (c *Client) readPump(conn *websocket.Conn, done chan<- struct{}) {
 	defer func() {
 		c.logger.Println("Read pump exiting")
-		c.handleDisconnect()
+		close(done)
+		c.handleDisconnect(conn)
 	}()
 
-	c.conn.SetReadLimit(512 * 1024) // 512KB max message size
-	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetReadLimit(512 * 1024) // 512KB max message size
+	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 		return nil
 	})
 
@@ -232,7 +473,7 @@ func (c *Client) readPump() {
 		case <-c.ctx.Done():
 			return
 		default:
-			_, message, err := c.conn.ReadMessage()
+			_, message, err := conn.ReadMessage()
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 					c.logger.Printf("WebSocket read error: %v", err)
@@ -245,8 +486,8 @@ func (c *Client) readPump() {
 	}
 }
 
-// writePump pumps messages from the sendChan to the WebSocket connection
-func (c *Client) writePump() {
+// writePump pumps messages from the sendChan to conn until done is closed
+func (c *Client) writePump(conn *websocket.Conn, done <-chan struct{}) {
 	ticker := time.NewTicker(30 * time.Second)
 	defer func() {
 		ticker.Stop()
@@ -257,22 +498,24 @@ func (c *Client) writePump() {
 		select {
 		case <-c.ctx.Done():
 			return
+		case <-done:
+			return
 		case message, ok := <-c.sendChan:
 			if !ok {
 				// Channel closed
 				return
 			}
 
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				c.logger.Printf("WebSocket write error: %v", err)
 				return
 			}
 
 		case <-ticker.C:
 			// Send ping message
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				c.logger.Printf("WebSocket ping error: %v", err)
 				return
 			}
@@ -297,11 +540,18 @@ func (c *Client) processMessage(data []byte) {
 		}
 	}
 
+	if message.Type == AppPongType {
+		c.recordAppPong()
+	}
+	if message.Type != "" {
+		c.acknowledge(message.Type)
+	}
+
 	// Call handlers for this message type
 	if message.Type != "" {
-		c.mu.Lock()
+		c.handlersMu.RLock()
 		handlers := c.handlers[message.Type]
-		c.mu.Unlock()
+		c.handlersMu.RUnlock()
 
 		for _, handler := range handlers {
 			go handler(message.Data)
@@ -316,9 +566,14 @@ func (c *Client) processMessage(data []byte) {
 	}
 }
 
-// handleDisconnect handles a disconnection
-func (c *Client) handleDisconnect() {
+// handleDisconnect handles the end of conn; a connection that has already
+// been replaced is left alone
+func (c *Client) handleDisconnect(conn *websocket.Conn) {
 	c.mu.Lock()
+	if c.conn != conn {
+		c.mu.Unlock()
+		return
+	}
 	wasConnected := c.isConnected
 	c.isConnected = false
 	c.conn = nil
@@ -331,13 +586,20 @@ func (c *Client) handleDisconnect() {
 
 // monitorConnection monitors the connection and reconnects if needed
 func (c *Client) monitorConnection() {
+	defer func() {
+		c.mu.Lock()
+		c.monitoring = false
+		c.mu.Unlock()
+	}()
+
 	retries := 0
+	wait := c.backoff.Next()
 
 	for {
 		select {
 		case <-c.ctx.Done():
 			return
-		case <-time.After(c.reconnectWait):
+		case <-time.After(wait):
 			// Check if we need to reconnect
 			c.mu.Lock()
 			needsReconnect := !c.isConnected
@@ -354,15 +616,12 @@ func (c *Client) monitorConnection() {
 
 				if err := c.Connect(); err != nil {
 					c.logger.Printf("Reconnection failed: %v", err)
-					// Use exponential backoff
-					c.reconnectWait = time.Duration(float64(c.reconnectWait) * 1.5)
-					if c.reconnectWait > 60*time.Second {
-						c.reconnectWait = 60 * time.Second
-					}
+					wait = c.backoff.Next()
 				} else {
 					c.logger.Println("Reconnection successful")
 					retries = 0
-					c.reconnectWait = 2 * time.Second // Reset to initial value
+					c.backoff.Reset()
+					wait = c.backoff.Next()
 				}
 			}
 		}
@@ -0,0 +1,334 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"datafeed/pkg/backoff"
+	"datafeed/pkg/config"
+)
+
+// newOfflineClient returns a client that dispatches messages without a connection
+func newOfflineClient(t *testing.T, buffer int) *Client {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &Client{
+		receiveChan: make(chan Message, buffer),
+		handlers:    make(map[string][]func([]byte)),
+		ctx:         ctx,
+		cancel:      cancel,
+		logger:      log.New(io.Discard, "", 0),
+	}
+}
+
+// dialServer connects a quiet client to a local server running handler
+func dialServer(t *testing.T, cfg config.WebSocketConfig, handler func(*websocket.Conn)) *Client {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(conn)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg.URL = "ws" + strings.TrimPrefix(server.URL, "http")
+	client := NewClient(&cfg, "test")
+	client.logger = log.New(io.Discard, "", 0)
+	client.backoff = backoff.New(10*time.Millisecond, 50*time.Millisecond, backoff.DefaultMultiplier, 0)
+	// Nothing reads the receive channel, so keep it from filling up
+	go func() {
+		for range client.Receive() {
+		}
+	}()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// waitFor fails the test when done is not closed within timeout
+func waitFor(t *testing.T, done <-chan struct{}, timeout time.Duration, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatalf("%s did not happen within %v", what, timeout)
+	}
+}
+
+// Handlers registered while readers dispatch share the handlers map safely;
+// run with -race
+func TestOnWhileDispatching(t *testing.T) {
+	const messages, readers, registrations = 200, 4, 50
+	c := newOfflineClient(t, 1)
+
+	var calls sync.WaitGroup
+	var baseline int64
+	c.On("tick", func([]byte) {
+		atomic.AddInt64(&baseline, 1)
+		calls.Done()
+	})
+
+	// Late handlers may or may not see a given message, so only the baseline
+	// handler's calls are counted
+	var workers sync.WaitGroup
+	for i := 0; i < registrations; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			c.On("tick", func([]byte) {})
+		}()
+	}
+	message := []byte(`{"type":"tick","data":{}}`)
+	for r := 0; r < readers; r++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for m := 0; m < messages; m++ {
+				calls.Add(1)
+				c.processMessage(message)
+				// Drain the receive channel like Receive consumers would
+				select {
+				case <-c.receiveChan:
+				default:
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		calls.Wait()
+		close(done)
+	}()
+	waitFor(t, done, 5*time.Second, "dispatch")
+
+	if got := atomic.LoadInt64(&baseline); got != messages*readers {
+		t.Errorf("baseline handler called %d times, want %d", got, messages*readers)
+	}
+	c.handlersMu.RLock()
+	registered := len(c.handlers["tick"])
+	c.handlersMu.RUnlock()
+	if registered != registrations+1 {
+		t.Errorf("%d handlers registered, want %d", registered, registrations+1)
+	}
+}
+
+type testTick struct {
+	Symbol string  `json:"symbol"`
+	Price  float64 `json:"price"`
+}
+
+func TestOnJSON(t *testing.T) {
+	c := newOfflineClient(t, 8)
+
+	var mu sync.Mutex
+	var pointer *testTick
+	var value testTick
+	var decodeErrors []string
+	calls := 0
+	var pending sync.WaitGroup
+	pending.Add(3)
+
+	c.OnDecodeError(func(messageType string, err error) {
+		mu.Lock()
+		decodeErrors = append(decodeErrors, messageType)
+		mu.Unlock()
+		pending.Done()
+	})
+	pointerTarget := &testTick{}
+	c.OnJSON("tick", pointerTarget, func(v interface{}) {
+		mu.Lock()
+		pointer, _ = v.(*testTick)
+		calls++
+		mu.Unlock()
+		pending.Done()
+	})
+	valueTarget := testTick{}
+	c.OnJSON("quote", valueTarget, func(v interface{}) {
+		mu.Lock()
+		value, _ = v.(testTick)
+		calls++
+		mu.Unlock()
+		pending.Done()
+	})
+
+	c.processMessage([]byte(`{"type":"tick","data":{"symbol":"GP","price":301.5}}`))
+	c.processMessage([]byte(`{"type":"quote","data":{"symbol":"BATBC","price":512}}`))
+	c.processMessage([]byte(`{"type":"tick","data":{"symbol":"GP","price":"not a number"}}`))
+
+	done := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(done)
+	}()
+	waitFor(t, done, 5*time.Second, "typed dispatch")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if pointer == nil || *pointer != (testTick{Symbol: "GP", Price: 301.5}) {
+		t.Errorf("pointer handler got %+v", pointer)
+	}
+	if value != (testTick{Symbol: "BATBC", Price: 512}) {
+		t.Errorf("value handler got %+v", value)
+	}
+	if calls != 2 {
+		t.Errorf("typed handlers called %d times, want 2", calls)
+	}
+	if len(decodeErrors) != 1 || decodeErrors[0] != "tick" {
+		t.Errorf("decode errors reported for %v, want [tick]", decodeErrors)
+	}
+	if *pointerTarget != (testTick{}) || valueTarget != (testTick{}) {
+		t.Error("registered targets were written to")
+	}
+}
+
+// The app heartbeat keeps its ping schedule, drops a connection whose pong is
+// app_pong_timeout late and reconnects
+func TestAppHeartbeatDropsSilentConnection(t *testing.T) {
+	const (
+		interval    = 50 * time.Millisecond
+		pongTimeout = 120 * time.Millisecond
+		answered    = 3
+	)
+
+	var mu sync.Mutex
+	var pings []time.Time
+	var gaps []time.Duration
+	var silentFrom, reconnectedAt time.Time
+	var silence time.Duration
+	connections := 0
+	reconnected := make(chan struct{})
+	var reconnectedOnce sync.Once
+
+	client := dialServer(t, config.WebSocketConfig{AppPingInterval: interval, AppPongTimeout: pongTimeout}, func(conn *websocket.Conn) {
+		mu.Lock()
+		connections++
+		first := connections == 1
+		if connections == 2 {
+			reconnectedAt = time.Now()
+		}
+		mu.Unlock()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				if first {
+					mu.Lock()
+					if !silentFrom.IsZero() {
+						silence = time.Since(silentFrom)
+					}
+					mu.Unlock()
+				}
+				return
+			}
+			var message Message
+			if json.Unmarshal(data, &message) != nil || message.Type != AppPingType {
+				continue
+			}
+			if !first {
+				conn.WriteJSON(Message{Type: AppPongType})
+				reconnectedOnce.Do(func() { close(reconnected) })
+				continue
+			}
+			mu.Lock()
+			now := time.Now()
+			if len(pings) > 0 {
+				gaps = append(gaps, now.Sub(pings[len(pings)-1]))
+			}
+			pings = append(pings, now)
+			answer := len(pings) <= answered
+			if !answer && silentFrom.IsZero() {
+				silentFrom = now
+			}
+			mu.Unlock()
+			if answer {
+				conn.WriteJSON(Message{Type: AppPongType})
+			}
+		}
+	})
+
+	waitFor(t, reconnected, 5*time.Second, "reconnect with a pong")
+	mu.Lock()
+	since := reconnectedAt
+	mu.Unlock()
+	// The pong is processed after the server sent it
+	deadline := time.Now().Add(time.Second)
+	for !client.LastAppPong().After(since) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Pings keep their schedule, unanswered ones included, until the drop
+	for i, gap := range gaps {
+		if gap < interval/2 || gap > 3*interval {
+			t.Errorf("ping %d came %v after the previous one, want about %v", i+2, gap, interval)
+		}
+	}
+	if len(pings) <= answered {
+		t.Errorf("got %d pings, want more than the %d answered", len(pings), answered)
+	}
+	if silence < pongTimeout || silence > pongTimeout+3*interval {
+		t.Errorf("connection dropped %v after the first unanswered ping, want about %v", silence, pongTimeout)
+	}
+	if connections < 2 || !client.LastAppPong().After(since) {
+		t.Errorf("got %d connections and last pong %v, want a reconnect answered after %v", connections, client.LastAppPong(), since)
+	}
+}
+
+// Subscribe returns once acknowledged, and fails with ErrNotAcknowledged after
+// the timeout when the server ignores it
+func TestSubscribeAck(t *testing.T) {
+	const ackDelay, ackTimeout = 30 * time.Millisecond, 200 * time.Millisecond
+	client := dialServer(t, config.WebSocketConfig{}, func(conn *websocket.Conn) {
+		for {
+			var message Message
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			if message.Type == "subscribe" {
+				time.Sleep(ackDelay)
+				conn.WriteJSON(Message{Type: "subscribed", Data: message.Data})
+			}
+		}
+	})
+
+	start := time.Now()
+	err := client.Subscribe(Message{Type: "subscribe", Data: json.RawMessage(`{"symbol":"GP"}`)}, "subscribed", ackTimeout)
+	if took := time.Since(start); err != nil || took < ackDelay || took >= ackTimeout {
+		t.Errorf("acknowledged Subscribe returned %v after %v, want nil after about %v", err, took, ackDelay)
+	}
+
+	start = time.Now()
+	err = client.Subscribe(Message{Type: "subscribe_silent", Data: json.RawMessage(`{"symbol":"SQ"}`)}, "subscribed", ackTimeout)
+	if took := time.Since(start); !errors.Is(err, ErrNotAcknowledged) || took < ackTimeout {
+		t.Errorf("ignored Subscribe returned %v after %v, want ErrNotAcknowledged after %v", err, took, ackTimeout)
+	}
+
+	client.ackMu.Lock()
+	waiting := len(client.ackWaiters)
+	client.ackMu.Unlock()
+	if waiting != 0 {
+		t.Errorf("%d subscriptions still waiting for an acknowledgement", waiting)
+	}
+}
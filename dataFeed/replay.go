@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/hello-api/pkg/httpclient"
+
+	"datafeed/pkg/config"
+	"datafeed/pkg/forwarder"
+	"datafeed/pkg/market"
+	"datafeed/pkg/signalr"
+)
+
+// runReplay replays a raw frame log (raw_frame_log) through the message
+// processor and returns the exit code. With --forward the parsed prices are
+// backfilled into the API at api_url after an outage, e.g.
+//
+//	datafeed replay --forward -capture frames.jsonl
+//
+// Prices the API already stored are skipped, so a capture can be backfilled
+// again after a failure. Without --forward nothing is posted; the frames and
+// prices the capture holds are only counted.
+func runReplay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	forward := flags.Bool("forward", false, "backfill the parsed prices into the API at api_url")
+	capturePath := flags.String("capture", "frames.jsonl", "raw frame log written by the datafeed (raw_frame_log)")
+	configPath := flags.String("config", "config.yaml", "config file to read api_url, api_secret and the decode settings from")
+	batchSize := flags.Int("batch", 500, "prices posted per request")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Printf("❌ Failed to load config: %v", err)
+		return 1
+	}
+	processor, err := backfillProcessor(cfg)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return 1
+	}
+	capture, err := os.Open(*capturePath)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return 1
+	}
+	defer capture.Close()
+
+	if !*forward {
+		frames, prices := 0, 0
+		processor.OnSharePrice(func(market.SharePrice) { prices++ })
+		err := signalr.ReadRawFrames(capture, func(frame signalr.RawFrame) error {
+			frames++
+			processor.Process(frame.Message())
+			return nil
+		})
+		if err != nil {
+			log.Printf("❌ Replay stopped after %d frames: %v", frames, err)
+			return 1
+		}
+		log.Printf("✅ %d frames, %d prices (dry run, add --forward to backfill the API)", frames, prices)
+		return 0
+	}
+
+	if cfg.APIURL == "" || cfg.APISecret == "" {
+		log.Printf("❌ api_url and api_secret must be set in %s to backfill the API", *configPath)
+		return 1
+	}
+	log.Printf("🔁 Backfilling %s from %s", cfg.APIURL, *capturePath)
+	fwd := forwarder.New(cfg.APIURL, cfg.APISecret, httpclient.New(cfg.HTTPClient()))
+	report, err := forwarder.Backfill(context.Background(), capture, processor, fwd, *batchSize)
+	if err != nil {
+		log.Printf("❌ Backfill stopped after %d frames and %d batches: %v", report.Frames, report.Batches, err)
+		return 1
+	}
+	log.Printf("✅ %d frames, %d prices in %d batches: %d stored, %d already stored, %d quarantined, %d shadow fires",
+		report.Frames, report.Prices, report.Batches, report.Accepted, report.Duplicates, report.Quarantined, report.ShadowFired)
+	for _, day := range report.Days {
+		log.Printf("   recomputed %s on %s", day.Symbol, day.TradingDate)
+	}
+	return 0
+}
+
+// backfillProcessor decodes share prices as the feed configured in cfg does
+func backfillProcessor(cfg *config.Config) (*signalr.MessageProcessor, error) {
+	processor := signalr.NewMessageProcessor()
+	processor.SetMaxDecompressedSize(cfg.MaxDecompressedSize)
+	if len(cfg.DecodePipelines) > 0 {
+		pipelines, err := signalr.NewDecodePipelines(cfg.DecodePipelines)
+		if err != nil {
+			return nil, fmt.Errorf("invalid decode_pipelines: %w", err)
+		}
+		processor.SetDecodePipelines(pipelines)
+	}
+	if len(cfg.SharePriceFields) > 0 {
+		layout, err := market.NewSharePriceLayout(cfg.SharePriceFields)
+		if err != nil {
+			return nil, fmt.Errorf("invalid share_price_fields: %w", err)
+		}
+		processor.SetSharePriceLayout(layout)
+	}
+	return processor, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"datafeed/pkg/signalr"
+)
+
+// writeCapture writes a raw frame log with one share price frame per price
+func writeCapture(t *testing.T, dir string, prices ...string) string {
+	t.Helper()
+	start := time.Date(2024, 3, 4, 4, 0, 0, 0, time.UTC)
+	var lines []string
+	for i, price := range prices {
+		line, _ := json.Marshal(signalr.RawFrame{Time: start.Add(time.Duration(i) * time.Minute), Method: "SharePriceUpdated", Args: []interface{}{price}})
+		lines = append(lines, string(line))
+	}
+	path := filepath.Join(dir, "frames.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// replay --forward posts the capture to api_url as backfill; without it
+// nothing is posted
+func TestRunReplay(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"success":true,"data":{"accepted":2,"duplicates":0,"quarantined":[],"recomputed":[]}}`)
+	}))
+	defer api.Close()
+
+	dir := t.TempDir()
+	capture := writeCapture(t, dir, "GP~350~100", "GP~352~50")
+	configPath := filepath.Join(dir, "config.yaml")
+	config := fmt.Sprintf("api_url: %q\napi_secret: \"replay-secret\"\n", api.URL)
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantPost int
+	}{
+		{"dry run", []string{"-capture", capture, "-config", configPath}, 0, 0},
+		{"forward", []string{"--forward", "-capture", capture, "-config", configPath}, 0, 1},
+		{"missing capture", []string{"--forward", "-capture", filepath.Join(dir, "none.jsonl"), "-config", configPath}, 1, 0},
+		{"unknown flag", []string{"--backfill"}, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			bodies = nil
+			mu.Unlock()
+			if code := runReplay(tt.args); code != tt.wantCode {
+				t.Errorf("got exit code %d, want %d", code, tt.wantCode)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(bodies) != tt.wantPost {
+				t.Fatalf("got %d requests to the API, want %d", len(bodies), tt.wantPost)
+			}
+			for _, body := range bodies {
+				if !strings.Contains(body, `"backfill":true`) {
+					t.Errorf("got body %s, want it flagged as backfill", body)
+				}
+			}
+		})
+	}
+}
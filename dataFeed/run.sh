@@ -29,6 +29,28 @@ case "$1" in
         cd "$(dirname "$0")/cmd/basic"
         go run basic_client.go
         ;;
+    "unit")
+        echo "🧪 Running the package tests..."
+        cd "$(dirname "$0")"
+        go test -race ./pkg/...
+        ;;
+    "replay")
+        echo "🔁 Replaying a raw frame log..."
+        cd "$(dirname "$0")"
+        shift
+        go run . replay "$@"
+        ;;
+    "check")
+        echo "🩺 Checking configuration, login and the SignalR hub..."
+        cd "$(dirname "$0")"
+        go run . check
+        ;;
+    "lifecycle")
+        echo "📜 Querying recorded connection lifecycle events..."
+        cd "$(dirname "$0")"
+        shift
+        go run ./cmd/lifecycle "$@"
+        ;;
     "build")
         echo "🔨 Building applications..."
         cd "$(dirname "$0")"
@@ -43,12 +65,16 @@ case "$1" in
         echo "✅ Build complete!"
         ;;
     *)
-        echo "Usage: $0 {main|debug|test|simple|basic|build}"
+        echo "Usage: $0 {main|debug|test|simple|basic|unit|replay|check|lifecycle|build}"
         echo "  main   - Run the main data feed application"
         echo "  debug  - Run main application with debug logging to file"
         echo "  test   - Run the special character test"
         echo "  simple - Run the simple documentation-based client"
         echo "  basic  - Run the basic connection test"
+        echo "  unit   - Run the package tests (no server needed)"
+        echo "  replay - Replay a raw frame log; --forward backfills the API from it"
+        echo "  check  - Verify the configuration, login and SignalR hub before a deploy"
+        echo "  lifecycle - Query the connection lifecycle log (-file, -since, -kind)"
         echo "  build  - Build all applications"
         exit 1
         ;;
@@ -0,0 +1,9 @@
+// Package integration runs the data feed and the API together: prices pushed
+// by the mock exchange feed of datafeed/pkg/devserver go through the data feed
+// pipeline and its forwarder to the API, whose alerts fire and are delivered
+// to a webhook.
+//
+// The tests are behind the integration build tag and need no MongoDB:
+//
+//	cd integration && go test -tags=integration ./...
+package integration
@@ -0,0 +1,326 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/internal/router"
+	"github.com/hello-api/internal/service"
+	"github.com/hello-api/pkg/httpclient"
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/money"
+
+	"datafeed/pkg/alert"
+	"datafeed/pkg/auth"
+	"datafeed/pkg/config"
+	"datafeed/pkg/devserver"
+	"datafeed/pkg/forwarder"
+	"datafeed/pkg/orchestrator"
+	"datafeed/pkg/pipeline"
+	"datafeed/pkg/signalr"
+)
+
+// Secrets of the webhook integrations, set for the API under test
+const (
+	datafeedSecret = "integration-datafeed-secret"
+	outboundSecret = "integration-outbound-secret"
+	adminSecret    = "integration-admin-secret"
+)
+
+// startFeed serves the mock exchange feed and returns it with its base URL
+func startFeed(t *testing.T, ctx context.Context) (*devserver.Server, string) {
+	t.Helper()
+	feed, err := devserver.New(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(feed)
+	t.Cleanup(server.Close)
+	return feed, server.URL
+}
+
+// delivery is a webhook request as the receiver got it
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver records every webhook delivered to it
+type webhookReceiver struct {
+	mu         sync.Mutex
+	deliveries []delivery
+	received   chan struct{}
+}
+
+func startWebhookReceiver(t *testing.T) (*webhookReceiver, string) {
+	t.Helper()
+	receiver := &webhookReceiver{received: make(chan struct{}, 16)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		receiver.deliveries = append(receiver.deliveries, delivery{header: r.Header.Clone(), body: body})
+		receiver.mu.Unlock()
+		receiver.received <- struct{}{}
+	}))
+	t.Cleanup(server.Close)
+	return receiver, server.URL
+}
+
+func (r *webhookReceiver) all() []delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]delivery(nil), r.deliveries...)
+}
+
+// startAPI serves the API on the in-memory backend with its notification
+// worker running, and returns its base URL including the version prefix
+func startAPI(t *testing.T, ctx context.Context) string {
+	t.Helper()
+	schedule, err := service.LoadMarketSchedule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tickFilter, err := service.LoadTickFilterConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alertCacheRefresh, err := service.LoadAlertCacheRefresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	feedStaleAfter, err := service.LoadFeedStaleAfter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluationSampling, err := service.LoadEvaluationSamplingConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	alertArchive, err := service.LoadAlertArchiveConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flagEnv, err := service.LoadFeatureFlags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	liveLimits, err := service.LoadLiveLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outboundCfg, err := service.LoadOutboundHTTPConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slowRequests, err := logging.SlowRequestsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outbox := repository.NewMemoryNotificationRepository()
+	workerCfg := service.DefaultNotificationWorkerConfig()
+	workerCfg.PollInterval = 100 * time.Millisecond
+	senders := service.NotificationSenders{
+		dto.NotificationChannelWebhook: service.NewHTTPWebhookSender(service.NewOutboxHTTPClient(outboundCfg)),
+	}
+	go service.NewNotificationWorker(outbox, senders, workerCfg).Run(ctx)
+
+	events := service.NewBroadcaster(liveLimits, service.DefaultSubscriberQueue, service.DefaultEventHistory)
+	handler := router.InitializeRoutes(ctx, router.Config{
+		Logger:                   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		SlowRequests:             slowRequests,
+		Notifications:            outbox,
+		Events:                   events,
+		Schedule:                 schedule,
+		TickFilter:               tickFilter,
+		AlertCacheRefresh:        alertCacheRefresh,
+		FeedStaleAfter:           feedStaleAfter,
+		EvaluationSampling:       evaluationSampling,
+		AlertArchive:             alertArchive,
+		FlagEnv:                  flagEnv,
+		AlertDates:               service.DefaultAlertDateBounds(),
+		EvaluatorDebugMaxEntries: service.DefaultEvaluatorDebugMaxEntries,
+	})
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL + router.APIVersionPrefix
+}
+
+// call sends a JSON request to the API, signed with secret unless it is
+// empty, and decodes the data of the response envelope into out
+func call(t *testing.T, method, url, secret string, in, out interface{}) {
+	t.Helper()
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(common.SignatureTimestampHeader, timestamp)
+		req.Header.Set(common.SignatureHeader, "sha256="+common.SignPayload([]byte(secret), timestamp, body))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		t.Fatalf("%s %s: got %s %s", method, url, resp.Status, raw)
+	}
+	envelope := struct {
+		Data interface{} `json:"data"`
+	}{Data: out}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		t.Fatalf("%s %s: undecodable body %q: %v", method, url, raw, err)
+	}
+}
+
+// A price pushed by the mock exchange feed crosses an alert created over HTTP:
+// the data feed, wired as main wires it with api_url set, forwards it, the API
+// marks the alert triggered, and the alert's webhook gets exactly one delivery
+// signed with the outbound secret
+func TestPriceReachesWebhook(t *testing.T) {
+	t.Setenv("DB_BACKEND", "memory")
+	t.Setenv("SYMBOL_VALIDATION", "off")
+	t.Setenv("WEBHOOK_SECRET_DATAFEED", datafeedSecret)
+	t.Setenv("WEBHOOK_SECRET_OUTBOUND", outboundSecret)
+	t.Setenv("WEBHOOK_SECRET_ADMIN", adminSecret)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	receiver, webhookURL := startWebhookReceiver(t)
+	api := startAPI(t, ctx)
+
+	var user dto.UserResponse
+	call(t, http.MethodPost, api+"/users", "", dto.UserCreateRequest{UserID: "alice", Name: "Alice", Email: "alice@example.com"}, &user)
+	var created dto.AlertResponse
+	call(t, http.MethodPost, api+"/alerts", "", map[string]interface{}{
+		"name": "GP above 100", "userId": user.UserID, "symbol": "GP", "rule": "above", "price": "100",
+		"status": "active", "evaluateOffHours": true, "webhookUrl": webhookURL,
+	}, &created)
+	// Creating an alert reloads the index in the background; reload it now so
+	// the price cannot arrive first
+	call(t, http.MethodPost, api+"/admin/debug/evaluator/resync", adminSecret, nil, nil)
+	var index dto.EvaluatorIndexResponse
+	call(t, http.MethodGet, api+"/admin/debug/evaluator/GP", adminSecret, nil, &index)
+	if index.Total != 1 {
+		t.Fatalf("the evaluator watches %d alerts for GP, want 1", index.Total)
+	}
+
+	feed, feedURL := startFeed(t, ctx)
+	cfg := &config.Config{
+		LoginURL:   feedURL + devserver.LoginPath,
+		SignalRURL: feedURL + devserver.HubPath,
+		APIURL:     api,
+		APISecret:  datafeedSecret,
+	}
+	token, err := auth.LoginContext(ctx, cfg)
+	if err != nil {
+		t.Fatalf("log in to the feed: %v", err)
+	}
+	client := signalr.NewClient(cfg, token)
+	t.Cleanup(client.Close)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("connect to the feed: %v", err)
+	}
+	processor := signalr.NewMessageProcessor()
+	processor.SetTransferFormat(client.TransferFormat())
+	prices := forwarder.NewStream(forwarder.New(cfg.APIURL, cfg.APISecret, httpclient.New(cfg.HTTPClient())), 0, 100*time.Millisecond)
+	flow := orchestrator.New(client, processor, alert.NewEvaluator(nil), alert.NewLogNotifier(), orchestrator.Config{
+		Messages:  pipeline.Config{Name: "integration"},
+		Forwarder: prices,
+	})
+	flow.Start()
+	t.Cleanup(func() { flow.Stop(context.Background()) })
+
+	select {
+	case <-feed.Subscribed():
+	case <-ctx.Done():
+		t.Fatal("the data feed never subscribed to the feed")
+	}
+	feed.SendSharePrice(devserver.Price{Symbol: "GP", Price: 101.5, Volume: 1000})
+
+	for prices.Stats().Forwarded == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("no price was forwarded: %+v", prices.Stats())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if stats := prices.Stats(); stats.Forwarded != 1 || stats.Failed != 0 {
+		t.Fatalf("got %+v, want one price forwarded", stats)
+	}
+
+	var stored dto.AlertResponse
+	call(t, http.MethodGet, api+"/alerts/"+created.ID, "", nil, &stored)
+	if !stored.Triggered || stored.LastTriggeredAt == nil {
+		t.Errorf("got triggered %t at %v, want the alert triggered", stored.Triggered, stored.LastTriggeredAt)
+	}
+
+	select {
+	case <-receiver.received:
+	case <-ctx.Done():
+		t.Fatal("the webhook got no delivery")
+	}
+	// Several worker polls pass without a second delivery
+	time.Sleep(time.Second)
+	deliveries := receiver.all()
+	if len(deliveries) != 1 {
+		t.Fatalf("the webhook got %d deliveries, want 1", len(deliveries))
+	}
+	got := deliveries[0]
+	timestamp := got.header.Get(common.SignatureTimestampHeader)
+	if want := "sha256=" + common.SignPayload([]byte(outboundSecret), timestamp, got.body); got.header.Get(common.SignatureHeader) != want {
+		t.Errorf("got signature %q over timestamp %q, want %q", got.header.Get(common.SignatureHeader), timestamp, want)
+	}
+	var payload struct {
+		Event   string       `json:"event"`
+		AlertID string       `json:"alertId"`
+		Symbol  string       `json:"symbol"`
+		Price   money.Amount `json:"price"`
+	}
+	if err := json.Unmarshal(got.body, &payload); err != nil {
+		t.Fatalf("undecodable payload %q: %v", got.body, err)
+	}
+	if payload.Event != service.EventAlertTriggered || payload.AlertID != created.ID || payload.Symbol != "GP" || payload.Price != 10150 {
+		t.Errorf("got payload %s, want GP at 101.50 for alert %s", got.body, created.ID)
+	}
+
+	// The delivery is listed for admins only
+	resp, err := http.Get(api + "/notifications?status=delivered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unsigned GET /notifications got %s, want 401", resp.Status)
+	}
+	var delivered []dto.NotificationResponse
+	call(t, http.MethodGet, api+"/notifications?status=delivered", adminSecret, nil, &delivered)
+	if len(delivered) != 1 || delivered[0].AlertID != created.ID {
+		t.Errorf("got %+v delivered, want the one delivery of alert %s", delivered, created.ID)
+	}
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// APIKeyHeader carries the API key of a client
+const APIKeyHeader = "X-API-Key"
+
+// APIKey is a configured client key and the scopes it grants
+type APIKey struct {
+	Client string
+	Scopes []string
+}
+
+// HasScope reports whether the key grants scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupAPIKey finds the client owning key. Each client is configured with
+// API_KEY_<CLIENT> holding its key and API_KEY_<CLIENT>_SCOPES holding a
+// comma separated list of scopes (e.g. "alerts:read").
+func LookupAPIKey(key string) (APIKey, bool) {
+	if key == "" {
+		return APIKey{}, false
+	}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(name, "API_KEY_") || strings.HasSuffix(name, "_SCOPES") || value == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(key), []byte(value)) != 1 {
+			continue
+		}
+		found := APIKey{Client: strings.ToLower(strings.TrimPrefix(name, "API_KEY_"))}
+		for _, scope := range strings.Split(os.Getenv(name+"_SCOPES"), ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				found.Scopes = append(found.Scopes, scope)
+			}
+		}
+		return found, true
+	}
+	return APIKey{}, false
+}
+
+// RequireScope is a middleware admitting requests whose X-API-Key grants scope.
+// Unknown keys are rejected with 401 and keys without the scope with 403. Keys
+// are read per request so rotated keys apply without a restart.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := LookupAPIKey(r.Header.Get(APIKeyHeader))
+			if !ok {
+				RespondWithError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Missing or unknown API key")
+				return
+			}
+			if !key.HasScope(scope) {
+				RespondWithError(w, http.StatusForbidden, "FORBIDDEN", "API key lacks the "+scope+" scope")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package common
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 	"net/http"
 
 	"github.com/hello-api/internal/domain"
@@ -21,7 +23,9 @@ func ErrorHandler(next http.Handler) http.Handler {
 func HandleError(w http.ResponseWriter, err error) {
 	var code, message string
 	switch {
-	case errors.Is(err, domain.ErrUserNotFound):
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrAlertNotFound),
+		errors.Is(err, domain.ErrHolidayNotFound), errors.Is(err, domain.ErrTickNotFound),
+		errors.Is(err, domain.ErrPriceNotFound), errors.Is(err, domain.ErrFlagNotFound):
 		code = "NOT_FOUND"
 		message = getCustomOrDefaultMessage(err, "Resource not found")
 		RespondWithError(w, http.StatusNotFound, code, message)
@@ -33,6 +37,14 @@ func HandleError(w http.ResponseWriter, err error) {
 		code = "USER_ALREADY_EXISTS"
 		message = getCustomOrDefaultMessage(err, "User already exists")
 		RespondWithError(w, http.StatusConflict, code, message)
+	case errors.Is(err, domain.ErrEmailAlreadyExists):
+		code = "EMAIL_ALREADY_EXISTS"
+		message = getCustomOrDefaultMessage(err, "Email already exists")
+		RespondWithError(w, http.StatusConflict, code, message)
+	case errors.Is(err, domain.ErrDuplicate):
+		code = "DUPLICATE"
+		message = getCustomOrDefaultMessage(err, "Duplicate value")
+		RespondWithError(w, http.StatusConflict, code, message)
 	case errors.Is(err, domain.ErrUnauthorized):
 		code = "UNAUTHORIZED"
 		message = getCustomOrDefaultMessage(err, "Unauthorized access")
@@ -41,9 +53,17 @@ func HandleError(w http.ResponseWriter, err error) {
 		code = "FORBIDDEN"
 		message = getCustomOrDefaultMessage(err, "Access forbidden")
 		RespondWithError(w, http.StatusForbidden, code, message)
+	case errors.Is(err, domain.ErrDependencyUnavailable):
+		code = "SERVICE_UNAVAILABLE"
+		message = getCustomOrDefaultMessage(err, "Service temporarily unavailable")
+		RespondWithError(w, http.StatusServiceUnavailable, code, message)
+	case errors.Is(err, context.DeadlineExceeded):
+		code = "TIMEOUT"
+		message = "The request took too long to complete"
+		RespondWithError(w, http.StatusGatewayTimeout, code, message)
 	default:
 		// Log the actual error for debugging
-		// logger.Error("Unexpected error", "error", err)
+		slog.Error("Unexpected error", "error", err)
 		code = "INTERNAL_ERROR"
 		message = getCustomOrDefaultMessage(err, "An unexpected error occurred")
 		RespondWithError(w, http.StatusInternalServerError, code, message)
@@ -52,12 +72,19 @@ func HandleError(w http.ResponseWriter, err error) {
 
 // Generalized error message mapping for domain errors
 var errorMessageMap = map[error]string{
-	domain.ErrUserNotFound:    "Resource not found",
-	domain.ErrValidation:      "Validation error",
-	domain.ErrUserAlreadyExit: "User already exists",
-	domain.ErrUnauthorized:    "Unauthorized access",
-	domain.ErrForbidden:       "Access forbidden",
-	domain.ErrInternal:        "An unexpected error occurred",
+	domain.ErrUserNotFound:          "User not found",
+	domain.ErrAlertNotFound:         "Alert not found",
+	domain.ErrHolidayNotFound:       "Holiday not found",
+	domain.ErrTickNotFound:          "Quarantined tick not found",
+	domain.ErrPriceNotFound:         "Price not found",
+	domain.ErrValidation:            "Validation error",
+	domain.ErrUserAlreadyExit:       "User already exists",
+	domain.ErrEmailAlreadyExists:    "Email already exists",
+	domain.ErrDuplicate:             "Duplicate value",
+	domain.ErrUnauthorized:          "Unauthorized access",
+	domain.ErrForbidden:             "Access forbidden",
+	domain.ErrDependencyUnavailable: "Service temporarily unavailable",
+	domain.ErrInternal:              "An unexpected error occurred",
 }
 
 // getCustomOrDefaultMessage returns the custom error message if it differs from the base error, otherwise returns the default from the map
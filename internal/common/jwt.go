@@ -0,0 +1,120 @@
+package common
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+)
+
+// MinJWTSecretLength is the shortest JWT_SECRET the self-check accepts, the
+// 256 bits of an HS256 key
+const MinJWTSecretLength = 32
+
+// CheckJWTSecret reports whether JWT_SECRET is set and at least MinJWTSecretLength bytes
+func CheckJWTSecret() error {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return errors.New("JWT_SECRET is not set, token authentication is off")
+	}
+	if len(secret) < MinJWTSecretLength {
+		return fmt.Errorf("JWT_SECRET is %d bytes, use at least %d", len(secret), MinJWTSecretLength)
+	}
+	return nil
+}
+
+// jwtClaims are the registered claims the API relies on
+type jwtClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+}
+
+// VerifyJWT checks an HS256 token signed with JWT_SECRET and returns its subject,
+// the user id. Tokens must carry an expiry. Every failure wraps domain.ErrUnauthorized.
+func VerifyJWT(token string) (string, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return "", fmt.Errorf("token authentication is not configured: %w", domain.ErrUnauthorized)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed token: %w", domain.ErrUnauthorized)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return "", fmt.Errorf("unsupported token header: %w", domain.ErrUnauthorized)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return "", fmt.Errorf("invalid token signature: %w", domain.ErrUnauthorized)
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("malformed token claims: %w", domain.ErrUnauthorized)
+	}
+	now := time.Now().Unix()
+	if claims.ExpiresAt == 0 || now >= claims.ExpiresAt {
+		return "", fmt.Errorf("token expired: %w", domain.ErrUnauthorized)
+	}
+	if claims.NotBefore != 0 && now < claims.NotBefore {
+		return "", fmt.Errorf("token not yet valid: %w", domain.ErrUnauthorized)
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("token has no subject: %w", domain.ErrUnauthorized)
+	}
+	return claims.Subject, nil
+}
+
+// userIDKey is the context key of the authenticated user id
+type userIDKey struct{}
+
+// RequireUser is a middleware admitting requests with a valid
+// "Authorization: Bearer <jwt>" header. The token subject is available to
+// handlers through UserIDFromContext.
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			HandleError(w, fmt.Errorf("missing bearer token: %w", domain.ErrUnauthorized))
+			return
+		}
+		userID, err := VerifyJWT(strings.TrimSpace(token))
+		if err != nil {
+			HandleError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDKey{}, userID)))
+	})
+}
+
+// UserIDFromContext returns the user authenticated by RequireUser, or ""
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
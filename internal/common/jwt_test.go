@@ -0,0 +1,31 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+// The self-check wants a JWT_SECRET of at least 32 bytes
+func TestCheckJWTSecret(t *testing.T) {
+	for _, tc := range []struct {
+		secret  string
+		wantErr string
+	}{
+		{secret: "", wantErr: "not set"},
+		{secret: "too-short", wantErr: "is 9 bytes, use at least 32"},
+		{secret: strings.Repeat("s", MinJWTSecretLength-1), wantErr: "is 31 bytes"},
+		{secret: strings.Repeat("s", MinJWTSecretLength)},
+	} {
+		t.Setenv("JWT_SECRET", tc.secret)
+		err := CheckJWTSecret()
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Errorf("%d bytes: got %v, want it accepted", len(tc.secret), err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%d bytes: got %v, want %q", len(tc.secret), err, tc.wantErr)
+		}
+	}
+}
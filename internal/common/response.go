@@ -1,8 +1,14 @@
 package common
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
+
+	"github.com/hello-api/pkg/money"
 )
 
 // Response represents a standard API response structure
@@ -49,9 +55,32 @@ func RespondWithError(w http.ResponseWriter, statusCode int, code string, messag
 	RespondWithJSON(w, statusCode, response)
 }
 
-// RespondWithJSON sends a JSON response with given status code
+// RequestFormatMessage explains a request body that failed to decode. A price
+// with more than two decimal places is valid JSON, so it is named.
+func RequestFormatMessage(err error) string {
+	if errors.Is(err, money.ErrPrecision) {
+		return err.Error()
+	}
+	return "Invalid request format"
+}
+
+// encodeFailedBody is sent when a response cannot be encoded; it is static so
+// that it cannot fail itself
+const encodeFailedBody = `{"success":false,"error":{"code":"INTERNAL_ERROR","message":"Failed to encode response"}}` + "\n"
+
+// RespondWithJSON sends a JSON response with given status code. The body is
+// encoded before anything is written, so a value that cannot be encoded turns
+// into a clean 500 rather than a truncated body behind the intended status.
 func RespondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(data); err != nil {
+		slog.Error("Failed to encode response", "status", statusCode, "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, encodeFailedBody)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(data)
+	w.Write(body.Bytes())
 }
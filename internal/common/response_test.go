@@ -0,0 +1,50 @@
+package common
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// A value JSON cannot encode turns into a clean 500 with INTERNAL_ERROR rather
+// than a truncated body behind the intended status
+func TestRespondWithJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     interface{}
+		wantCode int
+		wantErr  string
+	}{
+		{name: "encodable", data: map[string]int{"count": 3}, wantCode: http.StatusOK},
+		{name: "NaN", data: math.NaN(), wantCode: http.StatusInternalServerError, wantErr: "INTERNAL_ERROR"},
+		{name: "channel", data: make(chan int), wantCode: http.StatusInternalServerError, wantErr: "INTERNAL_ERROR"},
+		{name: "nested NaN", data: []float64{1, math.Inf(1)}, wantCode: http.StatusInternalServerError, wantErr: "INTERNAL_ERROR"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			RespondWithSuccess(rec, http.StatusOK, tc.data)
+
+			if rec.Code != tc.wantCode {
+				t.Errorf("got status %d, want %d", rec.Code, tc.wantCode)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("got Content-Type %q, want application/json", got)
+			}
+			var response Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("undecodable body %q: %v", rec.Body.String(), err)
+			}
+			if tc.wantErr == "" {
+				if !response.Success || response.Error != nil {
+					t.Errorf("got %+v, want a success", response)
+				}
+				return
+			}
+			if response.Success || response.Error == nil || response.Error.Code != tc.wantErr {
+				t.Errorf("got %+v, want error %s", response, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package common
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Only requests signed with the integration's secret within the tolerance
+// window reach the handler, and the handler still reads the whole body
+func TestVerifySignature(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET_TESTHOOK", "s3cret")
+	body := []byte(`{"symbol":"GP","price":101.5}`)
+	oversized := bytes.Repeat([]byte("a"), maxSignedBodyBytes+1)
+
+	for _, tc := range []struct {
+		name        string
+		integration string
+		body        []byte
+		// age is how long before now the request was signed; negative is ahead
+		age         time.Duration
+		timestamp   string // overrides the one derived from age
+		signSecret  string
+		signBody    []byte // defaults to body
+		noSignature bool
+		wantCode    int
+	}{
+		{name: "valid", body: body, wantCode: http.StatusOK},
+		{name: "valid inside the window", body: body, age: DefaultSignatureTolerance - time.Minute, wantCode: http.StatusOK},
+		{name: "no secret configured", integration: "unknown", body: body, wantCode: http.StatusUnauthorized},
+		{name: "missing signature header", body: body, noSignature: true, wantCode: http.StatusUnauthorized},
+		{name: "missing timestamp header", body: body, timestamp: "-", wantCode: http.StatusUnauthorized},
+		{name: "malformed timestamp", body: body, timestamp: "yesterday", wantCode: http.StatusUnauthorized},
+		{name: "wrong HMAC", body: body, signBody: []byte(`{"symbol":"GP","price":1}`), wantCode: http.StatusUnauthorized},
+		{name: "signed with another secret", body: body, signSecret: "other", wantCode: http.StatusUnauthorized},
+		{name: "timestamp too old", body: body, age: DefaultSignatureTolerance + time.Minute, wantCode: http.StatusUnauthorized},
+		{name: "timestamp too far ahead", body: body, age: -DefaultSignatureTolerance - time.Minute, wantCode: http.StatusUnauthorized},
+		{name: "body over the cap", body: oversized, wantCode: http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			integration, secret, signBody := tc.integration, tc.signSecret, tc.signBody
+			if integration == "" {
+				integration = "testhook"
+			}
+			if secret == "" {
+				secret = "s3cret"
+			}
+			if signBody == nil {
+				signBody = tc.body
+			}
+			timestamp := strconv.FormatInt(time.Now().Add(-tc.age).Unix(), 10)
+			if tc.timestamp != "" {
+				timestamp = tc.timestamp
+			}
+
+			var received []byte
+			called := false
+			handler := VerifySignature(integration, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				received, _ = io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/v1/prices", bytes.NewReader(tc.body))
+			if timestamp != "-" {
+				req.Header.Set(SignatureTimestampHeader, timestamp)
+			}
+			if !tc.noSignature {
+				req.Header.Set(SignatureHeader, "sha256="+SignPayload([]byte(secret), timestamp, signBody))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("got status %d (%s), want %d", rec.Code, rec.Body.String(), tc.wantCode)
+			}
+			if wantCalled := tc.wantCode == http.StatusOK; called != wantCalled {
+				t.Fatalf("got handler called %v, want %v", called, wantCalled)
+			}
+			if called && !bytes.Equal(received, tc.body) {
+				t.Errorf("handler read %q, want %q", received, tc.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// DefaultRequestTimeout bounds the work of a request unless its route sets another budget
+	DefaultRequestTimeout = 10 * time.Second
+	// LongRequestTimeout is the budget of slow routes such as admin listings
+	LongRequestTimeout = 30 * time.Second
+)
+
+// RouteTimeouts sets a deadline on the context of each request, by matched
+// route. Repositories give up once it passes and HandleError answers with a
+// TIMEOUT envelope, before the server's WriteTimeout drops the connection
+// without a body. Routes are configured while the router is built.
+type RouteTimeouts struct {
+	fallback time.Duration
+	routes   map[*mux.Route]time.Duration
+}
+
+// NewRouteTimeouts returns timeouts applying fallback to routes not configured otherwise
+func NewRouteTimeouts(fallback time.Duration) *RouteTimeouts {
+	return &RouteTimeouts{fallback: fallback, routes: make(map[*mux.Route]time.Duration)}
+}
+
+// Set gives routes a budget of timeout
+func (t *RouteTimeouts) Set(timeout time.Duration, routes ...*mux.Route) {
+	for _, route := range routes {
+		t.routes[route] = timeout
+	}
+}
+
+// Exempt leaves routes without a deadline, e.g. WebSocket and other streaming
+// endpoints that bound their own lifetime
+func (t *RouteTimeouts) Exempt(routes ...*mux.Route) {
+	t.Set(0, routes...)
+}
+
+// Middleware applies the budget of the matched route
+func (t *RouteTimeouts) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := t.fallback
+		if route := mux.CurrentRoute(r); route != nil {
+			if configured, ok := t.routes[route]; ok {
+				timeout = configured
+			}
+		}
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
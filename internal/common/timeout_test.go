@@ -0,0 +1,71 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Each route gets the budget it was configured with, the fallback otherwise,
+// and exempt routes no deadline at all
+func TestRouteTimeouts(t *testing.T) {
+	const fallback = 50 * time.Millisecond
+	r := mux.NewRouter()
+	timeouts := NewRouteTimeouts(fallback)
+	r.Use(timeouts.Middleware)
+
+	// budget reports the time left on the request context, or 0 without a deadline
+	budget := func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok := r.Context().Deadline()
+		if !ok {
+			w.Header().Set("X-Budget", "none")
+			return
+		}
+		w.Header().Set("X-Budget", time.Until(deadline).Round(time.Second).String())
+	}
+	r.HandleFunc("/default", budget)
+	timeouts.Set(LongRequestTimeout, r.HandleFunc("/long", budget))
+	timeouts.Exempt(r.HandleFunc("/stream", budget))
+	// slow works until its deadline passes, as a repository call would
+	r.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		HandleError(w, r.Context().Err())
+	})
+
+	for _, tc := range []struct {
+		path       string
+		wantBudget string
+		wantCode   int
+		wantErr    string
+	}{
+		{path: "/default", wantBudget: "0s", wantCode: http.StatusOK},
+		{path: "/long", wantBudget: LongRequestTimeout.String(), wantCode: http.StatusOK},
+		{path: "/stream", wantBudget: "none", wantCode: http.StatusOK},
+		{path: "/slow", wantCode: http.StatusGatewayTimeout, wantErr: "TIMEOUT"},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.wantCode {
+				t.Fatalf("got status %d (%s), want %d", rec.Code, rec.Body.String(), tc.wantCode)
+			}
+			if got := rec.Header().Get("X-Budget"); got != tc.wantBudget {
+				t.Errorf("got budget %q, want %q", got, tc.wantBudget)
+			}
+			if tc.wantErr == "" {
+				return
+			}
+			var response Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("undecodable body %q: %v", rec.Body.String(), err)
+			}
+			if response.Error == nil || response.Error.Code != tc.wantErr {
+				t.Errorf("got %+v, want error %s", response, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Package timeutil holds the API's time conventions: instants are stored and
+// returned in UTC, and converted to a local timezone only to find a trading
+// date or to render a time for people.
+package timeutil
+
+import (
+	"fmt"
+	"time"
+	// Embedded zone data, so timezones resolve in minimal images
+	_ "time/tzdata"
+)
+
+// DateLayout is the form of trading dates and holiday dates, e.g. "2024-01-02"
+const DateLayout = "2006-01-02"
+
+// Dhaka is the timezone of the Dhaka Stock Exchange. Bangladesh has no DST,
+// so the fixed +06:00 fallback is only used when zone data is missing.
+var Dhaka = loadDhaka()
+
+func loadDhaka() *time.Location {
+	location, err := time.LoadLocation("Asia/Dhaka")
+	if err != nil {
+		return time.FixedZone("+06", 6*60*60)
+	}
+	return location
+}
+
+// UTC returns t in UTC; the zero time stays zero
+func UTC(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC()
+}
+
+// TradingDate returns the date (YYYY-MM-DD) of an instant on the wall clock of
+// location, e.g. 2024-01-01T20:00:00Z is 2024-01-02 in Dhaka
+func TradingDate(at time.Time, location *time.Location) string {
+	return at.In(location).Format(DateLayout)
+}
+
+// StartOfDay returns midnight of the day containing at on the wall clock of location
+func StartOfDay(at time.Time, location *time.Location) time.Time {
+	local := at.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+}
+
+// ParseDate parses a YYYY-MM-DD date as midnight in location
+func ParseDate(date string, location *time.Location) (time.Time, error) {
+	parsed, err := time.ParseInLocation(DateLayout, date, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date must be YYYY-MM-DD, got %q", date)
+	}
+	return parsed, nil
+}
+
+// LoadLocation resolves an IANA timezone name; an empty name is fallback
+func LoadLocation(name string, fallback *time.Location) (*time.Location, error) {
+	if name == "" {
+		return fallback, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// Display returns the location a time is rendered in for a person: their
+// timezone when set and known, otherwise fallback
+func Display(name string, fallback *time.Location) *time.Location {
+	location, err := LoadLocation(name, fallback)
+	if err != nil || location == nil {
+		return fallback
+	}
+	return location
+}
@@ -0,0 +1,100 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+// Instants given with any offset are the same instant in UTC
+func TestUTC(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{input: "2024-03-04T10:00:00+06:00", want: "2024-03-04T04:00:00Z"},
+		{input: "2024-03-04T01:30:00+05:30", want: "2024-03-03T20:00:00Z"},
+		{input: "2024-03-04T22:00:00-05:00", want: "2024-03-05T03:00:00Z"},
+		{input: "2024-03-04T04:00:00Z", want: "2024-03-04T04:00:00Z"},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			parsed, err := time.Parse(time.RFC3339, tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := UTC(parsed)
+			if got.Location() != time.UTC || got.Format(time.RFC3339) != tc.want {
+				t.Errorf("got %v, want %s", got, tc.want)
+			}
+		})
+	}
+	if got := UTC(time.Time{}); !got.IsZero() {
+		t.Errorf("got %v for the zero time, want it to stay zero", got)
+	}
+}
+
+// Dhaka is six hours ahead all year round, so its date turns at 18:00 UTC in
+// winter and summer alike
+func TestTradingDate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{name: "before local midnight", at: time.Date(2024, 1, 1, 17, 59, 59, 0, time.UTC), want: "2024-01-01"},
+		{name: "local midnight", at: time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC), want: "2024-01-02"},
+		{name: "summer", at: time.Date(2024, 7, 1, 18, 0, 0, 0, time.UTC), want: "2024-07-02"},
+		{name: "new year", at: time.Date(2024, 12, 31, 18, 30, 0, 0, time.UTC), want: "2025-01-01"},
+		{name: "offset input", at: time.Date(2024, 1, 1, 13, 0, 0, 0, time.FixedZone("-05", -5*60*60)), want: "2024-01-02"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := TradingDate(tc.at, Dhaka); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// A Dhaka day runs from 18:00 UTC the day before, whatever zone the instant is in
+func TestStartOfDay(t *testing.T) {
+	want := time.Date(2024, 3, 3, 18, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{
+		time.Date(2024, 3, 3, 18, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 4, 17, 59, 0, 0, time.UTC),
+		time.Date(2024, 3, 4, 8, 0, 0, 0, time.FixedZone("+05:30", 5*60*60+30*60)),
+	} {
+		if got := StartOfDay(at, Dhaka); !got.Equal(want) {
+			t.Errorf("start of the day of %v is %v, want %v", at, got.UTC(), want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	got, err := ParseDate("2024-03-04", Dhaka)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 3, 18, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got.UTC(), want)
+	}
+	for _, date := range []string{"", "04-03-2024", "2024-02-30", "2024-03-04T00:00:00Z"} {
+		if _, err := ParseDate(date, Dhaka); err == nil {
+			t.Errorf("parsed %q, want an error", date)
+		}
+	}
+}
+
+// A person's timezone is used when it is known; an empty or unknown name falls back
+func TestDisplay(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want string
+	}{
+		{name: "Asia/Kolkata", want: "Asia/Kolkata"},
+		{name: "", want: "UTC"},
+		{name: "Mars/Olympus_Mons", want: "UTC"},
+	} {
+		if got := Display(tc.name, time.UTC); got.String() != tc.want {
+			t.Errorf("Display(%q) is %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+)
+
+// SelfCheckCollection is the scratch collection the self-check writes to
+const SelfCheckCollection = "self_check"
+
+// MissingIndexes lists the registered indexes that do not exist in database, as
+// "collection.index". uniqueEmail adds the unique email index. It only looks;
+// EnsureIndexes creates them.
+func MissingIndexes(ctx context.Context, database *mongodriver.Database, uniqueEmail bool) ([]string, error) {
+	var missing []string
+	for _, spec := range collections {
+		expected := make([]string, 0, len(spec.Indexes)+1)
+		for _, index := range spec.Indexes {
+			expected = append(expected, indexName(index))
+		}
+		if uniqueEmail && spec.Name == UsersCollection {
+			expected = append(expected, UserEmailIndex)
+		}
+		if len(expected) == 0 {
+			continue
+		}
+
+		indexes, err := database.Collection(spec.Name).Indexes().ListSpecifications(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list indexes of %s: %w", spec.Name, err)
+		}
+		existing := make(map[string]bool, len(indexes))
+		for _, index := range indexes {
+			existing[index.Name] = true
+		}
+		for _, name := range expected {
+			if !existing[name] {
+				missing = append(missing, spec.Name+"."+name)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// indexName is the name MongoDB gives an index: its own, or its keys and
+// directions joined with underscores, e.g. "status_1_stopDate_1"
+func indexName(index mongodriver.IndexModel) string {
+	if index.Options != nil && index.Options.Name != nil {
+		return *index.Options.Name
+	}
+	keys, _ := index.Keys.(bson.D)
+	parts := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		parts = append(parts, key.Key, fmt.Sprint(key.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// RoundTrip writes a document to the scratch collection, reads it back and
+// deletes it, to prove the credentials allow writes and reads
+func RoundTrip(ctx context.Context, database *mongodriver.Database) error {
+	collection := database.Collection(SelfCheckCollection)
+	id := primitive.NewObjectID()
+	if _, err := collection.InsertOne(ctx, bson.M{"_id": id, "checkedAt": time.Now().UTC()}); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", SelfCheckCollection, err)
+	}
+	var read bson.M
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&read); err != nil {
+		return fmt.Errorf("failed to read back from %s: %w", SelfCheckCollection, err)
+	}
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("failed to delete from %s: %w", SelfCheckCollection, err)
+	}
+	if result.DeletedCount != 1 {
+		return fmt.Errorf("deleted %d documents from %s, expected 1", result.DeletedCount, SelfCheckCollection)
+	}
+	return nil
+}
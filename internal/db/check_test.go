@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Unnamed indexes get MongoDB's default name, their keys and directions or
+// types joined with underscores; a set name is kept
+func TestIndexName(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		index mongodriver.IndexModel
+		want  string
+	}{
+		{name: "one key", index: mongodriver.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}}}, want: "userId_1"},
+		{name: "descending", index: mongodriver.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "archivedAt", Value: -1}}}, want: "userId_1_archivedAt_-1"},
+		{name: "int32 and int64", index: mongodriver.IndexModel{Keys: bson.D{{Key: "status", Value: int32(1)}, {Key: "stopDate", Value: int64(-1)}}}, want: "status_1_stopDate_-1"},
+		{name: "text", index: mongodriver.IndexModel{Keys: bson.D{{Key: "name", Value: "text"}}}, want: "name_text"},
+		{name: "options without a name", index: mongodriver.IndexModel{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)}, want: "expiresAt_1"},
+		{name: "named", index: mongodriver.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetName(UserEmailIndex)}, want: UserEmailIndex},
+	} {
+		if got := indexName(tc.index); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// The self-check can name every registered index: keys are ordered, and no two
+// indexes of a collection share a name
+func TestRegisteredIndexNames(t *testing.T) {
+	for _, spec := range collections {
+		seen := make(map[string]bool)
+		for _, index := range spec.Indexes {
+			if _, ok := index.Keys.(bson.D); !ok {
+				t.Errorf("%s: index keys %v are not a bson.D, so their order and name are unknown", spec.Name, index.Keys)
+				continue
+			}
+			name := indexName(index)
+			if name == "" || seen[name] || name == UserEmailIndex {
+				t.Errorf("%s: index name %q is empty or taken", spec.Name, name)
+			}
+			seen[name] = true
+		}
+	}
+
+	alerts, _ := lookupCollection(AlertsCollection)
+	var names []string
+	for _, index := range alerts.Indexes {
+		names = append(names, indexName(index))
+	}
+	if want := []string{"userId_1", "status_1", "status_1_stopDate_1"}; len(names) != len(want) || names[0] != want[0] || names[1] != want[1] || names[2] != want[2] {
+		t.Errorf("got alert indexes %v, want %v", names, want)
+	}
+}
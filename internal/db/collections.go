@@ -0,0 +1,344 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// Collection names. Use the accessors below rather than these names directly.
+const (
+	UsersCollection         = "users"
+	AlertsCollection        = "alerts"
+	AlertsArchiveCollection = "alerts_archive"
+	AlertEventsCollection   = "alert_events"
+	PriceTicksCollection    = "price_ticks"
+
+	NotificationOutboxCollection   = "notification_outbox"
+	MarketHolidaysCollection       = "market_holidays"
+	QuarantinedTicksCollection     = "quarantined_ticks"
+	LatestPricesCollection         = "latest_prices"
+	AlertChangesCollection         = "alert_changes"
+	CountersCollection             = "counters"
+	TelegramLinkCodesCollection    = "telegram_link_codes"
+	EmailDigestItemsCollection     = "email_digest_items"
+	NotificationThrottleCollection = "notification_throttle"
+	AlertEvaluationsCollection     = "alert_evaluations"
+	EvaluationSamplingCollection   = "evaluation_sampling"
+	SymbolsCollection              = "symbols"
+	FeatureFlagsCollection         = "feature_flags"
+)
+
+// CollectionSpec describes a collection's default concerns and indexes
+type CollectionSpec struct {
+	Name           string
+	WriteConcern   *writeconcern.WriteConcern
+	ReadPreference *readpref.ReadPref
+	Indexes        []mongodriver.IndexModel
+	// CappedSizeBytes creates the collection capped at this size; zero leaves it uncapped
+	CappedSizeBytes int64
+}
+
+// collections is the registry of every application collection
+var collections = []CollectionSpec{
+	{
+		Name:           UsersCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+	},
+	{
+		Name:           AlertsCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+		Indexes: []mongodriver.IndexModel{
+			{Keys: bson.D{{Key: "userId", Value: 1}}},
+			// The evaluation index reloads every active alert
+			{Keys: bson.D{{Key: "status", Value: 1}}},
+			// The archival job looks for inactive alerts past their stop date
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "stopDate", Value: 1}}},
+		},
+	},
+	{
+		// Fired alerts past their stop date, moved out of the live collection
+		Name:           AlertsArchiveCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+		Indexes: []mongodriver.IndexModel{
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "archivedAt", Value: -1}}},
+		},
+	},
+	{
+		Name:           AlertEventsCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+		Indexes: []mongodriver.IndexModel{
+			{Keys: bson.D{{Key: "alertId", Value: 1}, {Key: "triggeredAt", Value: -1}}},
+		},
+	},
+	{
+		// Ticks are high volume and replaceable, a single acknowledgement is enough
+		Name:           PriceTicksCollection,
+		WriteConcern:   writeconcern.W1(),
+		ReadPreference: readpref.SecondaryPreferred(),
+		Indexes: []mongodriver.IndexModel{
+			{Keys: bson.D{{Key: "symbol", Value: 1}, {Key: "time", Value: -1}}},
+		},
+	},
+	{
+		// Claims must see every committed delivery state change
+		Name:           NotificationOutboxCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+		Indexes: []mongodriver.IndexModel{
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+			{Keys: bson.D{{Key: "alertId", Value: 1}, {Key: "created_at", Value: -1}}},
+			// A trigger redelivered by the feed is queued only once per channel
+			{
+				Keys:    bson.D{{Key: "alertId", Value: 1}, {Key: "triggerId", Value: 1}, {Key: "channel", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	},
+	{
+		Name:           QuarantinedTicksCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+		Indexes: []mongodriver.IndexModel{
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		},
+	},
+	{
+		// Keyed by symbol and rewritten on every tick. Reads go to the primary so
+		// the day rollover and the deviation check see the previous write.
+		Name:           LatestPricesCollection,
+		WriteConcern:   writeconcern.W1(),
+		ReadPreference: readpref.Primary(),
+	},
+	{
+		// Keyed by cursor, so no extra indexes are needed
+		Name:           AlertChangesCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+	},
+	{
+		// Named sequences, e.g. the alert change cursor, and daily counts
+		Name:           CountersCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+	},
+	{
+		// Keyed by code; the TTL index removes codes once they expire
+		Name:           TelegramLinkCodesCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+		Indexes: []mongodriver.IndexModel{
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+	},
+	{
+		// Keyed by alert and trigger id; items live until their digest is queued
+		Name:           EmailDigestItemsCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+		Indexes: []mongodriver.IndexModel{
+			{Keys: bson.D{{Key: "windowStart", Value: 1}, {Key: "userId", Value: 1}}},
+		},
+	},
+	{
+		// Keyed by user, channel and hour; windows live until their hour is flushed
+		Name:           NotificationThrottleCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+		Indexes: []mongodriver.IndexModel{
+			{Keys: bson.D{{Key: "windowEnd", Value: 1}}},
+		},
+	},
+	{
+		// Sampled alert evaluations. Capped collections cannot have TTL indexes:
+		// reads skip expired samples and the cap reclaims their space.
+		Name:            AlertEvaluationsCollection,
+		WriteConcern:    writeconcern.W1(),
+		ReadPreference:  readpref.Primary(),
+		CappedSizeBytes: 64 << 20,
+		Indexes: []mongodriver.IndexModel{
+			{Keys: bson.D{{Key: "alertId", Value: 1}, {Key: "evaluatedAt", Value: -1}}},
+		},
+	},
+	{
+		// Keyed by alert id; the TTL index removes sampling flags once they expire
+		Name:           EvaluationSamplingCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+		Indexes: []mongodriver.IndexModel{
+			{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+	},
+	{
+		// Keyed by date, so no extra indexes are needed
+		Name:           MarketHolidaysCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+	},
+	{
+		// Keyed by symbol; typeahead also searches by name prefix
+		Name:           SymbolsCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+		Indexes: []mongodriver.IndexModel{
+			{Keys: bson.D{{Key: "nameKey", Value: 1}}},
+		},
+	},
+	{
+		// Keyed by flag name, so no extra indexes are needed
+		Name:           FeatureFlagsCollection,
+		WriteConcern:   writeconcern.Majority(),
+		ReadPreference: readpref.Primary(),
+	},
+}
+
+// Users returns the users collection
+func Users() *mongodriver.Collection { return registeredCollection(UsersCollection) }
+
+// Alerts returns the alerts collection
+func Alerts() *mongodriver.Collection { return registeredCollection(AlertsCollection) }
+
+// AlertsArchive returns the archived alerts collection
+func AlertsArchive() *mongodriver.Collection { return registeredCollection(AlertsArchiveCollection) }
+
+// AlertEvents returns the collection of triggered alert events
+func AlertEvents() *mongodriver.Collection { return registeredCollection(AlertEventsCollection) }
+
+// PriceTicks returns the collection of recorded price ticks
+func PriceTicks() *mongodriver.Collection { return registeredCollection(PriceTicksCollection) }
+
+// NotificationOutbox returns the collection of outbound notification deliveries
+func NotificationOutbox() *mongodriver.Collection {
+	return registeredCollection(NotificationOutboxCollection)
+}
+
+// MarketHolidays returns the collection of market holidays
+func MarketHolidays() *mongodriver.Collection { return registeredCollection(MarketHolidaysCollection) }
+
+// QuarantinedTicks returns the collection of ticks held back by the sanity checks
+func QuarantinedTicks() *mongodriver.Collection {
+	return registeredCollection(QuarantinedTicksCollection)
+}
+
+// LatestPrices returns the collection of latest prices and day statistics per symbol
+func LatestPrices() *mongodriver.Collection { return registeredCollection(LatestPricesCollection) }
+
+// AlertChanges returns the alert change feed collection
+func AlertChanges() *mongodriver.Collection { return registeredCollection(AlertChangesCollection) }
+
+// Counters returns the collection of named sequences
+func Counters() *mongodriver.Collection { return registeredCollection(CountersCollection) }
+
+// TelegramLinkCodes returns the collection of pending Telegram link codes
+func TelegramLinkCodes() *mongodriver.Collection {
+	return registeredCollection(TelegramLinkCodesCollection)
+}
+
+// EmailDigestItems returns the collection of triggers held for digest emails
+func EmailDigestItems() *mongodriver.Collection {
+	return registeredCollection(EmailDigestItemsCollection)
+}
+
+// NotificationThrottle returns the collection of hourly notification counts per user
+func NotificationThrottle() *mongodriver.Collection {
+	return registeredCollection(NotificationThrottleCollection)
+}
+
+// AlertEvaluations returns the capped collection of sampled alert evaluations
+func AlertEvaluations() *mongodriver.Collection {
+	return registeredCollection(AlertEvaluationsCollection)
+}
+
+// EvaluationSampling returns the collection of alerts whose evaluations are sampled
+func EvaluationSampling() *mongodriver.Collection {
+	return registeredCollection(EvaluationSamplingCollection)
+}
+
+// Symbols returns the collection of symbol reference data
+func Symbols() *mongodriver.Collection { return registeredCollection(SymbolsCollection) }
+
+// FeatureFlags returns the collection of flag values set by admins
+func FeatureFlags() *mongodriver.Collection { return registeredCollection(FeatureFlagsCollection) }
+
+// registeredCollection returns a registered collection with its default concerns applied
+func registeredCollection(name string) *mongodriver.Collection {
+	spec, ok := lookupCollection(name)
+	if !ok {
+		panic(fmt.Sprintf("db: collection %q is not registered", name))
+	}
+	opts := options.Collection().
+		SetWriteConcern(spec.WriteConcern).
+		SetReadPreference(spec.ReadPreference)
+	return GetDatabase().Collection(spec.Name, opts)
+}
+
+func lookupCollection(name string) (CollectionSpec, bool) {
+	for _, spec := range collections {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return CollectionSpec{}, false
+}
+
+// UserEmailIndex is the name of the unique index on user emails
+const UserEmailIndex = "email_unique"
+
+// EnsureUserEmailIndex adds a unique index on user emails, used when email
+// uniqueness is enforced. It fails if stored users already share an email.
+func EnsureUserEmailIndex(ctx context.Context) error {
+	_, err := Users().Indexes().CreateOne(ctx, mongodriver.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetName(UserEmailIndex).SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create unique email index on %s: %w", UsersCollection, err)
+	}
+	return nil
+}
+
+// EnsureIndexes creates the missing capped collections and the indexes of every
+// registered collection. Creating an index that already exists with the same
+// definition is a no-op; a collection that exists is left as it is.
+func EnsureIndexes(ctx context.Context) error {
+	for _, spec := range collections {
+		if spec.CappedSizeBytes > 0 {
+			if err := ensureCapped(ctx, spec); err != nil {
+				return err
+			}
+		}
+		if len(spec.Indexes) == 0 {
+			continue
+		}
+		if _, err := registeredCollection(spec.Name).Indexes().CreateMany(ctx, spec.Indexes); err != nil {
+			return fmt.Errorf("failed to create indexes on %s: %w", spec.Name, err)
+		}
+	}
+	return nil
+}
+
+// ensureCapped creates a capped collection unless it already exists; indexes
+// would otherwise create it uncapped
+func ensureCapped(ctx context.Context, spec CollectionSpec) error {
+	names, err := GetDatabase().ListCollectionNames(ctx, bson.M{"name": spec.Name})
+	if err != nil {
+		return fmt.Errorf("failed to look up collection %s: %w", spec.Name, err)
+	}
+	if len(names) > 0 {
+		return nil
+	}
+	opts := options.CreateCollection().SetCapped(true).SetSizeInBytes(spec.CappedSizeBytes)
+	if err := GetDatabase().CreateCollection(ctx, spec.Name, opts); err != nil {
+		return fmt.Errorf("failed to create capped collection %s: %w", spec.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Every registered collection has a unique name and default concerns
+func TestCollectionRegistry(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, spec := range collections {
+		if seen[spec.Name] {
+			t.Errorf("collection %q is registered twice", spec.Name)
+		}
+		seen[spec.Name] = true
+		if spec.WriteConcern == nil || spec.ReadPreference == nil {
+			t.Errorf("collection %q has no default write concern or read preference", spec.Name)
+		}
+		if _, ok := lookupCollection(spec.Name); !ok {
+			t.Errorf("collection %q cannot be looked up", spec.Name)
+		}
+	}
+	if _, ok := lookupCollection("unregistered"); ok {
+		t.Error("an unregistered collection was found")
+	}
+}
+
+// notCollectionNames are literals in the repositories that share a collection
+// name without naming the collection, by file
+var notCollectionNames = map[string]string{
+	// The id of the counters document of the alert change cursor
+	"alert_change_repository.go": "alert_changes",
+}
+
+// Repositories receive their collections from the accessors through their
+// constructors; none names a collection or opens one itself
+func TestRepositoriesUseRegisteredCollections(t *testing.T) {
+	names := make(map[string]bool)
+	for _, spec := range collections {
+		names[spec.Name] = true
+	}
+	files, err := filepath.Glob(filepath.Join("..", "repository", "*.go"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no repository sources found (%v)", err)
+	}
+
+	fset := token.NewFileSet()
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.BasicLit:
+				if value, err := strconv.Unquote(n.Value); err == nil && n.Kind == token.STRING && names[value] && notCollectionNames[filepath.Base(path)] != value {
+					t.Errorf("%s: collection name %q used directly", fset.Position(n.Pos()), value)
+				}
+			case *ast.SelectorExpr:
+				switch n.Sel.Name {
+				case "GetCollection", "GetDatabase", "Database":
+					t.Errorf("%s: repository opens a collection with %s", fset.Position(n.Pos()), n.Sel.Name)
+				}
+			}
+			return true
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package db
 
 import (
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
 	"github.com/hello-api/pkg/mongo"
 	mongodriver "go.mongodb.org/mongo-driver/mongo"
-	"sync"
 )
 
 var (
@@ -11,10 +15,31 @@ var (
 	clientOnce sync.Once
 )
 
+// Supported values of the DB_BACKEND environment variable
+const (
+	BackendMongo  = "mongo"
+	BackendMemory = "memory"
+)
+
+// Backend returns the configured repository backend, defaulting to MongoDB
+func Backend() string {
+	if strings.EqualFold(os.Getenv("DB_BACKEND"), BackendMemory) {
+		return BackendMemory
+	}
+	return BackendMongo
+}
+
+// UsesMongo reports whether the API is backed by MongoDB
+func UsesMongo() bool {
+	return Backend() == BackendMongo
+}
+
 // GetClient returns a singleton MongoDB client
 func GetClient() *mongodriver.Client {
 	clientOnce.Do(func() {
 		client = mongo.ConnectMongo()
+		supportsTransactions = detectTransactionSupport(client)
+		slog.Info("MongoDB transaction support", "supported", supportsTransactions)
 	})
 	return client
 }
@@ -24,7 +49,8 @@ func GetDatabase() *mongodriver.Database {
 	return mongo.CreateDatabase(GetClient())
 }
 
-// GetCollection returns a specific collection from the users database
+// GetCollection returns a specific collection from the users database without
+// any collection defaults. Prefer the registered accessors such as Users().
 func GetCollection(name string) *mongodriver.Collection {
 	return GetDatabase().Collection(name)
 }
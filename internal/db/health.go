@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Default connectivity supervisor settings
+const (
+	DefaultPingInterval = 5 * time.Second
+	DefaultPingTimeout  = 2 * time.Second
+)
+
+// healthy is read on every repository call, so it is an atomic rather than a locked value
+var healthy atomic.Bool
+
+func init() {
+	healthy.Store(true)
+}
+
+// Healthy reports whether the last connectivity check succeeded
+func Healthy() bool {
+	return healthy.Load()
+}
+
+// PingFunc checks connectivity to the database
+type PingFunc func(ctx context.Context) error
+
+// Supervisor periodically pings the database and flips the healthy flag.
+// Recovery is automatic: the flag is set again as soon as a ping succeeds.
+type Supervisor struct {
+	ping     PingFunc
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// NewSupervisor creates a supervisor using ping. Zero durations use the defaults.
+func NewSupervisor(ping PingFunc, interval, timeout time.Duration) *Supervisor {
+	if interval <= 0 {
+		interval = DefaultPingInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultPingTimeout
+	}
+	return &Supervisor{ping: ping, interval: interval, timeout: timeout}
+}
+
+// PingMongo pings the singleton client
+func PingMongo(ctx context.Context) error {
+	return GetClient().Ping(ctx, nil)
+}
+
+// Check runs a single ping and updates the healthy flag
+func (s *Supervisor) Check(ctx context.Context) bool {
+	pingCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	err := s.ping(pingCtx)
+	ok := err == nil
+	if previous := healthy.Swap(ok); previous != ok {
+		if ok {
+			slog.Info("MongoDB connectivity restored")
+		} else {
+			slog.Warn("MongoDB unreachable, failing requests fast", "error", err)
+		}
+	}
+	return ok
+}
+
+// Run checks connectivity every interval until ctx is canceled
+func (s *Supervisor) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Check(ctx)
+		}
+	}
+}
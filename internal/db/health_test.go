@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakePing fails while down is set
+type fakePing struct {
+	down  atomic.Bool
+	calls atomic.Int64
+}
+
+func (p *fakePing) ping(ctx context.Context) error {
+	p.calls.Add(1)
+	if p.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestSupervisorCheck(t *testing.T) {
+	t.Cleanup(func() { healthy.Store(true) })
+	ping := &fakePing{}
+	supervisor := NewSupervisor(ping.ping, 0, 0)
+	if supervisor.interval != DefaultPingInterval || supervisor.timeout != DefaultPingTimeout {
+		t.Errorf("zero durations gave %v and %v, want the defaults", supervisor.interval, supervisor.timeout)
+	}
+
+	for _, step := range []struct {
+		down bool
+		want bool
+	}{{false, true}, {true, false}, {true, false}, {false, true}} {
+		ping.down.Store(step.down)
+		if ok := supervisor.Check(context.Background()); ok != step.want || Healthy() != step.want {
+			t.Errorf("ping down %v: Check %v and Healthy %v, want %v", step.down, ok, Healthy(), step.want)
+		}
+	}
+}
+
+// A ping that hangs past the timeout counts as a failure
+func TestSupervisorCheckTimeout(t *testing.T) {
+	t.Cleanup(func() { healthy.Store(true) })
+	supervisor := NewSupervisor(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, time.Hour, 20*time.Millisecond)
+	if supervisor.Check(context.Background()) || Healthy() {
+		t.Error("a hung ping left the database healthy")
+	}
+}
+
+// Run marks the database unhealthy while pings fail and recovers on its own
+// once they succeed again
+func TestSupervisorRunRecovers(t *testing.T) {
+	t.Cleanup(func() { healthy.Store(true) })
+	ping := &fakePing{}
+	ping.down.Store(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewSupervisor(ping.ping, 5*time.Millisecond, time.Second).Run(ctx)
+		close(done)
+	}()
+
+	waitHealthy := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for Healthy() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Healthy stayed %v after %d pings", !want, ping.calls.Load())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitHealthy(false)
+	ping.down.Store(false)
+	waitHealthy(true)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/hello-api/pkg/logging"
+)
+
+const (
+	migrationsCollection = "schema_migrations"
+	migrationLockID      = "migration_lock"
+	migrationLockTTL     = 10 * time.Minute
+)
+
+// Migration is a named, one-time data or schema change.
+// Up must be safe to run again if a previous run failed part way through.
+type Migration struct {
+	Name string
+	Up   func(ctx context.Context, database *mongodriver.Database) error
+}
+
+// MigrationError identifies the migration that stopped the run
+type MigrationError struct {
+	Name string
+	Err  error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migration %q failed: %v", e.Name, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// appliedMigration is the record stored for every migration that ran
+type appliedMigration struct {
+	Name       string    `bson:"_id"`
+	AppliedAt  time.Time `bson:"appliedAt"`
+	DurationMs int64     `bson:"durationMs"`
+}
+
+// PendingMigrations returns the registered migrations that have not been applied yet, in order
+func PendingMigrations(ctx context.Context) ([]Migration, error) {
+	applied, err := appliedMigrationNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if !applied[m.Name] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// RunMigrations applies every pending migration in registration order while holding
+// the migration lock, so only one replica migrates at a time. It stops at the first
+// failure and returns a *MigrationError naming the migration.
+func RunMigrations(ctx context.Context) error {
+	owner, err := acquireMigrationLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer releaseMigrationLock(owner)
+
+	pending, err := PendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		logging.FromContext(ctx).Info("No pending migrations")
+		return nil
+	}
+
+	database := GetDatabase()
+	records := database.Collection(migrationsCollection)
+	for _, m := range pending {
+		logging.FromContext(ctx).Info("Applying migration", "migration", m.Name)
+		start := time.Now()
+		if err := m.Up(ctx, database); err != nil {
+			return &MigrationError{Name: m.Name, Err: err}
+		}
+
+		record := appliedMigration{
+			Name:       m.Name,
+			AppliedAt:  time.Now(),
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if _, err := records.InsertOne(ctx, record); err != nil {
+			return &MigrationError{Name: m.Name, Err: fmt.Errorf("failed to record migration: %w", err)}
+		}
+		logging.FromContext(ctx).Info("Applied migration", "migration", m.Name, "duration_ms", record.DurationMs)
+	}
+	return nil
+}
+
+func appliedMigrationNames(ctx context.Context) (map[string]bool, error) {
+	cursor, err := GetDatabase().Collection(migrationsCollection).Find(ctx, bson.M{"_id": bson.M{"$ne": migrationLockID}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var records []appliedMigration
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	applied := make(map[string]bool, len(records))
+	for _, r := range records {
+		applied[r.Name] = true
+	}
+	return applied, nil
+}
+
+// acquireMigrationLock takes the lock document, or fails if another replica holds an
+// unexpired lock. The upsert only matches an expired lock, so a live lock makes the
+// insert collide on _id.
+func acquireMigrationLock(ctx context.Context) (string, error) {
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano())
+	now := time.Now()
+
+	filter := bson.M{"_id": migrationLockID, "expiresAt": bson.M{"$lt": now}}
+	update := bson.M{"$set": bson.M{"owner": owner, "lockedAt": now, "expiresAt": now.Add(migrationLockTTL)}}
+	_, err := GetDatabase().Collection(migrationsCollection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		if mongodriver.IsDuplicateKeyError(err) {
+			return "", fmt.Errorf("migrations are locked by another process")
+		}
+		return "", fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	return owner, nil
+}
+
+func releaseMigrationLock(owner string) {
+	_, err := GetDatabase().Collection(migrationsCollection).DeleteOne(context.Background(), bson.M{"_id": migrationLockID, "owner": owner})
+	if err != nil {
+		slog.Warn("Failed to release the migration lock", "owner", owner, "error", err)
+	}
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrations is the ordered list of schema migrations.
+// Append new migrations at the end; never rename or reorder applied ones.
+var migrations = []Migration{
+	{
+		Name: "0001_alerts_user_index",
+		Up: func(ctx context.Context, database *mongodriver.Database) error {
+			// CreateOne is a no-op when an identical index already exists
+			_, err := database.Collection(AlertsCollection).Indexes().CreateOne(ctx, mongodriver.IndexModel{
+				Keys: bson.D{{Key: "userId", Value: 1}},
+			})
+			return err
+		},
+	},
+	{
+		Name: "0002_notification_outbox_channel",
+		Up: func(ctx context.Context, database *mongodriver.Database) error {
+			outbox := database.Collection(NotificationOutboxCollection)
+			// Deliveries queued before channels existed are webhooks
+			_, err := outbox.UpdateMany(ctx,
+				bson.M{"channel": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"channel": "webhook"}},
+			)
+			if err != nil {
+				return err
+			}
+			// The trigger index now includes the channel, so a trigger can be
+			// queued once per channel. EnsureIndexes creates the replacement.
+			_, err = outbox.Indexes().DropOne(ctx, "alertId_1_triggerId_1")
+			var cmdErr mongodriver.CommandError
+			if errors.As(err, &cmdErr) && cmdErr.Code == indexNotFoundCode {
+				return nil
+			}
+			return err
+		},
+	},
+	{
+		Name: "0003_decimal_prices",
+		Up: func(ctx context.Context, database *mongodriver.Database) error {
+			// Prices were stored as doubles; they are now Decimal128 with two
+			// decimal places, rounded to the paisa on the way
+			for collection, fields := range decimalPriceFields {
+				for _, field := range fields {
+					_, err := database.Collection(collection).UpdateMany(ctx,
+						bson.M{field: bson.M{"$type": bson.A{"double", "int", "long"}}},
+						mongodriver.Pipeline{{{Key: "$set", Value: bson.M{
+							field: bson.M{"$toDecimal": bson.M{"$round": bson.A{"$" + field, 2}}},
+						}}}},
+					)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// decimalPriceFields lists the price fields of each collection, which
+// 0003_decimal_prices converts to Decimal128
+var decimalPriceFields = map[string][]string{
+	AlertsCollection:           {"price"},
+	AlertsArchiveCollection:    {"price"},
+	PriceTicksCollection:       {"price"},
+	LatestPricesCollection:     {"price", "dayOpen", "dayHigh", "dayLow", "previousClose"},
+	QuarantinedTicksCollection: {"price", "lastAcceptedPrice"},
+	AlertEvaluationsCollection: {"price"},
+}
+
+// indexNotFoundCode is the server error for dropping a missing index
+const indexNotFoundCode = 27
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hello-api/pkg/money"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoTestDatabase returns a throwaway database on the MongoDB at
+// MONGO_TEST_URI, dropped when the test ends, and skips the test without one
+func mongoTestDatabase(t *testing.T) *mongodriver.Database {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongodriver.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := client.Database(fmt.Sprintf("stock_alert_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		database.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return database
+}
+
+// migration returns the migration called name
+func migration(t *testing.T, name string) Migration {
+	t.Helper()
+	for _, m := range migrations {
+		if m.Name == name {
+			return m
+		}
+	}
+	t.Fatalf("no migration %s", name)
+	return Migration{}
+}
+
+// Prices stored as doubles or integers become Decimal128 rounded to the
+// paisa; prices already decimal and unset fields are left alone
+func TestDecimalPricesMigration(t *testing.T) {
+	database := mongoTestDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	alerts := database.Collection(AlertsCollection)
+	already, _ := primitive.ParseDecimal128("99.50")
+	if _, err := alerts.InsertMany(ctx, []any{
+		bson.M{"_id": "double", "price": 123.45000000000001},
+		bson.M{"_id": "int", "price": int32(100)},
+		bson.M{"_id": "long", "price": int64(7)},
+		bson.M{"_id": "decimal", "price": already},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	latest := database.Collection(LatestPricesCollection)
+	if _, err := latest.InsertOne(ctx, bson.M{"_id": "GP", "price": 0.30000000000000004, "dayOpen": 0.1}); err != nil {
+		t.Fatal(err)
+	}
+
+	up := migration(t, "0003_decimal_prices").Up
+	// A second run finds nothing left to convert
+	for run := 0; run < 2; run++ {
+		if err := up(ctx, database); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		collection *mongodriver.Collection
+		id         string
+		field      string
+		want       money.Amount
+	}{
+		{collection: alerts, id: "double", field: "price", want: 12345},
+		{collection: alerts, id: "int", field: "price", want: 10000},
+		{collection: alerts, id: "long", field: "price", want: 700},
+		{collection: alerts, id: "decimal", field: "price", want: 9950},
+		{collection: latest, id: "GP", field: "price", want: 30},
+		{collection: latest, id: "GP", field: "dayOpen", want: 10},
+	} {
+		var stored bson.Raw
+		if err := tc.collection.FindOne(ctx, bson.M{"_id": tc.id}).Decode(&stored); err != nil {
+			t.Fatal(err)
+		}
+		value := stored.Lookup(tc.field)
+		decimal, ok := value.Decimal128OK()
+		if !ok {
+			t.Errorf("%s %s.%s is a %s, want a Decimal128", tc.collection.Name(), tc.id, tc.field, value.Type)
+			continue
+		}
+		if got, err := money.Parse(decimal.String()); err != nil || got != tc.want {
+			t.Errorf("%s %s.%s is %s, want %s", tc.collection.Name(), tc.id, tc.field, decimal, tc.want)
+		}
+	}
+
+	var latestPrice bson.Raw
+	if err := latest.FindOne(ctx, bson.M{"_id": "GP"}).Decode(&latestPrice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := latestPrice.LookupErr("dayLow"); err == nil {
+		t.Error("the migration set the unset dayLow")
+	}
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrTransactionsUnsupported is returned by WithTransaction when the deployment
+// is a standalone server, which cannot run multi-document transactions
+var ErrTransactionsUnsupported = errors.New("mongodb deployment does not support transactions")
+
+var supportsTransactions bool
+
+// SupportsTransactions reports whether the connected deployment (replica set or
+// sharded cluster) supports multi-document transactions. Callers should fall
+// back to non-transactional writes when it returns false.
+func SupportsTransactions() bool {
+	if !UsesMongo() {
+		return false
+	}
+	GetClient()
+	return supportsTransactions
+}
+
+// WithTransaction runs fn inside a multi-document transaction on a session of the
+// singleton client. The driver retries fn on TransientTransactionError and retries
+// the commit on UnknownTransactionCommitResult; any error returned by fn aborts the
+// transaction. fn must pass sessCtx to every repository call that participates.
+func WithTransaction(ctx context.Context, fn func(sessCtx mongodriver.SessionContext) error) error {
+	if !SupportsTransactions() {
+		return ErrTransactionsUnsupported
+	}
+
+	session, err := GetClient().StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongodriver.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// detectTransactionSupport inspects the topology reported by the server.
+// Transactions need a replica set member (setName) or a mongos router (isdbgrid).
+func detectTransactionSupport(client *mongodriver.Client) bool {
+	var result bson.M
+	admin := client.Database("admin")
+	err := admin.RunCommand(context.Background(), bson.D{{Key: "hello", Value: 1}}).Decode(&result)
+	if err != nil {
+		// Servers older than 4.4.2 only understand the legacy command name
+		err = admin.RunCommand(context.Background(), bson.D{{Key: "isMaster", Value: 1}}).Decode(&result)
+	}
+	if err != nil {
+		slog.Warn("Could not detect the MongoDB topology, assuming no transaction support", "error", err)
+		return false
+	}
+
+	if setName, ok := result["setName"].(string); ok && setName != "" {
+		return true
+	}
+	if msg, ok := result["msg"].(string); ok && msg == "isdbgrid" {
+		return true
+	}
+	return false
+}
@@ -1,20 +1,115 @@
 package domain
 
-import "github.com/hello-api/internal/handler/dto"
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/money"
+)
 
 // AlertRepository interface defines the contract for alert data operations
 type AlertRepository interface {
-	Create(alert *dto.AlertCreateRequest) (*dto.AlertResponse, error)
-	FindByID(id string) (*dto.AlertResponse, error)
-	FindAllByUser(userId string) ([]dto.AlertResponse, error)
-	Update(id string, alert *dto.AlertCreateRequest) (*dto.AlertResponse, error)
-	Delete(id string) error
+	Create(ctx context.Context, alert *dto.AlertCreateRequest) (*dto.AlertResponse, error)
+	FindByID(ctx context.Context, id string) (*dto.AlertResponse, error)
+	FindAllByUser(ctx context.Context, userId string) ([]dto.AlertResponse, error)
+	// FindActive returns every active alert, for the in-memory evaluation index
+	FindActive(ctx context.Context) ([]dto.AlertResponse, error)
+	// DistinctActiveSymbols returns, sorted, the symbols watched by active
+	// alerts whose start and stop dates include at
+	DistinctActiveSymbols(ctx context.Context, at time.Time) ([]string, error)
+	Update(ctx context.Context, id string, alert *dto.AlertCreateRequest) (*dto.AlertResponse, error)
+	Delete(ctx context.Context, id string) error
+	// SetShadow switches the alert's shadow mode; it returns nil when there is no such alert
+	SetShadow(ctx context.Context, id string, shadow bool) (*dto.AlertResponse, error)
+	// MarkTriggered atomically moves an alert from armed to triggered. It returns
+	// false when the alert was already triggered, so only one caller fires it.
+	MarkTriggered(ctx context.Context, id string, at time.Time) (bool, error)
+	// Rearm moves a triggered alert back to armed; it returns false when it was armed
+	Rearm(ctx context.Context, id string) (bool, error)
+	// FindAfter returns up to limit alerts with an id above after, in id order,
+	// so large scans can run in resumable chunks
+	FindAfter(ctx context.Context, after string, limit int64) ([]dto.AlertResponse, error)
+	// Deactivate switches the active alerts among ids off and returns how many it changed
+	Deactivate(ctx context.Context, ids []string) (int64, error)
+}
+
+// AlertChangeRepository is the persisted feed of alert mutations
+type AlertChangeRepository interface {
+	// NextCursor allocates the next change cursor; cursors survive restarts
+	NextCursor(ctx context.Context) (int64, error)
+	Append(ctx context.Context, change *dto.AlertChangeRequest) (*dto.AlertChangeResponse, error)
+	// Since returns up to limit changes with a cursor above since, in cursor order
+	Since(ctx context.Context, since int64, limit int64) ([]dto.AlertChangeResponse, error)
+}
+
+// AlertArchiveRepository moves fired alerts that have stopped out of the live
+// alerts and back
+type AlertArchiveRepository interface {
+	// Archive moves up to limit inactive alerts that have fired and whose stop
+	// date and last update are before before, and returns the alerts it moved
+	Archive(ctx context.Context, before time.Time, limit int64) ([]dto.AlertResponse, error)
+	// FindByUser returns one page of a user's archived alerts, most recently
+	// archived first, and their total. Pages start at 1.
+	FindByUser(ctx context.Context, userID string, page, pageSize int64) ([]dto.ArchivedAlertResponse, int64, error)
+	// Restore moves an archived alert back to the live alerts; it returns nil
+	// when the alert is not archived
+	Restore(ctx context.Context, id string) (*dto.AlertResponse, error)
+}
+
+// AlertArchiveService lists and restores archived alerts
+type AlertArchiveService interface {
+	GetArchivedAlerts(ctx context.Context, userID string, page, pageSize int64) (*dto.ArchivedAlertPageResponse, error)
+	RestoreAlert(ctx context.Context, id string) (*dto.AlertResponse, error)
+}
+
+// AlertMaintenanceService repairs stored alerts
+type AlertMaintenanceService interface {
+	// CleanupOrphanedAlerts scans one chunk of alerts for userIds without a
+	// user and, unless it is a dry run, switches their active alerts off
+	CleanupOrphanedAlerts(ctx context.Context, req dto.OrphanedAlertsRequest) (*dto.OrphanedAlertsResponse, error)
+}
+
+// AlertMatcher finds the active alerts a price would fire, without firing them
+type AlertMatcher interface {
+	MatchingAlerts(symbol string, price money.Amount) []dto.AlertResponse
+}
+
+// EvaluationSampleRepository stores sampled tick evaluations and the alerts
+// whose evaluations are sampled
+type EvaluationSampleRepository interface {
+	// SetSampling samples the alert's evaluations until expiresAt
+	SetSampling(ctx context.Context, alertID string, expiresAt time.Time) error
+	// ActiveSampling returns the expiry of every alert still sampled at now
+	ActiveSampling(ctx context.Context, now time.Time) (map[string]time.Time, error)
+	Insert(ctx context.Context, samples []dto.EvaluationSampleRequest) error
+	// FindByAlert returns up to limit samples of an alert not expired at now,
+	// newest first; shadowOnly keeps the shadow fires
+	FindByAlert(ctx context.Context, alertID string, now time.Time, limit int64, shadowOnly bool) ([]dto.EvaluationSampleResponse, error)
+}
+
+// EvaluationSamplingService records what the tick evaluator decided, for
+// alerts sampled by an admin and for a global sampling rate
+type EvaluationSamplingService interface {
+	// SampleAlert samples every evaluation of an alert for the requested TTL
+	SampleAlert(ctx context.Context, id string, req dto.EvaluationSamplingRequest) (*dto.EvaluationSamplingResponse, error)
+	// GetEvaluations returns the stored samples of an alert, newest first;
+	// shadowOnly keeps the shadow fires
+	GetEvaluations(ctx context.Context, id string, shadowOnly bool) ([]dto.EvaluationSampleResponse, error)
 }
 
 type AlertService interface {
-	CreateAlert(alert dto.AlertCreateRequest) (*dto.AlertResponse, error)
-	GetAlertByID(id string) (*dto.AlertResponse, error)
-	GetAlertsByUser(userId string) ([]dto.AlertResponse, error)
-	UpdateAlert(id string, alert dto.AlertCreateRequest) (*dto.AlertResponse, error)
-	DeleteAlert(id string) error
+	CreateAlert(ctx context.Context, alert dto.AlertCreateRequest) (*dto.AlertResponse, error)
+	GetAlertByID(ctx context.Context, id string) (*dto.AlertResponse, error)
+	GetAlertsByUser(ctx context.Context, userId string) ([]dto.AlertResponse, error)
+	UpdateAlert(ctx context.Context, id string, alert dto.AlertCreateRequest) (*dto.AlertResponse, error)
+	DeleteAlert(ctx context.Context, id string) error
+	// SetAlertShadow switches an alert's shadow mode, in which its triggers are
+	// recorded for admins instead of notified
+	SetAlertShadow(ctx context.Context, id string, shadow bool) (*dto.AlertResponse, error)
+	EvaluateAlert(ctx context.Context, id string, req dto.AlertEvaluateRequest) (*dto.AlertEvaluationResponse, error)
+	// BacktestAlert replays an alert definition, without storing it, over stored ticks
+	BacktestAlert(ctx context.Context, req dto.AlertBacktestRequest) (*dto.AlertBacktestResponse, error)
+	// GetChanges returns the changes after since, waiting up to wait for one to arrive
+	GetChanges(ctx context.Context, since int64, wait time.Duration) (*dto.AlertChangesResponse, error)
 }
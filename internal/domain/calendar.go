@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+)
+
+// HolidayRepository stores the market holidays
+type HolidayRepository interface {
+	FindAll(ctx context.Context) ([]dto.HolidayResponse, error)
+	// Add stores a holiday, replacing the name of an existing one on the same date
+	Add(ctx context.Context, holiday *dto.HolidayRequest) (*dto.HolidayResponse, error)
+	// Delete returns ErrHolidayNotFound when no holiday is stored on date
+	Delete(ctx context.Context, date string) error
+}
+
+// MarketCalendarService decides when the market is trading
+type MarketCalendarService interface {
+	Session(ctx context.Context, at time.Time) (dto.MarketSession, error)
+	GetCalendar(ctx context.Context) (*dto.MarketCalendarResponse, error)
+	AddHoliday(ctx context.Context, holiday dto.HolidayRequest) (*dto.HolidayResponse, error)
+	RemoveHoliday(ctx context.Context, date string) error
+}
@@ -6,9 +6,33 @@ var (
 	// ErrUserNotFound is returned when a user is not found
 	ErrUserNotFound = errors.New("user not found")
 	
+	// ErrAlertNotFound is returned when an alert is not found
+	ErrAlertNotFound = errors.New("alert not found")
+	
+	// ErrHolidayNotFound is returned when no market holiday is stored on a date
+	ErrHolidayNotFound = errors.New("holiday not found")
+	
+	// ErrTickNotFound is returned when a quarantined tick is not found
+	ErrTickNotFound = errors.New("quarantined tick not found")
+	
+	// ErrPriceNotFound is returned when no price has been recorded for a symbol
+	ErrPriceNotFound = errors.New("price not found")
+	
+	// ErrFlagNotFound is returned when no feature flag is defined with a name
+	ErrFlagNotFound = errors.New("feature flag not found")
+	
+	// ErrOutsideMarketHours is returned when a trigger is skipped because the market is closed
+	ErrOutsideMarketHours = errors.New("outside market hours")
+	
 	// if user already exists
 	ErrUserAlreadyExit = errors.New("user Already exit")
 	
+	// ErrEmailAlreadyExists is returned when another user already has the email
+	ErrEmailAlreadyExists = errors.New("email already exists")
+	
+	// ErrDuplicate is returned when a write would break a unique index
+	ErrDuplicate = errors.New("duplicate value")
+	
 	// ErrValidation is returned when input validation fails
 	ErrValidation = errors.New("validation error")
 	
@@ -18,6 +42,9 @@ var (
 	// ErrForbidden is returned when a request is not allowed
 	ErrForbidden = errors.New("forbidden")
 	
+	// ErrDependencyUnavailable is returned when a backing service such as MongoDB is unreachable
+	ErrDependencyUnavailable = errors.New("dependency unavailable")
+	
 	// ErrInternal is returned when an unexpected internal error occurs
 	ErrInternal = errors.New("internal server error")
 )
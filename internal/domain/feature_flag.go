@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+)
+
+// FeatureFlagRepository stores the flag values set through the admin route,
+// which take precedence over the environment and the defaults
+type FeatureFlagRepository interface {
+	// Set stores the raw value of a flag, replacing the stored one
+	Set(ctx context.Context, name, value string, at time.Time) error
+	// Delete removes the stored value, so the flag falls back to the
+	// environment or its default
+	Delete(ctx context.Context, name string) error
+	FindAll(ctx context.Context) ([]dto.FeatureFlagOverride, error)
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+)
+
+// NotificationRepository is the outbox of outbound notification deliveries
+type NotificationRepository interface {
+	// Enqueue adds a pending delivery; enqueueing the same alert trigger again
+	// returns the existing delivery
+	Enqueue(ctx context.Context, req *dto.NotificationEnqueueRequest) (*dto.NotificationResponse, error)
+	// ClaimDue atomically claims the oldest due delivery for lease, counting the
+	// attempt. It returns nil when nothing is due.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*dto.NotificationResponse, error)
+	// MarkDelivered, MarkRetry and MarkFailed record the outcome of the claim
+	// made for attempt. An outcome is dropped once the lease expired and the
+	// delivery was claimed again, so a slow worker cannot overwrite the newer claim.
+	MarkDelivered(ctx context.Context, id string, attempt int, at time.Time) error
+	MarkRetry(ctx context.Context, id string, attempt int, next time.Time, lastErr string) error
+	MarkFailed(ctx context.Context, id string, attempt int, lastErr string) error
+	FindByAlert(ctx context.Context, alertID string) ([]dto.NotificationResponse, error)
+	FindByStatus(ctx context.Context, status dto.NotificationStatus, limit int64) ([]dto.NotificationResponse, error)
+}
+
+// EmailDigestRepository holds triggers until their user's digest window ends
+type EmailDigestRepository interface {
+	// Add holds a trigger; adding the same alert trigger again is a no-op
+	Add(ctx context.Context, item *dto.EmailDigestItem) error
+	// DueGroups lists the user windows that started before the given time
+	DueGroups(ctx context.Context, before time.Time) ([]dto.EmailDigestGroup, error)
+	// Items returns a window's triggers, oldest first
+	Items(ctx context.Context, group dto.EmailDigestGroup) ([]dto.EmailDigestItem, error)
+	// Remove drops a window's triggers once its digest is queued
+	Remove(ctx context.Context, group dto.EmailDigestGroup) error
+}
+
+// NotificationThrottleRepository counts each user's notifications per channel and hour
+type NotificationThrottleRepository interface {
+	// Hit counts a notification in its window and returns the window's count so far
+	Hit(ctx context.Context, window *dto.NotificationThrottleWindow) (int64, error)
+	// Ended lists the windows that ended at or before the given time
+	Ended(ctx context.Context, before time.Time) ([]dto.NotificationThrottleWindow, error)
+	// Remove drops a window once its summary is queued
+	Remove(ctx context.Context, window dto.NotificationThrottleWindow) error
+}
+
+type NotificationService interface {
+	RecordTrigger(ctx context.Context, alertID string, trigger dto.AlertTriggerRequest) (*dto.NotificationResponse, error)
+	// RecordShadowTrigger applies the checks of RecordTrigger to a trigger of
+	// an alert in shadow mode, but notifies no one
+	RecordShadowTrigger(ctx context.Context, alert dto.AlertResponse, trigger dto.AlertTriggerRequest) error
+	GetAlertNotifications(ctx context.Context, alertID string) ([]dto.NotificationResponse, error)
+	GetNotificationsByStatus(ctx context.Context, status string) ([]dto.NotificationResponse, error)
+}
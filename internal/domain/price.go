@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+)
+
+// PriceRepository stores accepted price ticks
+type PriceRepository interface {
+	// Insert stores a tick and rolls it into the symbol's latest price and day
+	// statistics, returning the tick with the resulting latest price;
+	// tradingDate is the exchange-local date of the tick
+	Insert(ctx context.Context, tick *dto.PriceTickRequest, tradingDate string) (*dto.PriceTickResponse, error)
+	// Latest returns the latest price of a symbol, or nil when there is none
+	Latest(ctx context.Context, symbol string) (*dto.LatestPriceResponse, error)
+	// LatestForSymbols returns the latest prices of the symbols in one query,
+	// keyed by symbol; symbols without a price are left out
+	LatestForSymbols(ctx context.Context, symbols []string) (map[string]dto.LatestPriceResponse, error)
+	// MostRecent returns the latest price updated last across all symbols, or
+	// nil when no tick was ingested yet
+	MostRecent(ctx context.Context) (*dto.LatestPriceResponse, error)
+	// UpdatedSince returns the latest prices updated at or after since, in
+	// symbol order
+	UpdatedSince(ctx context.Context, since time.Time) ([]dto.LatestPriceResponse, error)
+	// InsertIfAbsent stores a backfilled tick unless a tick with the same
+	// symbol, time, price and volume is stored already, in which case it
+	// returns nil. The latest price is left alone; see RecomputeDay.
+	InsertIfAbsent(ctx context.Context, tick *dto.PriceTickRequest) (*dto.PriceTickResponse, error)
+	// RecomputeDay rebuilds the day statistics of a symbol's trading date from
+	// its stored ticks with from <= time < to, so running it again gives the
+	// same result. A latest price on an earlier trading date rolls over to
+	// tradingDate; one on a later date is left alone and nil is returned, as
+	// it is when the range holds no ticks.
+	RecomputeDay(ctx context.Context, symbol, tradingDate string, from, to time.Time) (*dto.LatestPriceResponse, error)
+	// CountTicks counts the stored ticks of a symbol with from <= time < to
+	CountTicks(ctx context.Context, symbol string, from, to time.Time) (int64, error)
+	// ReplayTicks streams the stored ticks of a symbol with from <= time < to
+	// to fn in time order, each with the latest price and day statistics as
+	// they stand after it, rolled from the first tick of the range. tradingDate
+	// gives the trading date of a tick time. It stops at the first error of fn.
+	ReplayTicks(ctx context.Context, symbol string, from, to time.Time, tradingDate func(time.Time) string, fn func(dto.PriceTickResponse) error) error
+}
+
+// QuarantineRepository stores ticks held back by the sanity checks
+type QuarantineRepository interface {
+	Add(ctx context.Context, req *dto.QuarantineRequest) (*dto.QuarantinedTickResponse, error)
+	FindByID(ctx context.Context, id string) (*dto.QuarantinedTickResponse, error)
+	FindByStatus(ctx context.Context, status dto.QuarantineStatus, limit int64) ([]dto.QuarantinedTickResponse, error)
+	// MarkReleased releases a held tick; it returns false when the tick was not held
+	MarkReleased(ctx context.Context, id string, at time.Time) (bool, error)
+}
+
+type PriceService interface {
+	Ingest(ctx context.Context, req dto.PriceIngestRequest) (*dto.PriceIngestResponse, error)
+	GetLatest(ctx context.Context, symbol string) (*dto.LatestPriceResponse, error)
+	GetQuarantined(ctx context.Context, status string) ([]dto.QuarantinedTickResponse, error)
+	ReleaseQuarantined(ctx context.Context, id string) (*dto.PriceTickResponse, error)
+}
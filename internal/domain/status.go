@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/hello-api/internal/handler/dto"
+)
+
+// DailyCounterRepository keeps named counts per market-local date, e.g. the
+// triggers fired on a trading day
+type DailyCounterRepository interface {
+	Increment(ctx context.Context, name, date string) error
+	// Get returns 0 for a count never incremented
+	Get(ctx context.Context, name, date string) (int64, error)
+}
+
+type StatusService interface {
+	GetStatus(ctx context.Context) (*dto.StatusResponse, error)
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+)
+
+// SymbolRepository stores the reference data of tradable symbols
+type SymbolRepository interface {
+	// Upsert stores symbols, keeping the stored name and sector where a request
+	// leaves them empty. A non-nil seenAt becomes their lastSeenAt.
+	Upsert(ctx context.Context, symbols []dto.SymbolRequest, seenAt *time.Time) error
+	Exists(ctx context.Context, symbol string) (bool, error)
+	// Search returns up to limit symbols whose symbol or upper-cased name starts
+	// with prefix, in symbol order; an empty prefix matches every symbol
+	Search(ctx context.Context, prefix string, limit int64) ([]dto.SymbolResponse, error)
+}
+
+// SymbolService serves and maintains the symbol reference data
+type SymbolService interface {
+	// Search finds symbols by symbol or name prefix, for typeahead
+	Search(ctx context.Context, query string, limit int64) ([]dto.SymbolResponse, error)
+	// Import upserts the rows of a symbol,name,sector CSV
+	Import(ctx context.Context, csv io.Reader) (*dto.SymbolImportResponse, error)
+}
+
+// SymbolValidator checks alert symbols against the reference data
+type SymbolValidator interface {
+	// ValidateSymbol returns an ErrValidation error, with a suggestion where
+	// one is found, when symbol is not a known symbol
+	ValidateSymbol(ctx context.Context, symbol string) error
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+)
+
+// TelegramLinkRepository stores the one-time codes that link Telegram chats to users
+type TelegramLinkRepository interface {
+	Create(ctx context.Context, code, userID string, expiresAt time.Time) error
+	// Consume removes the code and returns its user id, or "" when the code is
+	// unknown or expired
+	Consume(ctx context.Context, code string, now time.Time) (string, error)
+}
+
+type TelegramService interface {
+	// CreateLink issues a link code for the user
+	CreateLink(ctx context.Context, userID string) (*dto.TelegramLinkResponse, error)
+	// Unlink forgets the user's Telegram chat
+	Unlink(ctx context.Context, userID string) error
+	// HandleUpdate processes an update delivered to the bot webhook
+	HandleUpdate(ctx context.Context, update dto.TelegramUpdate) error
+}
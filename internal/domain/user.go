@@ -1,25 +1,44 @@
 package domain
 
 import (
+	"context"
+
 	"github.com/hello-api/internal/handler/dto"
 	"github.com/hello-api/internal/repository/entity"
 )
 
 // UserRepository interface defines the contract for user data operations
 type UserRepository interface {
-	FindAll() ([]entity.UserEntity, error)
-	FindByObjectID(id string) (*entity.UserEntity, error)
-	FindByUserID(userID string) (*entity.UserEntity, error)
-	Create(user *entity.UserEntity) (*entity.UserEntity, error)
-	Update(user *entity.UserEntity) (*entity.UserEntity, error)
-	DeleteByObjectID(id string) error
+	FindAll(ctx context.Context, page, pageSize int64) ([]entity.UserEntity, int64, error)
+	FindByObjectID(ctx context.Context, id string) (*entity.UserEntity, error)
+	FindByUserID(ctx context.Context, userID string) (*entity.UserEntity, error)
+	FindByEmail(ctx context.Context, email string) (*entity.UserEntity, error)
+	// ExistingUserIDs returns which of the userIds belong to a user
+	ExistingUserIDs(ctx context.Context, userIDs []string) (map[string]bool, error)
+	// SetTelegramChat links a Telegram chat to the user by userId; 0 unlinks it
+	SetTelegramChat(ctx context.Context, userID string, chatID int64) error
+	Create(ctx context.Context, user *entity.UserEntity) (*entity.UserEntity, error)
+	Update(ctx context.Context, user *entity.UserEntity) (*entity.UserEntity, error)
+	DeleteByObjectID(ctx context.Context, id string) error
+	Count(ctx context.Context) (int64, error)
 }
 
 // UserService defines the contract for the user service
 type UserService interface {
-	GetAllUsers() ([]dto.UserResponse, error)
-	GetUserByID(id string) (*dto.UserResponse, error)
-	CreateUser(user dto.UserCreateRequest) (*dto.UserResponse, error)
-	UpdateUser(id string, user dto.UserUpdateRequest) (*dto.UserResponse, error)
-	DeleteUser(id string) error
+	GetAllUsers(ctx context.Context, page, pageSize int64) (*dto.UserPageResponse, error)
+	GetUserByID(ctx context.Context, id string) (*dto.UserResponse, error)
+	CreateUser(ctx context.Context, user dto.UserCreateRequest) (*dto.UserResponse, error)
+	UpdateUser(ctx context.Context, id string, user dto.UserUpdateRequest) (*dto.UserResponse, error)
+	// PatchUser changes the fields present in the request, clearing those set to ""
+	PatchUser(ctx context.Context, id string, patch dto.UserPatchRequest) (*dto.UserResponse, error)
+	// MuteUser suppresses every notification of the user until the requested
+	// time; triggers are still recorded
+	MuteUser(ctx context.Context, id string, req dto.UserMuteRequest) (*dto.UserResponse, error)
+	// UnmuteUser lifts a mute right away
+	UnmuteUser(ctx context.Context, id string) (*dto.UserResponse, error)
+	// SetAlertDefaults replaces the settings applied to the new alerts of the
+	// user with the given userId
+	SetAlertDefaults(ctx context.Context, userID string, defaults dto.AlertDefaults) (*dto.UserResponse, error)
+	DeleteUser(ctx context.Context, id string) error
+	CountUsers(ctx context.Context) (*dto.UserCountResponse, error)
 }
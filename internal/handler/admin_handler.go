@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/service"
+)
+
+type AdminHandler struct {
+	alertService        domain.AlertService
+	notificationService domain.NotificationService
+	calendarService     domain.MarketCalendarService
+	samplingService     domain.EvaluationSamplingService
+}
+
+func NewAdminHandler(alertService domain.AlertService, notificationService domain.NotificationService, calendarService domain.MarketCalendarService, samplingService domain.EvaluationSamplingService) *AdminHandler {
+	return &AdminHandler{alertService: alertService, notificationService: notificationService, calendarService: calendarService, samplingService: samplingService}
+}
+
+// EvaluateAlert explains whether an alert fires for a price. With ?force=true a
+// passing evaluation is also recorded as a trigger, notifying the owner.
+func (h *AdminHandler) EvaluateAlert(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	force := false
+	if raw := r.URL.Query().Get("force"); raw != "" {
+		var err error
+		if force, err = strconv.ParseBool(raw); err != nil {
+			common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "force must be true or false")
+			return
+		}
+	}
+
+	var req dto.AlertEvaluateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", common.RequestFormatMessage(err))
+		return
+	}
+	result, err := h.alertService.EvaluateAlert(r.Context(), id, req)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+
+	if force && result.WouldFire {
+		notification, err := h.notificationService.RecordTrigger(r.Context(), id, dto.AlertTriggerRequest{
+			Price:       result.ObservedPrice,
+			Reason:      "forced re-evaluation: " + thresholdReason(result),
+			TriggeredAt: result.ObservedAt,
+		})
+		if err != nil {
+			common.HandleError(w, err)
+			return
+		}
+		result.Triggered = true
+		result.Notification = notification
+	}
+	common.RespondWithSuccess(w, http.StatusOK, result)
+}
+
+// SampleAlertEvaluations records every tick evaluation of an alert for the
+// requested TTL. An empty body samples for the default TTL.
+func (h *AdminHandler) SampleAlertEvaluations(w http.ResponseWriter, r *http.Request) {
+	var req dto.EvaluationSamplingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", common.RequestFormatMessage(err))
+		return
+	}
+	result, err := h.samplingService.SampleAlert(r.Context(), mux.Vars(r)["id"], req)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, result)
+}
+
+// GetAlertEvaluations returns the sampled evaluations of an alert, newest
+// first; ?shadow=true returns only its shadow fires
+func (h *AdminHandler) GetAlertEvaluations(w http.ResponseWriter, r *http.Request) {
+	shadowOnly := false
+	if raw := r.URL.Query().Get("shadow"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "shadow must be true or false")
+			return
+		}
+		shadowOnly = parsed
+	}
+	samples, err := h.samplingService.GetEvaluations(r.Context(), mux.Vars(r)["id"], shadowOnly)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, samples)
+}
+
+// SetAlertShadow switches an alert's shadow mode. A shadow alert fires as
+// usual, but its triggers are only recorded as evaluations, never notified.
+func (h *AdminHandler) SetAlertShadow(w http.ResponseWriter, r *http.Request) {
+	var req dto.AlertShadowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", common.RequestFormatMessage(err))
+		return
+	}
+	alert, err := h.alertService.SetAlertShadow(r.Context(), mux.Vars(r)["id"], req.Shadow)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, alert)
+}
+
+// GetMarketCalendar returns the trading schedule and the stored holidays
+func (h *AdminHandler) GetMarketCalendar(w http.ResponseWriter, r *http.Request) {
+	calendar, err := h.calendarService.GetCalendar(r.Context())
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, calendar)
+}
+
+// AddHoliday adds a market holiday, or renames the one on the same date
+func (h *AdminHandler) AddHoliday(w http.ResponseWriter, r *http.Request) {
+	var req dto.HolidayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", common.RequestFormatMessage(err))
+		return
+	}
+	holiday, err := h.calendarService.AddHoliday(r.Context(), req)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, holiday)
+}
+
+// RemoveHoliday deletes the market holiday on a date
+func (h *AdminHandler) RemoveHoliday(w http.ResponseWriter, r *http.Request) {
+	date := mux.Vars(r)["date"]
+	if err := h.calendarService.RemoveHoliday(r.Context(), date); err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Holiday removed"})
+}
+
+// thresholdReason is the explanation of the threshold gate of an evaluation
+func thresholdReason(result *dto.AlertEvaluationResponse) string {
+	for _, gate := range result.Gates {
+		if gate.Name == service.GateThreshold {
+			return gate.Reason
+		}
+	}
+	return fmt.Sprintf("price %s is %s %s", result.ObservedPrice, result.Rule, result.Threshold)
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/domain"
+)
+
+const (
+	defaultArchivePageSize = 50
+	maxArchivePageSize     = 200
+)
+
+// AlertArchiveHandler lists and restores archived alerts
+type AlertArchiveHandler struct {
+	archiveService domain.AlertArchiveService
+}
+
+func NewAlertArchiveHandler(archiveService domain.AlertArchiveService) *AlertArchiveHandler {
+	return &AlertArchiveHandler{archiveService: archiveService}
+}
+
+// GetArchivedAlerts returns one page of a user's archived alerts, most
+// recently archived first. page starts at 1 and pageSize defaults to 50,
+// capped at 200.
+func (h *AlertArchiveHandler) GetArchivedAlerts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page := int64(1)
+	if raw := query.Get("page"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 1 {
+			common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "page must be a positive integer")
+			return
+		}
+		page = parsed
+	}
+	pageSize := int64(defaultArchivePageSize)
+	if raw := query.Get("pageSize"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 1 || parsed > maxArchivePageSize {
+			common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", fmt.Sprintf("pageSize must be between 1 and %d", maxArchivePageSize))
+			return
+		}
+		pageSize = parsed
+	}
+	alerts, err := h.archiveService.GetArchivedAlerts(r.Context(), mux.Vars(r)["userId"], page, pageSize)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, alerts)
+}
+
+// RestoreAlert moves an archived alert back to the user's alerts
+func (h *AlertArchiveHandler) RestoreAlert(w http.ResponseWriter, r *http.Request) {
+	alert, err := h.archiveService.RestoreAlert(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, alert)
+}
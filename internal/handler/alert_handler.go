@@ -3,11 +3,15 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/hello-api/internal/common"
 	"github.com/hello-api/internal/domain"
 	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/money"
 )
 
 type AlertHandler struct {
@@ -18,13 +22,38 @@ func NewAlertHandler(alertService domain.AlertService) *AlertHandler {
 	return &AlertHandler{alertService: alertService}
 }
 
+// AlertMatchHandler serves the alerts a price would fire. It is separate from
+// AlertHandler because the matcher is the tick evaluator, built after the alert routes.
+type AlertMatchHandler struct {
+	matcher domain.AlertMatcher
+}
+
+func NewAlertMatchHandler(matcher domain.AlertMatcher) *AlertMatchHandler {
+	return &AlertMatchHandler{matcher: matcher}
+}
+
+// GetMatchingAlerts returns the active alerts ?symbol= would fire at ?price=
+func (h *AlertMatchHandler) GetMatchingAlerts(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.TrimSpace(r.URL.Query().Get("symbol"))
+	if symbol == "" {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "symbol is required")
+		return
+	}
+	price, err := money.Parse(r.URL.Query().Get("price"))
+	if err != nil || price <= 0 {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "price must be a positive number with at most two decimal places")
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, h.matcher.MatchingAlerts(symbol, price))
+}
+
 func (h *AlertHandler) CreateAlert(w http.ResponseWriter, r *http.Request) {
 	var req dto.AlertCreateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request format")
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", common.RequestFormatMessage(err))
 		return
 	}
-	alert, err := h.alertService.CreateAlert(req)
+	alert, err := h.alertService.CreateAlert(r.Context(), req)
 	if err != nil {
 		common.HandleError(w, err)
 		return
@@ -32,15 +61,26 @@ func (h *AlertHandler) CreateAlert(w http.ResponseWriter, r *http.Request) {
 	common.RespondWithSuccess(w, http.StatusCreated, alert)
 }
 
-func (h *AlertHandler) GetAlert(w http.ResponseWriter, r *http.Request) {
-	id := mux.Vars(r)["id"]
-	alert, err := h.alertService.GetAlertByID(id)
+// BacktestAlert reports the triggers an alert definition would have had over a date range
+func (h *AlertHandler) BacktestAlert(w http.ResponseWriter, r *http.Request) {
+	var req dto.AlertBacktestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", common.RequestFormatMessage(err))
+		return
+	}
+	result, err := h.alertService.BacktestAlert(r.Context(), req)
 	if err != nil {
 		common.HandleError(w, err)
 		return
 	}
-	if alert == nil {
-		common.RespondWithError(w, http.StatusNotFound, "NOT_FOUND", "Alert not found")
+	common.RespondWithSuccess(w, http.StatusOK, result)
+}
+
+func (h *AlertHandler) GetAlert(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	alert, err := h.alertService.GetAlertByID(r.Context(), id)
+	if err != nil {
+		common.HandleError(w, err)
 		return
 	}
 	common.RespondWithSuccess(w, http.StatusOK, alert)
@@ -48,7 +88,7 @@ func (h *AlertHandler) GetAlert(w http.ResponseWriter, r *http.Request) {
 
 func (h *AlertHandler) GetAlertsByUser(w http.ResponseWriter, r *http.Request) {
 	userId := mux.Vars(r)["userId"]
-	alerts, err := h.alertService.GetAlertsByUser(userId)
+	alerts, err := h.alertService.GetAlertsByUser(r.Context(), userId)
 	if err != nil {
 		common.HandleError(w, err)
 		return
@@ -60,10 +100,10 @@ func (h *AlertHandler) UpdateAlert(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 	var req dto.AlertCreateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request format")
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", common.RequestFormatMessage(err))
 		return
 	}
-	alert, err := h.alertService.UpdateAlert(id, req)
+	alert, err := h.alertService.UpdateAlert(r.Context(), id, req)
 	if err != nil {
 		common.HandleError(w, err)
 		return
@@ -73,9 +113,39 @@ func (h *AlertHandler) UpdateAlert(w http.ResponseWriter, r *http.Request) {
 
 func (h *AlertHandler) DeleteAlert(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
-	if err := h.alertService.DeleteAlert(id); err != nil {
+	if err := h.alertService.DeleteAlert(r.Context(), id); err != nil {
 		common.HandleError(w, err)
 		return
 	}
 	common.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Alert deleted"})
 }
+
+// GetAlertChanges returns the alert changes after the since cursor. With wait
+// (e.g. "30s") the request is held until a change arrives or the wait elapses.
+func (h *AlertHandler) GetAlertChanges(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var since int64
+	if raw := query.Get("since"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "since must be a non-negative cursor")
+			return
+		}
+		since = parsed
+	}
+	var wait time.Duration
+	if raw := query.Get("wait"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 {
+			common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "wait must be a duration such as 30s")
+			return
+		}
+		wait = parsed
+	}
+	changes, err := h.alertService.GetChanges(r.Context(), since, wait)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, changes)
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/internal/service"
+)
+
+// jsonShape returns the keys of a JSON object and, nested, of its object
+// values, e.g. [error error.code error.message success]
+func jsonShape(t *testing.T, body []byte) []string {
+	t.Helper()
+	var object map[string]interface{}
+	if err := json.Unmarshal(body, &object); err != nil {
+		t.Fatalf("undecodable body %q: %v", body, err)
+	}
+	var keys []string
+	for key, value := range object {
+		keys = append(keys, key)
+		if nested, ok := value.(map[string]interface{}); ok {
+			for nestedKey := range nested {
+				keys = append(keys, key+"."+nestedKey)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// A missing user and a missing alert are reported in the same JSON envelope
+func TestNotFoundShape(t *testing.T) {
+	alerts := NewAlertHandler(service.NewAlertService(repository.NewMemoryAlertRepository(), nil, nil, service.MarketSchedule{}, nil, nil, nil, nil, nil, service.AlertDateBounds{}))
+	r := newUserRouter(t)
+	r.HandleFunc("/alerts/{id}", alerts.GetAlert).Methods("GET")
+	r.HandleFunc("/alerts/{id}", alerts.DeleteAlert).Methods("DELETE")
+
+	const missing = "0123456789abcdef01234567"
+	want := []string{"error", "error.code", "error.message", "success"}
+	for _, method := range []string{"GET", "DELETE"} {
+		for _, path := range []string{"/users/" + missing, "/alerts/" + missing} {
+			t.Run(method+" "+path, func(t *testing.T) {
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+				if rec.Code != http.StatusNotFound {
+					t.Fatalf("got status %d, want 404", rec.Code)
+				}
+				if got := rec.Header().Get("Content-Type"); got != "application/json" {
+					t.Errorf("got Content-Type %q, want application/json", got)
+				}
+				if got := jsonShape(t, rec.Body.Bytes()); fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("got keys %v, want %v", got, want)
+				}
+				var response struct {
+					Success bool `json:"success"`
+					Error   struct {
+						Code string `json:"code"`
+					} `json:"error"`
+				}
+				json.Unmarshal(rec.Body.Bytes(), &response)
+				if response.Success || response.Error.Code != "NOT_FOUND" {
+					t.Errorf("got %s, want a NOT_FOUND error", rec.Body.String())
+				}
+			})
+		}
+	}
+}
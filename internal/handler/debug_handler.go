@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/service"
+	"github.com/hello-api/pkg/logging"
+)
+
+type DebugHandler struct {
+	slowRequests *logging.SlowRequests
+	events       *service.Broadcaster
+	evaluator    *service.TickEvaluator
+	// maxEntries caps the alerts or symbols one evaluator call returns
+	maxEntries int
+}
+
+func NewDebugHandler(slowRequests *logging.SlowRequests, events *service.Broadcaster, evaluator *service.TickEvaluator, maxEntries int) *DebugHandler {
+	return &DebugHandler{slowRequests: slowRequests, events: events, evaluator: evaluator, maxEntries: maxEntries}
+}
+
+// GetSlowRoutes returns today's slowest routes
+func (h *DebugHandler) GetSlowRoutes(w http.ResponseWriter, r *http.Request) {
+	common.RespondWithSuccess(w, http.StatusOK, h.slowRequests.Snapshot())
+}
+
+// ResetSlowRoutes drops today's route timings
+func (h *DebugHandler) ResetSlowRoutes(w http.ResponseWriter, r *http.Request) {
+	h.slowRequests.Reset()
+	common.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Route timings reset"})
+}
+
+// GetLiveConnections returns the live connections held, per user and in total, and their limits
+func (h *DebugHandler) GetLiveConnections(w http.ResponseWriter, r *http.Request) {
+	common.RespondWithSuccess(w, http.StatusOK, h.events.Stats())
+}
+
+// GetEvaluatorIndex returns the alerts the tick evaluator watches for {symbol}
+// or ?symbol, to tell an alert that never fires from one the evaluator never
+// sees. Without a symbol it summarizes every indexed symbol. ?limit, capped at
+// the configured maximum, bounds the page and ?after takes the previous
+// page's next.
+func (h *DebugHandler) GetEvaluatorIndex(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := h.maxEntries
+	if raw := query.Get("limit"); raw != "" {
+		requested, err := strconv.Atoi(raw)
+		if err != nil || requested < 1 {
+			common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "limit must be a positive integer")
+			return
+		}
+		limit = min(requested, h.maxEntries)
+	}
+
+	symbol := mux.Vars(r)["symbol"]
+	if symbol == "" {
+		symbol = query.Get("symbol")
+	}
+	if symbol == "" {
+		common.RespondWithSuccess(w, http.StatusOK, h.evaluator.IndexSummary(query.Get("after"), limit))
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, h.evaluator.IndexForSymbol(symbol, query.Get("after"), limit))
+}
+
+// ResyncEvaluatorIndex reloads the evaluator's alert index from the database
+// now and returns its sync state
+func (h *DebugHandler) ResyncEvaluatorIndex(w http.ResponseWriter, r *http.Request) {
+	status, err := h.evaluator.ResyncIndex(r.Context())
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, status)
+}
@@ -2,10 +2,13 @@ package dto
 
 import (
 	"time"
+
+	"github.com/hello-api/pkg/money"
 )
 
 type AlertStatus string
 type AlertRule string
+type AlertBaseline string
 
 const (
 	AlertStatusActive   AlertStatus = "active"
@@ -13,27 +16,394 @@ const (
 
 	AlertRuleAbove AlertRule = "above"
 	AlertRuleBelow AlertRule = "below"
+
+	// Percent rules fire when the price has moved Price percent up or down
+	// from the alert's baseline
+	AlertRulePercentChangeAbove AlertRule = "percent_change_above"
+	AlertRulePercentChangeBelow AlertRule = "percent_change_below"
+
+	// AlertBaselinePreviousClose is the default baseline of percent rules
+	AlertBaselinePreviousClose AlertBaseline = "previousClose"
+	AlertBaselineDayOpen       AlertBaseline = "dayOpen"
 )
 
+// IsPercentRule returns true for rules measured against a baseline price
+func (r AlertRule) IsPercentRule() bool {
+	return r == AlertRulePercentChangeAbove || r == AlertRulePercentChangeBelow
+}
+
 type AlertCreateRequest struct {
-	Name      string      `json:"name"`
-	Price     float64     `json:"price"`
-	Rule      AlertRule   `json:"rule"`
-	StopDate  time.Time   `json:"stopDate"`
-	StartDate time.Time   `json:"startDate"`
-	Status    AlertStatus `json:"status"`
-	UserID    string      `json:"userId"`
+	Name      string       `json:"name"`
+	Price     money.Amount `json:"price"`
+	Rule      AlertRule    `json:"rule"`
+	StopDate  time.Time    `json:"stopDate"`
+	StartDate time.Time    `json:"startDate"`
+	Status    AlertStatus  `json:"status"`
+	UserID    string       `json:"userId"`
+	// WebhookURL receives a POST for every trigger of the alert
+	WebhookURL string `json:"webhookUrl,omitempty"`
+	// EvaluateOffHours keeps evaluating the alert outside market hours
+	EvaluateOffHours bool `json:"evaluateOffHours"`
+	// Symbol is the instrument whose stored prices the alert watches; percent
+	// rules require it
+	Symbol string `json:"symbol,omitempty"`
+	// Baseline is the reference of percent rules, previousClose by default
+	Baseline AlertBaseline `json:"baseline,omitempty"`
+	// NotifyTelegram sends triggers to the Telegram chat the owner linked
+	NotifyTelegram bool `json:"notifyTelegram,omitempty"`
+	// NotifyEmail emails triggers to the owner, immediately or in the owner's
+	// hourly digest
+	NotifyEmail bool `json:"notifyEmail,omitempty"`
+	// Urgent notifications skip the owner's quiet hours and the hourly cap
+	Urgent bool `json:"urgent,omitempty"`
+	// NotifyOverride sends the alert's notifications to one destination
+	// instead of its webhook and the owner's channels
+	NotifyOverride *NotifyOverride `json:"notifyOverride,omitempty"`
+	// AppliedDefaults lists the fields filled in from the owner's alert
+	// defaults; set by the service, never by the client
+	AppliedDefaults []string `json:"-"`
+}
+
+// NotifyOverride is the single destination of an alert's notifications
+type NotifyOverride struct {
+	Channel NotificationChannel `json:"channel"`
+	// Target is a webhook URL, a Telegram chat ID or an email address
+	Target string `json:"target"`
 }
 
 type AlertResponse struct {
-	ID        string      `json:"id"`
-	Name      string      `json:"name"`
-	Price     float64     `json:"price"`
-	Rule      AlertRule   `json:"rule"`
-	StopDate  time.Time   `json:"stopDate"`
-	StartDate time.Time   `json:"startDate"`
-	Status    AlertStatus `json:"status"`
-	UserID    string      `json:"userId"`
-	CreatedAt time.Time   `json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
+	ID               string          `json:"id"`
+	Name             string          `json:"name"`
+	Price            money.Amount    `json:"price"`
+	Rule             AlertRule       `json:"rule"`
+	StopDate         time.Time       `json:"stopDate"`
+	StartDate        time.Time       `json:"startDate"`
+	Status           AlertStatus     `json:"status"`
+	UserID           string          `json:"userId"`
+	WebhookURL       string          `json:"webhookUrl,omitempty"`
+	EvaluateOffHours bool            `json:"evaluateOffHours"`
+	Symbol           string          `json:"symbol,omitempty"`
+	Baseline         AlertBaseline   `json:"baseline,omitempty"`
+	NotifyTelegram   bool            `json:"notifyTelegram,omitempty"`
+	NotifyEmail      bool            `json:"notifyEmail,omitempty"`
+	Urgent           bool            `json:"urgent,omitempty"`
+	NotifyOverride   *NotifyOverride `json:"notifyOverride,omitempty"`
+	// AppliedDefaults lists the fields that came from the owner's alert
+	// defaults when the alert was created
+	AppliedDefaults []string `json:"appliedDefaults,omitempty"`
+	// Shadow alerts are evaluated and fire, but their triggers are only
+	// recorded for admins, never notified; set by admins
+	Shadow bool `json:"shadow,omitempty"`
+	// Triggered is set when the alert fires on a tick and cleared once a tick
+	// no longer meets it, or when the alert is updated
+	Triggered       bool       `json:"triggered"`
+	LastTriggeredAt *time.Time `json:"lastTriggeredAt,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// The fields below are computed when an alert is read and never stored;
+	// they are left out of other responses and the change feed.
+
+	// IsExpired is set once the stop date has passed
+	IsExpired bool `json:"isExpired,omitempty"`
+	// IsSnoozed is set while the owner has muted notifications
+	IsSnoozed bool `json:"isSnoozed,omitempty"`
+	// CurrentPrice is the latest stored price of the symbol, absent when there
+	// is none
+	CurrentPrice *money.Amount `json:"currentPrice,omitempty"`
+	// DistanceToTriggerPercent is how far, in percent of the current price, the
+	// price still has to move in the rule's direction; zero or negative once
+	// the rule is met. It is absent without a current price or, for percent
+	// rules, without a baseline.
+	DistanceToTriggerPercent *float64 `json:"distanceToTriggerPercent,omitempty"`
+}
+
+// ArchivedAlertResponse is an alert moved to the archive after it fired and
+// stopped
+type ArchivedAlertResponse struct {
+	AlertResponse
+	ArchivedAt time.Time `json:"archivedAt"`
+}
+
+// ArchivedAlertPageResponse is one page of a user's archived alerts
+type ArchivedAlertPageResponse struct {
+	Items      []ArchivedAlertResponse `json:"items"`
+	Page       int64                   `json:"page"`
+	PageSize   int64                   `json:"pageSize"`
+	Total      int64                   `json:"total"`
+	TotalPages int64                   `json:"totalPages"`
+}
+
+// OrphanedAlertsRequest is one chunk of the orphaned alert scan
+type OrphanedAlertsRequest struct {
+	// DryRun only reports the orphans, without switching them off
+	DryRun bool
+	// Cursor resumes the scan after the alert with this id; empty starts it
+	Cursor string
+	// Limit bounds the alerts scanned by the chunk
+	Limit int64
+}
+
+// OrphanedAlertsResponse summarises one chunk of the orphaned alert scan
+type OrphanedAlertsResponse struct {
+	DryRun      bool  `json:"dryRun"`
+	Scanned     int   `json:"scanned"`
+	Orphaned    int   `json:"orphaned"`
+	Deactivated int64 `json:"deactivated"`
+	// NextCursor resumes the scan; empty once every alert has been scanned
+	NextCursor string                `json:"nextCursor"`
+	Users      []OrphanedUserSummary `json:"users"`
+}
+
+// OrphanedUserSummary counts the alerts of a userId no user has
+type OrphanedUserSummary struct {
+	UserID      string `json:"userId"`
+	Alerts      int    `json:"alerts"`
+	Active      int    `json:"active"`
+	Deactivated int64  `json:"deactivated"`
+}
+
+// AlertEvaluateRequest is the price an alert is re-evaluated against. It
+// defaults to the latest stored price of the alert's symbol.
+type AlertEvaluateRequest struct {
+	Price *money.Amount `json:"price"`
+	// ObservedAt is when the price was seen; it defaults to now
+	ObservedAt time.Time `json:"observedAt"`
+}
+
+// EvaluationGate is one check of an alert evaluation and why it passed or not
+type EvaluationGate struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Reason string `json:"reason"`
+}
+
+// AlertEvaluationResponse explains whether an alert fires for a price
+type AlertEvaluationResponse struct {
+	AlertID       string           `json:"alertId"`
+	Rule          AlertRule        `json:"rule"`
+	Threshold     money.Amount     `json:"threshold"`
+	ObservedPrice money.Amount     `json:"observedPrice"`
+	ObservedAt    time.Time        `json:"observedAt"`
+	Gates         []EvaluationGate `json:"gates"`
+	WouldFire     bool             `json:"wouldFire"`
+	// Triggered reports a trigger forced through the notification pipeline
+	Triggered    bool                  `json:"triggered"`
+	Notification *NotificationResponse `json:"notification,omitempty"`
+}
+
+type AlertChangeType string
+
+const (
+	AlertChangeCreated AlertChangeType = "created"
+	AlertChangeUpdated AlertChangeType = "updated"
+	AlertChangeDeleted AlertChangeType = "deleted"
+)
+
+// AlertChangeRequest records a mutation of an alert in the change feed
+type AlertChangeRequest struct {
+	Cursor int64
+	Alert  AlertResponse
+	Change AlertChangeType
+	At     time.Time
+}
+
+// AlertChangeResponse is one change feed entry; Cursor orders the feed
+type AlertChangeResponse struct {
+	Cursor  int64           `json:"cursor"`
+	AlertID string          `json:"alertId"`
+	UserID  string          `json:"userId"`
+	Symbol  string          `json:"symbol,omitempty"`
+	Status  AlertStatus     `json:"status"`
+	Change  AlertChangeType `json:"change"`
+	At      time.Time       `json:"at"`
+}
+
+// AlertChangesResponse is a page of the change feed. Cursor is the value to
+// pass as since on the next poll; HasMore is set when more changes are waiting.
+type AlertChangesResponse struct {
+	Changes []AlertChangeResponse `json:"changes"`
+	Cursor  int64                 `json:"cursor"`
+	HasMore bool                  `json:"hasMore"`
+}
+
+// AlertIndexSync is how current the tick evaluator's alert index is. The
+// index is reloaded in full from the repository, so the reload count stands in
+// for a sync cursor: a resync shows as a new generation.
+type AlertIndexSync struct {
+	// LoadedAt is when the index was last reloaded; zero until the first load
+	LoadedAt time.Time `json:"loadedAt"`
+	// Generation counts successful reloads since the process started
+	Generation int64 `json:"generation"`
+	// RefreshInterval is how often the index is reloaded without a change
+	RefreshInterval string `json:"refreshInterval"`
+	// LastError is why the last reload failed, absent once one succeeds
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// EvaluatorAlert is an alert as the tick evaluator holds it, with the rule
+// resolved against the symbol's last evaluated tick
+type EvaluatorAlert struct {
+	AlertResponse
+	// Shadowed is set when the alert runs in shadow mode, by itself or
+	// through its rule
+	Shadowed bool `json:"shadowed"`
+	// StateSource tells where Triggered comes from: "evaluator" once this
+	// process has moved the alert's state, "index" while it is as loaded
+	StateSource string `json:"stateSource"`
+	// BaselinePrice is the reference price of a percent rule; absent until a
+	// tick gives one
+	BaselinePrice *money.Amount `json:"baselinePrice,omitempty"`
+	// TriggerPrice is the price the rule fires at, for percent rules the
+	// price the baseline and percentage make, rounded to the paisa
+	TriggerPrice *money.Amount `json:"triggerPrice,omitempty"`
+	// LastSide is whether the last evaluated price meets the rule, "met" or
+	// "unmet"; absent before the symbol's first tick
+	LastSide string `json:"lastSide,omitempty"`
+}
+
+// EvaluatorIndexResponse is what the tick evaluator watches for a symbol: the
+// active alerts indexed under it, with the state the evaluator holds, at most
+// a page of them at a time
+type EvaluatorIndexResponse struct {
+	AlertIndexSync
+	// Symbol is the name as normalized for the index lookup
+	Symbol string           `json:"symbol"`
+	Alerts []EvaluatorAlert `json:"alerts"`
+	// LastPrice and LastTickAt are the symbol's last evaluated tick
+	LastPrice  *money.Amount `json:"lastPrice,omitempty"`
+	LastTickAt *time.Time    `json:"lastTickAt,omitempty"`
+	// Total counts the alerts under the symbol; Next is the ?after of the next
+	// page, absent on the last one
+	Total int    `json:"total"`
+	Next  string `json:"next,omitempty"`
+}
+
+// EvaluatorSymbolSummary counts the alerts the evaluator watches for a symbol
+type EvaluatorSymbolSummary struct {
+	Symbol    string        `json:"symbol"`
+	Alerts    int           `json:"alerts"`
+	Triggered int           `json:"triggered"`
+	LastPrice *money.Amount `json:"lastPrice,omitempty"`
+	// LastTickAt is when the symbol's last evaluated tick was priced
+	LastTickAt *time.Time `json:"lastTickAt,omitempty"`
+}
+
+// EvaluatorSummaryResponse is the evaluator's alert index across symbols, in
+// symbol order, at most a page of symbols at a time
+type EvaluatorSummaryResponse struct {
+	AlertIndexSync
+	Symbols []EvaluatorSymbolSummary `json:"symbols"`
+	// TotalSymbols and TotalAlerts count the whole index; Next is the ?after
+	// of the next page, absent on the last one
+	TotalSymbols int    `json:"totalSymbols"`
+	TotalAlerts  int    `json:"totalAlerts"`
+	Next         string `json:"next,omitempty"`
+}
+
+// AlertBacktestRequest replays an alert definition, which is not stored, over
+// the stored ticks of its symbol with From <= time < To
+type AlertBacktestRequest struct {
+	Alert AlertCreateRequest `json:"alert"`
+	From  time.Time          `json:"from"`
+	To    time.Time          `json:"to"`
+}
+
+// BacktestTrigger is a tick the alert would have fired on
+type BacktestTrigger struct {
+	TriggeredAt time.Time    `json:"triggeredAt"`
+	Price       money.Amount `json:"price"`
+	Reason      string       `json:"reason"`
+}
+
+// BacktestSummary sums up the triggers of a backtest
+type BacktestSummary struct {
+	Count   int        `json:"count"`
+	FirstAt *time.Time `json:"firstAt,omitempty"`
+	LastAt  *time.Time `json:"lastAt,omitempty"`
+	// MaxGap is the longest time between two consecutive triggers, e.g. "26h30m0s"
+	MaxGap string `json:"maxGap,omitempty"`
+}
+
+// AlertBacktestResponse lists the triggers an alert would have had. Triggers
+// lists the first ones only when Truncated is set; the summary counts all.
+type AlertBacktestResponse struct {
+	Symbol         string            `json:"symbol"`
+	From           time.Time         `json:"from"`
+	To             time.Time         `json:"to"`
+	TicksEvaluated int64             `json:"ticksEvaluated"`
+	Triggers       []BacktestTrigger `json:"triggers"`
+	Truncated      bool              `json:"truncated,omitempty"`
+	Summary        BacktestSummary   `json:"summary"`
+}
+
+// EvaluationOutcome is what the tick evaluator did with an alert
+type EvaluationOutcome string
+
+const (
+	EvaluationUnchanged EvaluationOutcome = "unchanged"
+	EvaluationFired     EvaluationOutcome = "fired"
+	// EvaluationFireConflict is a fire another evaluation had already made
+	EvaluationFireConflict EvaluationOutcome = "fire_conflict"
+	// EvaluationFireFailed is a fire that could not be recorded, e.g. outside
+	// market hours; the alert is re-armed
+	EvaluationFireFailed  EvaluationOutcome = "fire_failed"
+	EvaluationRearmed     EvaluationOutcome = "rearmed"
+	EvaluationRearmFailed EvaluationOutcome = "rearm_failed"
+)
+
+// AlertShadowRequest switches an alert's shadow mode on or off
+type AlertShadowRequest struct {
+	Shadow bool `json:"shadow"`
+}
+
+// EvaluationSamplingRequest samples every evaluation of an alert for TTL
+// (e.g. "30m"), one hour when empty
+type EvaluationSamplingRequest struct {
+	TTL string `json:"ttl"`
+}
+
+// EvaluationSamplingResponse is how long an alert's evaluations are sampled
+type EvaluationSamplingResponse struct {
+	AlertID   string    `json:"alertId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// EvaluationSampleRequest records one sampled tick evaluation of an alert
+type EvaluationSampleRequest struct {
+	AlertID  string
+	Symbol   string
+	Price    money.Amount
+	TickTime time.Time
+	// WasTriggered is the alert's state before the tick
+	WasTriggered bool
+	Gates        []EvaluationGate
+	Outcome      EvaluationOutcome
+	// Detail explains failed outcomes
+	Detail string
+	// SampledBy is "alert" for alerts sampled through the admin endpoint,
+	// "rate" for the global sampling rate and "shadow" for shadow fires
+	SampledBy string
+	// Shadow marks a fire of an alert in shadow mode, recorded instead of
+	// notified
+	Shadow      bool
+	EvaluatedAt time.Time
+	ExpiresAt   time.Time
+}
+
+type EvaluationSampleResponse struct {
+	ID           string            `json:"id"`
+	AlertID      string            `json:"alertId"`
+	Symbol       string            `json:"symbol"`
+	Price        money.Amount      `json:"price"`
+	TickTime     time.Time         `json:"tickTime"`
+	WasTriggered bool              `json:"wasTriggered"`
+	Gates        []EvaluationGate  `json:"gates"`
+	Outcome      EvaluationOutcome `json:"outcome"`
+	Detail       string            `json:"detail,omitempty"`
+	SampledBy    string            `json:"sampledBy"`
+	Shadow       bool              `json:"shadow,omitempty"`
+	EvaluatedAt  time.Time         `json:"evaluatedAt"`
+	ExpiresAt    time.Time         `json:"expiresAt"`
 }
@@ -0,0 +1,34 @@
+package dto
+
+import "time"
+
+// HolidayRequest adds a market holiday; Date is in YYYY-MM-DD form
+type HolidayRequest struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+type HolidayResponse struct {
+	Date      string    `json:"date"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MarketCalendarResponse is the trading schedule and the holidays it skips
+type MarketCalendarResponse struct {
+	Timezone    string            `json:"timezone"`
+	TradingDays []string          `json:"tradingDays"`
+	Open        string            `json:"open"`
+	Close       string            `json:"close"`
+	Holidays    []HolidayResponse `json:"holidays"`
+}
+
+// MarketSession reports whether the market is open at a time and, if not, why
+type MarketSession struct {
+	Open bool `json:"open"`
+	// Reason is weekend, holiday, before_open or after_close when closed
+	Reason string `json:"reason,omitempty"`
+	Detail string `json:"detail"`
+	// TradingDate is the market-local date (YYYY-MM-DD) of the time
+	TradingDate string `json:"tradingDate"`
+}
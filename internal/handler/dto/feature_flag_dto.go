@@ -0,0 +1,37 @@
+package dto
+
+import "time"
+
+// FeatureFlagSource is where a flag's current value comes from
+type FeatureFlagSource string
+
+const (
+	FeatureFlagSourceDefault FeatureFlagSource = "default"
+	FeatureFlagSourceEnv     FeatureFlagSource = "env"
+	FeatureFlagSourceAdmin   FeatureFlagSource = "admin"
+)
+
+// FeatureFlagRequest sets a flag. Value is written as in the flag's
+// environment variable, e.g. "off" or "percent_change_above,percent_change_below".
+type FeatureFlagRequest struct {
+	Value string `json:"value"`
+}
+
+// FeatureFlagOverride is a flag value set through the admin route
+type FeatureFlagOverride struct {
+	Name      string    `json:"name"`
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// FeatureFlagResponse is a flag with its current value and where it comes from
+type FeatureFlagResponse struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Env         string            `json:"env"`
+	Default     string            `json:"default"`
+	Value       string            `json:"value"`
+	Source      FeatureFlagSource `json:"source"`
+	// UpdatedAt is when an admin set the value; nil for other sources
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
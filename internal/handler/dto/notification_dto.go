@@ -0,0 +1,100 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hello-api/pkg/money"
+)
+
+type NotificationStatus string
+
+const (
+	// NotificationStatusPending is waiting for its next delivery attempt
+	NotificationStatusPending NotificationStatus = "pending"
+	// NotificationStatusInFlight is claimed by a worker
+	NotificationStatusInFlight NotificationStatus = "in_flight"
+	// NotificationStatusDelivered was accepted by the destination
+	NotificationStatusDelivered NotificationStatus = "delivered"
+	// NotificationStatusFailed gave up after the maximum age; kept for inspection
+	NotificationStatusFailed NotificationStatus = "failed"
+)
+
+// NotificationChannel is how a notification reaches its destination
+type NotificationChannel string
+
+const (
+	// NotificationChannelWebhook POSTs the trigger to a URL
+	NotificationChannelWebhook NotificationChannel = "webhook"
+	// NotificationChannelTelegram messages a Telegram chat through the bot
+	NotificationChannelTelegram NotificationChannel = "telegram"
+	// NotificationChannelEmail emails the alert owner over SMTP
+	NotificationChannelEmail NotificationChannel = "email"
+)
+
+// AlertTriggerRequest reports a trigger of an alert by the data feed
+type AlertTriggerRequest struct {
+	// TriggerID identifies the trigger so redelivered reports are queued once
+	TriggerID   string       `json:"triggerId"`
+	Price       money.Amount `json:"price"`
+	Reason      string       `json:"reason"`
+	TriggeredAt time.Time    `json:"triggeredAt"`
+	// Lag is how stale the tick that fired the alert was, when known
+	Lag *TickLag `json:"lag,omitempty"`
+}
+
+// NotificationEnqueueRequest is a delivery to add to the outbox
+type NotificationEnqueueRequest struct {
+	AlertID   string
+	TriggerID string
+	// Channel defaults to webhook; Destination is a URL or a Telegram chat id
+	Channel     NotificationChannel
+	Destination string
+	Payload     json.RawMessage
+	// NotBefore holds the first attempt back, e.g. until the owner's quiet hours end
+	NotBefore time.Time
+}
+
+type NotificationResponse struct {
+	ID            string              `json:"id"`
+	AlertID       string              `json:"alertId"`
+	TriggerID     string              `json:"triggerId"`
+	Channel       NotificationChannel `json:"channel"`
+	Destination   string              `json:"destination"`
+	Payload       json.RawMessage     `json:"payload"`
+	Status        NotificationStatus  `json:"status"`
+	Attempts      int                 `json:"attempts"`
+	NextAttemptAt time.Time           `json:"nextAttemptAt"`
+	LastError     string              `json:"lastError,omitempty"`
+	DeliveredAt   *time.Time          `json:"deliveredAt,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+}
+
+// EmailDigestItem is a trigger held for a user's digest email
+type EmailDigestItem struct {
+	UserID      string
+	Email       string
+	AlertID     string
+	TriggerID   string
+	WindowStart time.Time
+	Payload     json.RawMessage
+}
+
+// EmailDigestGroup is one user's digest window
+type EmailDigestGroup struct {
+	UserID      string
+	Email       string
+	WindowStart time.Time
+}
+
+// NotificationThrottleWindow counts a user's notifications over one channel in
+// one clock hour
+type NotificationThrottleWindow struct {
+	UserID      string
+	Channel     NotificationChannel
+	Destination string
+	WindowStart time.Time
+	WindowEnd   time.Time
+	Count       int64
+}
@@ -0,0 +1,149 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/hello-api/pkg/money"
+)
+
+type QuarantineStatus string
+
+const (
+	// QuarantineStatusHeld is waiting for review
+	QuarantineStatusHeld QuarantineStatus = "quarantined"
+	// QuarantineStatusReleased was judged legitimate and stored as a price tick
+	QuarantineStatusReleased QuarantineStatus = "released"
+)
+
+// PriceTickRequest is a single tick reported by the data feed
+type PriceTickRequest struct {
+	Symbol string       `json:"symbol"`
+	Price  money.Amount `json:"price"`
+	Volume float64      `json:"volume"`
+	Time   time.Time    `json:"time"`
+	// Name and Sector describe the symbol when the feed knows them; they go
+	// to the symbol reference data rather than the tick
+	Name   string `json:"name,omitempty"`
+	Sector string `json:"sector,omitempty"`
+	// ExchangeTime is the time the exchange stamped the price with, when the
+	// feed carries one
+	ExchangeTime *time.Time `json:"exchangeTime,omitempty"`
+	// FeedLagMs is how long after ExchangeTime the data feed processed the
+	// tick, measured by the data feed on the exchange's clock
+	FeedLagMs *int64 `json:"feedLagMs,omitempty"`
+	// Lag is filled in on ingest from the fields above and the ingest time
+	Lag *TickLag `json:"-"`
+}
+
+// TickLag is how stale a tick was when the API ingested it
+type TickLag struct {
+	// AsOf is when the price was current: its exchange time, or the time the
+	// data feed processed it when the feed carries none
+	AsOf time.Time `json:"asOf"`
+	// FeedMs is from the exchange time to the data feed processing the tick;
+	// nil without an exchange time
+	FeedMs *int64 `json:"feedMs,omitempty"`
+	// IngestMs is from the data feed processing the tick to the API ingesting it
+	IngestMs int64 `json:"ingestMs"`
+	// TotalMs is the worst case end to end, both parts added. Without FeedMs
+	// it only covers ingestion: the tick was at least that late.
+	TotalMs int64 `json:"totalMs"`
+}
+
+// Partial reports whether the lag misses the feed part, for lack of an exchange time
+func (l *TickLag) Partial() bool {
+	return l.FeedMs == nil
+}
+
+// Total returns the end to end lag as a duration
+func (l *TickLag) Total() time.Duration {
+	return time.Duration(l.TotalMs) * time.Millisecond
+}
+
+// PriceIngestRequest is a batch of ticks, applied in order
+type PriceIngestRequest struct {
+	Ticks []PriceTickRequest `json:"ticks"`
+	// Backfill marks ticks recorded earlier and sent late, e.g. replayed from a
+	// capture after a forwarder outage. They are stored once however often
+	// they are sent, the day statistics of their trading dates are recomputed
+	// and alerts only evaluate them in shadow mode.
+	Backfill bool `json:"backfill,omitempty"`
+}
+
+type PriceTickResponse struct {
+	ID        string       `json:"id"`
+	Symbol    string       `json:"symbol"`
+	Price     money.Amount `json:"price"`
+	Volume    float64      `json:"volume"`
+	Time      time.Time    `json:"time"`
+	CreatedAt time.Time    `json:"created_at"`
+	// Lag is how stale the tick was when ingested; nil for backfilled ticks
+	Lag *TickLag `json:"lag,omitempty"`
+	// Latest is the symbol's latest price after the tick; nil when the tick
+	// belongs to an earlier trading date than the stored one
+	Latest *LatestPriceResponse `json:"latest,omitempty"`
+}
+
+// LatestPriceResponse is the latest price of a symbol with the statistics of
+// its trading day
+type LatestPriceResponse struct {
+	Symbol string       `json:"symbol"`
+	Price  money.Amount `json:"price"`
+	Time   time.Time    `json:"time"`
+	// TradingDate is the exchange-local date (YYYY-MM-DD) the day statistics belong to
+	TradingDate string       `json:"tradingDate"`
+	DayOpen     money.Amount `json:"dayOpen"`
+	DayHigh     money.Amount `json:"dayHigh"`
+	DayLow      money.Amount `json:"dayLow"`
+	// PreviousClose is the last price of the previous trading day seen, if any
+	PreviousClose *money.Amount `json:"previousClose,omitempty"`
+	// Volume is the cumulative volume of the trading day
+	Volume float64 `json:"volume"`
+	// Lag is how stale the latest price was when ingested, if known
+	Lag       *TickLag  `json:"lag,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// QuarantineRequest holds back a tick that failed the sanity checks
+type QuarantineRequest struct {
+	Tick   PriceTickRequest
+	Reason string
+	// LastAcceptedPrice is the price the tick was compared with, if any
+	LastAcceptedPrice *money.Amount
+}
+
+type QuarantinedTickResponse struct {
+	ID                string           `json:"id"`
+	Symbol            string           `json:"symbol"`
+	Price             money.Amount     `json:"price"`
+	Volume            float64          `json:"volume"`
+	Time              time.Time        `json:"time"`
+	Reason            string           `json:"reason"`
+	LastAcceptedPrice *money.Amount    `json:"lastAcceptedPrice,omitempty"`
+	Status            QuarantineStatus `json:"status"`
+	ReleasedAt        *time.Time       `json:"releasedAt,omitempty"`
+	CreatedAt         time.Time        `json:"created_at"`
+}
+
+// PriceIngestResponse summarises an ingested batch
+type PriceIngestResponse struct {
+	Accepted    int                       `json:"accepted"`
+	Quarantined []QuarantinedTickResponse `json:"quarantined"`
+	// Fired counts the alerts fired by the accepted ticks
+	Fired int `json:"fired"`
+	// Duplicates counts backfilled ticks that were stored already
+	Duplicates int `json:"duplicates,omitempty"`
+	// ShadowFired counts the alerts backfilled ticks fired in shadow mode
+	ShadowFired int `json:"shadowFired,omitempty"`
+	// Recomputed lists the symbol trading dates a backfill recomputed
+	Recomputed []RecomputedDay `json:"recomputed,omitempty"`
+}
+
+// RecomputedDay is a symbol's trading date whose day statistics a backfill rebuilt
+type RecomputedDay struct {
+	Symbol      string `json:"symbol"`
+	TradingDate string `json:"tradingDate"`
+	// Latest is the symbol's latest price after the recompute; nil when the
+	// stored latest price is on a later trading date and was left alone
+	Latest *LatestPriceResponse `json:"latest,omitempty"`
+}
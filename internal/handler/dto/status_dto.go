@@ -0,0 +1,35 @@
+package dto
+
+import "time"
+
+// StatusResponse is the public, coarse view of whether alerts are working. It
+// holds no per-user information.
+type StatusResponse struct {
+	Ingestion IngestionStatus `json:"ingestion"`
+	// TriggersToday counts the alert triggers recorded on the current trading date
+	TriggersToday int64         `json:"triggersToday"`
+	Market        MarketSession `json:"market"`
+	GeneratedAt   time.Time     `json:"generatedAt"`
+}
+
+// IngestionStatus tells whether price ticks are arriving
+type IngestionStatus struct {
+	// Receiving is set when a tick was processed within StaleAfter
+	Receiving  bool   `json:"receiving"`
+	StaleAfter string `json:"staleAfter"`
+	// LatestTickAt is the time of the most recently processed tick, if any
+	LatestTickAt *time.Time `json:"latestTickAt,omitempty"`
+	// LatestProcessedAt is when that tick was processed
+	LatestProcessedAt *time.Time `json:"latestProcessedAt,omitempty"`
+	// Symbols gives the freshness of every symbol with a tick processed within
+	// StaleAfter, in symbol order
+	Symbols []SymbolFreshness `json:"symbols"`
+}
+
+// SymbolFreshness is how stale the latest price of a symbol was when ingested
+type SymbolFreshness struct {
+	Symbol       string    `json:"symbol"`
+	LatestTickAt time.Time `json:"latestTickAt"`
+	// Lag is unknown for a price that was backfilled or released from quarantine
+	Lag *TickLag `json:"lag,omitempty"`
+}
@@ -0,0 +1,35 @@
+package dto
+
+import "time"
+
+// SymbolRequest is reference data for one tradable symbol. Empty name and
+// sector leave the stored ones as they are.
+type SymbolRequest struct {
+	Symbol string `json:"symbol"`
+	Name   string `json:"name,omitempty"`
+	Sector string `json:"sector,omitempty"`
+}
+
+type SymbolResponse struct {
+	Symbol string `json:"symbol"`
+	Name   string `json:"name,omitempty"`
+	Sector string `json:"sector,omitempty"`
+	// LastSeenAt is when the data feed last reported a tick of the symbol;
+	// nil for symbols only known from an import
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// SymbolImportResponse summarises an uploaded symbol list
+type SymbolImportResponse struct {
+	Imported int `json:"imported"`
+	// Skipped lists the rows that were not imported and why
+	Skipped []SymbolImportSkip `json:"skipped"`
+}
+
+// SymbolImportSkip is a rejected row of a symbol list; rows count from 1
+type SymbolImportSkip struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+}
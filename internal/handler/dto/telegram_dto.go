@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+// TelegramLinkResponse is a one-time code that links a Telegram chat to the
+// requesting user once it is sent to the bot
+type TelegramLinkResponse struct {
+	Code string `json:"code"`
+	// DeepLink opens the bot with the code prefilled; empty unless the bot
+	// username is configured
+	DeepLink  string    `json:"deepLink,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// TelegramUpdate is the part of a Bot API update the webhook handles
+type TelegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *TelegramMessage `json:"message,omitempty"`
+}
+
+type TelegramMessage struct {
+	MessageID int64        `json:"message_id"`
+	Chat      TelegramChat `json:"chat"`
+	Text      string       `json:"text"`
+}
+
+type TelegramChat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
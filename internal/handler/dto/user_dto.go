@@ -4,14 +4,76 @@ import (
 	"time"
 )
 
+// EmailMode is how a user receives alert emails
+type EmailMode string
+
+const (
+	// EmailModeImmediate sends an email for every trigger; the default
+	EmailModeImmediate EmailMode = "immediate"
+	// EmailModeHourly batches the triggers of each clock hour into one digest
+	EmailModeHourly EmailMode = "hourly"
+)
+
+// QuietHours is a daily window in which non-urgent notifications are held
+// until it ends. Start and End are "HH:MM"; the window may span midnight, e.g.
+// 22:00 to 07:00.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Timezone is an IANA name such as "Asia/Dhaka"; the market timezone when empty
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// NotificationPreferences are how a user wants to be notified
+type NotificationPreferences struct {
+	QuietHours *QuietHours `json:"quietHours,omitempty"`
+	// DisplayTimezone is the IANA timezone times are shown in in Telegram
+	// messages and emails; the market timezone when empty. Stored times and
+	// API responses stay in UTC.
+	DisplayTimezone string `json:"displayTimezone,omitempty"`
+}
+
 // UserResponse is the DTO used for API responses
 type UserResponse struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"userId"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	// TelegramLinked is set once the user linked a Telegram chat
+	TelegramLinked bool      `json:"telegramLinked"`
+	EmailMode      EmailMode `json:"emailMode"`
+	// NotificationPreferences apply to Telegram messages and emails
+	NotificationPreferences NotificationPreferences `json:"notificationPreferences"`
+	// MutedUntil is set while every notification of the user is muted
+	MutedUntil *time.Time `json:"mutedUntil,omitempty"`
+	// AlertDefaults fill in the fields the user's new alerts leave unset
+	AlertDefaults *AlertDefaults `json:"alertDefaults,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// AlertDefaults are applied to the fields a new alert of the user leaves
+// unset; {} clears them
+type AlertDefaults struct {
+	// DurationDays sets the stop date that many days after the start date
+	DurationDays int `json:"defaultDurationDays,omitempty"`
+	// Channels turn on telegram and email notifications for alerts that
+	// choose no notification channel
+	Channels []NotificationChannel `json:"defaultChannels,omitempty"`
+}
+
+// UserPageResponse is one page of the user listing
+type UserPageResponse struct {
+	Items      []UserResponse `json:"items"`
+	Page       int64          `json:"page"`
+	PageSize   int64          `json:"pageSize"`
+	Total      int64          `json:"total"`
+	TotalPages int64          `json:"totalPages"`
+}
+
+// UserCountResponse is the DTO for the total number of users
+type UserCountResponse struct {
+	Count int64 `json:"count"`
 }
 
 // UserCreateRequest is the DTO for creating a new user
@@ -19,10 +81,31 @@ type UserCreateRequest struct {
 	UserID string `json:"userId"`
 	Name   string `json:"name"`
 	Email  string `json:"email"`
+	// EmailMode defaults to immediate
+	EmailMode               EmailMode                `json:"emailMode,omitempty"`
+	NotificationPreferences *NotificationPreferences `json:"notificationPreferences,omitempty"`
+}
+
+// UserPatchRequest changes only the fields present in the body. A field set
+// to "" is cleared; a missing or null field is left unchanged.
+type UserPatchRequest struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
+// UserMuteRequest mutes every notification of a user, either until a time or
+// for a duration such as "2h"; exactly one must be given
+type UserMuteRequest struct {
+	Until *time.Time `json:"until,omitempty"`
+	For   string     `json:"for,omitempty"`
 }
 
 // UserUpdateRequest is the DTO for updating an existing user
 type UserUpdateRequest struct {
 	Name  string `json:"name,omitempty"`
 	Email string `json:"email,omitempty"`
+	// EmailMode is left unchanged when empty
+	EmailMode EmailMode `json:"emailMode,omitempty"`
+	// NotificationPreferences replace the current ones when set; {} clears them
+	NotificationPreferences *NotificationPreferences `json:"notificationPreferences,omitempty"`
 }
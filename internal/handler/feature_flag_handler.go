@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/service"
+)
+
+type FeatureFlagHandler struct {
+	flags *service.FeatureFlags
+}
+
+func NewFeatureFlagHandler(flags *service.FeatureFlags) *FeatureFlagHandler {
+	return &FeatureFlagHandler{flags: flags}
+}
+
+// GetFlags returns every feature flag with its current value and its source
+func (h *FeatureFlagHandler) GetFlags(w http.ResponseWriter, r *http.Request) {
+	common.RespondWithSuccess(w, http.StatusOK, h.flags.List())
+}
+
+// SetFlag sets a feature flag on every replica, within one refresh interval
+func (h *FeatureFlagHandler) SetFlag(w http.ResponseWriter, r *http.Request) {
+	var req dto.FeatureFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request format")
+		return
+	}
+	flag, err := h.flags.Set(r.Context(), mux.Vars(r)["name"], req)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, flag)
+}
+
+// ClearFlag drops the value an admin set, returning the flag to its
+// environment value or default
+func (h *FeatureFlagHandler) ClearFlag(w http.ResponseWriter, r *http.Request) {
+	flag, err := h.flags.Clear(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, flag)
+}
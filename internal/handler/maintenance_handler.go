@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+)
+
+// MaintenanceHandler serves the admin data repair routes
+type MaintenanceHandler struct {
+	alertMaintenance domain.AlertMaintenanceService
+}
+
+func NewMaintenanceHandler(alertMaintenance domain.AlertMaintenanceService) *MaintenanceHandler {
+	return &MaintenanceHandler{alertMaintenance: alertMaintenance}
+}
+
+// CleanupOrphanedAlerts scans one chunk of alerts for userIds without a user.
+// ?dryRun defaults to true, so orphans are only switched off with
+// dryRun=false; ?cursor takes the previous response's nextCursor and ?limit
+// bounds the chunk.
+func (h *MaintenanceHandler) CleanupOrphanedAlerts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := dto.OrphanedAlertsRequest{DryRun: true, Cursor: query.Get("cursor")}
+	if raw := query.Get("dryRun"); raw != "" {
+		dryRun, err := strconv.ParseBool(raw)
+		if err != nil {
+			common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "dryRun must be true or false")
+			return
+		}
+		req.DryRun = dryRun
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || limit < 1 {
+			common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "limit must be a positive integer")
+			return
+		}
+		req.Limit = limit
+	}
+
+	result, err := h.alertMaintenance.CleanupOrphanedAlerts(r.Context(), req)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, result)
+}
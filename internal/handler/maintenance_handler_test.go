@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/internal/repository/entity"
+	"github.com/hello-api/internal/service"
+	"github.com/hello-api/pkg/money"
+)
+
+// newMaintenanceRouter routes the orphaned alert scan over alerts of alice,
+// who exists, and of ghost and bob, who do not
+func newMaintenanceRouter(t *testing.T) (*mux.Router, *repository.MemoryAlertRepository) {
+	t.Helper()
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	if _, err := users.Create(ctx, &entity.UserEntity{UserID: "alice", Name: "Alice", Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	alerts := repository.NewMemoryAlertRepository()
+	for _, alert := range []struct {
+		userID string
+		status dto.AlertStatus
+	}{
+		{"alice", dto.AlertStatusActive},
+		{"ghost", dto.AlertStatusActive},
+		{"alice", dto.AlertStatusActive},
+		{"ghost", dto.AlertStatusInactive},
+		{"bob", dto.AlertStatusActive},
+		{"ghost", dto.AlertStatusActive},
+	} {
+		_, err := alerts.Create(ctx, &dto.AlertCreateRequest{
+			UserID: alert.userID, Symbol: "GP", Rule: dto.AlertRuleAbove, Price: money.FromFloat(100), Status: alert.status,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := NewMaintenanceHandler(service.NewAlertMaintenanceService(alerts, users, nil, nil))
+	r := mux.NewRouter()
+	r.HandleFunc("/admin/maintenance/orphaned-alerts", h.CleanupOrphanedAlerts).Methods("POST")
+	return r, alerts
+}
+
+// activeAlerts counts the active alerts of each userId
+func activeAlerts(t *testing.T, alerts *repository.MemoryAlertRepository) map[string]int {
+	t.Helper()
+	active, err := alerts.FindActive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, alert := range active {
+		counts[alert.UserID]++
+	}
+	return counts
+}
+
+// A dry run reports the orphans per userId and changes nothing; a real run
+// switches off the active orphans only
+func TestCleanupOrphanedAlerts(t *testing.T) {
+	r, alerts := newMaintenanceRouter(t)
+	wantUsers := []dto.OrphanedUserSummary{
+		{UserID: "bob", Alerts: 1, Active: 1},
+		{UserID: "ghost", Alerts: 3, Active: 2},
+	}
+
+	for _, path := range []string{"/admin/maintenance/orphaned-alerts", "/admin/maintenance/orphaned-alerts?dryRun=true"} {
+		var result dto.OrphanedAlertsResponse
+		if code, _ := serve(t, r, "POST", path, "", &result); code != http.StatusOK {
+			t.Fatalf("POST %s returned %d, want 200", path, code)
+		}
+		if !result.DryRun || result.Scanned != 6 || result.Orphaned != 4 || result.Deactivated != 0 || result.NextCursor != "" {
+			t.Errorf("POST %s returned %+v, want a dry run of 6 alerts with 4 orphans", path, result)
+		}
+		if len(result.Users) != len(wantUsers) || result.Users[0] != wantUsers[0] || result.Users[1] != wantUsers[1] {
+			t.Errorf("POST %s returned users %+v, want %+v", path, result.Users, wantUsers)
+		}
+		if got := activeAlerts(t, alerts); got["alice"] != 2 || got["ghost"] != 2 || got["bob"] != 1 {
+			t.Errorf("got active alerts %v after a dry run, want them unchanged", got)
+		}
+	}
+
+	var result dto.OrphanedAlertsResponse
+	if code, _ := serve(t, r, "POST", "/admin/maintenance/orphaned-alerts?dryRun=false", "", &result); code != http.StatusOK {
+		t.Fatalf("the cleanup returned %d, want 200", code)
+	}
+	if result.DryRun || result.Orphaned != 4 || result.Deactivated != 3 {
+		t.Errorf("got %+v, want 3 of the 4 orphans switched off", result)
+	}
+	if got := activeAlerts(t, alerts); got["alice"] != 2 || got["ghost"] != 0 || got["bob"] != 0 {
+		t.Errorf("got active alerts %v, want only alice's 2", got)
+	}
+
+	// a second run finds the orphans again, all of them already off
+	serve(t, r, "POST", "/admin/maintenance/orphaned-alerts?dryRun=false", "", &result)
+	if result.Orphaned != 4 || result.Deactivated != 0 {
+		t.Errorf("got %+v on the second run, want 4 orphans and none switched off", result)
+	}
+}
+
+// The scan runs in chunks of limit alerts, each resumed from the previous
+// chunk's nextCursor, and together they cover every alert once
+func TestCleanupOrphanedAlertsCursor(t *testing.T) {
+	r, alerts := newMaintenanceRouter(t)
+
+	scanned, orphaned, chunks := 0, 0, 0
+	cursor := ""
+	for {
+		var result dto.OrphanedAlertsResponse
+		path := "/admin/maintenance/orphaned-alerts?dryRun=false&limit=4&cursor=" + cursor
+		if code, _ := serve(t, r, "POST", path, "", &result); code != http.StatusOK {
+			t.Fatalf("POST %s returned %d, want 200", path, code)
+		}
+		chunks++
+		scanned += result.Scanned
+		orphaned += result.Orphaned
+		if result.NextCursor == "" {
+			break
+		}
+		if chunks > 3 {
+			t.Fatal("the scan does not end")
+		}
+		cursor = result.NextCursor
+	}
+	if chunks != 2 || scanned != 6 || orphaned != 4 {
+		t.Errorf("got %d chunks scanning %d alerts with %d orphans, want 2, 6 and 4", chunks, scanned, orphaned)
+	}
+	if got := activeAlerts(t, alerts); got["alice"] != 2 || len(got) != 1 {
+		t.Errorf("got active alerts %v, want only alice's 2", got)
+	}
+}
+
+// Malformed parameters are rejected before anything is scanned
+func TestCleanupOrphanedAlertsValidation(t *testing.T) {
+	r, alerts := newMaintenanceRouter(t)
+	for _, tc := range []struct {
+		query string
+		code  string
+	}{
+		{"?dryRun=maybe", "INVALID_REQUEST"},
+		{"?dryRun=false&limit=0", "INVALID_REQUEST"},
+		{"?dryRun=false&limit=ten", "INVALID_REQUEST"},
+		{"?dryRun=false&limit=5001", "VALIDATION_ERROR"},
+	} {
+		code, response := serve(t, r, "POST", "/admin/maintenance/orphaned-alerts"+tc.query, "", nil)
+		if code != http.StatusBadRequest || response.Error == nil || response.Error.Code != tc.code {
+			t.Errorf("%s returned %d with %+v, want 400 %s", tc.query, code, response.Error, tc.code)
+		}
+	}
+	if got := activeAlerts(t, alerts); got["ghost"] != 2 || got["bob"] != 1 {
+		t.Errorf("got active alerts %v, want them unchanged", got)
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+)
+
+type NotificationHandler struct {
+	notificationService domain.NotificationService
+}
+
+func NewNotificationHandler(notificationService domain.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationService}
+}
+
+// RecordTrigger queues the alert's webhook for a trigger reported by the data feed
+func (h *NotificationHandler) RecordTrigger(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var req dto.AlertTriggerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", common.RequestFormatMessage(err))
+		return
+	}
+	notification, err := h.notificationService.RecordTrigger(r.Context(), id, req)
+	if errors.Is(err, domain.ErrOutsideMarketHours) {
+		common.RespondWithSuccess(w, http.StatusAccepted, map[string]string{"message": "Trigger skipped: " + err.Error()})
+		return
+	}
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	if notification == nil {
+		common.RespondWithSuccess(w, http.StatusAccepted, map[string]string{"message": "Trigger recorded; no notification queued"})
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusAccepted, notification)
+}
+
+// GetAlertNotifications lists the delivery status of every trigger of an alert
+func (h *NotificationHandler) GetAlertNotifications(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	notifications, err := h.notificationService.GetAlertNotifications(r.Context(), id)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, notifications)
+}
+
+// GetNotifications lists deliveries by status (?status=failed by default)
+func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = string(dto.NotificationStatusFailed)
+	}
+	notifications, err := h.notificationService.GetNotificationsByStatus(r.Context(), status)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, notifications)
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+)
+
+type PriceHandler struct {
+	priceService domain.PriceService
+}
+
+func NewPriceHandler(priceService domain.PriceService) *PriceHandler {
+	return &PriceHandler{priceService: priceService}
+}
+
+// IngestPrices stores a batch of ticks reported by the data feed, quarantining
+// those that fail the sanity checks
+func (h *PriceHandler) IngestPrices(w http.ResponseWriter, r *http.Request) {
+	var req dto.PriceIngestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", common.RequestFormatMessage(err))
+		return
+	}
+	result, err := h.priceService.Ingest(r.Context(), req)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusAccepted, result)
+}
+
+// GetLatestPrice returns a symbol's latest price with its day open, high, low,
+// previous close and volume
+func (h *PriceHandler) GetLatestPrice(w http.ResponseWriter, r *http.Request) {
+	symbol := mux.Vars(r)["symbol"]
+	latest, err := h.priceService.GetLatest(r.Context(), symbol)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, latest)
+}
+
+// GetQuarantinedTicks lists quarantined ticks (?status=quarantined by default)
+func (h *PriceHandler) GetQuarantinedTicks(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = string(dto.QuarantineStatusHeld)
+	}
+	ticks, err := h.priceService.GetQuarantined(r.Context(), status)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, ticks)
+}
+
+// ReleaseQuarantinedTick accepts a quarantined tick that turned out to be legitimate
+func (h *PriceHandler) ReleaseQuarantinedTick(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	tick, err := h.priceService.ReleaseQuarantined(r.Context(), id)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, tick)
+}
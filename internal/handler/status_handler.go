@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/domain"
+)
+
+type StatusHandler struct {
+	statusService domain.StatusService
+}
+
+func NewStatusHandler(statusService domain.StatusService) *StatusHandler {
+	return &StatusHandler{statusService: statusService}
+}
+
+// GetStatus reports whether alerts are working. It needs no authentication and
+// may be cached by clients for as long as the service caches it.
+func (h *StatusHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := h.statusService.GetStatus(r.Context())
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=10")
+	common.RespondWithSuccess(w, http.StatusOK, status)
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/domain"
+)
+
+type SymbolHandler struct {
+	symbolService domain.SymbolService
+}
+
+func NewSymbolHandler(symbolService domain.SymbolService) *SymbolHandler {
+	return &SymbolHandler{symbolService: symbolService}
+}
+
+// SearchSymbols serves typeahead: symbols whose symbol or name starts with ?q,
+// at most ?limit of them
+func (h *SymbolHandler) SearchSymbols(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var limit int64
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 1 {
+			common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+	symbols, err := h.symbolService.Search(r.Context(), query.Get("q"), limit)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, symbols)
+}
+
+// ImportSymbols upserts an uploaded symbol,name,sector CSV
+func (h *SymbolHandler) ImportSymbols(w http.ResponseWriter, r *http.Request) {
+	result, err := h.symbolService.Import(r.Context(), r.Body)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, result)
+}
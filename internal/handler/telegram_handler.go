@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+)
+
+// TelegramSecretHeader carries the secret_token given to setWebhook
+const TelegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+type TelegramHandler struct {
+	telegramService domain.TelegramService
+	webhookSecret   string
+}
+
+func NewTelegramHandler(telegramService domain.TelegramService, webhookSecret string) *TelegramHandler {
+	return &TelegramHandler{telegramService: telegramService, webhookSecret: webhookSecret}
+}
+
+// CreateLink handles POST /users/me/telegram/link for the authenticated user
+func (h *TelegramHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	link, err := h.telegramService.CreateLink(r.Context(), common.UserIDFromContext(r.Context()))
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusCreated, link)
+}
+
+// Unlink handles DELETE /users/me/telegram for the authenticated user
+func (h *TelegramHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	if err := h.telegramService.Unlink(r.Context(), common.UserIDFromContext(r.Context())); err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Telegram chat unlinked"})
+}
+
+// Webhook handles POST /telegram/webhook, the updates Telegram delivers to the
+// bot. Requests must carry the configured secret token.
+func (h *TelegramHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	if h.webhookSecret == "" ||
+		subtle.ConstantTimeCompare([]byte(r.Header.Get(TelegramSecretHeader)), []byte(h.webhookSecret)) != 1 {
+		common.RespondWithError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid webhook secret")
+		return
+	}
+	var update dto.TelegramUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&update); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request format")
+		return
+	}
+	// An error makes Telegram redeliver the update
+	if err := h.telegramService.HandleUpdate(r.Context(), update); err != nil {
+		common.HandleError(w, err)
+		return
+	}
+	common.RespondWithSuccess(w, http.StatusOK, map[string]bool{"ok": true})
+}
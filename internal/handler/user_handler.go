@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/hello-api/internal/common"
@@ -21,16 +23,52 @@ func NewUserHandler(userService domain.UserService) *UserHandler {
 	}
 }
 
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 200
+)
+
+// GetUsers returns one page of users. page starts at 1 and pageSize defaults
+// to 50, capped at 200.
 func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.userService.GetAllUsers()
+	query := r.URL.Query()
+	page := int64(1)
+	if raw := query.Get("page"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 1 {
+			common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "page must be a positive integer")
+			return
+		}
+		page = parsed
+	}
+	pageSize := int64(defaultUserPageSize)
+	if raw := query.Get("pageSize"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 1 || parsed > maxUserPageSize {
+			common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", fmt.Sprintf("pageSize must be between 1 and %d", maxUserPageSize))
+			return
+		}
+		pageSize = parsed
+	}
+	users, err := h.userService.GetAllUsers(r.Context(), page, pageSize)
 	if err != nil {
-		common.RespondWithError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to fetch users")
+		common.HandleError(w, err)
 		return
 	}
 
 	common.RespondWithSuccess(w, http.StatusOK, users)
 }
 
+func (h *UserHandler) GetUserCount(w http.ResponseWriter, r *http.Request) {
+	count, err := h.userService.CountUsers(r.Context())
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+
+	common.RespondWithSuccess(w, http.StatusOK, count)
+}
+
 func parseObjectIDParam(r *http.Request) (string, error) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -47,17 +85,12 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userService.GetUserByID(id)
+	user, err := h.userService.GetUserByID(r.Context(), id)
 	if err != nil {
 		common.HandleError(w, err)
 		return
 	}
 
-	if user == nil {
-		common.RespondWithError(w, http.StatusNotFound, "NOT_FOUND", "User not found")
-		return
-	}
-
 	common.RespondWithSuccess(w, http.StatusOK, user)
 }
 
@@ -75,7 +108,7 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createdUser, err := h.userService.CreateUser(request)
+	createdUser, err := h.userService.CreateUser(r.Context(), request)
 	if err != nil {
 		common.HandleError(w, err)
 		return
@@ -98,13 +131,36 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if at least one field is provided
-	if request.Name == "" && request.Email == "" {
-		validationErr := fmt.Errorf("%w: at least one field (name or email) must be provided", domain.ErrValidation)
+	if request.Name == "" && request.Email == "" && request.EmailMode == "" && request.NotificationPreferences == nil {
+		validationErr := fmt.Errorf("%w: at least one field (name, email, emailMode or notificationPreferences) must be provided", domain.ErrValidation)
 		common.HandleError(w, validationErr)
 		return
 	}
 
-	updatedUser, err := h.userService.UpdateUser(id, request)
+	updatedUser, err := h.userService.UpdateUser(r.Context(), id, request)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+
+	common.RespondWithSuccess(w, http.StatusOK, updatedUser)
+}
+
+// PatchUser changes only the name and email present in the body; "" clears a field
+func (h *UserHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseObjectIDParam(r)
+	if err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID format")
+		return
+	}
+
+	var request dto.UserPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request format")
+		return
+	}
+
+	updatedUser, err := h.userService.PatchUser(r.Context(), id, request)
 	if err != nil {
 		common.HandleError(w, err)
 		return
@@ -113,6 +169,72 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	common.RespondWithSuccess(w, http.StatusOK, updatedUser)
 }
 
+// MuteUser mutes every notification of the user until {"until": "<RFC 3339>"}
+// or for {"for": "2h"}; triggers are still recorded
+func (h *UserHandler) MuteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseObjectIDParam(r)
+	if err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID format")
+		return
+	}
+
+	var request dto.UserMuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request format")
+		return
+	}
+
+	user, err := h.userService.MuteUser(r.Context(), id, request)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+
+	common.RespondWithSuccess(w, http.StatusOK, user)
+}
+
+// UnmuteUser lifts the user's mute
+func (h *UserHandler) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseObjectIDParam(r)
+	if err != nil {
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_ID", "Invalid user ID format")
+		return
+	}
+
+	user, err := h.userService.UnmuteUser(r.Context(), id)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+
+	common.RespondWithSuccess(w, http.StatusOK, user)
+}
+
+// SetAlertDefaults handles PUT /users/me/alert-defaults for the authenticated
+// user; {} clears the defaults. Alerts have no repeat, cooldown or tags, so
+// defaults for them are rejected rather than silently dropped.
+func (h *UserHandler) SetAlertDefaults(w http.ResponseWriter, r *http.Request) {
+	var request dto.AlertDefaults
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			common.HandleError(w, fmt.Errorf("%s is not supported, only defaultDurationDays and defaultChannels are: %w", field, domain.ErrValidation))
+			return
+		}
+		common.RespondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request format")
+		return
+	}
+
+	user, err := h.userService.SetAlertDefaults(r.Context(), common.UserIDFromContext(r.Context()), request)
+	if err != nil {
+		common.HandleError(w, err)
+		return
+	}
+
+	common.RespondWithSuccess(w, http.StatusOK, user)
+}
+
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	id, err := parseObjectIDParam(r)
 	if err != nil {
@@ -120,7 +242,7 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.userService.DeleteUser(id)
+	err = h.userService.DeleteUser(r.Context(), id)
 	if err != nil {
 		common.HandleError(w, err)
 		return
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/internal/service"
+)
+
+// newUserRouter routes the user endpoints to a handler backed by an empty
+// in-memory repository
+func newUserRouter(t *testing.T) *mux.Router {
+	t.Helper()
+	h := NewUserHandler(service.NewUserService(repository.NewMemoryUserRepository(), true))
+	r := mux.NewRouter()
+	r.HandleFunc("/users", h.GetUsers).Methods("GET")
+	r.HandleFunc("/users/count", h.GetUserCount).Methods("GET")
+	r.HandleFunc("/users/{id:[a-fA-F0-9]{24}}", h.GetUser).Methods("GET")
+	r.HandleFunc("/users", h.CreateUser).Methods("POST")
+	r.HandleFunc("/users/{id:[a-fA-F0-9]{24}}", h.UpdateUser).Methods("PUT")
+	r.HandleFunc("/users/{id:[a-fA-F0-9]{24}}", h.PatchUser).Methods("PATCH")
+	r.HandleFunc("/users/{id:[a-fA-F0-9]{24}}", h.DeleteUser).Methods("DELETE")
+	r.Handle("/users/me/alert-defaults", common.RequireUser(http.HandlerFunc(h.SetAlertDefaults))).Methods("PUT")
+	return r
+}
+
+// serve sends a request to r and decodes the response envelope, putting its
+// data into data when given
+func serve(t *testing.T, r http.Handler, method, path, body string, data interface{}) (int, common.Response) {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(method, path, reader))
+
+	var envelope struct {
+		common.Response
+		Data json.RawMessage `json:"data"`
+	}
+	if rec.Code != http.StatusNoContent {
+		if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("%s %s: undecodable body %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	if data != nil && len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, data); err != nil {
+			t.Fatalf("%s %s: undecodable data %s: %v", method, path, envelope.Data, err)
+		}
+	}
+	return rec.Code, envelope.Response
+}
+
+func TestUserHandlerCRUD(t *testing.T) {
+	r := newUserRouter(t)
+
+	var created struct {
+		ID    string `json:"id"`
+		Email string `json:"email"`
+	}
+	code, _ := serve(t, r, "POST", "/users", `{"userId":"alice","name":"Alice","email":" Alice@Example.com "}`, &created)
+	if code != http.StatusCreated || created.Email != "alice@example.com" {
+		t.Fatalf("create returned %d with %+v, want 201 and a normalized email", code, created)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+		code               string
+	}{
+		{"GET", "/users/" + created.ID, "", http.StatusOK, ""},
+		{"POST", "/users", `{"userId":"bob","name":"Bob","email":"alice@example.com"}`, http.StatusConflict, "EMAIL_ALREADY_EXISTS"},
+		{"POST", "/users", `{"userId":"alice","name":"Alice","email":"other@example.com"}`, http.StatusConflict, "USER_ALREADY_EXISTS"},
+		{"POST", "/users", `{"userId":"bob",`, http.StatusBadRequest, "INVALID_REQUEST"},
+		{"PUT", "/users/" + created.ID, `{}`, http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"PUT", "/users/" + created.ID, `{"name":"Alice B"}`, http.StatusOK, ""},
+		{"DELETE", "/users/" + created.ID, "", http.StatusNoContent, ""},
+		{"GET", "/users/" + created.ID, "", http.StatusNotFound, "NOT_FOUND"},
+		{"DELETE", "/users/" + created.ID, "", http.StatusNotFound, "NOT_FOUND"},
+	} {
+		code, response := serve(t, r, tc.method, tc.path, tc.body, nil)
+		if code != tc.want {
+			t.Errorf("%s %s %s returned %d, want %d", tc.method, tc.path, tc.body, code, tc.want)
+		}
+		if tc.code != "" && (response.Error == nil || response.Error.Code != tc.code) {
+			t.Errorf("%s %s %s returned error %+v, want %s", tc.method, tc.path, tc.body, response.Error, tc.code)
+		}
+	}
+}
+
+func TestUserHandlerCount(t *testing.T) {
+	r := newUserRouter(t)
+	for i, userID := range []string{"alice", "bob", "carol"} {
+		body := fmt.Sprintf(`{"userId":%q,"name":"User %d","email":"%s@example.com"}`, userID, i, userID)
+		if code, _ := serve(t, r, "POST", "/users", body, nil); code != http.StatusCreated {
+			t.Fatalf("creating %s returned %d", userID, code)
+		}
+	}
+
+	var count struct {
+		Count int64 `json:"count"`
+	}
+	if code, _ := serve(t, r, "GET", "/users/count", "", &count); code != http.StatusOK || count.Count != 3 {
+		t.Errorf("count returned %d with %d users, want 200 and 3", code, count.Count)
+	}
+}
+
+// Pages are bounded on both ends: a page past the last is empty, and a page
+// whose offset overflows is rejected rather than wrapping around
+func TestUserHandlerPagination(t *testing.T) {
+	r := newUserRouter(t)
+	for i := 0; i < 5; i++ {
+		body := fmt.Sprintf(`{"userId":"user%d","name":"User %d","email":"user%d@example.com"}`, i, i, i)
+		if code, _ := serve(t, r, "POST", "/users", body, nil); code != http.StatusCreated {
+			t.Fatalf("creating user%d returned %d", i, code)
+		}
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  int
+		items int
+		pages int64
+	}{
+		{query: "", want: http.StatusOK, items: 5, pages: 1},
+		{query: "?page=2&pageSize=2", want: http.StatusOK, items: 2, pages: 3},
+		{query: "?page=3&pageSize=2", want: http.StatusOK, items: 1, pages: 3},
+		{query: "?page=4&pageSize=2", want: http.StatusOK, items: 0, pages: 3},
+		{query: "?pageSize=200", want: http.StatusOK, items: 5, pages: 1},
+		{query: "?page=0", want: http.StatusBadRequest},
+		{query: "?page=-1", want: http.StatusBadRequest},
+		{query: "?page=x", want: http.StatusBadRequest},
+		{query: "?pageSize=0", want: http.StatusBadRequest},
+		{query: "?pageSize=201", want: http.StatusBadRequest},
+		{query: "?page=9223372036854775807&pageSize=200", want: http.StatusBadRequest},
+		{query: "?page=46116860184273881&pageSize=200", want: http.StatusBadRequest},
+		{query: "?page=9223372036854775808", want: http.StatusBadRequest},
+		// The last page whose offset fits
+		{query: "?page=9223372036854775807&pageSize=1", want: http.StatusOK, items: 0, pages: 5},
+	} {
+		var page struct {
+			Items      []json.RawMessage `json:"items"`
+			TotalPages int64             `json:"totalPages"`
+		}
+		code, response := serve(t, r, "GET", "/users"+tc.query, "", &page)
+		if code != tc.want {
+			t.Errorf("GET /users%s returned %d (%+v), want %d", tc.query, code, response.Error, tc.want)
+			continue
+		}
+		if code == http.StatusOK && (len(page.Items) != tc.items || page.TotalPages != tc.pages) {
+			t.Errorf("GET /users%s returned %d items of %d pages, want %d of %d", tc.query, len(page.Items), page.TotalPages, tc.items, tc.pages)
+		}
+	}
+}
+
+// PATCH changes only the fields in the body: an absent or null field is kept
+// and "" clears it
+func TestUserHandlerPatch(t *testing.T) {
+	r := newUserRouter(t)
+	var created struct {
+		ID string `json:"id"`
+	}
+	if code, _ := serve(t, r, "POST", "/users", `{"userId":"alice","name":"Alice","email":"alice@example.com"}`, &created); code != http.StatusCreated {
+		t.Fatalf("create returned %d", code)
+	}
+	serve(t, r, "POST", "/users", `{"userId":"bob","name":"Bob","email":"bob@example.com"}`, nil)
+
+	for _, tc := range []struct {
+		name      string
+		body      string
+		want      int
+		code      string
+		wantName  string
+		wantEmail string
+	}{
+		{name: "name only", body: `{"name":"Alice B"}`, want: http.StatusOK, wantName: "Alice B", wantEmail: "alice@example.com"},
+		{name: "email only", body: `{"email":" Alice.B@Example.com "}`, want: http.StatusOK, wantName: "Alice B", wantEmail: "alice.b@example.com"},
+		{name: "null is unchanged", body: `{"name":null,"email":"alice@example.com"}`, want: http.StatusOK, wantName: "Alice B", wantEmail: "alice@example.com"},
+		{name: "clear name", body: `{"name":""}`, want: http.StatusOK, wantName: "", wantEmail: "alice@example.com"},
+		{name: "nothing to change", body: `{}`, want: http.StatusBadRequest, code: "VALIDATION_ERROR"},
+		{name: "taken email", body: `{"email":"bob@example.com"}`, want: http.StatusConflict, code: "EMAIL_ALREADY_EXISTS"},
+		{name: "invalid email", body: `{"email":"not-an-email"}`, want: http.StatusBadRequest, code: "VALIDATION_ERROR"},
+		// Emails are unique here, and the unique index admits a single empty one
+		{name: "clear email", body: `{"email":""}`, want: http.StatusBadRequest, code: "VALIDATION_ERROR"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var patched struct {
+				Name  string `json:"name"`
+				Email string `json:"email"`
+			}
+			code, response := serve(t, r, "PATCH", "/users/"+created.ID, tc.body, &patched)
+			if code != tc.want {
+				t.Fatalf("got %d (%+v), want %d", code, response.Error, tc.want)
+			}
+			if tc.code != "" {
+				if response.Error == nil || response.Error.Code != tc.code {
+					t.Errorf("got error %+v, want %s", response.Error, tc.code)
+				}
+				return
+			}
+			if patched.Name != tc.wantName || patched.Email != tc.wantEmail {
+				t.Errorf("got %+v, want name %q and email %q", patched, tc.wantName, tc.wantEmail)
+			}
+		})
+	}
+}
+
+// Alert defaults are set and cleared for the authenticated user; defaults
+// alerts cannot hold are rejected and leave the stored ones alone
+func TestUserHandlerAlertDefaults(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	r := newUserRouter(t)
+	var created struct {
+		ID string `json:"id"`
+	}
+	if code, _ := serve(t, r, "POST", "/users", `{"userId":"alice","name":"Alice","email":"alice@example.com"}`, &created); code != http.StatusCreated {
+		t.Fatalf("create returned %d, want 201", code)
+	}
+	asAlice := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+signTestJWT("alice"))
+		r.ServeHTTP(w, req)
+	})
+
+	var user dto.UserResponse
+	code, _ := serve(t, asAlice, "PUT", "/users/me/alert-defaults", `{"defaultDurationDays":7,"defaultChannels":["email"]}`, &user)
+	if code != http.StatusOK || user.AlertDefaults == nil || user.AlertDefaults.DurationDays != 7 || len(user.AlertDefaults.Channels) != 1 {
+		t.Fatalf("set returned %d with %+v, want 200 and the defaults", code, user.AlertDefaults)
+	}
+
+	for _, tc := range []struct {
+		body string
+		code string
+	}{
+		{`{"defaultTags":["bank"]}`, "VALIDATION_ERROR"},
+		{`{"defaultCooldown":"1h"}`, "VALIDATION_ERROR"},
+		{`{"defaultRepeat":true,"defaultDurationDays":3}`, "VALIDATION_ERROR"},
+		{`{"defaultChannels":["webhook"]}`, "VALIDATION_ERROR"},
+		{`{"defaultDurationDays":-1}`, "VALIDATION_ERROR"},
+		{`{"defaultDurationDays":`, "INVALID_REQUEST"},
+	} {
+		code, response := serve(t, asAlice, "PUT", "/users/me/alert-defaults", tc.body, nil)
+		if code != http.StatusBadRequest || response.Error == nil || response.Error.Code != tc.code {
+			t.Errorf("%s returned %d with %+v, want 400 %s", tc.body, code, response.Error, tc.code)
+		}
+	}
+	var stored dto.UserResponse
+	serve(t, r, "GET", "/users/"+created.ID, "", &stored)
+	if stored.AlertDefaults == nil || stored.AlertDefaults.DurationDays != 7 {
+		t.Errorf("got %+v after rejected updates, want the defaults kept", stored.AlertDefaults)
+	}
+
+	var cleared dto.UserResponse
+	if code, _ := serve(t, asAlice, "PUT", "/users/me/alert-defaults", `{}`, &cleared); code != http.StatusOK || cleared.AlertDefaults != nil {
+		t.Errorf("clear returned %d with %+v, want 200 and no defaults", code, cleared.AlertDefaults)
+	}
+	if code, _ := serve(t, r, "PUT", "/users/me/alert-defaults", `{}`, nil); code != http.StatusUnauthorized {
+		t.Errorf("got %d without a token, want 401", code)
+	}
+}
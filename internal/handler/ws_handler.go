@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/service"
+	"github.com/hello-api/pkg/metrics"
+)
+
+const (
+	// wsAuthWait is how long a client may take to send its auth frame
+	wsAuthWait = 10 * time.Second
+	// wsWriteWait bounds a single frame write
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is how long the connection may stay silent before it is considered dead
+	wsPongWait = 60 * time.Second
+	// wsPingPeriod must be shorter than wsPongWait
+	wsPingPeriod = wsPongWait * 9 / 10
+	// wsMaxInbound bounds client frames; clients only ever send the auth frame
+	wsMaxInbound = 4096
+)
+
+// wsAuthFrame is the first frame of a client that did not pass ?token=
+type wsAuthFrame struct {
+	Type  string `json:"type"`
+	Token string `json:"token"`
+}
+
+// WSHandler pushes a user's live events over a WebSocket, starting with a snapshot
+type WSHandler struct {
+	events    *service.Broadcaster
+	snapshots *service.LiveSnapshotService
+	upgrader  websocket.Upgrader
+}
+
+func NewWSHandler(events *service.Broadcaster, snapshots *service.LiveSnapshotService) *WSHandler {
+	metrics.Default.Describe("ws_connections", "Open WebSocket connections")
+	metrics.Default.Describe("ws_connections_rejected_total", "WebSocket connections refused, by reason")
+	metrics.Default.Describe("ws_disconnects_total", "WebSocket connections closed, by reason")
+
+	return &WSHandler{
+		events:    events,
+		snapshots: snapshots,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 4096,
+		},
+	}
+}
+
+// Serve handles GET /ws. The JWT is taken from ?token= or, when absent, from a
+// {"type":"auth","token":"..."} frame that must arrive within 10 seconds. The
+// first event is a snapshot of the latest prices and recent triggers; live
+// events follow.
+//
+// A connection over the per-user or server-wide limit is refused with 429 when
+// the token came in the query, or with a close frame after the auth frame.
+func (h *WSHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	// A query token is checked, and the subscription taken, before upgrading
+	// so a bad token gets a plain 401 and a connection over the limits a 429
+	var sub *service.Subscription
+	userID := ""
+	if token := r.URL.Query().Get("token"); token != "" {
+		var err error
+		if userID, err = common.VerifyJWT(token); err != nil {
+			h.reject("unauthorized")
+			common.HandleError(w, err)
+			return
+		}
+		if sub, err = h.events.Subscribe(userID); err != nil {
+			refusal := refuseSubscription(err)
+			h.reject(refusal.reason)
+			common.RespondWithError(w, refusal.status, refusal.code, err.Error())
+			return
+		}
+		defer h.events.Unsubscribe(sub)
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already wrote the error response
+		h.reject("upgrade_failed")
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(wsMaxInbound)
+
+	if sub == nil {
+		if userID, err = readAuthFrame(conn); err != nil {
+			h.reject("unauthorized")
+			closeWith(conn, websocket.ClosePolicyViolation, "unauthorized")
+			return
+		}
+		if sub, err = h.events.Subscribe(userID); err != nil {
+			refusal := refuseSubscription(err)
+			h.reject(refusal.reason)
+			closeWith(conn, refusal.closeCode, err.Error())
+			return
+		}
+		defer h.events.Unsubscribe(sub)
+	}
+
+	connections := metrics.Default.Gauge("ws_connections", nil)
+	connections.Inc()
+	defer connections.Dec()
+
+	log := slog.With("user_id", userID, "remote_addr", r.RemoteAddr)
+	log.Info("websocket connected")
+	// The subscription is taken first, so events published while the snapshot
+	// is built queue up behind it instead of being missed
+	if err := writeEvent(conn, h.snapshots.Snapshot(r.Context(), sub)); err != nil {
+		metrics.Default.Counter("ws_disconnects_total", metrics.Labels{"reason": "write_error"}).Inc()
+		log.Info("websocket disconnected", "reason", "write_error")
+		return
+	}
+	reason := h.pump(conn, sub)
+	metrics.Default.Counter("ws_disconnects_total", metrics.Labels{"reason": reason}).Inc()
+	log.Info("websocket disconnected", "reason", reason)
+}
+
+// pump writes events and keepalive pings until the client goes away or the
+// subscription ends, and returns why the connection closed
+func (h *WSHandler) pump(conn *websocket.Conn, sub *service.Subscription) string {
+	// The read side only handles pongs and notices the client leaving
+	gone := make(chan struct{})
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				switch {
+				case errors.Is(sub.Err(), service.ErrSubscriberOverflow):
+					closeWith(conn, websocket.ClosePolicyViolation, "event queue overflow")
+					return "overflow"
+				case errors.Is(sub.Err(), service.ErrBroadcasterClosed):
+					closeWith(conn, websocket.CloseGoingAway, "server shutting down")
+					return "shutdown"
+				}
+				closeWith(conn, websocket.CloseNormalClosure, "")
+				return "closed"
+			}
+			if err := writeEvent(conn, event); err != nil {
+				return "write_error"
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return "write_error"
+			}
+		case <-gone:
+			return "client_gone"
+		}
+	}
+}
+
+// writeEvent sends an event as a text frame. An event that cannot be encoded
+// is logged and skipped; only write failures are returned.
+func writeEvent(conn *websocket.Conn, event service.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to encode live event", "type", event.Type, "error", err)
+		return nil
+	}
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteMessage(websocket.TextMessage, payload)
+}
+
+// wsRefusal is how a refused subscription is reported, before and after upgrading
+type wsRefusal struct {
+	reason    string
+	code      string
+	status    int
+	closeCode int
+}
+
+// refuseSubscription maps a Subscribe error to its refusal
+func refuseSubscription(err error) wsRefusal {
+	switch {
+	case errors.Is(err, service.ErrTooManySubscribers):
+		return wsRefusal{"too_many_connections", "TOO_MANY_CONNECTIONS", http.StatusTooManyRequests, websocket.ClosePolicyViolation}
+	case errors.Is(err, service.ErrServerAtCapacity):
+		return wsRefusal{"server_at_capacity", "SERVER_AT_CAPACITY", http.StatusTooManyRequests, websocket.CloseTryAgainLater}
+	}
+	return wsRefusal{"unavailable", "SERVICE_UNAVAILABLE", http.StatusServiceUnavailable, websocket.CloseTryAgainLater}
+}
+
+func (h *WSHandler) reject(reason string) {
+	metrics.Default.Counter("ws_connections_rejected_total", metrics.Labels{"reason": reason}).Inc()
+}
+
+// readAuthFrame reads the auth frame and returns the user it authenticates
+func readAuthFrame(conn *websocket.Conn) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(wsAuthWait))
+	var frame wsAuthFrame
+	if err := conn.ReadJSON(&frame); err != nil {
+		return "", err
+	}
+	if frame.Type != "auth" {
+		return "", errors.New("expected an auth frame")
+	}
+	return common.VerifyJWT(frame.Token)
+}
+
+// closeWith sends a close frame; the caller closes the connection
+func closeWith(conn *websocket.Conn, code int, text string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(wsWriteWait))
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/internal/service"
+	"github.com/hello-api/pkg/metrics"
+	"github.com/hello-api/pkg/money"
+)
+
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+// signTestJWT returns an HS256 token for userID signed with testJWTSecret
+func signTestJWT(userID string) string {
+	encode := func(v string) string { return base64.RawURLEncoding.EncodeToString([]byte(v)) }
+	unsigned := encode(`{"alg":"HS256","typ":"JWT"}`) + "." +
+		encode(fmt.Sprintf(`{"sub":%q,"exp":%d}`, userID, time.Now().Add(time.Hour).Unix()))
+	mac := hmac.New(sha256.New, []byte(testJWTSecret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// dialWS opens a WebSocket to server as userID, authenticated by ?token=
+func dialWS(server *httptest.Server, userID string) (*websocket.Conn, *http.Response, error) {
+	return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?token="+signTestJWT(userID), nil)
+}
+
+// blockingPriceRepository holds Latest until released, so a test can publish
+// while the snapshot is being built
+type blockingPriceRepository struct {
+	domain.PriceRepository
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingPriceRepository) Latest(ctx context.Context, symbol string) (*dto.LatestPriceResponse, error) {
+	close(r.entered)
+	<-r.release
+	return r.PriceRepository.Latest(ctx, symbol)
+}
+
+// A client receives the snapshot, with the triggers published before it
+// connected, ahead of an event published while the snapshot was being built
+func TestWSHandlerSnapshotFirst(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	ctx := context.Background()
+
+	alerts := repository.NewMemoryAlertRepository()
+	if _, err := alerts.Create(ctx, &dto.AlertCreateRequest{UserID: "alice", Symbol: "GP", Rule: dto.AlertRuleAbove,
+		Price: money.FromFloat(350), Status: dto.AlertStatusActive}); err != nil {
+		t.Fatal(err)
+	}
+	prices := repository.NewMemoryPriceRepository()
+	if _, err := prices.Insert(ctx, &dto.PriceTickRequest{Symbol: "GP", Price: money.FromFloat(351), Time: time.Now().UTC()}, "2024-03-04"); err != nil {
+		t.Fatal(err)
+	}
+	blocking := &blockingPriceRepository{PriceRepository: prices, entered: make(chan struct{}), release: make(chan struct{})}
+
+	events := service.NewBroadcaster(service.LiveLimits{MaxPerUser: 1, MaxTotal: 1}, 8, 8)
+	defer events.Close()
+	events.Publish("alice", service.Event{Type: service.EventAlertTriggered, Data: "before"})
+
+	h := NewWSHandler(events, service.NewLiveSnapshotService(alerts, blocking))
+	server := httptest.NewServer(http.HandlerFunc(h.Serve))
+	defer server.Close()
+
+	conn, _, err := dialWS(server, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	<-blocking.entered
+	events.Publish("alice", service.Event{Type: service.EventAlertTriggered, Data: "live"})
+	close(blocking.release)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var snapshot struct {
+		Type string `json:"type"`
+		Data struct {
+			Prices   []dto.LatestPriceResponse `json:"prices"`
+			Triggers []service.Event           `json:"triggers"`
+		} `json:"data"`
+	}
+	if err := conn.ReadJSON(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Type != service.EventSnapshot {
+		t.Fatalf("got a %q frame first, want %q", snapshot.Type, service.EventSnapshot)
+	}
+	if len(snapshot.Data.Prices) != 1 || snapshot.Data.Prices[0].Symbol != "GP" {
+		t.Errorf("got snapshot prices %+v, want GP", snapshot.Data.Prices)
+	}
+	if len(snapshot.Data.Triggers) != 1 || snapshot.Data.Triggers[0].Data != "before" {
+		t.Errorf("got snapshot triggers %+v, want only the one published before connecting", snapshot.Data.Triggers)
+	}
+
+	var live service.Event
+	if err := conn.ReadJSON(&live); err != nil {
+		t.Fatal(err)
+	}
+	if live.Type != service.EventAlertTriggered || live.Data != "live" {
+		t.Errorf("got %+v after the snapshot, want the live trigger", live)
+	}
+}
+
+// A bad query token is refused with a plain 401 before upgrading
+func TestWSHandlerUnauthorized(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	events := service.NewBroadcaster(service.LiveLimits{MaxPerUser: 1, MaxTotal: 1}, 8, 8)
+	defer events.Close()
+	h := NewWSHandler(events, service.NewLiveSnapshotService(repository.NewMemoryAlertRepository(), repository.NewMemoryPriceRepository()))
+
+	rec := httptest.NewRecorder()
+	h.Serve(rec, httptest.NewRequest("GET", "/ws?token=not.a.token", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d with %s, want 401", rec.Code, rec.Body.String())
+	}
+}
+
+// waitForRelease waits until the broadcaster holds no subscription
+func waitForRelease(t *testing.T, events *service.Broadcaster) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for events.Stats().Total != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d subscriptions still held: %+v", events.Stats().Total, events.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Opening and closing 1000 streams, over every way a connection ends, leaves
+// no subscription or connection counted: a leaked slot would soon have the
+// limits refuse new connections
+func TestWSHandlerReleasesEveryConnection(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	events := service.NewBroadcaster(service.LiveLimits{MaxPerUser: 5, MaxTotal: 10}, 1, 0)
+	h := NewWSHandler(events, service.NewLiveSnapshotService(repository.NewMemoryAlertRepository(), repository.NewMemoryPriceRepository()))
+	server := httptest.NewServer(http.HandlerFunc(h.Serve))
+	defer server.Close()
+	connections := metrics.Default.Gauge("ws_connections", nil)
+	open := func(userID string) *websocket.Conn {
+		t.Helper()
+		conn, resp, err := dialWS(server, userID)
+		if err != nil {
+			t.Fatalf("connecting %s: %v (%+v)", userID, err, resp)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatalf("reading the snapshot: %v", err)
+		}
+		return conn
+	}
+
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user%d", i%3)
+		conn := open(userID)
+		switch i % 2 {
+		case 0:
+			// The client says goodbye
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			conn.Close()
+		case 1:
+			// The client vanishes and the next write to it fails
+			conn.UnderlyingConn().Close()
+			events.Publish(userID, service.Event{Type: service.EventAlertStatus, Data: i})
+		}
+		waitForRelease(t, events)
+	}
+
+	// The limits still admit a full user, and refuse one connection more
+	var held []*websocket.Conn
+	for i := 0; i < 5; i++ {
+		held = append(held, open("alice"))
+	}
+	if _, resp, err := dialWS(server, "alice"); err == nil || resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("a sixth connection got %v, want 429", err)
+	}
+	// Shutting down closes and releases the rest
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := events.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown left subscriptions held: %v", err)
+	}
+	for _, conn := range held {
+		conn.Close()
+	}
+
+	if stats := events.Stats(); stats.Total != 0 || len(stats.Users) != 0 {
+		t.Errorf("got %+v after every connection ended, want none held", stats)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for connections.Value() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := connections.Value(); got != 0 {
+		t.Errorf("ws_connections is %d, want 0", got)
+	}
+}
@@ -0,0 +1,88 @@
+// Package mapper converts between the entities the repositories store and the
+// DTOs the API exchanges, so create, update and find share one mapping
+package mapper
+
+import (
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// AlertToEntity maps an alert request onto a new entity. The ID, the
+// timestamps and the trigger state are left for the repository to set.
+func AlertToEntity(req *dto.AlertCreateRequest) entity.AlertEntity {
+	alert := entity.AlertEntity{AppliedDefaults: req.AppliedDefaults}
+	ApplyAlertUpdate(&alert, req)
+	return alert
+}
+
+// ApplyAlertUpdate overwrites the fields an edit of the alert may change. The
+// ID, the creation time, the applied defaults, the shadow flag and the trigger
+// state are kept.
+func ApplyAlertUpdate(alert *entity.AlertEntity, req *dto.AlertCreateRequest) {
+	alert.Name = req.Name
+	alert.Price = req.Price
+	alert.Rule = entity.AlertRule(req.Rule)
+	alert.StopDate = req.StopDate
+	alert.StartDate = req.StartDate
+	alert.Status = entity.AlertStatus(req.Status)
+	alert.UserID = req.UserID
+	alert.WebhookURL = req.WebhookURL
+	alert.EvaluateOffHours = req.EvaluateOffHours
+	alert.Symbol = req.Symbol
+	alert.Baseline = entity.AlertBaseline(req.Baseline)
+	alert.NotifyTelegram = req.NotifyTelegram
+	alert.NotifyEmail = req.NotifyEmail
+	alert.Urgent = req.Urgent
+	alert.NotifyOverride = notifyOverrideEntity(req.NotifyOverride)
+}
+
+// AlertToDTO maps a stored alert to its response
+func AlertToDTO(alert *entity.AlertEntity) *dto.AlertResponse {
+	return &dto.AlertResponse{
+		ID:         alert.ID,
+		Name:       alert.Name,
+		Price:      alert.Price,
+		Rule:       dto.AlertRule(alert.Rule),
+		StopDate:   alert.StopDate,
+		StartDate:  alert.StartDate,
+		Status:     dto.AlertStatus(alert.Status),
+		UserID:     alert.UserID,
+		WebhookURL: alert.WebhookURL,
+		CreatedAt:  alert.CreatedAt,
+		UpdatedAt:  alert.UpdatedAt,
+
+		EvaluateOffHours: alert.EvaluateOffHours,
+		Symbol:           alert.Symbol,
+		Baseline:         dto.AlertBaseline(alert.Baseline),
+		NotifyTelegram:   alert.NotifyTelegram,
+		NotifyEmail:      alert.NotifyEmail,
+		Urgent:           alert.Urgent,
+		Triggered:        alert.Triggered,
+		LastTriggeredAt:  alert.LastTriggeredAt,
+		NotifyOverride:   notifyOverrideDTO(alert.NotifyOverride),
+		AppliedDefaults:  alert.AppliedDefaults,
+		Shadow:           alert.Shadow,
+	}
+}
+
+// ArchivedAlertToDTO maps an archived alert to its response
+func ArchivedAlertToDTO(alert *entity.ArchivedAlertEntity) dto.ArchivedAlertResponse {
+	return dto.ArchivedAlertResponse{
+		AlertResponse: *AlertToDTO(&alert.AlertEntity),
+		ArchivedAt:    alert.ArchivedAt,
+	}
+}
+
+func notifyOverrideEntity(override *dto.NotifyOverride) *entity.AlertNotifyOverride {
+	if override == nil {
+		return nil
+	}
+	return &entity.AlertNotifyOverride{Channel: string(override.Channel), Target: override.Target}
+}
+
+func notifyOverrideDTO(override *entity.AlertNotifyOverride) *dto.NotifyOverride {
+	if override == nil {
+		return nil
+	}
+	return &dto.NotifyOverride{Channel: dto.NotificationChannel(override.Channel), Target: override.Target}
+}
@@ -0,0 +1,103 @@
+package mapper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"github.com/hello-api/pkg/money"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// storeRoundTrip returns stored as MongoDB would give it back
+func storeRoundTrip[T any](t *testing.T, stored T) T {
+	t.Helper()
+	raw, err := bson.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read T
+	if err := bson.Unmarshal(raw, &read); err != nil {
+		t.Fatal(err)
+	}
+	return read
+}
+
+// Every field of a create request survives create, store and read; the
+// repository's fields start unset
+func TestAlertRoundTrip(t *testing.T) {
+	start := time.Date(2024, 3, 4, 4, 0, 0, 0, time.UTC)
+	req := &dto.AlertCreateRequest{
+		Name:             "GP breakout",
+		Price:            money.FromFloat(123.45),
+		Rule:             dto.AlertRulePercentChangeAbove,
+		StartDate:        start,
+		StopDate:         start.Add(48 * time.Hour),
+		Status:           dto.AlertStatusActive,
+		UserID:           "alice",
+		WebhookURL:       "https://example.com/alice",
+		EvaluateOffHours: true,
+		Symbol:           "GP",
+		Baseline:         dto.AlertBaselineDayOpen,
+		NotifyTelegram:   true,
+		NotifyEmail:      true,
+		Urgent:           true,
+		NotifyOverride:   &dto.NotifyOverride{Channel: dto.NotificationChannelWebhook, Target: "https://bot.example.com/hook"},
+		AppliedDefaults:  []string{"stopDate"},
+	}
+	stored := AlertToEntity(req)
+	stored.ID = "65e5a0000000000000000001"
+	got := AlertToDTO(ptr(storeRoundTrip(t, stored)))
+
+	want := &dto.AlertResponse{
+		ID:               stored.ID,
+		Name:             req.Name,
+		Price:            req.Price,
+		Rule:             req.Rule,
+		StopDate:         req.StopDate,
+		StartDate:        req.StartDate,
+		Status:           req.Status,
+		UserID:           req.UserID,
+		WebhookURL:       req.WebhookURL,
+		EvaluateOffHours: req.EvaluateOffHours,
+		Symbol:           req.Symbol,
+		Baseline:         req.Baseline,
+		NotifyTelegram:   req.NotifyTelegram,
+		NotifyEmail:      req.NotifyEmail,
+		Urgent:           req.Urgent,
+		NotifyOverride:   req.NotifyOverride,
+		AppliedDefaults:  req.AppliedDefaults,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// An update replaces the editable fields and keeps the identity, creation
+// time, applied defaults, shadow flag and trigger state
+func TestApplyAlertUpdate(t *testing.T) {
+	created := time.Date(2024, 3, 4, 4, 0, 0, 0, time.UTC)
+	fired := created.Add(time.Hour)
+	stored := entity.AlertEntity{ID: "65e5a0000000000000000001", Name: "old", Price: money.FromFloat(100), Rule: entity.AlertRuleAbove,
+		Symbol: "GP", Status: entity.AlertStatusActive, UserID: "alice", WebhookURL: "https://example.com/old",
+		NotifyOverride: &entity.AlertNotifyOverride{Channel: "email", Target: "bot@example.com"}, AppliedDefaults: []string{"stopDate"},
+		Shadow: true, Triggered: true, LastTriggeredAt: &fired, CreatedAt: created}
+
+	ApplyAlertUpdate(&stored, &dto.AlertCreateRequest{Name: "new", Price: money.FromFloat(90), Rule: dto.AlertRuleBelow,
+		Symbol: "BATBC", Status: dto.AlertStatusInactive, UserID: "alice"})
+	got := AlertToDTO(&stored)
+	if got.Name != "new" || got.Price != money.FromFloat(90) || got.Rule != dto.AlertRuleBelow || got.Symbol != "BATBC" ||
+		got.Status != dto.AlertStatusInactive || got.WebhookURL != "" || got.NotifyOverride != nil {
+		t.Errorf("got %+v, want the edited fields replaced", got)
+	}
+	if got.ID != stored.ID || !got.CreatedAt.Equal(created) || len(got.AppliedDefaults) != 1 || !got.Shadow ||
+		!got.Triggered || got.LastTriggeredAt == nil {
+		t.Errorf("got %+v, want the identity, defaults, shadow flag and trigger state kept", got)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,82 @@
+package mapper
+
+import (
+	"time"
+
+	"github.com/hello-api/internal/common/timeutil"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// UserToEntity maps a validated user request onto a new entity; the ID and
+// the timestamps are left for the repository to set
+func UserToEntity(req dto.UserCreateRequest) *entity.UserEntity {
+	user := &entity.UserEntity{
+		UserID:    req.UserID,
+		Name:      req.Name,
+		Email:     req.Email,
+		EmailMode: string(req.EmailMode),
+	}
+	ApplyNotificationPreferences(user, req.NotificationPreferences)
+	return user
+}
+
+// ApplyNotificationPreferences replaces the user's quiet hours and display
+// timezone; nil preferences clear both
+func ApplyNotificationPreferences(user *entity.UserEntity, prefs *dto.NotificationPreferences) {
+	user.QuietHours = nil
+	user.DisplayTimezone = ""
+	if prefs == nil {
+		return
+	}
+	if quiet := prefs.QuietHours; quiet != nil {
+		user.QuietHours = &entity.QuietHoursEntity{Start: quiet.Start, End: quiet.End, Timezone: quiet.Timezone}
+	}
+	user.DisplayTimezone = prefs.DisplayTimezone
+}
+
+// AlertDefaultsToEntity maps validated alert defaults; empty defaults map to
+// nil, which clears them
+func AlertDefaultsToEntity(defaults dto.AlertDefaults) *entity.AlertDefaultsEntity {
+	if defaults.DurationDays == 0 && len(defaults.Channels) == 0 {
+		return nil
+	}
+	stored := &entity.AlertDefaultsEntity{DurationDays: defaults.DurationDays}
+	for _, channel := range defaults.Channels {
+		stored.Channels = append(stored.Channels, string(channel))
+	}
+	return stored
+}
+
+// UserToDTO maps a stored user to its response
+func UserToDTO(user *entity.UserEntity) dto.UserResponse {
+	response := dto.UserResponse{
+		ID:             user.ID.Hex(),
+		UserID:         user.UserID,
+		Name:           user.Name,
+		Email:          user.Email,
+		TelegramLinked: user.TelegramChatID != 0,
+		EmailMode:      dto.EmailModeImmediate,
+		CreatedAt:      user.CreatedAt,
+		UpdatedAt:      user.UpdatedAt,
+	}
+	if user.EmailMode != "" {
+		response.EmailMode = dto.EmailMode(user.EmailMode)
+	}
+	if quiet := user.QuietHours; quiet != nil {
+		response.NotificationPreferences.QuietHours = &dto.QuietHours{Start: quiet.Start, End: quiet.End, Timezone: quiet.Timezone}
+	}
+	response.NotificationPreferences.DisplayTimezone = user.DisplayTimezone
+	// An expired mute is left in place until the next change but not shown
+	if user.MutedUntil != nil && user.MutedUntil.After(time.Now()) {
+		mutedUntil := timeutil.UTC(*user.MutedUntil)
+		response.MutedUntil = &mutedUntil
+	}
+	if defaults := user.AlertDefaults; defaults != nil {
+		response.AlertDefaults = &dto.AlertDefaults{DurationDays: defaults.DurationDays}
+		for _, channel := range defaults.Channels {
+			response.AlertDefaults.Channels = append(response.AlertDefaults.Channels, dto.NotificationChannel(channel))
+		}
+	}
+	return response
+}
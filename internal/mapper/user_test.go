@@ -0,0 +1,61 @@
+package mapper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Every field of a create request survives create, store and read, and the
+// stored settings come back as they were set
+func TestUserRoundTrip(t *testing.T) {
+	req := dto.UserCreateRequest{
+		UserID:    "alice",
+		Name:      "Alice",
+		Email:     "alice@example.com",
+		EmailMode: dto.EmailModeHourly,
+		NotificationPreferences: &dto.NotificationPreferences{
+			QuietHours:      &dto.QuietHours{Start: "22:00", End: "07:00", Timezone: "Asia/Dhaka"},
+			DisplayTimezone: "Asia/Kolkata",
+		},
+	}
+	stored := UserToEntity(req)
+	stored.ID = primitive.NewObjectID()
+	stored.TelegramChatID = 4242
+	mutedUntil := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+	stored.MutedUntil = &mutedUntil
+	stored.AlertDefaults = AlertDefaultsToEntity(dto.AlertDefaults{DurationDays: 7, Channels: []dto.NotificationChannel{dto.NotificationChannelEmail}})
+	got := UserToDTO(ptr(storeRoundTrip(t, *stored)))
+
+	want := dto.UserResponse{
+		ID:                      stored.ID.Hex(),
+		UserID:                  req.UserID,
+		Name:                    req.Name,
+		Email:                   req.Email,
+		TelegramLinked:          true,
+		EmailMode:               req.EmailMode,
+		NotificationPreferences: *req.NotificationPreferences,
+		MutedUntil:              &mutedUntil,
+		AlertDefaults:           &dto.AlertDefaults{DurationDays: 7, Channels: []dto.NotificationChannel{dto.NotificationChannelEmail}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+// Unset settings read back as their defaults, and an expired mute is hidden
+func TestUserToDTODefaults(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+	got := UserToDTO(&entity.UserEntity{UserID: "bob", MutedUntil: &expired})
+	if got.EmailMode != dto.EmailModeImmediate || got.MutedUntil != nil || got.AlertDefaults != nil ||
+		got.NotificationPreferences.QuietHours != nil || got.TelegramLinked {
+		t.Errorf("got %+v, want the defaults", got)
+	}
+	if stored := AlertDefaultsToEntity(dto.AlertDefaults{}); stored != nil {
+		t.Errorf("empty alert defaults map to %+v, want nil", stored)
+	}
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/db"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/mapper"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoAlertArchiveRepository moves alerts between the alerts and the
+// alerts_archive collections. Moves run in one transaction where the
+// deployment supports it; elsewhere the copy is written first and undone for
+// any alert that changed before it could be removed, so an alert is never lost.
+type MongoAlertArchiveRepository struct {
+	alerts  *mongo.Collection
+	archive *mongo.Collection
+}
+
+func NewMongoAlertArchiveRepository(alerts, archive *mongo.Collection) *MongoAlertArchiveRepository {
+	return &MongoAlertArchiveRepository{alerts: alerts, archive: archive}
+}
+
+func (r *MongoAlertArchiveRepository) Archive(ctx context.Context, before time.Time, limit int64) ([]dto.AlertResponse, error) {
+	ctx, span := startSpan(ctx, r.archive, "Archive")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	filter := bson.M{
+		"status": entity.AlertStatusInactive,
+		// Switching an alert off clears triggered, so having fired is read
+		// from lastTriggeredAt
+		"lastTriggeredAt": bson.M{"$ne": nil},
+		// A zero stop date means the alert never stops
+		"stopDate": bson.M{"$gt": time.Time{}, "$lt": before},
+		// A restore counts as an update, so restored alerts are not archived again at once
+		"updated_at": bson.M{"$lt": before},
+	}
+	cursor, err := r.alerts.Find(ctx, filter, options.Find().SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	var alerts []entity.AlertEntity
+	if err := cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	if len(alerts) == 0 {
+		return nil, nil
+	}
+
+	var moved []entity.AlertEntity
+	err = inTransaction(ctx, func(ctx context.Context) error {
+		moved, err = r.move(ctx, alerts, filter)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]dto.AlertResponse, 0, len(moved))
+	for _, alert := range moved {
+		result = append(result, *mapper.AlertToDTO(&alert))
+	}
+	return result, nil
+}
+
+// move copies the alerts to the archive, then removes each live alert still
+// unchanged and matching filter, and returns the alerts it removed
+func (r *MongoAlertArchiveRepository) move(ctx context.Context, alerts []entity.AlertEntity, filter bson.M) ([]entity.AlertEntity, error) {
+	archivedAt := time.Now().UTC()
+	copies := make([]mongo.WriteModel, 0, len(alerts))
+	for _, alert := range alerts {
+		copies = append(copies, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": alert.ID}).
+			SetReplacement(entity.ArchivedAlertEntity{AlertEntity: alert, ArchivedAt: archivedAt}).
+			SetUpsert(true))
+	}
+	if _, err := r.archive.BulkWrite(ctx, copies); err != nil {
+		return nil, err
+	}
+
+	var moved []entity.AlertEntity
+	var changed []string
+	for _, alert := range alerts {
+		guard := bson.M{}
+		for key, value := range filter {
+			guard[key] = value
+		}
+		guard["_id"] = alert.ID
+		guard["updated_at"] = alert.UpdatedAt
+		result, err := r.alerts.DeleteOne(ctx, guard)
+		if err != nil {
+			return nil, err
+		}
+		if result.DeletedCount == 0 {
+			changed = append(changed, alert.ID)
+			continue
+		}
+		moved = append(moved, alert)
+	}
+	if len(changed) > 0 {
+		if _, err := r.archive.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": changed}}); err != nil {
+			return nil, err
+		}
+	}
+	return moved, nil
+}
+
+func (r *MongoAlertArchiveRepository) FindByUser(ctx context.Context, userID string, page, pageSize int64) ([]dto.ArchivedAlertResponse, int64, error) {
+	ctx, span := startSpan(ctx, r.archive, "FindByUser")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, 0, err
+	}
+	filter := bson.M{"userId": userID}
+	total, err := r.archive.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "archivedAt", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip((page - 1) * pageSize).
+		SetLimit(pageSize)
+	cursor, err := r.archive.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	var archived []entity.ArchivedAlertEntity
+	if err := cursor.All(ctx, &archived); err != nil {
+		return nil, 0, err
+	}
+	result := make([]dto.ArchivedAlertResponse, 0, len(archived))
+	for _, alert := range archived {
+		result = append(result, mapper.ArchivedAlertToDTO(&alert))
+	}
+	return result, total, nil
+}
+
+func (r *MongoAlertArchiveRepository) Restore(ctx context.Context, id string) (*dto.AlertResponse, error) {
+	ctx, span := startSpan(ctx, r.archive, "Restore")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	var restored *entity.AlertEntity
+	err := inTransaction(ctx, func(ctx context.Context) error {
+		restored = nil
+		var archived entity.ArchivedAlertEntity
+		err := r.archive.FindOne(ctx, bson.M{"_id": id}).Decode(&archived)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		archived.UpdatedAt = time.Now().UTC()
+		// A live copy left by an interrupted restore is kept as is
+		if _, err := r.alerts.InsertOne(ctx, archived.AlertEntity); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		if _, err := r.archive.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+			return err
+		}
+		restored = &archived.AlertEntity
+		return nil
+	})
+	if err != nil || restored == nil {
+		return nil, err
+	}
+	return mapper.AlertToDTO(restored), nil
+}
+
+// inTransaction runs fn in a transaction when the deployment supports them,
+// and directly otherwise
+func inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !db.SupportsTransactions() {
+		return fn(ctx)
+	}
+	return db.WithTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		return fn(sessCtx)
+	})
+}
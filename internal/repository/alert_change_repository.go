@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// alertChangeCounter is the counters document holding the last change cursor
+const alertChangeCounter = "alert_changes"
+
+type MongoAlertChangeRepository struct {
+	collection *mongo.Collection
+	counters   *mongo.Collection
+}
+
+func NewMongoAlertChangeRepository(collection, counters *mongo.Collection) *MongoAlertChangeRepository {
+	return &MongoAlertChangeRepository{collection: collection, counters: counters}
+}
+
+func (r *MongoAlertChangeRepository) NextCursor(ctx context.Context) (int64, error) {
+	ctx, span := startSpan(ctx, r.counters, "NextCursor")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return 0, err
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := r.counters.FindOneAndUpdate(ctx,
+		bson.M{"_id": alertChangeCounter},
+		bson.M{"$inc": bson.M{"seq": int64(1)}},
+		opts,
+	).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
+
+func (r *MongoAlertChangeRepository) Append(ctx context.Context, req *dto.AlertChangeRequest) (*dto.AlertChangeResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "Append")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	change := newAlertChangeEntity(req)
+	if _, err := r.collection.InsertOne(ctx, change); err != nil {
+		return nil, translateWriteError(err, nil)
+	}
+	return mapAlertChangeEntityToDTO(&change), nil
+}
+
+func (r *MongoAlertChangeRepository) Since(ctx context.Context, since int64, limit int64) ([]dto.AlertChangeResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "Since")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$gt": since}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var changes []entity.AlertChangeEntity
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, err
+	}
+	result := make([]dto.AlertChangeResponse, 0, len(changes))
+	for i := range changes {
+		result = append(result, *mapAlertChangeEntityToDTO(&changes[i]))
+	}
+	return result, nil
+}
+
+func newAlertChangeEntity(req *dto.AlertChangeRequest) entity.AlertChangeEntity {
+	return entity.AlertChangeEntity{
+		Cursor:  req.Cursor,
+		AlertID: req.Alert.ID,
+		UserID:  req.Alert.UserID,
+		Symbol:  req.Alert.Symbol,
+		Status:  entity.AlertStatus(req.Alert.Status),
+		Change:  string(req.Change),
+		At:      req.At,
+	}
+}
+
+func mapAlertChangeEntityToDTO(change *entity.AlertChangeEntity) *dto.AlertChangeResponse {
+	return &dto.AlertChangeResponse{
+		Cursor:  change.Cursor,
+		AlertID: change.AlertID,
+		UserID:  change.UserID,
+		Symbol:  change.Symbol,
+		Status:  dto.AlertStatus(change.Status),
+		Change:  dto.AlertChangeType(change.Change),
+		At:      change.At,
+	}
+}
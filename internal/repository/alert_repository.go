@@ -2,13 +2,16 @@ package repository
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/mapper"
 	"github.com/hello-api/internal/repository/entity"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoAlertRepository struct {
@@ -19,87 +22,287 @@ func NewMongoAlertRepository(collection *mongo.Collection) *MongoAlertRepository
 	return &MongoAlertRepository{collection: collection}
 }
 
-func (r *MongoAlertRepository) Create(alertReq *dto.AlertCreateRequest) (*dto.AlertResponse, error) {
-	alertEntity := entity.AlertEntity{
-		ID:        primitive.NewObjectID().Hex(),
-		Name:      alertReq.Name,
-		Price:     alertReq.Price,
-		Rule:      entity.AlertRule(alertReq.Rule),
-		StopDate:  alertReq.StopDate,
-		StartDate: alertReq.StartDate,
-		Status:    entity.AlertStatus(alertReq.Status),
-		UserID:    alertReq.UserID,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-	_, err := r.collection.InsertOne(context.Background(), alertEntity)
-	if err != nil {
+func (r *MongoAlertRepository) Create(ctx context.Context, alertReq *dto.AlertCreateRequest) (*dto.AlertResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "Create")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
 		return nil, err
 	}
-	return mapAlertEntityToDTO(&alertEntity), nil
+	alertEntity := mapper.AlertToEntity(alertReq)
+	alertEntity.ID = primitive.NewObjectID().Hex()
+	alertEntity.CreatedAt = time.Now().UTC()
+	alertEntity.UpdatedAt = alertEntity.CreatedAt
+	_, err := r.collection.InsertOne(ctx, alertEntity)
+	if err != nil {
+		return nil, translateWriteError(err, nil)
+	}
+	return mapper.AlertToDTO(&alertEntity), nil
 }
 
-func (r *MongoAlertRepository) FindByID(id string) (*dto.AlertResponse, error) {
+func (r *MongoAlertRepository) FindByID(ctx context.Context, id string) (*dto.AlertResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindByID")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
 	var alert entity.AlertEntity
-	err := r.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&alert)
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&alert)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil // Not found, but not an error
+		}
 		return nil, err
 	}
-	return mapAlertEntityToDTO(&alert), nil
+	return mapper.AlertToDTO(&alert), nil
 }
 
-func (r *MongoAlertRepository) FindAllByUser(userId string) ([]dto.AlertResponse, error) {
+func (r *MongoAlertRepository) FindAllByUser(ctx context.Context, userId string) ([]dto.AlertResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindAllByUser")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
 	var alerts []entity.AlertEntity
-	cursor, err := r.collection.Find(context.Background(), bson.M{"userId": userId})
+	cursor, err := r.collection.Find(ctx, bson.M{"userId": userId})
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.Background())
-	if err := cursor.All(context.Background(), &alerts); err != nil {
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &alerts); err != nil {
 		return nil, err
 	}
 	var result []dto.AlertResponse
 	for _, alert := range alerts {
-		result = append(result, *mapAlertEntityToDTO(&alert))
+		result = append(result, *mapper.AlertToDTO(&alert))
 	}
 	return result, nil
 }
 
-func (r *MongoAlertRepository) Update(id string, alertReq *dto.AlertCreateRequest) (*dto.AlertResponse, error) {
-	filter := bson.M{"_id": id}
-	update := bson.M{"$set": bson.M{
-		"name":       alertReq.Name,
-		"price":      alertReq.Price,
-		"rule":       alertReq.Rule,
-		"stopDate":   alertReq.StopDate,
-		"startDate":  alertReq.StartDate,
-		"status":     alertReq.Status,
-		"userId":     alertReq.UserID,
-		"updated_at": time.Now(),
-	}}
-	_, err := r.collection.UpdateOne(context.Background(), filter, update)
+func (r *MongoAlertRepository) FindActive(ctx context.Context) ([]dto.AlertResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindActive")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	var alerts []entity.AlertEntity
+	cursor, err := r.collection.Find(ctx, bson.M{"status": entity.AlertStatusActive})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	result := make([]dto.AlertResponse, 0, len(alerts))
+	for _, alert := range alerts {
+		result = append(result, *mapper.AlertToDTO(&alert))
+	}
+	return result, nil
+}
+
+// DistinctActiveSymbols leaves the deduplication to the server. A zero start or
+// stop date leaves that side of the window open, as in the evaluation.
+func (r *MongoAlertRepository) DistinctActiveSymbols(ctx context.Context, at time.Time) ([]string, error) {
+	ctx, span := startSpan(ctx, r.collection, "DistinctActiveSymbols")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	filter := bson.M{
+		"status":    entity.AlertStatusActive,
+		"symbol":    bson.M{"$nin": bson.A{nil, ""}},
+		"startDate": bson.M{"$lte": at},
+		"$or": bson.A{
+			bson.M{"stopDate": bson.M{"$gte": at}},
+			bson.M{"stopDate": time.Time{}},
+		},
+	}
+	values, err := r.collection.Distinct(ctx, "symbol", filter)
+	if err != nil {
+		return nil, err
+	}
+	symbols := make([]string, 0, len(values))
+	for _, value := range values {
+		if symbol, ok := value.(string); ok {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols, nil
+}
+
+func (r *MongoAlertRepository) Update(ctx context.Context, id string, alertReq *dto.AlertCreateRequest) (*dto.AlertResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "Update")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	var stored entity.AlertEntity
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&stored); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	edited := stored
+	mapper.ApplyAlertUpdate(&edited, alertReq)
+	update, err := alertUpdate(&stored, &edited)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return nil, translateWriteError(err, nil)
+	}
+	return r.FindByID(ctx, id)
+}
+
+// alertUpdate returns the update that turns stored into edited: $set for the
+// fields mapper.ApplyAlertUpdate changed and $unset for those it emptied, so an
+// edit never writes back the trigger state or the shadow flag it read. An
+// edited alert is armed again.
+func alertUpdate(stored, edited *entity.AlertEntity) (bson.M, error) {
+	before, err := bson.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+	after, err := bson.Marshal(edited)
+	if err != nil {
+		return nil, err
+	}
+	afterElements, err := bson.Raw(after).Elements()
+	if err != nil {
+		return nil, err
+	}
+	set := bson.M{}
+	for _, element := range afterElements {
+		previous, err := bson.Raw(before).LookupErr(element.Key())
+		if err != nil || !previous.Equal(element.Value()) {
+			set[element.Key()] = element.Value()
+		}
+	}
+	beforeElements, err := bson.Raw(before).Elements()
+	if err != nil {
+		return nil, err
+	}
+	unset := bson.M{}
+	for _, element := range beforeElements {
+		if _, err := bson.Raw(after).LookupErr(element.Key()); err != nil {
+			unset[element.Key()] = ""
+		}
+	}
+
+	set["triggered"] = false
+	set["updated_at"] = time.Now().UTC()
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, nil
+}
+
+func (r *MongoAlertRepository) SetShadow(ctx context.Context, id string, shadow bool) (*dto.AlertResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "SetShadow")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id},
+		bson.M{"$set": bson.M{"shadow": shadow, "updated_at": time.Now().UTC()}})
 	if err != nil {
 		return nil, err
 	}
-	return r.FindByID(id)
+	if result.MatchedCount == 0 {
+		return nil, nil
+	}
+	return r.FindByID(ctx, id)
 }
 
-func (r *MongoAlertRepository) Delete(id string) error {
-	_, err := r.collection.DeleteOne(context.Background(), bson.M{"_id": id})
+func (r *MongoAlertRepository) Delete(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, r.collection, "Delete")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 	return err
 }
 
-func mapAlertEntityToDTO(alert *entity.AlertEntity) *dto.AlertResponse {
-	return &dto.AlertResponse{
-		ID:        alert.ID,
-		Name:      alert.Name,
-		Price:     alert.Price,
-		Rule:      dto.AlertRule(alert.Rule),
-		StopDate:  alert.StopDate,
-		StartDate: alert.StartDate,
-		Status:    dto.AlertStatus(alert.Status),
-		UserID:    alert.UserID,
-		CreatedAt: alert.CreatedAt,
-		UpdatedAt: alert.UpdatedAt,
+func (r *MongoAlertRepository) MarkTriggered(ctx context.Context, id string, at time.Time) (bool, error) {
+	ctx, span := startSpan(ctx, r.collection, "MarkTriggered")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return false, err
+	}
+	filter := bson.M{"_id": id, "triggered": bson.M{"$ne": true}}
+	update := bson.M{"$set": bson.M{"triggered": true, "lastTriggeredAt": at}}
+	err := r.collection.FindOneAndUpdate(ctx, filter, update).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *MongoAlertRepository) Rearm(ctx context.Context, id string) (bool, error) {
+	ctx, span := startSpan(ctx, r.collection, "Rearm")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return false, err
+	}
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "triggered": true},
+		bson.M{"$set": bson.M{"triggered": false}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *MongoAlertRepository) FindAfter(ctx context.Context, after string, limit int64) ([]dto.AlertResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindAfter")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$gt": after}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var alerts []entity.AlertEntity
+	if err := cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	result := make([]dto.AlertResponse, 0, len(alerts))
+	for _, alert := range alerts {
+		result = append(result, *mapper.AlertToDTO(&alert))
+	}
+	return result, nil
+}
+
+func (r *MongoAlertRepository) Deactivate(ctx context.Context, ids []string) (int64, error) {
+	ctx, span := startSpan(ctx, r.collection, "Deactivate")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return 0, err
+	}
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}, "status": entity.AlertStatusActive},
+		bson.M{"$set": bson.M{"status": entity.AlertStatusInactive, "updated_at": time.Now().UTC()}})
+	if err != nil {
+		return 0, err
 	}
+	return result.ModifiedCount, nil
 }
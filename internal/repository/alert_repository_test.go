@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/mapper"
+	"github.com/hello-api/internal/repository/entity"
+	"github.com/hello-api/pkg/money"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// An edit sets the fields it changed, unsets those it emptied and re-arms the
+// alert; what it keeps, such as the trigger time and the shadow flag, is not
+// written back
+func TestAlertUpdate(t *testing.T) {
+	lastTriggered := time.Date(2024, 3, 4, 5, 0, 0, 0, time.UTC)
+	stored := entity.AlertEntity{
+		ID: "a1", UserID: "alice", Symbol: "GP", Rule: entity.AlertRuleAbove, Price: money.FromFloat(100),
+		Status: entity.AlertStatusActive, WebhookURL: "https://example.com/alice", NotifyTelegram: true,
+		NotifyOverride:  &entity.AlertNotifyOverride{Channel: "email", Target: "bot@example.com"},
+		AppliedDefaults: []string{"stopDate"}, Shadow: true, Triggered: true, LastTriggeredAt: &lastTriggered,
+		CreatedAt: lastTriggered.Add(-time.Hour), UpdatedAt: lastTriggered.Add(-time.Hour),
+	}
+	edited := stored
+	mapper.ApplyAlertUpdate(&edited, &dto.AlertCreateRequest{
+		UserID: "alice", Symbol: "GP", Rule: dto.AlertRuleBelow, Price: money.FromFloat(90),
+		Status: dto.AlertStatusActive, WebhookURL: "https://example.com/alice",
+	})
+
+	update, err := alertUpdate(&stored, &edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, _ := update["$set"].(bson.M)
+	unset, _ := update["$unset"].(bson.M)
+	wantSet := []string{"rule", "price", "triggered", "updated_at"}
+	if len(set) != len(wantSet) {
+		t.Errorf("got $set of %v, want %v", fieldNames(set), wantSet)
+	}
+	for _, key := range wantSet {
+		if _, ok := set[key]; !ok {
+			t.Errorf("$set lacks %s: %v", key, fieldNames(set))
+		}
+	}
+	if set["triggered"] != false {
+		t.Errorf("got triggered %v, want the alert re-armed", set["triggered"])
+	}
+	if len(unset) != 2 || unset["notifyTelegram"] == nil || unset["notifyOverride"] == nil {
+		t.Errorf("got $unset of %v, want notifyTelegram and notifyOverride", fieldNames(unset))
+	}
+
+	// an edit that changes nothing only re-arms the alert
+	update, _ = alertUpdate(&stored, &stored)
+	if set, _ := update["$set"].(bson.M); len(set) != 2 || update["$unset"] != nil {
+		t.Errorf("got %v for an unchanged alert, want triggered and updated_at only", update)
+	}
+}
+
+// fieldNames lists the fields of an update operator
+func fieldNames(m bson.M) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
+
+// alertRepositoryBackends returns a fresh, empty repository of every backend;
+// the Mongo one skips without MONGO_TEST_URI
+func alertRepositoryBackends() map[string]func(t *testing.T) domain.AlertRepository {
+	return map[string]func(t *testing.T) domain.AlertRepository{
+		"memory": func(t *testing.T) domain.AlertRepository { return NewMemoryAlertRepository() },
+		"mongo": func(t *testing.T) domain.AlertRepository {
+			return NewMongoAlertRepository(mongoTestDatabase(t).Collection("alerts"))
+		},
+	}
+}
+
+// Both backends apply an edit the same way: the edited fields change, cleared
+// ones are emptied, the alert is re-armed, and its trigger time, shadow flag
+// and applied defaults are kept
+func TestAlertRepositoryUpdate(t *testing.T) {
+	for name, newRepo := range alertRepositoryBackends() {
+		t.Run(name, func(t *testing.T) {
+			alerts := newRepo(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			created, err := alerts.Create(ctx, &dto.AlertCreateRequest{
+				UserID: "alice", Symbol: "GP", Rule: dto.AlertRuleAbove, Price: money.FromFloat(100),
+				Status: dto.AlertStatusActive, NotifyTelegram: true, AppliedDefaults: []string{"stopDate"},
+				NotifyOverride: &dto.NotifyOverride{Channel: dto.NotificationChannelEmail, Target: "bot@example.com"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			firedAt := time.Date(2024, 3, 4, 5, 0, 0, 0, time.UTC)
+			if _, err := alerts.MarkTriggered(ctx, created.ID, firedAt); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := alerts.SetShadow(ctx, created.ID, true); err != nil {
+				t.Fatal(err)
+			}
+
+			updated, err := alerts.Update(ctx, created.ID, &dto.AlertCreateRequest{
+				UserID: "alice", Symbol: "GP", Name: "GP dip", Rule: dto.AlertRuleBelow, Price: money.FromFloat(90),
+				Status: dto.AlertStatusActive,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated.Name != "GP dip" || updated.Rule != dto.AlertRuleBelow || updated.Price != money.FromFloat(90) {
+				t.Errorf("got %+v, want the edited name, rule and price", updated)
+			}
+			if updated.NotifyTelegram || updated.NotifyOverride != nil || updated.Triggered {
+				t.Errorf("got %+v, want the channels cleared and the alert re-armed", updated)
+			}
+			if !updated.Shadow || updated.LastTriggeredAt == nil || !updated.LastTriggeredAt.Equal(firedAt) ||
+				len(updated.AppliedDefaults) != 1 || !updated.CreatedAt.Equal(created.CreatedAt) {
+				t.Errorf("got %+v, want the shadow flag, trigger time, defaults and creation time kept", updated)
+			}
+
+			if missing, err := alerts.Update(ctx, "000000000000000000000000", &dto.AlertCreateRequest{UserID: "alice"}); err != nil || missing != nil {
+				t.Errorf("got %+v, %v updating a missing alert, want nil", missing, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/hello-api/internal/db"
+	"github.com/hello-api/internal/domain"
+)
+
+// checkAvailable fails fast while the database supervisor reports MongoDB as
+// unreachable, or once the request's deadline has passed
+func checkAvailable(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !db.Healthy() {
+		return domain.ErrDependencyUnavailable
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hello-api/internal/db"
+	"github.com/hello-api/internal/domain"
+)
+
+// Repository calls fail fast with ErrDependencyUnavailable while the database
+// is down and go through again once it is back
+func TestCheckAvailable(t *testing.T) {
+	var down bool
+	supervisor := db.NewSupervisor(func(context.Context) error {
+		if down {
+			return errors.New("no reachable servers")
+		}
+		return nil
+	}, 0, 0)
+	t.Cleanup(func() {
+		down = false
+		supervisor.Check(context.Background())
+	})
+
+	if err := checkAvailable(context.Background()); err != nil {
+		t.Errorf("healthy database returned %v", err)
+	}
+	down = true
+	supervisor.Check(context.Background())
+	if err := checkAvailable(context.Background()); !errors.Is(err, domain.ErrDependencyUnavailable) {
+		t.Errorf("database down returned %v, want ErrDependencyUnavailable", err)
+	}
+	down = false
+	supervisor.Check(context.Background())
+	if err := checkAvailable(context.Background()); err != nil {
+		t.Errorf("recovered database returned %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := checkAvailable(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled request returned %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoDailyCounterRepository keeps daily counts in the counters collection,
+// one document per name and date such as "triggers:2024-01-02"
+type MongoDailyCounterRepository struct {
+	counters *mongo.Collection
+}
+
+func NewMongoDailyCounterRepository(counters *mongo.Collection) *MongoDailyCounterRepository {
+	return &MongoDailyCounterRepository{counters: counters}
+}
+
+func (r *MongoDailyCounterRepository) Increment(ctx context.Context, name, date string) error {
+	ctx, span := startSpan(ctx, r.counters, "Increment")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	_, err := r.counters.UpdateOne(ctx,
+		bson.M{"_id": name + ":" + date},
+		bson.M{"$inc": bson.M{"seq": int64(1)}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *MongoDailyCounterRepository) Get(ctx context.Context, name, date string) (int64, error) {
+	ctx, span := startSpan(ctx, r.counters, "Get")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return 0, err
+	}
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := r.counters.FindOne(ctx, bson.M{"_id": name + ":" + date}).Decode(&counter)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return counter.Seq, nil
+}
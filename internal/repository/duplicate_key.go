@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/hello-api/internal/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// duplicateKeyCode is the server error code of a unique index violation
+const duplicateKeyCode = 11000
+
+// duplicateKeyIndex finds the index name in a duplicate key message such as
+// "E11000 duplicate key error collection: db.users index: email_unique dup key: ..."
+var duplicateKeyIndex = regexp.MustCompile(`index: (\S+)`)
+
+// IsDuplicateKey reports whether err is a unique index violation, from a
+// single write, a bulk write or a findAndModify command
+func IsDuplicateKey(err error) bool {
+	return mongo.IsDuplicateKeyError(err)
+}
+
+// DuplicateKeyIndex returns the name of the unique index err violated, or ""
+// when err is not a duplicate key error or the server did not name the index
+func DuplicateKeyIndex(err error) string {
+	if !IsDuplicateKey(err) {
+		return ""
+	}
+	var messages []string
+	var writeErr mongo.WriteException
+	var bulkErr mongo.BulkWriteException
+	var cmdErr mongo.CommandError
+	switch {
+	case errors.As(err, &writeErr):
+		for _, we := range writeErr.WriteErrors {
+			if we.Code == duplicateKeyCode {
+				messages = append(messages, we.Message)
+			}
+		}
+	case errors.As(err, &bulkErr):
+		for _, we := range bulkErr.WriteErrors {
+			if we.Code == duplicateKeyCode {
+				messages = append(messages, we.Message)
+			}
+		}
+	case errors.As(err, &cmdErr):
+		messages = append(messages, cmdErr.Message)
+	}
+	for _, message := range messages {
+		if match := duplicateKeyIndex.FindStringSubmatch(message); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// translateWriteError turns a duplicate key error into a domain error so it
+// reaches clients as a 409 rather than a driver message. byIndex names the
+// domain error of each index that has a more specific meaning, such as
+// ErrEmailAlreadyExists for the unique email index; other indexes give
+// ErrDuplicate with the index name. Other errors are returned unchanged.
+func translateWriteError(err error, byIndex map[string]error) error {
+	if !IsDuplicateKey(err) {
+		return err
+	}
+	index := DuplicateKeyIndex(err)
+	if mapped, ok := byIndex[index]; ok {
+		return mapped
+	}
+	if index == "" {
+		return domain.ErrDuplicate
+	}
+	return fmt.Errorf("unique index %s already holds this value: %w", index, domain.ErrDuplicate)
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/db"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// duplicateMessage is the server's message for a violation of index
+func duplicateMessage(index string) string {
+	return fmt.Sprintf(`E11000 duplicate key error collection: stock.users index: %s dup key: { email: "alice@example.com" }`, index)
+}
+
+// respond returns the status and error code a handler reports err with
+func respond(t *testing.T, err error) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	common.HandleError(rec, err)
+	var response common.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("undecodable body %q: %v", rec.Body.String(), err)
+	}
+	if response.Success || response.Error == nil {
+		t.Fatalf("got %s, want an error envelope", rec.Body.String())
+	}
+	return rec.Code, response.Error.Code
+}
+
+// Duplicate key errors of every write shape the driver returns reach clients
+// as a 409 naming what was duplicated; other errors pass through unchanged
+func TestTranslateWriteError(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "single write on the email index",
+			err:        mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: duplicateMessage(db.UserEmailIndex)}}},
+			wantStatus: http.StatusConflict, wantCode: "EMAIL_ALREADY_EXISTS"},
+		{name: "bulk write on the userId index",
+			err:        mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Code: 11000, Message: duplicateMessage("userId_1")}}}},
+			wantStatus: http.StatusConflict, wantCode: "USER_ALREADY_EXISTS"},
+		{name: "findAndModify on another index",
+			err:        mongo.CommandError{Code: 11000, Message: duplicateMessage("idempotencyKey_1")},
+			wantStatus: http.StatusConflict, wantCode: "DUPLICATE"},
+		{name: "unnamed index",
+			err:        mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}},
+			wantStatus: http.StatusConflict, wantCode: "DUPLICATE"},
+		{name: "other write error",
+			err:        mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 121, Message: "Document failed validation"}}},
+			wantStatus: http.StatusInternalServerError, wantCode: "INTERNAL_ERROR"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			translated := translateWriteError(tc.err, userDuplicates)
+			status, code := respond(t, translated)
+			if status != tc.wantStatus || code != tc.wantCode {
+				t.Errorf("got %d %s, want %d %s", status, code, tc.wantStatus, tc.wantCode)
+			}
+		})
+	}
+
+	err := translateWriteError(mongo.CommandError{Code: 11000, Message: duplicateMessage("idempotencyKey_1")}, nil)
+	if !errors.Is(err, domain.ErrDuplicate) || err.Error() != "unique index idempotencyKey_1 already holds this value: duplicate value" {
+		t.Errorf("got %v, want ErrDuplicate naming the index", err)
+	}
+}
+
+// mongoTestDatabase returns a throwaway database on the MongoDB at
+// MONGO_TEST_URI, dropped when the test ends, and skips the test without one
+func mongoTestDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := client.Database(fmt.Sprintf("stock_alert_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		database.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return database
+}
+
+// A real duplicate key error from the server is mapped the same way
+func TestMongoUserRepositoryDuplicateKey(t *testing.T) {
+	database := mongoTestDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	collection := database.Collection("users")
+	if _, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true).SetName("userId_1")},
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true).SetName(db.UserEmailIndex)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	users := NewMongoUserRepository(collection)
+	if _, err := users.Create(ctx, &entity.UserEntity{UserID: "alice", Name: "Alice", Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		user     *entity.UserEntity
+		wantCode string
+	}{
+		{name: "email", user: &entity.UserEntity{UserID: "bob", Name: "Bob", Email: "alice@example.com"}, wantCode: "EMAIL_ALREADY_EXISTS"},
+		{name: "userId", user: &entity.UserEntity{UserID: "alice", Name: "Alice", Email: "other@example.com"}, wantCode: "USER_ALREADY_EXISTS"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := users.Create(ctx, tc.user)
+			if err == nil {
+				t.Fatal("the duplicate was stored")
+			}
+			if status, code := respond(t, err); status != http.StatusConflict || code != tc.wantCode {
+				t.Errorf("got %d %s, want 409 %s", status, code, tc.wantCode)
+			}
+		})
+	}
+}
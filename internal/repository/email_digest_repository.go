@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoEmailDigestRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoEmailDigestRepository(collection *mongo.Collection) *MongoEmailDigestRepository {
+	return &MongoEmailDigestRepository{collection: collection}
+}
+
+func (r *MongoEmailDigestRepository) Add(ctx context.Context, item *dto.EmailDigestItem) error {
+	ctx, span := startSpan(ctx, r.collection, "Add")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	_, err := r.collection.InsertOne(ctx, newEmailDigestItemEntity(item))
+	if mongo.IsDuplicateKeyError(err) {
+		// The trigger is already held
+		return nil
+	}
+	return err
+}
+
+func (r *MongoEmailDigestRepository) DueGroups(ctx context.Context, before time.Time) ([]dto.EmailDigestGroup, error) {
+	ctx, span := startSpan(ctx, r.collection, "DueGroups")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"windowStart": bson.M{"$lt": before}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"userId": "$userId", "windowStart": "$windowStart"},
+			"email": bson.M{"$last": "$email"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.windowStart", Value: 1}}}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		ID struct {
+			UserID      string    `bson:"userId"`
+			WindowStart time.Time `bson:"windowStart"`
+		} `bson:"_id"`
+		Email string `bson:"email"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	groups := make([]dto.EmailDigestGroup, 0, len(rows))
+	for _, row := range rows {
+		groups = append(groups, dto.EmailDigestGroup{UserID: row.ID.UserID, Email: row.Email, WindowStart: row.ID.WindowStart})
+	}
+	return groups, nil
+}
+
+func (r *MongoEmailDigestRepository) Items(ctx context.Context, group dto.EmailDigestGroup) ([]dto.EmailDigestItem, error) {
+	ctx, span := startSpan(ctx, r.collection, "Items")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"userId": group.UserID, "windowStart": group.WindowStart}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var items []entity.EmailDigestItemEntity
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	result := make([]dto.EmailDigestItem, 0, len(items))
+	for i := range items {
+		result = append(result, mapEmailDigestItemEntityToDTO(&items[i]))
+	}
+	return result, nil
+}
+
+func (r *MongoEmailDigestRepository) Remove(ctx context.Context, group dto.EmailDigestGroup) error {
+	ctx, span := startSpan(ctx, r.collection, "Remove")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	_, err := r.collection.DeleteMany(ctx, bson.M{"userId": group.UserID, "windowStart": group.WindowStart})
+	return err
+}
+
+func newEmailDigestItemEntity(item *dto.EmailDigestItem) entity.EmailDigestItemEntity {
+	return entity.EmailDigestItemEntity{
+		ID:          item.AlertID + ":" + item.TriggerID,
+		UserID:      item.UserID,
+		Email:       item.Email,
+		AlertID:     item.AlertID,
+		TriggerID:   item.TriggerID,
+		WindowStart: item.WindowStart,
+		Payload:     string(item.Payload),
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+func mapEmailDigestItemEntityToDTO(item *entity.EmailDigestItemEntity) dto.EmailDigestItem {
+	return dto.EmailDigestItem{
+		UserID:      item.UserID,
+		Email:       item.Email,
+		AlertID:     item.AlertID,
+		TriggerID:   item.TriggerID,
+		WindowStart: item.WindowStart,
+		Payload:     json.RawMessage(item.Payload),
+	}
+}
@@ -2,11 +2,14 @@ package entity
 
 import (
 	"time"
+
+	"github.com/hello-api/pkg/money"
 )
 
 // AlertStatus and AlertRule enums
 type AlertStatus string
 type AlertRule string
+type AlertBaseline string
 
 const (
 	AlertStatusActive   AlertStatus = "active"
@@ -14,18 +17,89 @@ const (
 
 	AlertRuleAbove AlertRule = "above"
 	AlertRuleBelow AlertRule = "below"
+
+	AlertRulePercentChangeAbove AlertRule = "percent_change_above"
+	AlertRulePercentChangeBelow AlertRule = "percent_change_below"
+
+	AlertBaselinePreviousClose AlertBaseline = "previousClose"
+	AlertBaselineDayOpen       AlertBaseline = "dayOpen"
 )
 
 // AlertEntity represents the alert as stored in the database
 type AlertEntity struct {
-	ID        string      `bson:"_id,omitempty" json:"id"`
-	Name      string      `bson:"name" json:"name"`
-	Price     float64     `bson:"price" json:"price"`
-	Rule      AlertRule   `bson:"rule" json:"rule"`
-	StopDate  time.Time   `bson:"stopDate" json:"stopDate"`
-	StartDate time.Time   `bson:"startDate" json:"startDate"`
-	Status    AlertStatus `bson:"status" json:"status"`
-	UserID    string      `bson:"userId" json:"userId"`
-	CreatedAt time.Time   `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time   `bson:"updated_at" json:"updated_at"`
+	ID               string               `bson:"_id,omitempty" json:"id"`
+	Name             string               `bson:"name" json:"name"`
+	Price            money.Amount         `bson:"price" json:"price"`
+	Rule             AlertRule            `bson:"rule" json:"rule"`
+	StopDate         time.Time            `bson:"stopDate" json:"stopDate"`
+	StartDate        time.Time            `bson:"startDate" json:"startDate"`
+	Status           AlertStatus          `bson:"status" json:"status"`
+	UserID           string               `bson:"userId" json:"userId"`
+	WebhookURL       string               `bson:"webhookUrl,omitempty" json:"webhookUrl,omitempty"`
+	EvaluateOffHours bool                 `bson:"evaluateOffHours" json:"evaluateOffHours"`
+	Symbol           string               `bson:"symbol,omitempty" json:"symbol,omitempty"`
+	Baseline         AlertBaseline        `bson:"baseline,omitempty" json:"baseline,omitempty"`
+	NotifyTelegram   bool                 `bson:"notifyTelegram,omitempty" json:"notifyTelegram,omitempty"`
+	NotifyEmail      bool                 `bson:"notifyEmail,omitempty" json:"notifyEmail,omitempty"`
+	Urgent           bool                 `bson:"urgent,omitempty" json:"urgent,omitempty"`
+	NotifyOverride   *AlertNotifyOverride `bson:"notifyOverride,omitempty" json:"notifyOverride,omitempty"`
+	AppliedDefaults  []string             `bson:"appliedDefaults,omitempty" json:"appliedDefaults,omitempty"`
+	Shadow           bool                 `bson:"shadow,omitempty" json:"shadow,omitempty"`
+	Triggered        bool                 `bson:"triggered" json:"triggered"`
+	LastTriggeredAt  *time.Time           `bson:"lastTriggeredAt,omitempty" json:"lastTriggeredAt,omitempty"`
+	CreatedAt        time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt        time.Time            `bson:"updated_at" json:"updated_at"`
+}
+
+// ArchivedAlertEntity is a fired alert moved to the archive collection
+type ArchivedAlertEntity struct {
+	AlertEntity `bson:",inline"`
+	ArchivedAt  time.Time `bson:"archivedAt" json:"archivedAt"`
+}
+
+// AlertNotifyOverride is the single destination of an alert's notifications
+type AlertNotifyOverride struct {
+	Channel string `bson:"channel" json:"channel"`
+	Target  string `bson:"target" json:"target"`
+}
+
+// AlertChangeEntity is an entry of the alert change feed, keyed by its cursor
+type AlertChangeEntity struct {
+	Cursor  int64       `bson:"_id" json:"cursor"`
+	AlertID string      `bson:"alertId" json:"alertId"`
+	UserID  string      `bson:"userId" json:"userId"`
+	Symbol  string      `bson:"symbol,omitempty" json:"symbol,omitempty"`
+	Status  AlertStatus `bson:"status" json:"status"`
+	Change  string      `bson:"change" json:"change"`
+	At      time.Time   `bson:"at" json:"at"`
+}
+
+// EvaluationGateEntity is one gate of a sampled evaluation
+type EvaluationGateEntity struct {
+	Name   string `bson:"name" json:"name"`
+	Passed bool   `bson:"passed" json:"passed"`
+	Reason string `bson:"reason" json:"reason"`
+}
+
+// EvaluationSampleEntity is a sampled tick evaluation of an alert
+type EvaluationSampleEntity struct {
+	ID           string                 `bson:"_id,omitempty" json:"id"`
+	AlertID      string                 `bson:"alertId" json:"alertId"`
+	Symbol       string                 `bson:"symbol" json:"symbol"`
+	Price        money.Amount           `bson:"price" json:"price"`
+	TickTime     time.Time              `bson:"tickTime" json:"tickTime"`
+	WasTriggered bool                   `bson:"wasTriggered" json:"wasTriggered"`
+	Gates        []EvaluationGateEntity `bson:"gates" json:"gates"`
+	Outcome      string                 `bson:"outcome" json:"outcome"`
+	Detail       string                 `bson:"detail,omitempty" json:"detail,omitempty"`
+	SampledBy    string                 `bson:"sampledBy" json:"sampledBy"`
+	Shadow       bool                   `bson:"shadow,omitempty" json:"shadow,omitempty"`
+	EvaluatedAt  time.Time              `bson:"evaluatedAt" json:"evaluatedAt"`
+	ExpiresAt    time.Time              `bson:"expiresAt" json:"expiresAt"`
+}
+
+// EvaluationSamplingEntity samples an alert's evaluations until ExpiresAt
+type EvaluationSamplingEntity struct {
+	AlertID   string    `bson:"_id" json:"alertId"`
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
 }
@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// EmailDigestItemEntity is a trigger waiting for its digest email. The id is
+// the alert and trigger id, so a redelivered trigger is held once.
+type EmailDigestItemEntity struct {
+	ID          string    `bson:"_id" json:"id"`
+	UserID      string    `bson:"userId" json:"userId"`
+	Email       string    `bson:"email" json:"email"`
+	AlertID     string    `bson:"alertId" json:"alertId"`
+	TriggerID   string    `bson:"triggerId" json:"triggerId"`
+	WindowStart time.Time `bson:"windowStart" json:"windowStart"`
+	Payload     string    `bson:"payload" json:"payload"`
+	CreatedAt   time.Time `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package entity
+
+import "time"
+
+// FeatureFlagEntity is a flag value set through the admin route, keyed by flag name
+type FeatureFlagEntity struct {
+	Name      string    `bson:"_id" json:"name"`
+	Value     string    `bson:"value" json:"value"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
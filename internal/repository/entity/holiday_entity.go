@@ -0,0 +1,12 @@
+package entity
+
+import (
+	"time"
+)
+
+// HolidayEntity is a market holiday; the date doubles as the id so each day is stored once
+type HolidayEntity struct {
+	Date      string    `bson:"_id" json:"date"`
+	Name      string    `bson:"name" json:"name"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+)
+
+type NotificationStatus string
+
+const (
+	NotificationStatusPending   NotificationStatus = "pending"
+	NotificationStatusInFlight  NotificationStatus = "in_flight"
+	NotificationStatusDelivered NotificationStatus = "delivered"
+	NotificationStatusFailed    NotificationStatus = "failed"
+)
+
+// NotificationEntity is an outbound notification delivery as stored in the outbox
+type NotificationEntity struct {
+	ID            string             `bson:"_id,omitempty" json:"id"`
+	AlertID       string             `bson:"alertId" json:"alertId"`
+	TriggerID     string             `bson:"triggerId" json:"triggerId"`
+	Channel       string             `bson:"channel" json:"channel"`
+	Destination   string             `bson:"destination" json:"destination"`
+	Payload       string             `bson:"payload" json:"payload"`
+	Status        NotificationStatus `bson:"status" json:"status"`
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	NextAttemptAt time.Time          `bson:"nextAttemptAt" json:"nextAttemptAt"`
+	// LockedUntil is the claim lease; an in-flight row whose lease expired is
+	// claimable again, so a worker that dies mid-delivery does not strand it
+	LockedUntil time.Time  `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	LastError   string     `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	DeliveredAt *time.Time `bson:"deliveredAt,omitempty" json:"deliveredAt,omitempty"`
+	CreatedAt   time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `bson:"updated_at" json:"updated_at"`
+}
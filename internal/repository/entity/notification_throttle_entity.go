@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// NotificationThrottleEntity counts a user's notifications over one channel in
+// one clock hour. The id is the user, channel and window start, so concurrent
+// counts of the same window land on one document.
+type NotificationThrottleEntity struct {
+	ID          string    `bson:"_id" json:"id"`
+	UserID      string    `bson:"userId" json:"userId"`
+	Channel     string    `bson:"channel" json:"channel"`
+	Destination string    `bson:"destination" json:"destination"`
+	WindowStart time.Time `bson:"windowStart" json:"windowStart"`
+	WindowEnd   time.Time `bson:"windowEnd" json:"windowEnd"`
+	Count       int64     `bson:"count" json:"count"`
+}
@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/hello-api/pkg/money"
+)
+
+type QuarantineStatus string
+
+const (
+	QuarantineStatusHeld     QuarantineStatus = "quarantined"
+	QuarantineStatusReleased QuarantineStatus = "released"
+)
+
+// PriceTickEntity is an accepted price tick
+type PriceTickEntity struct {
+	ID     string       `bson:"_id,omitempty" json:"id"`
+	Symbol string       `bson:"symbol" json:"symbol"`
+	Price  money.Amount `bson:"price" json:"price"`
+	Volume float64      `bson:"volume" json:"volume"`
+	Time   time.Time    `bson:"time" json:"time"`
+	// Lag is how stale the tick was when ingested; absent on backfilled ticks
+	Lag       *TickLagEntity `bson:"lag,omitempty" json:"lag,omitempty"`
+	CreatedAt time.Time      `bson:"created_at" json:"created_at"`
+}
+
+// TickLagEntity is how stale a tick was when ingested, in milliseconds
+type TickLagEntity struct {
+	AsOf     time.Time `bson:"asOf" json:"asOf"`
+	FeedMs   *int64    `bson:"feedMs,omitempty" json:"feedMs,omitempty"`
+	IngestMs int64     `bson:"ingestMs" json:"ingestMs"`
+	TotalMs  int64     `bson:"totalMs" json:"totalMs"`
+}
+
+// LatestPriceEntity is the latest price of a symbol and its trading day
+// statistics, one document per symbol keyed by the symbol
+type LatestPriceEntity struct {
+	Symbol        string        `bson:"_id" json:"symbol"`
+	Price         money.Amount  `bson:"price" json:"price"`
+	Time          time.Time     `bson:"time" json:"time"`
+	TradingDate   string        `bson:"tradingDate" json:"tradingDate"`
+	DayOpen       money.Amount  `bson:"dayOpen" json:"dayOpen"`
+	OpenTime      time.Time     `bson:"openTime" json:"openTime"`
+	DayHigh       money.Amount  `bson:"dayHigh" json:"dayHigh"`
+	DayLow        money.Amount  `bson:"dayLow" json:"dayLow"`
+	PreviousClose *money.Amount `bson:"previousClose,omitempty" json:"previousClose,omitempty"`
+	Volume        float64       `bson:"volume" json:"volume"`
+	// Lag is that of the tick holding the latest price
+	Lag       *TickLagEntity `bson:"lag,omitempty" json:"lag,omitempty"`
+	UpdatedAt time.Time      `bson:"updated_at" json:"updated_at"`
+}
+
+// QuarantinedTickEntity is a tick held back by the sanity checks, kept for review
+type QuarantinedTickEntity struct {
+	ID                string           `bson:"_id,omitempty" json:"id"`
+	Symbol            string           `bson:"symbol" json:"symbol"`
+	Price             money.Amount     `bson:"price" json:"price"`
+	Volume            float64          `bson:"volume" json:"volume"`
+	Time              time.Time        `bson:"time" json:"time"`
+	Reason            string           `bson:"reason" json:"reason"`
+	LastAcceptedPrice *money.Amount    `bson:"lastAcceptedPrice,omitempty" json:"lastAcceptedPrice,omitempty"`
+	Status            QuarantineStatus `bson:"status" json:"status"`
+	ReleasedAt        *time.Time       `bson:"releasedAt,omitempty" json:"releasedAt,omitempty"`
+	CreatedAt         time.Time        `bson:"created_at" json:"created_at"`
+}
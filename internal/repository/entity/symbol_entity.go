@@ -0,0 +1,17 @@
+package entity
+
+import (
+	"time"
+)
+
+// SymbolEntity is the reference data of a tradable symbol, keyed by the symbol
+type SymbolEntity struct {
+	Symbol string `bson:"_id" json:"symbol"`
+	Name   string `bson:"name,omitempty" json:"name,omitempty"`
+	// NameKey is the upper-cased name, for prefix searches by name
+	NameKey    string     `bson:"nameKey,omitempty" json:"-"`
+	Sector     string     `bson:"sector,omitempty" json:"sector,omitempty"`
+	LastSeenAt *time.Time `bson:"lastSeenAt,omitempty" json:"lastSeenAt,omitempty"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time  `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+// TelegramLinkCodeEntity is a pending Telegram link, removed when used or expired
+type TelegramLinkCodeEntity struct {
+	Code      string    `bson:"_id" json:"code"`
+	UserID    string    `bson:"userId" json:"userId"`
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
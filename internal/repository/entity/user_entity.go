@@ -12,6 +12,35 @@ type UserEntity struct {
 	UserID    string            `bson:"userId"`
 	Name      string            `bson:"name"`
 	Email     string            `bson:"email"`
+	// TelegramChatID is the chat linked through the bot; 0 when none is linked
+	TelegramChatID int64        `bson:"telegramChatId,omitempty"`
+	// EmailMode is immediate or hourly; empty means immediate
+	EmailMode string             `bson:"emailMode,omitempty"`
+	// QuietHours hold non-urgent notifications back; nil when not set. Not
+	// omitted when empty so an update clears it.
+	QuietHours *QuietHoursEntity `bson:"quietHours"`
+	// DisplayTimezone is the IANA timezone of rendered notifications; empty
+	// means the market timezone
+	DisplayTimezone string `bson:"displayTimezone"`
+	// MutedUntil suppresses every notification of triggers before it; nil
+	// when not muted. Not omitted when empty so an update clears it.
+	MutedUntil *time.Time `bson:"mutedUntil"`
+	// AlertDefaults fill in the fields new alerts leave unset; nil when not
+	// set. Not omitted when empty so an update clears it.
+	AlertDefaults *AlertDefaultsEntity `bson:"alertDefaults"`
 	CreatedAt time.Time         `bson:"created_at"`
 	UpdatedAt time.Time         `bson:"updated_at"`
 }
+
+// AlertDefaultsEntity are the settings applied to a user's new alerts
+type AlertDefaultsEntity struct {
+	DurationDays int      `bson:"durationDays,omitempty"`
+	Channels     []string `bson:"channels,omitempty"`
+}
+
+// QuietHoursEntity is a daily "HH:MM" window in the given IANA timezone
+type QuietHoursEntity struct {
+	Start    string `bson:"start"`
+	End      string `bson:"end"`
+	Timezone string `bson:"timezone,omitempty"`
+}
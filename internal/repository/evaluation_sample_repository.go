@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoEvaluationSampleRepository stores samples in a capped collection and
+// sampling flags in a collection with a TTL index on their expiry
+type MongoEvaluationSampleRepository struct {
+	samples  *mongo.Collection
+	sampling *mongo.Collection
+}
+
+func NewMongoEvaluationSampleRepository(samples, sampling *mongo.Collection) *MongoEvaluationSampleRepository {
+	return &MongoEvaluationSampleRepository{samples: samples, sampling: sampling}
+}
+
+func (r *MongoEvaluationSampleRepository) SetSampling(ctx context.Context, alertID string, expiresAt time.Time) error {
+	ctx, span := startSpan(ctx, r.sampling, "SetSampling")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	_, err := r.sampling.ReplaceOne(ctx, bson.M{"_id": alertID},
+		entity.EvaluationSamplingEntity{AlertID: alertID, ExpiresAt: expiresAt},
+		options.Replace().SetUpsert(true))
+	return err
+}
+
+// ActiveSampling filters on the expiry too, as the TTL monitor removes expired
+// flags only about once a minute
+func (r *MongoEvaluationSampleRepository) ActiveSampling(ctx context.Context, now time.Time) (map[string]time.Time, error) {
+	ctx, span := startSpan(ctx, r.sampling, "ActiveSampling")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	cursor, err := r.sampling.Find(ctx, bson.M{"expiresAt": bson.M{"$gt": now}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var flags []entity.EvaluationSamplingEntity
+	if err := cursor.All(ctx, &flags); err != nil {
+		return nil, err
+	}
+	result := make(map[string]time.Time, len(flags))
+	for _, flag := range flags {
+		result[flag.AlertID] = flag.ExpiresAt
+	}
+	return result, nil
+}
+
+func (r *MongoEvaluationSampleRepository) Insert(ctx context.Context, samples []dto.EvaluationSampleRequest) error {
+	ctx, span := startSpan(ctx, r.samples, "Insert")
+	defer span.End()
+
+	if len(samples) == 0 {
+		return nil
+	}
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	docs := make([]interface{}, 0, len(samples))
+	for i := range samples {
+		docs = append(docs, newEvaluationSampleEntity(&samples[i]))
+	}
+	_, err := r.samples.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return err
+}
+
+// FindByAlert skips expired samples: a capped collection cannot have a TTL
+// index, so old samples stay until newer ones overwrite them
+func (r *MongoEvaluationSampleRepository) FindByAlert(ctx context.Context, alertID string, now time.Time, limit int64, shadowOnly bool) ([]dto.EvaluationSampleResponse, error) {
+	ctx, span := startSpan(ctx, r.samples, "FindByAlert")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	filter := bson.M{"alertId": alertID, "expiresAt": bson.M{"$gt": now}}
+	if shadowOnly {
+		filter["shadow"] = true
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "evaluatedAt", Value: -1}}).SetLimit(limit)
+	cursor, err := r.samples.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var samples []entity.EvaluationSampleEntity
+	if err := cursor.All(ctx, &samples); err != nil {
+		return nil, err
+	}
+	result := make([]dto.EvaluationSampleResponse, 0, len(samples))
+	for i := range samples {
+		result = append(result, mapEvaluationSampleEntityToDTO(&samples[i]))
+	}
+	return result, nil
+}
+
+func newEvaluationSampleEntity(req *dto.EvaluationSampleRequest) entity.EvaluationSampleEntity {
+	gates := make([]entity.EvaluationGateEntity, 0, len(req.Gates))
+	for _, gate := range req.Gates {
+		gates = append(gates, entity.EvaluationGateEntity{Name: gate.Name, Passed: gate.Passed, Reason: gate.Reason})
+	}
+	return entity.EvaluationSampleEntity{
+		ID:           primitive.NewObjectID().Hex(),
+		AlertID:      req.AlertID,
+		Symbol:       req.Symbol,
+		Price:        req.Price,
+		TickTime:     req.TickTime,
+		WasTriggered: req.WasTriggered,
+		Gates:        gates,
+		Outcome:      string(req.Outcome),
+		Detail:       req.Detail,
+		SampledBy:    req.SampledBy,
+		Shadow:       req.Shadow,
+		EvaluatedAt:  req.EvaluatedAt,
+		ExpiresAt:    req.ExpiresAt,
+	}
+}
+
+func mapEvaluationSampleEntityToDTO(sample *entity.EvaluationSampleEntity) dto.EvaluationSampleResponse {
+	gates := make([]dto.EvaluationGate, 0, len(sample.Gates))
+	for _, gate := range sample.Gates {
+		gates = append(gates, dto.EvaluationGate{Name: gate.Name, Passed: gate.Passed, Reason: gate.Reason})
+	}
+	return dto.EvaluationSampleResponse{
+		ID:           sample.ID,
+		AlertID:      sample.AlertID,
+		Symbol:       sample.Symbol,
+		Price:        sample.Price,
+		TickTime:     sample.TickTime,
+		WasTriggered: sample.WasTriggered,
+		Gates:        gates,
+		Outcome:      dto.EvaluationOutcome(sample.Outcome),
+		Detail:       sample.Detail,
+		SampledBy:    sample.SampledBy,
+		Shadow:       sample.Shadow,
+		EvaluatedAt:  sample.EvaluatedAt,
+		ExpiresAt:    sample.ExpiresAt,
+	}
+}
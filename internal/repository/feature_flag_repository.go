@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoFeatureFlagRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoFeatureFlagRepository(collection *mongo.Collection) *MongoFeatureFlagRepository {
+	return &MongoFeatureFlagRepository{collection: collection}
+}
+
+func (r *MongoFeatureFlagRepository) Set(ctx context.Context, name, value string, at time.Time) error {
+	ctx, span := startSpan(ctx, r.collection, "Set")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": name},
+		entity.FeatureFlagEntity{Name: name, Value: value, UpdatedAt: at},
+		options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *MongoFeatureFlagRepository) Delete(ctx context.Context, name string) error {
+	ctx, span := startSpan(ctx, r.collection, "Delete")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": name})
+	return err
+}
+
+func (r *MongoFeatureFlagRepository) FindAll(ctx context.Context) ([]dto.FeatureFlagOverride, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindAll")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	cursor, err := r.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var flags []entity.FeatureFlagEntity
+	if err := cursor.All(ctx, &flags); err != nil {
+		return nil, err
+	}
+	result := make([]dto.FeatureFlagOverride, 0, len(flags))
+	for _, flag := range flags {
+		result = append(result, dto.FeatureFlagOverride{Name: flag.Name, Value: flag.Value, UpdatedAt: flag.UpdatedAt})
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoHolidayRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoHolidayRepository(collection *mongo.Collection) *MongoHolidayRepository {
+	return &MongoHolidayRepository{collection: collection}
+}
+
+func (r *MongoHolidayRepository) FindAll(ctx context.Context) ([]dto.HolidayResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindAll")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var holidays []entity.HolidayEntity
+	if err := cursor.All(ctx, &holidays); err != nil {
+		return nil, err
+	}
+	result := make([]dto.HolidayResponse, 0, len(holidays))
+	for i := range holidays {
+		result = append(result, *mapHolidayEntityToDTO(&holidays[i]))
+	}
+	return result, nil
+}
+
+func (r *MongoHolidayRepository) Add(ctx context.Context, req *dto.HolidayRequest) (*dto.HolidayResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "Add")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	update := bson.M{
+		"$set":         bson.M{"name": req.Name},
+		"$setOnInsert": bson.M{"created_at": time.Now().UTC()},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var holiday entity.HolidayEntity
+	if err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": req.Date}, update, opts).Decode(&holiday); err != nil {
+		return nil, translateWriteError(err, nil)
+	}
+	return mapHolidayEntityToDTO(&holiday), nil
+}
+
+func (r *MongoHolidayRepository) Delete(ctx context.Context, date string) error {
+	ctx, span := startSpan(ctx, r.collection, "Delete")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": date})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return domain.ErrHolidayNotFound
+	}
+	return nil
+}
+
+func mapHolidayEntityToDTO(holiday *entity.HolidayEntity) *dto.HolidayResponse {
+	return &dto.HolidayResponse{
+		Date:      holiday.Date,
+		Name:      holiday.Name,
+		CreatedAt: holiday.CreatedAt,
+	}
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/mapper"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// MemoryAlertArchiveRepository is an in-memory AlertArchiveRepository that
+// moves alerts out of and back into a MemoryAlertRepository. Both locks are
+// held for a move, so it is atomic.
+type MemoryAlertArchiveRepository struct {
+	alerts *MemoryAlertRepository
+
+	mu       sync.RWMutex
+	archived map[string]entity.ArchivedAlertEntity
+}
+
+func NewMemoryAlertArchiveRepository(alerts *MemoryAlertRepository) *MemoryAlertArchiveRepository {
+	return &MemoryAlertArchiveRepository{
+		alerts:   alerts,
+		archived: make(map[string]entity.ArchivedAlertEntity),
+	}
+}
+
+func (r *MemoryAlertArchiveRepository) Archive(ctx context.Context, before time.Time, limit int64) ([]dto.AlertResponse, error) {
+	r.alerts.mu.Lock()
+	defer r.alerts.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	archivedAt := time.Now().UTC()
+	var result []dto.AlertResponse
+	kept := r.alerts.order[:0]
+	for _, id := range r.alerts.order {
+		alert := r.alerts.alerts[id]
+		if int64(len(result)) >= limit || alert.Status != entity.AlertStatusInactive || alert.LastTriggeredAt == nil ||
+			alert.StopDate.IsZero() || !alert.StopDate.Before(before) || !alert.UpdatedAt.Before(before) {
+			kept = append(kept, id)
+			continue
+		}
+		r.archived[id] = entity.ArchivedAlertEntity{AlertEntity: alert, ArchivedAt: archivedAt}
+		delete(r.alerts.alerts, id)
+		result = append(result, *mapper.AlertToDTO(&alert))
+	}
+	r.alerts.order = kept
+	return result, nil
+}
+
+func (r *MemoryAlertArchiveRepository) FindByUser(ctx context.Context, userID string, page, pageSize int64) ([]dto.ArchivedAlertResponse, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []entity.ArchivedAlertEntity
+	for _, alert := range r.archived {
+		if alert.UserID == userID {
+			matched = append(matched, alert)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].ArchivedAt.Equal(matched[j].ArchivedAt) {
+			return matched[i].ArchivedAt.After(matched[j].ArchivedAt)
+		}
+		return matched[i].ID < matched[j].ID
+	})
+
+	total := int64(len(matched))
+	result := []dto.ArchivedAlertResponse{}
+	for i := (page - 1) * pageSize; i < total && i < page*pageSize; i++ {
+		result = append(result, mapper.ArchivedAlertToDTO(&matched[i]))
+	}
+	return result, total, nil
+}
+
+func (r *MemoryAlertArchiveRepository) Restore(ctx context.Context, id string) (*dto.AlertResponse, error) {
+	r.alerts.mu.Lock()
+	defer r.alerts.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	archived, ok := r.archived[id]
+	if !ok {
+		return nil, nil
+	}
+	delete(r.archived, id)
+	archived.UpdatedAt = time.Now().UTC()
+	if _, live := r.alerts.alerts[id]; !live {
+		r.alerts.alerts[id] = archived.AlertEntity
+		r.alerts.order = append(r.alerts.order, id)
+	}
+	return mapper.AlertToDTO(&archived.AlertEntity), nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// MemoryAlertChangeRepository is an in-memory AlertChangeRepository for local
+// development and tests. Cursors restart from zero with the process.
+type MemoryAlertChangeRepository struct {
+	mu      sync.Mutex
+	seq     int64
+	changes []entity.AlertChangeEntity
+}
+
+func NewMemoryAlertChangeRepository() *MemoryAlertChangeRepository {
+	return &MemoryAlertChangeRepository{}
+}
+
+func (r *MemoryAlertChangeRepository) NextCursor(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	return r.seq, nil
+}
+
+func (r *MemoryAlertChangeRepository) Append(ctx context.Context, req *dto.AlertChangeRequest) (*dto.AlertChangeResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	change := newAlertChangeEntity(req)
+	// Keep the log in cursor order even if appends arrive out of order
+	i := len(r.changes)
+	for i > 0 && r.changes[i-1].Cursor > change.Cursor {
+		i--
+	}
+	r.changes = append(r.changes, entity.AlertChangeEntity{})
+	copy(r.changes[i+1:], r.changes[i:])
+	r.changes[i] = change
+	return mapAlertChangeEntityToDTO(&change), nil
+}
+
+func (r *MemoryAlertChangeRepository) Since(ctx context.Context, since int64, limit int64) ([]dto.AlertChangeResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []dto.AlertChangeResponse{}
+	for i := range r.changes {
+		if r.changes[i].Cursor <= since {
+			continue
+		}
+		if int64(len(result)) >= limit {
+			break
+		}
+		result = append(result, *mapAlertChangeEntityToDTO(&r.changes[i]))
+	}
+	return result, nil
+}
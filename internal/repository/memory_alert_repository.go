@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/mapper"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryAlertRepository is an in-memory AlertRepository for local development and tests.
+// It keeps insertion order so listings match the Mongo natural order.
+type MemoryAlertRepository struct {
+	mu     sync.RWMutex
+	alerts map[string]entity.AlertEntity
+	order  []string
+}
+
+func NewMemoryAlertRepository() *MemoryAlertRepository {
+	return &MemoryAlertRepository{
+		alerts: make(map[string]entity.AlertEntity),
+	}
+}
+
+func (r *MemoryAlertRepository) Create(ctx context.Context, alertReq *dto.AlertCreateRequest) (*dto.AlertResponse, error) {
+	alertEntity := mapper.AlertToEntity(alertReq)
+	alertEntity.ID = primitive.NewObjectID().Hex()
+	alertEntity.CreatedAt = time.Now().UTC()
+	alertEntity.UpdatedAt = alertEntity.CreatedAt
+
+	r.mu.Lock()
+	r.alerts[alertEntity.ID] = alertEntity
+	r.order = append(r.order, alertEntity.ID)
+	r.mu.Unlock()
+
+	return mapper.AlertToDTO(&alertEntity), nil
+}
+
+func (r *MemoryAlertRepository) FindByID(ctx context.Context, id string) (*dto.AlertResponse, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	alert, ok := r.alerts[id]
+	if !ok {
+		return nil, nil
+	}
+	return mapper.AlertToDTO(&alert), nil
+}
+
+func (r *MemoryAlertRepository) FindAllByUser(ctx context.Context, userId string) ([]dto.AlertResponse, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []dto.AlertResponse
+	for _, id := range r.order {
+		alert := r.alerts[id]
+		if alert.UserID == userId {
+			result = append(result, *mapper.AlertToDTO(&alert))
+		}
+	}
+	return result, nil
+}
+
+func (r *MemoryAlertRepository) FindActive(ctx context.Context) ([]dto.AlertResponse, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []dto.AlertResponse
+	for _, id := range r.order {
+		alert := r.alerts[id]
+		if alert.Status == entity.AlertStatusActive {
+			result = append(result, *mapper.AlertToDTO(&alert))
+		}
+	}
+	return result, nil
+}
+
+func (r *MemoryAlertRepository) DistinctActiveSymbols(ctx context.Context, at time.Time) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	symbols := []string{}
+	for _, alert := range r.alerts {
+		if alert.Status != entity.AlertStatusActive || alert.Symbol == "" || seen[alert.Symbol] {
+			continue
+		}
+		if at.Before(alert.StartDate) || (!alert.StopDate.IsZero() && at.After(alert.StopDate)) {
+			continue
+		}
+		seen[alert.Symbol] = true
+		symbols = append(symbols, alert.Symbol)
+	}
+	sort.Strings(symbols)
+	return symbols, nil
+}
+
+func (r *MemoryAlertRepository) MarkTriggered(ctx context.Context, id string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	alert, ok := r.alerts[id]
+	if !ok || alert.Triggered {
+		return false, nil
+	}
+	alert.Triggered = true
+	alert.LastTriggeredAt = &at
+	r.alerts[id] = alert
+	return true, nil
+}
+
+func (r *MemoryAlertRepository) Rearm(ctx context.Context, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	alert, ok := r.alerts[id]
+	if !ok || !alert.Triggered {
+		return false, nil
+	}
+	alert.Triggered = false
+	r.alerts[id] = alert
+	return true, nil
+}
+
+func (r *MemoryAlertRepository) FindAfter(ctx context.Context, after string, limit int64) ([]dto.AlertResponse, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.order))
+	for _, id := range r.order {
+		if id > after {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if int64(len(ids)) > limit {
+		ids = ids[:limit]
+	}
+	result := make([]dto.AlertResponse, 0, len(ids))
+	for _, id := range ids {
+		alert := r.alerts[id]
+		result = append(result, *mapper.AlertToDTO(&alert))
+	}
+	return result, nil
+}
+
+func (r *MemoryAlertRepository) Deactivate(ctx context.Context, ids []string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var changed int64
+	for _, id := range ids {
+		alert, ok := r.alerts[id]
+		if !ok || alert.Status != entity.AlertStatusActive {
+			continue
+		}
+		alert.Status = entity.AlertStatusInactive
+		alert.UpdatedAt = time.Now().UTC()
+		r.alerts[id] = alert
+		changed++
+	}
+	return changed, nil
+}
+
+func (r *MemoryAlertRepository) Update(ctx context.Context, id string, alertReq *dto.AlertCreateRequest) (*dto.AlertResponse, error) {
+	r.mu.Lock()
+	alert, ok := r.alerts[id]
+	if ok {
+		mapper.ApplyAlertUpdate(&alert, alertReq)
+		alert.Triggered = false
+		alert.UpdatedAt = time.Now().UTC()
+		r.alerts[id] = alert
+	}
+	r.mu.Unlock()
+
+	return r.FindByID(ctx, id)
+}
+
+func (r *MemoryAlertRepository) SetShadow(ctx context.Context, id string, shadow bool) (*dto.AlertResponse, error) {
+	r.mu.Lock()
+	alert, ok := r.alerts[id]
+	if ok {
+		alert.Shadow = shadow
+		alert.UpdatedAt = time.Now().UTC()
+		r.alerts[id] = alert
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		return nil, nil
+	}
+	return r.FindByID(ctx, id)
+}
+
+func (r *MemoryAlertRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.alerts[id]; !ok {
+		return nil
+	}
+	delete(r.alerts, id)
+	for i, existing := range r.order {
+		if existing == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
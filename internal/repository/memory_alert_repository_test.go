@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/money"
+)
+
+func createAlert(t *testing.T, repo *MemoryAlertRepository, userID, symbol string, status dto.AlertStatus) *dto.AlertResponse {
+	t.Helper()
+	created, err := repo.Create(context.Background(), &dto.AlertCreateRequest{
+		UserID: userID,
+		Symbol: symbol,
+		Rule:   dto.AlertRuleAbove,
+		Price:  money.FromFloat(100),
+		Status: status,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+func TestMemoryAlertRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryAlertRepository()
+	first := createAlert(t, repo, "alice", "GP", dto.AlertStatusActive)
+	second := createAlert(t, repo, "bob", "BATBC", dto.AlertStatusActive)
+	third := createAlert(t, repo, "alice", "ACI", dto.AlertStatusInactive)
+
+	byUser, _ := repo.FindAllByUser(ctx, "alice")
+	if len(byUser) != 2 || byUser[0].ID != first.ID || byUser[1].ID != third.ID {
+		t.Errorf("alice's alerts are %v, want %s and %s in creation order", alertIDs(byUser), first.ID, third.ID)
+	}
+	active, _ := repo.FindActive(ctx)
+	if len(active) != 2 || active[0].ID != first.ID || active[1].ID != second.ID {
+		t.Errorf("active alerts are %v, want %s and %s", alertIDs(active), first.ID, second.ID)
+	}
+
+	// Only one of two concurrent triggers wins until the alert is re-armed
+	if won, _ := repo.MarkTriggered(ctx, first.ID, time.Now()); !won {
+		t.Error("first trigger was refused")
+	}
+	if won, _ := repo.MarkTriggered(ctx, first.ID, time.Now()); won {
+		t.Error("second trigger of a triggered alert was accepted")
+	}
+	if rearmed, _ := repo.Rearm(ctx, first.ID); !rearmed {
+		t.Error("triggered alert was not re-armed")
+	}
+
+	if changed, _ := repo.Deactivate(ctx, []string{first.ID, third.ID, "missing"}); changed != 1 {
+		t.Errorf("deactivated %d alerts, want only the active one", changed)
+	}
+	if err := repo.Delete(ctx, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := repo.FindByID(ctx, second.ID); found != nil {
+		t.Errorf("deleted alert is still found: %+v", found)
+	}
+	if active, _ := repo.FindActive(ctx); len(active) != 0 {
+		t.Errorf("active alerts are %v, want none", alertIDs(active))
+	}
+	if after, _ := repo.FindAfter(ctx, "", 10); len(after) != 2 {
+		t.Errorf("%d alerts left, want 2", len(after))
+	}
+}
+
+func alertIDs(alerts []dto.AlertResponse) []string {
+	ids := make([]string, 0, len(alerts))
+	for _, alert := range alerts {
+		ids = append(ids, alert.ID)
+	}
+	return ids
+}
+
+// checkDistinctActiveSymbols seeds alerts in and out of their active window
+// and checks only the symbols of active alerts in it are returned, once each
+func checkDistinctActiveSymbols(t *testing.T, repo domain.AlertRepository) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Date(2024, 3, 4, 5, 0, 0, 0, time.UTC)
+	for _, alert := range []struct {
+		symbol      string
+		status      dto.AlertStatus
+		start, stop time.Time
+	}{
+		{symbol: "GP", status: dto.AlertStatusActive},
+		{symbol: "GP", status: dto.AlertStatusActive, start: now.Add(-time.Hour), stop: now.Add(time.Hour)},
+		{symbol: "BATBC", status: dto.AlertStatusActive, start: now.Add(-time.Hour)},
+		{symbol: "ACI", status: dto.AlertStatusActive, stop: now.Add(time.Hour)},
+		{symbol: "SQURPHARMA", status: dto.AlertStatusActive, start: now.Add(time.Hour)},
+		{symbol: "BEXIMCO", status: dto.AlertStatusActive, stop: now.Add(-time.Hour)},
+		{symbol: "RENATA", status: dto.AlertStatusInactive},
+		{symbol: "", status: dto.AlertStatusActive},
+	} {
+		if _, err := repo.Create(ctx, &dto.AlertCreateRequest{UserID: "alice", Symbol: alert.symbol, Rule: dto.AlertRuleAbove,
+			Price: money.FromFloat(100), Status: alert.status, StartDate: alert.start, StopDate: alert.stop}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	symbols, err := repo.DistinctActiveSymbols(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ACI", "BATBC", "GP"}; fmt.Sprint(symbols) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", symbols, want)
+	}
+}
+
+func TestMemoryAlertRepositoryDistinctActiveSymbols(t *testing.T) {
+	checkDistinctActiveSymbols(t, NewMemoryAlertRepository())
+}
+
+// The server-side filter gives the same symbols as the memory repository
+func TestMongoAlertRepositoryDistinctActiveSymbols(t *testing.T) {
+	checkDistinctActiveSymbols(t, NewMongoAlertRepository(mongoTestDatabase(t).Collection("alerts")))
+}
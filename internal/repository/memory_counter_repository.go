@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryDailyCounterRepository is an in-memory DailyCounterRepository for local development and tests
+type MemoryDailyCounterRepository struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func NewMemoryDailyCounterRepository() *MemoryDailyCounterRepository {
+	return &MemoryDailyCounterRepository{counts: make(map[string]int64)}
+}
+
+func (r *MemoryDailyCounterRepository) Increment(ctx context.Context, name, date string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[name+":"+date]++
+	return nil
+}
+
+func (r *MemoryDailyCounterRepository) Get(ctx context.Context, name, date string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[name+":"+date], nil
+}
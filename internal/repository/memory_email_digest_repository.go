@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// MemoryEmailDigestRepository is an in-memory EmailDigestRepository for local development and tests
+type MemoryEmailDigestRepository struct {
+	mu    sync.Mutex
+	items map[string]entity.EmailDigestItemEntity
+	// order keeps items in the order they were added
+	order []string
+}
+
+func NewMemoryEmailDigestRepository() *MemoryEmailDigestRepository {
+	return &MemoryEmailDigestRepository{items: make(map[string]entity.EmailDigestItemEntity)}
+}
+
+func (r *MemoryEmailDigestRepository) Add(ctx context.Context, item *dto.EmailDigestItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	held := newEmailDigestItemEntity(item)
+	if _, ok := r.items[held.ID]; ok {
+		return nil
+	}
+	r.items[held.ID] = held
+	r.order = append(r.order, held.ID)
+	return nil
+}
+
+func (r *MemoryEmailDigestRepository) DueGroups(ctx context.Context, before time.Time) ([]dto.EmailDigestGroup, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[dto.EmailDigestGroup]bool)
+	var groups []dto.EmailDigestGroup
+	for _, id := range r.order {
+		item := r.items[id]
+		if !item.WindowStart.Before(before) {
+			continue
+		}
+		group := dto.EmailDigestGroup{UserID: item.UserID, Email: item.Email, WindowStart: item.WindowStart}
+		if !seen[group] {
+			seen[group] = true
+			groups = append(groups, group)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].WindowStart.Before(groups[j].WindowStart) })
+	return groups, nil
+}
+
+func (r *MemoryEmailDigestRepository) Items(ctx context.Context, group dto.EmailDigestGroup) ([]dto.EmailDigestItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []dto.EmailDigestItem{}
+	for _, id := range r.order {
+		item := r.items[id]
+		if item.UserID == group.UserID && item.WindowStart.Equal(group.WindowStart) {
+			result = append(result, mapEmailDigestItemEntityToDTO(&item))
+		}
+	}
+	return result, nil
+}
+
+func (r *MemoryEmailDigestRepository) Remove(ctx context.Context, group dto.EmailDigestGroup) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.order[:0]
+	for _, id := range r.order {
+		item := r.items[id]
+		if item.UserID == group.UserID && item.WindowStart.Equal(group.WindowStart) {
+			delete(r.items, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	r.order = kept
+	return nil
+}
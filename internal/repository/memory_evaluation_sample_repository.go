@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// memoryEvaluationSampleCap bounds the stored samples like the capped collection does
+const memoryEvaluationSampleCap = 10000
+
+// MemoryEvaluationSampleRepository is an in-memory EvaluationSampleRepository for local development and tests
+type MemoryEvaluationSampleRepository struct {
+	mu       sync.Mutex
+	samples  []entity.EvaluationSampleEntity
+	sampling map[string]time.Time
+}
+
+func NewMemoryEvaluationSampleRepository() *MemoryEvaluationSampleRepository {
+	return &MemoryEvaluationSampleRepository{sampling: make(map[string]time.Time)}
+}
+
+func (r *MemoryEvaluationSampleRepository) SetSampling(ctx context.Context, alertID string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sampling[alertID] = expiresAt
+	return nil
+}
+
+func (r *MemoryEvaluationSampleRepository) ActiveSampling(ctx context.Context, now time.Time) (map[string]time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[string]time.Time, len(r.sampling))
+	for id, expiresAt := range r.sampling {
+		if !expiresAt.After(now) {
+			delete(r.sampling, id)
+			continue
+		}
+		result[id] = expiresAt
+	}
+	return result, nil
+}
+
+func (r *MemoryEvaluationSampleRepository) Insert(ctx context.Context, samples []dto.EvaluationSampleRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range samples {
+		r.samples = append(r.samples, newEvaluationSampleEntity(&samples[i]))
+	}
+	if over := len(r.samples) - memoryEvaluationSampleCap; over > 0 {
+		r.samples = append(r.samples[:0:0], r.samples[over:]...)
+	}
+	return nil
+}
+
+func (r *MemoryEvaluationSampleRepository) FindByAlert(ctx context.Context, alertID string, now time.Time, limit int64, shadowOnly bool) ([]dto.EvaluationSampleResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []dto.EvaluationSampleResponse{}
+	for i := len(r.samples) - 1; i >= 0; i-- {
+		sample := r.samples[i]
+		if sample.AlertID != alertID || !sample.ExpiresAt.After(now) || (shadowOnly && !sample.Shadow) {
+			continue
+		}
+		result = append(result, mapEvaluationSampleEntityToDTO(&sample))
+		if limit > 0 && int64(len(result)) >= limit {
+			break
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// MemoryFeatureFlagRepository is an in-memory FeatureFlagRepository for local development and tests
+type MemoryFeatureFlagRepository struct {
+	mu    sync.Mutex
+	flags map[string]entity.FeatureFlagEntity
+}
+
+func NewMemoryFeatureFlagRepository() *MemoryFeatureFlagRepository {
+	return &MemoryFeatureFlagRepository{flags: make(map[string]entity.FeatureFlagEntity)}
+}
+
+func (r *MemoryFeatureFlagRepository) Set(ctx context.Context, name, value string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.flags[name] = entity.FeatureFlagEntity{Name: name, Value: value, UpdatedAt: at}
+	return nil
+}
+
+func (r *MemoryFeatureFlagRepository) Delete(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.flags, name)
+	return nil
+}
+
+func (r *MemoryFeatureFlagRepository) FindAll(ctx context.Context) ([]dto.FeatureFlagOverride, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]dto.FeatureFlagOverride, 0, len(r.flags))
+	for _, flag := range r.flags {
+		result = append(result, dto.FeatureFlagOverride{Name: flag.Name, Value: flag.Value, UpdatedAt: flag.UpdatedAt})
+	}
+	return result, nil
+}
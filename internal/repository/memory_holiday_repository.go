@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// MemoryHolidayRepository is an in-memory HolidayRepository for local development and tests
+type MemoryHolidayRepository struct {
+	mu       sync.Mutex
+	holidays map[string]entity.HolidayEntity
+}
+
+func NewMemoryHolidayRepository() *MemoryHolidayRepository {
+	return &MemoryHolidayRepository{holidays: make(map[string]entity.HolidayEntity)}
+}
+
+func (r *MemoryHolidayRepository) FindAll(ctx context.Context) ([]dto.HolidayResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]dto.HolidayResponse, 0, len(r.holidays))
+	for _, holiday := range r.holidays {
+		result = append(result, *mapHolidayEntityToDTO(&holiday))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Date < result[j].Date })
+	return result, nil
+}
+
+func (r *MemoryHolidayRepository) Add(ctx context.Context, req *dto.HolidayRequest) (*dto.HolidayResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	holiday, ok := r.holidays[req.Date]
+	if !ok {
+		holiday = entity.HolidayEntity{Date: req.Date, CreatedAt: time.Now().UTC()}
+	}
+	holiday.Name = req.Name
+	r.holidays[req.Date] = holiday
+	return mapHolidayEntityToDTO(&holiday), nil
+}
+
+func (r *MemoryHolidayRepository) Delete(ctx context.Context, date string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.holidays[date]; !ok {
+		return domain.ErrHolidayNotFound
+	}
+	delete(r.holidays, date)
+	return nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// MemoryNotificationRepository is an in-memory NotificationRepository for local
+// development and tests. Claims are atomic within the process only.
+type MemoryNotificationRepository struct {
+	mu            sync.Mutex
+	notifications map[string]entity.NotificationEntity
+	order         []string
+}
+
+func NewMemoryNotificationRepository() *MemoryNotificationRepository {
+	return &MemoryNotificationRepository{
+		notifications: make(map[string]entity.NotificationEntity),
+	}
+}
+
+func (r *MemoryNotificationRepository) Enqueue(ctx context.Context, req *dto.NotificationEnqueueRequest) (*dto.NotificationResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	notification := newNotificationEntity(req, time.Now().UTC())
+	for _, id := range r.order {
+		existing := r.notifications[id]
+		if existing.AlertID == req.AlertID && existing.TriggerID == req.TriggerID && existing.Channel == notification.Channel {
+			return mapNotificationEntityToDTO(&existing), nil
+		}
+	}
+	r.notifications[notification.ID] = notification
+	r.order = append(r.order, notification.ID)
+	return mapNotificationEntityToDTO(&notification), nil
+}
+
+func (r *MemoryNotificationRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*dto.NotificationResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due *entity.NotificationEntity
+	for _, id := range r.order {
+		n := r.notifications[id]
+		claimable := (n.Status == entity.NotificationStatusPending && !n.NextAttemptAt.After(now)) ||
+			(n.Status == entity.NotificationStatusInFlight && !n.LockedUntil.After(now))
+		if claimable && (due == nil || n.NextAttemptAt.Before(due.NextAttemptAt)) {
+			due = &n
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+	due.Status = entity.NotificationStatusInFlight
+	due.LockedUntil = now.Add(lease)
+	due.Attempts++
+	due.UpdatedAt = now
+	r.notifications[due.ID] = *due
+	return mapNotificationEntityToDTO(due), nil
+}
+
+func (r *MemoryNotificationRepository) MarkDelivered(ctx context.Context, id string, attempt int, at time.Time) error {
+	r.finishAttempt(id, attempt, func(n *entity.NotificationEntity) {
+		n.Status = entity.NotificationStatusDelivered
+		n.DeliveredAt = &at
+		n.LastError = ""
+	})
+	return nil
+}
+
+func (r *MemoryNotificationRepository) MarkRetry(ctx context.Context, id string, attempt int, next time.Time, lastErr string) error {
+	r.finishAttempt(id, attempt, func(n *entity.NotificationEntity) {
+		n.Status = entity.NotificationStatusPending
+		n.NextAttemptAt = next
+		n.LastError = lastErr
+	})
+	return nil
+}
+
+func (r *MemoryNotificationRepository) MarkFailed(ctx context.Context, id string, attempt int, lastErr string) error {
+	r.finishAttempt(id, attempt, func(n *entity.NotificationEntity) {
+		n.Status = entity.NotificationStatusFailed
+		n.LastError = lastErr
+	})
+	return nil
+}
+
+// finishAttempt applies an outcome to a delivery still in flight at the claimed attempt
+func (r *MemoryNotificationRepository) finishAttempt(id string, attempt int, apply func(*entity.NotificationEntity)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n, ok := r.notifications[id]
+	if !ok || n.Status != entity.NotificationStatusInFlight || n.Attempts != attempt {
+		return
+	}
+	apply(&n)
+	n.LockedUntil = time.Time{}
+	n.UpdatedAt = time.Now().UTC()
+	r.notifications[id] = n
+}
+
+func (r *MemoryNotificationRepository) FindByAlert(ctx context.Context, alertID string) ([]dto.NotificationResponse, error) {
+	return r.findNewestFirst(func(n entity.NotificationEntity) bool { return n.AlertID == alertID }, 0), nil
+}
+
+func (r *MemoryNotificationRepository) FindByStatus(ctx context.Context, status dto.NotificationStatus, limit int64) ([]dto.NotificationResponse, error) {
+	return r.findNewestFirst(func(n entity.NotificationEntity) bool {
+		return n.Status == entity.NotificationStatus(status)
+	}, limit), nil
+}
+
+func (r *MemoryNotificationRepository) findNewestFirst(match func(entity.NotificationEntity) bool, limit int64) []dto.NotificationResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []dto.NotificationResponse{}
+	for i := len(r.order) - 1; i >= 0; i-- {
+		n := r.notifications[r.order[i]]
+		if !match(n) {
+			continue
+		}
+		result = append(result, *mapNotificationEntityToDTO(&n))
+		if limit > 0 && int64(len(result)) >= limit {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+)
+
+// A worker whose lease expired cannot record its outcome over the claim of the
+// worker that took the delivery over
+func TestMemoryNotificationRepositoryClaim(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryNotificationRepository()
+	queued, err := repo.Enqueue(ctx, &dto.NotificationEnqueueRequest{AlertID: "alert-1", TriggerID: "t1", Destination: "https://example.com/hook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	const lease = time.Minute
+	slow, _ := repo.ClaimDue(ctx, now, lease)
+	if slow == nil || slow.ID != queued.ID || slow.Attempts != 1 {
+		t.Fatalf("first claim got %+v, want attempt 1 of %s", slow, queued.ID)
+	}
+	if again, _ := repo.ClaimDue(ctx, now.Add(lease/2), lease); again != nil {
+		t.Fatalf("claimed %s again within its lease", again.ID)
+	}
+
+	// The lease expires and another worker claims the delivery
+	taken, _ := repo.ClaimDue(ctx, now.Add(lease), lease)
+	if taken == nil || taken.Attempts != 2 {
+		t.Fatalf("second claim got %+v, want attempt 2", taken)
+	}
+	if err := repo.MarkFailed(ctx, slow.ID, slow.Attempts, "timed out"); err != nil {
+		t.Fatal(err)
+	}
+	if inFlight, _ := repo.FindByStatus(ctx, dto.NotificationStatusInFlight, 0); len(inFlight) != 1 {
+		t.Fatalf("the outcome of the expired claim was recorded over the new claim")
+	}
+
+	if err := repo.MarkDelivered(ctx, taken.ID, taken.Attempts, now.Add(lease)); err != nil {
+		t.Fatal(err)
+	}
+	delivered, _ := repo.FindByStatus(ctx, dto.NotificationStatusDelivered, 0)
+	if len(delivered) != 1 || delivered[0].LastError != "" || delivered[0].Attempts != 2 {
+		t.Errorf("got %+v, want the delivery delivered on attempt 2", delivered)
+	}
+	// Nor can a late outcome overwrite a delivered row
+	repo.MarkRetry(ctx, taken.ID, taken.Attempts, now.Add(2*lease), "late")
+	if delivered, _ := repo.FindByStatus(ctx, dto.NotificationStatusDelivered, 0); len(delivered) != 1 {
+		t.Error("a late retry overwrote the delivered row")
+	}
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// MemoryNotificationThrottleRepository is an in-memory NotificationThrottleRepository
+// for local development and tests
+type MemoryNotificationThrottleRepository struct {
+	mu      sync.Mutex
+	windows map[string]entity.NotificationThrottleEntity
+}
+
+func NewMemoryNotificationThrottleRepository() *MemoryNotificationThrottleRepository {
+	return &MemoryNotificationThrottleRepository{windows: make(map[string]entity.NotificationThrottleEntity)}
+}
+
+func (r *MemoryNotificationThrottleRepository) Hit(ctx context.Context, window *dto.NotificationThrottleWindow) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := notificationThrottleID(window)
+	counted, ok := r.windows[id]
+	if !ok {
+		counted = entity.NotificationThrottleEntity{
+			ID:          id,
+			UserID:      window.UserID,
+			Channel:     string(window.Channel),
+			WindowStart: window.WindowStart,
+			WindowEnd:   window.WindowEnd,
+		}
+	}
+	counted.Destination = window.Destination
+	counted.Count++
+	r.windows[id] = counted
+	return counted.Count, nil
+}
+
+func (r *MemoryNotificationThrottleRepository) Ended(ctx context.Context, before time.Time) ([]dto.NotificationThrottleWindow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	windows := []dto.NotificationThrottleWindow{}
+	for _, counted := range r.windows {
+		if !counted.WindowEnd.After(before) {
+			windows = append(windows, mapNotificationThrottleEntityToDTO(&counted))
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].WindowEnd.Before(windows[j].WindowEnd) })
+	return windows, nil
+}
+
+func (r *MemoryNotificationThrottleRepository) Remove(ctx context.Context, window dto.NotificationThrottleWindow) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.windows, notificationThrottleID(&window))
+	return nil
+}
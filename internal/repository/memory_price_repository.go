@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// MemoryPriceRepository is an in-memory PriceRepository for local development and
+// tests. It keeps the ticks and the latest price and day statistics of each symbol.
+type MemoryPriceRepository struct {
+	mu     sync.Mutex
+	ticks  map[string][]entity.PriceTickEntity
+	latest map[string]entity.LatestPriceEntity
+}
+
+func NewMemoryPriceRepository() *MemoryPriceRepository {
+	return &MemoryPriceRepository{
+		ticks:  make(map[string][]entity.PriceTickEntity),
+		latest: make(map[string]entity.LatestPriceEntity),
+	}
+}
+
+func (r *MemoryPriceRepository) Insert(ctx context.Context, req *dto.PriceTickRequest, tradingDate string) (*dto.PriceTickResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	tick := newPriceTickEntity(req, now)
+	r.ticks[tick.Symbol] = append(r.ticks[tick.Symbol], tick)
+	result := mapPriceTickEntityToDTO(&tick)
+	var current *entity.LatestPriceEntity
+	if latest, ok := r.latest[tick.Symbol]; ok {
+		current = &latest
+	}
+	if current != nil && current.TradingDate > tradingDate {
+		return result, nil
+	}
+	rolled := rollLatestPrice(current, tick, tradingDate, now)
+	r.latest[tick.Symbol] = rolled
+	result.Latest = mapLatestPriceEntityToDTO(&rolled)
+	return result, nil
+}
+
+func (r *MemoryPriceRepository) InsertIfAbsent(ctx context.Context, req *dto.PriceTickRequest) (*dto.PriceTickResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.ticks[req.Symbol] {
+		if stored.Time.Equal(req.Time) && stored.Price == req.Price && stored.Volume == req.Volume {
+			return nil, nil
+		}
+	}
+	tick := newPriceTickEntity(req, time.Now().UTC())
+	r.ticks[tick.Symbol] = append(r.ticks[tick.Symbol], tick)
+	return mapPriceTickEntityToDTO(&tick), nil
+}
+
+func (r *MemoryPriceRepository) RecomputeDay(ctx context.Context, symbol, tradingDate string, from, to time.Time) (*dto.LatestPriceResponse, error) {
+	ticks := r.ticksBetween(symbol, from, to)
+	if len(ticks) == 0 {
+		return nil, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var current *entity.LatestPriceEntity
+	if latest, ok := r.latest[symbol]; ok {
+		current = &latest
+	}
+	day, ok := recomputeDay(current, ticks, tradingDate, time.Now().UTC())
+	if !ok {
+		return nil, nil
+	}
+	r.latest[symbol] = day
+	return mapLatestPriceEntityToDTO(&day), nil
+}
+
+func (r *MemoryPriceRepository) Latest(ctx context.Context, symbol string) (*dto.LatestPriceResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	latest, ok := r.latest[symbol]
+	if !ok {
+		return nil, nil
+	}
+	return mapLatestPriceEntityToDTO(&latest), nil
+}
+
+func (r *MemoryPriceRepository) LatestForSymbols(ctx context.Context, symbols []string) (map[string]dto.LatestPriceResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[string]dto.LatestPriceResponse, len(symbols))
+	for _, symbol := range symbols {
+		if latest, ok := r.latest[symbol]; ok {
+			result[symbol] = *mapLatestPriceEntityToDTO(&latest)
+		}
+	}
+	return result, nil
+}
+
+func (r *MemoryPriceRepository) MostRecent(ctx context.Context) (*dto.LatestPriceResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var recent *entity.LatestPriceEntity
+	for _, latest := range r.latest {
+		if recent == nil || latest.UpdatedAt.After(recent.UpdatedAt) {
+			found := latest
+			recent = &found
+		}
+	}
+	if recent == nil {
+		return nil, nil
+	}
+	return mapLatestPriceEntityToDTO(recent), nil
+}
+
+func (r *MemoryPriceRepository) UpdatedSince(ctx context.Context, since time.Time) ([]dto.LatestPriceResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []dto.LatestPriceResponse{}
+	for _, latest := range r.latest {
+		if !latest.UpdatedAt.Before(since) {
+			result = append(result, *mapLatestPriceEntityToDTO(&latest))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Symbol < result[j].Symbol })
+	return result, nil
+}
+
+func (r *MemoryPriceRepository) CountTicks(ctx context.Context, symbol string, from, to time.Time) (int64, error) {
+	return int64(len(r.ticksBetween(symbol, from, to))), nil
+}
+
+func (r *MemoryPriceRepository) ReplayTicks(ctx context.Context, symbol string, from, to time.Time, tradingDate func(time.Time) string, fn func(dto.PriceTickResponse) error) error {
+	var latest *entity.LatestPriceEntity
+	for _, tick := range r.ticksBetween(symbol, from, to) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		if latest, err = replayTick(latest, tick, tradingDate, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ticksBetween copies the ticks of a symbol with from <= time < to, in time order
+func (r *MemoryPriceRepository) ticksBetween(symbol string, from, to time.Time) []entity.PriceTickEntity {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ticks []entity.PriceTickEntity
+	for _, tick := range r.ticks[symbol] {
+		if !tick.Time.Before(from) && tick.Time.Before(to) {
+			ticks = append(ticks, tick)
+		}
+	}
+	sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].Time.Before(ticks[j].Time) })
+	return ticks
+}
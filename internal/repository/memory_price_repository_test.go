@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/money"
+)
+
+// The day statistics roll over on the first tick of a new trading date, here
+// across the Dhaka midnight (18:00 UTC)
+func TestLatestPriceRollover(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryPriceRepository()
+	insert := func(price float64, volume float64, at time.Time, tradingDate string) {
+		t.Helper()
+		req := &dto.PriceTickRequest{Symbol: "GP", Price: money.FromFloat(price), Volume: volume, Time: at}
+		if _, err := repo.Insert(ctx, req, tradingDate); err != nil {
+			t.Fatal(err)
+		}
+	}
+	day := time.Date(2024, 3, 4, 4, 0, 0, 0, time.UTC)
+
+	insert(352, 10, day.Add(time.Hour), "2024-03-04")
+	// Out of order: an earlier tick becomes the open and the low, not the price
+	insert(348, 5, day, "2024-03-04")
+	insert(355, 20, day.Add(2*time.Hour), "2024-03-04")
+	latest, _ := repo.Latest(ctx, "GP")
+	want := dto.LatestPriceResponse{Price: money.FromFloat(355), TradingDate: "2024-03-04",
+		DayOpen: money.FromFloat(348), DayHigh: money.FromFloat(355), DayLow: money.FromFloat(348), Volume: 35}
+	if latest.Price != want.Price || latest.DayOpen != want.DayOpen || latest.DayHigh != want.DayHigh ||
+		latest.DayLow != want.DayLow || latest.Volume != want.Volume || latest.PreviousClose != nil {
+		t.Fatalf("first day is %+v, want %+v", latest, want)
+	}
+
+	// 18:30 UTC is already the next trading date in Dhaka
+	next := time.Date(2024, 3, 4, 18, 30, 0, 0, time.UTC)
+	insert(360, 7, next, "2024-03-05")
+	// A late tick of the previous date changes nothing
+	insert(300, 100, day.Add(3*time.Hour), "2024-03-04")
+	latest, _ = repo.Latest(ctx, "GP")
+	if latest.TradingDate != "2024-03-05" || latest.Price != money.FromFloat(360) || latest.DayOpen != money.FromFloat(360) ||
+		latest.DayHigh != money.FromFloat(360) || latest.DayLow != money.FromFloat(360) || latest.Volume != 7 {
+		t.Errorf("next day is %+v, want the day restarted from the 360 tick", latest)
+	}
+	if latest.PreviousClose == nil || *latest.PreviousClose != money.FromFloat(355) {
+		t.Errorf("previous close is %v, want 355", latest.PreviousClose)
+	}
+}
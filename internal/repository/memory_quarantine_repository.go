@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// MemoryQuarantineRepository is an in-memory QuarantineRepository for local development and tests
+type MemoryQuarantineRepository struct {
+	mu    sync.Mutex
+	ticks map[string]entity.QuarantinedTickEntity
+	order []string
+}
+
+func NewMemoryQuarantineRepository() *MemoryQuarantineRepository {
+	return &MemoryQuarantineRepository{ticks: make(map[string]entity.QuarantinedTickEntity)}
+}
+
+func (r *MemoryQuarantineRepository) Add(ctx context.Context, req *dto.QuarantineRequest) (*dto.QuarantinedTickResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tick := newQuarantinedTickEntity(req, time.Now().UTC())
+	r.ticks[tick.ID] = tick
+	r.order = append(r.order, tick.ID)
+	return mapQuarantinedTickEntityToDTO(&tick), nil
+}
+
+func (r *MemoryQuarantineRepository) FindByID(ctx context.Context, id string) (*dto.QuarantinedTickResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tick, ok := r.ticks[id]
+	if !ok {
+		return nil, nil
+	}
+	return mapQuarantinedTickEntityToDTO(&tick), nil
+}
+
+func (r *MemoryQuarantineRepository) FindByStatus(ctx context.Context, status dto.QuarantineStatus, limit int64) ([]dto.QuarantinedTickResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := []dto.QuarantinedTickResponse{}
+	for i := len(r.order) - 1; i >= 0; i-- {
+		tick := r.ticks[r.order[i]]
+		if tick.Status != entity.QuarantineStatus(status) {
+			continue
+		}
+		result = append(result, *mapQuarantinedTickEntityToDTO(&tick))
+		if limit > 0 && int64(len(result)) >= limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (r *MemoryQuarantineRepository) MarkReleased(ctx context.Context, id string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tick, ok := r.ticks[id]
+	if !ok || tick.Status != entity.QuarantineStatusHeld {
+		return false, nil
+	}
+	tick.Status = entity.QuarantineStatusReleased
+	tick.ReleasedAt = &at
+	r.ticks[id] = tick
+	return true, nil
+}
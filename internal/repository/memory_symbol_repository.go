@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// MemorySymbolRepository is an in-memory SymbolRepository for local development and tests
+type MemorySymbolRepository struct {
+	mu      sync.Mutex
+	symbols map[string]entity.SymbolEntity
+}
+
+func NewMemorySymbolRepository() *MemorySymbolRepository {
+	return &MemorySymbolRepository{symbols: make(map[string]entity.SymbolEntity)}
+}
+
+func (r *MemorySymbolRepository) Upsert(ctx context.Context, symbols []dto.SymbolRequest, seenAt *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, req := range symbols {
+		symbol, ok := r.symbols[req.Symbol]
+		if !ok {
+			symbol = entity.SymbolEntity{Symbol: req.Symbol, CreatedAt: now}
+		}
+		if req.Name != "" {
+			symbol.Name = req.Name
+			symbol.NameKey = strings.ToUpper(req.Name)
+		}
+		if req.Sector != "" {
+			symbol.Sector = req.Sector
+		}
+		if seenAt != nil && (symbol.LastSeenAt == nil || seenAt.After(*symbol.LastSeenAt)) {
+			seen := seenAt.UTC()
+			symbol.LastSeenAt = &seen
+		}
+		symbol.UpdatedAt = now
+		r.symbols[req.Symbol] = symbol
+	}
+	return nil
+}
+
+func (r *MemorySymbolRepository) Exists(ctx context.Context, symbol string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.symbols[symbol]
+	return ok, nil
+}
+
+func (r *MemorySymbolRepository) Search(ctx context.Context, prefix string, limit int64) ([]dto.SymbolResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]dto.SymbolResponse, 0)
+	for _, symbol := range r.symbols {
+		if strings.HasPrefix(symbol.Symbol, prefix) || strings.HasPrefix(symbol.NameKey, prefix) {
+			result = append(result, *mapSymbolEntityToDTO(&symbol))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Symbol < result[j].Symbol })
+	if limit > 0 && int64(len(result)) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
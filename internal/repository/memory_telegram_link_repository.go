@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// MemoryTelegramLinkRepository is an in-memory TelegramLinkRepository for local development and tests
+type MemoryTelegramLinkRepository struct {
+	mu    sync.Mutex
+	codes map[string]entity.TelegramLinkCodeEntity
+}
+
+func NewMemoryTelegramLinkRepository() *MemoryTelegramLinkRepository {
+	return &MemoryTelegramLinkRepository{codes: make(map[string]entity.TelegramLinkCodeEntity)}
+}
+
+func (r *MemoryTelegramLinkRepository) Create(ctx context.Context, code, userID string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	// Expired codes are dropped here, as the TTL index does on MongoDB
+	for existing, link := range r.codes {
+		if !link.ExpiresAt.After(now) {
+			delete(r.codes, existing)
+		}
+	}
+	r.codes[code] = entity.TelegramLinkCodeEntity{Code: code, UserID: userID, ExpiresAt: expiresAt, CreatedAt: now}
+	return nil
+}
+
+func (r *MemoryTelegramLinkRepository) Consume(ctx context.Context, code string, now time.Time) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	link, ok := r.codes[code]
+	if !ok {
+		return "", nil
+	}
+	delete(r.codes, code)
+	if !link.ExpiresAt.After(now) {
+		return "", nil
+	}
+	return link.UserID, nil
+}
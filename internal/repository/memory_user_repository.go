@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryUserRepository is an in-memory UserRepository for local development and tests.
+// Entities are copied on every read and write so callers never share state with the store.
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[primitive.ObjectID]entity.UserEntity
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users: make(map[primitive.ObjectID]entity.UserEntity),
+	}
+}
+
+// FindAll retrieves one page of user entities sorted by ID, like the Mongo
+// repository, and the total number of users
+func (r *MemoryUserRepository) FindAll(ctx context.Context, page, pageSize int64) ([]entity.UserEntity, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	userEntities := make([]entity.UserEntity, 0, len(r.users))
+	for _, user := range r.users {
+		userEntities = append(userEntities, user)
+	}
+	sort.Slice(userEntities, func(i, j int) bool {
+		return userEntities[i].ID.Hex() < userEntities[j].ID.Hex()
+	})
+	total := int64(len(userEntities))
+	start := min((page-1)*pageSize, total)
+	end := min(start+pageSize, total)
+	return userEntities[start:end], total, nil
+}
+
+// FindByObjectID retrieves a user entity by ObjectID hex string
+func (r *MemoryUserRepository) FindByObjectID(ctx context.Context, id string) (*entity.UserEntity, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[objID]
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+// FindByUserID retrieves a user entity by userId
+func (r *MemoryUserRepository) FindByUserID(ctx context.Context, userID string) (*entity.UserEntity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.UserID == userID {
+			found := user
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+// FindByEmail retrieves a user entity by email
+func (r *MemoryUserRepository) FindByEmail(ctx context.Context, email string) (*entity.UserEntity, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			found := user
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+// ExistingUserIDs returns which of the userIds belong to a user
+func (r *MemoryUserRepository) ExistingUserIDs(ctx context.Context, userIDs []string) (map[string]bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		wanted[userID] = true
+	}
+	existing := make(map[string]bool)
+	for _, user := range r.users {
+		if wanted[user.UserID] {
+			existing[user.UserID] = true
+		}
+	}
+	return existing, nil
+}
+
+// SetTelegramChat links a Telegram chat to the user with the userId, or unlinks it when chatID is 0
+func (r *MemoryUserRepository) SetTelegramChat(ctx context.Context, userID string, chatID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, user := range r.users {
+		if user.UserID == userID {
+			user.TelegramChatID = chatID
+			user.UpdatedAt = time.Now().UTC()
+			r.users[id] = user
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+// Create inserts a new user entity
+func (r *MemoryUserRepository) Create(ctx context.Context, userEntity *entity.UserEntity) (*entity.UserEntity, error) {
+	userEntity.CreatedAt = time.Now().UTC()
+	userEntity.UpdatedAt = time.Now().UTC()
+	userEntity.ID = primitive.NewObjectID()
+
+	r.mu.Lock()
+	r.users[userEntity.ID] = *userEntity
+	r.mu.Unlock()
+
+	return userEntity, nil
+}
+
+// Update replaces an existing user entity, preserving its ID and creation date
+func (r *MemoryUserRepository) Update(ctx context.Context, userEntity *entity.UserEntity) (*entity.UserEntity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var existing *entity.UserEntity
+	for _, user := range r.users {
+		if user.UserID == userEntity.UserID {
+			found := user
+			existing = &found
+			break
+		}
+	}
+	if existing == nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	userEntity.CreatedAt = existing.CreatedAt
+	userEntity.ID = existing.ID
+	userEntity.UpdatedAt = time.Now().UTC()
+	r.users[userEntity.ID] = *userEntity
+
+	return userEntity, nil
+}
+
+// DeleteByObjectID removes a user entity by ObjectID hex string
+func (r *MemoryUserRepository) DeleteByObjectID(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[objID]; !ok {
+		return domain.ErrUserNotFound
+	}
+	delete(r.users, objID)
+	return nil
+}
+
+// Count returns the total number of users
+func (r *MemoryUserRepository) Count(ctx context.Context) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return int64(len(r.users)), nil
+}
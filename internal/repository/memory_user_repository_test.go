@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// seedUsers creates n users with userIds user-0 and up
+func seedUsers(t *testing.T, repo *MemoryUserRepository, n int) []*entity.UserEntity {
+	t.Helper()
+	users := make([]*entity.UserEntity, 0, n)
+	for i := 0; i < n; i++ {
+		created, err := repo.Create(context.Background(), &entity.UserEntity{
+			UserID: fmt.Sprintf("user-%d", i),
+			Name:   fmt.Sprintf("User %d", i),
+			Email:  fmt.Sprintf("user%d@example.com", i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, created)
+	}
+	return users
+}
+
+func TestMemoryUserRepositoryCRUD(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryUserRepository()
+	created := seedUsers(t, repo, 1)[0]
+	if created.ID.IsZero() || created.CreatedAt.IsZero() {
+		t.Fatalf("created user has no ID or creation date: %+v", created)
+	}
+
+	for name, find := range map[string]func() (*entity.UserEntity, error){
+		"object id": func() (*entity.UserEntity, error) { return repo.FindByObjectID(ctx, created.ID.Hex()) },
+		"user id":   func() (*entity.UserEntity, error) { return repo.FindByUserID(ctx, "user-0") },
+		"email":     func() (*entity.UserEntity, error) { return repo.FindByEmail(ctx, "user0@example.com") },
+	} {
+		found, err := find()
+		if err != nil || found == nil || found.ID != created.ID {
+			t.Errorf("find by %s returned %+v (%v), want the created user", name, found, err)
+		}
+	}
+
+	update := *created
+	update.Name = "Renamed"
+	if _, err := repo.Update(ctx, &update); err != nil {
+		t.Fatal(err)
+	}
+	found, _ := repo.FindByUserID(ctx, "user-0")
+	if found.Name != "Renamed" || !found.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("updated user is %+v, want the new name and the original creation date", found)
+	}
+	if _, err := repo.Update(ctx, &entity.UserEntity{UserID: "nobody"}); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("updating an unknown user returned %v, want ErrUserNotFound", err)
+	}
+
+	if err := repo.DeleteByObjectID(ctx, created.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := repo.FindByObjectID(ctx, created.ID.Hex()); found != nil || err != nil {
+		t.Errorf("deleted user is still found: %+v (%v)", found, err)
+	}
+	if err := repo.DeleteByObjectID(ctx, created.ID.Hex()); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("deleting twice returned %v, want ErrUserNotFound", err)
+	}
+	if count, _ := repo.Count(ctx); count != 0 {
+		t.Errorf("count after delete is %d, want 0", count)
+	}
+}
+
+// Entities read from or written to the repository are copies
+func TestMemoryUserRepositoryCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryUserRepository()
+	created := seedUsers(t, repo, 1)[0]
+	created.Name = "changed after create"
+
+	found, _ := repo.FindByObjectID(ctx, created.ID.Hex())
+	found.Email = "changed@example.com"
+	again, _ := repo.FindByObjectID(ctx, created.ID.Hex())
+	if again.Name != "User 0" || again.Email != "user0@example.com" {
+		t.Errorf("stored user is %+v, want it unchanged by callers", again)
+	}
+}
+
+func TestMemoryUserRepositoryFindAll(t *testing.T) {
+	repo := NewMemoryUserRepository()
+	seedUsers(t, repo, 5)
+
+	seen := make(map[string]bool)
+	for _, tc := range []struct {
+		page, pageSize int64
+		want           int
+	}{
+		{page: 1, pageSize: 2, want: 2},
+		{page: 2, pageSize: 2, want: 2},
+		{page: 3, pageSize: 2, want: 1},
+		{page: 4, pageSize: 2, want: 0},
+		{page: 1, pageSize: 10, want: 5},
+	} {
+		users, total, err := repo.FindAll(context.Background(), tc.page, tc.pageSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != tc.want || total != 5 {
+			t.Errorf("page %d of %d holds %d users of %d, want %d of 5", tc.page, tc.pageSize, len(users), total, tc.want)
+		}
+		for i := 1; i < len(users); i++ {
+			if users[i-1].ID.Hex() >= users[i].ID.Hex() {
+				t.Errorf("page %d of %d is not in ID order", tc.page, tc.pageSize)
+			}
+		}
+		if tc.pageSize == 2 {
+			for _, user := range users {
+				if seen[user.UserID] {
+					t.Errorf("%s is on more than one page", user.UserID)
+				}
+				seen[user.UserID] = true
+			}
+		}
+	}
+	if len(seen) != 5 {
+		t.Errorf("pages of 2 held %d distinct users, want 5", len(seen))
+	}
+}
+
+func TestMemoryUserRepositoryCount(t *testing.T) {
+	repo := NewMemoryUserRepository()
+	if count, err := repo.Count(context.Background()); err != nil || count != 0 {
+		t.Errorf("empty repository counts %d (%v), want 0", count, err)
+	}
+	seedUsers(t, repo, 7)
+	if count, err := repo.Count(context.Background()); err != nil || count != 7 {
+		t.Errorf("counted %d users (%v), want 7", count, err)
+	}
+}
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoNotificationRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoNotificationRepository(collection *mongo.Collection) *MongoNotificationRepository {
+	return &MongoNotificationRepository{collection: collection}
+}
+
+func (r *MongoNotificationRepository) Enqueue(ctx context.Context, req *dto.NotificationEnqueueRequest) (*dto.NotificationResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "Enqueue")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	notification := newNotificationEntity(req, time.Now().UTC())
+	_, err := r.collection.InsertOne(ctx, notification)
+	if mongo.IsDuplicateKeyError(err) {
+		// The trigger is already queued
+		var existing entity.NotificationEntity
+		filter := bson.M{"alertId": req.AlertID, "triggerId": req.TriggerID, "channel": notification.Channel}
+		if err := r.collection.FindOne(ctx, filter).Decode(&existing); err != nil {
+			return nil, err
+		}
+		return mapNotificationEntityToDTO(&existing), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapNotificationEntityToDTO(&notification), nil
+}
+
+// ClaimDue uses a single findOneAndUpdate so that concurrent workers on several
+// API replicas never claim the same delivery
+func (r *MongoNotificationRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*dto.NotificationResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "ClaimDue")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": entity.NotificationStatusPending, "nextAttemptAt": bson.M{"$lte": now}},
+		bson.M{"status": entity.NotificationStatusInFlight, "lockedUntil": bson.M{"$lte": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"status":      entity.NotificationStatusInFlight,
+			"lockedUntil": now.Add(lease),
+			"updated_at":  now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var notification entity.NotificationEntity
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&notification)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil // Nothing due
+		}
+		return nil, err
+	}
+	return mapNotificationEntityToDTO(&notification), nil
+}
+
+func (r *MongoNotificationRepository) MarkDelivered(ctx context.Context, id string, attempt int, at time.Time) error {
+	return r.finishAttempt(ctx, "MarkDelivered", id, attempt, bson.M{
+		"status":      entity.NotificationStatusDelivered,
+		"deliveredAt": at,
+		"lastError":   "",
+	})
+}
+
+func (r *MongoNotificationRepository) MarkRetry(ctx context.Context, id string, attempt int, next time.Time, lastErr string) error {
+	return r.finishAttempt(ctx, "MarkRetry", id, attempt, bson.M{
+		"status":        entity.NotificationStatusPending,
+		"nextAttemptAt": next,
+		"lastError":     lastErr,
+	})
+}
+
+func (r *MongoNotificationRepository) MarkFailed(ctx context.Context, id string, attempt int, lastErr string) error {
+	return r.finishAttempt(ctx, "MarkFailed", id, attempt, bson.M{
+		"status":    entity.NotificationStatusFailed,
+		"lastError": lastErr,
+	})
+}
+
+// finishAttempt records the outcome of a claimed delivery. Only in-flight rows
+// still at the claimed attempt are updated, so a late outcome cannot overwrite
+// a delivered or failed row, nor the claim of a worker that took over after the
+// lease expired; ClaimDue counts every claim, so the attempt identifies it.
+func (r *MongoNotificationRepository) finishAttempt(ctx context.Context, operation, id string, attempt int, set bson.M) error {
+	ctx, span := startSpan(ctx, r.collection, operation)
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	set["updated_at"] = time.Now().UTC()
+	filter := bson.M{"_id": id, "status": entity.NotificationStatusInFlight, "attempts": attempt}
+	update := bson.M{"$set": set, "$unset": bson.M{"lockedUntil": ""}}
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *MongoNotificationRepository) FindByAlert(ctx context.Context, alertID string) ([]dto.NotificationResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindByAlert")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return r.find(ctx, bson.M{"alertId": alertID}, opts)
+}
+
+func (r *MongoNotificationRepository) FindByStatus(ctx context.Context, status dto.NotificationStatus, limit int64) ([]dto.NotificationResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindByStatus")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	return r.find(ctx, bson.M{"status": status}, opts)
+}
+
+func (r *MongoNotificationRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]dto.NotificationResponse, error) {
+	var notifications []entity.NotificationEntity
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	result := make([]dto.NotificationResponse, 0, len(notifications))
+	for _, notification := range notifications {
+		result = append(result, *mapNotificationEntityToDTO(&notification))
+	}
+	return result, nil
+}
+
+func newNotificationEntity(req *dto.NotificationEnqueueRequest, now time.Time) entity.NotificationEntity {
+	channel := req.Channel
+	if channel == "" {
+		channel = dto.NotificationChannelWebhook
+	}
+	next := now
+	if req.NotBefore.After(now) {
+		next = req.NotBefore
+	}
+	return entity.NotificationEntity{
+		ID:            primitive.NewObjectID().Hex(),
+		AlertID:       req.AlertID,
+		TriggerID:     req.TriggerID,
+		Channel:       string(channel),
+		Destination:   req.Destination,
+		Payload:       string(req.Payload),
+		Status:        entity.NotificationStatusPending,
+		NextAttemptAt: next,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+func mapNotificationEntityToDTO(notification *entity.NotificationEntity) *dto.NotificationResponse {
+	// Rows queued before channels existed are webhooks
+	channel := dto.NotificationChannel(notification.Channel)
+	if channel == "" {
+		channel = dto.NotificationChannelWebhook
+	}
+	return &dto.NotificationResponse{
+		ID:            notification.ID,
+		AlertID:       notification.AlertID,
+		TriggerID:     notification.TriggerID,
+		Channel:       channel,
+		Destination:   notification.Destination,
+		Payload:       json.RawMessage(notification.Payload),
+		Status:        dto.NotificationStatus(notification.Status),
+		Attempts:      notification.Attempts,
+		NextAttemptAt: notification.NextAttemptAt,
+		LastError:     notification.LastError,
+		DeliveredAt:   notification.DeliveredAt,
+		CreatedAt:     notification.CreatedAt,
+		UpdatedAt:     notification.UpdatedAt,
+	}
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoNotificationThrottleRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoNotificationThrottleRepository(collection *mongo.Collection) *MongoNotificationThrottleRepository {
+	return &MongoNotificationThrottleRepository{collection: collection}
+}
+
+// Hit upserts the window and increments its count in one findOneAndUpdate, so
+// replicas counting the same window concurrently each see their own count
+func (r *MongoNotificationThrottleRepository) Hit(ctx context.Context, window *dto.NotificationThrottleWindow) (int64, error) {
+	ctx, span := startSpan(ctx, r.collection, "Hit")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return 0, err
+	}
+	update := bson.M{
+		"$inc": bson.M{"count": 1},
+		// The latest destination gets the summary, e.g. after a chat was relinked
+		"$set": bson.M{"destination": window.Destination},
+		"$setOnInsert": bson.M{
+			"userId":      window.UserID,
+			"channel":     string(window.Channel),
+			"windowStart": window.WindowStart,
+			"windowEnd":   window.WindowEnd,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counted entity.NotificationThrottleEntity
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": notificationThrottleID(window)}, update, opts).Decode(&counted)
+	if err != nil {
+		return 0, err
+	}
+	return counted.Count, nil
+}
+
+func (r *MongoNotificationThrottleRepository) Ended(ctx context.Context, before time.Time) ([]dto.NotificationThrottleWindow, error) {
+	ctx, span := startSpan(ctx, r.collection, "Ended")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "windowEnd", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"windowEnd": bson.M{"$lte": before}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var counted []entity.NotificationThrottleEntity
+	if err := cursor.All(ctx, &counted); err != nil {
+		return nil, err
+	}
+	windows := make([]dto.NotificationThrottleWindow, 0, len(counted))
+	for i := range counted {
+		windows = append(windows, mapNotificationThrottleEntityToDTO(&counted[i]))
+	}
+	return windows, nil
+}
+
+func (r *MongoNotificationThrottleRepository) Remove(ctx context.Context, window dto.NotificationThrottleWindow) error {
+	ctx, span := startSpan(ctx, r.collection, "Remove")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": notificationThrottleID(&window)})
+	return err
+}
+
+func notificationThrottleID(window *dto.NotificationThrottleWindow) string {
+	return window.UserID + ":" + string(window.Channel) + ":" + strconv.FormatInt(window.WindowStart.Unix(), 10)
+}
+
+func mapNotificationThrottleEntityToDTO(counted *entity.NotificationThrottleEntity) dto.NotificationThrottleWindow {
+	return dto.NotificationThrottleWindow{
+		UserID:      counted.UserID,
+		Channel:     dto.NotificationChannel(counted.Channel),
+		Destination: counted.Destination,
+		WindowStart: counted.WindowStart,
+		WindowEnd:   counted.WindowEnd,
+		Count:       counted.Count,
+	}
+}
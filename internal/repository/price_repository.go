@@ -0,0 +1,485 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"github.com/hello-api/pkg/money"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type MongoPriceRepository struct {
+	collection *mongo.Collection
+	latest     *mongo.Collection
+}
+
+// NewMongoPriceRepository stores ticks in collection and the latest price of
+// each symbol in latest
+func NewMongoPriceRepository(collection, latest *mongo.Collection) *MongoPriceRepository {
+	return &MongoPriceRepository{collection: collection, latest: latest}
+}
+
+func (r *MongoPriceRepository) Insert(ctx context.Context, req *dto.PriceTickRequest, tradingDate string) (*dto.PriceTickResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "Insert")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	tick := newPriceTickEntity(req, now)
+	if _, err := r.collection.InsertOne(ctx, tick); err != nil {
+		return nil, err
+	}
+	latest, err := r.rollLatest(ctx, tick, tradingDate, now)
+	if err != nil {
+		return nil, err
+	}
+	result := mapPriceTickEntityToDTO(&tick)
+	if latest != nil {
+		result.Latest = mapLatestPriceEntityToDTO(latest)
+	}
+	return result, nil
+}
+
+// rollLatest applies a tick to the symbol's latest price document in a single
+// pipeline update, mirroring rollLatestPrice. A document already on a later
+// trading date does not match the filter, so the upsert collides on the symbol
+// and the late tick is left out of the day statistics; nil is returned then.
+func (r *MongoPriceRepository) rollLatest(ctx context.Context, tick entity.PriceTickEntity, tradingDate string, now time.Time) (*entity.LatestPriceEntity, error) {
+	ctx, span := startSpan(ctx, r.latest, "RollLatest")
+	defer span.End()
+
+	newDay := bson.M{"$ne": bson.A{"$tradingDate", tradingDate}}
+	later := bson.M{"$or": bson.A{newDay, bson.M{"$gte": bson.A{tick.Time, "$time"}}}}
+	opens := bson.M{"$or": bson.A{newDay, bson.M{"$lt": bson.A{tick.Time, "$openTime"}}}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"previousClose": bson.M{"$cond": bson.A{newDay, "$price", "$previousClose"}},
+		"dayOpen":       bson.M{"$cond": bson.A{opens, tick.Price, "$dayOpen"}},
+		"openTime":      bson.M{"$cond": bson.A{opens, tick.Time, "$openTime"}},
+		"dayHigh":       bson.M{"$cond": bson.A{newDay, tick.Price, bson.M{"$max": bson.A{"$dayHigh", tick.Price}}}},
+		"dayLow":        bson.M{"$cond": bson.A{newDay, tick.Price, bson.M{"$min": bson.A{"$dayLow", tick.Price}}}},
+		"volume":        bson.M{"$cond": bson.A{newDay, tick.Volume, bson.M{"$add": bson.A{"$volume", tick.Volume}}}},
+		"price":         bson.M{"$cond": bson.A{later, tick.Price, "$price"}},
+		"time":          bson.M{"$cond": bson.A{later, tick.Time, "$time"}},
+		"lag":           bson.M{"$cond": bson.A{later, tick.Lag, "$lag"}},
+		"tradingDate":   tradingDate,
+		"updated_at":    now,
+	}}}}
+	filter := bson.M{"_id": tick.Symbol, "tradingDate": bson.M{"$not": bson.M{"$gt": tradingDate}}}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var latest entity.LatestPriceEntity
+	err := r.latest.FindOneAndUpdate(ctx, filter, update, opts).Decode(&latest)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &latest, nil
+}
+
+// InsertIfAbsent upserts on the tick's fields, so a tick sent twice, or
+// already received live, is stored once
+func (r *MongoPriceRepository) InsertIfAbsent(ctx context.Context, req *dto.PriceTickRequest) (*dto.PriceTickResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "InsertIfAbsent")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	tick := newPriceTickEntity(req, time.Now().UTC())
+	filter := bson.M{"symbol": tick.Symbol, "time": tick.Time, "price": tick.Price, "volume": tick.Volume}
+	update := bson.M{"$setOnInsert": bson.M{"_id": tick.ID, "created_at": tick.CreatedAt}}
+	result, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return nil, err
+	}
+	if result.UpsertedCount == 0 {
+		return nil, nil
+	}
+	return mapPriceTickEntityToDTO(&tick), nil
+}
+
+// dayAggregate is a trading day's statistics aggregated from its ticks
+type dayAggregate struct {
+	DayOpen  money.Amount `bson:"dayOpen"`
+	OpenTime time.Time    `bson:"openTime"`
+	DayHigh  money.Amount `bson:"dayHigh"`
+	DayLow   money.Amount `bson:"dayLow"`
+	Volume   float64      `bson:"volume"`
+	Price    money.Amount `bson:"price"`
+	Time     time.Time    `bson:"time"`
+	// Lag is that of the last tick, absent when it was backfilled
+	Lag *entity.TickLagEntity `bson:"lag"`
+}
+
+// RecomputeDay aggregates the ticks on the primary, which holds the ticks
+// just backfilled, and replaces the day statistics in one pipeline update.
+// A live tick rolled in between the two is kept in the latest price, but its
+// volume is only counted once the day is recomputed again.
+func (r *MongoPriceRepository) RecomputeDay(ctx context.Context, symbol, tradingDate string, from, to time.Time) (*dto.LatestPriceResponse, error) {
+	ctx, span := startSpan(ctx, r.latest, "RecomputeDay")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	ticks, err := r.collection.Clone(options.Collection().SetReadPreference(readpref.Primary()))
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := ticks.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: tickRangeFilter(symbol, from, to)}},
+		{{Key: "$sort", Value: bson.D{{Key: "time", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "dayOpen", Value: bson.M{"$first": "$price"}},
+			{Key: "openTime", Value: bson.M{"$first": "$time"}},
+			{Key: "dayHigh", Value: bson.M{"$max": "$price"}},
+			{Key: "dayLow", Value: bson.M{"$min": "$price"}},
+			{Key: "volume", Value: bson.M{"$sum": "$volume"}},
+			{Key: "price", Value: bson.M{"$last": "$price"}},
+			{Key: "time", Value: bson.M{"$last": "$time"}},
+			{Key: "lag", Value: bson.M{"$last": "$lag"}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var days []dayAggregate
+	if err := cursor.All(ctx, &days); err != nil {
+		return nil, err
+	}
+	if len(days) == 0 {
+		return nil, nil
+	}
+	day := days[0]
+
+	sameDay := bson.M{"$eq": bson.A{"$tradingDate", tradingDate}}
+	// A live tick of the day rolled in after the aggregation stays the latest price
+	later := bson.M{"$and": bson.A{sameDay, bson.M{"$gt": bson.A{"$time", day.Time}}}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"previousClose": bson.M{"$cond": bson.A{sameDay, "$previousClose", "$price"}},
+		"dayOpen":       day.DayOpen,
+		"openTime":      day.OpenTime,
+		"dayHigh":       day.DayHigh,
+		"dayLow":        day.DayLow,
+		"volume":        day.Volume,
+		"price":         bson.M{"$cond": bson.A{later, "$price", day.Price}},
+		"time":          bson.M{"$cond": bson.A{later, "$time", day.Time}},
+		"lag":           bson.M{"$cond": bson.A{later, "$lag", day.Lag}},
+		"tradingDate":   tradingDate,
+		"updated_at":    time.Now().UTC(),
+	}}}}
+	filter := bson.M{"_id": symbol, "tradingDate": bson.M{"$not": bson.M{"$gt": tradingDate}}}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var latest entity.LatestPriceEntity
+	err = r.latest.FindOneAndUpdate(ctx, filter, update, opts).Decode(&latest)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapLatestPriceEntityToDTO(&latest), nil
+}
+
+func (r *MongoPriceRepository) Latest(ctx context.Context, symbol string) (*dto.LatestPriceResponse, error) {
+	ctx, span := startSpan(ctx, r.latest, "Latest")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	var latest entity.LatestPriceEntity
+	err := r.latest.FindOne(ctx, bson.M{"_id": symbol}).Decode(&latest)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return mapLatestPriceEntityToDTO(&latest), nil
+}
+
+func (r *MongoPriceRepository) LatestForSymbols(ctx context.Context, symbols []string) (map[string]dto.LatestPriceResponse, error) {
+	ctx, span := startSpan(ctx, r.latest, "LatestForSymbols")
+	defer span.End()
+
+	result := make(map[string]dto.LatestPriceResponse, len(symbols))
+	if len(symbols) == 0 {
+		return result, nil
+	}
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	cursor, err := r.latest.Find(ctx, bson.M{"_id": bson.M{"$in": symbols}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var latest []entity.LatestPriceEntity
+	if err := cursor.All(ctx, &latest); err != nil {
+		return nil, err
+	}
+	for i := range latest {
+		result[latest[i].Symbol] = *mapLatestPriceEntityToDTO(&latest[i])
+	}
+	return result, nil
+}
+
+// MostRecent sorts the latest prices, which hold one document per symbol, so
+// it stays cheap without an index
+func (r *MongoPriceRepository) MostRecent(ctx context.Context) (*dto.LatestPriceResponse, error) {
+	ctx, span := startSpan(ctx, r.latest, "MostRecent")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "updated_at", Value: -1}})
+	var latest entity.LatestPriceEntity
+	err := r.latest.FindOne(ctx, bson.M{}, opts).Decode(&latest)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return mapLatestPriceEntityToDTO(&latest), nil
+}
+
+// UpdatedSince reads the latest prices, one document per symbol, so it stays
+// cheap without an index
+func (r *MongoPriceRepository) UpdatedSince(ctx context.Context, since time.Time) ([]dto.LatestPriceResponse, error) {
+	ctx, span := startSpan(ctx, r.latest, "UpdatedSince")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := r.latest.Find(ctx, bson.M{"updated_at": bson.M{"$gte": since}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var latest []entity.LatestPriceEntity
+	if err := cursor.All(ctx, &latest); err != nil {
+		return nil, err
+	}
+	result := make([]dto.LatestPriceResponse, len(latest))
+	for i := range latest {
+		result[i] = *mapLatestPriceEntityToDTO(&latest[i])
+	}
+	return result, nil
+}
+
+func (r *MongoPriceRepository) CountTicks(ctx context.Context, symbol string, from, to time.Time) (int64, error) {
+	ctx, span := startSpan(ctx, r.collection, "CountTicks")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return 0, err
+	}
+	return r.collection.CountDocuments(ctx, tickRangeFilter(symbol, from, to))
+}
+
+// ReplayTicks reads the range through a cursor in batches, so it is never
+// held in memory as a whole
+func (r *MongoPriceRepository) ReplayTicks(ctx context.Context, symbol string, from, to time.Time, tradingDate func(time.Time) string, fn func(dto.PriceTickResponse) error) error {
+	ctx, span := startSpan(ctx, r.collection, "ReplayTicks")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "time", Value: 1}, {Key: "_id", Value: 1}}).
+		SetBatchSize(replayBatchSize)
+	cursor, err := r.collection.Find(ctx, tickRangeFilter(symbol, from, to), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var latest *entity.LatestPriceEntity
+	for cursor.Next(ctx) {
+		var tick entity.PriceTickEntity
+		if err := cursor.Decode(&tick); err != nil {
+			return err
+		}
+		if latest, err = replayTick(latest, tick, tradingDate, fn); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// replayBatchSize is how many ticks a replay cursor fetches at a time
+const replayBatchSize = 1000
+
+func tickRangeFilter(symbol string, from, to time.Time) bson.M {
+	return bson.M{"symbol": symbol, "time": bson.M{"$gte": from, "$lt": to}}
+}
+
+// replayTick rolls a replayed tick into the latest price and hands both to fn
+func replayTick(current *entity.LatestPriceEntity, tick entity.PriceTickEntity, tradingDate func(time.Time) string, fn func(dto.PriceTickResponse) error) (*entity.LatestPriceEntity, error) {
+	rolled := rollLatestPrice(current, tick, tradingDate(tick.Time), tick.CreatedAt)
+	result := mapPriceTickEntityToDTO(&tick)
+	result.Latest = mapLatestPriceEntityToDTO(&rolled)
+	return &rolled, fn(*result)
+}
+
+// rollLatestPrice applies a tick to the latest price of its symbol, which is nil
+// for the first tick. The first tick of a new trading date rolls the day over:
+// the last price becomes the previous close and the day statistics restart.
+// A tick older than the latest one still counts towards the day statistics but
+// does not replace the latest price; a tick from an earlier trading date is
+// ignored.
+func rollLatestPrice(current *entity.LatestPriceEntity, tick entity.PriceTickEntity, tradingDate string, now time.Time) entity.LatestPriceEntity {
+	if current == nil || current.TradingDate < tradingDate {
+		rolled := entity.LatestPriceEntity{
+			Symbol:      tick.Symbol,
+			Price:       tick.Price,
+			Time:        tick.Time,
+			TradingDate: tradingDate,
+			DayOpen:     tick.Price,
+			OpenTime:    tick.Time,
+			DayHigh:     tick.Price,
+			DayLow:      tick.Price,
+			Volume:      tick.Volume,
+			Lag:         tick.Lag,
+			UpdatedAt:   now,
+		}
+		if current != nil {
+			previousClose := current.Price
+			rolled.PreviousClose = &previousClose
+		}
+		return rolled
+	}
+
+	rolled := *current
+	if current.TradingDate > tradingDate {
+		return rolled
+	}
+	if tick.Time.Before(rolled.OpenTime) {
+		rolled.DayOpen = tick.Price
+		rolled.OpenTime = tick.Time
+	}
+	if tick.Price > rolled.DayHigh {
+		rolled.DayHigh = tick.Price
+	}
+	if tick.Price < rolled.DayLow {
+		rolled.DayLow = tick.Price
+	}
+	rolled.Volume += tick.Volume
+	if !tick.Time.Before(rolled.Time) {
+		rolled.Price = tick.Price
+		rolled.Time = tick.Time
+		rolled.Lag = tick.Lag
+	}
+	rolled.UpdatedAt = now
+	return rolled
+}
+
+// recomputeDay replaces the day statistics of current with those of the
+// ticks of tradingDate, in time order, mirroring RecomputeDay. It returns false
+// when current is on a later trading date.
+func recomputeDay(current *entity.LatestPriceEntity, ticks []entity.PriceTickEntity, tradingDate string, now time.Time) (entity.LatestPriceEntity, bool) {
+	if current != nil && current.TradingDate > tradingDate {
+		return *current, false
+	}
+	first, last := ticks[0], ticks[len(ticks)-1]
+	day := entity.LatestPriceEntity{
+		Symbol:      first.Symbol,
+		Price:       last.Price,
+		Time:        last.Time,
+		TradingDate: tradingDate,
+		DayOpen:     first.Price,
+		OpenTime:    first.Time,
+		DayHigh:     first.Price,
+		DayLow:      first.Price,
+		Lag:         last.Lag,
+		UpdatedAt:   now,
+	}
+	for _, tick := range ticks {
+		day.DayHigh = max(day.DayHigh, tick.Price)
+		day.DayLow = min(day.DayLow, tick.Price)
+		day.Volume += tick.Volume
+	}
+	if current != nil {
+		if current.TradingDate == tradingDate {
+			day.PreviousClose = current.PreviousClose
+		} else {
+			previousClose := current.Price
+			day.PreviousClose = &previousClose
+		}
+	}
+	return day, true
+}
+
+func newPriceTickEntity(req *dto.PriceTickRequest, now time.Time) entity.PriceTickEntity {
+	return entity.PriceTickEntity{
+		ID:        primitive.NewObjectID().Hex(),
+		Symbol:    req.Symbol,
+		Price:     req.Price,
+		Volume:    req.Volume,
+		Time:      req.Time,
+		Lag:       newTickLagEntity(req.Lag),
+		CreatedAt: now,
+	}
+}
+
+func newTickLagEntity(lag *dto.TickLag) *entity.TickLagEntity {
+	if lag == nil {
+		return nil
+	}
+	return &entity.TickLagEntity{AsOf: lag.AsOf, FeedMs: lag.FeedMs, IngestMs: lag.IngestMs, TotalMs: lag.TotalMs}
+}
+
+func mapTickLagEntityToDTO(lag *entity.TickLagEntity) *dto.TickLag {
+	if lag == nil {
+		return nil
+	}
+	return &dto.TickLag{AsOf: lag.AsOf, FeedMs: lag.FeedMs, IngestMs: lag.IngestMs, TotalMs: lag.TotalMs}
+}
+
+func mapPriceTickEntityToDTO(tick *entity.PriceTickEntity) *dto.PriceTickResponse {
+	return &dto.PriceTickResponse{
+		ID:        tick.ID,
+		Symbol:    tick.Symbol,
+		Price:     tick.Price,
+		Volume:    tick.Volume,
+		Time:      tick.Time,
+		CreatedAt: tick.CreatedAt,
+		Lag:       mapTickLagEntityToDTO(tick.Lag),
+	}
+}
+
+func mapLatestPriceEntityToDTO(latest *entity.LatestPriceEntity) *dto.LatestPriceResponse {
+	return &dto.LatestPriceResponse{
+		Symbol:        latest.Symbol,
+		Price:         latest.Price,
+		Time:          latest.Time,
+		TradingDate:   latest.TradingDate,
+		DayOpen:       latest.DayOpen,
+		DayHigh:       latest.DayHigh,
+		DayLow:        latest.DayLow,
+		PreviousClose: latest.PreviousClose,
+		Volume:        latest.Volume,
+		Lag:           mapTickLagEntityToDTO(latest.Lag),
+		UpdatedAt:     latest.UpdatedAt,
+	}
+}
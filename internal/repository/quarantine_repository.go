@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoQuarantineRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoQuarantineRepository(collection *mongo.Collection) *MongoQuarantineRepository {
+	return &MongoQuarantineRepository{collection: collection}
+}
+
+func (r *MongoQuarantineRepository) Add(ctx context.Context, req *dto.QuarantineRequest) (*dto.QuarantinedTickResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "Add")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	tick := newQuarantinedTickEntity(req, time.Now().UTC())
+	if _, err := r.collection.InsertOne(ctx, tick); err != nil {
+		return nil, translateWriteError(err, nil)
+	}
+	return mapQuarantinedTickEntityToDTO(&tick), nil
+}
+
+func (r *MongoQuarantineRepository) FindByID(ctx context.Context, id string) (*dto.QuarantinedTickResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindByID")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	var tick entity.QuarantinedTickEntity
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&tick); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return mapQuarantinedTickEntityToDTO(&tick), nil
+}
+
+func (r *MongoQuarantineRepository) FindByStatus(ctx context.Context, status dto.QuarantineStatus, limit int64) ([]dto.QuarantinedTickResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindByStatus")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, bson.M{"status": status}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var ticks []entity.QuarantinedTickEntity
+	if err := cursor.All(ctx, &ticks); err != nil {
+		return nil, err
+	}
+	result := make([]dto.QuarantinedTickResponse, 0, len(ticks))
+	for i := range ticks {
+		result = append(result, *mapQuarantinedTickEntityToDTO(&ticks[i]))
+	}
+	return result, nil
+}
+
+// MarkReleased only matches held ticks, so concurrent releases store the tick once
+func (r *MongoQuarantineRepository) MarkReleased(ctx context.Context, id string, at time.Time) (bool, error) {
+	ctx, span := startSpan(ctx, r.collection, "MarkReleased")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return false, err
+	}
+	filter := bson.M{"_id": id, "status": entity.QuarantineStatusHeld}
+	update := bson.M{"$set": bson.M{"status": entity.QuarantineStatusReleased, "releasedAt": at}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func newQuarantinedTickEntity(req *dto.QuarantineRequest, now time.Time) entity.QuarantinedTickEntity {
+	return entity.QuarantinedTickEntity{
+		ID:                primitive.NewObjectID().Hex(),
+		Symbol:            req.Tick.Symbol,
+		Price:             req.Tick.Price,
+		Volume:            req.Tick.Volume,
+		Time:              req.Tick.Time,
+		Reason:            req.Reason,
+		LastAcceptedPrice: req.LastAcceptedPrice,
+		Status:            entity.QuarantineStatusHeld,
+		CreatedAt:         now,
+	}
+}
+
+func mapQuarantinedTickEntityToDTO(tick *entity.QuarantinedTickEntity) *dto.QuarantinedTickResponse {
+	return &dto.QuarantinedTickResponse{
+		ID:                tick.ID,
+		Symbol:            tick.Symbol,
+		Price:             tick.Price,
+		Volume:            tick.Volume,
+		Time:              tick.Time,
+		Reason:            tick.Reason,
+		LastAcceptedPrice: tick.LastAcceptedPrice,
+		Status:            dto.QuarantineStatus(tick.Status),
+		ReleasedAt:        tick.ReleasedAt,
+		CreatedAt:         tick.CreatedAt,
+	}
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoSymbolRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoSymbolRepository(collection *mongo.Collection) *MongoSymbolRepository {
+	return &MongoSymbolRepository{collection: collection}
+}
+
+func (r *MongoSymbolRepository) Upsert(ctx context.Context, symbols []dto.SymbolRequest, seenAt *time.Time) error {
+	ctx, span := startSpan(ctx, r.collection, "Upsert")
+	defer span.End()
+
+	if len(symbols) == 0 {
+		return nil
+	}
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	models := make([]mongo.WriteModel, 0, len(symbols))
+	for _, symbol := range symbols {
+		set := bson.M{"updated_at": now}
+		if symbol.Name != "" {
+			set["name"] = symbol.Name
+			set["nameKey"] = strings.ToUpper(symbol.Name)
+		}
+		if symbol.Sector != "" {
+			set["sector"] = symbol.Sector
+		}
+		update := bson.M{
+			"$set":         set,
+			"$setOnInsert": bson.M{"created_at": now},
+		}
+		if seenAt != nil {
+			// $max keeps a late batch from moving lastSeenAt back
+			update["$max"] = bson.M{"lastSeenAt": seenAt.UTC()}
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": symbol.Symbol}).
+			SetUpdate(update).
+			SetUpsert(true))
+	}
+	_, err := r.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return translateWriteError(err, nil)
+}
+
+func (r *MongoSymbolRepository) Exists(ctx context.Context, symbol string) (bool, error) {
+	ctx, span := startSpan(ctx, r.collection, "Exists")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return false, err
+	}
+	count, err := r.collection.CountDocuments(ctx, bson.M{"_id": symbol}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *MongoSymbolRepository) Search(ctx context.Context, prefix string, limit int64) ([]dto.SymbolResponse, error) {
+	ctx, span := startSpan(ctx, r.collection, "Search")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	filter := bson.M{}
+	if prefix != "" {
+		// Anchored prefixes can use the _id and nameKey indexes
+		pattern := "^" + regexp.QuoteMeta(prefix)
+		filter = bson.M{"$or": bson.A{
+			bson.M{"_id": bson.M{"$regex": pattern}},
+			bson.M{"nameKey": bson.M{"$regex": pattern}},
+		}}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var symbols []entity.SymbolEntity
+	if err := cursor.All(ctx, &symbols); err != nil {
+		return nil, err
+	}
+	result := make([]dto.SymbolResponse, 0, len(symbols))
+	for i := range symbols {
+		result = append(result, *mapSymbolEntityToDTO(&symbols[i]))
+	}
+	return result, nil
+}
+
+func mapSymbolEntityToDTO(symbol *entity.SymbolEntity) *dto.SymbolResponse {
+	return &dto.SymbolResponse{
+		Symbol:     symbol.Symbol,
+		Name:       symbol.Name,
+		Sector:     symbol.Sector,
+		LastSeenAt: symbol.LastSeenAt,
+		CreatedAt:  symbol.CreatedAt,
+		UpdatedAt:  symbol.UpdatedAt,
+	}
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/hello-api/internal/repository/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoTelegramLinkRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoTelegramLinkRepository(collection *mongo.Collection) *MongoTelegramLinkRepository {
+	return &MongoTelegramLinkRepository{collection: collection}
+}
+
+func (r *MongoTelegramLinkRepository) Create(ctx context.Context, code, userID string, expiresAt time.Time) error {
+	ctx, span := startSpan(ctx, r.collection, "Create")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	_, err := r.collection.InsertOne(ctx, entity.TelegramLinkCodeEntity{
+		Code:      code,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now().UTC(),
+	})
+	return translateWriteError(err, nil)
+}
+
+// Consume deletes the code with findOneAndDelete so it links at most one chat
+func (r *MongoTelegramLinkRepository) Consume(ctx context.Context, code string, now time.Time) (string, error) {
+	ctx, span := startSpan(ctx, r.collection, "Consume")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return "", err
+	}
+	var link entity.TelegramLinkCodeEntity
+	filter := bson.M{"_id": code, "expiresAt": bson.M{"$gt": now}}
+	err := r.collection.FindOneAndDelete(ctx, filter).Decode(&link)
+	if err == mongo.ErrNoDocuments {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return link.UserID, nil
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hello-api/pkg/tracing"
+)
+
+// startSpan starts a client span around a repository operation on collection.
+// The returned context must be passed to the driver call.
+func startSpan(ctx context.Context, collection *mongo.Collection, operation string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, collection.Name()+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(tracing.DBAttributes(collection.Name(), operation)...),
+	)
+}
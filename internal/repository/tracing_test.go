@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hello-api/internal/db"
+	"github.com/hello-api/pkg/tracing"
+)
+
+// A traced request produces a server span with the repository's database
+// span as its child. The database is reported down so the repository fails
+// fast without a server, after starting its span.
+func TestRequestSpanTree(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider(sdktrace.NewSimpleSpanProcessor(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	supervisor := db.NewSupervisor(func(context.Context) error { return errors.New("down") }, 0, 0)
+	supervisor.Check(context.Background())
+	t.Cleanup(func() {
+		db.NewSupervisor(func(context.Context) error { return nil }, 0, 0).Check(context.Background())
+	})
+
+	// Connect does not reach the server until an operation runs
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+	users := NewMongoUserRepository(client.Database("test").Collection(db.UsersCollection))
+
+	r := mux.NewRouter()
+	r.Use(tracing.Middleware)
+	r.HandleFunc("/users/count", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := users.Count(r.Context()); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/count", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want the request and its database span", len(spans))
+	}
+	dbSpan, server := spans[0], spans[1]
+	if server.Name != "GET /users/count" || server.SpanKind != trace.SpanKindServer {
+		t.Errorf("server span is %s (%s)", server.Name, server.SpanKind)
+	}
+	if dbSpan.Name != db.UsersCollection+".Count" || dbSpan.SpanKind != trace.SpanKindClient {
+		t.Errorf("database span is %s (%s)", dbSpan.Name, dbSpan.SpanKind)
+	}
+	if dbSpan.Parent.SpanID() != server.SpanContext.SpanID() || dbSpan.SpanContext.TraceID() != server.SpanContext.TraceID() {
+		t.Error("database span is not a child of the request span")
+	}
+	attrs := attribute.NewSet(dbSpan.Attributes...)
+	if v, _ := attrs.Value("db.collection.name"); v.AsString() != db.UsersCollection {
+		t.Errorf("database span attributes are %v", dbSpan.Attributes)
+	}
+	statusAttrs := attribute.NewSet(server.Attributes...)
+	if v, _ := statusAttrs.Value("http.response.status_code"); v.AsInt64() != http.StatusServiceUnavailable {
+		t.Errorf("server span attributes are %v", server.Attributes)
+	}
+}
@@ -2,9 +2,10 @@ package repository
 
 import (
 	"context"
-	"errors"
 	"time"
 	
+	"github.com/hello-api/internal/db"
+	"github.com/hello-api/internal/domain"
 	"github.com/hello-api/internal/repository/entity"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -12,6 +13,12 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// userDuplicates names the domain errors of the unique user indexes
+var userDuplicates = map[string]error{
+	db.UserEmailIndex: domain.ErrEmailAlreadyExists,
+	"userId_1":        domain.ErrUserAlreadyExit,
+}
+
 type MongoUserRepository struct {
 	collection *mongo.Collection
 }
@@ -22,50 +29,56 @@ func NewMongoUserRepository(collection *mongo.Collection) *MongoUserRepository {
 	}
 }
 
-// FindAll retrieves all user entities
-func (r *MongoUserRepository) FindAll() ([]entity.UserEntity, error) {
-	var userEntities []entity.UserEntity
+// FindAll retrieves one page of user entities sorted by ID, and the total number of users.
+// Pages start at 1.
+func (r *MongoUserRepository) FindAll(ctx context.Context, page, pageSize int64) ([]entity.UserEntity, int64, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindAll")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, 0, err
+	}
+	total, err := r.collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, 0, err
+	}
+	userEntities := []entity.UserEntity{}
 	
-	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
-	cursor, err := r.collection.Find(context.Background(), bson.M{}, opts)
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetSkip((page - 1) * pageSize).
+		SetLimit(pageSize)
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	defer cursor.Close(context.Background())
+	defer cursor.Close(ctx)
 
-	if err := cursor.All(context.Background(), &userEntities); err != nil {
-		return nil, err
+	if err := cursor.All(ctx, &userEntities); err != nil {
+		return nil, 0, err
 	}
 	
-	return userEntities, nil
+	return userEntities, total, nil
 }
 
-// FindByID retrieves a user entity by ID
-func (r *MongoUserRepository) FindByID(id string) (*entity.UserEntity, error) {
-	var userEntity entity.UserEntity
-	err := r.collection.FindOne(context.Background(), bson.M{"id": id}).Decode(&userEntity)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, nil // Not found, but not an error
-		}
+// Create inserts a new user entity
+func (r *MongoUserRepository) Create(ctx context.Context, userEntity *entity.UserEntity) (*entity.UserEntity, error) {
+	ctx, span := startSpan(ctx, r.collection, "Create")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
 		return nil, err
 	}
-	
-	return &userEntity, nil
-}
-
-// Create inserts a new user entity
-func (r *MongoUserRepository) Create(userEntity *entity.UserEntity) (*entity.UserEntity, error) {
 	// Set the created_at and updated_at
-	userEntity.CreatedAt = time.Now()
-	userEntity.UpdatedAt = time.Now()
+	userEntity.CreatedAt = time.Now().UTC()
+	userEntity.UpdatedAt = time.Now().UTC()
 	
 	// Ensure we have a new ID
 	userEntity.ID = primitive.NewObjectID()
 	
-	res, err := r.collection.InsertOne(context.Background(), userEntity)
+	res, err := r.collection.InsertOne(ctx, userEntity)
 	if err != nil {
-		return nil, err
+		return nil, translateWriteError(err, userDuplicates)
 	}
 	
 	// Set the newly generated ID
@@ -75,52 +88,70 @@ func (r *MongoUserRepository) Create(userEntity *entity.UserEntity) (*entity.Use
 }
 
 // Update updates an existing user entity
-func (r *MongoUserRepository) Update(userEntity *entity.UserEntity) (*entity.UserEntity, error) {
+func (r *MongoUserRepository) Update(ctx context.Context, userEntity *entity.UserEntity) (*entity.UserEntity, error) {
+	ctx, span := startSpan(ctx, r.collection, "Update")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
 	// Find the existing user
-	existingEntity, err := r.FindByID(userEntity.UserID)
+	existingEntity, err := r.FindByUserID(ctx, userEntity.UserID)
 	if err != nil {
 		return nil, err
 	}
 	if existingEntity == nil {
-		return nil, errors.New("user not found")
+		return nil, domain.ErrUserNotFound
 	}
 	
 	// Preserve creation date and ID
 	userEntity.CreatedAt = existingEntity.CreatedAt
 	userEntity.ID = existingEntity.ID
-	userEntity.UpdatedAt = time.Now()
+	userEntity.UpdatedAt = time.Now().UTC()
 	
 	filter := bson.M{"userId": userEntity.UserID}
 	update := bson.M{"$set": userEntity}
 	
-	_, err = r.collection.UpdateOne(context.Background(), filter, update)
+	_, err = r.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
-		return nil, err
+		return nil, translateWriteError(err, userDuplicates)
 	}
 	
 	return userEntity, nil
 }
 
 // Delete removes a user entity by ID
-func (r *MongoUserRepository) Delete(id string) error {
-	result, err := r.collection.DeleteOne(context.Background(), bson.M{"userId": id})
+func (r *MongoUserRepository) Delete(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, r.collection, "Delete")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	result, err := r.collection.DeleteOne(ctx, bson.M{"userId": id})
 	if err != nil {
 		return err
 	}
 	if result.DeletedCount == 0 {
-		return errors.New("user not found")
+		return domain.ErrUserNotFound
 	}
 	return nil
 }
 
 // FindByObjectID retrieves a user entity by MongoDB ObjectID
-func (r *MongoUserRepository) FindByObjectID(id string) (*entity.UserEntity, error) {
+func (r *MongoUserRepository) FindByObjectID(ctx context.Context, id string) (*entity.UserEntity, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindByObjectID")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
 	var userEntity entity.UserEntity
-	err = r.collection.FindOne(context.Background(), bson.M{"_id": objID}).Decode(&userEntity)
+	err = r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&userEntity)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
@@ -131,25 +162,37 @@ func (r *MongoUserRepository) FindByObjectID(id string) (*entity.UserEntity, err
 }
 
 // DeleteByObjectID removes a user entity by MongoDB ObjectID
-func (r *MongoUserRepository) DeleteByObjectID(id string) error {
+func (r *MongoUserRepository) DeleteByObjectID(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, r.collection, "DeleteByObjectID")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
-	result, err := r.collection.DeleteOne(context.Background(), bson.M{"_id": objID})
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objID})
 	if err != nil {
 		return err
 	}
 	if result.DeletedCount == 0 {
-		return errors.New("user not found")
+		return domain.ErrUserNotFound
 	}
 	return nil
 }
 
 // FindByUserID retrieves a user entity by userId
-func (r *MongoUserRepository) FindByUserID(userID string) (*entity.UserEntity, error) {
+func (r *MongoUserRepository) FindByUserID(ctx context.Context, userID string) (*entity.UserEntity, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindByUserID")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
 	var userEntity entity.UserEntity
-	err := r.collection.FindOne(context.Background(), bson.M{"userId": userID}).Decode(&userEntity)
+	err := r.collection.FindOne(ctx, bson.M{"userId": userID}).Decode(&userEntity)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
@@ -158,3 +201,76 @@ func (r *MongoUserRepository) FindByUserID(userID string) (*entity.UserEntity, e
 	}
 	return &userEntity, nil
 }
+
+// FindByEmail retrieves a user entity by email
+func (r *MongoUserRepository) FindByEmail(ctx context.Context, email string) (*entity.UserEntity, error) {
+	ctx, span := startSpan(ctx, r.collection, "FindByEmail")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	var userEntity entity.UserEntity
+	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&userEntity)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &userEntity, nil
+}
+
+// ExistingUserIDs looks the userIds up in one query and returns those that belong to a user
+func (r *MongoUserRepository) ExistingUserIDs(ctx context.Context, userIDs []string) (map[string]bool, error) {
+	ctx, span := startSpan(ctx, r.collection, "ExistingUserIDs")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return nil, err
+	}
+	found, err := r.collection.Distinct(ctx, "userId", bson.M{"userId": bson.M{"$in": userIDs}})
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(found))
+	for _, value := range found {
+		if userID, ok := value.(string); ok {
+			existing[userID] = true
+		}
+	}
+	return existing, nil
+}
+
+// SetTelegramChat links a Telegram chat to the user with the userId, or unlinks it when chatID is 0
+func (r *MongoUserRepository) SetTelegramChat(ctx context.Context, userID string, chatID int64) error {
+	ctx, span := startSpan(ctx, r.collection, "SetTelegramChat")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"telegramChatId": chatID, "updated_at": time.Now().UTC()}}
+	if chatID == 0 {
+		update = bson.M{"$unset": bson.M{"telegramChatId": ""}, "$set": bson.M{"updated_at": time.Now().UTC()}}
+	}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"userId": userID}, update)
+	if err != nil {
+		return translateWriteError(err, userDuplicates)
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+// Count returns the total number of users
+func (r *MongoUserRepository) Count(ctx context.Context) (int64, error) {
+	ctx, span := startSpan(ctx, r.collection, "Count")
+	defer span.End()
+
+	if err := checkAvailable(ctx); err != nil {
+		return 0, err
+	}
+	return r.collection.CountDocuments(ctx, bson.M{})
+}
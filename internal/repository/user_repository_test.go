@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+// userRepositoryBackends returns a fresh, empty repository of every backend;
+// the Mongo one skips without MONGO_TEST_URI
+func userRepositoryBackends() map[string]func(t *testing.T) domain.UserRepository {
+	return map[string]func(t *testing.T) domain.UserRepository{
+		"memory": func(t *testing.T) domain.UserRepository { return NewMemoryUserRepository() },
+		"mongo": func(t *testing.T) domain.UserRepository {
+			return NewMongoUserRepository(mongoTestDatabase(t).Collection("users"))
+		},
+	}
+}
+
+// Both backends look users up, update them by userId keeping their ID and
+// creation date, and delete them the same way
+func TestUserRepositoryBackends(t *testing.T) {
+	for name, newRepo := range userRepositoryBackends() {
+		t.Run(name, func(t *testing.T) {
+			users := newRepo(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			created, err := users.Create(ctx, &entity.UserEntity{UserID: "alice", Name: "Alice", Email: "alice@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for by, find := range map[string]func() (*entity.UserEntity, error){
+				"object id": func() (*entity.UserEntity, error) { return users.FindByObjectID(ctx, created.ID.Hex()) },
+				"user id":   func() (*entity.UserEntity, error) { return users.FindByUserID(ctx, "alice") },
+				"email":     func() (*entity.UserEntity, error) { return users.FindByEmail(ctx, "alice@example.com") },
+			} {
+				found, err := find()
+				if err != nil || found == nil || found.ID != created.ID {
+					t.Errorf("find by %s returned %+v (%v), want the created user", by, found, err)
+				}
+			}
+
+			mutedUntil := time.Now().UTC().Add(time.Hour).Truncate(time.Millisecond)
+			update := *created
+			update.Name = "Alice Smith"
+			update.MutedUntil = &mutedUntil
+			if _, err := users.Update(ctx, &update); err != nil {
+				t.Fatalf("updating an existing user returned %v", err)
+			}
+			found, err := users.FindByUserID(ctx, "alice")
+			if err != nil || found == nil {
+				t.Fatalf("got %+v (%v), want the updated user", found, err)
+			}
+			// Mongo keeps milliseconds
+			if found.ID != created.ID || !found.CreatedAt.Truncate(time.Millisecond).Equal(created.CreatedAt.Truncate(time.Millisecond)) {
+				t.Errorf("got ID %s created %s, want %s created %s", found.ID.Hex(), found.CreatedAt, created.ID.Hex(), created.CreatedAt)
+			}
+			if found.Name != "Alice Smith" || found.MutedUntil == nil || !found.MutedUntil.Equal(mutedUntil) {
+				t.Errorf("got name %q muted until %v, want %q muted until %s", found.Name, found.MutedUntil, "Alice Smith", mutedUntil)
+			}
+			if _, err := users.Update(ctx, &entity.UserEntity{UserID: "nobody"}); !errors.Is(err, domain.ErrUserNotFound) {
+				t.Errorf("updating an unknown user returned %v, want ErrUserNotFound", err)
+			}
+
+			if err := users.DeleteByObjectID(ctx, created.ID.Hex()); err != nil {
+				t.Fatal(err)
+			}
+			if found, err := users.FindByUserID(ctx, "alice"); found != nil || err != nil {
+				t.Errorf("deleted user is still found: %+v (%v)", found, err)
+			}
+			if count, err := users.Count(ctx); count != 0 || err != nil {
+				t.Errorf("count after delete is %d (%v), want 0", count, err)
+			}
+		})
+	}
+}
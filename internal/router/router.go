@@ -1,49 +1,296 @@
 package router
 
 import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
 	"github.com/gorilla/mux"
+	"github.com/hello-api/internal/common"
 	"github.com/hello-api/internal/db"
 	"github.com/hello-api/internal/domain"
 	"github.com/hello-api/internal/handler"
 	"github.com/hello-api/internal/repository"
 	"github.com/hello-api/internal/service"
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/metrics"
+	"github.com/hello-api/pkg/tracing"
 )
 
-func InitializeRoutes() *mux.Router {
+// Config holds what InitializeRoutes builds the API from, other than the
+// repositories and services it creates itself
+type Config struct {
+	Logger       *slog.Logger
+	SlowRequests *logging.SlowRequests
+	// Notifications is the notification outbox and Events the live event
+	// broadcaster; both outlive the request path
+	Notifications domain.NotificationRepository
+	Events        *service.Broadcaster
+
+	Schedule                service.MarketSchedule
+	TickFilter              service.TickFilterConfig
+	AlertCacheRefresh       time.Duration
+	UniqueEmail             bool
+	Telegram                service.TelegramConfig
+	TelegramBot             *service.TelegramBot
+	EmailEnabled            bool
+	MaxNotificationsPerHour int64
+	FeedStaleAfter          time.Duration
+	EvaluationSampling      service.EvaluationSamplingConfig
+	AlertArchive            service.AlertArchiveConfig
+	FlagEnv                 service.FeatureFlagEnv
+	AlertDates              service.AlertDateBounds
+	// EvaluatorDebugMaxEntries caps the entries of one evaluator debug call
+	EvaluatorDebugMaxEntries int
+}
+
+// InitializeRoutes builds the API router, serving every route under
+// APIVersionPrefix. Background work started here, such as the alert cache,
+// stops with ctx.
+func InitializeRoutes(ctx context.Context, cfg Config) http.Handler {
 	r := mux.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware(cfg.Logger, cfg.SlowRequests))
+	// Per-route request budgets; routes not listed get DefaultRequestTimeout
+	timeouts := common.NewRouteTimeouts(common.DefaultRequestTimeout)
+	r.Use(timeouts.Middleware)
+	// Unmatched paths, such as a malformed user id, get the same JSON error
+	// envelope as the handlers rather than mux's plain text
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		common.RespondWithError(w, http.StatusNotFound, "NOT_FOUND", "Resource not found")
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		common.RespondWithError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed")
+	})
 
 	// Initialize dependencies using interfaces for better decoupling
-	userCollection := db.GetCollection("users")
-
-	// Repository layer
 	var userRepository domain.UserRepository
-	userRepository = repository.NewMongoUserRepository(userCollection)
+	var alertRepository domain.AlertRepository
+	var holidayRepository domain.HolidayRepository
+	var priceRepository domain.PriceRepository
+	var quarantineRepository domain.QuarantineRepository
+	var alertChangeRepository domain.AlertChangeRepository
+	var telegramLinkRepository domain.TelegramLinkRepository
+	var emailDigestRepository domain.EmailDigestRepository
+	var notificationThrottleRepository domain.NotificationThrottleRepository
+	var dailyCounterRepository domain.DailyCounterRepository
+	var evaluationSampleRepository domain.EvaluationSampleRepository
+	var alertArchiveRepository domain.AlertArchiveRepository
+	var symbolRepository domain.SymbolRepository
+	var featureFlagRepository domain.FeatureFlagRepository
+	if db.UsesMongo() {
+		// Repository layer
+		userRepository = repository.NewMongoUserRepository(db.Users())
+		alertRepository = repository.NewMongoAlertRepository(db.Alerts())
+		holidayRepository = repository.NewMongoHolidayRepository(db.MarketHolidays())
+		priceRepository = repository.NewMongoPriceRepository(db.PriceTicks(), db.LatestPrices())
+		quarantineRepository = repository.NewMongoQuarantineRepository(db.QuarantinedTicks())
+		alertChangeRepository = repository.NewMongoAlertChangeRepository(db.AlertChanges(), db.Counters())
+		telegramLinkRepository = repository.NewMongoTelegramLinkRepository(db.TelegramLinkCodes())
+		emailDigestRepository = repository.NewMongoEmailDigestRepository(db.EmailDigestItems())
+		notificationThrottleRepository = repository.NewMongoNotificationThrottleRepository(db.NotificationThrottle())
+		dailyCounterRepository = repository.NewMongoDailyCounterRepository(db.Counters())
+		evaluationSampleRepository = repository.NewMongoEvaluationSampleRepository(db.AlertEvaluations(), db.EvaluationSampling())
+		alertArchiveRepository = repository.NewMongoAlertArchiveRepository(db.Alerts(), db.AlertsArchive())
+		symbolRepository = repository.NewMongoSymbolRepository(db.Symbols())
+		featureFlagRepository = repository.NewMongoFeatureFlagRepository(db.FeatureFlags())
+	} else {
+		cfg.Logger.Warn("Using in-memory repositories; data is not persisted", "backend", db.Backend())
+		userRepository = repository.NewMemoryUserRepository()
+		memoryAlertRepository := repository.NewMemoryAlertRepository()
+		alertRepository = memoryAlertRepository
+		holidayRepository = repository.NewMemoryHolidayRepository()
+		priceRepository = repository.NewMemoryPriceRepository()
+		quarantineRepository = repository.NewMemoryQuarantineRepository()
+		alertChangeRepository = repository.NewMemoryAlertChangeRepository()
+		telegramLinkRepository = repository.NewMemoryTelegramLinkRepository()
+		emailDigestRepository = repository.NewMemoryEmailDigestRepository()
+		notificationThrottleRepository = repository.NewMemoryNotificationThrottleRepository()
+		dailyCounterRepository = repository.NewMemoryDailyCounterRepository()
+		evaluationSampleRepository = repository.NewMemoryEvaluationSampleRepository()
+		alertArchiveRepository = repository.NewMemoryAlertArchiveRepository(memoryAlertRepository)
+		symbolRepository = repository.NewMemorySymbolRepository()
+		featureFlagRepository = repository.NewMemoryFeatureFlagRepository()
+	}
+
+	// Feature flags staging risky behavior, settable by admins at runtime
+	featureFlags := service.NewFeatureFlags(featureFlagRepository, cfg.FlagEnv, service.DefaultFeatureFlagRefresh)
+	go featureFlags.Run(ctx)
 
 	// Service layer
 	var userService domain.UserService
-	userService = service.NewUserService(userRepository)
+	userService = service.NewUserService(userRepository, cfg.UniqueEmail)
 
 	// Handler layer
 	userHandler := handler.NewUserHandler(userService)
 
 	// User routes
 	r.HandleFunc("/users", userHandler.GetUsers).Methods("GET")
+	r.HandleFunc("/users/count", userHandler.GetUserCount).Methods("GET")
 	r.HandleFunc("/users/{id:[a-fA-F0-9]{24}}", userHandler.GetUser).Methods("GET")
 	r.HandleFunc("/users", userHandler.CreateUser).Methods("POST")
 	r.HandleFunc("/users/{id:[a-fA-F0-9]{24}}", userHandler.UpdateUser).Methods("PUT")
+	r.HandleFunc("/users/{id:[a-fA-F0-9]{24}}", userHandler.PatchUser).Methods("PATCH")
 	r.HandleFunc("/users/{id:[a-fA-F0-9]{24}}", userHandler.DeleteUser).Methods("DELETE")
+	r.HandleFunc("/users/{id:[a-fA-F0-9]{24}}/mute", userHandler.MuteUser).Methods("PUT")
+	r.HandleFunc("/users/{id:[a-fA-F0-9]{24}}/mute", userHandler.UnmuteUser).Methods("DELETE")
+	r.Handle("/users/me/alert-defaults", common.RequireUser(http.HandlerFunc(userHandler.SetAlertDefaults))).Methods("PUT")
+
+	// Market hours gate alert evaluation
+	calendarService := service.NewMarketCalendarService(cfg.Schedule, holidayRepository)
+
+	// Public status: feed lag, triggers fired today and the market session
+	statusService := service.NewStatusService(priceRepository, dailyCounterRepository, calendarService, cfg.Schedule, cfg.FeedStaleAfter)
+	statusHandler := handler.NewStatusHandler(statusService)
+	r.HandleFunc("/status", statusHandler.GetStatus).Methods("GET")
+
+	// Symbol reference data, recorded from ingested ticks and admin imports,
+	// for typeahead and for rejecting alerts on unknown symbols
+	symbolService := service.NewSymbolService(symbolRepository, featureFlags)
+	symbolHandler := handler.NewSymbolHandler(symbolService)
+	r.HandleFunc("/symbols", symbolHandler.SearchSymbols).Methods("GET")
+
+	// Active alerts are indexed in memory so ingested ticks are matched without
+	// querying the database
+	alertCache := service.NewAlertCache(alertRepository, cfg.AlertCacheRefresh)
+	go alertCache.Run(ctx)
 
 	// Alert routes
-	alertCollection := db.GetCollection("alerts")
-	alertRepository := repository.NewMongoAlertRepository(alertCollection)
-	alertService := service.NewAlertService(alertRepository)
+	alertChanges := service.NewAlertChangeFeed(alertChangeRepository)
+	alertService := service.NewAlertService(alertRepository, cfg.Events, calendarService, cfg.Schedule, priceRepository, alertCache, alertChanges, symbolService, userRepository, cfg.AlertDates)
 	alertHandler := handler.NewAlertHandler(alertService)
 
 	r.HandleFunc("/alerts", alertHandler.CreateAlert).Methods("POST")
+	// Backtests stream up to MaxBacktestTicks stored ticks
+	timeouts.Set(common.LongRequestTimeout,
+		r.HandleFunc("/alerts/backtest", alertHandler.BacktestAlert).Methods("POST"))
+	// The change feed is read by the data feed with an API key holding alerts:read.
+	// Registered before /alerts/{id} so "changes" is not taken for an id.
+	alertChangesRoute := r.Handle("/alerts/changes",
+		common.RequireScope("alerts:read")(http.HandlerFunc(alertHandler.GetAlertChanges)),
+	).Methods("GET")
+	// A long poll, bounded by MaxAlertChangeWait rather than the request budget,
+	// and slow by design
+	timeouts.Exempt(alertChangesRoute)
+	cfg.SlowRequests.Ignore(alertChangesRoute)
+	// Also registered before /alerts/{id}; the handler needs the tick evaluator
+	// and is set once it is built below
+	alertMatchingRoute := r.Path("/alerts/matching").Methods("GET")
 	r.HandleFunc("/alerts/{id}", alertHandler.GetAlert).Methods("GET")
 	r.HandleFunc("/alerts/user/{userId}", alertHandler.GetAlertsByUser).Methods("GET")
 	r.HandleFunc("/alerts/{id}", alertHandler.UpdateAlert).Methods("PUT")
 	r.HandleFunc("/alerts/{id}", alertHandler.DeleteAlert).Methods("DELETE")
 
-	return r
+	// Fired alerts past their stop date are moved to the archive in the background
+	alertArchiver := service.NewAlertArchiver(alertArchiveRepository, alertChanges, cfg.AlertArchive)
+	go alertArchiver.Run(ctx)
+	alertArchiveHandler := handler.NewAlertArchiveHandler(alertArchiver)
+	r.HandleFunc("/alerts/user/{userId}/archive", alertArchiveHandler.GetArchivedAlerts).Methods("GET")
+	r.HandleFunc("/alerts/archive/{id}/restore", alertArchiveHandler.RestoreAlert).Methods("POST")
+
+	// Notification routes. Triggers are reported by the data feed and must be
+	// signed with WEBHOOK_SECRET_DATAFEED.
+	channels := service.NotificationChannels{Telegram: cfg.TelegramBot != nil, Email: cfg.EmailEnabled}
+	if channels.Telegram || channels.Email {
+		// Quiet hours and the hourly cap; summaries are queued once their hour has ended
+		channels.Policy = service.NewNotificationPolicy(userRepository, notificationThrottleRepository, cfg.Notifications, cfg.MaxNotificationsPerHour, cfg.Schedule.Location)
+		go channels.Policy.Run(ctx)
+	}
+	if cfg.EmailEnabled {
+		// Hourly digests are queued in the outbox once their hour has ended
+		channels.Digests = service.NewEmailDigester(emailDigestRepository, cfg.Notifications, channels.Policy, service.EmailDigestWindow)
+		go channels.Digests.Run(ctx)
+	}
+	notificationService := service.NewNotificationService(cfg.Notifications, alertRepository, cfg.Events, calendarService, userRepository, channels, statusService)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+
+	r.Handle("/alerts/{id}/triggers",
+		common.VerifySignature("datafeed", common.DefaultSignatureTolerance)(http.HandlerFunc(notificationHandler.RecordTrigger)),
+	).Methods("POST")
+	r.HandleFunc("/alerts/{id}/notifications", notificationHandler.GetAlertNotifications).Methods("GET")
+	// Deliveries of every user, so listed for admins only, signed with WEBHOOK_SECRET_ADMIN
+	r.Handle("/notifications",
+		common.VerifySignature("admin", common.DefaultSignatureTolerance)(http.HandlerFunc(notificationHandler.GetNotifications)),
+	).Methods("GET")
+
+	// Price ingestion from the data feed, signed with WEBHOOK_SECRET_DATAFEED
+	// Evaluation decisions of sampled alerts and shadow fires are stored for the
+	// admin evaluations route
+	evaluationSampler := service.NewEvaluationSampler(evaluationSampleRepository, alertRepository, cfg.EvaluationSampling)
+	go evaluationSampler.Run(ctx)
+	tickEvaluator := service.NewTickEvaluator(alertCache, alertRepository, notificationService, evaluationSampler, featureFlags)
+	alertMatchingRoute.Handler(http.HandlerFunc(handler.NewAlertMatchHandler(tickEvaluator).GetMatchingAlerts))
+	priceService := service.NewPriceService(priceRepository, quarantineRepository, cfg.TickFilter, cfg.Schedule, tickEvaluator, symbolService)
+	priceHandler := handler.NewPriceHandler(priceService)
+	r.Handle("/prices",
+		common.VerifySignature("datafeed", common.DefaultSignatureTolerance)(http.HandlerFunc(priceHandler.IngestPrices)),
+	).Methods("POST")
+	r.HandleFunc("/prices/{symbol}/latest", priceHandler.GetLatestPrice).Methods("GET")
+
+	// Telegram chat linking: the user asks for a code with their JWT and sends it
+	// to the bot, whose webhook links the chat
+	if cfg.TelegramBot != nil {
+		telegramService := service.NewTelegramService(telegramLinkRepository, userRepository, cfg.TelegramBot, cfg.Telegram)
+		telegramHandler := handler.NewTelegramHandler(telegramService, cfg.Telegram.WebhookSecret)
+		r.Handle("/users/me/telegram/link", common.RequireUser(http.HandlerFunc(telegramHandler.CreateLink))).Methods("POST")
+		r.Handle("/users/me/telegram", common.RequireUser(http.HandlerFunc(telegramHandler.Unlink))).Methods("DELETE")
+		r.HandleFunc("/telegram/webhook", telegramHandler.Webhook).Methods("POST")
+	}
+
+	// Admin routes, signed with WEBHOOK_SECRET_ADMIN
+	adminHandler := handler.NewAdminHandler(alertService, notificationService, calendarService, evaluationSampler)
+	admin := common.VerifySignature("admin", common.DefaultSignatureTolerance)
+	maintenanceHandler := handler.NewMaintenanceHandler(service.NewAlertMaintenanceService(alertRepository, userRepository, alertCache, alertChanges))
+	// Admin listings and evaluations get the longer budget
+	timeouts.Set(common.LongRequestTimeout,
+		r.Handle("/admin/alerts/{id}/evaluate", admin(http.HandlerFunc(adminHandler.EvaluateAlert))).Methods("POST"),
+		r.Handle("/admin/alerts/{id}/sampling", admin(http.HandlerFunc(adminHandler.SampleAlertEvaluations))).Methods("POST"),
+		r.Handle("/admin/alerts/{id}/evaluations", admin(http.HandlerFunc(adminHandler.GetAlertEvaluations))).Methods("GET"),
+		r.Handle("/admin/alerts/{id}/shadow", admin(http.HandlerFunc(adminHandler.SetAlertShadow))).Methods("PUT"),
+		r.Handle("/admin/market-calendar", admin(http.HandlerFunc(adminHandler.GetMarketCalendar))).Methods("GET"),
+		r.Handle("/admin/market-calendar/holidays", admin(http.HandlerFunc(adminHandler.AddHoliday))).Methods("POST"),
+		r.Handle("/admin/market-calendar/holidays/{date}", admin(http.HandlerFunc(adminHandler.RemoveHoliday))).Methods("DELETE"),
+		r.Handle("/admin/quarantined-ticks", admin(http.HandlerFunc(priceHandler.GetQuarantinedTicks))).Methods("GET"),
+		r.Handle("/admin/quarantined-ticks/{id}/release", admin(http.HandlerFunc(priceHandler.ReleaseQuarantinedTick))).Methods("POST"),
+		r.Handle("/admin/maintenance/orphaned-alerts", admin(http.HandlerFunc(maintenanceHandler.CleanupOrphanedAlerts))).Methods("POST"),
+		r.Handle("/admin/symbols/import", admin(http.HandlerFunc(symbolHandler.ImportSymbols))).Methods("POST"),
+	)
+
+	// Today's slowest routes, from the request log, and what the tick evaluator
+	// watches, with a reload of its alert index on demand
+	debugHandler := handler.NewDebugHandler(cfg.SlowRequests, cfg.Events, tickEvaluator, cfg.EvaluatorDebugMaxEntries)
+	r.Handle("/admin/debug/slow-routes", admin(http.HandlerFunc(debugHandler.GetSlowRoutes))).Methods("GET")
+	r.Handle("/admin/debug/slow-routes", admin(http.HandlerFunc(debugHandler.ResetSlowRoutes))).Methods("DELETE")
+	r.Handle("/admin/debug/live-connections", admin(http.HandlerFunc(debugHandler.GetLiveConnections))).Methods("GET")
+	r.Handle("/admin/debug/evaluator", admin(http.HandlerFunc(debugHandler.GetEvaluatorIndex))).Methods("GET")
+	r.Handle("/admin/debug/evaluator/resync", admin(http.HandlerFunc(debugHandler.ResyncEvaluatorIndex))).Methods("POST")
+	r.Handle("/admin/debug/evaluator/{symbol}", admin(http.HandlerFunc(debugHandler.GetEvaluatorIndex))).Methods("GET")
+	// the per-symbol view is also served where operators first asked for it,
+	// signed all the same
+	r.Handle("/debug/evaluator/{symbol}", admin(http.HandlerFunc(debugHandler.GetEvaluatorIndex))).Methods("GET")
+
+	// Feature flags: every flag with its value and source, and admin overrides
+	featureFlagHandler := handler.NewFeatureFlagHandler(featureFlags)
+	r.Handle("/admin/debug/flags", admin(http.HandlerFunc(featureFlagHandler.GetFlags))).Methods("GET")
+	r.Handle("/admin/flags/{name}", admin(http.HandlerFunc(featureFlagHandler.SetFlag))).Methods("PUT")
+	r.Handle("/admin/flags/{name}", admin(http.HandlerFunc(featureFlagHandler.ClearFlag))).Methods("DELETE")
+
+	// Live alert triggers and status changes for the authenticated user
+	wsHandler := handler.NewWSHandler(cfg.Events, service.NewLiveSnapshotService(alertRepository, priceRepository))
+	timeouts.Exempt(r.HandleFunc("/ws", wsHandler.Serve).Methods("GET"))
+
+	// Readiness: fails while the MongoDB supervisor reports the database unreachable
+	r.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if db.UsesMongo() && !db.Healthy() {
+			common.RespondWithError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "MongoDB is unreachable")
+			return
+		}
+		common.RespondWithSuccess(w, http.StatusOK, map[string]string{"status": "ready"})
+	}).Methods("GET")
+
+	// Metrics in the Prometheus text format
+	r.Handle("/metrics", metrics.Default.Handler()).Methods("GET")
+
+	return versionRoutes(r, featureFlags)
 }
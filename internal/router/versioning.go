@@ -0,0 +1,49 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/service"
+	"github.com/hello-api/pkg/metrics"
+)
+
+// APIVersionPrefix is the prefix every route is served under
+const APIVersionPrefix = "/v1"
+
+// unversionedPaths are served without the prefix whatever the legacy_routes
+// flag says, since probes and scrapers are configured outside the API
+var unversionedPaths = map[string]bool{
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// versionRoutes serves every route under APIVersionPrefix. The unversioned
+// paths are the legacy routes: while the legacy_routes flag is on they are
+// still served, with a Deprecation header and a Link to their successor;
+// once it is off they are answered 410 Gone.
+func versionRoutes(next http.Handler, flags *service.FeatureFlags) http.Handler {
+	metrics.Default.Describe("legacy_route_requests_total", "Requests served on a route without the /v1 prefix")
+	versioned := http.StripPrefix(APIVersionPrefix, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, APIVersionPrefix+"/") {
+			versioned.ServeHTTP(w, r)
+			return
+		}
+		if unversionedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		successor := APIVersionPrefix + r.URL.Path
+		if !service.FlagLegacyRoutes.Get(flags) {
+			common.RespondWithError(w, http.StatusGone, "GONE", fmt.Sprintf("Unversioned routes are retired, use %s", successor))
+			return
+		}
+		metrics.Default.Counter("legacy_route_requests_total", nil).Inc()
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		next.ServeHTTP(w, r)
+	})
+}
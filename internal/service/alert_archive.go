@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/metrics"
+)
+
+const (
+	// DefaultAlertArchiveAfter is how long after its stop date a fired alert is archived
+	DefaultAlertArchiveAfter = 30 * 24 * time.Hour
+	// DefaultAlertArchiveInterval is how often the archival job runs
+	DefaultAlertArchiveInterval = time.Hour
+	// alertArchiveBatch bounds the alerts moved by one archive call
+	alertArchiveBatch = 500
+)
+
+// AlertArchiveConfig is when fired alerts are moved to the archive
+type AlertArchiveConfig struct {
+	// After is how long past its stop date an alert is archived, 0 to turn archival off
+	After    time.Duration
+	Interval time.Duration
+}
+
+// LoadAlertArchiveConfig reads ALERT_ARCHIVE_AFTER (e.g. "168h", default 720h,
+// 0 to turn archival off) and ALERT_ARCHIVE_INTERVAL (default 1h)
+func LoadAlertArchiveConfig() (AlertArchiveConfig, error) {
+	cfg := AlertArchiveConfig{After: DefaultAlertArchiveAfter, Interval: DefaultAlertArchiveInterval}
+	if raw := os.Getenv("ALERT_ARCHIVE_AFTER"); raw != "" {
+		after, err := time.ParseDuration(raw)
+		if err != nil || after < 0 {
+			return cfg, fmt.Errorf("ALERT_ARCHIVE_AFTER must be a duration of 0 or more, got %q", raw)
+		}
+		cfg.After = after
+	}
+	if raw := os.Getenv("ALERT_ARCHIVE_INTERVAL"); raw != "" {
+		interval, err := time.ParseDuration(raw)
+		if err != nil || interval <= 0 {
+			return cfg, fmt.Errorf("ALERT_ARCHIVE_INTERVAL must be a positive duration, got %q", raw)
+		}
+		cfg.Interval = interval
+	}
+	return cfg, nil
+}
+
+// AlertArchiver moves alerts that fired and stopped long ago out of the live
+// alerts, so listings and per-user counts only see alerts that can still
+// matter. Archived alerts are listed per user and restored one at a time; a
+// restored alert is archived again once it has gone unchanged for the same age.
+// Moves are reported to the change feed as deletes and restores as creates.
+type AlertArchiver struct {
+	repo    domain.AlertArchiveRepository
+	changes *AlertChangeFeed
+	cfg     AlertArchiveConfig
+}
+
+func NewAlertArchiver(repo domain.AlertArchiveRepository, changes *AlertChangeFeed, cfg AlertArchiveConfig) *AlertArchiver {
+	metrics.Default.Describe("alerts_archived_total", "Alerts moved to the archive")
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultAlertArchiveInterval
+	}
+	return &AlertArchiver{repo: repo, changes: changes, cfg: cfg}
+}
+
+// Run archives eligible alerts every interval until ctx is done; it returns
+// at once when archival is off
+func (a *AlertArchiver) Run(ctx context.Context) {
+	if a.cfg.After <= 0 {
+		return
+	}
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		a.archive(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// archive moves eligible alerts in batches until none are left
+func (a *AlertArchiver) archive(ctx context.Context) {
+	before := time.Now().UTC().Add(-a.cfg.After)
+	for {
+		archived, err := a.repo.Archive(ctx, before, alertArchiveBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("Failed to archive alerts", "error", err)
+			}
+			return
+		}
+		metrics.Default.Counter("alerts_archived_total", nil).Add(int64(len(archived)))
+		for i := range archived {
+			a.recordChange(ctx, &archived[i], dto.AlertChangeDeleted)
+		}
+		if len(archived) > 0 {
+			slog.Info("Archived alerts", "count", len(archived))
+		}
+		if len(archived) < alertArchiveBatch {
+			return
+		}
+	}
+}
+
+// GetArchivedAlerts returns one page of a user's archived alerts. Pages start at 1.
+func (a *AlertArchiver) GetArchivedAlerts(ctx context.Context, userID string, page, pageSize int64) (*dto.ArchivedAlertPageResponse, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, fmt.Errorf("page and pageSize must be positive: %w", domain.ErrValidation)
+	}
+	items, total, err := a.repo.FindByUser(ctx, userID, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []dto.ArchivedAlertResponse{}
+	}
+	return &dto.ArchivedAlertPageResponse{
+		Items:      items,
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+		TotalPages: (total + pageSize - 1) / pageSize,
+	}, nil
+}
+
+// RestoreAlert moves an archived alert back to the live alerts, as it was
+// when archived
+func (a *AlertArchiver) RestoreAlert(ctx context.Context, id string) (*dto.AlertResponse, error) {
+	restored, err := a.repo.Restore(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if restored == nil {
+		return nil, domain.ErrAlertNotFound
+	}
+	a.recordChange(ctx, restored, dto.AlertChangeCreated)
+	logging.FromContext(ctx).Info("alert restored from archive", "alert_id", id)
+	return restored, nil
+}
+
+// recordChange appends a move to the change feed; the move is already stored,
+// so a failure is logged rather than returned
+func (a *AlertArchiver) recordChange(ctx context.Context, alert *dto.AlertResponse, change dto.AlertChangeType) {
+	if a.changes == nil {
+		return
+	}
+	if _, err := a.changes.Record(ctx, *alert, change); err != nil {
+		logging.FromContext(ctx).Error("failed to record alert change",
+			"alert_id", alert.ID, "change", change, "error", err)
+	}
+}
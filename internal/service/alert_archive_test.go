@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/pkg/money"
+)
+
+// Only inactive alerts that fired and stopped more than the configured age ago
+// are moved to the archive; they leave the user's live alerts, are listed in
+// pages and can be restored once
+func TestAlertArchiver(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	const after = 100 * time.Millisecond
+	archiver := NewAlertArchiver(repository.NewMemoryAlertArchiveRepository(alerts), nil, AlertArchiveConfig{After: after})
+	start := time.Now().UTC()
+
+	// create stores an alert of alice; fired ones fire and are then switched
+	// off, as a one-shot alert is
+	create := func(status dto.AlertStatus, stopDate time.Time, fired bool) string {
+		t.Helper()
+		alert, err := alerts.Create(ctx, &dto.AlertCreateRequest{
+			UserID: "alice", Symbol: "GP", Rule: dto.AlertRuleAbove, Price: money.FromFloat(100),
+			Status: dto.AlertStatusActive, StopDate: stopDate,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fired {
+			if _, err := alerts.MarkTriggered(ctx, alert.ID, start); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if status == dto.AlertStatusInactive {
+			if _, err := alerts.Deactivate(ctx, []string{alert.ID}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return alert.ID
+	}
+	longAgo := start.Add(-time.Hour)
+	var archivable []string
+	for i := 0; i < 3; i++ {
+		archivable = append(archivable, create(dto.AlertStatusInactive, longAgo, true))
+	}
+	kept := map[string]string{
+		"active":           create(dto.AlertStatusActive, longAgo, true),
+		"never fired":      create(dto.AlertStatusInactive, longAgo, false),
+		"no stop date":     create(dto.AlertStatusInactive, time.Time{}, true),
+		"stopped recently": create(dto.AlertStatusInactive, start.Add(150*time.Millisecond), true),
+	}
+	time.Sleep(2 * after)
+	// switched off within the age, though it stopped long ago
+	kept["updated recently"] = create(dto.AlertStatusInactive, longAgo, true)
+
+	archiver.archive(ctx)
+
+	live, err := alerts.FindAllByUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	liveIDs := make(map[string]bool)
+	for _, alert := range live {
+		liveIDs[alert.ID] = true
+	}
+	for name, id := range kept {
+		if !liveIDs[id] {
+			t.Errorf("the %s alert was archived", name)
+		}
+	}
+	for _, id := range archivable {
+		if liveIDs[id] {
+			t.Errorf("alert %s is still live", id)
+		}
+	}
+
+	page, err := archiver.GetArchivedAlerts(ctx, "alice", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || page.TotalPages != 2 || len(page.Items) != 2 {
+		t.Fatalf("got %d items of %d in %d pages, want 2 of 3 in 2", len(page.Items), page.Total, page.TotalPages)
+	}
+	archived := page.Items[0]
+	if archived.ArchivedAt.Before(start) || archived.LastTriggeredAt == nil || archived.Status != dto.AlertStatusInactive {
+		t.Errorf("got %+v, want a fired inactive alert archived after %s", archived, start)
+	}
+	if last, _ := archiver.GetArchivedAlerts(ctx, "alice", 2, 2); len(last.Items) != 1 {
+		t.Errorf("got %d items on the last page, want 1", len(last.Items))
+	}
+	if other, _ := archiver.GetArchivedAlerts(ctx, "bob", 1, 10); other.Total != 0 || other.Items == nil {
+		t.Errorf("got %+v for bob, want an empty page", other)
+	}
+
+	restored, err := archiver.RestoreAlert(ctx, archived.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := alerts.FindByID(ctx, archived.ID); stored == nil || restored.ID != archived.ID {
+		t.Errorf("the restored alert %s is not live", archived.ID)
+	}
+	if _, err := archiver.RestoreAlert(ctx, archived.ID); !errors.Is(err, domain.ErrAlertNotFound) {
+		t.Errorf("got %v restoring twice, want ErrAlertNotFound", err)
+	}
+	// the restore counts as an update, so the alert stays live for another age
+	archiver.archive(ctx)
+	if stored, _ := alerts.FindByID(ctx, archived.ID); stored == nil {
+		t.Error("the restored alert was archived again at once")
+	}
+}
+
+// Pages start at 1 and hold at least one alert
+func TestGetArchivedAlertsValidation(t *testing.T) {
+	archiver := NewAlertArchiver(repository.NewMemoryAlertArchiveRepository(repository.NewMemoryAlertRepository()), nil, AlertArchiveConfig{})
+	for _, tc := range []struct{ page, pageSize int64 }{{0, 10}, {1, 0}, {-1, -1}} {
+		if _, err := archiver.GetArchivedAlerts(context.Background(), "alice", tc.page, tc.pageSize); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("page %d of %d: got %v, want ErrValidation", tc.page, tc.pageSize, err)
+		}
+	}
+}
+
+// The archive age and interval come from the environment; 0 turns archival off
+func TestLoadAlertArchiveConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		after     string
+		interval  string
+		wantAfter time.Duration
+		wantErr   bool
+	}{
+		{name: "defaults", wantAfter: DefaultAlertArchiveAfter},
+		{name: "a week", after: "168h", interval: "10m", wantAfter: 168 * time.Hour},
+		{name: "off", after: "0", wantAfter: 0},
+		{name: "negative age", after: "-1h", wantErr: true},
+		{name: "zero interval", interval: "0s", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ALERT_ARCHIVE_AFTER", tc.after)
+			t.Setenv("ALERT_ARCHIVE_INTERVAL", tc.interval)
+			cfg, err := LoadAlertArchiveConfig()
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got %+v, want an error", cfg)
+				}
+				return
+			}
+			if err != nil || cfg.After != tc.wantAfter {
+				t.Errorf("got %+v, %v, want archival after %s", cfg, err, tc.wantAfter)
+			}
+		})
+	}
+}
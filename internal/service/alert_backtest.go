@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hello-api/internal/common/timeutil"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/logging"
+)
+
+const (
+	// MaxBacktestTicks is the most ticks one backtest replays; longer ranges
+	// of busy symbols have to be split
+	MaxBacktestTicks = 200000
+	// maxBacktestTriggersListed is the most triggers a backtest response lists
+	maxBacktestTriggersListed = 1000
+)
+
+// BacktestAlert replays an alert definition over the stored ticks of its
+// symbol and reports the triggers it would have had. Each tick is decided by
+// DecideTick, like live ticks, with the day statistics rolled from the start
+// of the range: percent rules on previous close have no baseline on its first
+// trading date. Like live triggers, those outside market hours are skipped
+// unless the alert evaluates off hours, and the alert stays armed.
+func (s *AlertService) BacktestAlert(ctx context.Context, req dto.AlertBacktestRequest) (*dto.AlertBacktestResponse, error) {
+	definition := req.Alert
+	if err := normalizeAlert(&definition); err != nil {
+		return nil, err
+	}
+	if definition.Symbol == "" {
+		return nil, fmt.Errorf("backtests need an alert symbol: %w", domain.ErrValidation)
+	}
+	from, to := timeutil.UTC(req.From), timeutil.UTC(req.To)
+	if from.IsZero() || to.IsZero() || !from.Before(to) {
+		return nil, fmt.Errorf("from and to are required and from must be before to: %w", domain.ErrValidation)
+	}
+
+	count, err := s.prices.CountTicks(ctx, definition.Symbol, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if count > MaxBacktestTicks {
+		return nil, fmt.Errorf("%s has %d ticks in the range, more than the %d a backtest replays; narrow the range: %w",
+			definition.Symbol, count, MaxBacktestTicks, domain.ErrValidation)
+	}
+
+	alert := backtestAlert(definition)
+	result := &dto.AlertBacktestResponse{Symbol: alert.Symbol, From: from, To: to, Triggers: []dto.BacktestTrigger{}}
+	triggered := false
+	var previous time.Time
+	var maxGap time.Duration
+	err = s.prices.ReplayTicks(ctx, alert.Symbol, from, to, s.schedule.TradingDate, func(tick dto.PriceTickResponse) error {
+		result.TicksEvaluated++
+		decision, gate := DecideTick(alert, triggered, tick.Price, tick.Time, tick.Latest, s.schedule.TradingDate(tick.Time))
+		switch decision {
+		case TickRearms:
+			triggered = false
+			return nil
+		case TickUnchanged:
+			return nil
+		}
+		if !alert.EvaluateOffHours {
+			session, err := s.calendar.Session(ctx, tick.Time)
+			if err != nil {
+				return err
+			}
+			if SkipsOutsideMarketHours(alert, session) {
+				return nil
+			}
+		}
+		triggered = true
+
+		at := tick.Time
+		result.Summary.Count++
+		if result.Summary.FirstAt == nil {
+			result.Summary.FirstAt = &at
+		} else if gap := at.Sub(previous); gap > maxGap {
+			maxGap = gap
+		}
+		previous = at
+		result.Summary.LastAt = &at
+		if len(result.Triggers) < maxBacktestTriggersListed {
+			result.Triggers = append(result.Triggers, dto.BacktestTrigger{TriggeredAt: at, Price: tick.Price, Reason: gate.Reason})
+		} else {
+			result.Truncated = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Summary.Count > 1 {
+		result.Summary.MaxGap = maxGap.String()
+	}
+	logging.FromContext(ctx).Info("alert backtested", "symbol", alert.Symbol, "rule", alert.Rule,
+		"ticks", result.TicksEvaluated, "triggers", result.Summary.Count)
+	return result, nil
+}
+
+// backtestAlert is the alert a definition would be once stored: active and armed
+func backtestAlert(definition dto.AlertCreateRequest) dto.AlertResponse {
+	return dto.AlertResponse{
+		Name:             definition.Name,
+		Price:            definition.Price,
+		Rule:             definition.Rule,
+		StopDate:         definition.StopDate,
+		StartDate:        definition.StartDate,
+		Status:           dto.AlertStatusActive,
+		UserID:           definition.UserID,
+		EvaluateOffHours: definition.EvaluateOffHours,
+		Symbol:           definition.Symbol,
+		Baseline:         definition.Baseline,
+	}
+}
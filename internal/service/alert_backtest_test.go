@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/pkg/money"
+)
+
+// backtestSeries is GP over two trading days, Sunday 3 and Monday 4 March
+// 2024; the market is open from 04:00 to 08:30 UTC
+var backtestSeries = []struct {
+	at    string
+	price float64
+}{
+	{"2024-03-03T04:00:00Z", 98},
+	{"2024-03-03T04:30:00Z", 101},
+	{"2024-03-03T05:00:00Z", 102},
+	{"2024-03-03T05:30:00Z", 99},
+	{"2024-03-03T06:00:00Z", 100},
+	{"2024-03-03T08:00:00Z", 97},
+	{"2024-03-03T09:00:00Z", 103}, // after the close
+	{"2024-03-04T04:30:00Z", 104},
+	{"2024-03-04T05:00:00Z", 105},
+}
+
+// newTestBacktestService returns an alert service over backtestSeries
+func newTestBacktestService(t *testing.T) *AlertService {
+	t.Helper()
+	ctx := context.Background()
+	prices := repository.NewMemoryPriceRepository()
+	schedule := DefaultMarketSchedule()
+	for _, point := range backtestSeries {
+		at, err := time.Parse(time.RFC3339, point.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tick := &dto.PriceTickRequest{Symbol: "GP", Price: money.FromFloat(point.price), Volume: 10, Time: at}
+		if _, err := prices.Insert(ctx, tick, schedule.TradingDate(at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	calendar := NewMarketCalendarService(schedule, repository.NewMemoryHolidayRepository())
+	return NewAlertService(repository.NewMemoryAlertRepository(), nil, calendar, schedule, prices, nil, nil, nil, nil, DefaultAlertDateBounds())
+}
+
+// A backtest fires on every crossing of the series, re-arming in between,
+// skips triggers after the close unless the alert evaluates off hours, and
+// sums the triggers up
+func TestBacktestAlert(t *testing.T) {
+	from := time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)
+	to := from.Add(48 * time.Hour)
+
+	for _, tc := range []struct {
+		name      string
+		alert     dto.AlertCreateRequest
+		from      time.Time
+		wantTimes []string
+		wantGap   string
+	}{
+		{
+			name:      "above",
+			alert:     dto.AlertCreateRequest{Symbol: "gp", Rule: dto.AlertRuleAbove, Price: money.FromFloat(100)},
+			from:      from,
+			wantTimes: []string{"2024-03-03T04:30:00Z", "2024-03-03T06:00:00Z", "2024-03-04T04:30:00Z"},
+			wantGap:   "22h30m0s",
+		},
+		{
+			name:      "above off hours",
+			alert:     dto.AlertCreateRequest{Symbol: "GP", Rule: dto.AlertRuleAbove, Price: money.FromFloat(100), EvaluateOffHours: true},
+			from:      from,
+			wantTimes: []string{"2024-03-03T04:30:00Z", "2024-03-03T06:00:00Z", "2024-03-03T09:00:00Z"},
+			wantGap:   "3h0m0s",
+		},
+		{
+			name:      "below",
+			alert:     dto.AlertCreateRequest{Symbol: "GP", Rule: dto.AlertRuleBelow, Price: money.FromFloat(99)},
+			from:      from,
+			wantTimes: []string{"2024-03-03T04:00:00Z", "2024-03-03T05:30:00Z", "2024-03-03T08:00:00Z"},
+			wantGap:   "2h30m0s",
+		},
+		{
+			// the first day has no previous close; 105 is 1.9% above the 103 close
+			name:      "percent change above",
+			alert:     dto.AlertCreateRequest{Symbol: "GP", Rule: dto.AlertRulePercentChangeAbove, Price: money.FromFloat(1.5)},
+			from:      from,
+			wantTimes: []string{"2024-03-04T05:00:00Z"},
+		},
+		{
+			name:      "range start",
+			alert:     dto.AlertCreateRequest{Symbol: "GP", Rule: dto.AlertRuleAbove, Price: money.FromFloat(100)},
+			from:      time.Date(2024, 3, 3, 5, 0, 0, 0, time.UTC),
+			wantTimes: []string{"2024-03-03T05:00:00Z", "2024-03-03T06:00:00Z", "2024-03-04T04:30:00Z"},
+			wantGap:   "22h30m0s",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			alerts := newTestBacktestService(t)
+			result, err := alerts.BacktestAlert(context.Background(), dto.AlertBacktestRequest{Alert: tc.alert, From: tc.from, To: to})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var times []string
+			for _, trigger := range result.Triggers {
+				times = append(times, trigger.TriggeredAt.Format(time.RFC3339))
+				if trigger.Reason == "" {
+					t.Errorf("the trigger at %s has no reason", trigger.TriggeredAt)
+				}
+			}
+			if len(times) != len(tc.wantTimes) {
+				t.Fatalf("got triggers %v, want %v", times, tc.wantTimes)
+			}
+			for i := range times {
+				if times[i] != tc.wantTimes[i] {
+					t.Errorf("got trigger %d at %s, want %s", i, times[i], tc.wantTimes[i])
+				}
+			}
+
+			summary := result.Summary
+			if summary.Count != len(tc.wantTimes) || summary.MaxGap != tc.wantGap {
+				t.Errorf("got count %d and max gap %q, want %d and %q", summary.Count, summary.MaxGap, len(tc.wantTimes), tc.wantGap)
+			}
+			if first := summary.FirstAt.Format(time.RFC3339); first != tc.wantTimes[0] {
+				t.Errorf("got first %s, want %s", first, tc.wantTimes[0])
+			}
+			if last := summary.LastAt.Format(time.RFC3339); last != tc.wantTimes[len(tc.wantTimes)-1] {
+				t.Errorf("got last %s, want %s", last, tc.wantTimes[len(tc.wantTimes)-1])
+			}
+			if result.Symbol != "GP" || result.Truncated {
+				t.Errorf("got symbol %s truncated %v, want GP untruncated", result.Symbol, result.Truncated)
+			}
+		})
+	}
+}
+
+// The first trigger carries the tick's price, and every tick of the range is
+// evaluated once
+func TestBacktestAlertTicks(t *testing.T) {
+	alerts := newTestBacktestService(t)
+	from := time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)
+	result, err := alerts.BacktestAlert(context.Background(), dto.AlertBacktestRequest{
+		Alert: dto.AlertCreateRequest{Symbol: "GP", Rule: dto.AlertRuleAbove, Price: money.FromFloat(100)},
+		From:  from,
+		To:    from.Add(24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TicksEvaluated != 7 {
+		t.Errorf("got %d ticks evaluated, want 7", result.TicksEvaluated)
+	}
+	if got := result.Triggers[0].Price; got != money.FromFloat(101) {
+		t.Errorf("got first trigger price %s, want 101", got)
+	}
+	if result.Summary.MaxGap != "1h30m0s" {
+		t.Errorf("got max gap %q, want 1h30m0s", result.Summary.MaxGap)
+	}
+}
+
+// Backtests without a symbol or with an empty range are rejected
+func TestBacktestAlertValidation(t *testing.T) {
+	alerts := newTestBacktestService(t)
+	from := time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)
+	definition := dto.AlertCreateRequest{Symbol: "GP", Rule: dto.AlertRuleAbove, Price: money.FromFloat(100)}
+
+	for _, tc := range []struct {
+		name string
+		req  dto.AlertBacktestRequest
+	}{
+		{name: "no symbol", req: dto.AlertBacktestRequest{Alert: dto.AlertCreateRequest{Rule: dto.AlertRuleAbove, Price: money.FromFloat(100)}, From: from, To: from.Add(time.Hour)}},
+		{name: "no range", req: dto.AlertBacktestRequest{Alert: definition}},
+		{name: "reversed range", req: dto.AlertBacktestRequest{Alert: definition, From: from.Add(time.Hour), To: from}},
+		{name: "empty range", req: dto.AlertBacktestRequest{Alert: definition, From: from, To: from}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := alerts.BacktestAlert(context.Background(), tc.req)
+			if !errors.Is(err, domain.ErrValidation) {
+				t.Errorf("got %v, want ErrValidation", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/metrics"
+)
+
+// DefaultAlertCacheRefresh is how often the active alert index is reloaded
+const DefaultAlertCacheRefresh = 30 * time.Second
+
+// LoadAlertCacheRefresh reads the reload interval from ALERT_CACHE_REFRESH (e.g. "10s")
+func LoadAlertCacheRefresh() (time.Duration, error) {
+	raw := os.Getenv("ALERT_CACHE_REFRESH")
+	if raw == "" {
+		return DefaultAlertCacheRefresh, nil
+	}
+	interval, err := time.ParseDuration(raw)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("ALERT_CACHE_REFRESH must be a positive duration, got %q", raw)
+	}
+	return interval, nil
+}
+
+// AlertCache is an in-memory index of active alerts by symbol, so evaluating a
+// tick is a map lookup rather than a database query. It is reloaded
+// periodically, and right away when alerts change through this process;
+// changes made by other replicas show up within one refresh interval.
+type AlertCache struct {
+	repo     domain.AlertRepository
+	interval time.Duration
+	// invalidated wakes Run for an early reload
+	invalidated chan struct{}
+	// refreshing serializes reloads, so a forced one cannot race Run's and
+	// leave the older load in place
+	refreshing sync.Mutex
+
+	mu       sync.RWMutex
+	bySymbol map[string][]dto.AlertResponse
+	loadedAt time.Time
+	// generation counts successful reloads
+	generation  int64
+	lastError   error
+	lastErrorAt time.Time
+}
+
+func NewAlertCache(repo domain.AlertRepository, interval time.Duration) *AlertCache {
+	metrics.Default.Describe("alert_cache_refreshes_total", "Reloads of the active alert index, by result")
+	metrics.Default.Describe("alert_cache_alerts", "Active alerts with a symbol held in the evaluation index")
+	if interval <= 0 {
+		interval = DefaultAlertCacheRefresh
+	}
+	return &AlertCache{
+		repo:        repo,
+		interval:    interval,
+		invalidated: make(chan struct{}, 1),
+		bySymbol:    make(map[string][]dto.AlertResponse),
+	}
+}
+
+// Refresh reloads the active alerts. On failure the previous index is kept.
+func (c *AlertCache) Refresh(ctx context.Context) error {
+	c.refreshing.Lock()
+	defer c.refreshing.Unlock()
+
+	alerts, err := c.repo.FindActive(ctx)
+	if err != nil {
+		metrics.Default.Counter("alert_cache_refreshes_total", metrics.Labels{"result": "error"}).Inc()
+		c.mu.Lock()
+		c.lastError = err
+		c.lastErrorAt = time.Now()
+		c.mu.Unlock()
+		return err
+	}
+
+	// Only alerts with a symbol can be matched against ticks
+	bySymbol := make(map[string][]dto.AlertResponse)
+	indexed := 0
+	for _, alert := range alerts {
+		if alert.Symbol == "" {
+			continue
+		}
+		symbol := strings.ToUpper(alert.Symbol)
+		bySymbol[symbol] = append(bySymbol[symbol], alert)
+		indexed++
+	}
+
+	c.mu.Lock()
+	c.bySymbol = bySymbol
+	c.loadedAt = time.Now()
+	c.generation++
+	c.lastError = nil
+	c.mu.Unlock()
+
+	metrics.Default.Counter("alert_cache_refreshes_total", metrics.Labels{"result": "ok"}).Inc()
+	metrics.Default.Gauge("alert_cache_alerts", nil).Set(int64(indexed))
+	return nil
+}
+
+// Invalidate asks Run to reload the index without waiting for the next interval
+func (c *AlertCache) Invalidate() {
+	select {
+	case c.invalidated <- struct{}{}:
+	default:
+		// A reload is already pending
+	}
+}
+
+// Run loads the index and keeps reloading it until ctx is done
+func (c *AlertCache) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Refresh(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Failed to refresh the active alert index; keeping the previous one", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.invalidated:
+		}
+	}
+}
+
+// ForSymbol returns the active alerts watching a symbol. The slice is shared
+// and must not be modified.
+func (c *AlertCache) ForSymbol(symbol string) []dto.AlertResponse {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.bySymbol[symbol]
+}
+
+// LoadedAt returns when the index was last reloaded successfully
+func (c *AlertCache) LoadedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loadedAt
+}
+
+// snapshot returns the index as of its last reload, with its sync state. A
+// reload replaces the map rather than changing it, so the map may be read
+// without the lock but must not be modified.
+func (c *AlertCache) snapshot() (map[string][]dto.AlertResponse, dto.AlertIndexSync) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	status := dto.AlertIndexSync{
+		LoadedAt:        c.loadedAt,
+		Generation:      c.generation,
+		RefreshInterval: c.interval.String(),
+	}
+	if c.lastError != nil {
+		status.LastError = c.lastError.Error()
+		lastErrorAt := c.lastErrorAt
+		status.LastErrorAt = &lastErrorAt
+	}
+	return c.bySymbol, status
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/pkg/money"
+)
+
+// failingAlertRepository fails FindActive while err is set
+type failingAlertRepository struct {
+	domain.AlertRepository
+	err error
+}
+
+func (r *failingAlertRepository) FindActive(ctx context.Context) ([]dto.AlertResponse, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.AlertRepository.FindActive(ctx)
+}
+
+func alertRequest(symbol string, status dto.AlertStatus) *dto.AlertCreateRequest {
+	return &dto.AlertCreateRequest{UserID: "alice", Symbol: symbol, Rule: dto.AlertRuleAbove, Price: money.FromFloat(100), Status: status}
+}
+
+// A refresh picks up a newly activated alert, drops a deactivated one, and
+// keeps the previous index when the repository fails
+func TestAlertCacheRefresh(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	repo := &failingAlertRepository{AlertRepository: alerts}
+	cache := NewAlertCache(repo, time.Minute)
+
+	active, _ := alerts.Create(ctx, alertRequest("gp", dto.AlertStatusActive))
+	inactive, _ := alerts.Create(ctx, alertRequest("BATBC", dto.AlertStatusInactive))
+	alerts.Create(ctx, alertRequest("", dto.AlertStatusActive))
+	if err := cache.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := cache.ForSymbol("GP"); len(got) != 1 || got[0].ID != active.ID {
+		t.Fatalf("GP is watched by %v, want %s", got, active.ID)
+	}
+	if got := cache.ForSymbol("BATBC"); len(got) != 0 {
+		t.Fatalf("BATBC is watched by %v before its alert is activated", got)
+	}
+
+	if _, err := alerts.Update(ctx, inactive.ID, alertRequest("BATBC", dto.AlertStatusActive)); err != nil {
+		t.Fatal(err)
+	}
+	alerts.Deactivate(ctx, []string{active.ID})
+	if err := cache.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := cache.ForSymbol("BATBC"); len(got) != 1 || got[0].ID != inactive.ID {
+		t.Errorf("BATBC is watched by %v after activation, want %s", got, inactive.ID)
+	}
+	if got := cache.ForSymbol("GP"); len(got) != 0 {
+		t.Errorf("GP is still watched by %v after deactivation", got)
+	}
+
+	repo.err = errors.New("connection refused")
+	loadedAt := cache.LoadedAt()
+	if err := cache.Refresh(ctx); err == nil {
+		t.Fatal("refresh succeeded while the repository failed")
+	}
+	index, status := cache.snapshot()
+	if len(index["BATBC"]) != 1 || !cache.LoadedAt().Equal(loadedAt) || status.Generation != 2 || status.LastError != "connection refused" {
+		t.Errorf("got %v and %+v after a failed refresh, want the previous index and the error", index, status)
+	}
+}
+
+// Matching a tick against the cached index is a map lookup; the repository
+// query it replaces scans every active alert
+func BenchmarkAlertLookup(b *testing.B) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	for i := 0; i < 5000; i++ {
+		alerts.Create(ctx, alertRequest(fmt.Sprintf("SYM%d", i%500), dto.AlertStatusActive))
+	}
+	cache := NewAlertCache(alerts, time.Minute)
+	if err := cache.Refresh(ctx); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			symbol := fmt.Sprintf("SYM%d", i%500)
+			active, _ := alerts.FindActive(ctx)
+			watching := 0
+			for _, alert := range active {
+				if strings.EqualFold(alert.Symbol, symbol) {
+					watching++
+				}
+			}
+		}
+	})
+	b.Run("cache", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cache.ForSymbol(fmt.Sprintf("SYM%d", i%500))
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/metrics"
+)
+
+const (
+	// MaxAlertChangeWait caps how long a change feed poll may hold the request;
+	// it stays under the server's 15s write timeout
+	MaxAlertChangeWait = 10 * time.Second
+	// alertChangePageSize bounds the changes returned by one poll
+	alertChangePageSize = 500
+	// alertChangePollInterval is how often a waiting poll rechecks the store for
+	// changes written by other replicas
+	alertChangePollInterval = time.Second
+)
+
+// AlertChangeFeed records alert mutations under a monotonic cursor so
+// consumers such as the dataFeed can follow alert changes instead of
+// reloading every alert. Cursors are allocated and appended under one lock,
+// so changes made through this process are stored in cursor order.
+type AlertChangeFeed struct {
+	repo domain.AlertChangeRepository
+
+	mu sync.Mutex
+	// appended is closed and replaced after every append to wake waiting polls
+	appended chan struct{}
+}
+
+func NewAlertChangeFeed(repo domain.AlertChangeRepository) *AlertChangeFeed {
+	metrics.Default.Describe("alert_changes_total", "Alert changes recorded in the change feed, by change type")
+	return &AlertChangeFeed{repo: repo, appended: make(chan struct{})}
+}
+
+// Record appends a change for the alert to the feed
+func (f *AlertChangeFeed) Record(ctx context.Context, alert dto.AlertResponse, change dto.AlertChangeType) (*dto.AlertChangeResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cursor, err := f.repo.NextCursor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := f.repo.Append(ctx, &dto.AlertChangeRequest{
+		Cursor: cursor,
+		Alert:  alert,
+		Change: change,
+		At:     time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.Default.Counter("alert_changes_total", map[string]string{"change": string(change)}).Inc()
+	close(f.appended)
+	f.appended = make(chan struct{})
+	return recorded, nil
+}
+
+// Since returns the changes after the cursor. When there are none it waits up
+// to wait for one to be recorded before returning an empty page.
+func (f *AlertChangeFeed) Since(ctx context.Context, since int64, wait time.Duration) (*dto.AlertChangesResponse, error) {
+	if wait > MaxAlertChangeWait {
+		wait = MaxAlertChangeWait
+	}
+	deadline := time.Now().Add(wait)
+	ticker := time.NewTicker(alertChangePollInterval)
+	defer ticker.Stop()
+
+	for {
+		f.mu.Lock()
+		appended := f.appended
+		f.mu.Unlock()
+
+		changes, err := f.repo.Since(ctx, since, alertChangePageSize+1)
+		if err != nil {
+			return nil, err
+		}
+		remaining := time.Until(deadline)
+		if len(changes) > 0 || remaining <= 0 {
+			return newAlertChangesPage(since, changes), nil
+		}
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-appended:
+		case <-ticker.C:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// newAlertChangesPage trims changes to one page and sets the next cursor
+func newAlertChangesPage(since int64, changes []dto.AlertChangeResponse) *dto.AlertChangesResponse {
+	page := &dto.AlertChangesResponse{Changes: changes, Cursor: since}
+	if len(changes) > alertChangePageSize {
+		page.Changes = changes[:alertChangePageSize]
+		page.HasMore = true
+	}
+	if len(page.Changes) > 0 {
+		page.Cursor = page.Changes[len(page.Changes)-1].Cursor
+	}
+	return page
+}
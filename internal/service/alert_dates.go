@@ -0,0 +1,109 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+)
+
+const (
+	// DefaultAlertStartMaxPast is how far back an alert's start date may be
+	DefaultAlertStartMaxPast = 365 * 24 * time.Hour
+	// DefaultAlertStartMaxFuture is how far ahead an alert's start date may be
+	DefaultAlertStartMaxFuture = 5 * 365 * 24 * time.Hour
+	// DefaultAlertMaxWindow is the longest active window an alert may be given
+	DefaultAlertMaxWindow = 5 * 365 * 24 * time.Hour
+)
+
+// AlertDateBounds keeps an alert's active window plausible, so a mistyped
+// year cannot make an alert that never fires or fires for decades. A zero
+// bound turns its check off.
+type AlertDateBounds struct {
+	// StartMaxPast and StartMaxFuture bound the start date around now
+	StartMaxPast   time.Duration
+	StartMaxFuture time.Duration
+	// MaxWindow bounds the stop date from the start date, or from now for an
+	// alert that starts right away. It is not applied to an alert given no
+	// stop date, which stays open-ended.
+	MaxWindow time.Duration
+}
+
+// DefaultAlertDateBounds allows starts within a year back and five years
+// ahead, and windows of up to five years
+func DefaultAlertDateBounds() AlertDateBounds {
+	return AlertDateBounds{
+		StartMaxPast:   DefaultAlertStartMaxPast,
+		StartMaxFuture: DefaultAlertStartMaxFuture,
+		MaxWindow:      DefaultAlertMaxWindow,
+	}
+}
+
+// LoadAlertDateBounds overrides the defaults from ALERT_START_MAX_PAST,
+// ALERT_START_MAX_FUTURE and ALERT_MAX_WINDOW (e.g. "8760h", 0 to turn a
+// check off)
+func LoadAlertDateBounds() (AlertDateBounds, error) {
+	bounds := DefaultAlertDateBounds()
+	for _, setting := range []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"ALERT_START_MAX_PAST", &bounds.StartMaxPast},
+		{"ALERT_START_MAX_FUTURE", &bounds.StartMaxFuture},
+		{"ALERT_MAX_WINDOW", &bounds.MaxWindow},
+	} {
+		raw := os.Getenv(setting.name)
+		if raw == "" {
+			continue
+		}
+		bound, err := time.ParseDuration(raw)
+		if err != nil || bound < 0 {
+			return bounds, fmt.Errorf("%s must be a duration of 0 or more, got %q", setting.name, raw)
+		}
+		*setting.target = bound
+	}
+	return bounds, nil
+}
+
+// Check rejects an active window outside the bounds with ErrValidation. A
+// zero start date means the alert starts at now and a zero stop date that it
+// never stops.
+func (b AlertDateBounds) Check(start, stop, now time.Time) error {
+	if !start.IsZero() {
+		if b.StartMaxPast > 0 && start.Before(now.Add(-b.StartMaxPast)) {
+			return fmt.Errorf("startDate %s is more than %s in the past: %w", start.Format(time.DateOnly), describeDays(b.StartMaxPast), domain.ErrValidation)
+		}
+		if b.StartMaxFuture > 0 && start.After(now.Add(b.StartMaxFuture)) {
+			return fmt.Errorf("startDate %s is more than %s ahead: %w", start.Format(time.DateOnly), describeDays(b.StartMaxFuture), domain.ErrValidation)
+		}
+	}
+	if stop.IsZero() {
+		return nil
+	}
+	from := start
+	if from.IsZero() {
+		from = now
+		if !stop.After(now) {
+			return fmt.Errorf("stopDate %s has passed: %w", stop.Format(time.DateOnly), domain.ErrValidation)
+		}
+	} else if !stop.After(start) {
+		return fmt.Errorf("stopDate must be after startDate: %w", domain.ErrValidation)
+	}
+	if b.MaxWindow > 0 && stop.Sub(from) > b.MaxWindow {
+		return fmt.Errorf("stopDate %s is more than %s after the alert starts: %w", stop.Format(time.DateOnly), describeDays(b.MaxWindow), domain.ErrValidation)
+	}
+	return nil
+}
+
+// describeDays words a bound in whole days, e.g. "365 days"
+func describeDays(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	if days == 1 {
+		return "1 day"
+	}
+	if days == 0 {
+		return d.String()
+	}
+	return fmt.Sprintf("%d days", days)
+}
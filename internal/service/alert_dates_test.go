@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/pkg/money"
+)
+
+var datesNow = time.Date(2024, 3, 4, 5, 0, 0, 0, time.UTC)
+
+// Starts are kept within their bounds around now, and stops after the start,
+// or after now for an alert that starts right away, within the window
+func TestAlertDateBoundsCheck(t *testing.T) {
+	day := 24 * time.Hour
+	bounds := DefaultAlertDateBounds()
+	for _, tc := range []struct {
+		name    string
+		bounds  AlertDateBounds
+		start   time.Time
+		stop    time.Time
+		wantErr bool
+	}{
+		{name: "valid range", bounds: bounds, start: datesNow.Add(-30 * day), stop: datesNow.Add(30 * day)},
+		{name: "open ended", bounds: bounds},
+		{name: "start too far past", bounds: bounds, start: datesNow.Add(-366 * day), wantErr: true},
+		{name: "start at the past bound", bounds: bounds, start: datesNow.Add(-365 * day)},
+		{name: "start too far ahead", bounds: bounds, start: datesNow.Add(5*365*day + time.Minute), wantErr: true},
+		{name: "stop before start", bounds: bounds, start: datesNow.Add(day), stop: datesNow, wantErr: true},
+		{name: "stop at start", bounds: bounds, start: datesNow.Add(day), stop: datesNow.Add(day), wantErr: true},
+		{name: "stop passed without a start", bounds: bounds, stop: datesNow.Add(-day), wantErr: true},
+		{name: "stop at now without a start", bounds: bounds, stop: datesNow, wantErr: true},
+		{name: "stop ahead without a start", bounds: bounds, stop: datesNow.Add(day)},
+		{name: "window too long", bounds: bounds, start: datesNow.Add(day), stop: datesNow.Add(day + 5*365*day + time.Minute), wantErr: true},
+		{name: "window too long from now", bounds: bounds, stop: datesNow.Add(5*365*day + time.Minute), wantErr: true},
+		{name: "bounds off", start: datesNow.Add(-20 * 365 * day), stop: datesNow.Add(20 * 365 * day)},
+		{name: "bounds off, stop passed", stop: datesNow.Add(-day), wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.bounds.Check(tc.start, tc.stop, datesNow)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got %v, want error %t", err, tc.wantErr)
+			}
+			if err != nil && !errors.Is(err, domain.ErrValidation) {
+				t.Errorf("got %v, want ErrValidation", err)
+			}
+		})
+	}
+}
+
+// The service refuses alerts outside the bounds and stores the dates it was
+// given, leaving an alert without a stop date open-ended
+func TestCreateAlertDateBounds(t *testing.T) {
+	ctx := context.Background()
+	alerts := NewAlertService(repository.NewMemoryAlertRepository(), nil, nil, MarketSchedule{}, nil, nil, nil, nil, nil, DefaultAlertDateBounds())
+	request := func(start, stop time.Time) dto.AlertCreateRequest {
+		return dto.AlertCreateRequest{UserID: "alice", Symbol: "GP", Rule: dto.AlertRuleAbove, Price: money.FromFloat(100),
+			Status: dto.AlertStatusActive, StartDate: start, StopDate: stop}
+	}
+	now := time.Now().UTC()
+
+	for _, tc := range []struct {
+		name  string
+		start time.Time
+	}{
+		{name: "past bound", start: now.AddDate(-2, 0, 0)},
+		{name: "future bound", start: now.AddDate(6, 0, 0)},
+	} {
+		if _, err := alerts.CreateAlert(ctx, request(tc.start, time.Time{})); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("%s: got %v, want ErrValidation", tc.name, err)
+		}
+	}
+
+	start := now.AddDate(0, 1, 0)
+	for _, tc := range []struct {
+		name string
+		stop time.Time
+	}{
+		{name: "valid range", stop: start.AddDate(1, 0, 0)},
+		{name: "open ended", stop: time.Time{}},
+	} {
+		created, err := alerts.CreateAlert(ctx, request(start, tc.stop))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !created.StartDate.Equal(start) || !created.StopDate.Equal(tc.stop) {
+			t.Errorf("%s: got %v to %v, want %v to %v", tc.name, created.StartDate, created.StopDate, start, tc.stop)
+		}
+	}
+}
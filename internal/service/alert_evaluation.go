@@ -0,0 +1,253 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/money"
+)
+
+// Names of the gates an alert evaluation goes through, in order
+const (
+	GateStatus      = "status"
+	GateWindow      = "window"
+	GateMarketHours = "market_hours"
+	GateThreshold   = "threshold"
+)
+
+// EvaluateAlert decides whether alert fires for price observed at the given time,
+// during the given market session. day is the latest stored price of the alert's
+// symbol, which percent rules take their baseline from; it may be nil.
+// Every gate is checked even after one fails, so the result explains all the
+// reasons an alert stays quiet. It has no side effects.
+func EvaluateAlert(alert dto.AlertResponse, session dto.MarketSession, price money.Amount, day *dto.LatestPriceResponse, at time.Time) dto.AlertEvaluationResponse {
+	result := dto.AlertEvaluationResponse{
+		AlertID:       alert.ID,
+		Rule:          alert.Rule,
+		Threshold:     alert.Price,
+		ObservedPrice: price,
+		ObservedAt:    at,
+	}
+
+	result.Gates = append(result.Gates,
+		statusGate(alert),
+		windowGate(alert, at),
+		marketHoursGate(alert, session),
+		thresholdGate(alert, price, day, session.TradingDate),
+	)
+
+	result.WouldFire = true
+	for _, gate := range result.Gates {
+		if !gate.Passed {
+			result.WouldFire = false
+		}
+	}
+	return result
+}
+
+// TickDecision is what a tick does to an alert
+type TickDecision int
+
+const (
+	// TickUnchanged leaves the alert as it is
+	TickUnchanged TickDecision = iota
+	// TickFires fires an armed alert
+	TickFires
+	// TickRearms re-arms a triggered alert
+	TickRearms
+)
+
+// DecideTick is the tick evaluator's decision for one alert: an armed alert
+// fires when the tick meets its threshold within its window, and a triggered
+// one re-arms once a tick no longer meets it. latest is the symbol's latest
+// price after the tick. Backtests decide with it too, so they cannot diverge
+// from live evaluation. The threshold gate explains the decision.
+func DecideTick(alert dto.AlertResponse, triggered bool, price money.Amount, at time.Time, latest *dto.LatestPriceResponse, tradingDate string) (TickDecision, dto.EvaluationGate) {
+	gate := thresholdGate(alert, price, latest, tradingDate)
+	met := gate.Passed && windowGate(alert, at).Passed
+	switch {
+	case met == triggered:
+		return TickUnchanged, gate
+	case met:
+		return TickFires, gate
+	}
+	return TickRearms, gate
+}
+
+// SkipsOutsideMarketHours reports whether a trigger of the alert in the session
+// is skipped, as RecordTrigger does for alerts that do not evaluate off hours
+func SkipsOutsideMarketHours(alert dto.AlertResponse, session dto.MarketSession) bool {
+	return !marketHoursGate(alert, session).Passed
+}
+
+func statusGate(alert dto.AlertResponse) dto.EvaluationGate {
+	if alert.Status != dto.AlertStatusActive {
+		return dto.EvaluationGate{Name: GateStatus, Reason: fmt.Sprintf("alert is %s", alert.Status)}
+	}
+	return dto.EvaluationGate{Name: GateStatus, Passed: true, Reason: "alert is active"}
+}
+
+// windowGate checks the alert's start and stop dates; a zero date leaves that side open
+func windowGate(alert dto.AlertResponse, at time.Time) dto.EvaluationGate {
+	gate := dto.EvaluationGate{Name: GateWindow}
+	switch {
+	case !alert.StartDate.IsZero() && at.Before(alert.StartDate):
+		gate.Reason = fmt.Sprintf("%s is before the start date %s",
+			at.Format(time.RFC3339), alert.StartDate.Format(time.RFC3339))
+	case !alert.StopDate.IsZero() && at.After(alert.StopDate):
+		gate.Reason = fmt.Sprintf("%s is after the stop date %s",
+			at.Format(time.RFC3339), alert.StopDate.Format(time.RFC3339))
+	default:
+		gate.Passed = true
+		gate.Reason = "within the alert window"
+	}
+	return gate
+}
+
+// marketHoursGate skips alerts outside trading hours unless they opt in with EvaluateOffHours
+func marketHoursGate(alert dto.AlertResponse, session dto.MarketSession) dto.EvaluationGate {
+	switch {
+	case session.Open:
+		return dto.EvaluationGate{Name: GateMarketHours, Passed: true, Reason: session.Detail}
+	case alert.EvaluateOffHours:
+		return dto.EvaluationGate{Name: GateMarketHours, Passed: true, Reason: session.Detail + "; alert evaluates off hours"}
+	}
+	return dto.EvaluationGate{Name: GateMarketHours, Reason: session.Detail}
+}
+
+func thresholdGate(alert dto.AlertResponse, price money.Amount, day *dto.LatestPriceResponse, tradingDate string) dto.EvaluationGate {
+	if alert.Rule.IsPercentRule() {
+		return percentGate(alert, price, day, tradingDate)
+	}
+
+	gate := dto.EvaluationGate{Name: GateThreshold}
+	switch alert.Rule {
+	case dto.AlertRuleAbove:
+		gate.Passed = price >= alert.Price
+	case dto.AlertRuleBelow:
+		gate.Passed = price <= alert.Price
+	default:
+		gate.Reason = fmt.Sprintf("unknown rule %q", alert.Rule)
+		return gate
+	}
+	if gate.Passed {
+		gate.Reason = fmt.Sprintf("price %s is %s %s", price, alert.Rule, alert.Price)
+	} else {
+		gate.Reason = fmt.Sprintf("price %s is not %s %s", price, alert.Rule, alert.Price)
+	}
+	return gate
+}
+
+// percentGate compares the move from the alert's baseline with the alert's
+// percentage, upwards for percent_change_above and downwards for percent_change_below.
+// The percentage is held in hundredths of a percent like any price, so the
+// comparison cross-multiplies in paisa and a move of exactly the percentage
+// meets it.
+func percentGate(alert dto.AlertResponse, price money.Amount, day *dto.LatestPriceResponse, tradingDate string) dto.EvaluationGate {
+	gate := dto.EvaluationGate{Name: GateThreshold}
+	baseline, ok := PercentBaseline(alert.Baseline, day, tradingDate)
+	if !ok {
+		gate.Reason = fmt.Sprintf("no %s recorded for %s on %s", baselineName(alert.Baseline), alert.Symbol, tradingDate)
+		return gate
+	}
+
+	// move/baseline*100 >= percent/100, with both sides times 100*baseline
+	move := int64(price-baseline) * money.Scale * 100
+	threshold := int64(alert.Price) * int64(baseline)
+	if alert.Rule == dto.AlertRulePercentChangeAbove {
+		gate.Passed = move >= threshold
+	} else {
+		gate.Passed = move <= -threshold
+	}
+	change := float64(price-baseline) / float64(baseline) * 100
+	verb := "has"
+	if !gate.Passed {
+		verb = "has not"
+	}
+	direction := "up"
+	if alert.Rule == dto.AlertRulePercentChangeBelow {
+		direction = "down"
+	}
+	gate.Reason = fmt.Sprintf("price %s is %+.2f%% from the %s %s and %s moved %s%% %s",
+		price, change, baselineName(alert.Baseline), baseline, verb, alert.Price, direction)
+	return gate
+}
+
+// DistanceToTrigger returns how far, in percent of the latest price, the price
+// still has to rise for above rules or fall for below rules to meet the alert;
+// it is zero or negative once the rule is met. Percent rules measure from the
+// price their baseline and percentage make, and report false without a baseline.
+func DistanceToTrigger(alert dto.AlertResponse, day *dto.LatestPriceResponse, tradingDate string) (float64, bool) {
+	if day == nil || day.Price <= 0 {
+		return 0, false
+	}
+	target := alert.Price.Float()
+	if alert.Rule.IsPercentRule() {
+		baseline, ok := PercentBaseline(alert.Baseline, day, tradingDate)
+		if !ok {
+			return 0, false
+		}
+		if alert.Rule == dto.AlertRulePercentChangeAbove {
+			target = baseline.Float() * (1 + alert.Price.Float()/100)
+		} else {
+			target = baseline.Float() * (1 - alert.Price.Float()/100)
+		}
+	}
+
+	distance := (target - day.Price.Float()) / day.Price.Float() * 100
+	switch alert.Rule {
+	case dto.AlertRuleAbove, dto.AlertRulePercentChangeAbove:
+		return distance, true
+	case dto.AlertRuleBelow, dto.AlertRulePercentChangeBelow:
+		return -distance, true
+	}
+	return 0, false
+}
+
+// triggerPrice returns the price an alert fires at and, for percent rules,
+// the baseline it is measured from, rounded to the paisa. Percent rules report
+// false without a baseline.
+func triggerPrice(alert dto.AlertResponse, day *dto.LatestPriceResponse, tradingDate string) (trigger, baseline money.Amount, ok bool) {
+	if !alert.Rule.IsPercentRule() {
+		return alert.Price, 0, true
+	}
+	baseline, ok = PercentBaseline(alert.Baseline, day, tradingDate)
+	if !ok {
+		return 0, 0, false
+	}
+	percent := alert.Price.Float() / 100
+	if alert.Rule == dto.AlertRulePercentChangeBelow {
+		percent = -percent
+	}
+	return money.FromFloat(baseline.Float() * (1 + percent)), baseline, true
+}
+
+// PercentBaseline returns the reference price of a percent rule on the given
+// trading date. When the latest stored price belongs to an earlier trading date,
+// the day has rolled over without a tick yet: its last price is the previous
+// close and there is no day open.
+func PercentBaseline(baseline dto.AlertBaseline, day *dto.LatestPriceResponse, tradingDate string) (money.Amount, bool) {
+	if day == nil {
+		return 0, false
+	}
+	rolledOver := day.TradingDate < tradingDate
+	switch {
+	case baseline == dto.AlertBaselineDayOpen && !rolledOver:
+		return day.DayOpen, day.DayOpen > 0
+	case baseline == dto.AlertBaselineDayOpen:
+		return 0, false
+	case rolledOver:
+		return day.Price, day.Price > 0
+	case day.PreviousClose != nil:
+		return *day.PreviousClose, *day.PreviousClose > 0
+	}
+	return 0, false
+}
+
+func baselineName(baseline dto.AlertBaseline) string {
+	if baseline == dto.AlertBaselineDayOpen {
+		return "day open"
+	}
+	return "previous close"
+}
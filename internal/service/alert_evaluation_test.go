@@ -0,0 +1,268 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/mapper"
+	"github.com/hello-api/internal/repository/entity"
+	"github.com/hello-api/pkg/money"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var evaluationTime = time.Date(2024, 3, 4, 5, 0, 0, 0, time.UTC)
+
+// gateResults returns whether each gate of an evaluation passed, by name
+func gateResults(result dto.AlertEvaluationResponse) map[string]bool {
+	passed := make(map[string]bool, len(result.Gates))
+	for _, gate := range result.Gates {
+		passed[gate.Name] = gate.Passed
+	}
+	return passed
+}
+
+// Every gate is reported even after one fails, and the alert fires only when
+// all of them pass
+func TestEvaluateAlert(t *testing.T) {
+	active := dto.AlertResponse{ID: "a1", Symbol: "GP", Rule: dto.AlertRuleAbove, Price: money.FromFloat(350), Status: dto.AlertStatusActive}
+	open := dto.MarketSession{Open: true, Detail: "market is open", TradingDate: "2024-03-04"}
+	closed := dto.MarketSession{Reason: "after_close", Detail: "market closed at 14:30", TradingDate: "2024-03-04"}
+
+	for _, tc := range []struct {
+		name    string
+		alert   func(dto.AlertResponse) dto.AlertResponse
+		session dto.MarketSession
+		price   float64
+		want    map[string]bool
+	}{
+		{
+			name:    "fires",
+			session: open,
+			price:   350,
+			want:    map[string]bool{GateStatus: true, GateWindow: true, GateMarketHours: true, GateThreshold: true},
+		},
+		{
+			name:    "below the threshold",
+			session: open,
+			price:   349.99,
+			want:    map[string]bool{GateStatus: true, GateWindow: true, GateMarketHours: true, GateThreshold: false},
+		},
+		{
+			name: "inactive, expired and closed at once",
+			alert: func(a dto.AlertResponse) dto.AlertResponse {
+				a.Status = dto.AlertStatusInactive
+				a.StopDate = evaluationTime.Add(-time.Hour)
+				return a
+			},
+			session: closed,
+			price:   351,
+			want:    map[string]bool{GateStatus: false, GateWindow: false, GateMarketHours: false, GateThreshold: true},
+		},
+		{
+			name: "before the start date",
+			alert: func(a dto.AlertResponse) dto.AlertResponse {
+				a.StartDate = evaluationTime.Add(time.Hour)
+				return a
+			},
+			session: open,
+			price:   351,
+			want:    map[string]bool{GateStatus: true, GateWindow: false, GateMarketHours: true, GateThreshold: true},
+		},
+		{
+			name: "evaluates off hours",
+			alert: func(a dto.AlertResponse) dto.AlertResponse {
+				a.EvaluateOffHours = true
+				return a
+			},
+			session: closed,
+			price:   351,
+			want:    map[string]bool{GateStatus: true, GateWindow: true, GateMarketHours: true, GateThreshold: true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			alert := active
+			if tc.alert != nil {
+				alert = tc.alert(alert)
+			}
+			result := EvaluateAlert(alert, tc.session, money.FromFloat(tc.price), nil, evaluationTime)
+			if len(result.Gates) != len(tc.want) {
+				t.Fatalf("got %d gates, want %d", len(result.Gates), len(tc.want))
+			}
+			wouldFire := true
+			for name, passed := range gateResults(result) {
+				if passed != tc.want[name] {
+					t.Errorf("%s gate passed %v, want %v", name, passed, tc.want[name])
+				}
+				wouldFire = wouldFire && passed
+			}
+			if result.WouldFire != wouldFire {
+				t.Errorf("wouldFire %v, want %v", result.WouldFire, wouldFire)
+			}
+			for _, gate := range result.Gates {
+				if gate.Reason == "" {
+					t.Errorf("%s gate has no reason", gate.Name)
+				}
+			}
+		})
+	}
+}
+
+// An armed alert fires when a tick meets it, and a triggered one re-arms once a
+// tick no longer does
+func TestDecideTick(t *testing.T) {
+	alert := dto.AlertResponse{ID: "a1", Symbol: "GP", Rule: dto.AlertRuleBelow, Price: money.FromFloat(100), Status: dto.AlertStatusActive}
+	for _, tc := range []struct {
+		triggered bool
+		price     float64
+		want      TickDecision
+	}{
+		{triggered: false, price: 100.01, want: TickUnchanged},
+		{triggered: false, price: 100, want: TickFires},
+		{triggered: true, price: 99, want: TickUnchanged},
+		{triggered: true, price: 100.01, want: TickRearms},
+	} {
+		if got, _ := DecideTick(alert, tc.triggered, money.FromFloat(tc.price), evaluationTime, nil, "2024-03-04"); got != tc.want {
+			t.Errorf("triggered %v at %v: got %v, want %v", tc.triggered, tc.price, got, tc.want)
+		}
+	}
+
+	// Outside its window an armed alert never fires
+	alert.StopDate = evaluationTime.Add(-time.Minute)
+	if got, _ := DecideTick(alert, false, money.FromFloat(90), evaluationTime, nil, "2024-03-04"); got != TickUnchanged {
+		t.Errorf("got %v after the stop date, want TickUnchanged", got)
+	}
+}
+
+// A price keeps every paisa from the create request through storage to the
+// tick decision, including a price stored as a double before the decimal
+// migration
+func TestPriceRoundTrip(t *testing.T) {
+	var req dto.AlertCreateRequest
+	if err := json.Unmarshal([]byte(`{"symbol":"GP","rule":"above","price":"123.45","status":"active"}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	created := mapper.AlertToEntity(&req)
+	raw, err := bson.Marshal(created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var legacy bson.M
+	if err := bson.Unmarshal(raw, &legacy); err != nil {
+		t.Fatal(err)
+	}
+	legacy["price"] = 123.45
+	legacyRaw, err := bson.Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, stored := range map[string][]byte{"decimal": raw, "double": legacyRaw} {
+		var read entity.AlertEntity
+		if err := bson.Unmarshal(stored, &read); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		alert := *mapper.AlertToDTO(&read)
+		if alert.Price != 12345 {
+			t.Errorf("%s: read %s, want 123.45", name, alert.Price)
+		}
+		for _, tc := range []struct {
+			price string
+			want  TickDecision
+		}{
+			{price: "123.45", want: TickFires},
+			{price: "123.44", want: TickUnchanged},
+		} {
+			price, _ := money.Parse(tc.price)
+			if got, _ := DecideTick(alert, false, price, evaluationTime, nil, "2024-03-04"); got != tc.want {
+				t.Errorf("%s: got %v at %s, want %v", name, got, tc.price, tc.want)
+			}
+		}
+	}
+}
+
+// Percent rules measure from the previous close or the day open; on a trading
+// date without a tick yet the last stored price is the previous close and
+// there is no day open
+func TestPercentBaseline(t *testing.T) {
+	previousClose := money.FromFloat(100)
+	day := &dto.LatestPriceResponse{Symbol: "GP", Price: money.FromFloat(104), TradingDate: "2024-03-04",
+		DayOpen: money.FromFloat(102), PreviousClose: &previousClose}
+
+	for _, tc := range []struct {
+		name        string
+		baseline    dto.AlertBaseline
+		tradingDate string
+		want        float64
+		ok          bool
+	}{
+		{name: "previous close", baseline: dto.AlertBaselinePreviousClose, tradingDate: "2024-03-04", want: 100, ok: true},
+		{name: "day open", baseline: dto.AlertBaselineDayOpen, tradingDate: "2024-03-04", want: 102, ok: true},
+		{name: "previous close after rollover", baseline: dto.AlertBaselinePreviousClose, tradingDate: "2024-03-05", want: 104, ok: true},
+		{name: "day open after rollover", baseline: dto.AlertBaselineDayOpen, tradingDate: "2024-03-05", ok: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := PercentBaseline(tc.baseline, day, tc.tradingDate)
+			if ok != tc.ok || (ok && got != money.FromFloat(tc.want)) {
+				t.Errorf("got %s (%v), want %v (%v)", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+	if _, ok := PercentBaseline(dto.AlertBaselinePreviousClose, nil, "2024-03-04"); ok {
+		t.Error("a symbol without a stored price has a baseline")
+	}
+
+	// A 4% rise from the previous close meets exactly 4%, not 4.01%
+	alert := dto.AlertResponse{Symbol: "GP", Rule: dto.AlertRulePercentChangeAbove, Price: money.FromFloat(4), Status: dto.AlertStatusActive}
+	if _, gate := DecideTick(alert, false, money.FromFloat(104), evaluationTime, day, "2024-03-04"); !gate.Passed {
+		t.Errorf("4%% rule not met at +4%%: %s", gate.Reason)
+	}
+	alert.Price = money.FromFloat(4.01)
+	if _, gate := DecideTick(alert, false, money.FromFloat(104), evaluationTime, day, "2024-03-04"); gate.Passed {
+		t.Errorf("4.01%% rule met at +4%%: %s", gate.Reason)
+	}
+}
+
+// The distance to trigger is the move still needed, in percent of the latest
+// price, towards the rule; it turns negative once the rule is met
+func TestDistanceToTrigger(t *testing.T) {
+	previousClose := money.FromFloat(100)
+	day := &dto.LatestPriceResponse{Symbol: "GP", Price: money.FromFloat(104), TradingDate: "2024-03-04",
+		DayOpen: money.FromFloat(102), PreviousClose: &previousClose}
+
+	for _, tc := range []struct {
+		name        string
+		rule        dto.AlertRule
+		price       float64
+		baseline    dto.AlertBaseline
+		tradingDate string
+		want        float64
+		ok          bool
+	}{
+		{name: "above, not met", rule: dto.AlertRuleAbove, price: 114.4, want: 10, ok: true},
+		{name: "above, met", rule: dto.AlertRuleAbove, price: 93.6, want: -10, ok: true},
+		{name: "below, not met", rule: dto.AlertRuleBelow, price: 93.6, want: 10, ok: true},
+		{name: "below, met", rule: dto.AlertRuleBelow, price: 114.4, want: -10, ok: true},
+		{name: "percent above the previous close", rule: dto.AlertRulePercentChangeAbove, price: 14.4, want: 10, ok: true},
+		{name: "percent below the day open", rule: dto.AlertRulePercentChangeBelow, price: 10, baseline: dto.AlertBaselineDayOpen, want: 11.73, ok: true},
+		{name: "percent after rollover", rule: dto.AlertRulePercentChangeAbove, price: 10, tradingDate: "2024-03-05", want: 10, ok: true},
+		{name: "day open after rollover", rule: dto.AlertRulePercentChangeAbove, price: 10, baseline: dto.AlertBaselineDayOpen, tradingDate: "2024-03-05"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tradingDate := tc.tradingDate
+			if tradingDate == "" {
+				tradingDate = day.TradingDate
+			}
+			alert := dto.AlertResponse{Symbol: "GP", Rule: tc.rule, Price: money.FromFloat(tc.price), Baseline: tc.baseline}
+			got, ok := DistanceToTrigger(alert, day, tradingDate)
+			if ok != tc.ok || math.Abs(got-tc.want) > 0.01 {
+				t.Errorf("got %.4f (%v), want %.2f (%v)", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+	if _, ok := DistanceToTrigger(dto.AlertResponse{Rule: dto.AlertRuleAbove, Price: money.FromFloat(100)}, nil, "2024-03-04"); ok {
+		t.Error("a symbol without a stored price has a distance to trigger")
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/metrics"
+)
+
+const (
+	// DefaultOrphanScanLimit is how many alerts one chunk of the orphan scan reads
+	DefaultOrphanScanLimit = 1000
+	// MaxOrphanScanLimit bounds a chunk so a request stays within its budget
+	MaxOrphanScanLimit = 5000
+)
+
+// AlertMaintenanceService finds alerts whose userId matches no user, such as
+// alerts created before userIds were checked or left behind by deleted users.
+// Orphans are switched off rather than deleted, so they can still be inspected.
+type AlertMaintenanceService struct {
+	alerts  domain.AlertRepository
+	users   domain.UserRepository
+	cache   *AlertCache
+	changes *AlertChangeFeed
+}
+
+func NewAlertMaintenanceService(alerts domain.AlertRepository, users domain.UserRepository, cache *AlertCache, changes *AlertChangeFeed) *AlertMaintenanceService {
+	metrics.Default.Describe("orphaned_alerts_deactivated_total", "Alerts switched off because their user does not exist")
+	return &AlertMaintenanceService{alerts: alerts, users: users, cache: cache, changes: changes}
+}
+
+// CleanupOrphanedAlerts scans the chunk of alerts after req.Cursor and looks
+// its distinct userIds up in one query. Each chunk is independent, so a scan
+// interrupted between chunks resumes from the last NextCursor.
+func (s *AlertMaintenanceService) CleanupOrphanedAlerts(ctx context.Context, req dto.OrphanedAlertsRequest) (*dto.OrphanedAlertsResponse, error) {
+	if req.Limit == 0 {
+		req.Limit = DefaultOrphanScanLimit
+	}
+	if req.Limit < 1 || req.Limit > MaxOrphanScanLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d: %w", MaxOrphanScanLimit, domain.ErrValidation)
+	}
+	alerts, err := s.alerts.FindAfter(ctx, req.Cursor, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+	result := &dto.OrphanedAlertsResponse{DryRun: req.DryRun, Scanned: len(alerts), Users: []dto.OrphanedUserSummary{}}
+	if int64(len(alerts)) == req.Limit {
+		result.NextCursor = alerts[len(alerts)-1].ID
+	}
+
+	byUser := make(map[string][]dto.AlertResponse)
+	var userIDs []string
+	for _, alert := range alerts {
+		if _, seen := byUser[alert.UserID]; !seen {
+			userIDs = append(userIDs, alert.UserID)
+		}
+		byUser[alert.UserID] = append(byUser[alert.UserID], alert)
+	}
+	var existing map[string]bool
+	if len(userIDs) > 0 {
+		if existing, err = s.users.ExistingUserIDs(ctx, userIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(userIDs)
+	for _, userID := range userIDs {
+		if existing[userID] {
+			continue
+		}
+		summary := dto.OrphanedUserSummary{UserID: userID, Alerts: len(byUser[userID])}
+		var active []dto.AlertResponse
+		for _, alert := range byUser[userID] {
+			if alert.Status == dto.AlertStatusActive {
+				active = append(active, alert)
+			}
+		}
+		summary.Active = len(active)
+		if !req.DryRun && len(active) > 0 {
+			if summary.Deactivated, err = s.deactivate(ctx, active); err != nil {
+				return nil, err
+			}
+		}
+		result.Orphaned += summary.Alerts
+		result.Deactivated += summary.Deactivated
+		result.Users = append(result.Users, summary)
+	}
+
+	logging.FromContext(ctx).Info("orphaned alert scan chunk",
+		"dry_run", req.DryRun, "cursor", req.Cursor, "next_cursor", result.NextCursor,
+		"scanned", result.Scanned, "orphaned", result.Orphaned,
+		"orphaned_users", len(result.Users), "deactivated", result.Deactivated)
+	return result, nil
+}
+
+// deactivate switches the alerts off and reports them on the change feed
+func (s *AlertMaintenanceService) deactivate(ctx context.Context, active []dto.AlertResponse) (int64, error) {
+	ids := make([]string, 0, len(active))
+	for _, alert := range active {
+		ids = append(ids, alert.ID)
+	}
+	changed, err := s.alerts.Deactivate(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	metrics.Default.Counter("orphaned_alerts_deactivated_total", nil).Add(changed)
+	if s.cache != nil {
+		s.cache.Invalidate()
+	}
+	if s.changes != nil {
+		for _, alert := range active {
+			alert.Status = dto.AlertStatusInactive
+			if _, err := s.changes.Record(ctx, alert, dto.AlertChangeUpdated); err != nil {
+				logging.FromContext(ctx).Error("failed to record alert change",
+					"alert_id", alert.ID, "change", dto.AlertChangeUpdated, "error", err)
+			}
+		}
+	}
+	return changed, nil
+}
@@ -1,34 +1,394 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hello-api/internal/common/timeutil"
 	"github.com/hello-api/internal/domain"
 	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/logging"
 )
 
 type AlertService struct {
-	repo domain.AlertRepository
+	repo     domain.AlertRepository
+	events   *Broadcaster
+	calendar domain.MarketCalendarService
+	// schedule gives backtested ticks their trading dates
+	schedule MarketSchedule
+	// prices give evaluations the latest price and day statistics of a symbol
+	prices domain.PriceRepository
+	// cache is reloaded when alerts change; nil when ticks are not evaluated
+	cache *AlertCache
+	// changes is the feed alert mutations are recorded in
+	changes *AlertChangeFeed
+	// symbols checks alert symbols against the reference data; nil accepts any symbol
+	symbols domain.SymbolValidator
+	// users tell whether an alert's owner has muted notifications
+	users domain.UserRepository
+	// dates bound the active window of created and updated alerts
+	dates AlertDateBounds
+}
+
+func NewAlertService(repo domain.AlertRepository, events *Broadcaster, calendar domain.MarketCalendarService, schedule MarketSchedule, prices domain.PriceRepository, cache *AlertCache, changes *AlertChangeFeed, symbols domain.SymbolValidator, users domain.UserRepository, dates AlertDateBounds) *AlertService {
+	return &AlertService{repo: repo, events: events, calendar: calendar, schedule: schedule, prices: prices, cache: cache, changes: changes, symbols: symbols, users: users, dates: dates}
+}
+
+// validateSymbol rejects an alert symbol unknown to the reference data
+func (s *AlertService) validateSymbol(ctx context.Context, symbol string) error {
+	if s.symbols == nil || symbol == "" {
+		return nil
+	}
+	return s.symbols.ValidateSymbol(ctx, symbol)
+}
+
+// recordChange appends a mutation to the change feed. The alert is already
+// stored, so a failure is logged rather than returned.
+func (s *AlertService) recordChange(ctx context.Context, alert *dto.AlertResponse, change dto.AlertChangeType) {
+	if s.changes == nil || alert == nil {
+		return
+	}
+	if _, err := s.changes.Record(ctx, *alert, change); err != nil {
+		logging.FromContext(ctx).Error("failed to record alert change",
+			"alert_id", alert.ID, "change", change, "error", err)
+	}
+}
+
+// GetChanges returns the alert changes after the since cursor
+func (s *AlertService) GetChanges(ctx context.Context, since int64, wait time.Duration) (*dto.AlertChangesResponse, error) {
+	if since < 0 {
+		return nil, fmt.Errorf("since must not be negative: %w", domain.ErrValidation)
+	}
+	return s.changes.Since(ctx, since, wait)
+}
+
+// invalidateCache reloads the tick evaluation index after an alert changed
+func (s *AlertService) invalidateCache() {
+	if s.cache != nil {
+		s.cache.Invalidate()
+	}
+}
+
+// normalizeAlert stores the active window in UTC and checks the notification
+// override and the symbol and baseline percent rules depend on
+func normalizeAlert(alert *dto.AlertCreateRequest) error {
+	alert.StartDate = timeutil.UTC(alert.StartDate)
+	alert.StopDate = timeutil.UTC(alert.StopDate)
+	alert.Symbol = strings.ToUpper(strings.TrimSpace(alert.Symbol))
+	if err := normalizeNotifyOverride(alert.NotifyOverride); err != nil {
+		return err
+	}
+	if !alert.Rule.IsPercentRule() {
+		if alert.Baseline != "" {
+			return fmt.Errorf("baseline only applies to percent rules: %w", domain.ErrValidation)
+		}
+		return nil
+	}
+	if alert.Symbol == "" {
+		return fmt.Errorf("%s alerts need a symbol: %w", alert.Rule, domain.ErrValidation)
+	}
+	if alert.Price <= 0 {
+		return fmt.Errorf("%s alerts need a positive percentage as price: %w", alert.Rule, domain.ErrValidation)
+	}
+	switch alert.Baseline {
+	case "":
+		alert.Baseline = dto.AlertBaselinePreviousClose
+	case dto.AlertBaselinePreviousClose, dto.AlertBaselineDayOpen:
+	default:
+		return fmt.Errorf("baseline must be previousClose or dayOpen, got %q: %w", alert.Baseline, domain.ErrValidation)
+	}
+	return nil
 }
 
-func NewAlertService(repo domain.AlertRepository) *AlertService {
-	return &AlertService{repo: repo}
+// normalizeNotifyOverride checks the override target suits its channel: an
+// http(s) URL for webhooks, a numeric chat ID for Telegram and a bare address
+// for email
+func normalizeNotifyOverride(override *dto.NotifyOverride) error {
+	if override == nil {
+		return nil
+	}
+	override.Target = strings.TrimSpace(override.Target)
+	if override.Target == "" {
+		return fmt.Errorf("notifyOverride needs a target: %w", domain.ErrValidation)
+	}
+	switch override.Channel {
+	case dto.NotificationChannelWebhook:
+		parsed, err := url.Parse(override.Target)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("notifyOverride webhook target must be an http or https URL, got %q: %w", override.Target, domain.ErrValidation)
+		}
+	case dto.NotificationChannelTelegram:
+		if _, err := strconv.ParseInt(override.Target, 10, 64); err != nil {
+			return fmt.Errorf("notifyOverride telegram target must be a chat ID, got %q: %w", override.Target, domain.ErrValidation)
+		}
+	case dto.NotificationChannelEmail:
+		email, err := normalizeEmail(override.Target)
+		if err != nil {
+			return fmt.Errorf("notifyOverride: %w", err)
+		}
+		override.Target = email
+	default:
+		return fmt.Errorf("notifyOverride channel must be webhook, telegram or email, got %q: %w", override.Channel, domain.ErrValidation)
+	}
+	return nil
 }
 
-func (s *AlertService) CreateAlert(alert dto.AlertCreateRequest) (*dto.AlertResponse, error) {
-	return s.repo.Create(&alert)
+// applyAlertDefaults fills the fields the request leaves unset from the
+// owner's alert defaults and records which ones it filled. The merged request
+// is validated like any other.
+func (s *AlertService) applyAlertDefaults(ctx context.Context, alert *dto.AlertCreateRequest) error {
+	alert.AppliedDefaults = nil
+	if s.users == nil || alert.UserID == "" {
+		return nil
+	}
+	owner, err := s.users.FindByUserID(ctx, alert.UserID)
+	if err != nil {
+		return err
+	}
+	if owner == nil || owner.AlertDefaults == nil {
+		return nil
+	}
+	defaults := owner.AlertDefaults
+	if alert.StopDate.IsZero() && defaults.DurationDays > 0 {
+		start := alert.StartDate
+		if start.IsZero() {
+			start = time.Now()
+		}
+		alert.StopDate = start.AddDate(0, 0, defaults.DurationDays)
+		alert.AppliedDefaults = append(alert.AppliedDefaults, "stopDate")
+	}
+	// Channels only apply to alerts that choose none of their own
+	if alert.WebhookURL != "" || alert.NotifyTelegram || alert.NotifyEmail || alert.NotifyOverride != nil {
+		return nil
+	}
+	for _, channel := range defaults.Channels {
+		switch dto.NotificationChannel(channel) {
+		case dto.NotificationChannelTelegram:
+			alert.NotifyTelegram = true
+			alert.AppliedDefaults = append(alert.AppliedDefaults, "notifyTelegram")
+		case dto.NotificationChannelEmail:
+			alert.NotifyEmail = true
+			alert.AppliedDefaults = append(alert.AppliedDefaults, "notifyEmail")
+		}
+	}
+	return nil
 }
 
-func (s *AlertService) GetAlertByID(id string) (*dto.AlertResponse, error) {
-	return s.repo.FindByID(id)
+func (s *AlertService) CreateAlert(ctx context.Context, alert dto.AlertCreateRequest) (*dto.AlertResponse, error) {
+	if err := s.applyAlertDefaults(ctx, &alert); err != nil {
+		return nil, err
+	}
+	if err := normalizeAlert(&alert); err != nil {
+		return nil, err
+	}
+	if err := s.dates.Check(alert.StartDate, alert.StopDate, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if err := s.validateSymbol(ctx, alert.Symbol); err != nil {
+		return nil, err
+	}
+	created, err := s.repo.Create(ctx, &alert)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateCache()
+	s.recordChange(ctx, created, dto.AlertChangeCreated)
+	logging.FromContext(ctx).Info("alert created",
+		"alert_id", created.ID, "user_id", created.UserID, "rule", created.Rule, "price", created.Price,
+		"applied_defaults", created.AppliedDefaults)
+	return created, nil
 }
 
-func (s *AlertService) GetAlertsByUser(userId string) ([]dto.AlertResponse, error) {
-	return s.repo.FindAllByUser(userId)
+func (s *AlertService) GetAlertByID(ctx context.Context, id string) (*dto.AlertResponse, error) {
+	alert, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, domain.ErrAlertNotFound
+	}
+	alerts := []dto.AlertResponse{*alert}
+	s.computeRuntimeFields(ctx, alerts)
+	return &alerts[0], nil
 }
 
-func (s *AlertService) UpdateAlert(id string, alert dto.AlertCreateRequest) (*dto.AlertResponse, error) {
-	return s.repo.Update(id, &alert)
+func (s *AlertService) GetAlertsByUser(ctx context.Context, userId string) ([]dto.AlertResponse, error) {
+	alerts, err := s.repo.FindAllByUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	s.computeRuntimeFields(ctx, alerts)
+	return alerts, nil
 }
 
-func (s *AlertService) DeleteAlert(id string) error {
-	return s.repo.Delete(id)
+// computeRuntimeFields fills in the computed fields of alerts being read. The
+// latest prices of their symbols come from one query and each owner is looked
+// up once. The fields are best effort: a failed lookup is logged and leaves
+// its fields out rather than failing the read.
+func (s *AlertService) computeRuntimeFields(ctx context.Context, alerts []dto.AlertResponse) {
+	now := time.Now().UTC()
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, alert := range alerts {
+		if alert.Symbol != "" && !seen[alert.Symbol] {
+			seen[alert.Symbol] = true
+			symbols = append(symbols, alert.Symbol)
+		}
+	}
+	latest, err := s.prices.LatestForSymbols(ctx, symbols)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to load latest prices for alerts", "symbols", len(symbols), "error", err)
+	}
+
+	owners := make(map[string]bool)
+	tradingDate := s.schedule.TradingDate(now)
+	for i := range alerts {
+		alert := &alerts[i]
+		alert.IsExpired = !alert.StopDate.IsZero() && !now.Before(alert.StopDate)
+		snoozed, ok := owners[alert.UserID]
+		if !ok {
+			snoozed = s.ownerMuted(ctx, alert.UserID, now)
+			owners[alert.UserID] = snoozed
+		}
+		alert.IsSnoozed = snoozed
+		if day, ok := latest[alert.Symbol]; ok {
+			price := day.Price
+			alert.CurrentPrice = &price
+			if distance, ok := DistanceToTrigger(*alert, &day, tradingDate); ok {
+				alert.DistanceToTriggerPercent = &distance
+			}
+		}
+	}
+}
+
+// ownerMuted reports whether the owner of an alert has muted notifications at
+// now; an owner that cannot be loaded counts as not muted
+func (s *AlertService) ownerMuted(ctx context.Context, userID string, now time.Time) bool {
+	if s.users == nil {
+		return false
+	}
+	owner, err := s.users.FindByUserID(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to load alert owner", "user_id", userID, "error", err)
+		return false
+	}
+	return muted(owner, now)
+}
+
+func (s *AlertService) UpdateAlert(ctx context.Context, id string, alert dto.AlertCreateRequest) (*dto.AlertResponse, error) {
+	if err := normalizeAlert(&alert); err != nil {
+		return nil, err
+	}
+	previous, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if previous == nil {
+		return nil, domain.ErrAlertNotFound
+	}
+	// Alerts kept on a symbol created before the reference data are left alone
+	if previous.Symbol != alert.Symbol {
+		if err := s.validateSymbol(ctx, alert.Symbol); err != nil {
+			return nil, err
+		}
+	}
+	// Likewise an alert whose dates are kept, though they may have drifted
+	// out of bounds since it was created
+	if !previous.StartDate.Equal(alert.StartDate) || !previous.StopDate.Equal(alert.StopDate) {
+		if err := s.dates.Check(alert.StartDate, alert.StopDate, time.Now().UTC()); err != nil {
+			return nil, err
+		}
+	}
+	updated, err := s.repo.Update(ctx, id, &alert)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateCache()
+	s.recordChange(ctx, updated, dto.AlertChangeUpdated)
+	// Tell the owner's live connections when an alert is switched on or off
+	if s.events != nil && updated != nil && previous.Status != updated.Status {
+		s.events.Publish(updated.UserID, Event{Type: EventAlertStatus, Data: map[string]interface{}{
+			"alertId": updated.ID,
+			"from":    previous.Status,
+			"to":      updated.Status,
+		}})
+	}
+	return updated, nil
+}
+
+func (s *AlertService) DeleteAlert(ctx context.Context, id string) error {
+	// Look the alert up first so the change feed can report its symbol
+	existing, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return domain.ErrAlertNotFound
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.invalidateCache()
+	s.recordChange(ctx, existing, dto.AlertChangeDeleted)
+	logging.FromContext(ctx).Info("alert deleted", "alert_id", id)
+	return nil
+}
+
+func (s *AlertService) SetAlertShadow(ctx context.Context, id string, shadow bool) (*dto.AlertResponse, error) {
+	updated, err := s.repo.SetShadow(ctx, id, shadow)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, domain.ErrAlertNotFound
+	}
+	s.invalidateCache()
+	s.recordChange(ctx, updated, dto.AlertChangeUpdated)
+	logging.FromContext(ctx).Info("alert shadow mode changed", "alert_id", id, "shadow", shadow)
+	return updated, nil
+}
+
+// EvaluateAlert dry-runs the alert against the given price, or the latest stored
+// price of its symbol, and explains the decision
+func (s *AlertService) EvaluateAlert(ctx context.Context, id string, req dto.AlertEvaluateRequest) (*dto.AlertEvaluationResponse, error) {
+	alert, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, domain.ErrAlertNotFound
+	}
+
+	var day *dto.LatestPriceResponse
+	if alert.Symbol != "" {
+		if day, err = s.prices.Latest(ctx, alert.Symbol); err != nil {
+			return nil, err
+		}
+	}
+	price := req.Price
+	at := timeutil.UTC(req.ObservedAt)
+	if price == nil && day != nil {
+		price = &day.Price
+		if at.IsZero() {
+			at = day.Time
+		}
+	}
+	if price == nil {
+		return nil, fmt.Errorf("price is required when no price is stored for the alert's symbol: %w", domain.ErrValidation)
+	}
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+	session, err := s.calendar.Session(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+	result := EvaluateAlert(*alert, session, *price, day, at)
+	logging.FromContext(ctx).Info("alert evaluated",
+		"alert_id", alert.ID, "price", *price, "would_fire", result.WouldFire)
+	return &result, nil
 }
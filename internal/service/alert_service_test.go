@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/internal/repository/entity"
+	"github.com/hello-api/pkg/money"
+)
+
+// Start and stop dates sent with any offset are stored as the same instants in UTC
+func TestNormalizeAlertDates(t *testing.T) {
+	var alert dto.AlertCreateRequest
+	body := `{"userId":"alice","symbol":"gp","rule":"above","price":"350","startDate":"2024-03-04T10:00:00+06:00","stopDate":"2024-03-04T22:00:00-05:00"}`
+	if err := json.Unmarshal([]byte(body), &alert); err != nil {
+		t.Fatal(err)
+	}
+	if err := normalizeAlert(&alert); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		got  time.Time
+		want time.Time
+	}{
+		{name: "startDate", got: alert.StartDate, want: time.Date(2024, 3, 4, 4, 0, 0, 0, time.UTC)},
+		{name: "stopDate", got: alert.StopDate, want: time.Date(2024, 3, 5, 3, 0, 0, 0, time.UTC)},
+	} {
+		if !tc.got.Equal(tc.want) || tc.got.Location() != time.UTC {
+			t.Errorf("%s is %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+	if alert.Symbol != "GP" {
+		t.Errorf("got symbol %q, want GP", alert.Symbol)
+	}
+}
+
+// An override target must suit its channel; email targets are normalized
+func TestNormalizeNotifyOverride(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		override   dto.NotifyOverride
+		wantTarget string
+		wantErr    bool
+	}{
+		{name: "webhook", override: dto.NotifyOverride{Channel: dto.NotificationChannelWebhook, Target: " https://bot.example.com/hook "}, wantTarget: "https://bot.example.com/hook"},
+		{name: "webhook without scheme", override: dto.NotifyOverride{Channel: dto.NotificationChannelWebhook, Target: "bot.example.com/hook"}, wantErr: true},
+		{name: "webhook over ftp", override: dto.NotifyOverride{Channel: dto.NotificationChannelWebhook, Target: "ftp://bot.example.com"}, wantErr: true},
+		{name: "telegram chat", override: dto.NotifyOverride{Channel: dto.NotificationChannelTelegram, Target: "-100123"}, wantTarget: "-100123"},
+		{name: "telegram username", override: dto.NotifyOverride{Channel: dto.NotificationChannelTelegram, Target: "@alice"}, wantErr: true},
+		{name: "email", override: dto.NotifyOverride{Channel: dto.NotificationChannelEmail, Target: "Bot@Example.com"}, wantTarget: "bot@example.com"},
+		{name: "email with a name", override: dto.NotifyOverride{Channel: dto.NotificationChannelEmail, Target: "Bot <bot@example.com>"}, wantErr: true},
+		{name: "unknown channel", override: dto.NotifyOverride{Channel: "sms", Target: "+8801700000000"}, wantErr: true},
+		{name: "no target", override: dto.NotifyOverride{Channel: dto.NotificationChannelWebhook, Target: " "}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			override := tc.override
+			err := normalizeNotifyOverride(&override)
+			if tc.wantErr {
+				if !errors.Is(err, domain.ErrValidation) {
+					t.Errorf("got %v, want ErrValidation", err)
+				}
+				return
+			}
+			if err != nil || override.Target != tc.wantTarget {
+				t.Errorf("got %q, %v, want %q", override.Target, err, tc.wantTarget)
+			}
+		})
+	}
+}
+
+// Alerts read back carry whether they have expired, whether their owner has
+// muted notifications, and the latest price and distance to trigger of their
+// symbol when one is stored
+func TestAlertRuntimeFields(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	schedule := DefaultMarketSchedule()
+
+	users := repository.NewMemoryUserRepository()
+	mutedUntil, mutedBefore := now.Add(time.Hour), now.Add(-time.Hour)
+	for _, user := range []entity.UserEntity{
+		{UserID: "alice", Name: "Alice", Email: "alice@example.com", MutedUntil: &mutedUntil},
+		{UserID: "bob", Name: "Bob", Email: "bob@example.com", MutedUntil: &mutedBefore},
+	} {
+		if _, err := users.Create(ctx, &user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prices := repository.NewMemoryPriceRepository()
+	if _, err := prices.Insert(ctx, &dto.PriceTickRequest{Symbol: "GP", Price: money.FromFloat(100), Time: now}, schedule.TradingDate(now)); err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.NewMemoryAlertRepository()
+	alerts := NewAlertService(repo, nil, nil, schedule, prices, nil, nil, nil, users, DefaultAlertDateBounds())
+
+	for _, tc := range []struct {
+		name        string
+		userID      string
+		symbol      string
+		stopDate    time.Time
+		wantExpired bool
+		wantSnoozed bool
+		wantPrice   bool
+	}{
+		{name: "muted owner", userID: "alice", symbol: "GP", wantSnoozed: true, wantPrice: true},
+		{name: "stopped", userID: "alice", symbol: "GP", stopDate: now.Add(-time.Minute), wantExpired: true, wantSnoozed: true, wantPrice: true},
+		{name: "stopping later", userID: "bob", symbol: "GP", stopDate: now.Add(time.Hour), wantPrice: true},
+		{name: "mute over", userID: "bob", symbol: "GP", wantPrice: true},
+		{name: "unknown owner", userID: "ghost", symbol: "GP", wantPrice: true},
+		{name: "no stored price", userID: "bob", symbol: "BATBC"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			created, err := repo.Create(ctx, &dto.AlertCreateRequest{
+				UserID: tc.userID, Symbol: tc.symbol, Rule: dto.AlertRuleAbove, Price: money.FromFloat(110),
+				Status: dto.AlertStatusActive, StopDate: tc.stopDate,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := alerts.GetAlertByID(ctx, created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.IsExpired != tc.wantExpired || got.IsSnoozed != tc.wantSnoozed {
+				t.Errorf("got expired %v and snoozed %v, want %v and %v", got.IsExpired, got.IsSnoozed, tc.wantExpired, tc.wantSnoozed)
+			}
+			if !tc.wantPrice {
+				if got.CurrentPrice != nil || got.DistanceToTriggerPercent != nil {
+					t.Errorf("got price %v and distance %v, want neither", got.CurrentPrice, got.DistanceToTriggerPercent)
+				}
+				return
+			}
+			if got.CurrentPrice == nil || *got.CurrentPrice != money.FromFloat(100) {
+				t.Errorf("got current price %v, want 100", got.CurrentPrice)
+			}
+			if got.DistanceToTriggerPercent == nil || math.Abs(*got.DistanceToTriggerPercent-10) > 0.01 {
+				t.Errorf("got distance %v, want 10%%", got.DistanceToTriggerPercent)
+			}
+		})
+	}
+
+	// a list of alerts gets the same fields
+	list, err := alerts.GetAlertsByUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || !list[0].IsSnoozed || list[0].DistanceToTriggerPercent == nil || list[0].IsExpired == list[1].IsExpired {
+		t.Errorf("got %+v, want both of alice's alerts snoozed, one expired", list)
+	}
+}
+
+// New alerts take the owner's default duration and channels for the fields
+// they leave unset, record which ones they took, and are validated with them
+func TestCreateAlertAppliesDefaults(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	for _, user := range []entity.UserEntity{
+		{UserID: "alice", Name: "Alice", Email: "alice@example.com",
+			AlertDefaults: &entity.AlertDefaultsEntity{DurationDays: 7, Channels: []string{"telegram", "email"}}},
+		{UserID: "bob", Name: "Bob", Email: "bob@example.com"},
+		{UserID: "carol", Name: "Carol", Email: "carol@example.com",
+			AlertDefaults: &entity.AlertDefaultsEntity{DurationDays: 2000}},
+	} {
+		if _, err := users.Create(ctx, &user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alerts := NewAlertService(repository.NewMemoryAlertRepository(), nil, nil, MarketSchedule{}, nil, nil, nil, nil, users, DefaultAlertDateBounds())
+	start := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	stop := start.Add(48 * time.Hour)
+
+	for _, tc := range []struct {
+		name        string
+		alert       dto.AlertCreateRequest
+		wantApplied string
+		wantStop    time.Time
+		wantEmail   bool
+		wantErr     bool
+	}{
+		{name: "everything unset", alert: dto.AlertCreateRequest{UserID: "alice", StartDate: start},
+			wantApplied: "stopDate notifyTelegram notifyEmail", wantStop: start.AddDate(0, 0, 7), wantEmail: true},
+		{name: "stop date set", alert: dto.AlertCreateRequest{UserID: "alice", StartDate: start, StopDate: stop},
+			wantApplied: "notifyTelegram notifyEmail", wantStop: stop, wantEmail: true},
+		{name: "channel chosen", alert: dto.AlertCreateRequest{UserID: "alice", StartDate: start, WebhookURL: "https://bot.example.com/hook"},
+			wantApplied: "stopDate", wantStop: start.AddDate(0, 0, 7)},
+		{name: "nothing applies", alert: dto.AlertCreateRequest{UserID: "alice", StartDate: start, StopDate: stop, NotifyTelegram: true},
+			wantStop: stop},
+		{name: "no defaults", alert: dto.AlertCreateRequest{UserID: "bob", StartDate: start, StopDate: stop},
+			wantStop: stop},
+		{name: "default past the longest window", alert: dto.AlertCreateRequest{UserID: "carol", StartDate: start},
+			wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			alert := tc.alert
+			alert.Symbol, alert.Rule, alert.Price, alert.Status = "GP", dto.AlertRuleAbove, money.FromFloat(100), dto.AlertStatusActive
+			created, err := alerts.CreateAlert(ctx, alert)
+			if tc.wantErr {
+				if !errors.Is(err, domain.ErrValidation) {
+					t.Errorf("got %v, want ErrValidation", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(created.AppliedDefaults, " "); got != tc.wantApplied {
+				t.Errorf("got applied defaults %q, want %q", got, tc.wantApplied)
+			}
+			if !created.StopDate.Equal(tc.wantStop) || created.NotifyEmail != tc.wantEmail {
+				t.Errorf("got stop %s and email %v, want %s and %v", created.StopDate, created.NotifyEmail, tc.wantStop, tc.wantEmail)
+			}
+		})
+	}
+}
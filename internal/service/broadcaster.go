@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hello-api/pkg/metrics"
+)
+
+// Event types pushed to live clients
+const (
+	EventAlertTriggered = "alert.triggered"
+	EventAlertStatus    = "alert.status"
+)
+
+// Event is a live update for one user
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+	At   time.Time   `json:"at"`
+}
+
+var (
+	// ErrTooManySubscribers is returned when a user already holds the maximum number of live connections
+	ErrTooManySubscribers = errors.New("too many live connections for this user")
+
+	// ErrServerAtCapacity is returned when the server already holds the maximum number of live connections
+	ErrServerAtCapacity = errors.New("too many live connections on this server")
+
+	// ErrSubscriberOverflow closes a subscription whose client did not keep up
+	ErrSubscriberOverflow = errors.New("event queue overflow")
+
+	// ErrBroadcasterClosed closes every subscription when the server shuts down
+	ErrBroadcasterClosed = errors.New("server shutting down")
+)
+
+// Default limits of a Broadcaster
+const (
+	DefaultMaxSubscribersPerUser = 5
+	DefaultMaxSubscribers        = 1000
+	DefaultSubscriberQueue       = 64
+	// DefaultEventHistory is how many recent triggers are kept per user for new subscribers
+	DefaultEventHistory = 20
+)
+
+// LiveLimits caps the live connections held at once, per user and in total,
+// so a client opening a stream per render cannot exhaust file descriptors
+type LiveLimits struct {
+	MaxPerUser int
+	MaxTotal   int
+}
+
+// LoadLiveLimits reads LIVE_MAX_CONNECTIONS_PER_USER and LIVE_MAX_CONNECTIONS
+func LoadLiveLimits() (LiveLimits, error) {
+	limits := LiveLimits{MaxPerUser: DefaultMaxSubscribersPerUser, MaxTotal: DefaultMaxSubscribers}
+	for _, setting := range []struct {
+		name  string
+		limit *int
+	}{
+		{"LIVE_MAX_CONNECTIONS_PER_USER", &limits.MaxPerUser},
+		{"LIVE_MAX_CONNECTIONS", &limits.MaxTotal},
+	} {
+		raw := os.Getenv(setting.name)
+		if raw == "" {
+			continue
+		}
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return LiveLimits{}, fmt.Errorf("%s must be a positive integer, got %q", setting.name, raw)
+		}
+		*setting.limit = limit
+	}
+	if limits.MaxPerUser > limits.MaxTotal {
+		return LiveLimits{}, fmt.Errorf("LIVE_MAX_CONNECTIONS_PER_USER (%d) exceeds LIVE_MAX_CONNECTIONS (%d)", limits.MaxPerUser, limits.MaxTotal)
+	}
+	return limits, nil
+}
+
+// SubscriberStats are the live connections held, per user and in total
+type SubscriberStats struct {
+	Total      int            `json:"total"`
+	MaxTotal   int            `json:"max_total"`
+	MaxPerUser int            `json:"max_per_user"`
+	Users      map[string]int `json:"users"`
+}
+
+// Subscription receives a user's events until it is closed
+type Subscription struct {
+	userID string
+	events chan Event
+	// history holds the user's recent triggers published before the subscription
+	history []Event
+
+	mu  sync.Mutex
+	err error
+
+	released sync.Once
+}
+
+// Events returns the subscription's queue; it is closed when the subscription ends
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// History returns the user's recent triggers published before the subscription
+// started, oldest first. Events() only carries later events, so a client shown
+// the history first sees every trigger once.
+func (s *Subscription) History() []Event {
+	return s.history
+}
+
+// Err returns why the subscription was closed by the broadcaster, if it was
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Broadcaster fans events out to the live connections of each user. Every
+// subscription has a bounded queue; a subscriber that falls behind is dropped
+// rather than slowing down the publisher.
+//
+// A subscription counts against the limits from Subscribe until its consumer
+// calls Unsubscribe, not merely until it is closed, so a connection still
+// being torn down keeps its slot.
+type Broadcaster struct {
+	limits      LiveLimits
+	queueSize   int
+	historySize int
+
+	mu     sync.Mutex
+	subs   map[string]map[*Subscription]struct{}
+	closed bool
+	// history keeps each user's last historySize triggers
+	history map[string][]Event
+	// held counts each user's unreleased subscriptions, total all of them
+	held  map[string]int
+	total int
+
+	// Subscriptions not yet released by their consumer
+	active sync.WaitGroup
+}
+
+func NewBroadcaster(limits LiveLimits, queueSize, historySize int) *Broadcaster {
+	metrics.Default.Describe("live_subscribers", "Live event subscribers currently connected")
+	metrics.Default.Describe("live_subscriber_users", "Users with at least one live event subscriber")
+	metrics.Default.Describe("live_subscribers_rejected_total", "Live subscribers refused, by the limit reached")
+	metrics.Default.Describe("live_events_dropped_subscribers_total", "Live subscribers dropped because their queue overflowed")
+
+	return &Broadcaster{
+		limits:      limits,
+		queueSize:   queueSize,
+		historySize: historySize,
+		subs:        make(map[string]map[*Subscription]struct{}),
+		history:     make(map[string][]Event),
+		held:        make(map[string]int),
+	}
+}
+
+// Subscribe registers a subscriber for the user's events
+func (b *Broadcaster) Subscribe(userID string) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrBroadcasterClosed
+	}
+	if b.held[userID] >= b.limits.MaxPerUser {
+		metrics.Default.Counter("live_subscribers_rejected_total", metrics.Labels{"limit": "per_user"}).Inc()
+		return nil, ErrTooManySubscribers
+	}
+	if b.total >= b.limits.MaxTotal {
+		metrics.Default.Counter("live_subscribers_rejected_total", metrics.Labels{"limit": "total"}).Inc()
+		return nil, ErrServerAtCapacity
+	}
+	sub := &Subscription{
+		userID:  userID,
+		events:  make(chan Event, b.queueSize),
+		history: append([]Event(nil), b.history[userID]...),
+	}
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[*Subscription]struct{})
+	}
+	b.subs[userID][sub] = struct{}{}
+	b.held[userID]++
+	b.total++
+	b.active.Add(1)
+	b.updateGaugesLocked()
+	return sub, nil
+}
+
+// Unsubscribe removes a subscriber and releases it; consumers must call it once
+// they are done, including after the broadcaster closed the subscription.
+// It is safe to call more than once.
+func (b *Broadcaster) Unsubscribe(sub *Subscription) {
+	sub.released.Do(func() {
+		b.mu.Lock()
+		b.removeLocked(sub, nil)
+		if b.held[sub.userID]--; b.held[sub.userID] <= 0 {
+			delete(b.held, sub.userID)
+		}
+		b.total--
+		b.updateGaugesLocked()
+		b.mu.Unlock()
+
+		b.active.Done()
+	})
+}
+
+// Stats returns the live connections held, per user and in total
+func (b *Broadcaster) Stats() SubscriberStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	users := make(map[string]int, len(b.held))
+	for userID, held := range b.held {
+		users[userID] = held
+	}
+	return SubscriberStats{Total: b.total, MaxTotal: b.limits.MaxTotal, MaxPerUser: b.limits.MaxPerUser, Users: users}
+}
+
+// updateGaugesLocked publishes the held counts; b.mu must be held
+func (b *Broadcaster) updateGaugesLocked() {
+	metrics.Default.Gauge("live_subscribers", nil).Set(int64(b.total))
+	metrics.Default.Gauge("live_subscriber_users", nil).Set(int64(len(b.held)))
+}
+
+// Publish queues an event for every subscriber of the user without blocking.
+// Triggers are also kept in the user's history for later subscribers.
+func (b *Broadcaster) Publish(userID string, event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now().UTC()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if event.Type == EventAlertTriggered && b.historySize > 0 {
+		history := append(b.history[userID], event)
+		if len(history) > b.historySize {
+			history = append([]Event(nil), history[len(history)-b.historySize:]...)
+		}
+		b.history[userID] = history
+	}
+
+	for sub := range b.subs[userID] {
+		select {
+		case sub.events <- event:
+		default:
+			metrics.Default.Counter("live_events_dropped_subscribers_total", nil).Inc()
+			b.removeLocked(sub, ErrSubscriberOverflow)
+		}
+	}
+}
+
+// Close ends every subscription and refuses new ones
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, subs := range b.subs {
+		for sub := range subs {
+			b.removeLocked(sub, ErrBroadcasterClosed)
+		}
+	}
+}
+
+// Shutdown closes every subscription and waits until their consumers have
+// released them, e.g. sent their close frames, or until ctx is done
+func (b *Broadcaster) Shutdown(ctx context.Context) error {
+	b.Close()
+
+	done := make(chan struct{})
+	go func() {
+		b.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// removeLocked closes a subscription with reason; b.mu must be held
+func (b *Broadcaster) removeLocked(sub *Subscription, reason error) {
+	subs, ok := b.subs[sub.userID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subs, sub.userID)
+	}
+
+	sub.mu.Lock()
+	sub.err = reason
+	sub.mu.Unlock()
+	close(sub.events)
+}
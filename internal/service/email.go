@@ -0,0 +1,388 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/hello-api/internal/common/timeutil"
+)
+
+// Email TLS modes
+const (
+	// EmailTLSStartTLS upgrades a plain connection and refuses servers without STARTTLS
+	EmailTLSStartTLS = "starttls"
+	// EmailTLSImplicit connects over TLS from the start, usually on port 465
+	EmailTLSImplicit = "tls"
+	// EmailTLSNone sends in the clear; only for local relays
+	EmailTLSNone = "none"
+)
+
+// EmailConfig configures the SMTP sender. Email is disabled unless SMTP_HOST is set.
+type EmailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	TLS      string
+	// UnsubscribeURL is linked in every email as the place to turn emails off
+	UnsubscribeURL string
+}
+
+// Enabled reports whether an SMTP server is configured
+func (c EmailConfig) Enabled() bool {
+	return c.Host != ""
+}
+
+// LoadEmailConfig reads SMTP_HOST, SMTP_PORT (587), SMTP_USERNAME,
+// SMTP_PASSWORD, SMTP_FROM, SMTP_TLS (starttls, tls or none) and
+// EMAIL_UNSUBSCRIBE_URL
+func LoadEmailConfig() (EmailConfig, error) {
+	cfg := EmailConfig{
+		Host:           os.Getenv("SMTP_HOST"),
+		Port:           587,
+		Username:       os.Getenv("SMTP_USERNAME"),
+		Password:       os.Getenv("SMTP_PASSWORD"),
+		From:           os.Getenv("SMTP_FROM"),
+		TLS:            EmailTLSStartTLS,
+		UnsubscribeURL: os.Getenv("EMAIL_UNSUBSCRIBE_URL"),
+	}
+	if !cfg.Enabled() {
+		return cfg, nil
+	}
+	if raw := os.Getenv("SMTP_PORT"); raw != "" {
+		port, err := strconv.Atoi(raw)
+		if err != nil || port <= 0 || port > 65535 {
+			return cfg, fmt.Errorf("SMTP_PORT must be a port number, got %q", raw)
+		}
+		cfg.Port = port
+	}
+	if raw := os.Getenv("SMTP_TLS"); raw != "" {
+		switch raw {
+		case EmailTLSStartTLS, EmailTLSImplicit, EmailTLSNone:
+			cfg.TLS = raw
+		default:
+			return cfg, fmt.Errorf("SMTP_TLS must be starttls, tls or none, got %q", raw)
+		}
+	}
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return cfg, fmt.Errorf("SMTP_FROM must be an email address, got %q", cfg.From)
+	}
+	return cfg, nil
+}
+
+// MailTransport hands a finished message to a mail server. Tests substitute a
+// fake to capture messages without an SMTP server.
+type MailTransport interface {
+	SendMail(ctx context.Context, from string, to []string, msg []byte) error
+}
+
+// SMTPTransport sends mail through the configured SMTP server
+type SMTPTransport struct {
+	cfg EmailConfig
+}
+
+func NewSMTPTransport(cfg EmailConfig) *SMTPTransport {
+	return &SMTPTransport{cfg: cfg}
+}
+
+// SendMail delivers msg in one SMTP session. A recipient the server rejects
+// with a 5xx reply returns ErrUndeliverable; other failures are retried.
+func (t *SMTPTransport) SendMail(ctx context.Context, from string, to []string, msg []byte) error {
+	addr := net.JoinHostPort(t.cfg.Host, strconv.Itoa(t.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: t.cfg.Host}
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if t.cfg.TLS == EmailTLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, t.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if t.cfg.TLS == EmailTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server %s does not offer STARTTLS", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if t.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", t.cfg.Username, t.cfg.Password, t.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			var reply *textproto.Error
+			if errors.As(err, &reply) && reply.Code >= 500 {
+				return fmt.Errorf("recipient %s rejected: %v: %w", rcpt, err, ErrUndeliverable)
+			}
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// EmailMessage is a rendered email
+type EmailMessage struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// emailTrigger is one trigger as shown in an email
+type emailTrigger struct {
+	Name      string
+	Symbol    string
+	Condition string
+	Price     string
+	// Freshness says how stale the price was; empty when unknown
+	Freshness string
+	Reason    string
+	Time      string
+}
+
+// emailView is the data of the email templates
+type emailView struct {
+	Triggers []emailTrigger
+	// Digest is set for hourly summaries; Window describes the hour covered
+	Digest bool
+	Window string
+	// Throttled words an hourly cap summary, which lists no triggers
+	Throttled      string
+	UnsubscribeURL string
+}
+
+var emailSubjectTemplate = texttemplate.Must(texttemplate.New("subject").Parse(
+	`{{if .Throttled}}{{.Throttled}}{{else if .Digest}}{{len .Triggers}} stock alert{{if gt (len .Triggers) 1}}s{{end}} triggered, {{.Window}}` +
+		`{{else}}{{with index .Triggers 0}}{{.Symbol}} is {{.Condition}}: {{.Name}}{{end}}{{end}}`))
+
+var emailTextTemplate = texttemplate.Must(texttemplate.New("text").Parse(
+	`{{if .Throttled}}{{.Throttled}}.
+You reached the hourly limit of notifications, so these were not sent one by one.
+{{else if .Digest}}Alerts triggered {{.Window}}:
+{{else}}Your alert was triggered:
+{{end}}{{range .Triggers}}
+{{.Name}}
+  {{.Symbol}} is {{.Condition}}
+  Price: {{.Price}}{{with .Freshness}} ({{.}}){{end}}
+  Time: {{.Time}}{{if .Reason}}
+  Reason: {{.Reason}}{{end}}
+{{end}}
+--
+You get these emails because email notifications are on for your alerts.
+Turn them off in the alert settings{{if .UnsubscribeURL}} or at {{.UnsubscribeURL}}{{end}}.
+`))
+
+var emailHTMLTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(
+	`<!DOCTYPE html>
+<html><body style="font-family:sans-serif">
+{{if .Throttled}}<p>{{.Throttled}}.</p>
+<p>You reached the hourly limit of notifications, so these were not sent one by one.</p>
+{{else}}<p>{{if .Digest}}Alerts triggered {{.Window}}:{{else}}Your alert was triggered:{{end}}</p>
+<table cellpadding="6" style="border-collapse:collapse">
+<tr><th align="left">Alert</th><th align="left">Condition</th><th align="left">Price</th><th align="left">Time</th></tr>
+{{range .Triggers}}<tr><td>{{.Name}}</td><td>{{.Symbol}} is {{.Condition}}</td><td>{{.Price}}{{with .Freshness}} ({{.}}){{end}}</td><td>{{.Time}}</td></tr>
+{{end}}</table>
+{{end}}
+<p style="color:#777;font-size:12px">You get these emails because email notifications are on for your alerts.
+Turn them off in the alert settings{{if .UnsubscribeURL}} or <a href="{{.UnsubscribeURL}}">unsubscribe here</a>{{end}}.</p>
+</body></html>
+`))
+
+// EmailSender emails alert triggers and digests as a NotificationSender; the
+// destination is the recipient address
+type EmailSender struct {
+	transport      MailTransport
+	from           string
+	unsubscribeURL string
+	location       *time.Location
+}
+
+func NewEmailSender(transport MailTransport, cfg EmailConfig, location *time.Location) *EmailSender {
+	if location == nil {
+		location = time.UTC
+	}
+	return &EmailSender{transport: transport, from: cfg.From, unsubscribeURL: cfg.UnsubscribeURL, location: location}
+}
+
+// Send validates the address, renders the payload and hands the email to the transport
+func (s *EmailSender) Send(ctx context.Context, destination string, payload []byte) error {
+	to, err := mail.ParseAddress(destination)
+	if err != nil || to.Address != destination {
+		return fmt.Errorf("invalid email address %q: %w", destination, ErrUndeliverable)
+	}
+	rendered, err := s.Render(payload)
+	if err != nil {
+		return fmt.Errorf("rendering email: %v: %w", err, ErrUndeliverable)
+	}
+	msg, err := s.compose(to.Address, rendered)
+	if err != nil {
+		return err
+	}
+	from, _ := mail.ParseAddress(s.from)
+	return s.transport.SendMail(ctx, from.Address, []string{to.Address}, msg)
+}
+
+// Render builds the subject and bodies of a trigger, digest or hourly cap summary payload
+func (s *EmailSender) Render(payload []byte) (*EmailMessage, error) {
+	var head struct {
+		Event string `json:"event"`
+	}
+	if err := json.Unmarshal(payload, &head); err != nil {
+		return nil, err
+	}
+	view := emailView{UnsubscribeURL: s.unsubscribeURL}
+	switch head.Event {
+	case EventAlertTriggered:
+		var event alertTriggeredEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		view.Triggers = []emailTrigger{s.trigger(event)}
+	case EventAlertDigest:
+		var digest alertDigestEvent
+		if err := json.Unmarshal(payload, &digest); err != nil {
+			return nil, err
+		}
+		if len(digest.Triggers) == 0 {
+			return nil, fmt.Errorf("digest has no triggers")
+		}
+		view.Digest = true
+		location := timeutil.Display(digest.DisplayTimezone, s.location)
+		view.Window = fmt.Sprintf("%s to %s", digest.WindowStart.In(location).Format("02 Jan 2006 15:04"),
+			digest.WindowEnd.In(location).Format("15:04 MST"))
+		for _, event := range digest.Triggers {
+			if event.DisplayTimezone == "" {
+				event.DisplayTimezone = digest.DisplayTimezone
+			}
+			view.Triggers = append(view.Triggers, s.trigger(event))
+		}
+	case EventAlertsThrottled:
+		var summary alertsThrottledEvent
+		if err := json.Unmarshal(payload, &summary); err != nil {
+			return nil, err
+		}
+		if summary.Suppressed <= 0 {
+			return nil, fmt.Errorf("summary counts no alerts")
+		}
+		view.Throttled = describeThrottled(summary, timeutil.Display(summary.DisplayTimezone, s.location))
+	default:
+		return nil, fmt.Errorf("unknown event %q", head.Event)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := emailSubjectTemplate.Execute(&subject, view); err != nil {
+		return nil, err
+	}
+	if err := emailTextTemplate.Execute(&text, view); err != nil {
+		return nil, err
+	}
+	if err := emailHTMLTemplate.Execute(&html, view); err != nil {
+		return nil, err
+	}
+	return &EmailMessage{Subject: subject.String(), Text: text.String(), HTML: html.String()}, nil
+}
+
+func (s *EmailSender) trigger(event alertTriggeredEvent) emailTrigger {
+	name := event.Name
+	if name == "" {
+		name = "Alert"
+	}
+	symbol := event.Symbol
+	if symbol == "" {
+		symbol = "Price"
+	}
+	location := timeutil.Display(event.DisplayTimezone, s.location)
+	return emailTrigger{
+		Name:      name,
+		Symbol:    symbol,
+		Condition: describeCondition(event.Rule, event.Threshold),
+		Price:     event.Price.String(),
+		Freshness: describeFreshness(event.Lag, location),
+		Reason:    event.Reason,
+		Time:      event.TriggeredAt.In(location).Format("02 Jan 2006 15:04 MST"),
+	}
+}
+
+// compose writes a multipart/alternative message with text and HTML parts
+func (s *EmailSender) compose(to string, rendered *EmailMessage) ([]byte, error) {
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	boundary := "alt-" + hex.EncodeToString(raw)
+
+	var msg bytes.Buffer
+	// Header values are free of CR and LF: addresses were parsed and the
+	// subject is Q-encoded
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.ReplaceAll(rendered.Subject, "\n", " ")))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", rendered.Text},
+		{"text/html", rendered.HTML},
+	} {
+		fmt.Fprintf(&msg, "--%s\r\n", boundary)
+		fmt.Fprintf(&msg, "Content-Type: %s; charset=utf-8\r\n", part.contentType)
+		msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		qp := quotedprintable.NewWriter(&msg)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+		msg.WriteString("\r\n")
+	}
+	fmt.Fprintf(&msg, "--%s--\r\n", boundary)
+	return msg.Bytes(), nil
+}
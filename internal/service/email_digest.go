@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/metrics"
+)
+
+// EventAlertDigest is the outbox payload of a digest email
+const EventAlertDigest = "alert.digest"
+
+const (
+	// EmailDigestWindow is the period whose triggers one digest email summarizes
+	EmailDigestWindow = time.Hour
+	// emailDigestGrace lets triggers added right at the end of a window land
+	// before the window is flushed
+	emailDigestGrace = time.Minute
+	// emailDigestInterval is how often ended windows are looked for
+	emailDigestInterval = time.Minute
+)
+
+// alertDigestEvent is the payload of a digest email
+type alertDigestEvent struct {
+	Event       string                `json:"event"`
+	UserID      string                `json:"userId"`
+	WindowStart time.Time             `json:"windowStart"`
+	WindowEnd   time.Time             `json:"windowEnd"`
+	Triggers    []alertTriggeredEvent `json:"triggers"`
+	// DisplayTimezone is the owner's display timezone, if any
+	DisplayTimezone string `json:"displayTimezone,omitempty"`
+}
+
+// EmailDigester batches the triggers of users on hourly emails. Triggers are
+// held per user and clock hour; once the hour has ended each user's triggers
+// are queued in the outbox as one digest email and released. Any number of
+// replicas may run it: queueing a digest twice returns the existing delivery.
+// Digests of users in their quiet hours are queued for the end of the window.
+type EmailDigester struct {
+	repo   domain.EmailDigestRepository
+	outbox domain.NotificationRepository
+	policy *NotificationPolicy
+	window time.Duration
+	now    func() time.Time
+}
+
+func NewEmailDigester(repo domain.EmailDigestRepository, outbox domain.NotificationRepository, policy *NotificationPolicy, window time.Duration) *EmailDigester {
+	metrics.Default.Describe("email_digests_queued_total", "Digest emails queued in the notification outbox")
+	if window <= 0 {
+		window = EmailDigestWindow
+	}
+	return &EmailDigester{repo: repo, outbox: outbox, policy: policy, window: window, now: time.Now}
+}
+
+// Add holds a trigger for the user's digest of the current window
+func (d *EmailDigester) Add(ctx context.Context, userID, email string, event alertTriggeredEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return d.repo.Add(ctx, &dto.EmailDigestItem{
+		UserID:      userID,
+		Email:       email,
+		AlertID:     event.AlertID,
+		TriggerID:   event.TriggerID,
+		WindowStart: d.now().UTC().Truncate(d.window),
+		Payload:     payload,
+	})
+}
+
+// Run queues digests of ended windows until ctx is cancelled
+func (d *EmailDigester) Run(ctx context.Context) {
+	ticker := time.NewTicker(emailDigestInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Failed to queue email digests", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush queues one digest per user window that ended at least the grace period ago
+func (d *EmailDigester) Flush(ctx context.Context) error {
+	before := d.now().UTC().Add(-emailDigestGrace).Truncate(d.window)
+	groups, err := d.repo.DueGroups(ctx, before)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if err := d.flushGroup(ctx, group); err != nil {
+			return fmt.Errorf("digest of %s at %s: %w", group.UserID, group.WindowStart.Format(time.RFC3339), err)
+		}
+	}
+	return nil
+}
+
+func (d *EmailDigester) flushGroup(ctx context.Context, group dto.EmailDigestGroup) error {
+	items, err := d.repo.Items(ctx, group)
+	if err != nil {
+		return err
+	}
+	digest := alertDigestEvent{
+		Event:       EventAlertDigest,
+		UserID:      group.UserID,
+		WindowStart: group.WindowStart,
+		WindowEnd:   group.WindowStart.Add(d.window),
+	}
+	for _, item := range items {
+		var event alertTriggeredEvent
+		if err := json.Unmarshal(item.Payload, &event); err != nil {
+			slog.Warn("Dropping unreadable digest item", "alert_id", item.AlertID, "trigger_id", item.TriggerID, "error", err)
+			continue
+		}
+		digest.Triggers = append(digest.Triggers, event)
+	}
+	if len(digest.Triggers) > 0 {
+		notBefore, displayTimezone, err := d.policy.deliverAtUser(ctx, group.UserID, d.now())
+		if err != nil {
+			return err
+		}
+		// Without a policy to look the owner up, the held triggers carry it
+		if displayTimezone == "" {
+			displayTimezone = digest.Triggers[0].DisplayTimezone
+		}
+		digest.DisplayTimezone = displayTimezone
+		payload, err := json.Marshal(digest)
+		if err != nil {
+			return err
+		}
+		notification, err := d.outbox.Enqueue(ctx, &dto.NotificationEnqueueRequest{
+			// Digests belong to no single alert; the trigger id names the window
+			TriggerID:   "digest:" + group.UserID + ":" + strconv.FormatInt(group.WindowStart.Unix(), 10),
+			Channel:     dto.NotificationChannelEmail,
+			Destination: group.Email,
+			Payload:     payload,
+			NotBefore:   notBefore,
+		})
+		if err != nil {
+			return err
+		}
+		metrics.Default.Counter("email_digests_queued_total", nil).Inc()
+		slog.Info("email digest queued", "user_id", group.UserID, "triggers", len(digest.Triggers),
+			"notification_id", notification.ID)
+	}
+	return d.repo.Remove(ctx, group)
+}
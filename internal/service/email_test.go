@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/pkg/money"
+)
+
+// fakeTransport captures the mail handed to it instead of talking SMTP
+type fakeTransport struct {
+	err  error
+	sent []sentMail
+}
+
+type sentMail struct {
+	from string
+	to   []string
+	msg  []byte
+}
+
+func (t *fakeTransport) SendMail(ctx context.Context, from string, to []string, msg []byte) error {
+	t.sent = append(t.sent, sentMail{from: from, to: to, msg: msg})
+	return t.err
+}
+
+// readEmail returns the decoded subject and the text and HTML parts of msg
+func readEmail(t *testing.T, msg []byte) (subject, text, html string) {
+	t.Helper()
+	parsed, err := mail.ReadMessage(strings.NewReader(string(msg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err = new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(part)
+		switch mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); mediaType {
+		case "text/plain":
+			text = string(body)
+		case "text/html":
+			html = string(body)
+		}
+	}
+	return subject, text, html
+}
+
+func testEmailSender(transport MailTransport) *EmailSender {
+	return NewEmailSender(transport, EmailConfig{From: "Alerts <alerts@example.com>", UnsubscribeURL: "https://example.com/settings"}, time.UTC)
+}
+
+func triggeredPayload(t *testing.T, name string) []byte {
+	t.Helper()
+	payload, err := json.Marshal(alertTriggeredEvent{
+		Event:       EventAlertTriggered,
+		AlertID:     "a1",
+		Name:        name,
+		Symbol:      "GP",
+		Rule:        dto.AlertRuleAbove,
+		Threshold:   money.FromFloat(350),
+		TriggerID:   "t1",
+		Price:       money.FromFloat(351.25),
+		Reason:      "price 351.25 is above 350.00",
+		TriggeredAt: time.Date(2024, 3, 4, 5, 30, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+// A trigger is sent as one text and HTML email to the validated address, with
+// the alert's details, an escaped name and the unsubscribe hint
+func TestEmailSenderSend(t *testing.T) {
+	transport := &fakeTransport{}
+	sender := testEmailSender(transport)
+	if err := sender.Send(context.Background(), "alice@example.com", triggeredPayload(t, "GP <breakout>")); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(transport.sent))
+	}
+	sent := transport.sent[0]
+	if sent.from != "alerts@example.com" || len(sent.to) != 1 || sent.to[0] != "alice@example.com" {
+		t.Errorf("sent from %s to %v", sent.from, sent.to)
+	}
+
+	subject, text, html := readEmail(t, sent.msg)
+	if subject != "GP is above 350.00: GP <breakout>" {
+		t.Errorf("subject %q", subject)
+	}
+	for _, want := range []string{"GP <breakout>", "GP is above 350.00", "Price: 351.25", "04 Mar 2024 05:30 UTC", "Reason: price 351.25 is above 350.00", "https://example.com/settings"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text part lacks %q:\n%s", want, text)
+		}
+	}
+	for _, want := range []string{"GP &lt;breakout&gt;", "<td>351.25</td>", `<a href="https://example.com/settings">`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML part lacks %q:\n%s", want, html)
+		}
+	}
+}
+
+// The freshness of the price follows it in both parts, in the sender's location
+func TestEmailSenderFreshness(t *testing.T) {
+	transport := &fakeTransport{}
+	sender := testEmailSender(transport)
+	feedMs := int64(45000)
+	payload, err := json.Marshal(alertTriggeredEvent{
+		Event:       EventAlertTriggered,
+		Symbol:      "GP",
+		Rule:        dto.AlertRuleAbove,
+		Threshold:   money.FromFloat(350),
+		Price:       money.FromFloat(351.25),
+		TriggeredAt: time.Date(2024, 3, 4, 5, 30, 0, 0, time.UTC),
+		Lag:         &dto.TickLag{AsOf: time.Date(2024, 3, 4, 5, 29, 13, 0, time.UTC), FeedMs: &feedMs, IngestMs: 2000, TotalMs: 47000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(context.Background(), "alice@example.com", payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(transport.sent))
+	}
+	_, text, html := readEmail(t, transport.sent[0].msg)
+	if want := "Price: 351.25 (as of 05:29:13, 47s delayed)"; !strings.Contains(text, want) {
+		t.Errorf("text part lacks %q:\n%s", want, text)
+	}
+	if want := "<td>351.25 (as of 05:29:13, 47s delayed)</td>"; !strings.Contains(html, want) {
+		t.Errorf("HTML part lacks %q:\n%s", want, html)
+	}
+}
+
+// Invalid addresses and payloads are undeliverable and never reach the
+// transport; a transport failure is left to the outbox to retry
+func TestEmailSenderErrors(t *testing.T) {
+	ctx := context.Background()
+	transport := &fakeTransport{}
+	sender := testEmailSender(transport)
+	for _, destination := range []string{"not-an-email", "Alice <alice@example.com>", ""} {
+		if err := sender.Send(ctx, destination, triggeredPayload(t, "GP")); !errors.Is(err, ErrUndeliverable) {
+			t.Errorf("sending to %q returned %v, want ErrUndeliverable", destination, err)
+		}
+	}
+	if err := sender.Send(ctx, "alice@example.com", []byte(`{"event":"alert.unknown"}`)); !errors.Is(err, ErrUndeliverable) {
+		t.Errorf("sending an unknown event returned %v, want ErrUndeliverable", err)
+	}
+	if len(transport.sent) != 0 {
+		t.Fatalf("%d undeliverable emails reached the transport", len(transport.sent))
+	}
+
+	transport.err = errors.New("connection reset")
+	err := sender.Send(ctx, "alice@example.com", triggeredPayload(t, "GP"))
+	if err == nil || errors.Is(err, ErrUndeliverable) {
+		t.Errorf("a transport failure returned %v, want a retryable error", err)
+	}
+}
+
+// The triggers of an hourly user are queued as one digest email once the
+// window has ended, and render as a summary
+func TestEmailDigest(t *testing.T) {
+	ctx := context.Background()
+	outbox := repository.NewMemoryNotificationRepository()
+	digester := NewEmailDigester(repository.NewMemoryEmailDigestRepository(), outbox, nil, time.Hour)
+	now := time.Date(2024, 3, 4, 5, 10, 0, 0, time.UTC)
+	digester.now = func() time.Time { return now }
+
+	for _, id := range []string{"t1", "t2"} {
+		event := alertTriggeredEvent{Event: EventAlertTriggered, AlertID: "a-" + id, Name: "Alert " + id, Symbol: "GP",
+			Rule: dto.AlertRuleBelow, Threshold: money.FromFloat(300), TriggerID: id, Price: money.FromFloat(299), TriggeredAt: now}
+		if err := digester.Add(ctx, "alice", "alice@example.com", event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := digester.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if queued, _ := outbox.FindByStatus(ctx, dto.NotificationStatusPending, 0); len(queued) != 0 {
+		t.Fatalf("queued %d digests before the window ended", len(queued))
+	}
+
+	now = now.Add(time.Hour)
+	if err := digester.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	queued, _ := outbox.FindByStatus(ctx, dto.NotificationStatusPending, 0)
+	if len(queued) != 1 || queued[0].Channel != dto.NotificationChannelEmail || queued[0].Destination != "alice@example.com" {
+		t.Fatalf("queued %+v, want one email digest to alice", queued)
+	}
+	// A flushed window is released
+	if err := digester.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := outbox.FindByStatus(ctx, dto.NotificationStatusPending, 0); len(again) != 1 {
+		t.Errorf("queued %d digests after flushing again, want 1", len(again))
+	}
+
+	rendered, err := testEmailSender(&fakeTransport{}).Render(queued[0].Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Subject != "2 stock alerts triggered, 04 Mar 2024 05:00 to 06:00 UTC" {
+		t.Errorf("subject %q", rendered.Subject)
+	}
+	for _, want := range []string{"Alert t1", "Alert t2", "GP is below 300.00"} {
+		if !strings.Contains(rendered.Text, want) || !strings.Contains(rendered.HTML, want) {
+			t.Errorf("digest lacks %q", want)
+		}
+	}
+}
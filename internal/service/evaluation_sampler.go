@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/metrics"
+)
+
+const (
+	// DefaultEvaluationRetention is how long sampled evaluations are kept
+	DefaultEvaluationRetention = 24 * time.Hour
+	// DefaultEvaluationSamplingTTL is how long an alert is sampled when the request has no TTL
+	DefaultEvaluationSamplingTTL = time.Hour
+	// MaxEvaluationSamplingTTL bounds how long an alert is sampled for
+	MaxEvaluationSamplingTTL = 24 * time.Hour
+	// MaxEvaluationSamples bounds the samples GetEvaluations returns
+	MaxEvaluationSamples = 500
+
+	// evaluationSamplingRefresh is how often sampling flags set by other
+	// replicas are picked up
+	evaluationSamplingRefresh = 10 * time.Second
+	evaluationSampleFlush     = time.Second
+	evaluationSampleBatch     = 100
+	evaluationSampleQueue     = 1000
+)
+
+// Values of EvaluationSampleRequest.SampledBy
+const (
+	SampledByAlert = "alert"
+	SampledByRate  = "rate"
+	// SampledByShadow records every fire of an alert in shadow mode
+	SampledByShadow = "shadow"
+)
+
+// EvaluationSamplingConfig is the global sampling rate and how long samples are kept
+type EvaluationSamplingConfig struct {
+	// Rate is the fraction of all evaluations sampled, 0 to turn global sampling off
+	Rate      float64
+	Retention time.Duration
+}
+
+// LoadEvaluationSamplingConfig reads ALERT_EVALUATION_SAMPLE_RATE (0 to 1,
+// default 0) and ALERT_EVALUATION_RETENTION (e.g. "6h", default 24h)
+func LoadEvaluationSamplingConfig() (EvaluationSamplingConfig, error) {
+	cfg := EvaluationSamplingConfig{Retention: DefaultEvaluationRetention}
+	if raw := os.Getenv("ALERT_EVALUATION_SAMPLE_RATE"); raw != "" {
+		rate, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return cfg, fmt.Errorf("ALERT_EVALUATION_SAMPLE_RATE must be a number from 0 to 1, got %q", raw)
+		}
+		cfg.Rate = rate
+	}
+	if raw := os.Getenv("ALERT_EVALUATION_RETENTION"); raw != "" {
+		retention, err := time.ParseDuration(raw)
+		if err != nil || retention <= 0 {
+			return cfg, fmt.Errorf("ALERT_EVALUATION_RETENTION must be a positive duration, got %q", raw)
+		}
+		cfg.Retention = retention
+	}
+	return cfg, nil
+}
+
+// EvaluationSampler records the tick evaluator's decisions for alerts sampled
+// through the admin endpoint and for a global sampling rate. Samples are
+// queued and written in batches by Run, so sampling never blocks an ingest; a
+// full queue drops samples. Sampling flags live in the repository with their
+// expiry and are reloaded periodically, so every replica samples the alert.
+type EvaluationSampler struct {
+	repo   domain.EvaluationSampleRepository
+	alerts domain.AlertRepository
+	cfg    EvaluationSamplingConfig
+
+	// on is set while the rate is positive or any alert is sampled, so the
+	// evaluator pays a single atomic load when sampling is off
+	on atomic.Bool
+
+	mu      sync.RWMutex
+	sampled map[string]time.Time
+
+	queue chan dto.EvaluationSampleRequest
+}
+
+func NewEvaluationSampler(repo domain.EvaluationSampleRepository, alerts domain.AlertRepository, cfg EvaluationSamplingConfig) *EvaluationSampler {
+	metrics.Default.Describe("alert_evaluation_samples_total", "Sampled alert evaluations, by result")
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultEvaluationRetention
+	}
+	s := &EvaluationSampler{
+		repo:    repo,
+		alerts:  alerts,
+		cfg:     cfg,
+		sampled: make(map[string]time.Time),
+		queue:   make(chan dto.EvaluationSampleRequest, evaluationSampleQueue),
+	}
+	s.on.Store(cfg.Rate > 0)
+	return s
+}
+
+// On reports whether any evaluation may be sampled. It is safe on a nil sampler.
+func (s *EvaluationSampler) On() bool {
+	return s != nil && s.on.Load()
+}
+
+// Sampled returns why an evaluation of the alert at now is sampled, or "" when it is not
+func (s *EvaluationSampler) Sampled(alertID string, now time.Time) string {
+	s.mu.RLock()
+	expiresAt, ok := s.sampled[alertID]
+	s.mu.RUnlock()
+	if ok && now.Before(expiresAt) {
+		return SampledByAlert
+	}
+	if s.cfg.Rate > 0 && rand.Float64() < s.cfg.Rate {
+		return SampledByRate
+	}
+	return ""
+}
+
+// Record queues a sample for Run to store, dropping it when the queue is full
+func (s *EvaluationSampler) Record(sample dto.EvaluationSampleRequest) {
+	sample.ExpiresAt = sample.EvaluatedAt.Add(s.cfg.Retention)
+	select {
+	case s.queue <- sample:
+	default:
+		metrics.Default.Counter("alert_evaluation_samples_total", metrics.Labels{"result": "dropped"}).Inc()
+	}
+}
+
+// Run stores queued samples and reloads sampling flags until ctx is done
+func (s *EvaluationSampler) Run(ctx context.Context) {
+	refresh := time.NewTicker(evaluationSamplingRefresh)
+	defer refresh.Stop()
+	flush := time.NewTicker(evaluationSampleFlush)
+	defer flush.Stop()
+
+	s.refresh(ctx)
+	var batch []dto.EvaluationSampleRequest
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sample := <-s.queue:
+			batch = append(batch, sample)
+			if len(batch) >= evaluationSampleBatch {
+				batch = s.store(ctx, batch)
+			}
+		case <-flush.C:
+			batch = s.store(ctx, batch)
+		case <-refresh.C:
+			s.refresh(ctx)
+		}
+	}
+}
+
+// store writes a batch and returns it emptied for reuse; a failed batch is dropped
+func (s *EvaluationSampler) store(ctx context.Context, batch []dto.EvaluationSampleRequest) []dto.EvaluationSampleRequest {
+	if len(batch) == 0 {
+		return batch
+	}
+	result := "stored"
+	if err := s.repo.Insert(ctx, batch); err != nil {
+		result = "error"
+		if ctx.Err() == nil {
+			slog.Warn("Failed to store sampled alert evaluations", "samples", len(batch), "error", err)
+		}
+	}
+	metrics.Default.Counter("alert_evaluation_samples_total", metrics.Labels{"result": result}).Add(int64(len(batch)))
+	return batch[:0]
+}
+
+// refresh reloads the sampled alerts, keeping the previous ones on error
+func (s *EvaluationSampler) refresh(ctx context.Context) {
+	flags, err := s.repo.ActiveSampling(ctx, time.Now().UTC())
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("Failed to reload sampled alerts; keeping the previous ones", "error", err)
+		}
+		return
+	}
+	s.mu.Lock()
+	s.sampled = flags
+	s.mu.Unlock()
+	s.on.Store(s.cfg.Rate > 0 || len(flags) > 0)
+}
+
+func (s *EvaluationSampler) SampleAlert(ctx context.Context, id string, req dto.EvaluationSamplingRequest) (*dto.EvaluationSamplingResponse, error) {
+	ttl := DefaultEvaluationSamplingTTL
+	if req.TTL != "" {
+		parsed, err := time.ParseDuration(req.TTL)
+		if err != nil || parsed <= 0 || parsed > MaxEvaluationSamplingTTL {
+			return nil, fmt.Errorf("ttl must be a positive duration up to %s: %w", MaxEvaluationSamplingTTL, domain.ErrValidation)
+		}
+		ttl = parsed
+	}
+	alert, err := s.alerts.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, domain.ErrAlertNotFound
+	}
+
+	expiresAt := time.Now().UTC().Add(ttl)
+	if err := s.repo.SetSampling(ctx, id, expiresAt); err != nil {
+		return nil, err
+	}
+	// Other replicas pick the flag up on their next refresh
+	s.mu.Lock()
+	s.sampled[id] = expiresAt
+	s.mu.Unlock()
+	s.on.Store(true)
+	return &dto.EvaluationSamplingResponse{AlertID: id, ExpiresAt: expiresAt}, nil
+}
+
+func (s *EvaluationSampler) GetEvaluations(ctx context.Context, id string, shadowOnly bool) ([]dto.EvaluationSampleResponse, error) {
+	alert, err := s.alerts.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, domain.ErrAlertNotFound
+	}
+	return s.repo.FindByAlert(ctx, id, time.Now().UTC(), MaxEvaluationSamples, shadowOnly)
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+)
+
+// storeQueued writes the samples queued so far, as Run does on its next flush
+func storeQueued(t *testing.T, sampler *EvaluationSampler) {
+	t.Helper()
+	var batch []dto.EvaluationSampleRequest
+	for {
+		select {
+		case sample := <-sampler.queue:
+			batch = append(batch, sample)
+		default:
+			sampler.store(context.Background(), batch)
+			return
+		}
+	}
+}
+
+// Sampling is off by default and then records nothing; a sampled alert gets
+// every decision recorded, newest first
+func TestEvaluationSampler(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	alert, _ := alerts.Create(ctx, alertRequest("GP", dto.AlertStatusActive))
+	other, _ := alerts.Create(ctx, alertRequest("GP", dto.AlertStatusActive))
+	sampler := NewEvaluationSampler(repository.NewMemoryEvaluationSampleRepository(), alerts, EvaluationSamplingConfig{})
+	cache := NewAlertCache(alerts, time.Minute)
+	if err := cache.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	evaluator := NewTickEvaluator(cache, alerts, &countingNotifications{}, sampler, nil)
+	now := time.Now().UTC()
+
+	if sampler.On() || (*EvaluationSampler)(nil).On() {
+		t.Fatal("sampling is on by default")
+	}
+	tick, latest := crossingTick("GP", 90, now)
+	evaluator.Evaluate(ctx, tick, latest, latest.TradingDate)
+	if queued := len(sampler.queue); queued != 0 {
+		t.Fatalf("got %d samples queued with sampling off, want 0", queued)
+	}
+
+	flag, err := sampler.SampleAlert(ctx, alert.ID, dto.EvaluationSamplingRequest{TTL: "30m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sampler.On() || flag.ExpiresAt.Sub(now) < 29*time.Minute {
+		t.Fatalf("got on %v until %s, want on for 30m", sampler.On(), flag.ExpiresAt)
+	}
+	for i, price := range []float64{110, 95} {
+		tick, latest := crossingTick("GP", price, now.Add(time.Duration(i+1)*time.Second))
+		evaluator.Evaluate(ctx, tick, latest, latest.TradingDate)
+	}
+	storeQueued(t, sampler)
+
+	samples, err := sampler.GetEvaluations(ctx, alert.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []dto.EvaluationOutcome{dto.EvaluationRearmed, dto.EvaluationFired}
+	if len(samples) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(samples), len(want))
+	}
+	for i, sample := range samples {
+		if sample.Outcome != want[i] || sample.SampledBy != SampledByAlert || len(sample.Gates) == 0 {
+			t.Errorf("got sample %d %+v, want %s sampled by alert with gates", i, sample, want[i])
+		}
+	}
+	if !samples[0].WasTriggered || samples[1].WasTriggered {
+		t.Errorf("got triggered before %v and %v, want true and false", samples[0].WasTriggered, samples[1].WasTriggered)
+	}
+	if got, _ := sampler.GetEvaluations(ctx, other.ID, false); len(got) != 0 {
+		t.Errorf("got %d samples of the unsampled alert, want 0", len(got))
+	}
+}
+
+// A global rate of 1 samples every alert without a flag
+func TestEvaluationSamplerRate(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	alert, _ := alerts.Create(ctx, alertRequest("GP", dto.AlertStatusActive))
+	sampler := NewEvaluationSampler(repository.NewMemoryEvaluationSampleRepository(), alerts, EvaluationSamplingConfig{Rate: 1})
+	evaluator := newTestTickEvaluator(t, alerts, &countingNotifications{})
+	evaluator.sampler = sampler
+
+	tick, latest := crossingTick("GP", 101, time.Now().UTC())
+	evaluator.Evaluate(ctx, tick, latest, latest.TradingDate)
+	storeQueued(t, sampler)
+
+	samples, _ := sampler.GetEvaluations(ctx, alert.ID, false)
+	if len(samples) != 1 || samples[0].SampledBy != SampledByRate || samples[0].Outcome != dto.EvaluationFired {
+		t.Errorf("got %+v, want one fire sampled by rate", samples)
+	}
+}
+
+// Sampling flags and stored samples both expire: an expired flag stops
+// sampling and turns sampling off on the next refresh, and samples older than
+// the retention are no longer returned
+func TestEvaluationSamplerExpiry(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	alert, _ := alerts.Create(ctx, alertRequest("GP", dto.AlertStatusActive))
+	sampler := NewEvaluationSampler(repository.NewMemoryEvaluationSampleRepository(), alerts, EvaluationSamplingConfig{Retention: time.Hour})
+
+	if _, err := sampler.SampleAlert(ctx, alert.ID, dto.EvaluationSamplingRequest{TTL: "10ms"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sampler.Sampled(alert.ID, time.Now()); got != SampledByAlert {
+		t.Fatalf("got sampled by %q, want alert", got)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := sampler.Sampled(alert.ID, time.Now()); got != "" {
+		t.Errorf("got sampled by %q after the TTL, want not sampled", got)
+	}
+	sampler.refresh(ctx)
+	if sampler.On() {
+		t.Error("sampling is still on after the flag expired")
+	}
+
+	now := time.Now().UTC()
+	sampler.Record(dto.EvaluationSampleRequest{AlertID: alert.ID, Outcome: dto.EvaluationUnchanged, EvaluatedAt: now.Add(-2 * time.Hour)})
+	sampler.Record(dto.EvaluationSampleRequest{AlertID: alert.ID, Outcome: dto.EvaluationFired, EvaluatedAt: now})
+	storeQueued(t, sampler)
+	samples, _ := sampler.GetEvaluations(ctx, alert.ID, false)
+	if len(samples) != 1 || samples[0].Outcome != dto.EvaluationFired {
+		t.Errorf("got %+v, want only the sample within the retention", samples)
+	}
+	if want := now.Add(time.Hour); len(samples) == 1 && !samples[0].ExpiresAt.Equal(want) {
+		t.Errorf("got expiry %s, want %s", samples[0].ExpiresAt, want)
+	}
+}
+
+// Sampling requests for unknown alerts or with a TTL out of range are rejected
+func TestSampleAlertErrors(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	alert, _ := alerts.Create(ctx, alertRequest("GP", dto.AlertStatusActive))
+	sampler := NewEvaluationSampler(repository.NewMemoryEvaluationSampleRepository(), alerts, EvaluationSamplingConfig{})
+
+	for _, tc := range []struct {
+		name    string
+		id      string
+		ttl     string
+		wantErr error
+	}{
+		{name: "unknown alert", id: "missing", wantErr: domain.ErrAlertNotFound},
+		{name: "malformed ttl", id: alert.ID, ttl: "soon", wantErr: domain.ErrValidation},
+		{name: "negative ttl", id: alert.ID, ttl: "-1h", wantErr: domain.ErrValidation},
+		{name: "ttl over the max", id: alert.ID, ttl: "25h", wantErr: domain.ErrValidation},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := sampler.SampleAlert(ctx, tc.id, dto.EvaluationSamplingRequest{TTL: tc.ttl})
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("got %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+	if sampler.On() {
+		t.Error("a rejected request turned sampling on")
+	}
+}
+
+// The sampling rate and retention come from the environment, off by default
+func TestLoadEvaluationSamplingConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		rate          string
+		retention     string
+		wantRate      float64
+		wantRetention time.Duration
+		wantErr       bool
+	}{
+		{name: "defaults", wantRetention: DefaultEvaluationRetention},
+		{name: "set", rate: "0.25", retention: "6h", wantRate: 0.25, wantRetention: 6 * time.Hour},
+		{name: "rate over 1", rate: "1.5", wantErr: true},
+		{name: "negative rate", rate: "-0.1", wantErr: true},
+		{name: "malformed retention", retention: "a day", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ALERT_EVALUATION_SAMPLE_RATE", tc.rate)
+			t.Setenv("ALERT_EVALUATION_RETENTION", tc.retention)
+			cfg, err := LoadEvaluationSamplingConfig()
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got %+v, want an error", cfg)
+				}
+				return
+			}
+			if err != nil || cfg.Rate != tc.wantRate || cfg.Retention != tc.wantRetention {
+				t.Errorf("got %+v, %v, want rate %v and retention %s", cfg, err, tc.wantRate, tc.wantRetention)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hello-api/internal/handler/dto"
+)
+
+// DefaultEvaluatorDebugMaxEntries is the most alerts or symbols one call to
+// the evaluator debug routes returns
+const DefaultEvaluatorDebugMaxEntries = 200
+
+// Where the triggered state of an alert in the evaluator comes from
+const (
+	StateFromEvaluator = "evaluator"
+	StateFromIndex     = "index"
+)
+
+// LoadEvaluatorDebugMaxEntries reads EVALUATOR_DEBUG_MAX_ENTRIES, the most
+// alerts or symbols one call to the evaluator debug routes returns (default 200)
+func LoadEvaluatorDebugMaxEntries() (int, error) {
+	raw := os.Getenv("EVALUATOR_DEBUG_MAX_ENTRIES")
+	if raw == "" {
+		return DefaultEvaluatorDebugMaxEntries, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("EVALUATOR_DEBUG_MAX_ENTRIES must be a positive integer, got %q", raw)
+	}
+	return limit, nil
+}
+
+// IndexForSymbol returns up to limit of the alerts the evaluator watches for
+// symbol, in ID order from the first ID after after, with the state it holds
+// for each. The symbol is normalized as ticks are, so an alert stored under a
+// name the feed never sends shows up missing here.
+func (e *TickEvaluator) IndexForSymbol(symbol, after string, limit int) dto.EvaluatorIndexResponse {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	_, status := e.alerts.snapshot()
+	alerts := e.AlertsForSymbol(symbol)
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].ID < alerts[j].ID })
+
+	day, seen := e.day(symbol)
+	response := dto.EvaluatorIndexResponse{
+		AlertIndexSync: status,
+		Symbol:         symbol,
+		Alerts:         []dto.EvaluatorAlert{},
+		Total:          len(alerts),
+	}
+	if seen {
+		response.LastPrice = day.latest.Price.Ptr()
+		response.LastTickAt = &day.latest.Time
+	}
+	start := sort.Search(len(alerts), func(i int) bool { return alerts[i].ID > after })
+	for _, alert := range alerts[start:] {
+		if len(response.Alerts) == limit {
+			response.Next = response.Alerts[limit-1].ID
+			break
+		}
+		response.Alerts = append(response.Alerts, e.indexedAlert(alert, day, seen))
+	}
+	return response
+}
+
+// IndexSummary returns up to limit of the symbols the evaluator watches, in
+// order from the first symbol after after, with how many alerts watch each
+func (e *TickEvaluator) IndexSummary(after string, limit int) dto.EvaluatorSummaryResponse {
+	index, status := e.alerts.snapshot()
+	symbols := make([]string, 0, len(index))
+	response := dto.EvaluatorSummaryResponse{
+		AlertIndexSync: status,
+		Symbols:        []dto.EvaluatorSymbolSummary{},
+		TotalSymbols:   len(index),
+	}
+	for symbol, alerts := range index {
+		symbols = append(symbols, symbol)
+		response.TotalAlerts += len(alerts)
+	}
+	sort.Strings(symbols)
+
+	after = strings.ToUpper(strings.TrimSpace(after))
+	start := sort.SearchStrings(symbols, after)
+	if start < len(symbols) && symbols[start] == after {
+		start++
+	}
+	for _, symbol := range symbols[start:] {
+		if len(response.Symbols) == limit {
+			response.Next = response.Symbols[limit-1].Symbol
+			break
+		}
+		summary := dto.EvaluatorSymbolSummary{Symbol: symbol, Alerts: len(index[symbol])}
+		for _, alert := range index[symbol] {
+			if e.triggered(alert) {
+				summary.Triggered++
+			}
+		}
+		if day, seen := e.day(symbol); seen {
+			summary.LastPrice = day.latest.Price.Ptr()
+			summary.LastTickAt = &day.latest.Time
+		}
+		response.Symbols = append(response.Symbols, summary)
+	}
+	return response
+}
+
+// ResyncIndex reloads the alert index from the repository now rather than at
+// the next refresh, and returns its sync state. On failure the previous index
+// is kept.
+func (e *TickEvaluator) ResyncIndex(ctx context.Context) (dto.AlertIndexSync, error) {
+	err := e.alerts.Refresh(ctx)
+	_, status := e.alerts.snapshot()
+	return status, err
+}
+
+// indexedAlert resolves an alert from AlertsForSymbol against the symbol's
+// last evaluated tick, if seen
+func (e *TickEvaluator) indexedAlert(alert dto.AlertResponse, day symbolDay, seen bool) dto.EvaluatorAlert {
+	entry := dto.EvaluatorAlert{AlertResponse: alert, Shadowed: e.shadowed(alert), StateSource: StateFromIndex}
+	e.mu.Lock()
+	state, ok := e.states[alert.ID]
+	e.mu.Unlock()
+	if ok && state.updatedAt.Equal(alert.UpdatedAt) {
+		entry.StateSource = StateFromEvaluator
+	}
+
+	var latest *dto.LatestPriceResponse
+	if seen {
+		latest = &day.latest
+	}
+	trigger, baseline, ok := triggerPrice(alert, latest, day.tradingDate)
+	if !ok {
+		return entry
+	}
+	entry.TriggerPrice = trigger.Ptr()
+	if alert.Rule.IsPercentRule() {
+		entry.BaselinePrice = baseline.Ptr()
+	}
+	if seen {
+		entry.LastSide = "unmet"
+		if thresholdGate(alert, day.latest.Price, latest, day.tradingDate).Passed {
+			entry.LastSide = "met"
+		}
+	}
+	return entry
+}
+
+// day returns the symbol's latest price as of its last evaluated tick
+func (e *TickEvaluator) day(symbol string) (symbolDay, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	day, ok := e.days[symbol]
+	return day, ok
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/pkg/money"
+)
+
+// The debug view of a symbol pages through the alerts AlertsForSymbol
+// returns, and says where each triggered state comes from; after a tick at
+// 115 the alerts above 100 and 110 have fired and the one above 120 has not
+func TestIndexForSymbol(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	for _, price := range []float64{100, 110, 120} {
+		if _, err := alerts.Create(ctx, &dto.AlertCreateRequest{UserID: "alice", Symbol: "GP", Rule: dto.AlertRuleAbove,
+			Price: money.FromFloat(price), Status: dto.AlertStatusActive, EvaluateOffHours: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	evaluator := newTestTickEvaluator(t, alerts, &countingNotifications{})
+	tick, latest := crossingTick("GP", 115, time.Now().UTC())
+	if fired := evaluator.Evaluate(ctx, tick, latest, latest.TradingDate); fired != 2 {
+		t.Fatalf("fired %d alerts, want 2", fired)
+	}
+
+	want := make(map[string]bool)
+	for _, alert := range evaluator.AlertsForSymbol("GP") {
+		want[alert.ID] = alert.Triggered
+	}
+	var pages []int
+	got := make(map[string]string)
+	after := ""
+	for {
+		page := evaluator.IndexForSymbol(" gp ", after, 2)
+		if page.Symbol != "GP" || page.Total != 3 {
+			t.Fatalf("got symbol %q with %d alerts, want GP with 3", page.Symbol, page.Total)
+		}
+		pages = append(pages, len(page.Alerts))
+		for _, alert := range page.Alerts {
+			got[alert.ID] = fmt.Sprintf("%t:%s:%s", alert.Triggered, alert.StateSource, alert.LastSide)
+		}
+		if page.Next == "" {
+			break
+		}
+		after = page.Next
+	}
+	if fmt.Sprint(pages) != "[2 1]" {
+		t.Errorf("got pages of %v, want [2 1]", pages)
+	}
+	// Only the alerts that fired have a state of the evaluator's own
+	for id, triggered := range want {
+		wantEntry := "false:" + StateFromIndex + ":unmet"
+		if triggered {
+			wantEntry = "true:" + StateFromEvaluator + ":met"
+		}
+		if got[id] != wantEntry {
+			t.Errorf("alert %s: got %s, want %s", id, got[id], wantEntry)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/metrics"
+)
+
+// DefaultFeatureFlagRefresh is how often the flag values set through the admin
+// route are reloaded, so a change made on another replica applies within it
+const DefaultFeatureFlagRefresh = 10 * time.Second
+
+// Flag is a feature flag defined in code. Its value is Default unless the
+// environment variable Env sets one at startup or an admin sets one at
+// runtime; an admin's value wins over the environment. Get takes no lock and
+// does no I/O, so it can be called on every request.
+type Flag[T any] struct {
+	Name        string
+	Env         string
+	Description string
+	Default     T
+	// parse reads a value written as in Env; format writes it back that way
+	parse  func(raw string) (T, error)
+	format func(value T) string
+}
+
+// Get returns the flag's current value, or its default when flags is nil
+func (f *Flag[T]) Get(flags *FeatureFlags) T {
+	if flags == nil {
+		return f.Default
+	}
+	if value, ok := flags.snapshot.Load().values[f.Name]; ok {
+		return value.value.(T)
+	}
+	return f.Default
+}
+
+func (f *Flag[T]) info() (name, env, description string) { return f.Name, f.Env, f.Description }
+
+func (f *Flag[T]) defaultValue() (any, string) { return f.Default, f.format(f.Default) }
+
+func (f *Flag[T]) parseValue(raw string) (any, string, error) {
+	value, err := f.parse(raw)
+	if err != nil {
+		return nil, "", err
+	}
+	return value, f.format(value), nil
+}
+
+// featureFlag is a Flag of any type
+type featureFlag interface {
+	info() (name, env, description string)
+	defaultValue() (value any, raw string)
+	// parseValue returns the value and its canonical form
+	parseValue(raw string) (value any, canonical string, err error)
+}
+
+// The feature flags; each is also listed in featureFlags
+var (
+	// FlagSymbolValidation is whether alert symbols must be known reference data
+	FlagSymbolValidation = &Flag[SymbolValidation]{
+		Name:        "symbol_validation",
+		Env:         "SYMBOL_VALIDATION",
+		Description: "strict rejects alerts for symbols missing from the reference data; off accepts any symbol",
+		Default:     SymbolValidationStrict,
+		parse:       parseSymbolValidation,
+		format:      func(validation SymbolValidation) string { return string(validation) },
+	}
+	// FlagShadowRules are the rules whose alerts all run in shadow mode
+	FlagShadowRules = &Flag[ShadowRules]{
+		Name:        "shadow_rules",
+		Env:         "ALERT_SHADOW_RULES",
+		Description: "comma-separated rules whose alerts fire without notifying anyone, for trying new rules out",
+		Default:     ShadowRules{},
+		parse:       parseShadowRules,
+		format:      ShadowRules.String,
+	}
+	// FlagLegacyRoutes is whether the routes are still served without the /v1 prefix
+	FlagLegacyRoutes = &Flag[bool]{
+		Name:        "legacy_routes",
+		Env:         "LEGACY_ROUTES",
+		Description: "serve every route without the /v1 prefix too, marked deprecated; false answers them 410 Gone",
+		Default:     true,
+		parse:       parseBoolFlag,
+		format:      strconv.FormatBool,
+	}
+)
+
+// featureFlags lists every flag, in the order they are reported
+var featureFlags = []featureFlag{FlagSymbolValidation, FlagShadowRules, FlagLegacyRoutes}
+
+// findFlag returns the flag with the given name, or nil
+func findFlag(name string) featureFlag {
+	for _, flag := range featureFlags {
+		if flagName, _, _ := flag.info(); flagName == name {
+			return flag
+		}
+	}
+	return nil
+}
+
+func parseBoolFlag(raw string) (bool, error) {
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("must be true or false, got %q", raw)
+	}
+	return value, nil
+}
+
+// flagValue is a flag's current value and where it comes from
+type flagValue struct {
+	value     any
+	raw       string
+	source    dto.FeatureFlagSource
+	updatedAt *time.Time
+}
+
+// FeatureFlagEnv holds the flag values set by the environment
+type FeatureFlagEnv struct {
+	values map[string]flagValue
+}
+
+// LoadFeatureFlags reads the environment variable of every flag; an unset
+// variable leaves the flag's default
+func LoadFeatureFlags() (FeatureFlagEnv, error) {
+	env := FeatureFlagEnv{values: make(map[string]flagValue)}
+	for _, flag := range featureFlags {
+		name, variable, _ := flag.info()
+		raw, ok := os.LookupEnv(variable)
+		if !ok || raw == "" {
+			continue
+		}
+		value, canonical, err := flag.parseValue(raw)
+		if err != nil {
+			return FeatureFlagEnv{}, fmt.Errorf("%s: %v", variable, err)
+		}
+		env.values[name] = flagValue{value: value, raw: canonical, source: dto.FeatureFlagSourceEnv}
+	}
+	return env, nil
+}
+
+// flagSnapshot is the value of every flag as of one reload
+type flagSnapshot struct {
+	values map[string]flagValue
+}
+
+// FeatureFlags resolves the feature flags from their defaults, the environment
+// and the values admins set. Admin values are stored in the repository and
+// reloaded every interval, and right away when set through this process.
+type FeatureFlags struct {
+	repo     domain.FeatureFlagRepository
+	env      FeatureFlagEnv
+	interval time.Duration
+
+	// mu serializes reloads, so a reload started before a change cannot
+	// replace the snapshot taken after it
+	mu       sync.Mutex
+	snapshot atomic.Pointer[flagSnapshot]
+}
+
+// NewFeatureFlags starts from the defaults and the environment; admin values
+// apply from the first Refresh
+func NewFeatureFlags(repo domain.FeatureFlagRepository, env FeatureFlagEnv, interval time.Duration) *FeatureFlags {
+	metrics.Default.Describe("feature_flag_refreshes_total", "Reloads of the feature flag values set by admins, by result")
+	if interval <= 0 {
+		interval = DefaultFeatureFlagRefresh
+	}
+	flags := &FeatureFlags{repo: repo, env: env, interval: interval}
+	flags.snapshot.Store(flags.resolve(nil))
+	return flags
+}
+
+// resolve takes every flag's default, then its environment value, then its admin value
+func (f *FeatureFlags) resolve(overrides []dto.FeatureFlagOverride) *flagSnapshot {
+	values := make(map[string]flagValue, len(featureFlags))
+	for _, flag := range featureFlags {
+		name, _, _ := flag.info()
+		value, raw := flag.defaultValue()
+		values[name] = flagValue{value: value, raw: raw, source: dto.FeatureFlagSourceDefault}
+		if env, ok := f.env.values[name]; ok {
+			values[name] = env
+		}
+	}
+	for _, override := range overrides {
+		flag := findFlag(override.Name)
+		if flag == nil {
+			// A flag since removed from the code
+			continue
+		}
+		value, raw, err := flag.parseValue(override.Value)
+		if err != nil {
+			slog.Warn("Ignoring an invalid stored feature flag value", "flag", override.Name, "value", override.Value, "error", err)
+			continue
+		}
+		updatedAt := override.UpdatedAt
+		values[override.Name] = flagValue{value: value, raw: raw, source: dto.FeatureFlagSourceAdmin, updatedAt: &updatedAt}
+	}
+	return &flagSnapshot{values: values}
+}
+
+// Refresh reloads the values set by admins. On failure the previous values are kept.
+func (f *FeatureFlags) Refresh(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	overrides, err := f.repo.FindAll(ctx)
+	if err != nil {
+		metrics.Default.Counter("feature_flag_refreshes_total", metrics.Labels{"result": "error"}).Inc()
+		return err
+	}
+	f.snapshot.Store(f.resolve(overrides))
+	metrics.Default.Counter("feature_flag_refreshes_total", metrics.Labels{"result": "ok"}).Inc()
+	return nil
+}
+
+// Run loads the admin values and keeps reloading them until ctx is done
+func (f *FeatureFlags) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.Refresh(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Failed to reload the feature flags; keeping the previous values", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Set stores an admin's value of a flag, written as in its environment
+// variable, and applies it in this process right away
+func (f *FeatureFlags) Set(ctx context.Context, name string, req dto.FeatureFlagRequest) (*dto.FeatureFlagResponse, error) {
+	flag := findFlag(name)
+	if flag == nil {
+		return nil, fmt.Errorf("no feature flag named %q: %w", name, domain.ErrFlagNotFound)
+	}
+	_, raw, err := flag.parseValue(req.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %w", name, err, domain.ErrValidation)
+	}
+	if err := f.repo.Set(ctx, name, raw, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("feature flag set", "flag", name, "value", raw)
+	return f.reloaded(ctx, flag)
+}
+
+// Clear removes an admin's value of a flag, so it falls back to the
+// environment or its default
+func (f *FeatureFlags) Clear(ctx context.Context, name string) (*dto.FeatureFlagResponse, error) {
+	flag := findFlag(name)
+	if flag == nil {
+		return nil, fmt.Errorf("no feature flag named %q: %w", name, domain.ErrFlagNotFound)
+	}
+	if err := f.repo.Delete(ctx, name); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("feature flag cleared", "flag", name)
+	return f.reloaded(ctx, flag)
+}
+
+// reloaded applies a change and returns the flag as it now stands
+func (f *FeatureFlags) reloaded(ctx context.Context, flag featureFlag) (*dto.FeatureFlagResponse, error) {
+	if err := f.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	response := f.describe(flag, f.snapshot.Load())
+	return &response, nil
+}
+
+// List returns every flag with its current value
+func (f *FeatureFlags) List() []dto.FeatureFlagResponse {
+	snapshot := f.snapshot.Load()
+	result := make([]dto.FeatureFlagResponse, 0, len(featureFlags))
+	for _, flag := range featureFlags {
+		result = append(result, f.describe(flag, snapshot))
+	}
+	return result
+}
+
+func (f *FeatureFlags) describe(flag featureFlag, snapshot *flagSnapshot) dto.FeatureFlagResponse {
+	name, env, description := flag.info()
+	_, defaultRaw := flag.defaultValue()
+	current := snapshot.values[name]
+	return dto.FeatureFlagResponse{
+		Name:        name,
+		Description: description,
+		Env:         env,
+		Default:     defaultRaw,
+		Value:       current.raw,
+		Source:      current.source,
+		UpdatedAt:   current.updatedAt,
+	}
+}
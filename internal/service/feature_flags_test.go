@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+)
+
+// failingFlagRepository is a FeatureFlagRepository whose reloads fail
+type failingFlagRepository struct {
+	domain.FeatureFlagRepository
+}
+
+func (failingFlagRepository) FindAll(ctx context.Context) ([]dto.FeatureFlagOverride, error) {
+	return nil, errors.New("test: database down")
+}
+
+// flagSources returns the value and source of every listed flag, by name
+func flagSources(flags *FeatureFlags) map[string]string {
+	sources := make(map[string]string)
+	for _, flag := range flags.List() {
+		sources[flag.Name] = flag.Value + " from " + string(flag.Source)
+	}
+	return sources
+}
+
+// Unset variables leave the defaults; set ones are parsed into the canonical
+// form, and a value a flag cannot take fails the load
+func TestLoadFeatureFlags(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		env     map[string]string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{
+				"symbol_validation": "strict from default",
+				"shadow_rules":      " from default",
+				"legacy_routes":     "true from default",
+			},
+		},
+		{
+			name: "set",
+			env:  map[string]string{"SYMBOL_VALIDATION": "off", "ALERT_SHADOW_RULES": " below,above", "LEGACY_ROUTES": "0"},
+			want: map[string]string{
+				"symbol_validation": "off from env",
+				"shadow_rules":      "above,below from env",
+				"legacy_routes":     "false from env",
+			},
+		},
+		{name: "unknown rule", env: map[string]string{"ALERT_SHADOW_RULES": "sideways"}, wantErr: "ALERT_SHADOW_RULES"},
+		{name: "not a bool", env: map[string]string{"LEGACY_ROUTES": "maybe"}, wantErr: "LEGACY_ROUTES: must be true or false"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, variable := range []string{"SYMBOL_VALIDATION", "ALERT_SHADOW_RULES", "LEGACY_ROUTES"} {
+				t.Setenv(variable, tc.env[variable])
+			}
+			env, err := LoadFeatureFlags()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("got %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := flagSources(NewFeatureFlags(repository.NewMemoryFeatureFlagRepository(), env, time.Minute))
+			for name, want := range tc.want {
+				if got[name] != want {
+					t.Errorf("%s: got %q, want %q", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+// An admin's value wins over the environment in this process right away and
+// on other replicas from their next refresh; clearing it falls back to the
+// environment
+func TestFeatureFlagOverrides(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("SYMBOL_VALIDATION", "off")
+	env, err := LoadFeatureFlags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.NewMemoryFeatureFlagRepository()
+	flags := NewFeatureFlags(repo, env, time.Minute)
+	replica := NewFeatureFlags(repo, env, time.Minute)
+
+	set, err := flags.Set(ctx, FlagSymbolValidation.Name, dto.FeatureFlagRequest{Value: "strict"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Value != "strict" || set.Source != dto.FeatureFlagSourceAdmin || set.UpdatedAt == nil || set.Default != "strict" {
+		t.Errorf("got %+v, want strict set by an admin", set)
+	}
+	if got := FlagSymbolValidation.Get(flags); got != SymbolValidationStrict {
+		t.Errorf("got %s in this process, want strict", got)
+	}
+	if got := FlagSymbolValidation.Get(replica); got != SymbolValidationOff {
+		t.Errorf("got %s on the replica before its refresh, want off", got)
+	}
+	if err := replica.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := FlagSymbolValidation.Get(replica); got != SymbolValidationStrict {
+		t.Errorf("got %s on the replica after its refresh, want strict", got)
+	}
+
+	// a failed reload keeps the values it had
+	stale := NewFeatureFlags(failingFlagRepository{repo}, env, time.Minute)
+	if err := stale.Refresh(ctx); err == nil {
+		t.Error("a failed reload returned no error")
+	}
+	if got := FlagSymbolValidation.Get(stale); got != SymbolValidationOff {
+		t.Errorf("got %s after a failed reload, want the environment's off", got)
+	}
+
+	cleared, err := flags.Clear(ctx, FlagSymbolValidation.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleared.Value != "off" || cleared.Source != dto.FeatureFlagSourceEnv || cleared.UpdatedAt != nil {
+		t.Errorf("got %+v after clearing, want off from the environment", cleared)
+	}
+	if got := flagSources(flags)["shadow_rules"]; got != " from default" {
+		t.Errorf("got shadow_rules %q, want it untouched", got)
+	}
+}
+
+// Unknown flags are not found, values a flag cannot take are rejected, and a
+// stored value that no longer parses is ignored
+func TestFeatureFlagErrors(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryFeatureFlagRepository()
+	flags := NewFeatureFlags(repo, FeatureFlagEnv{}, time.Minute)
+
+	if _, err := flags.Set(ctx, "dark_mode", dto.FeatureFlagRequest{Value: "true"}); !errors.Is(err, domain.ErrFlagNotFound) {
+		t.Errorf("got %v setting an unknown flag, want ErrFlagNotFound", err)
+	}
+	if _, err := flags.Clear(ctx, "dark_mode"); !errors.Is(err, domain.ErrFlagNotFound) {
+		t.Errorf("got %v clearing an unknown flag, want ErrFlagNotFound", err)
+	}
+	for name, value := range map[string]string{
+		FlagSymbolValidation.Name: "lenient",
+		FlagShadowRules.Name:      "above,sideways",
+		FlagLegacyRoutes.Name:     "maybe",
+	} {
+		if _, err := flags.Set(ctx, name, dto.FeatureFlagRequest{Value: value}); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("%s=%s: got %v, want ErrValidation", name, value, err)
+		}
+	}
+	if overrides, _ := repo.FindAll(ctx); len(overrides) != 0 {
+		t.Errorf("got %d stored values after rejected sets, want 0", len(overrides))
+	}
+
+	if err := repo.Set(ctx, FlagLegacyRoutes.Name, "maybe", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Set(ctx, "removed_flag", "on", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := flagSources(flags)["legacy_routes"]; got != "true from default" || !FlagLegacyRoutes.Get(flags) {
+		t.Errorf("got legacy_routes %q, want the invalid stored value ignored", got)
+	}
+	if got := len(flags.List()); got != 3 {
+		t.Errorf("got %d flags listed, want the 3 defined in code", got)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/logging"
+)
+
+// EventSnapshot is the first event of a live connection
+const EventSnapshot = "snapshot"
+
+// liveSnapshot is the data of a snapshot event
+type liveSnapshot struct {
+	// Prices are the latest prices of the symbols the user's alerts watch
+	Prices []dto.LatestPriceResponse `json:"prices"`
+	// Triggers are the user's recent triggers, oldest first
+	Triggers []Event `json:"triggers"`
+}
+
+// LiveSnapshotService builds the snapshot a live client receives on connect,
+// so it has something to show before the next event arrives
+type LiveSnapshotService struct {
+	alerts domain.AlertRepository
+	prices domain.PriceRepository
+}
+
+func NewLiveSnapshotService(alerts domain.AlertRepository, prices domain.PriceRepository) *LiveSnapshotService {
+	return &LiveSnapshotService{alerts: alerts, prices: prices}
+}
+
+// Snapshot returns the snapshot event of a new subscription. Prices that cannot
+// be read are left out rather than holding the connection back.
+func (s *LiveSnapshotService) Snapshot(ctx context.Context, sub *Subscription) Event {
+	snapshot := liveSnapshot{Prices: []dto.LatestPriceResponse{}, Triggers: sub.History()}
+	if snapshot.Triggers == nil {
+		snapshot.Triggers = []Event{}
+	}
+	prices, err := s.latestPrices(ctx, sub.userID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("snapshot sent without prices", "user_id", sub.userID, "error", err)
+	} else {
+		snapshot.Prices = prices
+	}
+	return Event{Type: EventSnapshot, Data: snapshot, At: time.Now().UTC()}
+}
+
+// latestPrices reads the latest price of each symbol the user's alerts watch
+func (s *LiveSnapshotService) latestPrices(ctx context.Context, userID string) ([]dto.LatestPriceResponse, error) {
+	alerts, err := s.alerts.FindAllByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, alert := range alerts {
+		if alert.Symbol != "" && !seen[alert.Symbol] {
+			seen[alert.Symbol] = true
+			symbols = append(symbols, alert.Symbol)
+		}
+	}
+	sort.Strings(symbols)
+
+	prices := []dto.LatestPriceResponse{}
+	for _, symbol := range symbols {
+		latest, err := s.prices.Latest(ctx, symbol)
+		if err != nil {
+			return nil, err
+		}
+		if latest != nil {
+			prices = append(prices, *latest)
+		}
+	}
+	return prices, nil
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hello-api/internal/common/timeutil"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/logging"
+)
+
+// Reasons a market session is closed
+const (
+	SessionWeekend    = "weekend"
+	SessionHoliday    = "holiday"
+	SessionBeforeOpen = "before_open"
+	SessionAfterClose = "after_close"
+)
+
+// MarketSchedule is the weekly trading schedule of the market
+type MarketSchedule struct {
+	Location    *time.Location
+	TradingDays []time.Weekday
+	// Open and Close are offsets from midnight in Location
+	Open  time.Duration
+	Close time.Duration
+}
+
+// DefaultMarketSchedule is the Dhaka Stock Exchange schedule: Sunday to
+// Thursday, 10:00 to 14:30 Bangladesh time
+func DefaultMarketSchedule() MarketSchedule {
+	return MarketSchedule{
+		Location:    timeutil.Dhaka,
+		TradingDays: []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday},
+		Open:        10 * time.Hour,
+		Close:       14*time.Hour + 30*time.Minute,
+	}
+}
+
+// LoadMarketSchedule overrides the DSE schedule from MARKET_TIMEZONE,
+// MARKET_TRADING_DAYS (e.g. "sun,mon,tue,wed,thu"), MARKET_OPEN and MARKET_CLOSE ("10:00")
+func LoadMarketSchedule() (MarketSchedule, error) {
+	schedule := DefaultMarketSchedule()
+	if raw := os.Getenv("MARKET_TIMEZONE"); raw != "" {
+		location, err := time.LoadLocation(raw)
+		if err != nil {
+			return schedule, fmt.Errorf("MARKET_TIMEZONE: %w", err)
+		}
+		schedule.Location = location
+	}
+	if raw := os.Getenv("MARKET_TRADING_DAYS"); raw != "" {
+		schedule.TradingDays = nil
+		for _, name := range strings.Split(raw, ",") {
+			day, ok := parseWeekday(name)
+			if !ok {
+				return schedule, fmt.Errorf("MARKET_TRADING_DAYS: unknown day %q", name)
+			}
+			schedule.TradingDays = append(schedule.TradingDays, day)
+		}
+	}
+	for _, setting := range []struct {
+		name   string
+		target *time.Duration
+	}{{"MARKET_OPEN", &schedule.Open}, {"MARKET_CLOSE", &schedule.Close}} {
+		if raw := os.Getenv(setting.name); raw != "" {
+			clock, err := time.Parse("15:04", raw)
+			if err != nil {
+				return schedule, fmt.Errorf("%s must be HH:MM, got %q", setting.name, raw)
+			}
+			*setting.target = time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute
+		}
+	}
+	if schedule.Close <= schedule.Open {
+		return schedule, fmt.Errorf("MARKET_CLOSE must be after MARKET_OPEN")
+	}
+	return schedule, nil
+}
+
+// TradingDate returns the market-local date (YYYY-MM-DD) of a time, which keys
+// the day statistics of prices
+func (s MarketSchedule) TradingDate(at time.Time) string {
+	return timeutil.TradingDate(at, s.Location)
+}
+
+// Session decides whether the market trades at the given time. holidays maps
+// dates in YYYY-MM-DD form to holiday names. It has no side effects.
+func (s MarketSchedule) Session(at time.Time, holidays map[string]string) dto.MarketSession {
+	session := s.session(at, holidays)
+	session.TradingDate = s.TradingDate(at)
+	return session
+}
+
+func (s MarketSchedule) session(at time.Time, holidays map[string]string) dto.MarketSession {
+	local := at.In(s.Location)
+	date := timeutil.TradingDate(at, s.Location)
+	if name, ok := holidays[date]; ok {
+		return dto.MarketSession{Reason: SessionHoliday, Detail: fmt.Sprintf("%s is a market holiday (%s)", date, name)}
+	}
+
+	trading := false
+	for _, day := range s.TradingDays {
+		if local.Weekday() == day {
+			trading = true
+		}
+	}
+	if !trading {
+		return dto.MarketSession{Reason: SessionWeekend, Detail: fmt.Sprintf("the market does not trade on %s", local.Weekday())}
+	}
+
+	switch sinceMidnight := at.Sub(timeutil.StartOfDay(at, s.Location)); {
+	case sinceMidnight < s.Open:
+		return dto.MarketSession{Reason: SessionBeforeOpen, Detail: fmt.Sprintf("%s is before the %s open", local.Format("15:04"), formatClock(s.Open))}
+	case sinceMidnight >= s.Close:
+		return dto.MarketSession{Reason: SessionAfterClose, Detail: fmt.Sprintf("%s is after the %s close", local.Format("15:04"), formatClock(s.Close))}
+	}
+	return dto.MarketSession{Open: true, Detail: fmt.Sprintf("the market is open until %s", formatClock(s.Close))}
+}
+
+type MarketCalendarService struct {
+	schedule MarketSchedule
+	holidays domain.HolidayRepository
+}
+
+func NewMarketCalendarService(schedule MarketSchedule, holidays domain.HolidayRepository) *MarketCalendarService {
+	return &MarketCalendarService{schedule: schedule, holidays: holidays}
+}
+
+// Session reports whether the market trades at the given time
+func (s *MarketCalendarService) Session(ctx context.Context, at time.Time) (dto.MarketSession, error) {
+	holidays, err := s.holidays.FindAll(ctx)
+	if err != nil {
+		return dto.MarketSession{}, err
+	}
+	byDate := make(map[string]string, len(holidays))
+	for _, holiday := range holidays {
+		byDate[holiday.Date] = holiday.Name
+	}
+	return s.schedule.Session(at, byDate), nil
+}
+
+func (s *MarketCalendarService) GetCalendar(ctx context.Context) (*dto.MarketCalendarResponse, error) {
+	holidays, err := s.holidays.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	days := make([]string, 0, len(s.schedule.TradingDays))
+	for _, day := range s.schedule.TradingDays {
+		days = append(days, day.String())
+	}
+	return &dto.MarketCalendarResponse{
+		Timezone:    s.schedule.Location.String(),
+		TradingDays: days,
+		Open:        formatClock(s.schedule.Open),
+		Close:       formatClock(s.schedule.Close),
+		Holidays:    holidays,
+	}, nil
+}
+
+func (s *MarketCalendarService) AddHoliday(ctx context.Context, holiday dto.HolidayRequest) (*dto.HolidayResponse, error) {
+	if _, err := timeutil.ParseDate(holiday.Date, s.schedule.Location); err != nil {
+		return nil, fmt.Errorf("%v: %w", err, domain.ErrValidation)
+	}
+	if strings.TrimSpace(holiday.Name) == "" {
+		return nil, fmt.Errorf("name is required: %w", domain.ErrValidation)
+	}
+	added, err := s.holidays.Add(ctx, &holiday)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("market holiday added", "date", added.Date, "name", added.Name)
+	return added, nil
+}
+
+func (s *MarketCalendarService) RemoveHoliday(ctx context.Context, date string) error {
+	if err := s.holidays.Delete(ctx, date); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("market holiday removed", "date", date)
+	return nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if len(name) >= 3 && strings.HasPrefix(strings.ToLower(day.String()), name) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+func formatClock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
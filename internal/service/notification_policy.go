@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hello-api/internal/common/timeutil"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"github.com/hello-api/pkg/metrics"
+)
+
+// EventAlertsThrottled is the outbox payload summarizing notifications held
+// back by the hourly cap
+const EventAlertsThrottled = "alert.throttled"
+
+const (
+	// notificationThrottleGrace lets notifications counted right at the end of
+	// an hour land before the hour is summarized
+	notificationThrottleGrace = time.Minute
+	// notificationThrottleInterval is how often ended hours are looked for
+	notificationThrottleInterval = time.Minute
+)
+
+// alertsThrottledEvent is the payload of an "and N more alerts fired" summary
+type alertsThrottledEvent struct {
+	Event       string    `json:"event"`
+	UserID      string    `json:"userId"`
+	WindowStart time.Time `json:"windowStart"`
+	WindowEnd   time.Time `json:"windowEnd"`
+	Suppressed  int64     `json:"suppressed"`
+	// DisplayTimezone is the owner's display timezone, if any
+	DisplayTimezone string `json:"displayTimezone,omitempty"`
+}
+
+// LoadNotificationMaxPerHour reads NOTIFICATION_MAX_PER_HOUR, the most
+// notifications a user gets per channel and hour; 0, the default, is no cap
+func LoadNotificationMaxPerHour() (int64, error) {
+	raw := os.Getenv("NOTIFICATION_MAX_PER_HOUR")
+	if raw == "" {
+		return 0, nil
+	}
+	limit, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("NOTIFICATION_MAX_PER_HOUR must be a non-negative integer, got %q", raw)
+	}
+	return limit, nil
+}
+
+// NotificationPolicy keeps notifications meant for people, i.e. Telegram
+// messages and emails, out of the owner's quiet hours and under the hourly
+// cap. Notifications raised in quiet hours are queued for the end of the
+// window; those over the cap are counted and summarized once the hour ends.
+// Hours are clock hours in the owner's timezone. A nil policy lets every
+// notification through.
+type NotificationPolicy struct {
+	users    domain.UserRepository
+	throttle domain.NotificationThrottleRepository
+	outbox   domain.NotificationRepository
+	// maxPerHour caps each user's notifications per channel and hour; 0 is no cap
+	maxPerHour int64
+	// location is the timezone of users whose quiet hours name none
+	location *time.Location
+	now      func() time.Time
+}
+
+func NewNotificationPolicy(users domain.UserRepository, throttle domain.NotificationThrottleRepository, outbox domain.NotificationRepository, maxPerHour int64, location *time.Location) *NotificationPolicy {
+	metrics.Default.Describe("notifications_throttled_total", "Notifications held back by the hourly cap, by channel")
+	metrics.Default.Describe("notification_summaries_queued_total", "Hourly cap summaries queued in the notification outbox")
+	if location == nil {
+		location = time.UTC
+	}
+	return &NotificationPolicy{users: users, throttle: throttle, outbox: outbox, maxPerHour: maxPerHour, location: location, now: time.Now}
+}
+
+// DeliverAt returns when a non-urgent notification to owner raised at the
+// given time may first be attempted: the end of the owner's quiet hours when
+// it falls in them, otherwise the time itself
+func (p *NotificationPolicy) DeliverAt(owner *entity.UserEntity, at time.Time) time.Time {
+	if p == nil || owner == nil {
+		return at
+	}
+	return timeutil.UTC(quietUntil(owner.QuietHours, at, p.userLocation(owner)))
+}
+
+// Admit counts a notification to owner toward the cap of the hour it is
+// delivered in. It returns false once the cap is reached; the notification is
+// then only counted in the hour's summary.
+func (p *NotificationPolicy) Admit(ctx context.Context, owner *entity.UserEntity, channel dto.NotificationChannel, destination string, at time.Time) (bool, error) {
+	if p == nil || p.maxPerHour <= 0 || owner == nil {
+		return true, nil
+	}
+	start, end := hourWindow(at, p.userLocation(owner))
+	count, err := p.throttle.Hit(ctx, &dto.NotificationThrottleWindow{
+		UserID:      owner.UserID,
+		Channel:     channel,
+		Destination: destination,
+		WindowStart: start.UTC(),
+		WindowEnd:   end.UTC(),
+	})
+	if err != nil {
+		return false, err
+	}
+	if count > p.maxPerHour {
+		metrics.Default.Counter("notifications_throttled_total", metrics.Labels{"channel": string(channel)}).Inc()
+		return false, nil
+	}
+	return true, nil
+}
+
+// deliverAtUser is DeliverAt for a user known by id, e.g. the owner of a
+// digest. It also returns the user's display timezone, empty when unset.
+func (p *NotificationPolicy) deliverAtUser(ctx context.Context, userID string, at time.Time) (time.Time, string, error) {
+	if p == nil {
+		return at, "", nil
+	}
+	owner, err := p.users.FindByUserID(ctx, userID)
+	if err != nil {
+		return at, "", err
+	}
+	if owner == nil {
+		return p.DeliverAt(owner, at), "", nil
+	}
+	return p.DeliverAt(owner, at), owner.DisplayTimezone, nil
+}
+
+// userLocation is the timezone of the owner's quiet hours, or the default one
+func (p *NotificationPolicy) userLocation(owner *entity.UserEntity) *time.Location {
+	if owner.QuietHours != nil && owner.QuietHours.Timezone != "" {
+		if location, err := time.LoadLocation(owner.QuietHours.Timezone); err == nil {
+			return location
+		}
+	}
+	return p.location
+}
+
+// Run queues the summaries of ended hours until ctx is cancelled
+func (p *NotificationPolicy) Run(ctx context.Context) {
+	ticker := time.NewTicker(notificationThrottleInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Failed to queue notification summaries", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush queues one summary per hour that ended at least the grace period ago
+// with notifications over the cap, and drops the counts of those hours
+func (p *NotificationPolicy) Flush(ctx context.Context) error {
+	windows, err := p.throttle.Ended(ctx, p.now().Add(-notificationThrottleGrace))
+	if err != nil {
+		return err
+	}
+	for _, window := range windows {
+		if err := p.flushWindow(ctx, window); err != nil {
+			return fmt.Errorf("summary of %s at %s: %w", window.UserID, window.WindowStart.Format(time.RFC3339), err)
+		}
+	}
+	return nil
+}
+
+func (p *NotificationPolicy) flushWindow(ctx context.Context, window dto.NotificationThrottleWindow) error {
+	if suppressed := window.Count - p.maxPerHour; p.maxPerHour > 0 && suppressed > 0 {
+		// Summaries wait out quiet hours like any other notification
+		notBefore, displayTimezone, err := p.deliverAtUser(ctx, window.UserID, p.now())
+		if err != nil {
+			return err
+		}
+		payload, err := json.Marshal(alertsThrottledEvent{
+			Event:           EventAlertsThrottled,
+			UserID:          window.UserID,
+			WindowStart:     window.WindowStart,
+			WindowEnd:       window.WindowEnd,
+			Suppressed:      suppressed,
+			DisplayTimezone: displayTimezone,
+		})
+		if err != nil {
+			return err
+		}
+		notification, err := p.outbox.Enqueue(ctx, &dto.NotificationEnqueueRequest{
+			// Summaries belong to no single alert; the trigger id names the hour
+			TriggerID:   "throttle:" + window.UserID + ":" + strconv.FormatInt(window.WindowStart.Unix(), 10),
+			Channel:     window.Channel,
+			Destination: window.Destination,
+			Payload:     payload,
+			NotBefore:   notBefore,
+		})
+		if err != nil {
+			return err
+		}
+		metrics.Default.Counter("notification_summaries_queued_total", nil).Inc()
+		slog.Info("notification summary queued", "user_id", window.UserID, "channel", window.Channel,
+			"suppressed", suppressed, "notification_id", notification.ID)
+	}
+	return p.throttle.Remove(ctx, window)
+}
+
+// parseClock parses an "HH:MM" time of day into minutes after midnight
+func parseClock(value string) (int, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("time of day must be HH:MM, got %q", value)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// quietUntil returns the end of the quiet hours containing at, or at itself
+// when it is outside them. The window is read on the wall clock of location,
+// so with quiet hours from 22:00 to 07:00 a notification at 23:30 waits until
+// 07:00 the next day and one at 03:00 until 07:00 the same day.
+func quietUntil(quiet *entity.QuietHoursEntity, at time.Time, location *time.Location) time.Time {
+	if quiet == nil {
+		return at
+	}
+	start, err := parseClock(quiet.Start)
+	if err != nil {
+		return at
+	}
+	end, err := parseClock(quiet.End)
+	if err != nil || start == end {
+		return at
+	}
+	local := at.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	days := 0
+	switch {
+	case start < end:
+		if minute < start || minute >= end {
+			return at
+		}
+	case minute >= start:
+		// The window spans midnight and ends tomorrow
+		days = 1
+	case minute >= end:
+		return at
+	}
+	year, month, day := local.Date()
+	return time.Date(year, month, day+days, end/60, end%60, 0, 0, location)
+}
+
+// hourWindow returns the clock hour containing at on the wall clock of
+// location, e.g. 09:00 to 10:00 in a +05:30 zone rather than the UTC hour.
+// The start is taken back from at rather than built with time.Date, which
+// would pick the wrong one of the two hours repeated when DST ends.
+func hourWindow(at time.Time, location *time.Location) (time.Time, time.Time) {
+	local := at.In(location)
+	intoHour := time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second +
+		time.Duration(local.Nanosecond())
+	start := at.Add(-intoHour).In(location)
+	return start, start.Add(time.Hour)
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/internal/repository/entity"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone %s unavailable: %v", name, err)
+	}
+	return location
+}
+
+// Quiet hours are read on the owner's wall clock; a window spanning midnight
+// defers to its end on the next day or the same day, depending on the side of
+// midnight the notification falls on
+func TestDeliverAt(t *testing.T) {
+	dhaka := mustLoadLocation(t, "Asia/Dhaka")
+	policy := NewNotificationPolicy(nil, nil, nil, 0, time.UTC)
+	overnight := &entity.UserEntity{QuietHours: &entity.QuietHoursEntity{Start: "22:00", End: "07:00", Timezone: "Asia/Dhaka"}}
+	daytime := &entity.UserEntity{QuietHours: &entity.QuietHoursEntity{Start: "12:00", End: "13:30"}}
+	local := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, dhaka)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		owner *entity.UserEntity
+		at    time.Time
+		want  time.Time
+	}{
+		{name: "before the window", owner: overnight, at: local(2024, 3, 4, 21, 59), want: local(2024, 3, 4, 21, 59)},
+		{name: "window start", owner: overnight, at: local(2024, 3, 4, 22, 0), want: local(2024, 3, 5, 7, 0)},
+		{name: "before midnight", owner: overnight, at: local(2024, 3, 4, 23, 30), want: local(2024, 3, 5, 7, 0)},
+		{name: "midnight", owner: overnight, at: local(2024, 3, 5, 0, 0), want: local(2024, 3, 5, 7, 0)},
+		{name: "3 AM", owner: overnight, at: local(2024, 3, 5, 3, 0), want: local(2024, 3, 5, 7, 0)},
+		{name: "window end", owner: overnight, at: local(2024, 3, 5, 7, 0), want: local(2024, 3, 5, 7, 0)},
+		{name: "new year's eve", owner: overnight, at: local(2024, 12, 31, 23, 45), want: local(2025, 1, 1, 7, 0)},
+		// 17:30 UTC is already 23:30 in Dhaka, past the UTC-less reading of the window
+		{name: "utc input", owner: overnight, at: time.Date(2024, 3, 4, 17, 30, 0, 0, time.UTC), want: local(2024, 3, 5, 7, 0)},
+		{name: "daytime window in the default zone", owner: daytime, at: time.Date(2024, 3, 4, 12, 15, 0, 0, time.UTC),
+			want: time.Date(2024, 3, 4, 13, 30, 0, 0, time.UTC)},
+		{name: "no quiet hours", owner: &entity.UserEntity{}, at: local(2024, 3, 5, 3, 0), want: local(2024, 3, 5, 3, 0)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := policy.DeliverAt(tc.owner, tc.at)
+			if !got.Equal(tc.want) || got.Location() != time.UTC {
+				t.Errorf("got %v, want %v in UTC", got, tc.want.UTC())
+			}
+		})
+	}
+}
+
+// Hours are clock hours of the owner's zone, e.g. half past the UTC hour in +05:30
+func TestHourWindow(t *testing.T) {
+	kolkata := mustLoadLocation(t, "Asia/Kolkata")
+	start, end := hourWindow(time.Date(2024, 3, 4, 3, 50, 12, 0, time.UTC), kolkata)
+	if want := time.Date(2024, 3, 4, 3, 30, 0, 0, time.UTC); !start.Equal(want) || !end.Equal(want.Add(time.Hour)) {
+		t.Errorf("got %v to %v, want the hour from %v", start, end, want)
+	}
+	// The hour before local midnight belongs to the previous day
+	start, _ = hourWindow(time.Date(2024, 3, 4, 18, 29, 0, 0, time.UTC), kolkata)
+	if got := start.Format("2006-01-02 15:04"); got != "2024-03-04 23:00" {
+		t.Errorf("got the hour starting %s, want 2024-03-04 23:00", got)
+	}
+}
+
+// Past the hourly cap notifications are only counted, and once the hour has
+// ended they are summarized in one notification that waits out quiet hours
+func TestNotificationThrottle(t *testing.T) {
+	ctx := context.Background()
+	dhaka := mustLoadLocation(t, "Asia/Dhaka")
+	users := repository.NewMemoryUserRepository()
+	owner, err := users.Create(ctx, &entity.UserEntity{UserID: "alice", Name: "Alice", Email: "alice@example.com",
+		QuietHours: &entity.QuietHoursEntity{Start: "00:00", End: "06:00", Timezone: "Asia/Dhaka"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox := repository.NewMemoryNotificationRepository()
+	policy := NewNotificationPolicy(users, repository.NewMemoryNotificationThrottleRepository(), outbox, 2, time.UTC)
+
+	// Four notifications in the Dhaka hour before midnight, one right after it.
+	// The outbox attempts nothing before now, so the summary's deferral is only
+	// visible in the future.
+	late := time.Date(2100, 3, 4, 23, 10, 0, 0, dhaka)
+	var admitted []bool
+	for _, at := range []time.Time{late, late.Add(10 * time.Minute), late.Add(20 * time.Minute), late.Add(49 * time.Minute), late.Add(50 * time.Minute)} {
+		ok, err := policy.Admit(ctx, owner, dto.NotificationChannelEmail, owner.Email, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		admitted = append(admitted, ok)
+	}
+	if want := []bool{true, true, false, false, true}; fmt.Sprint(admitted) != fmt.Sprint(want) {
+		t.Fatalf("admitted %v, want %v", admitted, want)
+	}
+
+	policy.now = func() time.Time { return time.Date(2100, 3, 5, 0, 5, 0, 0, dhaka) }
+	if err := policy.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	queued, _ := outbox.FindByStatus(ctx, dto.NotificationStatusPending, 0)
+	if len(queued) != 1 {
+		t.Fatalf("queued %d summaries, want 1", len(queued))
+	}
+	var summary alertsThrottledEvent
+	if err := json.Unmarshal(queued[0].Payload, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Suppressed != 2 || !summary.WindowStart.Equal(time.Date(2100, 3, 4, 23, 0, 0, 0, dhaka)) {
+		t.Errorf("summary %+v, want 2 suppressed in the hour from 23:00", summary)
+	}
+	if want := time.Date(2100, 3, 5, 6, 0, 0, 0, dhaka); !queued[0].NextAttemptAt.Equal(want) {
+		t.Errorf("summary is due at %v, want the end of the quiet hours %v", queued[0].NextAttemptAt, want)
+	}
+}
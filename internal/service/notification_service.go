@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hello-api/internal/common/timeutil"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository/entity"
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/metrics"
+	"github.com/hello-api/pkg/money"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxNotificationsListed bounds status listings
+const maxNotificationsListed = 500
+
+type NotificationService struct {
+	repo      domain.NotificationRepository
+	alertRepo domain.AlertRepository
+	events    *Broadcaster
+	calendar  domain.MarketCalendarService
+	// users resolve the owner's Telegram chat and email address
+	users    domain.UserRepository
+	channels NotificationChannels
+	// status counts the triggers recorded each trading date
+	status *StatusService
+}
+
+// NotificationChannels are the optional channels configured for RecordTrigger
+type NotificationChannels struct {
+	Telegram bool
+	Email    bool
+	// Digests holds the email triggers of users on hourly digests
+	Digests *EmailDigester
+	// Policy applies quiet hours and the hourly cap to Telegram and email
+	Policy *NotificationPolicy
+}
+
+func NewNotificationService(repo domain.NotificationRepository, alertRepo domain.AlertRepository, events *Broadcaster, calendar domain.MarketCalendarService, users domain.UserRepository, channels NotificationChannels, status *StatusService) *NotificationService {
+	metrics.Default.Describe("alert_triggers_skipped_total", "Alert triggers skipped outside market hours, by reason")
+	metrics.Default.Describe("alert_triggers_muted_total", "Alert triggers recorded without notifications while their owner was muted")
+	return &NotificationService{repo: repo, alertRepo: alertRepo, events: events, calendar: calendar, users: users, channels: channels, status: status}
+}
+
+// alertTriggeredEvent is the webhook body delivered for a trigger
+type alertTriggeredEvent struct {
+	Event       string        `json:"event"`
+	AlertID     string        `json:"alertId"`
+	Name        string        `json:"name"`
+	Symbol      string        `json:"symbol,omitempty"`
+	Rule        dto.AlertRule `json:"rule"`
+	Threshold   money.Amount  `json:"threshold"`
+	TriggerID   string        `json:"triggerId"`
+	Price       money.Amount  `json:"price"`
+	Reason      string        `json:"reason"`
+	TriggeredAt time.Time     `json:"triggeredAt"`
+	// Lag is how stale the price was when it fired the alert, when known
+	Lag *dto.TickLag `json:"lag,omitempty"`
+	// DisplayTimezone is the owner's display timezone, set on Telegram and
+	// email payloads only
+	DisplayTimezone string `json:"displayTimezone,omitempty"`
+}
+
+// RecordTrigger pushes the trigger to the owner's live connections and queues a
+// webhook delivery to the alert's webhook URL and, when the alert asks for them,
+// a message to the owner's linked Telegram chat and an email to the owner. Owners
+// on hourly emails get the trigger in their next digest instead. The first
+// queued delivery is returned, in webhook, Telegram, email order; nil when
+// nothing is queued. An alert's NotifyOverride replaces all of these with its
+// single destination. Unless the alert is urgent, Telegram and email deliveries
+// wait out the owner's quiet hours and are left to the hourly summary once the
+// owner is over the cap. While the owner is muted the trigger is counted and
+// pushed to live connections but nothing is queued. Triggers outside market
+// hours are skipped with ErrOutsideMarketHours unless the alert evaluates off hours.
+// RecordShadowTrigger admits the trigger of an alert in shadow mode like
+// RecordTrigger, so the evaluator re-arms it outside market hours, but counts,
+// publishes and notifies nothing
+func (s *NotificationService) RecordShadowTrigger(ctx context.Context, alert dto.AlertResponse, trigger dto.AlertTriggerRequest) error {
+	_, err := s.admitTrigger(ctx, alert, trigger)
+	return err
+}
+
+// admitTrigger fills in the trigger's id and time and rejects it with
+// ErrOutsideMarketHours when the market is closed and the alert does not
+// evaluate off hours
+func (s *NotificationService) admitTrigger(ctx context.Context, alert dto.AlertResponse, trigger dto.AlertTriggerRequest) (dto.AlertTriggerRequest, error) {
+	if trigger.TriggerID == "" {
+		trigger.TriggerID = primitive.NewObjectID().Hex()
+	}
+	if trigger.TriggeredAt.IsZero() {
+		trigger.TriggeredAt = time.Now()
+	}
+	trigger.TriggeredAt = timeutil.UTC(trigger.TriggeredAt)
+	if alert.EvaluateOffHours {
+		return trigger, nil
+	}
+	session, err := s.calendar.Session(ctx, trigger.TriggeredAt)
+	if err != nil {
+		return trigger, err
+	}
+	if SkipsOutsideMarketHours(alert, session) {
+		metrics.Default.Counter("alert_triggers_skipped_total", metrics.Labels{"reason": session.Reason}).Inc()
+		logging.FromContext(ctx).Info("alert trigger skipped outside market hours",
+			"alert_id", alert.ID, "reason", session.Reason)
+		return trigger, fmt.Errorf("%s: %w", session.Detail, domain.ErrOutsideMarketHours)
+	}
+	return trigger, nil
+}
+
+func (s *NotificationService) RecordTrigger(ctx context.Context, alertID string, trigger dto.AlertTriggerRequest) (*dto.NotificationResponse, error) {
+	alert, err := s.alertRepo.FindByID(ctx, alertID)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, domain.ErrAlertNotFound
+	}
+
+	trigger, err = s.admitTrigger(ctx, *alert, trigger)
+	if err != nil {
+		return nil, err
+	}
+	s.status.CountTrigger(ctx, trigger.TriggeredAt)
+	event := alertTriggeredEvent{
+		Event:       EventAlertTriggered,
+		AlertID:     alert.ID,
+		Name:        alert.Name,
+		Symbol:      alert.Symbol,
+		Rule:        alert.Rule,
+		Threshold:   alert.Price,
+		TriggerID:   trigger.TriggerID,
+		Price:       trigger.Price,
+		Reason:      trigger.Reason,
+		TriggeredAt: trigger.TriggeredAt,
+		Lag:         trigger.Lag,
+	}
+	if s.events != nil {
+		s.events.Publish(alert.UserID, Event{Type: EventAlertTriggered, Data: event, At: trigger.TriggeredAt})
+	}
+
+	owner, err := s.users.FindByUserID(ctx, alert.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if muted(owner, trigger.TriggeredAt) {
+		metrics.Default.Counter("alert_triggers_muted_total", nil).Inc()
+		logging.FromContext(ctx).Info("alert trigger not notified, owner is muted", "alert_id", alert.ID,
+			"trigger_id", trigger.TriggerID, "user_id", alert.UserID, "muted_until", *owner.MutedUntil)
+		return nil, nil
+	}
+
+	destinations := make(map[dto.NotificationChannel]string)
+	if override := alert.NotifyOverride; override != nil {
+		// The override replaces the alert's webhook and the owner's channels;
+		// the owner still sets quiet hours and the display timezone
+		if !s.channelEnabled(override.Channel) {
+			logging.FromContext(ctx).Warn("alert notification override uses a disabled channel",
+				"alert_id", alert.ID, "channel", override.Channel)
+			return nil, nil
+		}
+		destinations[override.Channel] = override.Target
+	} else if alert.WebhookURL != "" {
+		destinations[dto.NotificationChannelWebhook] = alert.WebhookURL
+	}
+	wantTelegram := alert.NotifyOverride == nil && alert.NotifyTelegram && s.channels.Telegram
+	wantEmail := alert.NotifyOverride == nil && alert.NotifyEmail && s.channels.Email
+	if wantTelegram || wantEmail {
+		if owner != nil && wantTelegram && owner.TelegramChatID != 0 {
+			destinations[dto.NotificationChannelTelegram] = strconv.FormatInt(owner.TelegramChatID, 10)
+		}
+		if owner != nil && wantEmail && owner.Email != "" {
+			if dto.EmailMode(owner.EmailMode) == dto.EmailModeHourly && s.channels.Digests != nil {
+				held := event
+				held.DisplayTimezone = owner.DisplayTimezone
+				if err := s.channels.Digests.Add(ctx, owner.UserID, owner.Email, held); err != nil {
+					return nil, err
+				}
+				logging.FromContext(ctx).Info("alert trigger held for email digest",
+					"alert_id", alert.ID, "trigger_id", trigger.TriggerID, "user_id", owner.UserID)
+			} else {
+				destinations[dto.NotificationChannelEmail] = owner.Email
+			}
+		}
+	}
+	if len(destinations) == 0 {
+		return nil, nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	// Messages read by people are rendered in the owner's display timezone
+	personalPayload := payload
+	if owner != nil && owner.DisplayTimezone != "" {
+		personal := event
+		personal.DisplayTimezone = owner.DisplayTimezone
+		if personalPayload, err = json.Marshal(personal); err != nil {
+			return nil, err
+		}
+	}
+
+	var first *dto.NotificationResponse
+	now := time.Now().UTC()
+	for _, channel := range []dto.NotificationChannel{dto.NotificationChannelWebhook, dto.NotificationChannelTelegram, dto.NotificationChannelEmail} {
+		destination, ok := destinations[channel]
+		if !ok {
+			continue
+		}
+		req := &dto.NotificationEnqueueRequest{
+			AlertID:     alert.ID,
+			TriggerID:   trigger.TriggerID,
+			Channel:     channel,
+			Destination: destination,
+			Payload:     payload,
+		}
+		// Webhooks are read by machines and go out as they come
+		if channel != dto.NotificationChannelWebhook {
+			req.Payload = personalPayload
+		}
+		if channel != dto.NotificationChannelWebhook && !alert.Urgent {
+			req.NotBefore = s.channels.Policy.DeliverAt(owner, now)
+			admitted, err := s.channels.Policy.Admit(ctx, owner, channel, destination, req.NotBefore)
+			if err != nil {
+				return nil, err
+			}
+			if !admitted {
+				logging.FromContext(ctx).Info("alert notification over the hourly cap, left to the summary",
+					"alert_id", alert.ID, "trigger_id", trigger.TriggerID, "channel", channel)
+				continue
+			}
+		}
+		notification, err := s.repo.Enqueue(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		logging.FromContext(ctx).Info("alert notification queued", "alert_id", alert.ID,
+			"trigger_id", trigger.TriggerID, "channel", channel, "notification_id", notification.ID,
+			"next_attempt_at", notification.NextAttemptAt)
+		if first == nil {
+			first = notification
+		}
+	}
+	return first, nil
+}
+
+// muted reports whether a trigger at the given time falls in the owner's mute
+func muted(owner *entity.UserEntity, at time.Time) bool {
+	return owner != nil && owner.MutedUntil != nil && at.Before(*owner.MutedUntil)
+}
+
+// channelEnabled reports whether notifications can go out over channel
+func (s *NotificationService) channelEnabled(channel dto.NotificationChannel) bool {
+	switch channel {
+	case dto.NotificationChannelTelegram:
+		return s.channels.Telegram
+	case dto.NotificationChannelEmail:
+		return s.channels.Email
+	}
+	return true
+}
+
+// GetAlertNotifications returns the deliveries of an alert, newest first
+func (s *NotificationService) GetAlertNotifications(ctx context.Context, alertID string) ([]dto.NotificationResponse, error) {
+	alert, err := s.alertRepo.FindByID(ctx, alertID)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, domain.ErrAlertNotFound
+	}
+	return s.repo.FindByAlert(ctx, alertID)
+}
+
+// GetNotificationsByStatus lists deliveries in a status, e.g. failed ones for inspection
+func (s *NotificationService) GetNotificationsByStatus(ctx context.Context, status string) ([]dto.NotificationResponse, error) {
+	switch st := dto.NotificationStatus(status); st {
+	case dto.NotificationStatusPending, dto.NotificationStatusInFlight,
+		dto.NotificationStatusDelivered, dto.NotificationStatusFailed:
+		return s.repo.FindByStatus(ctx, st, maxNotificationsListed)
+	}
+	return nil, fmt.Errorf("unknown notification status %q: %w", status, domain.ErrValidation)
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/internal/repository/entity"
+	"github.com/hello-api/pkg/money"
+)
+
+// An alert's override is the only destination of that alert's notifications;
+// the owner's other alert still goes to its webhook and the owner's Telegram chat
+func TestNotifyOverride(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	if _, err := users.Create(ctx, &entity.UserEntity{UserID: "alice", Name: "Alice", Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetTelegramChat(ctx, "alice", 4242); err != nil {
+		t.Fatal(err)
+	}
+	alerts := repository.NewMemoryAlertRepository()
+	newAlert := func(override *dto.NotifyOverride) *dto.AlertResponse {
+		alert, err := alerts.Create(ctx, &dto.AlertCreateRequest{UserID: "alice", Symbol: "GP", Rule: dto.AlertRuleAbove,
+			Price: money.FromFloat(100), Status: dto.AlertStatusActive, EvaluateOffHours: true,
+			WebhookURL: "https://example.com/alice", NotifyTelegram: true, NotifyOverride: override})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return alert
+	}
+	overridden := newAlert(&dto.NotifyOverride{Channel: dto.NotificationChannelWebhook, Target: "https://bot.example.com/hook"})
+	plain := newAlert(nil)
+
+	outbox := repository.NewMemoryNotificationRepository()
+	notifications := NewNotificationService(outbox, alerts, nil, nil, users, NotificationChannels{Telegram: true}, nil)
+	evaluator := newTestTickEvaluator(t, alerts, notifications)
+	tick, latest := crossingTick("GP", 101, time.Now().UTC())
+	if fired := evaluator.Evaluate(ctx, tick, latest, latest.TradingDate); fired != 2 {
+		t.Fatalf("fired %d alerts, want 2", fired)
+	}
+
+	queued, _ := outbox.FindByStatus(ctx, dto.NotificationStatusPending, 0)
+	got := make(map[string][]string)
+	for _, n := range queued {
+		got[n.AlertID] = append(got[n.AlertID], fmt.Sprintf("%s:%s", n.Channel, n.Destination))
+	}
+	for _, tc := range []struct {
+		name  string
+		alert *dto.AlertResponse
+		want  []string
+	}{
+		{name: "override", alert: overridden, want: []string{"webhook:https://bot.example.com/hook"}},
+		{name: "no override", alert: plain, want: []string{"telegram:4242", "webhook:https://example.com/alice"}},
+	} {
+		sort.Strings(got[tc.alert.ID])
+		if fmt.Sprint(got[tc.alert.ID]) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: queued %v, want %v", tc.name, got[tc.alert.ID], tc.want)
+		}
+	}
+}
+
+// An override on a channel that is switched off queues nothing, rather than
+// falling back to the destinations it replaces
+func TestNotifyOverrideDisabledChannel(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	alert, _ := alerts.Create(ctx, &dto.AlertCreateRequest{UserID: "alice", Symbol: "GP", Rule: dto.AlertRuleAbove,
+		Price: money.FromFloat(100), Status: dto.AlertStatusActive, EvaluateOffHours: true, WebhookURL: "https://example.com/alice",
+		NotifyOverride: &dto.NotifyOverride{Channel: dto.NotificationChannelEmail, Target: "bot@example.com"}})
+	outbox := repository.NewMemoryNotificationRepository()
+	notifications := NewNotificationService(outbox, alerts, nil, nil, repository.NewMemoryUserRepository(), NotificationChannels{}, nil)
+
+	notification, err := notifications.RecordTrigger(ctx, alert.ID, dto.AlertTriggerRequest{Price: money.FromFloat(101)})
+	if err != nil || notification != nil {
+		t.Fatalf("got %+v, %v, want nothing queued", notification, err)
+	}
+	if queued, _ := outbox.FindByStatus(ctx, dto.NotificationStatusPending, 0); len(queued) != 0 {
+		t.Errorf("queued %+v", queued)
+	}
+}
+
+// While the owner is muted a trigger is still pushed to live connections but
+// queues nothing, urgent or not; from the end of the mute it is notified again
+func TestMutedOwner(t *testing.T) {
+	ctx := context.Background()
+	mutedUntil := time.Date(2024, 3, 4, 6, 0, 0, 0, time.UTC)
+	users := repository.NewMemoryUserRepository()
+	if _, err := users.Create(ctx, &entity.UserEntity{UserID: "alice", Name: "Alice", Email: "alice@example.com", MutedUntil: &mutedUntil}); err != nil {
+		t.Fatal(err)
+	}
+	alerts := repository.NewMemoryAlertRepository()
+	alert, _ := alerts.Create(ctx, &dto.AlertCreateRequest{UserID: "alice", Symbol: "GP", Rule: dto.AlertRuleAbove, Price: money.FromFloat(100),
+		Status: dto.AlertStatusActive, EvaluateOffHours: true, Urgent: true, WebhookURL: "https://example.com/alice"})
+	outbox := repository.NewMemoryNotificationRepository()
+	events := NewBroadcaster(LiveLimits{MaxPerUser: 1, MaxTotal: 1}, 8, 8)
+	defer events.Close()
+	live, err := events.Subscribe("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifications := NewNotificationService(outbox, alerts, events, nil, users, NotificationChannels{}, nil)
+
+	for _, tc := range []struct {
+		name   string
+		at     time.Time
+		queued int
+	}{
+		{name: "muted", at: mutedUntil.Add(-time.Minute), queued: 0},
+		{name: "mute ended", at: mutedUntil, queued: 1},
+		{name: "after the mute", at: mutedUntil.Add(time.Hour), queued: 2},
+	} {
+		if _, err := notifications.RecordTrigger(ctx, alert.ID, dto.AlertTriggerRequest{Price: money.FromFloat(101), TriggeredAt: tc.at}); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		select {
+		case event := <-live.Events():
+			if event.Type != EventAlertTriggered {
+				t.Errorf("%s: pushed a %s event", tc.name, event.Type)
+			}
+		default:
+			t.Errorf("%s: the trigger was not pushed to the live connection", tc.name)
+		}
+		if queued, _ := outbox.FindByStatus(ctx, dto.NotificationStatusPending, 0); len(queued) != tc.queued {
+			t.Errorf("%s: %d notifications queued, want %d", tc.name, len(queued), tc.queued)
+		}
+	}
+}
+
+// A shadow alert's trigger queues nothing and reaches no live connection; out
+// of market hours it is skipped like any other, so the alert stays armed
+func TestShadowTrigger(t *testing.T) {
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	if _, err := users.Create(ctx, &entity.UserEntity{UserID: "alice", Name: "Alice", Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetTelegramChat(ctx, "alice", 4242); err != nil {
+		t.Fatal(err)
+	}
+	alerts := repository.NewMemoryAlertRepository()
+	alert, _ := alerts.Create(ctx, &dto.AlertCreateRequest{UserID: "alice", Symbol: "GP", Rule: dto.AlertRuleAbove, Price: money.FromFloat(100),
+		Status: dto.AlertStatusActive, Urgent: true, WebhookURL: "https://example.com/alice", NotifyTelegram: true})
+	if _, err := alerts.SetShadow(ctx, alert.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	outbox := repository.NewMemoryNotificationRepository()
+	events := NewBroadcaster(LiveLimits{MaxPerUser: 1, MaxTotal: 1}, 8, 8)
+	defer events.Close()
+	live, err := events.Subscribe("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calendar := NewMarketCalendarService(DefaultMarketSchedule(), repository.NewMemoryHolidayRepository())
+	notifications := NewNotificationService(outbox, alerts, events, calendar, users, NotificationChannels{Telegram: true}, nil)
+	evaluator := newTestTickEvaluator(t, alerts, notifications)
+
+	for _, tc := range []struct {
+		name          string
+		at            time.Time
+		wantFired     int
+		wantTriggered bool
+	}{
+		{name: "after the close", at: time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)},
+		{name: "in session", at: time.Date(2024, 3, 5, 5, 0, 0, 0, time.UTC), wantFired: 1, wantTriggered: true},
+	} {
+		tick, latest := crossingTick("GP", 101, tc.at)
+		if fired := evaluator.Evaluate(ctx, tick, latest, latest.TradingDate); fired != tc.wantFired {
+			t.Errorf("%s: fired %d alerts, want %d", tc.name, fired, tc.wantFired)
+		}
+		if stored, _ := alerts.FindByID(ctx, alert.ID); stored.Triggered != tc.wantTriggered {
+			t.Errorf("%s: got triggered %v, want %v", tc.name, stored.Triggered, tc.wantTriggered)
+		}
+	}
+	if queued, _ := outbox.FindByStatus(ctx, dto.NotificationStatusPending, 0); len(queued) != 0 {
+		t.Errorf("got %d notifications queued for a shadow alert, want 0", len(queued))
+	}
+	select {
+	case event := <-live.Events():
+		t.Errorf("pushed a %s event for a shadow alert", event.Type)
+	default:
+	}
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/common"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/httpclient"
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/metrics"
+)
+
+// NotificationSender delivers a notification payload to a destination of its channel
+type NotificationSender interface {
+	Send(ctx context.Context, destination string, payload []byte) error
+}
+
+// NotificationSenders maps each channel to the sender delivering it
+type NotificationSenders map[dto.NotificationChannel]NotificationSender
+
+// ErrUndeliverable marks a delivery that cannot succeed however often it is
+// retried, e.g. a chat that blocked the bot; it is marked failed right away
+var ErrUndeliverable = errors.New("undeliverable")
+
+// HTTPWebhookSender POSTs payloads as JSON. When WEBHOOK_SECRET_OUTBOUND is set
+// requests are signed like inbound webhooks (see common.VerifySignature).
+type HTTPWebhookSender struct {
+	client *httpclient.Client
+}
+
+func NewHTTPWebhookSender(client *httpclient.Client) *HTTPWebhookSender {
+	return &HTTPWebhookSender{client: client}
+}
+
+func (s *HTTPWebhookSender) Send(ctx context.Context, destination string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, destination, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret := common.WebhookSecret("outbound"); secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(common.SignatureTimestampHeader, timestamp)
+		req.Header.Set(common.SignatureHeader, "sha256="+common.SignPayload([]byte(secret), timestamp, payload))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// NotificationWorkerConfig tunes outbox delivery
+type NotificationWorkerConfig struct {
+	// PollInterval is how often the outbox is checked for due deliveries
+	PollInterval time.Duration
+	// Lease is how long a claim lasts before another worker may take the delivery over
+	Lease time.Duration
+	// InitialBackoff is the delay after the first failed attempt; it doubles per
+	// attempt up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxAge is how long after queueing a delivery is retried before it is marked failed
+	MaxAge time.Duration
+	// SendTimeout bounds a single delivery attempt
+	SendTimeout time.Duration
+	// MaxInFlight bounds the deliveries in progress at once, across every
+	// channel, so a burst of triggers cannot flood SMTP servers or webhook
+	// endpoints. Deliveries beyond it stay queued in the outbox.
+	MaxInFlight int
+}
+
+// DefaultNotificationMaxInFlight is the default bound on concurrent deliveries
+const DefaultNotificationMaxInFlight = 8
+
+// LoadNotificationMaxInFlight reads NOTIFICATION_MAX_IN_FLIGHT, the most
+// notifications delivered at once (default 8)
+func LoadNotificationMaxInFlight() (int, error) {
+	raw := os.Getenv("NOTIFICATION_MAX_IN_FLIGHT")
+	if raw == "" {
+		return DefaultNotificationMaxInFlight, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("NOTIFICATION_MAX_IN_FLIGHT must be a positive integer, got %q", raw)
+	}
+	return limit, nil
+}
+
+// DefaultNotificationWorkerConfig keeps retrying for a day, enough to ride out an endpoint outage
+func DefaultNotificationWorkerConfig() NotificationWorkerConfig {
+	return NotificationWorkerConfig{
+		PollInterval:   2 * time.Second,
+		Lease:          time.Minute,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     15 * time.Minute,
+		MaxAge:         24 * time.Hour,
+		SendTimeout:    10 * time.Second,
+		MaxInFlight:    DefaultNotificationMaxInFlight,
+	}
+}
+
+// NotificationWorker delivers due outbox rows, up to MaxInFlight at a time. A
+// row is only claimed once a delivery slot is free, so rows waiting for one
+// stay in the outbox rather than holding a lease. Any number of workers, in
+// this process or on other replicas, may run against the same outbox; the
+// bound is per worker.
+type NotificationWorker struct {
+	repo    domain.NotificationRepository
+	senders NotificationSenders
+	cfg     NotificationWorkerConfig
+	now     func() time.Time
+
+	// slots holds a token per delivery in progress
+	slots    chan struct{}
+	inFlight sync.WaitGroup
+}
+
+func NewNotificationWorker(repo domain.NotificationRepository, senders NotificationSenders, cfg NotificationWorkerConfig) *NotificationWorker {
+	metrics.Default.Describe("notifications_delivered_total", "Notifications delivered")
+	metrics.Default.Describe("notifications_retried_total", "Notification attempts that failed and were rescheduled")
+	metrics.Default.Describe("notifications_failed_total", "Notifications given up on after the maximum age or as undeliverable")
+	metrics.Default.Describe("notifications_in_flight", "Notification deliveries in progress")
+
+	if cfg.MaxInFlight < 1 {
+		cfg.MaxInFlight = 1
+	}
+	return &NotificationWorker{repo: repo, senders: senders, cfg: cfg, now: time.Now,
+		slots: make(chan struct{}, cfg.MaxInFlight)}
+}
+
+// Run delivers due notifications until ctx is cancelled, then waits for the
+// deliveries in progress to record their outcome
+func (w *NotificationWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+	defer w.inFlight.Wait()
+
+	for {
+		w.drain(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain starts delivering due notifications, each once a slot is free, until
+// none is left
+func (w *NotificationWorker) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		select {
+		case w.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		notification, err := w.repo.ClaimDue(ctx, w.now(), w.cfg.Lease)
+		if err != nil || notification == nil {
+			<-w.slots
+			if err != nil && ctx.Err() == nil {
+				logging.FromContext(ctx).Warn("Failed to claim notification", "error", err)
+			}
+			return
+		}
+
+		inFlight := metrics.Default.Gauge("notifications_in_flight", nil)
+		inFlight.Inc()
+		w.inFlight.Add(1)
+		go func() {
+			defer func() {
+				inFlight.Dec()
+				<-w.slots
+				w.inFlight.Done()
+			}()
+			w.deliver(ctx, notification)
+		}()
+	}
+}
+
+func (w *NotificationWorker) deliver(ctx context.Context, n *dto.NotificationResponse) {
+	var sendErr error
+	if sender, ok := w.senders[n.Channel]; ok {
+		sendCtx, cancel := context.WithTimeout(ctx, w.cfg.SendTimeout)
+		sendErr = sender.Send(sendCtx, n.Destination, n.Payload)
+		cancel()
+	} else {
+		sendErr = fmt.Errorf("no sender for channel %q: %w", n.Channel, ErrUndeliverable)
+	}
+
+	// Outcomes are recorded even while shutting down so the claim is released
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	log := logging.FromContext(ctx).With("notification_id", n.ID, "alert_id", n.AlertID, "channel", n.Channel, "attempt", n.Attempts)
+	now := w.now()
+	next := now.Add(w.backoff(n.Attempts))
+	// A rate limited destination says when to come back
+	var retryAfter *RetryAfterError
+	if errors.As(sendErr, &retryAfter) {
+		next = now.Add(retryAfter.After)
+	}
+	var err error
+	switch {
+	case sendErr == nil:
+		err = w.repo.MarkDelivered(recordCtx, n.ID, n.Attempts, now)
+		metrics.Default.Counter("notifications_delivered_total", nil).Inc()
+		log.Info("notification delivered")
+	case errors.Is(sendErr, ErrUndeliverable), next.After(n.CreatedAt.Add(w.cfg.MaxAge)):
+		err = w.repo.MarkFailed(recordCtx, n.ID, n.Attempts, sendErr.Error())
+		metrics.Default.Counter("notifications_failed_total", nil).Inc()
+		log.Error("notification failed permanently", "error", sendErr)
+	default:
+		err = w.repo.MarkRetry(recordCtx, n.ID, n.Attempts, next, sendErr.Error())
+		metrics.Default.Counter("notifications_retried_total", nil).Inc()
+		log.Warn("notification delivery failed, will retry", "error", sendErr, "next_attempt_at", next)
+	}
+	if err != nil {
+		// The lease expires and another attempt is made
+		log.Error("Failed to record notification outcome", "error", err)
+	}
+}
+
+// backoff returns the delay after the given failed attempt
+func (w *NotificationWorker) backoff(attempt int) time.Duration {
+	delay := w.cfg.InitialBackoff
+	for i := 1; i < attempt && delay < w.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > w.cfg.MaxBackoff {
+		delay = w.cfg.MaxBackoff
+	}
+	return delay
+}
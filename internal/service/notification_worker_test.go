@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/pkg/httpclient"
+)
+
+// gatedSender blocks every delivery until released and records the most
+// deliveries in progress at once
+type gatedSender struct {
+	release chan struct{}
+	started chan struct{}
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (s *gatedSender) Send(ctx context.Context, destination string, payload []byte) error {
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.peak {
+		s.peak = s.inFlight
+	}
+	s.mu.Unlock()
+	s.started <- struct{}{}
+
+	<-s.release
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+	return nil
+}
+
+// A burst of queued notifications is delivered MaxInFlight at a time; the rest
+// wait in the outbox, unclaimed, until a slot frees up
+func TestNotificationWorkerMaxInFlight(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	outbox := repository.NewMemoryNotificationRepository()
+	const burst, maxInFlight = 20, 3
+	for i := 0; i < burst; i++ {
+		if _, err := outbox.Enqueue(ctx, &dto.NotificationEnqueueRequest{AlertID: "a1", TriggerID: fmt.Sprintf("t%d", i),
+			Channel: dto.NotificationChannelWebhook, Destination: "https://example.com/hook"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sender := &gatedSender{release: make(chan struct{}), started: make(chan struct{}, burst)}
+	cfg := DefaultNotificationWorkerConfig()
+	cfg.PollInterval = 10 * time.Millisecond
+	cfg.MaxInFlight = maxInFlight
+	worker := NewNotificationWorker(outbox, NotificationSenders{dto.NotificationChannelWebhook: sender}, cfg)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		worker.Run(ctx)
+	}()
+
+	for i := 0; i < maxInFlight; i++ {
+		select {
+		case <-sender.started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d deliveries started, want %d", i, maxInFlight)
+		}
+	}
+	// Give the worker a few polls to overshoot
+	time.Sleep(50 * time.Millisecond)
+	if pending, _ := outbox.FindByStatus(ctx, dto.NotificationStatusPending, 0); len(pending) != burst-maxInFlight {
+		t.Errorf("%d notifications still pending while the slots are taken, want %d", len(pending), burst-maxInFlight)
+	}
+
+	close(sender.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		delivered, _ := outbox.FindByStatus(ctx, dto.NotificationStatusDelivered, 0)
+		if len(delivered) == burst {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivered %d of %d notifications", len(delivered), burst)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if sender.peak != maxInFlight {
+		t.Errorf("got at most %d deliveries in flight, want %d", sender.peak, maxInFlight)
+	}
+}
+
+// A webhook answering 503 is tried once per outbox attempt, however many
+// attempts the shared outbound configuration allows, so the worker's backoff
+// alone paces the retries
+func TestHTTPWebhookSenderSendsOnce(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := httpclient.DefaultConfig()
+	cfg.MaxAttempts = 3
+	cfg.InitialBackoff = time.Millisecond
+	sender := NewHTTPWebhookSender(NewOutboxHTTPClient(cfg))
+	if err := sender.Send(context.Background(), server.URL, []byte(`{}`)); err == nil {
+		t.Fatal("a 503 was reported delivered")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
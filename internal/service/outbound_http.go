@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hello-api/pkg/httpclient"
+	"github.com/hello-api/pkg/metrics"
+)
+
+// LoadOutboundHTTPConfig reads the retry settings of outbound HTTP calls
+// (webhooks, Telegram): OUTBOUND_HTTP_TIMEOUT per attempt (default 10s),
+// OUTBOUND_HTTP_MAX_ATTEMPTS (default 3) and OUTBOUND_HTTP_MAX_BACKOFF between
+// attempts (default 5s). Retries happen within a delivery attempt of the
+// notification worker, which still reschedules deliveries that fail.
+func LoadOutboundHTTPConfig() (httpclient.Config, error) {
+	cfg := httpclient.DefaultConfig()
+	if raw := os.Getenv("OUTBOUND_HTTP_TIMEOUT"); raw != "" {
+		timeout, err := time.ParseDuration(raw)
+		if err != nil || timeout <= 0 {
+			return cfg, fmt.Errorf("OUTBOUND_HTTP_TIMEOUT must be a positive duration, got %q", raw)
+		}
+		cfg.Timeout = timeout
+	}
+	if raw := os.Getenv("OUTBOUND_HTTP_MAX_ATTEMPTS"); raw != "" {
+		attempts, err := strconv.Atoi(raw)
+		if err != nil || attempts < 1 {
+			return cfg, fmt.Errorf("OUTBOUND_HTTP_MAX_ATTEMPTS must be a positive integer, got %q", raw)
+		}
+		cfg.MaxAttempts = attempts
+	}
+	if raw := os.Getenv("OUTBOUND_HTTP_MAX_BACKOFF"); raw != "" {
+		backoff, err := time.ParseDuration(raw)
+		if err != nil || backoff <= 0 {
+			return cfg, fmt.Errorf("OUTBOUND_HTTP_MAX_BACKOFF must be a positive duration, got %q", raw)
+		}
+		cfg.MaxBackoff = backoff
+	}
+	return cfg, nil
+}
+
+// NewOutboundHTTPClient creates the client outbound calls share, counting every
+// attempt per destination host and outcome
+func NewOutboundHTTPClient(cfg httpclient.Config) *httpclient.Client {
+	metrics.Default.Describe("outbound_http_attempts_total", "Outbound HTTP attempts by destination host and outcome")
+	metrics.Default.Describe("outbound_http_duration_seconds", "Outbound HTTP attempt latency by destination host")
+	cfg.Hooks.OnAttempt = func(attempt httpclient.Attempt) {
+		metrics.Default.Counter("outbound_http_attempts_total", metrics.Labels{"host": attempt.Host, "outcome": string(attempt.Outcome)}).Inc()
+		metrics.Default.Histogram("outbound_http_duration_seconds", metrics.Labels{"host": attempt.Host}).Observe(attempt.Latency.Seconds())
+	}
+	return httpclient.New(cfg)
+}
+
+// NewOutboxHTTPClient creates the outbound client for deliveries from the
+// notification outbox. The worker retries failed deliveries on its own
+// backoff, so each delivery attempt is a single request.
+func NewOutboxHTTPClient(cfg httpclient.Config) *httpclient.Client {
+	cfg.MaxAttempts = 1
+	return NewOutboundHTTPClient(cfg)
+}
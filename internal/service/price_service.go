@@ -0,0 +1,363 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hello-api/internal/common/timeutil"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/metrics"
+	"github.com/hello-api/pkg/money"
+)
+
+// Reasons a tick is quarantined
+const (
+	TickNonPositivePrice = "non_positive_price"
+	TickDeviation        = "deviation"
+	TickFutureTimestamp  = "future_timestamp"
+)
+
+// maxTicksPerBatch bounds a single ingest request
+const maxTicksPerBatch = 5000
+
+// maxQuarantinedListed bounds quarantine listings
+const maxQuarantinedListed = 500
+
+// TickFilterConfig tunes the sanity checks applied to ingested ticks
+type TickFilterConfig struct {
+	// MaxDeviationPercent is how far a tick may move from the last accepted
+	// price of its symbol; zero disables the check
+	MaxDeviationPercent float64
+	// FutureTolerance is how far ahead of now a tick timestamp may be
+	FutureTolerance time.Duration
+}
+
+// DefaultTickFilterConfig allows moves well beyond the DSE circuit breakers
+// while catching decimal errors such as 100x spikes
+func DefaultTickFilterConfig() TickFilterConfig {
+	return TickFilterConfig{
+		MaxDeviationPercent: 50,
+		FutureTolerance:     time.Minute,
+	}
+}
+
+// LoadTickFilterConfig overrides the defaults from TICK_MAX_DEVIATION_PERCENT
+// and TICK_FUTURE_TOLERANCE (e.g. "30s")
+func LoadTickFilterConfig() (TickFilterConfig, error) {
+	cfg := DefaultTickFilterConfig()
+	if raw := os.Getenv("TICK_MAX_DEVIATION_PERCENT"); raw != "" {
+		pct, err := strconv.ParseFloat(raw, 64)
+		if err != nil || pct < 0 {
+			return cfg, fmt.Errorf("TICK_MAX_DEVIATION_PERCENT must be a non-negative number, got %q", raw)
+		}
+		cfg.MaxDeviationPercent = pct
+	}
+	if raw := os.Getenv("TICK_FUTURE_TOLERANCE"); raw != "" {
+		tolerance, err := time.ParseDuration(raw)
+		if err != nil || tolerance < 0 {
+			return cfg, fmt.Errorf("TICK_FUTURE_TOLERANCE must be a non-negative duration, got %q", raw)
+		}
+		cfg.FutureTolerance = tolerance
+	}
+	return cfg, nil
+}
+
+// CheckTick returns why a tick should be quarantined, or "" when it looks sane.
+// last is the last accepted price of the symbol, nil when there is none.
+func CheckTick(tick dto.PriceTickRequest, last *money.Amount, now time.Time, cfg TickFilterConfig) string {
+	if tick.Price <= 0 {
+		return TickNonPositivePrice
+	}
+	if tick.Time.After(now.Add(cfg.FutureTolerance)) {
+		return TickFutureTimestamp
+	}
+	if last != nil && *last > 0 && cfg.MaxDeviationPercent > 0 {
+		deviation := math.Abs(float64(tick.Price-*last)) / float64(*last) * 100
+		if deviation > cfg.MaxDeviationPercent {
+			return TickDeviation
+		}
+	}
+	return ""
+}
+
+type PriceService struct {
+	prices     domain.PriceRepository
+	quarantine domain.QuarantineRepository
+	filter     TickFilterConfig
+	// schedule gives the trading date that day statistics roll over on
+	schedule MarketSchedule
+	// evaluator fires alerts met by accepted ticks; nil disables evaluation
+	evaluator *TickEvaluator
+	// symbols records the symbols of accepted ticks as reference data; may be nil
+	symbols *SymbolService
+}
+
+func NewPriceService(prices domain.PriceRepository, quarantine domain.QuarantineRepository, filter TickFilterConfig, schedule MarketSchedule, evaluator *TickEvaluator, symbols *SymbolService) *PriceService {
+	metrics.Default.Describe("price_ticks_accepted_total", "Ingested price ticks that passed the sanity checks")
+	metrics.Default.Describe("price_ticks_quarantined_total", "Ingested price ticks held back by the sanity checks, by reason")
+	metrics.Default.Describe("price_ticks_backfilled_total", "Backfilled price ticks stored, by whether they were new or duplicates")
+	metrics.Default.Describe("price_tick_lag_seconds", "How stale ingested price ticks were, by part: feed (exchange to data feed), ingest (data feed to API) and total")
+	return &PriceService{prices: prices, quarantine: quarantine, filter: filter, schedule: schedule, evaluator: evaluator, symbols: symbols}
+}
+
+// Ingest applies the sanity checks to a batch in order, storing sane ticks and
+// quarantining the rest. Quarantined ticks never become the last accepted price.
+func (s *PriceService) Ingest(ctx context.Context, req dto.PriceIngestRequest) (*dto.PriceIngestResponse, error) {
+	if len(req.Ticks) == 0 {
+		return nil, fmt.Errorf("ticks are required: %w", domain.ErrValidation)
+	}
+	if len(req.Ticks) > maxTicksPerBatch {
+		return nil, fmt.Errorf("at most %d ticks per batch: %w", maxTicksPerBatch, domain.ErrValidation)
+	}
+	for i := range req.Ticks {
+		req.Ticks[i].Time = timeutil.UTC(req.Ticks[i].Time)
+		req.Ticks[i].Symbol = strings.ToUpper(strings.TrimSpace(req.Ticks[i].Symbol))
+		if req.Ticks[i].Symbol == "" {
+			return nil, fmt.Errorf("tick %d has no symbol: %w", i, domain.ErrValidation)
+		}
+		if req.Backfill && req.Ticks[i].Time.IsZero() {
+			return nil, fmt.Errorf("backfilled tick %d has no time: %w", i, domain.ErrValidation)
+		}
+	}
+	if req.Backfill {
+		return s.ingestBackfill(ctx, req.Ticks)
+	}
+
+	now := time.Now().UTC()
+	last := make(map[string]*money.Amount)
+	result := &dto.PriceIngestResponse{Quarantined: []dto.QuarantinedTickResponse{}}
+	var accepted []dto.PriceTickRequest
+	for _, tick := range req.Ticks {
+		if tick.Time.IsZero() {
+			tick.Time = now
+		}
+		tick.Lag = tickLag(tick, now)
+		lastPrice, ok := last[tick.Symbol]
+		if !ok {
+			latest, err := s.prices.Latest(ctx, tick.Symbol)
+			if err != nil {
+				return nil, err
+			}
+			if latest != nil {
+				lastPrice = &latest.Price
+			}
+		}
+
+		if reason := CheckTick(tick, lastPrice, now, s.filter); reason != "" {
+			quarantined, err := s.quarantineTick(ctx, tick, reason, lastPrice)
+			if err != nil {
+				return nil, err
+			}
+			result.Quarantined = append(result.Quarantined, *quarantined)
+			last[tick.Symbol] = lastPrice
+			continue
+		}
+
+		tradingDate := s.schedule.TradingDate(tick.Time)
+		inserted, err := s.prices.Insert(ctx, &tick, tradingDate)
+		if err != nil {
+			return nil, err
+		}
+		if s.evaluator != nil {
+			result.Fired += s.evaluator.Evaluate(ctx, tick, inserted.Latest, tradingDate)
+		}
+		metrics.Default.Counter("price_ticks_accepted_total", nil).Inc()
+		observeTickLag(tick.Lag)
+		price := tick.Price
+		last[tick.Symbol] = &price
+		result.Accepted++
+		accepted = append(accepted, tick)
+	}
+	if s.symbols != nil && len(accepted) > 0 {
+		s.symbols.RecordSeen(ctx, accepted, now)
+	}
+	return result, nil
+}
+
+// tickLag works out how stale a live tick is when ingested at now. tick.Time
+// is when the data feed processed the tick. The feed part is the data feed's
+// own measure, taken on the exchange's clock with its skew estimate, and only
+// known when the feed carries an exchange time. The data feed and the API may
+// disagree on the time by a little, so a negative ingest lag counts as none.
+func tickLag(tick dto.PriceTickRequest, now time.Time) *dto.TickLag {
+	lag := &dto.TickLag{AsOf: tick.Time, IngestMs: max(now.Sub(tick.Time).Milliseconds(), 0)}
+	var feed *int64
+	switch {
+	case tick.FeedLagMs != nil:
+		ms := max(*tick.FeedLagMs, 0)
+		feed = &ms
+	case tick.ExchangeTime != nil && !tick.ExchangeTime.IsZero():
+		ms := max(tick.Time.Sub(*tick.ExchangeTime).Milliseconds(), 0)
+		feed = &ms
+	}
+	if feed == nil {
+		lag.TotalMs = lag.IngestMs
+		return lag
+	}
+	lag.FeedMs = feed
+	lag.TotalMs = lag.IngestMs + *feed
+	if tick.ExchangeTime != nil && !tick.ExchangeTime.IsZero() {
+		lag.AsOf = timeutil.UTC(*tick.ExchangeTime)
+	} else {
+		lag.AsOf = tick.Time.Add(-time.Duration(*feed) * time.Millisecond)
+	}
+	return lag
+}
+
+func observeTickLag(lag *dto.TickLag) {
+	if lag == nil {
+		return
+	}
+	if lag.FeedMs != nil {
+		metrics.Default.Histogram("price_tick_lag_seconds", metrics.Labels{"part": "feed"}).Observe(float64(*lag.FeedMs) / 1000)
+	}
+	metrics.Default.Histogram("price_tick_lag_seconds", metrics.Labels{"part": "ingest"}).Observe(float64(lag.IngestMs) / 1000)
+	metrics.Default.Histogram("price_tick_lag_seconds", metrics.Labels{"part": "total"}).Observe(lag.Total().Seconds())
+}
+
+// ingestBackfill stores ticks recorded earlier, e.g. replayed from a capture
+// after a forwarder outage. Ticks stored already are skipped, so a capture can
+// be sent again. The day statistics of every symbol and trading date touched
+// are then recomputed from the stored ticks rather than rolled forward, and the
+// new ticks are evaluated in shadow mode only: a stale crossing notifies no one.
+func (s *PriceService) ingestBackfill(ctx context.Context, ticks []dto.PriceTickRequest) (*dto.PriceIngestResponse, error) {
+	type symbolDate struct {
+		symbol      string
+		tradingDate string
+	}
+	now := time.Now().UTC()
+	result := &dto.PriceIngestResponse{Quarantined: []dto.QuarantinedTickResponse{}, Recomputed: []dto.RecomputedDay{}}
+	stored := make(map[symbolDate][]dto.PriceTickRequest)
+	var days []symbolDate
+	var accepted []dto.PriceTickRequest
+	for _, tick := range ticks {
+		// The last accepted price is from after the gap, so moves are not checked against it
+		if reason := CheckTick(tick, nil, now, s.filter); reason != "" {
+			quarantined, err := s.quarantineTick(ctx, tick, reason, nil)
+			if err != nil {
+				return nil, err
+			}
+			result.Quarantined = append(result.Quarantined, *quarantined)
+			continue
+		}
+		inserted, err := s.prices.InsertIfAbsent(ctx, &tick)
+		if err != nil {
+			return nil, err
+		}
+		if inserted == nil {
+			metrics.Default.Counter("price_ticks_backfilled_total", metrics.Labels{"result": "duplicate"}).Inc()
+			result.Duplicates++
+			continue
+		}
+		metrics.Default.Counter("price_ticks_backfilled_total", metrics.Labels{"result": "stored"}).Inc()
+		day := symbolDate{symbol: tick.Symbol, tradingDate: s.schedule.TradingDate(tick.Time)}
+		if _, ok := stored[day]; !ok {
+			days = append(days, day)
+		}
+		stored[day] = append(stored[day], tick)
+		result.Accepted++
+		accepted = append(accepted, tick)
+	}
+
+	for _, day := range days {
+		from, err := timeutil.ParseDate(day.tradingDate, s.schedule.Location)
+		if err != nil {
+			return nil, err
+		}
+		latest, err := s.prices.RecomputeDay(ctx, day.symbol, day.tradingDate, from, from.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+		result.Recomputed = append(result.Recomputed, dto.RecomputedDay{Symbol: day.symbol, TradingDate: day.tradingDate, Latest: latest})
+		if s.evaluator != nil {
+			dayTicks := stored[day]
+			sort.SliceStable(dayTicks, func(i, j int) bool { return dayTicks[i].Time.Before(dayTicks[j].Time) })
+			result.ShadowFired += s.evaluator.EvaluateBackfill(ctx, dayTicks, latest, day.tradingDate)
+		}
+	}
+	if s.symbols != nil && len(accepted) > 0 {
+		s.symbols.RecordSeen(ctx, accepted, now)
+	}
+	logging.FromContext(ctx).Info("price ticks backfilled", "stored", result.Accepted,
+		"duplicates", result.Duplicates, "quarantined", len(result.Quarantined), "days", len(days), "shadow_fired", result.ShadowFired)
+	return result, nil
+}
+
+// quarantineTick holds back a tick that failed the sanity checks
+func (s *PriceService) quarantineTick(ctx context.Context, tick dto.PriceTickRequest, reason string, lastPrice *money.Amount) (*dto.QuarantinedTickResponse, error) {
+	quarantined, err := s.quarantine.Add(ctx, &dto.QuarantineRequest{Tick: tick, Reason: reason, LastAcceptedPrice: lastPrice})
+	if err != nil {
+		return nil, err
+	}
+	metrics.Default.Counter("price_ticks_quarantined_total", metrics.Labels{"reason": reason}).Inc()
+	logging.FromContext(ctx).Warn("price tick quarantined",
+		"symbol", tick.Symbol, "price", tick.Price, "reason", reason, "tick_id", quarantined.ID)
+	return quarantined, nil
+}
+
+// GetLatest returns the latest price of a symbol with its day statistics
+func (s *PriceService) GetLatest(ctx context.Context, symbol string) (*dto.LatestPriceResponse, error) {
+	latest, err := s.prices.Latest(ctx, strings.ToUpper(strings.TrimSpace(symbol)))
+	if err != nil {
+		return nil, err
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("no price recorded for %s: %w", symbol, domain.ErrPriceNotFound)
+	}
+	return latest, nil
+}
+
+// GetQuarantined lists quarantined ticks by status, newest first
+func (s *PriceService) GetQuarantined(ctx context.Context, status string) ([]dto.QuarantinedTickResponse, error) {
+	switch st := dto.QuarantineStatus(status); st {
+	case dto.QuarantineStatusHeld, dto.QuarantineStatusReleased:
+		return s.quarantine.FindByStatus(ctx, st, maxQuarantinedListed)
+	}
+	return nil, fmt.Errorf("unknown quarantine status %q: %w", status, domain.ErrValidation)
+}
+
+// ReleaseQuarantined stores a quarantined tick that turned out to be legitimate,
+// e.g. a real move on a circuit-breaker day, so it becomes the symbol's last
+// accepted price
+func (s *PriceService) ReleaseQuarantined(ctx context.Context, id string) (*dto.PriceTickResponse, error) {
+	held, err := s.quarantine.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if held == nil {
+		return nil, domain.ErrTickNotFound
+	}
+	released, err := s.quarantine.MarkReleased(ctx, id, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !released {
+		return nil, fmt.Errorf("tick %s is already %s: %w", id, held.Status, domain.ErrValidation)
+	}
+
+	req := dto.PriceTickRequest{
+		Symbol: held.Symbol,
+		Price:  held.Price,
+		Volume: held.Volume,
+		Time:   held.Time,
+	}
+	tradingDate := s.schedule.TradingDate(held.Time)
+	tick, err := s.prices.Insert(ctx, &req, tradingDate)
+	if err != nil {
+		return nil, err
+	}
+	if s.evaluator != nil {
+		s.evaluator.Evaluate(ctx, req, tick.Latest, tradingDate)
+	}
+	logging.FromContext(ctx).Info("quarantined price tick released",
+		"tick_id", id, "symbol", held.Symbol, "price", held.Price, "reason", held.Reason)
+	return tick, nil
+}
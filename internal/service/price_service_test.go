@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/pkg/money"
+)
+
+// The feed part comes from the data feed's measure or else its exchange time,
+// the ingest part from the tick's processing time, and clock disagreement
+// never makes either negative
+func TestTickLag(t *testing.T) {
+	now := time.Date(2024, 3, 4, 8, 32, 52, 0, time.UTC)
+	processed := now.Add(-2 * time.Second)
+	exchange := processed.Add(-45 * time.Second)
+	ms := func(v int64) *int64 { return &v }
+
+	for _, tc := range []struct {
+		name      string
+		tick      dto.PriceTickRequest
+		wantFeed  *int64
+		wantTotal int64
+		wantAsOf  time.Time
+	}{
+		{name: "no exchange time", tick: dto.PriceTickRequest{Time: processed}, wantTotal: 2000, wantAsOf: processed},
+		{name: "feed lag", tick: dto.PriceTickRequest{Time: processed, FeedLagMs: ms(45000)}, wantFeed: ms(45000), wantTotal: 47000, wantAsOf: exchange},
+		{name: "exchange time", tick: dto.PriceTickRequest{Time: processed, ExchangeTime: &exchange}, wantFeed: ms(45000), wantTotal: 47000, wantAsOf: exchange},
+		// the data feed's measure corrects for its clock skew, so it wins
+		{name: "both", tick: dto.PriceTickRequest{Time: processed, ExchangeTime: &exchange, FeedLagMs: ms(40000)}, wantFeed: ms(40000), wantTotal: 42000, wantAsOf: exchange},
+		{name: "processed ahead of the API", tick: dto.PriceTickRequest{Time: now.Add(time.Second), FeedLagMs: ms(-500)}, wantFeed: ms(0), wantTotal: 0, wantAsOf: now.Add(time.Second)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lag := tickLag(tc.tick, now)
+			if (lag.FeedMs == nil) != (tc.wantFeed == nil) || lag.FeedMs != nil && *lag.FeedMs != *tc.wantFeed {
+				t.Errorf("got feed lag %v, want %v", lag.FeedMs, tc.wantFeed)
+			}
+			if lag.TotalMs != tc.wantTotal || !lag.AsOf.Equal(tc.wantAsOf) {
+				t.Errorf("got %dms as of %s, want %dms as of %s", lag.TotalMs, lag.AsOf, tc.wantTotal, tc.wantAsOf)
+			}
+			if lag.Partial() != (tc.wantFeed == nil) {
+				t.Errorf("got partial %t, want %t", lag.Partial(), tc.wantFeed == nil)
+			}
+		})
+	}
+}
+
+// Live ticks keep their lag on the symbol's latest price; backfilled ticks
+// have none
+func TestIngestRecordsLag(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryPriceRepository()
+	prices := NewPriceService(repo, repository.NewMemoryQuarantineRepository(), DefaultTickFilterConfig(), DefaultMarketSchedule(), nil, nil)
+	feedLag := int64(45000)
+	processed := time.Now().UTC().Add(-2 * time.Second)
+
+	live := dto.PriceTickRequest{Symbol: "gp", Price: money.FromFloat(350), Time: processed, FeedLagMs: &feedLag}
+	if result, err := prices.Ingest(ctx, dto.PriceIngestRequest{Ticks: []dto.PriceTickRequest{live}}); err != nil || result.Accepted != 1 {
+		t.Fatalf("got %+v, %v, want the tick accepted", result, err)
+	}
+	latest, err := repo.Latest(ctx, "GP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest == nil || latest.Lag == nil || latest.Lag.FeedMs == nil || *latest.Lag.FeedMs != feedLag {
+		t.Fatalf("got latest %+v, want the feed lag kept", latest)
+	}
+	if lag := latest.Lag; lag.IngestMs < 2000 || lag.TotalMs != lag.IngestMs+feedLag || !lag.AsOf.Equal(processed.Add(-45*time.Second)) {
+		t.Errorf("got lag %+v, want at least 2s ingest on top of the feed's 45s", lag)
+	}
+
+	backfilled := dto.PriceTickRequest{Symbol: "BATBC", Price: money.FromFloat(500), Time: processed, FeedLagMs: &feedLag}
+	if _, err := prices.Ingest(ctx, dto.PriceIngestRequest{Ticks: []dto.PriceTickRequest{backfilled}, Backfill: true}); err != nil {
+		t.Fatal(err)
+	}
+	if latest, _ := repo.Latest(ctx, "BATBC"); latest == nil || latest.Lag != nil {
+		t.Errorf("got latest %+v for a backfilled tick, want no lag", latest)
+	}
+}
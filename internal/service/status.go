@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/logging"
+)
+
+const (
+	// DefaultFeedStaleAfter is how long without a tick before ingestion is reported as stalled
+	DefaultFeedStaleAfter = 5 * time.Minute
+	// StatusCacheTTL is how long a computed status is served before it is rebuilt
+	StatusCacheTTL = 10 * time.Second
+	// triggersCounter is the daily counter of recorded alert triggers
+	triggersCounter = "triggers"
+)
+
+// LoadFeedStaleAfter reads STATUS_FEED_STALE_AFTER (e.g. "5m")
+func LoadFeedStaleAfter() (time.Duration, error) {
+	raw := os.Getenv("STATUS_FEED_STALE_AFTER")
+	if raw == "" {
+		return DefaultFeedStaleAfter, nil
+	}
+	staleAfter, err := time.ParseDuration(raw)
+	if err != nil || staleAfter <= 0 {
+		return 0, fmt.Errorf("STATUS_FEED_STALE_AFTER must be a positive duration, got %q", raw)
+	}
+	return staleAfter, nil
+}
+
+// StatusService reports whether alerts are working from cheap sources only: the
+// latest prices, one per symbol, with how stale each was when ingested, a daily
+// trigger counter and the market calendar. The result is cached for StatusCacheTTL, and concurrent requests
+// share one rebuild.
+type StatusService struct {
+	prices     domain.PriceRepository
+	counters   domain.DailyCounterRepository
+	calendar   domain.MarketCalendarService
+	schedule   MarketSchedule
+	staleAfter time.Duration
+	now        func() time.Time
+
+	mu       sync.Mutex
+	cached   *dto.StatusResponse
+	cachedAt time.Time
+}
+
+func NewStatusService(prices domain.PriceRepository, counters domain.DailyCounterRepository, calendar domain.MarketCalendarService, schedule MarketSchedule, staleAfter time.Duration) *StatusService {
+	if staleAfter <= 0 {
+		staleAfter = DefaultFeedStaleAfter
+	}
+	return &StatusService{
+		prices:     prices,
+		counters:   counters,
+		calendar:   calendar,
+		schedule:   schedule,
+		staleAfter: staleAfter,
+		now:        time.Now,
+	}
+}
+
+// CountTrigger adds a recorded trigger to the count of its trading date. A
+// failure is only logged: the count is informational.
+func (s *StatusService) CountTrigger(ctx context.Context, at time.Time) {
+	if s == nil {
+		return
+	}
+	if err := s.counters.Increment(ctx, triggersCounter, s.schedule.TradingDate(at)); err != nil {
+		logging.FromContext(ctx).Warn("failed to count alert trigger", "error", err)
+	}
+}
+
+// GetStatus returns the cached status, rebuilding it once it is older than StatusCacheTTL
+func (s *StatusService) GetStatus(ctx context.Context) (*dto.StatusResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.cached != nil && now.Sub(s.cachedAt) < StatusCacheTTL {
+		return s.cached, nil
+	}
+	status, err := s.build(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	s.cached, s.cachedAt = status, now
+	return status, nil
+}
+
+func (s *StatusService) build(ctx context.Context, now time.Time) (*dto.StatusResponse, error) {
+	session, err := s.calendar.Session(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	recent, err := s.prices.MostRecent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	triggers, err := s.counters.Get(ctx, triggersCounter, session.TradingDate)
+	if err != nil {
+		return nil, err
+	}
+	fresh, err := s.prices.UpdatedSince(ctx, now.Add(-s.staleAfter))
+	if err != nil {
+		return nil, err
+	}
+
+	status := &dto.StatusResponse{
+		Ingestion:     dto.IngestionStatus{StaleAfter: s.staleAfter.String(), Symbols: make([]dto.SymbolFreshness, len(fresh))},
+		TriggersToday: triggers,
+		Market:        session,
+		GeneratedAt:   now.UTC(),
+	}
+	if recent != nil {
+		tickAt, processedAt := recent.Time, recent.UpdatedAt
+		status.Ingestion.LatestTickAt = &tickAt
+		status.Ingestion.LatestProcessedAt = &processedAt
+		status.Ingestion.Receiving = now.Sub(processedAt) <= s.staleAfter
+	}
+	for i, latest := range fresh {
+		status.Ingestion.Symbols[i] = dto.SymbolFreshness{Symbol: latest.Symbol, LatestTickAt: latest.Time, Lag: latest.Lag}
+	}
+	return status, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/pkg/money"
+)
+
+// The status reports ingestion as receiving while a tick was processed within
+// staleAfter, lists the symbols fresh within it with their lag and counts the
+// day's triggers, and a built status is served from the cache until
+// StatusCacheTTL has passed
+func TestStatusService(t *testing.T) {
+	ctx := context.Background()
+	prices := repository.NewMemoryPriceRepository()
+	schedule := DefaultMarketSchedule()
+	calendar := NewMarketCalendarService(schedule, repository.NewMemoryHolidayRepository())
+	status := NewStatusService(prices, repository.NewMemoryDailyCounterRepository(), calendar, schedule, time.Minute)
+	now := time.Now()
+	status.now = func() time.Time { return now }
+
+	before, err := status.GetStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Ingestion.Receiving || before.Ingestion.LatestTickAt != nil || before.TriggersToday != 0 {
+		t.Fatalf("got %+v before any tick, want nothing received", before.Ingestion)
+	}
+
+	lag := &dto.TickLag{AsOf: now.Add(-48 * time.Second), IngestMs: 1000, TotalMs: 47000}
+	tick := &dto.PriceTickRequest{Symbol: "GP", Price: money.FromFloat(350), Volume: 10, Time: now.Add(-time.Second), Lag: lag}
+	if _, err := prices.Insert(ctx, tick, schedule.TradingDate(tick.Time)); err != nil {
+		t.Fatal(err)
+	}
+	status.CountTrigger(ctx, now)
+	status.CountTrigger(ctx, now)
+	if cached, _ := status.GetStatus(ctx); cached != before {
+		t.Error("the status was rebuilt within StatusCacheTTL")
+	}
+
+	for _, tc := range []struct {
+		name          string
+		after         time.Duration
+		wantReceiving bool
+		wantSymbols   int
+	}{
+		{name: "fresh", after: StatusCacheTTL, wantReceiving: true, wantSymbols: 1},
+		{name: "stale", after: StatusCacheTTL + 2*time.Minute, wantReceiving: false, wantSymbols: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			status.now = func() time.Time { return now.Add(tc.after) }
+			got, err := status.GetStatus(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Ingestion.Receiving != tc.wantReceiving || len(got.Ingestion.Symbols) != tc.wantSymbols {
+				t.Errorf("got receiving %t with %d symbols, want %t with %d", got.Ingestion.Receiving, len(got.Ingestion.Symbols), tc.wantReceiving, tc.wantSymbols)
+			}
+			for _, symbol := range got.Ingestion.Symbols {
+				if symbol.Symbol != "GP" || !symbol.LatestTickAt.Equal(tick.Time) || symbol.Lag == nil || symbol.Lag.TotalMs != lag.TotalMs {
+					t.Errorf("got %+v, want GP with its tick's %dms lag", symbol, lag.TotalMs)
+				}
+			}
+			if got.Ingestion.LatestTickAt == nil || !got.Ingestion.LatestTickAt.Equal(tick.Time) {
+				t.Errorf("got latest tick at %v, want %s", got.Ingestion.LatestTickAt, tick.Time)
+			}
+			if got.TriggersToday != 2 {
+				t.Errorf("got %d triggers today, want 2", got.TriggersToday)
+			}
+		})
+	}
+}
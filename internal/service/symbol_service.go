@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/logging"
+)
+
+const (
+	defaultSymbolSearchLimit = 20
+	maxSymbolSearchLimit     = 100
+	// maxSymbolImportRows bounds one uploaded symbol list
+	maxSymbolImportRows = 10000
+	// maxSuggestionPrefix bounds the lookups behind one suggestion
+	maxSuggestionPrefix = 16
+)
+
+// SymbolValidation is whether alert symbols must be known reference data
+type SymbolValidation string
+
+const (
+	// SymbolValidationStrict rejects alerts for symbols missing from the reference data
+	SymbolValidationStrict SymbolValidation = "strict"
+	// SymbolValidationOff accepts any symbol, for exchanges without reference data
+	SymbolValidationOff SymbolValidation = "off"
+)
+
+// parseSymbolValidation reads strict or off; empty means strict
+func parseSymbolValidation(raw string) (SymbolValidation, error) {
+	switch validation := SymbolValidation(raw); validation {
+	case "":
+		return SymbolValidationStrict, nil
+	case SymbolValidationStrict, SymbolValidationOff:
+		return validation, nil
+	default:
+		return "", fmt.Errorf("must be strict or off, got %q", raw)
+	}
+}
+
+// SymbolService keeps the symbol reference data the feed and admins supply
+// and checks alert symbols against it
+type SymbolService struct {
+	repo domain.SymbolRepository
+	// flags give whether alert symbols are validated
+	flags *FeatureFlags
+}
+
+func NewSymbolService(repo domain.SymbolRepository, flags *FeatureFlags) *SymbolService {
+	return &SymbolService{repo: repo, flags: flags}
+}
+
+// Search finds up to limit symbols whose symbol or name starts with query,
+// case-insensitively; a limit of zero takes the default
+func (s *SymbolService) Search(ctx context.Context, query string, limit int64) ([]dto.SymbolResponse, error) {
+	if limit < 0 || limit > maxSymbolSearchLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d: %w", maxSymbolSearchLimit, domain.ErrValidation)
+	}
+	if limit == 0 {
+		limit = defaultSymbolSearchLimit
+	}
+	return s.repo.Search(ctx, normalizeSymbol(query), limit)
+}
+
+// Import upserts the rows of a symbol,name,sector CSV. Name and sector may be
+// left out; a first row starting with the column name "symbol" is a header.
+// Rows without a symbol are skipped and reported.
+func (s *SymbolService) Import(ctx context.Context, r io.Reader) (*dto.SymbolImportResponse, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	result := &dto.SymbolImportResponse{Skipped: []dto.SymbolImportSkip{}}
+	var symbols []dto.SymbolRequest
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v: %w", err, domain.ErrValidation)
+		}
+		if row == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "symbol") {
+			continue
+		}
+		if row > maxSymbolImportRows {
+			return nil, fmt.Errorf("at most %d rows per import: %w", maxSymbolImportRows, domain.ErrValidation)
+		}
+		symbol := dto.SymbolRequest{Symbol: normalizeSymbol(record[0])}
+		if symbol.Symbol == "" {
+			result.Skipped = append(result.Skipped, dto.SymbolImportSkip{Row: row, Reason: "no symbol"})
+			continue
+		}
+		if len(record) > 1 {
+			symbol.Name = strings.TrimSpace(record[1])
+		}
+		if len(record) > 2 {
+			symbol.Sector = strings.TrimSpace(record[2])
+		}
+		symbols = append(symbols, symbol)
+	}
+	if err := s.repo.Upsert(ctx, symbols, nil); err != nil {
+		return nil, err
+	}
+	result.Imported = len(symbols)
+	logging.FromContext(ctx).Info("symbols imported", "imported", result.Imported, "skipped", len(result.Skipped))
+	return result, nil
+}
+
+// RecordSeen upserts the symbols of accepted ticks with the time they were
+// seen. The ticks are already stored, so a failure is logged rather than
+// returned.
+func (s *SymbolService) RecordSeen(ctx context.Context, ticks []dto.PriceTickRequest, seenAt time.Time) {
+	seen := make(map[string]int)
+	var symbols []dto.SymbolRequest
+	for _, tick := range ticks {
+		symbol := dto.SymbolRequest{Symbol: tick.Symbol, Name: strings.TrimSpace(tick.Name), Sector: strings.TrimSpace(tick.Sector)}
+		if i, ok := seen[tick.Symbol]; ok {
+			// Later ticks of a batch describe the symbol best
+			if symbol.Name != "" {
+				symbols[i].Name = symbol.Name
+			}
+			if symbol.Sector != "" {
+				symbols[i].Sector = symbol.Sector
+			}
+			continue
+		}
+		seen[tick.Symbol] = len(symbols)
+		symbols = append(symbols, symbol)
+	}
+	if err := s.repo.Upsert(ctx, symbols, &seenAt); err != nil {
+		logging.FromContext(ctx).Error("failed to record seen symbols", "symbols", len(symbols), "error", err)
+	}
+}
+
+// ValidateSymbol rejects symbols missing from the reference data, suggesting
+// the closest known one by prefix. It accepts every symbol when validation is off.
+func (s *SymbolService) ValidateSymbol(ctx context.Context, symbol string) error {
+	if FlagSymbolValidation.Get(s.flags) == SymbolValidationOff {
+		return nil
+	}
+	exists, err := s.repo.Exists(ctx, symbol)
+	if err != nil || exists {
+		return err
+	}
+	suggestion, err := s.suggest(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	if suggestion == "" {
+		return fmt.Errorf("unknown symbol %s: %w", symbol, domain.ErrValidation)
+	}
+	return fmt.Errorf("unknown symbol %s, did you mean %s?: %w", symbol, suggestion, domain.ErrValidation)
+}
+
+// suggest returns the first known symbol sharing the longest prefix with
+// symbol, by symbol or by name, so "GRAMEENPHON" finds GP by its name
+// Grameenphone. Prefixes shorter than two characters match too much to help.
+func (s *SymbolService) suggest(ctx context.Context, symbol string) (string, error) {
+	runes := []rune(symbol)
+	for n := min(len(runes), maxSuggestionPrefix); n >= 2; n-- {
+		matches, err := s.repo.Search(ctx, string(runes[:n]), 1)
+		if err != nil {
+			return "", err
+		}
+		if len(matches) > 0 {
+			return matches[0].Symbol, nil
+		}
+	}
+	return "", nil
+}
+
+// normalizeSymbol upper-cases a symbol the way alerts and ticks store it
+func normalizeSymbol(symbol string) string {
+	return strings.ToUpper(strings.TrimSpace(symbol))
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/pkg/money"
+)
+
+// symbolList is an uploaded symbol list with a header, a quoted name, a
+// lower-case symbol, rows without name or sector and a row without a symbol
+const symbolList = `symbol,name,sector
+GP,Grameenphone Ltd.,Telecommunication
+ squarepharma , "Square Pharmaceuticals, PLC", Pharmaceuticals
+,Nameless Ltd.,Bank
+BATBC,British American Tobacco Bangladesh
+BRACBANK
+`
+
+// newTestSymbolService returns a symbol service over the imported symbolList
+func newTestSymbolService(t *testing.T) (*SymbolService, *FeatureFlags) {
+	t.Helper()
+	flags := NewFeatureFlags(repository.NewMemoryFeatureFlagRepository(), FeatureFlagEnv{}, time.Minute)
+	symbols := NewSymbolService(repository.NewMemorySymbolRepository(), flags)
+	if _, err := symbols.Import(context.Background(), strings.NewReader(symbolList)); err != nil {
+		t.Fatal(err)
+	}
+	return symbols, flags
+}
+
+// Valid rows are imported with whatever name and sector they have; rows
+// without a symbol are skipped and reported, and a malformed list imports nothing
+func TestSymbolImport(t *testing.T) {
+	ctx := context.Background()
+	symbols := NewSymbolService(repository.NewMemorySymbolRepository(), nil)
+
+	result, err := symbols.Import(ctx, strings.NewReader(symbolList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Imported != 4 || len(result.Skipped) != 1 || result.Skipped[0] != (dto.SymbolImportSkip{Row: 4, Reason: "no symbol"}) {
+		t.Errorf("got %+v, want 4 imported and row 4 skipped", result)
+	}
+	found, _ := symbols.Search(ctx, "", 0)
+	want := map[string]dto.SymbolResponse{
+		"BATBC":        {Symbol: "BATBC", Name: "British American Tobacco Bangladesh"},
+		"BRACBANK":     {Symbol: "BRACBANK"},
+		"GP":           {Symbol: "GP", Name: "Grameenphone Ltd.", Sector: "Telecommunication"},
+		"SQUAREPHARMA": {Symbol: "SQUAREPHARMA", Name: "Square Pharmaceuticals, PLC", Sector: "Pharmaceuticals"},
+	}
+	if len(found) != len(want) {
+		t.Fatalf("got %d symbols, want %d", len(found), len(want))
+	}
+	for _, symbol := range found {
+		w := want[symbol.Symbol]
+		if symbol.Name != w.Name || symbol.Sector != w.Sector || symbol.LastSeenAt != nil {
+			t.Errorf("got %+v, want %+v never seen", symbol, w)
+		}
+	}
+
+	// a later list without names keeps the stored ones
+	if _, err := symbols.Import(ctx, strings.NewReader("gp\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := symbols.Search(ctx, "GP", 1); got[0].Name != "Grameenphone Ltd." {
+		t.Errorf("got %+v after a re-import, want the name kept", got[0])
+	}
+
+	for _, tc := range []struct {
+		name string
+		csv  string
+	}{
+		{name: "unterminated quote", csv: "GP,\"Grameenphone\nBATBC,BAT\n"},
+		{name: "stray quote", csv: "GP,Grameen\"phone\n"},
+		{name: "too many rows", csv: strings.Repeat("GP\n", maxSymbolImportRows+1)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			empty := NewSymbolService(repository.NewMemorySymbolRepository(), nil)
+			if _, err := empty.Import(ctx, strings.NewReader(tc.csv)); !errors.Is(err, domain.ErrValidation) {
+				t.Errorf("got %v, want ErrValidation", err)
+			}
+			if got, _ := empty.Search(ctx, "", 0); len(got) != 0 {
+				t.Errorf("got %d symbols imported from a rejected list, want 0", len(got))
+			}
+		})
+	}
+}
+
+// Search matches the start of the symbol or of the name, whatever the case,
+// in symbol order
+func TestSymbolSearch(t *testing.T) {
+	symbols, _ := newTestSymbolService(t)
+	for _, tc := range []struct {
+		query string
+		limit int64
+		want  string
+	}{
+		{query: "gp", want: "GP"},
+		{query: " b ", want: "BATBC BRACBANK"},
+		{query: "b", limit: 1, want: "BATBC"},
+		{query: "grameen", want: "GP"},
+		{query: "square pharma", want: "SQUAREPHARMA"},
+		{query: "pharma", want: ""},
+		{query: "", want: "BATBC BRACBANK GP SQUAREPHARMA"},
+	} {
+		found, err := symbols.Search(context.Background(), tc.query, tc.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, symbol := range found {
+			got = append(got, symbol.Symbol)
+		}
+		if strings.Join(got, " ") != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.query, got, tc.want)
+		}
+	}
+	for _, limit := range []int64{-1, maxSymbolSearchLimit + 1} {
+		if _, err := symbols.Search(context.Background(), "G", limit); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("limit %d: got %v, want ErrValidation", limit, err)
+		}
+	}
+}
+
+// Unknown symbols are rejected with the closest known one by prefix as the
+// suggestion, unless the symbol_validation flag is off
+func TestValidateSymbol(t *testing.T) {
+	ctx := context.Background()
+	symbols, flags := newTestSymbolService(t)
+
+	for _, tc := range []struct {
+		symbol  string
+		wantErr string
+	}{
+		{symbol: "GP"},
+		{symbol: "BRACBANK"},
+		{symbol: "GRAMEENPHON", wantErr: "unknown symbol GRAMEENPHON, did you mean GP?"},
+		{symbol: "BRACBNK", wantErr: "unknown symbol BRACBNK, did you mean BRACBANK?"},
+		{symbol: "SQUARE", wantErr: "unknown symbol SQUARE, did you mean SQUAREPHARMA?"},
+		{symbol: "XYZ", wantErr: "unknown symbol XYZ: "},
+	} {
+		err := symbols.ValidateSymbol(ctx, tc.symbol)
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: got %v, want it accepted", tc.symbol, err)
+			}
+			continue
+		}
+		if !errors.Is(err, domain.ErrValidation) || !strings.HasPrefix(err.Error(), tc.wantErr) {
+			t.Errorf("%s: got %v, want %q", tc.symbol, err, tc.wantErr)
+		}
+	}
+
+	if _, err := flags.Set(ctx, FlagSymbolValidation.Name, dto.FeatureFlagRequest{Value: "off"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := symbols.ValidateSymbol(ctx, "XYZ"); err != nil {
+		t.Errorf("got %v with validation off, want any symbol accepted", err)
+	}
+}
+
+// Ticks add their symbols with the time they were seen; the last tick of a
+// batch naming the symbol describes it
+func TestSymbolRecordSeen(t *testing.T) {
+	ctx := context.Background()
+	symbols, _ := newTestSymbolService(t)
+	seenAt := time.Date(2024, 3, 4, 4, 0, 0, 0, time.UTC)
+
+	symbols.RecordSeen(ctx, []dto.PriceTickRequest{
+		{Symbol: "ACI", Name: "ACI Ltd", Price: money.FromFloat(250)},
+		{Symbol: "GP", Price: money.FromFloat(350)},
+		{Symbol: "ACI", Name: "ACI Limited", Sector: "Pharmaceuticals", Price: money.FromFloat(251)},
+	}, seenAt)
+
+	aci, _ := symbols.Search(ctx, "ACI", 1)
+	if len(aci) != 1 || aci[0].Name != "ACI Limited" || aci[0].Sector != "Pharmaceuticals" || aci[0].LastSeenAt == nil || !aci[0].LastSeenAt.Equal(seenAt) {
+		t.Errorf("got %+v, want ACI Limited seen at %s", aci, seenAt)
+	}
+	gp, _ := symbols.Search(ctx, "GP", 1)
+	if gp[0].Name != "Grameenphone Ltd." || gp[0].LastSeenAt == nil {
+		t.Errorf("got %+v, want the imported name kept and a last seen time", gp[0])
+	}
+	if err := symbols.ValidateSymbol(ctx, "ACI"); err != nil {
+		t.Errorf("got %v for a symbol seen on the feed, want it accepted", err)
+	}
+}
+
+// Alerts are created only for known symbols
+func TestCreateAlertValidatesSymbol(t *testing.T) {
+	ctx := context.Background()
+	symbols, _ := newTestSymbolService(t)
+	alerts := NewAlertService(repository.NewMemoryAlertRepository(), nil, nil, MarketSchedule{}, nil, nil, nil, symbols, nil, DefaultAlertDateBounds())
+	request := func(symbol string) dto.AlertCreateRequest {
+		return dto.AlertCreateRequest{UserID: "alice", Symbol: symbol, Rule: dto.AlertRuleAbove, Price: money.FromFloat(100), Status: dto.AlertStatusActive}
+	}
+
+	if _, err := alerts.CreateAlert(ctx, request("gp")); err != nil {
+		t.Errorf("got %v for gp, want it created", err)
+	}
+	_, err := alerts.CreateAlert(ctx, request("GRAMEENPHON"))
+	if !errors.Is(err, domain.ErrValidation) || !strings.Contains(err.Error(), "did you mean GP?") {
+		t.Errorf("got %v, want an unknown symbol error suggesting GP", err)
+	}
+}
@@ -0,0 +1,426 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/hello-api/internal/common/timeutil"
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/httpclient"
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/metrics"
+	"github.com/hello-api/pkg/money"
+)
+
+// DefaultTelegramTemplate renders a trigger. Every field is already escaped
+// for MarkdownV2, so literal text added to a template must be escaped too.
+const DefaultTelegramTemplate = `🔔 *{{.Name}}*
+{{.Symbol}} is {{.Condition}}
+Price: {{.Price}}{{with .Freshness}} \({{.}}\){{end}}
+{{.Time}}`
+
+// TelegramConfig configures the Telegram bot. The bot is disabled unless
+// TELEGRAM_BOT_TOKEN is set.
+type TelegramConfig struct {
+	BotToken string
+	// BotUsername builds t.me deep links for link codes
+	BotUsername string
+	// WebhookSecret must arrive in X-Telegram-Bot-Api-Secret-Token on every
+	// webhook update; set the same value with setWebhook
+	WebhookSecret string
+	APIURL        string
+	Template      *template.Template
+	// LinkCodeTTL is how long a link code can be sent to the bot
+	LinkCodeTTL time.Duration
+}
+
+// Enabled reports whether a bot token is configured
+func (c TelegramConfig) Enabled() bool {
+	return c.BotToken != ""
+}
+
+// LoadTelegramConfig reads TELEGRAM_BOT_TOKEN, TELEGRAM_BOT_USERNAME,
+// TELEGRAM_WEBHOOK_SECRET, TELEGRAM_API_URL and TELEGRAM_MESSAGE_TEMPLATE
+func LoadTelegramConfig() (TelegramConfig, error) {
+	cfg := TelegramConfig{
+		BotToken:      os.Getenv("TELEGRAM_BOT_TOKEN"),
+		BotUsername:   strings.TrimPrefix(os.Getenv("TELEGRAM_BOT_USERNAME"), "@"),
+		WebhookSecret: os.Getenv("TELEGRAM_WEBHOOK_SECRET"),
+		APIURL:        strings.TrimSuffix(os.Getenv("TELEGRAM_API_URL"), "/"),
+		LinkCodeTTL:   10 * time.Minute,
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = "https://api.telegram.org"
+	}
+	text := DefaultTelegramTemplate
+	if raw := os.Getenv("TELEGRAM_MESSAGE_TEMPLATE"); raw != "" {
+		text = raw
+	}
+	tmpl, err := template.New("telegram").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return cfg, fmt.Errorf("TELEGRAM_MESSAGE_TEMPLATE: %w", err)
+	}
+	cfg.Template = tmpl
+	return cfg, nil
+}
+
+// RetryAfterError asks the notification worker to retry no sooner than After,
+// e.g. when the destination rate limits
+type RetryAfterError struct {
+	After time.Duration
+	Err   error
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", e.Err, e.After)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// TelegramBot sends messages through the Telegram Bot API. As a
+// NotificationSender it renders queued triggers; the destination is a chat id.
+type TelegramBot struct {
+	cfg      TelegramConfig
+	client   *httpclient.Client
+	location *time.Location
+
+	// pausedUntil holds back sends after a 429 for the retry_after the API gave
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+func NewTelegramBot(cfg TelegramConfig, location *time.Location, client *httpclient.Client) *TelegramBot {
+	metrics.Default.Describe("telegram_rate_limited_total", "Telegram Bot API requests refused with 429")
+	if location == nil {
+		location = time.UTC
+	}
+	return &TelegramBot{cfg: cfg, client: client, location: location}
+}
+
+// Send renders an alert trigger or hourly cap summary payload and messages it to the chat
+func (b *TelegramBot) Send(ctx context.Context, destination string, payload []byte) error {
+	chatID, err := strconv.ParseInt(destination, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid telegram chat id %q: %w", destination, ErrUndeliverable)
+	}
+	var head struct {
+		Event string `json:"event"`
+	}
+	if err := json.Unmarshal(payload, &head); err != nil {
+		return fmt.Errorf("invalid trigger payload: %v: %w", err, ErrUndeliverable)
+	}
+	if head.Event == EventAlertsThrottled {
+		var summary alertsThrottledEvent
+		if err := json.Unmarshal(payload, &summary); err != nil {
+			return fmt.Errorf("invalid summary payload: %v: %w", err, ErrUndeliverable)
+		}
+		location := timeutil.Display(summary.DisplayTimezone, b.location)
+		return b.SendMessage(ctx, chatID, EscapeTelegramMarkdown(describeThrottled(summary, location)))
+	}
+	var event alertTriggeredEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("invalid trigger payload: %v: %w", err, ErrUndeliverable)
+	}
+	text, err := b.render(event)
+	if err != nil {
+		return fmt.Errorf("rendering telegram message: %v: %w", err, ErrUndeliverable)
+	}
+	return b.SendMessage(ctx, chatID, text)
+}
+
+// telegramView is the data of the message template, escaped for MarkdownV2
+type telegramView struct {
+	Name      string
+	Symbol    string
+	Rule      string
+	Condition string
+	Threshold string
+	Price     string
+	// Freshness says how stale the price was, e.g. "as of 14:32:05, 47s
+	// delayed"; empty when unknown
+	Freshness string
+	Reason    string
+	Time      string
+}
+
+func (b *TelegramBot) render(event alertTriggeredEvent) (string, error) {
+	name := event.Name
+	if name == "" {
+		name = "Alert"
+	}
+	symbol := event.Symbol
+	if symbol == "" {
+		symbol = "Price"
+	}
+	threshold := event.Threshold.String()
+	condition := describeCondition(event.Rule, event.Threshold)
+	location := timeutil.Display(event.DisplayTimezone, b.location)
+	view := telegramView{
+		Name:      EscapeTelegramMarkdown(name),
+		Symbol:    EscapeTelegramMarkdown(symbol),
+		Rule:      EscapeTelegramMarkdown(string(event.Rule)),
+		Condition: EscapeTelegramMarkdown(condition),
+		Threshold: EscapeTelegramMarkdown(threshold),
+		Price:     EscapeTelegramMarkdown(event.Price.String()),
+		Freshness: EscapeTelegramMarkdown(describeFreshness(event.Lag, location)),
+		Reason:    EscapeTelegramMarkdown(event.Reason),
+		Time:      EscapeTelegramMarkdown(event.TriggeredAt.In(location).Format("02 Jan 2006 15:04 MST")),
+	}
+	var text bytes.Buffer
+	if err := b.cfg.Template.Execute(&text, view); err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
+
+// describeThrottled words an hourly cap summary, e.g. "...and 12 more alerts
+// fired between 09:00 and 10:00 +06"
+func describeThrottled(summary alertsThrottledEvent, location *time.Location) string {
+	alerts := "alerts"
+	if summary.Suppressed == 1 {
+		alerts = "alert"
+	}
+	return fmt.Sprintf("...and %d more %s fired between %s and %s", summary.Suppressed, alerts,
+		summary.WindowStart.In(location).Format("15:04"), summary.WindowEnd.In(location).Format("15:04 MST"))
+}
+
+// describeFreshness words how stale a triggered price was, e.g. "as of
+// 14:32:05, 47s delayed". Without an exchange time only ingestion was timed,
+// so the delay is a lower bound: "at least 47s delayed". It is empty when the
+// lag is unknown.
+func describeFreshness(lag *dto.TickLag, location *time.Location) string {
+	if lag == nil {
+		return ""
+	}
+	asOf := "as of " + lag.AsOf.In(location).Format("15:04:05")
+	delay := lag.Total().Round(time.Second)
+	switch {
+	case delay <= 0:
+		return asOf
+	case lag.Partial():
+		return fmt.Sprintf("%s, at least %s delayed", asOf, delay)
+	}
+	return fmt.Sprintf("%s, %s delayed", asOf, delay)
+}
+
+// describeCondition words a rule for people, e.g. "above 120.5" or "up 3% or more"
+func describeCondition(rule dto.AlertRule, threshold money.Amount) string {
+	value := threshold.String()
+	switch rule {
+	case dto.AlertRuleAbove:
+		return "above " + value
+	case dto.AlertRuleBelow:
+		return "below " + value
+	case dto.AlertRulePercentChangeAbove:
+		return "up " + value + "% or more"
+	case dto.AlertRulePercentChangeBelow:
+		return "down " + value + "% or more"
+	}
+	return string(rule) + " " + value
+}
+
+// telegramMarkdownEscaper escapes the characters MarkdownV2 reserves
+var telegramMarkdownEscaper = func() *strings.Replacer {
+	var pairs []string
+	for _, c := range `\_*[]()~` + "`" + `>#+-=|{}.!` {
+		pairs = append(pairs, string(c), `\`+string(c))
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// EscapeTelegramMarkdown makes user-supplied text safe to embed in a
+// MarkdownV2 message
+func EscapeTelegramMarkdown(text string) string {
+	return telegramMarkdownEscaper.Replace(text)
+}
+
+// telegramResponse is the envelope of every Bot API response
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// SendMessage sends MarkdownV2 text to a chat. A 429 returns a RetryAfterError
+// and holds back every send until retry_after has passed; a chat the bot can
+// no longer reach returns ErrUndeliverable.
+func (b *TelegramBot) SendMessage(ctx context.Context, chatID int64, text string) error {
+	b.mu.Lock()
+	paused := time.Until(b.pausedUntil)
+	b.mu.Unlock()
+	if paused > 0 {
+		return &RetryAfterError{After: paused, Err: fmt.Errorf("telegram rate limit")}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id":    chatID,
+		"text":       text,
+		"parse_mode": "MarkdownV2",
+	})
+	if err != nil {
+		return err
+	}
+	url := b.cfg.APIURL + "/bot" + b.cfg.BotToken + "/sendMessage"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// The error text includes the URL, which carries the token
+		return fmt.Errorf("telegram request failed: %v", redactToken(err.Error(), b.cfg.BotToken))
+	}
+	defer resp.Body.Close()
+	var result telegramResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err != nil {
+		return fmt.Errorf("telegram responded %s", resp.Status)
+	}
+	if result.OK {
+		return nil
+	}
+
+	apiErr := fmt.Errorf("telegram responded %d: %s", result.ErrorCode, result.Description)
+	switch {
+	case result.ErrorCode == http.StatusTooManyRequests:
+		after := time.Duration(result.Parameters.RetryAfter) * time.Second
+		if after <= 0 {
+			after = time.Second
+		}
+		b.mu.Lock()
+		if until := time.Now().Add(after); until.After(b.pausedUntil) {
+			b.pausedUntil = until
+		}
+		b.mu.Unlock()
+		metrics.Default.Counter("telegram_rate_limited_total", nil).Inc()
+		return &RetryAfterError{After: after, Err: apiErr}
+	case result.ErrorCode == http.StatusForbidden, result.ErrorCode == http.StatusBadRequest:
+		// Blocked by the user, chat deleted, or a message Telegram rejects;
+		// retrying cannot help
+		return fmt.Errorf("%v: %w", apiErr, ErrUndeliverable)
+	}
+	return apiErr
+}
+
+func redactToken(text, token string) string {
+	if token == "" {
+		return text
+	}
+	return strings.ReplaceAll(text, token, "<token>")
+}
+
+// TelegramService links Telegram chats to users with one-time codes. A code is
+// issued to an authenticated user and linked when its chat sends it to the bot,
+// so a chat id is only ever associated by the person holding both.
+type TelegramService struct {
+	links domain.TelegramLinkRepository
+	users domain.UserRepository
+	bot   *TelegramBot
+	cfg   TelegramConfig
+	now   func() time.Time
+}
+
+func NewTelegramService(links domain.TelegramLinkRepository, users domain.UserRepository, bot *TelegramBot, cfg TelegramConfig) *TelegramService {
+	return &TelegramService{links: links, users: users, bot: bot, cfg: cfg, now: time.Now}
+}
+
+// CreateLink issues a link code for the user
+func (s *TelegramService) CreateLink(ctx context.Context, userID string) (*dto.TelegramLinkResponse, error) {
+	user, err := s.users.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	code := hex.EncodeToString(raw)
+	expiresAt := s.now().Add(s.cfg.LinkCodeTTL).UTC()
+	if err := s.links.Create(ctx, code, userID, expiresAt); err != nil {
+		return nil, err
+	}
+	link := &dto.TelegramLinkResponse{Code: code, ExpiresAt: expiresAt}
+	if s.cfg.BotUsername != "" {
+		link.DeepLink = "https://t.me/" + s.cfg.BotUsername + "?start=" + code
+	}
+	logging.FromContext(ctx).Info("telegram link code issued", "user_id", userID, "expires_at", expiresAt)
+	return link, nil
+}
+
+// Unlink forgets the user's Telegram chat
+func (s *TelegramService) Unlink(ctx context.Context, userID string) error {
+	if err := s.users.SetTelegramChat(ctx, userID, 0); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("telegram chat unlinked", "user_id", userID)
+	return nil
+}
+
+// HandleUpdate links the chat when a private message carries a link code,
+// as "/start <code>" from a deep link or "/link <code>" typed by hand
+func (s *TelegramService) HandleUpdate(ctx context.Context, update dto.TelegramUpdate) error {
+	msg := update.Message
+	if msg == nil || msg.Chat.Type != "private" {
+		return nil
+	}
+	fields := strings.Fields(msg.Text)
+	if len(fields) == 0 {
+		return nil
+	}
+	command := strings.SplitN(fields[0], "@", 2)[0]
+	if command != "/start" && command != "/link" {
+		return nil
+	}
+	if len(fields) < 2 {
+		s.reply(ctx, msg.Chat.ID, "Send the link code from the app as: /link <code>")
+		return nil
+	}
+
+	userID, err := s.links.Consume(ctx, fields[1], s.now())
+	if err != nil {
+		return err
+	}
+	if userID == "" {
+		s.reply(ctx, msg.Chat.ID, "This link code is invalid or has expired. Request a new one in the app.")
+		return nil
+	}
+	if err := s.users.SetTelegramChat(ctx, userID, msg.Chat.ID); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("telegram chat linked", "user_id", userID)
+	s.reply(ctx, msg.Chat.ID, "✅ Linked. Alerts with Telegram notifications on will be sent to this chat.")
+	return nil
+}
+
+// reply answers a chat with plain text; failures only matter to the chat, so they are logged
+func (s *TelegramService) reply(ctx context.Context, chatID int64, text string) {
+	if s.bot == nil {
+		return
+	}
+	if err := s.bot.SendMessage(ctx, chatID, EscapeTelegramMarkdown(text)); err != nil {
+		slog.Warn("Failed to reply on Telegram", "error", err)
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/money"
+)
+
+// The freshness of a price is given in the display location, rounded to the
+// second, as a lower bound when only ingestion was timed
+func TestDescribeFreshness(t *testing.T) {
+	asOf := time.Date(2024, 3, 4, 8, 32, 5, 0, time.UTC)
+	dhaka := time.FixedZone("+06", 6*60*60)
+	feedMs := int64(45000)
+
+	for _, tc := range []struct {
+		name     string
+		lag      *dto.TickLag
+		location *time.Location
+		want     string
+	}{
+		{name: "unknown", lag: nil, location: time.UTC, want: ""},
+		{name: "full", lag: &dto.TickLag{AsOf: asOf, FeedMs: &feedMs, IngestMs: 2000, TotalMs: 47000}, location: time.UTC, want: "as of 08:32:05, 47s delayed"},
+		{name: "display location", lag: &dto.TickLag{AsOf: asOf, FeedMs: &feedMs, IngestMs: 2000, TotalMs: 47000}, location: dhaka, want: "as of 14:32:05, 47s delayed"},
+		{name: "ingest only", lag: &dto.TickLag{AsOf: asOf, IngestMs: 46600, TotalMs: 46600}, location: time.UTC, want: "as of 08:32:05, at least 47s delayed"},
+		{name: "no delay", lag: &dto.TickLag{AsOf: asOf, IngestMs: 300, TotalMs: 300}, location: time.UTC, want: "as of 08:32:05"},
+	} {
+		if got := describeFreshness(tc.lag, tc.location); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// The default template adds the escaped freshness after the price, and
+// leaves the price line alone when the lag is unknown
+func TestTelegramRenderFreshness(t *testing.T) {
+	t.Setenv("TELEGRAM_MESSAGE_TEMPLATE", "")
+	cfg, err := LoadTelegramConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot := NewTelegramBot(cfg, time.FixedZone("+06", 6*60*60), nil)
+	event := alertTriggeredEvent{
+		Event:       EventAlertTriggered,
+		Name:        "GP breakout",
+		Symbol:      "GP",
+		Rule:        dto.AlertRuleAbove,
+		Threshold:   money.FromFloat(350),
+		Price:       money.FromFloat(351.25),
+		TriggeredAt: time.Date(2024, 3, 4, 8, 32, 52, 0, time.UTC),
+	}
+
+	text, err := bot.render(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "Price: 351\\.25\n") {
+		t.Errorf("got %q without a lag, want the bare price", text)
+	}
+
+	event.Lag = &dto.TickLag{AsOf: time.Date(2024, 3, 4, 8, 32, 5, 0, time.UTC), IngestMs: 47000, TotalMs: 47000}
+	if text, err = bot.render(event); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Price: 351\\.25 \\(as of 14:32:05, at least 47s delayed\\)\n"; !strings.Contains(text, want) {
+		t.Errorf("got %q, want it to contain %q", text, want)
+	}
+}
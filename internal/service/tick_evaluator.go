@@ -0,0 +1,414 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/metrics"
+	"github.com/hello-api/pkg/money"
+)
+
+// ShadowRules are the rules whose alerts all run in shadow mode: they fire, but
+// their triggers are only recorded for admins
+type ShadowRules map[dto.AlertRule]bool
+
+// parseShadowRules reads comma-separated rules such as
+// "percent_change_above,percent_change_below"; empty means none
+func parseShadowRules(raw string) (ShadowRules, error) {
+	rules := make(ShadowRules)
+	for _, part := range strings.Split(raw, ",") {
+		rule := dto.AlertRule(strings.TrimSpace(part))
+		switch rule {
+		case "":
+		case dto.AlertRuleAbove, dto.AlertRuleBelow, dto.AlertRulePercentChangeAbove, dto.AlertRulePercentChangeBelow:
+			rules[rule] = true
+		default:
+			return nil, fmt.Errorf("unknown rule %q", rule)
+		}
+	}
+	return rules, nil
+}
+
+// String returns the rules comma-separated in name order, as parseShadowRules reads them
+func (r ShadowRules) String() string {
+	names := make([]string, 0, len(r))
+	for rule := range r {
+		names = append(names, string(rule))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// TickEvaluator fires the alerts watching a symbol when an ingested tick meets
+// them. Alerts come from the AlertCache, so a tick that changes no alert's state
+// queries nothing. An alert fires when its condition becomes true and re-arms
+// once a tick no longer meets it.
+//
+// Ticks of one symbol are evaluated one at a time. The alert's triggered flag in
+// the repository is the source of truth: only the caller that moves it from
+// armed to triggered sends the notification, so concurrent ingests, in this
+// process or in another replica, fire an alert once. For the same reason a
+// restart loses nothing: states is only a cache of the flag, and an alert that
+// fired before the restart stays triggered until a tick no longer meets it.
+type TickEvaluator struct {
+	alerts        *AlertCache
+	repo          domain.AlertRepository
+	notifications domain.NotificationService
+	// sampler records decisions of sampled evaluations and shadow fires; it may be nil
+	sampler *EvaluationSampler
+	// flags give the rules whose alerts run in shadow mode
+	flags *FeatureFlags
+
+	mu sync.Mutex
+	// Serializes evaluation per symbol
+	symbolLocks map[string]*symbolLock
+	// Last known triggered state per alert, so only transitions reach the repository
+	states map[string]alertState
+	// Latest price and trading date per symbol as of the last evaluated tick,
+	// the baseline of percent rules in MatchingAlerts
+	days map[string]symbolDay
+	// prunedGeneration is the alert index reload the maps above were last pruned for
+	prunedGeneration int64
+}
+
+// symbolLock serializes evaluation of one symbol; users counts the evaluations
+// holding or waiting for it, so only an idle lock is pruned
+type symbolLock struct {
+	sync.Mutex
+	users int
+}
+
+// symbolDay is the latest price of a symbol and the trading date it was evaluated on
+type symbolDay struct {
+	latest      dto.LatestPriceResponse
+	tradingDate string
+}
+
+// alertState is the triggered state of an alert as of one of its versions
+type alertState struct {
+	triggered bool
+	// updatedAt identifies the alert version; an edit re-arms the alert
+	updatedAt time.Time
+}
+
+func NewTickEvaluator(alerts *AlertCache, repo domain.AlertRepository, notifications domain.NotificationService, sampler *EvaluationSampler, flags *FeatureFlags) *TickEvaluator {
+	metrics.Default.Describe("alerts_fired_total", "Alerts fired by ingested price ticks")
+	metrics.Default.Describe("alerts_shadow_fired_total", "Alerts in shadow mode fired by ingested price ticks, not notified")
+	metrics.Default.Describe("alert_fire_conflicts_total", "Alerts met by a tick that another evaluation had already fired")
+	metrics.Default.Describe("alerts_backfill_fired_total", "Alerts fired in shadow mode by backfilled price ticks, not notified")
+	return &TickEvaluator{
+		alerts:        alerts,
+		repo:          repo,
+		notifications: notifications,
+		sampler:       sampler,
+		flags:         flags,
+		symbolLocks:   make(map[string]*symbolLock),
+		states:        make(map[string]alertState),
+		days:          make(map[string]symbolDay),
+	}
+}
+
+// Evaluate checks an accepted tick against the alerts watching its symbol and
+// returns how many fired. latest is the symbol's latest price after the tick;
+// a tick older than it is out of order and not evaluated.
+func (e *TickEvaluator) Evaluate(ctx context.Context, tick dto.PriceTickRequest, latest *dto.LatestPriceResponse, tradingDate string) int {
+	if latest == nil || tick.Time.Before(latest.Time) {
+		return 0
+	}
+	e.pruneIfReloaded()
+	alerts := e.alerts.ForSymbol(tick.Symbol)
+	if len(alerts) == 0 {
+		return 0
+	}
+
+	unlock := e.lockSymbol(tick.Symbol)
+	defer unlock()
+	e.setDay(tick.Symbol, *latest, tradingDate)
+
+	fired := 0
+	for _, alert := range alerts {
+		triggered := e.triggered(alert)
+		decision, gate := DecideTick(alert, triggered, tick.Price, tick.Time, latest, tradingDate)
+		outcome := dto.EvaluationUnchanged
+		var err error
+		switch decision {
+		case TickRearms:
+			outcome, err = e.rearm(ctx, alert)
+		case TickFires:
+			outcome, err = e.fire(ctx, alert, tick, gate.Reason)
+			if outcome == dto.EvaluationFired {
+				fired++
+			}
+		}
+		// Shadow fires are always recorded, they are all there is to see of them
+		shadowFired := outcome == dto.EvaluationFired && e.shadowed(alert)
+		if e.sampler.On() || (shadowFired && e.sampler != nil) {
+			e.sample(alert, tick, triggered, gate, outcome, err, shadowFired)
+		}
+	}
+	return fired
+}
+
+// EvaluateBackfill checks backfilled ticks of one symbol and trading date, in
+// time order, against the alerts watching the symbol as if every alert ran in
+// shadow mode: a fire is recorded for admins, no one is notified and no
+// alert's state changes, since the crossing is stale. Each alert starts from
+// its current triggered state. latest is the symbol's day recomputed with the
+// ticks, the baseline of percent rules; nil leaves them without one. It
+// returns how many alerts fired.
+func (e *TickEvaluator) EvaluateBackfill(ctx context.Context, ticks []dto.PriceTickRequest, latest *dto.LatestPriceResponse, tradingDate string) int {
+	if len(ticks) == 0 {
+		return 0
+	}
+	fired := 0
+	for _, alert := range e.alerts.ForSymbol(ticks[0].Symbol) {
+		triggered := e.triggered(alert)
+		for _, tick := range ticks {
+			decision, gate := DecideTick(alert, triggered, tick.Price, tick.Time, latest, tradingDate)
+			switch decision {
+			case TickRearms:
+				triggered = false
+				continue
+			case TickUnchanged:
+				continue
+			}
+			trigger := dto.AlertTriggerRequest{Price: tick.Price, Reason: gate.Reason, TriggeredAt: tick.Time}
+			outcome := dto.EvaluationFired
+			err := e.notifications.RecordShadowTrigger(ctx, alert, trigger)
+			if err != nil {
+				// Like a live trigger that fails, the alert stays armed
+				outcome = dto.EvaluationFireFailed
+			} else {
+				triggered = true
+				fired++
+				metrics.Default.Counter("alerts_backfill_fired_total", metrics.Labels{"rule": string(alert.Rule)}).Inc()
+				logging.FromContext(ctx).Info("alert fired by a backfilled tick, not notified",
+					"alert_id", alert.ID, "symbol", tick.Symbol, "price", tick.Price, "tick_time", tick.Time, "reason", gate.Reason)
+			}
+			// Only an armed alert fires, so it was not triggered before the tick
+			if e.sampler != nil {
+				e.sample(alert, tick, false, gate, outcome, err, outcome == dto.EvaluationFired)
+			}
+		}
+	}
+	return fired
+}
+
+func (e *TickEvaluator) rearm(ctx context.Context, alert dto.AlertResponse) (dto.EvaluationOutcome, error) {
+	if _, err := e.repo.Rearm(ctx, alert.ID); err != nil {
+		logging.FromContext(ctx).Warn("failed to re-arm alert", "alert_id", alert.ID, "error", err)
+		return dto.EvaluationRearmFailed, err
+	}
+	e.setTriggered(alert, false)
+	return dto.EvaluationRearmed, nil
+}
+
+// shadowed reports whether the alert runs in shadow mode, by itself or through its rule
+func (e *TickEvaluator) shadowed(alert dto.AlertResponse) bool {
+	return alert.Shadow || FlagShadowRules.Get(e.flags)[alert.Rule]
+}
+
+// fire claims the alert's transition to triggered and, if this call won it,
+// records the trigger. The trigger of an alert in shadow mode goes through the
+// same checks but notifies no one.
+func (e *TickEvaluator) fire(ctx context.Context, alert dto.AlertResponse, tick dto.PriceTickRequest, reason string) (dto.EvaluationOutcome, error) {
+	claimed, err := e.repo.MarkTriggered(ctx, alert.ID, tick.Time)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to mark alert triggered", "alert_id", alert.ID, "error", err)
+		return dto.EvaluationFireFailed, err
+	}
+	e.setTriggered(alert, true)
+	if !claimed {
+		metrics.Default.Counter("alert_fire_conflicts_total", nil).Inc()
+		return dto.EvaluationFireConflict, nil
+	}
+
+	trigger := dto.AlertTriggerRequest{
+		Price:       tick.Price,
+		Reason:      reason,
+		TriggeredAt: tick.Time,
+		Lag:         tick.Lag,
+	}
+	shadow := e.shadowed(alert)
+	if shadow {
+		err = e.notifications.RecordShadowTrigger(ctx, alert, trigger)
+	} else {
+		_, err = e.notifications.RecordTrigger(ctx, alert.ID, trigger)
+	}
+	if err != nil {
+		// Re-arm, so the alert fires on the next tick that meets it, e.g. once
+		// the market opens
+		if _, rearmErr := e.repo.Rearm(ctx, alert.ID); rearmErr == nil {
+			e.setTriggered(alert, false)
+		}
+		if !errors.Is(err, domain.ErrOutsideMarketHours) {
+			logging.FromContext(ctx).Warn("failed to fire alert", "alert_id", alert.ID, "error", err)
+		}
+		return dto.EvaluationFireFailed, err
+	}
+	if shadow {
+		metrics.Default.Counter("alerts_shadow_fired_total", metrics.Labels{"rule": string(alert.Rule)}).Inc()
+		logging.FromContext(ctx).Info("shadow alert fired, not notified",
+			"alert_id", alert.ID, "symbol", tick.Symbol, "price", tick.Price, "reason", reason)
+		return dto.EvaluationFired, nil
+	}
+	metrics.Default.Counter("alerts_fired_total", nil).Inc()
+	logging.FromContext(ctx).Info("alert fired",
+		"alert_id", alert.ID, "symbol", tick.Symbol, "price", tick.Price, "reason", reason)
+	return dto.EvaluationFired, nil
+}
+
+// sample records the decision for an alert if its evaluation is sampled or
+// is a shadow fire. triggered is the alert's state before the tick.
+func (e *TickEvaluator) sample(alert dto.AlertResponse, tick dto.PriceTickRequest, triggered bool, threshold dto.EvaluationGate, outcome dto.EvaluationOutcome, err error, shadowFired bool) {
+	now := time.Now().UTC()
+	sampledBy := e.sampler.Sampled(alert.ID, now)
+	if sampledBy == "" {
+		if !shadowFired {
+			return
+		}
+		sampledBy = SampledByShadow
+	}
+	sample := dto.EvaluationSampleRequest{
+		AlertID:      alert.ID,
+		Symbol:       tick.Symbol,
+		Price:        tick.Price,
+		TickTime:     tick.Time,
+		WasTriggered: triggered,
+		Gates:        []dto.EvaluationGate{windowGate(alert, tick.Time), threshold},
+		Outcome:      outcome,
+		SampledBy:    sampledBy,
+		Shadow:       shadowFired,
+		EvaluatedAt:  now,
+	}
+	if err != nil {
+		sample.Detail = err.Error()
+	}
+	e.sampler.Record(sample)
+}
+
+// MatchingAlerts returns the active alerts watching symbol that price would
+// fire now, whether or not they have fired already. Nothing is fired or
+// stored. Percent rules take their baseline from the symbol's last evaluated
+// tick and match nothing until one has been ingested.
+func (e *TickEvaluator) MatchingAlerts(symbol string, price money.Amount) []dto.AlertResponse {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	e.mu.Lock()
+	day, ok := e.days[symbol]
+	e.mu.Unlock()
+	var latest *dto.LatestPriceResponse
+	if ok {
+		latest = &day.latest
+	}
+
+	now := time.Now().UTC()
+	matching := []dto.AlertResponse{}
+	for _, alert := range e.alerts.ForSymbol(symbol) {
+		if decision, _ := DecideTick(alert, false, price, now, latest, day.tradingDate); decision == TickFires {
+			matching = append(matching, alert)
+		}
+	}
+	return matching
+}
+
+// AlertsForSymbol returns the alerts the evaluator watches for symbol, as
+// held in its in-memory index, with the triggered state it last recorded for
+// each. The symbol is normalized as ticks are, so an alert stored under a name
+// the feed never sends shows up missing here.
+func (e *TickEvaluator) AlertsForSymbol(symbol string) []dto.AlertResponse {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	indexed := e.alerts.ForSymbol(symbol)
+	alerts := make([]dto.AlertResponse, len(indexed))
+	for i, alert := range indexed {
+		alert.Triggered = e.triggered(alert)
+		alerts[i] = alert
+	}
+	return alerts
+}
+
+// lockSymbol serializes evaluation of one symbol and returns the unlock function
+func (e *TickEvaluator) lockSymbol(symbol string) func() {
+	e.mu.Lock()
+	lock, ok := e.symbolLocks[symbol]
+	if !ok {
+		lock = &symbolLock{}
+		e.symbolLocks[symbol] = lock
+	}
+	lock.users++
+	e.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		e.mu.Lock()
+		lock.users--
+		e.mu.Unlock()
+	}
+}
+
+// pruneIfReloaded drops, once per reload of the alert index, the states of
+// alerts it no longer holds and the idle locks and days of symbols no alert
+// watches any more, so none of them grows with every alert ever evaluated.
+// A dropped alert that comes back starts again from its stored triggered flag.
+func (e *TickEvaluator) pruneIfReloaded() {
+	index, status := e.alerts.snapshot()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if status.Generation == e.prunedGeneration {
+		return
+	}
+	e.prunedGeneration = status.Generation
+
+	indexed := make(map[string]bool)
+	for _, alerts := range index {
+		for _, alert := range alerts {
+			indexed[alert.ID] = true
+		}
+	}
+	for id := range e.states {
+		if !indexed[id] {
+			delete(e.states, id)
+		}
+	}
+	for symbol, lock := range e.symbolLocks {
+		if lock.users == 0 && len(index[symbol]) == 0 {
+			delete(e.symbolLocks, symbol)
+		}
+	}
+	for symbol := range e.days {
+		if len(index[symbol]) == 0 {
+			delete(e.days, symbol)
+		}
+	}
+}
+
+// triggered returns the last known triggered state of an alert, falling back
+// to the cached alert when this version has not been evaluated yet
+func (e *TickEvaluator) triggered(alert dto.AlertResponse) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if state, ok := e.states[alert.ID]; ok && state.updatedAt.Equal(alert.UpdatedAt) {
+		return state.triggered
+	}
+	return alert.Triggered
+}
+
+func (e *TickEvaluator) setDay(symbol string, latest dto.LatestPriceResponse, tradingDate string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.days[symbol] = symbolDay{latest: latest, tradingDate: tradingDate}
+}
+
+func (e *TickEvaluator) setTriggered(alert dto.AlertResponse, triggered bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.states[alert.ID] = alertState{triggered: triggered, updatedAt: alert.UpdatedAt}
+}
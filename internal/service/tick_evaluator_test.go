@@ -0,0 +1,480 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+	"github.com/hello-api/pkg/money"
+)
+
+// countingNotifications is a NotificationService that counts the triggers it
+// records, shadow triggers apart
+type countingNotifications struct {
+	domain.NotificationService
+
+	mu       sync.Mutex
+	triggers map[string]int
+	shadows  map[string]int
+}
+
+func (n *countingNotifications) RecordTrigger(ctx context.Context, alertID string, trigger dto.AlertTriggerRequest) (*dto.NotificationResponse, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.triggers == nil {
+		n.triggers = make(map[string]int)
+	}
+	n.triggers[alertID]++
+	return &dto.NotificationResponse{AlertID: alertID}, nil
+}
+
+func (n *countingNotifications) recorded(alertID string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.triggers[alertID]
+}
+
+func (n *countingNotifications) RecordShadowTrigger(ctx context.Context, alert dto.AlertResponse, trigger dto.AlertTriggerRequest) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.shadows == nil {
+		n.shadows = make(map[string]int)
+	}
+	n.shadows[alert.ID]++
+	return nil
+}
+
+func (n *countingNotifications) recordedShadow(alertID string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.shadows[alertID]
+}
+
+// newTestTickEvaluator returns an evaluator over the active alerts of alerts,
+// with the index loaded
+func newTestTickEvaluator(t *testing.T, alerts domain.AlertRepository, notifications domain.NotificationService) *TickEvaluator {
+	t.Helper()
+	cache := NewAlertCache(alerts, time.Minute)
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return NewTickEvaluator(cache, alerts, notifications, nil, nil)
+}
+
+func crossingTick(symbol string, price float64, at time.Time) (dto.PriceTickRequest, *dto.LatestPriceResponse) {
+	tick := dto.PriceTickRequest{Symbol: symbol, Price: money.FromFloat(price), Time: at}
+	return tick, &dto.LatestPriceResponse{Symbol: symbol, Price: tick.Price, Time: at, TradingDate: "2024-03-04"}
+}
+
+// The same crossing tick ingested from 10 goroutines, split across two
+// evaluators sharing the repository as two replicas would, fires the alert once
+func TestTickEvaluatorConcurrentCrossing(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	alert, _ := alerts.Create(ctx, alertRequest("GP", dto.AlertStatusActive))
+	notifications := &countingNotifications{}
+	replicas := []*TickEvaluator{
+		newTestTickEvaluator(t, alerts, notifications),
+		newTestTickEvaluator(t, alerts, notifications),
+	}
+	tick, latest := crossingTick("GP", 101, time.Now().UTC())
+
+	var wg sync.WaitGroup
+	fired := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(evaluator *TickEvaluator) {
+			defer wg.Done()
+			fired <- evaluator.Evaluate(ctx, tick, latest, latest.TradingDate)
+		}(replicas[i%len(replicas)])
+	}
+	wg.Wait()
+	close(fired)
+
+	total := 0
+	for n := range fired {
+		total += n
+	}
+	if total != 1 {
+		t.Errorf("the alert fired %d times, want once", total)
+	}
+	if got := notifications.recorded(alert.ID); got != 1 {
+		t.Errorf("recorded %d notifications, want 1", got)
+	}
+	if stored, _ := alerts.FindByID(ctx, alert.ID); !stored.Triggered {
+		t.Error("the alert is not triggered in the repository")
+	}
+}
+
+// A reload of the alert index drops the state and the idle lock of an alert
+// that is no longer active
+func TestTickEvaluatorPrunesOnReload(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	gp, _ := alerts.Create(ctx, alertRequest("GP", dto.AlertStatusActive))
+	alerts.Create(ctx, alertRequest("BATBC", dto.AlertStatusActive))
+	evaluator := newTestTickEvaluator(t, alerts, &countingNotifications{})
+
+	now := time.Now().UTC()
+	tick, latest := crossingTick("GP", 101, now)
+	if fired := evaluator.Evaluate(ctx, tick, latest, latest.TradingDate); fired != 1 {
+		t.Fatalf("fired %d alerts, want 1", fired)
+	}
+	if _, ok := evaluator.states[gp.ID]; !ok {
+		t.Fatal("the fired alert has no state")
+	}
+
+	alerts.Deactivate(ctx, []string{gp.ID})
+	if err := evaluator.alerts.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tick, latest = crossingTick("BATBC", 99, now)
+	evaluator.Evaluate(ctx, tick, latest, latest.TradingDate)
+
+	evaluator.mu.Lock()
+	defer evaluator.mu.Unlock()
+	if _, ok := evaluator.states[gp.ID]; ok {
+		t.Error("the deactivated alert's state was kept")
+	}
+	if _, ok := evaluator.symbolLocks["GP"]; ok {
+		t.Error("the lock of a symbol no alert watches was kept")
+	}
+	if _, ok := evaluator.days["GP"]; ok {
+		t.Error("the day of a symbol no alert watches was kept")
+	}
+	if _, ok := evaluator.symbolLocks["BATBC"]; !ok {
+		t.Error("the lock of a watched symbol was dropped")
+	}
+}
+
+// An alert that fired before a restart is loaded as triggered, so the first
+// tick after the restart does not fire it again; it fires once a tick has
+// re-armed it
+func TestTickEvaluatorRestart(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	alert, _ := alerts.Create(ctx, alertRequest("GP", dto.AlertStatusActive))
+	notifications := &countingNotifications{}
+	now := time.Now().UTC()
+
+	before := newTestTickEvaluator(t, alerts, notifications)
+	tick, latest := crossingTick("GP", 101, now)
+	if fired := before.Evaluate(ctx, tick, latest, latest.TradingDate); fired != 1 {
+		t.Fatalf("fired %d alerts before the restart, want 1", fired)
+	}
+
+	after := newTestTickEvaluator(t, alerts, notifications)
+	for i, step := range []struct {
+		price float64
+		fired int
+	}{
+		{price: 102, fired: 0},
+		{price: 99, fired: 0},
+		{price: 103, fired: 1},
+	} {
+		tick, latest := crossingTick("GP", step.price, now.Add(time.Duration(i+1)*time.Second))
+		if fired := after.Evaluate(ctx, tick, latest, latest.TradingDate); fired != step.fired {
+			t.Errorf("tick at %v after the restart fired %d alerts, want %d", step.price, fired, step.fired)
+		}
+	}
+	if got := notifications.recorded(alert.ID); got != 2 {
+		t.Errorf("recorded %d notifications, want 2", got)
+	}
+}
+
+// MatchingAlerts returns the active alerts of the symbol the price satisfies,
+// without firing them
+func TestMatchingAlerts(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	seed := func(name, symbol string, rule dto.AlertRule, price float64, status dto.AlertStatus) {
+		if _, err := alerts.Create(ctx, &dto.AlertCreateRequest{Name: name, UserID: "alice", Symbol: symbol, Rule: rule,
+			Price: money.FromFloat(price), Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seed("above 100", "GP", dto.AlertRuleAbove, 100, dto.AlertStatusActive)
+	seed("above 120", "GP", dto.AlertRuleAbove, 120, dto.AlertStatusActive)
+	seed("below 90", "GP", dto.AlertRuleBelow, 90, dto.AlertStatusActive)
+	seed("below 80", "GP", dto.AlertRuleBelow, 80, dto.AlertStatusActive)
+	seed("up 5%", "GP", dto.AlertRulePercentChangeAbove, 5, dto.AlertStatusActive)
+	seed("inactive above 10", "GP", dto.AlertRuleAbove, 10, dto.AlertStatusInactive)
+	seed("other symbol", "BATBC", dto.AlertRuleAbove, 10, dto.AlertStatusActive)
+	notifications := &countingNotifications{}
+	evaluator := newTestTickEvaluator(t, alerts, notifications)
+
+	for _, tc := range []struct {
+		symbol string
+		price  float64
+		want   []string
+	}{
+		{symbol: "GP", price: 95, want: nil},
+		// Thresholds are inclusive
+		{symbol: "GP", price: 100, want: []string{"above 100"}},
+		{symbol: "GP", price: 90, want: []string{"below 90"}},
+		{symbol: "GP", price: 110, want: []string{"above 100"}},
+		{symbol: "GP", price: 130, want: []string{"above 100", "above 120"}},
+		{symbol: "GP", price: 85, want: []string{"below 90"}},
+		{symbol: " gp ", price: 75, want: []string{"below 80", "below 90"}},
+		{symbol: "SQURPHARMA", price: 75, want: nil},
+	} {
+		t.Run(fmt.Sprintf("%s at %v", tc.symbol, tc.price), func(t *testing.T) {
+			var got []string
+			for _, alert := range evaluator.MatchingAlerts(tc.symbol, money.FromFloat(tc.price)) {
+				got = append(got, alert.Name)
+			}
+			sort.Strings(got)
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+	if len(notifications.triggers) != 0 {
+		t.Errorf("matching fired %v", notifications.triggers)
+	}
+}
+
+// The index the evaluator exposes follows alerts as they are created and
+// deleted, once it reloads, and carries the triggered state the evaluator
+// recorded
+func TestAlertsForSymbol(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	create := func(name, symbol string) *dto.AlertResponse {
+		alert, err := alerts.Create(ctx, &dto.AlertCreateRequest{Name: name, UserID: "alice", Symbol: symbol, Rule: dto.AlertRuleAbove,
+			Price: money.FromFloat(100), Status: dto.AlertStatusActive, EvaluateOffHours: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return alert
+	}
+	first := create("first", "GP")
+	create("other symbol", "BATBC")
+	evaluator := newTestTickEvaluator(t, alerts, &countingNotifications{})
+	indexed := func() []string {
+		var names []string
+		for _, alert := range evaluator.AlertsForSymbol(" gp ") {
+			names = append(names, fmt.Sprintf("%s:%t", alert.Name, alert.Triggered))
+		}
+		sort.Strings(names)
+		return names
+	}
+	reload := func() {
+		if err := evaluator.alerts.Refresh(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, step := range []struct {
+		name   string
+		change func()
+		want   []string
+	}{
+		{name: "loaded", change: func() {}, want: []string{"first:false"}},
+		{name: "added", change: func() { create("second", "GP"); reload() }, want: []string{"first:false", "second:false"}},
+		{name: "fired", change: func() {
+			tick, latest := crossingTick("GP", 101, time.Now().UTC())
+			evaluator.Evaluate(ctx, tick, latest, latest.TradingDate)
+		}, want: []string{"first:true", "second:true"}},
+		{name: "deleted", change: func() {
+			if err := alerts.Delete(ctx, first.ID); err != nil {
+				t.Fatal(err)
+			}
+			reload()
+		}, want: []string{"second:true"}},
+	} {
+		step.change()
+		if got := indexed(); fmt.Sprint(got) != fmt.Sprint(step.want) {
+			t.Errorf("%s: got %v, want %v", step.name, got, step.want)
+		}
+	}
+	if got := evaluator.AlertsForSymbol("SQURPHARMA"); len(got) != 0 {
+		t.Errorf("got %v for a symbol without alerts", got)
+	}
+}
+
+// An alert in shadow mode, by itself or through the shadow_rules flag, fires
+// and is re-armed like any other, but its trigger only reaches the shadow
+// record and a shadow sample, even with sampling off
+func TestTickEvaluatorShadow(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	normal, _ := alerts.Create(ctx, alertRequest("GP", dto.AlertStatusActive))
+	own, _ := alerts.Create(ctx, alertRequest("GP", dto.AlertStatusActive))
+	if _, err := alerts.SetShadow(ctx, own.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	below := alertRequest("GP", dto.AlertStatusActive)
+	below.Rule, below.Price = dto.AlertRuleBelow, money.FromFloat(120)
+	byRule, _ := alerts.Create(ctx, below)
+
+	flags := NewFeatureFlags(repository.NewMemoryFeatureFlagRepository(), FeatureFlagEnv{}, time.Minute)
+	if _, err := flags.Set(ctx, FlagShadowRules.Name, dto.FeatureFlagRequest{Value: "below"}); err != nil {
+		t.Fatal(err)
+	}
+	sampler := NewEvaluationSampler(repository.NewMemoryEvaluationSampleRepository(), alerts, EvaluationSamplingConfig{})
+	cache := NewAlertCache(alerts, time.Minute)
+	if err := cache.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	notifications := &countingNotifications{}
+	evaluator := NewTickEvaluator(cache, alerts, notifications, sampler, flags)
+	now := time.Now().UTC()
+
+	tick, latest := crossingTick("GP", 110, now)
+	if fired := evaluator.Evaluate(ctx, tick, latest, latest.TradingDate); fired != 3 {
+		t.Fatalf("fired %d alerts, want 3", fired)
+	}
+	for _, tc := range []struct {
+		name         string
+		id           string
+		wantNotified int
+		wantShadow   int
+	}{
+		{name: "normal", id: normal.ID, wantNotified: 1},
+		{name: "shadow alert", id: own.ID, wantShadow: 1},
+		{name: "shadow rule", id: byRule.ID, wantShadow: 1},
+	} {
+		if got, shadow := notifications.recorded(tc.id), notifications.recordedShadow(tc.id); got != tc.wantNotified || shadow != tc.wantShadow {
+			t.Errorf("%s: got %d triggers and %d shadow triggers, want %d and %d", tc.name, got, shadow, tc.wantNotified, tc.wantShadow)
+		}
+		if stored, _ := alerts.FindByID(ctx, tc.id); !stored.Triggered {
+			t.Errorf("%s: the alert is not triggered", tc.name)
+		}
+	}
+
+	storeQueued(t, sampler)
+	for _, id := range []string{own.ID, byRule.ID} {
+		samples, err := sampler.GetEvaluations(ctx, id, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(samples) != 1 || !samples[0].Shadow || samples[0].SampledBy != SampledByShadow || samples[0].Outcome != dto.EvaluationFired {
+			t.Errorf("got samples %+v of shadow alert %s, want one shadow fire", samples, id)
+		}
+	}
+	if samples, _ := sampler.GetEvaluations(ctx, normal.ID, false); len(samples) != 0 {
+		t.Errorf("got %d samples of the normal alert with sampling off, want 0", len(samples))
+	}
+
+	// once the flag is cleared, the rule's alerts notify again after re-arming
+	if _, err := flags.Clear(ctx, FlagShadowRules.Name); err != nil {
+		t.Fatal(err)
+	}
+	for i, price := range []float64{130, 110} {
+		tick, latest := crossingTick("GP", price, now.Add(time.Duration(i+1)*time.Second))
+		evaluator.Evaluate(ctx, tick, latest, latest.TradingDate)
+	}
+	if got, shadow := notifications.recorded(byRule.ID), notifications.recordedShadow(byRule.ID); got != 1 || shadow != 1 {
+		t.Errorf("got %d triggers and %d shadow triggers with the flag cleared, want 1 and 1", got, shadow)
+	}
+}
+
+// Shadow rules are read comma-separated, whatever the spacing, and written
+// back in name order
+func TestParseShadowRules(t *testing.T) {
+	for _, tc := range []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "", want: ""},
+		{raw: "below", want: "below"},
+		{raw: " percent_change_below , above,", want: "above,percent_change_below"},
+		{raw: "above,sideways", wantErr: true},
+	} {
+		rules, err := parseShadowRules(tc.raw)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: got %v, want an error", tc.raw, rules)
+			}
+			continue
+		}
+		if err != nil || rules.String() != tc.want {
+			t.Errorf("%q: got %q, %v, want %q", tc.raw, rules.String(), err, tc.want)
+		}
+	}
+}
+
+// Backfilled ticks are evaluated in time order as if every alert ran in shadow
+// mode: each crossing is a shadow trigger and a shadow sample, no one is
+// notified, and no alert's state changes, so the next live crossing still fires
+func TestEvaluateBackfillIsShadowOnly(t *testing.T) {
+	ctx := context.Background()
+	alerts := repository.NewMemoryAlertRepository()
+	above, _ := alerts.Create(ctx, alertRequest("GP", dto.AlertStatusActive))
+	request := alertRequest("GP", dto.AlertStatusActive)
+	request.Rule, request.Price = dto.AlertRuleBelow, money.FromFloat(90)
+	below, _ := alerts.Create(ctx, request)
+
+	sampler := NewEvaluationSampler(repository.NewMemoryEvaluationSampleRepository(), alerts, EvaluationSamplingConfig{})
+	cache := NewAlertCache(alerts, time.Minute)
+	if err := cache.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	notifications := &countingNotifications{}
+	evaluator := NewTickEvaluator(cache, alerts, notifications, sampler, nil)
+	prices := NewPriceService(repository.NewMemoryPriceRepository(), repository.NewMemoryQuarantineRepository(),
+		DefaultTickFilterConfig(), DefaultMarketSchedule(), evaluator, nil)
+
+	// sent out of order: 95, 105 fires above, 98 re-arms it, 106 fires it
+	// again and 85 fires below
+	start := time.Date(2024, 3, 4, 5, 0, 0, 0, time.UTC)
+	var ticks []dto.PriceTickRequest
+	for _, tick := range []struct {
+		minute int
+		price  float64
+	}{{3, 106}, {0, 95}, {4, 85}, {2, 98}, {1, 105}} {
+		ticks = append(ticks, dto.PriceTickRequest{Symbol: "GP", Price: money.FromFloat(tick.price), Time: start.Add(time.Duration(tick.minute) * time.Minute)})
+	}
+	result, err := prices.Ingest(ctx, dto.PriceIngestRequest{Ticks: ticks, Backfill: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Accepted != 5 || result.ShadowFired != 3 {
+		t.Fatalf("got %d accepted and %d shadow fired, want 5 and 3", result.Accepted, result.ShadowFired)
+	}
+
+	storeQueued(t, sampler)
+	for _, tc := range []struct {
+		name       string
+		alert      *dto.AlertResponse
+		wantShadow int
+	}{
+		{name: "above", alert: above, wantShadow: 2},
+		{name: "below", alert: below, wantShadow: 1},
+	} {
+		if got, shadow := notifications.recorded(tc.alert.ID), notifications.recordedShadow(tc.alert.ID); got != 0 || shadow != tc.wantShadow {
+			t.Errorf("%s: got %d triggers and %d shadow triggers, want 0 and %d", tc.name, got, shadow, tc.wantShadow)
+		}
+		if stored, _ := alerts.FindByID(ctx, tc.alert.ID); stored.Triggered || stored.LastTriggeredAt != nil {
+			t.Errorf("%s: got %+v, want the alert left armed", tc.name, stored)
+		}
+		samples, _ := sampler.GetEvaluations(ctx, tc.alert.ID, false)
+		if len(samples) != tc.wantShadow {
+			t.Errorf("%s: got %d samples, want %d", tc.name, len(samples), tc.wantShadow)
+		}
+		for _, sample := range samples {
+			if !sample.Shadow || sample.SampledBy != SampledByShadow || sample.Outcome != dto.EvaluationFired {
+				t.Errorf("%s: got sample %+v, want a shadow fire", tc.name, sample)
+			}
+		}
+	}
+
+	// a replay of the capture stores and fires nothing
+	result, err = prices.Ingest(ctx, dto.PriceIngestRequest{Ticks: ticks, Backfill: true})
+	if err != nil || result.Duplicates != 5 || result.ShadowFired != 0 {
+		t.Errorf("got %+v, %v on replay, want 5 duplicates and no fires", result, err)
+	}
+
+	tick, latest := crossingTick("GP", 101, time.Now().UTC())
+	if fired := evaluator.Evaluate(ctx, tick, latest, latest.TradingDate); fired != 1 || notifications.recorded(above.ID) != 1 {
+		t.Errorf("fired %d alerts on a live crossing, want the above alert notified", fired)
+	}
+}
@@ -1,105 +1,233 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"net/mail"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/hello-api/internal/domain"
 	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/mapper"
 	"github.com/hello-api/internal/repository/entity"
+	"github.com/hello-api/pkg/logging"
 )
 
 type UserService struct {
 	repo domain.UserRepository
+	// uniqueEmail rejects a user whose email another user already has
+	uniqueEmail bool
 }
 
 // Ensure UserServiceImpl implements UserService
 var _ domain.UserService = (*UserService)(nil)
 
-func NewUserService(repo domain.UserRepository) *UserService {
+func NewUserService(repo domain.UserRepository, uniqueEmail bool) *UserService {
 	return &UserService{
-		repo: repo,
+		repo:        repo,
+		uniqueEmail: uniqueEmail,
 	}
 }
 
-// mapEntityToDTO converts a user entity to a user DTO
-func mapEntityToDTO(userEntity *entity.UserEntity) dto.UserResponse {
-	return dto.UserResponse{
-		ID:        userEntity.ID.Hex(),
-		UserID:    userEntity.UserID,
-		Name:      userEntity.Name,
-		Email:     userEntity.Email,
-		CreatedAt: userEntity.CreatedAt,
-		UpdatedAt: userEntity.UpdatedAt,
+// LoadUniqueEmail reads USER_UNIQUE_EMAIL; emails may be shared unless it is true
+func LoadUniqueEmail() (bool, error) {
+	raw := os.Getenv("USER_UNIQUE_EMAIL")
+	if raw == "" {
+		return false, nil
 	}
+	unique, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("USER_UNIQUE_EMAIL must be true or false, got %q", raw)
+	}
+	return unique, nil
 }
 
-// GetAllUsers retrieves all users and returns them as DTOs
-func (s *UserService) GetAllUsers() ([]dto.UserResponse, error) {
-	userEntities, err := s.repo.FindAll()
+// checkEmailAvailable rejects an email held by a user other than self when
+// uniqueness is enforced. Emails are compared lower-cased.
+func (s *UserService) checkEmailAvailable(ctx context.Context, email string, self *entity.UserEntity) error {
+	if !s.uniqueEmail {
+		return nil
+	}
+	existing, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to check email uniqueness: %w", err)
+	}
+	if existing != nil && (self == nil || existing.ID != self.ID) {
+		return fmt.Errorf("email '%s' already exists: %w", email, domain.ErrEmailAlreadyExists)
+	}
+	return nil
+}
+
+// normalizeEmail trims and lower-cases an email and checks it is a bare
+// address such as "user@example.com"
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	parsed, err := mail.ParseAddress(email)
+	// ParseAddress also accepts "Name <user@example.com>"; only the address is stored
+	if err != nil || parsed.Address != email {
+		return "", fmt.Errorf("invalid email address %q: %w", email, domain.ErrValidation)
+	}
+	return email, nil
+}
+
+// checkEmailMode accepts the supported email delivery modes
+func checkEmailMode(mode dto.EmailMode) error {
+	switch mode {
+	case dto.EmailModeImmediate, dto.EmailModeHourly:
+		return nil
+	}
+	return fmt.Errorf("emailMode must be immediate or hourly, got %q: %w", mode, domain.ErrValidation)
+}
+
+// checkNotificationPreferences validates the quiet hours and the display
+// timezone of notification preferences
+func checkNotificationPreferences(prefs *dto.NotificationPreferences) error {
+	if prefs == nil {
+		return nil
+	}
+	if quiet := prefs.QuietHours; quiet != nil {
+		start, err := parseClock(quiet.Start)
+		if err != nil {
+			return fmt.Errorf("quietHours.start: %v: %w", err, domain.ErrValidation)
+		}
+		end, err := parseClock(quiet.End)
+		if err != nil {
+			return fmt.Errorf("quietHours.end: %v: %w", err, domain.ErrValidation)
+		}
+		if start == end {
+			return fmt.Errorf("quietHours must not start and end at the same time: %w", domain.ErrValidation)
+		}
+		if quiet.Timezone != "" {
+			if _, err := time.LoadLocation(quiet.Timezone); err != nil {
+				return fmt.Errorf("quietHours.timezone %q is not a known timezone: %w", quiet.Timezone, domain.ErrValidation)
+			}
+		}
+	}
+	if prefs.DisplayTimezone != "" {
+		if _, err := time.LoadLocation(prefs.DisplayTimezone); err != nil {
+			return fmt.Errorf("displayTimezone %q is not a known timezone: %w", prefs.DisplayTimezone, domain.ErrValidation)
+		}
+	}
+	return nil
+}
+
+// checkAlertDefaults validates alert defaults; empty defaults clear them
+func checkAlertDefaults(defaults dto.AlertDefaults) error {
+	if defaults.DurationDays < 0 {
+		return fmt.Errorf("defaultDurationDays must not be negative: %w", domain.ErrValidation)
+	}
+	seen := make(map[dto.NotificationChannel]bool)
+	for _, channel := range defaults.Channels {
+		// Webhooks need a URL per alert, so they cannot be a default
+		if channel != dto.NotificationChannelTelegram && channel != dto.NotificationChannelEmail {
+			return fmt.Errorf("defaultChannels must be telegram or email, got %q: %w", channel, domain.ErrValidation)
+		}
+		if seen[channel] {
+			return fmt.Errorf("defaultChannels lists %s twice: %w", channel, domain.ErrValidation)
+		}
+		seen[channel] = true
+	}
+	return nil
+}
+
+// GetAllUsers retrieves one page of users and returns them as DTOs. Pages start at 1.
+func (s *UserService) GetAllUsers(ctx context.Context, page, pageSize int64) (*dto.UserPageResponse, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, fmt.Errorf("page and pageSize must be positive: %w", domain.ErrValidation)
+	}
+	// The page's offset, (page-1)*pageSize, must fit in an int64
+	if page-1 > math.MaxInt64/pageSize {
+		return nil, fmt.Errorf("page %d is out of range: %w", page, domain.ErrValidation)
+	}
+	userEntities, total, err := s.repo.FindAll(ctx, page, pageSize)
 	if err != nil {
 		return nil, err
 	}
 	
-	var userDTOs []dto.UserResponse
+	userDTOs := make([]dto.UserResponse, 0, len(userEntities))
 	for _, entity := range userEntities {
-		userDTOs = append(userDTOs, mapEntityToDTO(&entity))
+		userDTOs = append(userDTOs, mapper.UserToDTO(&entity))
 	}
 	
-	return userDTOs, nil
+	return &dto.UserPageResponse{
+		Items:      userDTOs,
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+		TotalPages: (total + pageSize - 1) / pageSize,
+	}, nil
 }
 
 // GetUserByID retrieves a user by ID and returns it as a DTO
-func (s *UserService) GetUserByID(id string) (*dto.UserResponse, error) {
-	userEntity, err := s.repo.FindByObjectID(id)
+func (s *UserService) GetUserByID(ctx context.Context, id string) (*dto.UserResponse, error) {
+	userEntity, err := s.repo.FindByObjectID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	if userEntity == nil {
-		return nil, nil
+		return nil, domain.ErrUserNotFound
 	}
-	response := mapEntityToDTO(userEntity)
+	response := mapper.UserToDTO(userEntity)
 	return &response, nil
 }
 
 // CreateUser creates a new user from a DTO and returns a response DTO
-func (s *UserService) CreateUser(userDTO dto.UserCreateRequest) (*dto.UserResponse, error) {
+func (s *UserService) CreateUser(ctx context.Context, userDTO dto.UserCreateRequest) (*dto.UserResponse, error) {
 	// Validate required fields
 	if userDTO.Name == "" || userDTO.Email == "" || userDTO.UserID == "" {
 		return nil, fmt.Errorf("name, email, and userId are required: %w", domain.ErrValidation)
 	}
 	userID := strings.ToLower(userDTO.UserID)
+	email, err := normalizeEmail(userDTO.Email)
+	if err != nil {
+		return nil, err
+	}
+	if userDTO.EmailMode == "" {
+		userDTO.EmailMode = dto.EmailModeImmediate
+	}
+	if err := checkEmailMode(userDTO.EmailMode); err != nil {
+		return nil, err
+	}
+	if err := checkNotificationPreferences(userDTO.NotificationPreferences); err != nil {
+		return nil, err
+	}
 	// Efficiently check if userId exists in DB
-	existing, err := s.repo.FindByUserID(userID)
+	existing, err := s.repo.FindByUserID(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check userId uniqueness: %w", err)
 	}
 	if existing != nil {
 		return nil, fmt.Errorf("userId '%s' already exists: %w", userID, domain.ErrUserAlreadyExit)
 	}
-	// Create entity from DTO
-	userEntity := &entity.UserEntity{
-		UserID: userID,
-		Name:  userDTO.Name,
-		Email: userDTO.Email,
+	if err := s.checkEmailAvailable(ctx, email, nil); err != nil {
+		return nil, err
 	}
+	// Create entity from DTO
+	userDTO.UserID = userID
+	userDTO.Email = email
+	userEntity := mapper.UserToEntity(userDTO)
 	
 	// Save to repository
-	createdEntity, err := s.repo.Create(userEntity)
+	createdEntity, err := s.repo.Create(ctx, userEntity)
 	if err != nil {
 		return nil, err
 	}
 	
+	logging.FromContext(ctx).Info("user registered", "id", createdEntity.ID.Hex(), "user_id", createdEntity.UserID)
+
 	// Convert back to DTO
-	response := mapEntityToDTO(createdEntity)
+	response := mapper.UserToDTO(createdEntity)
 	return &response, nil
 }
 
 // UpdateUser updates an existing user from a DTO and returns a response DTO
-func (s *UserService) UpdateUser(id string, userDTO dto.UserUpdateRequest) (*dto.UserResponse, error) {
+func (s *UserService) UpdateUser(ctx context.Context, id string, userDTO dto.UserUpdateRequest) (*dto.UserResponse, error) {
 	// First, get the existing user
-	existingEntity, err := s.repo.FindByObjectID(id)
+	existingEntity, err := s.repo.FindByObjectID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -112,25 +240,182 @@ func (s *UserService) UpdateUser(id string, userDTO dto.UserUpdateRequest) (*dto
 		existingEntity.Name = userDTO.Name
 	}
 	if userDTO.Email != "" {
-		existingEntity.Email = userDTO.Email
+		email, err := normalizeEmail(userDTO.Email)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkEmailAvailable(ctx, email, existingEntity); err != nil {
+			return nil, err
+		}
+		existingEntity.Email = email
+	}
+	if userDTO.EmailMode != "" {
+		if err := checkEmailMode(userDTO.EmailMode); err != nil {
+			return nil, err
+		}
+		existingEntity.EmailMode = string(userDTO.EmailMode)
+	}
+	if userDTO.NotificationPreferences != nil {
+		if err := checkNotificationPreferences(userDTO.NotificationPreferences); err != nil {
+			return nil, err
+		}
+		mapper.ApplyNotificationPreferences(existingEntity, userDTO.NotificationPreferences)
 	}
 	
-	existingEntity.UpdatedAt = time.Now()
+	existingEntity.UpdatedAt = time.Now().UTC()
 
 	// Save to repository
-	updatedEntity, err := s.repo.Update(existingEntity)
+	updatedEntity, err := s.repo.Update(ctx, existingEntity)
 	if err != nil {
 		return nil, err
 	}
 	
 	// Convert back to DTO
-	response := mapEntityToDTO(updatedEntity)
+	response := mapper.UserToDTO(updatedEntity)
+	return &response, nil
+}
+
+// PatchUser updates the name and email present in the request. An empty
+// value clears the field; an absent one is left unchanged.
+func (s *UserService) PatchUser(ctx context.Context, id string, patch dto.UserPatchRequest) (*dto.UserResponse, error) {
+	if patch.Name == nil && patch.Email == nil {
+		return nil, fmt.Errorf("at least one field (name or email) must be provided: %w", domain.ErrValidation)
+	}
+	existingEntity, err := s.repo.FindByObjectID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existingEntity == nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	if patch.Name != nil {
+		existingEntity.Name = strings.TrimSpace(*patch.Name)
+	}
+	if patch.Email != nil {
+		email := strings.TrimSpace(*patch.Email)
+		if email == "" {
+			// The unique email index admits a single empty email
+			if s.uniqueEmail {
+				return nil, fmt.Errorf("email cannot be cleared while emails must be unique: %w", domain.ErrValidation)
+			}
+		} else {
+			email, err = normalizeEmail(email)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.checkEmailAvailable(ctx, email, existingEntity); err != nil {
+				return nil, err
+			}
+		}
+		existingEntity.Email = email
+	}
+	existingEntity.UpdatedAt = time.Now().UTC()
+
+	updatedEntity, err := s.repo.Update(ctx, existingEntity)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("user patched", "id", id,
+		"name_changed", patch.Name != nil, "email_changed", patch.Email != nil)
+
+	response := mapper.UserToDTO(updatedEntity)
+	return &response, nil
+}
+
+// MuteUser suppresses every notification of the user, on every channel and
+// including urgent alerts, for triggers before the requested time. Triggers
+// are still recorded and pushed to live connections.
+func (s *UserService) MuteUser(ctx context.Context, id string, req dto.UserMuteRequest) (*dto.UserResponse, error) {
+	now := time.Now().UTC()
+	var until time.Time
+	switch {
+	case req.Until != nil && req.For != "":
+		return nil, fmt.Errorf("only one of until and for may be given: %w", domain.ErrValidation)
+	case req.Until != nil:
+		until = req.Until.UTC()
+	case req.For != "":
+		duration, err := time.ParseDuration(req.For)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("for must be a positive duration such as 2h, got %q: %w", req.For, domain.ErrValidation)
+		}
+		until = now.Add(duration)
+	default:
+		return nil, fmt.Errorf("until or for is required: %w", domain.ErrValidation)
+	}
+	if !until.After(now) {
+		return nil, fmt.Errorf("until must be in the future: %w", domain.ErrValidation)
+	}
+	return s.setMutedUntil(ctx, id, &until)
+}
+
+// UnmuteUser lets the user's notifications through again
+func (s *UserService) UnmuteUser(ctx context.Context, id string) (*dto.UserResponse, error) {
+	return s.setMutedUntil(ctx, id, nil)
+}
+
+func (s *UserService) setMutedUntil(ctx context.Context, id string, until *time.Time) (*dto.UserResponse, error) {
+	existingEntity, err := s.repo.FindByObjectID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existingEntity == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	existingEntity.MutedUntil = until
+	updatedEntity, err := s.repo.Update(ctx, existingEntity)
+	if err != nil {
+		return nil, err
+	}
+	if until != nil {
+		logging.FromContext(ctx).Info("user notifications muted", "user_id", updatedEntity.UserID, "muted_until", *until)
+	} else {
+		logging.FromContext(ctx).Info("user notifications unmuted", "user_id", updatedEntity.UserID)
+	}
+	response := mapper.UserToDTO(updatedEntity)
 	return &response, nil
 }
 
 // DeleteUser deletes a user by ID
-func (s *UserService) DeleteUser(id string) error {
+func (s *UserService) DeleteUser(ctx context.Context, id string) error {
 	// You could add additional business logic here
 	// For example, check if the user has related data before deleting
-	return s.repo.DeleteByObjectID(id)
+	if err := s.repo.DeleteByObjectID(ctx, id); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("user deleted", "id", id)
+	return nil
+}
+
+// CountUsers returns the total number of users
+func (s *UserService) CountUsers(ctx context.Context) (*dto.UserCountResponse, error) {
+	count, err := s.repo.Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.UserCountResponse{Count: count}, nil
+}
+
+// SetAlertDefaults replaces the alert defaults of the user with the given
+// userId; empty defaults clear them
+func (s *UserService) SetAlertDefaults(ctx context.Context, userID string, defaults dto.AlertDefaults) (*dto.UserResponse, error) {
+	if err := checkAlertDefaults(defaults); err != nil {
+		return nil, err
+	}
+	defaultsEntity := mapper.AlertDefaultsToEntity(defaults)
+	existingEntity, err := s.repo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existingEntity == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	existingEntity.AlertDefaults = defaultsEntity
+	updatedEntity, err := s.repo.Update(ctx, existingEntity)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("user alert defaults set", "user_id", updatedEntity.UserID, "cleared", defaultsEntity == nil)
+	response := mapper.UserToDTO(updatedEntity)
+	return &response, nil
 }
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hello-api/internal/domain"
+	"github.com/hello-api/internal/handler/dto"
+	"github.com/hello-api/internal/repository"
+)
+
+// newTestUserService returns a user service on an empty in-memory repository
+func newTestUserService(t *testing.T, uniqueEmail bool) *UserService {
+	t.Helper()
+	return NewUserService(repository.NewMemoryUserRepository(), uniqueEmail)
+}
+
+func createTestUser(t *testing.T, users *UserService, userID, email string) *dto.UserResponse {
+	t.Helper()
+	created, err := users.CreateUser(context.Background(), dto.UserCreateRequest{UserID: userID, Name: "User " + userID, Email: email})
+	if err != nil {
+		t.Fatalf("create %s: %v", userID, err)
+	}
+	return created
+}
+
+func TestUserServiceLifecycle(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUserService(t, false)
+	created := createTestUser(t, users, "Alice", "alice@example.com")
+	if created.UserID != "alice" || created.EmailMode != dto.EmailModeImmediate {
+		t.Errorf("created %+v, want a lower-cased userId and immediate email", created)
+	}
+
+	if _, err := users.CreateUser(ctx, dto.UserCreateRequest{UserID: "ALICE", Name: "Again", Email: "again@example.com"}); !errors.Is(err, domain.ErrUserAlreadyExit) {
+		t.Errorf("creating the userId again returned %v, want ErrUserAlreadyExit", err)
+	}
+	if _, err := users.CreateUser(ctx, dto.UserCreateRequest{UserID: "bob", Email: "bob@example.com"}); !errors.Is(err, domain.ErrValidation) {
+		t.Errorf("creating a user without a name returned %v, want ErrValidation", err)
+	}
+
+	updated, err := users.UpdateUser(ctx, created.ID, dto.UserUpdateRequest{Name: "Alice B", EmailMode: dto.EmailModeHourly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Alice B" || updated.Email != "alice@example.com" || updated.EmailMode != dto.EmailModeHourly {
+		t.Errorf("updated %+v, want the new name and mode and the email kept", updated)
+	}
+	got, err := users.GetUserByID(ctx, created.ID)
+	if err != nil || got.Name != "Alice B" {
+		t.Errorf("got %+v (%v) after the update", got, err)
+	}
+
+	if err := users.DeleteUser(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.GetUserByID(ctx, created.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("getting a deleted user returned %v, want ErrUserNotFound", err)
+	}
+	if _, err := users.UpdateUser(ctx, created.ID, dto.UserUpdateRequest{Name: "Ghost"}); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("updating a deleted user returned %v, want ErrUserNotFound", err)
+	}
+}
+
+// With uniqueness enforced a second user cannot take an email, whether on
+// creation or update; without it emails may be shared
+func TestUserServiceUniqueEmail(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name        string
+		uniqueEmail bool
+		want        error
+	}{
+		{name: "enforced", uniqueEmail: true, want: domain.ErrEmailAlreadyExists},
+		{name: "not enforced", uniqueEmail: false, want: nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			users := newTestUserService(t, tc.uniqueEmail)
+			alice := createTestUser(t, users, "alice", "alice@example.com")
+			bob := createTestUser(t, users, "bob", "bob@example.com")
+
+			_, err := users.CreateUser(ctx, dto.UserCreateRequest{UserID: "carol", Name: "Carol", Email: "alice@example.com"})
+			if !errors.Is(err, tc.want) {
+				t.Errorf("creating a user with alice's email returned %v, want %v", err, tc.want)
+			}
+			_, err = users.UpdateUser(ctx, bob.ID, dto.UserUpdateRequest{Email: "alice@example.com"})
+			if !errors.Is(err, tc.want) {
+				t.Errorf("giving bob alice's email returned %v, want %v", err, tc.want)
+			}
+			// A user keeps their own email on update
+			if _, err := users.UpdateUser(ctx, alice.ID, dto.UserUpdateRequest{Name: "Alice", Email: "alice@example.com"}); err != nil {
+				t.Errorf("updating alice with her own email returned %v", err)
+			}
+		})
+	}
+}
+
+// Emails are trimmed and lower-cased, and anything but a bare address is
+// rejected, on creation and update alike
+func TestUserServiceEmailNormalization(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		email string
+		want  string // "" when the email is invalid
+	}{
+		{email: "alice@example.com", want: "alice@example.com"},
+		{email: "  Alice@Example.COM \t", want: "alice@example.com"},
+		{email: "not-an-email"},
+		{email: "alice@"},
+		{email: "Alice <alice@example.com>"},
+		{email: "alice @example.com"},
+	} {
+		t.Run(tc.email, func(t *testing.T) {
+			users := newTestUserService(t, false)
+			created, err := users.CreateUser(ctx, dto.UserCreateRequest{UserID: "alice", Name: "Alice", Email: tc.email})
+			if tc.want == "" {
+				if !errors.Is(err, domain.ErrValidation) {
+					t.Errorf("creating with %q returned %v, want ErrValidation", tc.email, err)
+				}
+			} else if err != nil || created.Email != tc.want {
+				t.Errorf("creating with %q stored %+v (%v), want %s", tc.email, created, err, tc.want)
+			}
+
+			bob := createTestUser(t, users, "bob", "bob@example.com")
+			updated, err := users.UpdateUser(ctx, bob.ID, dto.UserUpdateRequest{Email: tc.email})
+			if tc.want == "" {
+				if !errors.Is(err, domain.ErrValidation) {
+					t.Errorf("updating with %q returned %v, want ErrValidation", tc.email, err)
+				}
+				if got, _ := users.GetUserByID(ctx, bob.ID); got.Email != "bob@example.com" {
+					t.Errorf("a rejected update stored %s", got.Email)
+				}
+			} else if err != nil || updated.Email != tc.want {
+				t.Errorf("updating with %q stored %+v (%v), want %s", tc.email, updated, err, tc.want)
+			}
+		})
+	}
+}
+
+// Without unique emails a PATCH may clear the email
+func TestUserServicePatchClearEmail(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUserService(t, false)
+	created := createTestUser(t, users, "alice", "alice@example.com")
+	empty := ""
+	patched, err := users.PatchUser(ctx, created.ID, dto.UserPatchRequest{Email: &empty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Email != "" || patched.Name != created.Name {
+		t.Errorf("got %+v, want the email cleared and the name kept", patched)
+	}
+}
+
+// A mute needs exactly one of a future time or a positive duration, and
+// unmuting clears it
+func TestUserServiceMute(t *testing.T) {
+	ctx := context.Background()
+	users := newTestUserService(t, false)
+	created := createTestUser(t, users, "alice", "alice@example.com")
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	for _, tc := range []struct {
+		name    string
+		req     dto.UserMuteRequest
+		wantErr bool
+	}{
+		{name: "for", req: dto.UserMuteRequest{For: "2h"}},
+		{name: "until", req: dto.UserMuteRequest{Until: &future}},
+		{name: "neither", req: dto.UserMuteRequest{}, wantErr: true},
+		{name: "both", req: dto.UserMuteRequest{Until: &future, For: "2h"}, wantErr: true},
+		{name: "past", req: dto.UserMuteRequest{Until: &past}, wantErr: true},
+		{name: "negative", req: dto.UserMuteRequest{For: "-1h"}, wantErr: true},
+		{name: "not a duration", req: dto.UserMuteRequest{For: "soon"}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			muted, err := users.MuteUser(ctx, created.ID, tc.req)
+			if tc.wantErr {
+				if !errors.Is(err, domain.ErrValidation) {
+					t.Errorf("got %v, want ErrValidation", err)
+				}
+				return
+			}
+			if err != nil || muted.MutedUntil == nil || !muted.MutedUntil.After(time.Now()) {
+				t.Errorf("got %+v, %v, want muted until a future time", muted, err)
+			}
+		})
+	}
+
+	unmuted, err := users.UnmuteUser(ctx, created.ID)
+	if err != nil || unmuted.MutedUntil != nil {
+		t.Errorf("got %+v, %v, want unmuted", unmuted, err)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/hello-api/pkg/httpclient
+
+go 1.24.4
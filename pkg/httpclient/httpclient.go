@@ -0,0 +1,350 @@
+// Package httpclient is the outbound HTTP client shared by the API and the
+// datafeed. Every outbound call goes through it, so timeouts, retries, size
+// limits and request IDs behave the same everywhere and are tuned in one place.
+package httpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RequestIDHeader carries the request ID of outbound requests
+const RequestIDHeader = "X-Request-ID"
+
+var (
+	// ErrRequestTooLarge is returned before sending a body over MaxRequestBytes
+	ErrRequestTooLarge = errors.New("request body exceeds the size limit")
+	// ErrResponseTooLarge is returned when reading a body past MaxResponseBytes
+	ErrResponseTooLarge = errors.New("response body exceeds the size limit")
+)
+
+// Config tunes a Client
+type Config struct {
+	// Timeout bounds a single attempt, including reading the response body
+	Timeout time.Duration
+	// MaxAttempts is how often a request is tried in total (1 disables retries)
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt; it doubles per
+	// attempt up to MaxBackoff, and half of it is random jitter
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxRetryAfter is the longest Retry-After waited out. A response asking for
+	// more is returned to the caller instead, which may schedule its own retry.
+	MaxRetryAfter time.Duration
+	// MaxRequestBytes and MaxResponseBytes bound bodies (0 for no limit)
+	MaxRequestBytes  int64
+	MaxResponseBytes int64
+	// Hooks observe every attempt, e.g. for metrics
+	Hooks Hooks
+	// Transport sends the requests; nil uses http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// DefaultConfig suits calls made while something waits on them: three tries
+// within a few seconds
+func DefaultConfig() Config {
+	return Config{
+		Timeout:          10 * time.Second,
+		MaxAttempts:      3,
+		InitialBackoff:   250 * time.Millisecond,
+		MaxBackoff:       5 * time.Second,
+		MaxRetryAfter:    30 * time.Second,
+		MaxRequestBytes:  1 << 20,
+		MaxResponseBytes: 1 << 20,
+	}
+}
+
+// Outcome classifies an attempt
+type Outcome string
+
+const (
+	// OutcomeSuccess is a response below 400
+	OutcomeSuccess Outcome = "success"
+	// OutcomeRejected is a 4xx response that is not retried
+	OutcomeRejected Outcome = "rejected"
+	// OutcomeRetried is a failed attempt followed by another
+	OutcomeRetried Outcome = "retried"
+	// OutcomeFailed is a failed last attempt: a network error, 429 or 5xx
+	OutcomeFailed Outcome = "failed"
+)
+
+// Attempt describes one try of a request
+type Attempt struct {
+	Host    string
+	Method  string
+	Attempt int // 1-based
+	Status  int // 0 when no response arrived
+	Err     error
+	Latency time.Duration
+	Outcome Outcome
+}
+
+// Hooks are called synchronously; they must be quick
+type Hooks struct {
+	OnAttempt func(Attempt)
+}
+
+// Client sends requests with the configured retries and limits. It is safe
+// for concurrent use.
+type Client struct {
+	cfg   Config
+	http  *http.Client
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// New creates a client; zero fields of cfg take their DefaultConfig value
+func New(cfg Config) *Client {
+	defaults := DefaultConfig()
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaults.Timeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaults.MaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaults.InitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaults.MaxBackoff
+	}
+	cfg.InitialBackoff = min(cfg.InitialBackoff, cfg.MaxBackoff)
+	transport := cfg.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Client{cfg: cfg, http: &http.Client{Transport: transport}, sleep: sleepContext}
+}
+
+// Config returns the effective configuration
+func (c *Client) Config() Config {
+	return c.cfg
+}
+
+// Do sends req, retrying network errors, 408, 429 and 5xx responses (except
+// 501) with backoff. A Retry-After on the response is honoured up to
+// MaxRetryAfter; no retry is made that could not start before the context's
+// deadline. Requests whose body cannot be replayed (no GetBody) are tried
+// once. The request carries an X-Request-ID: the one already set, the one in
+// its context, or a new one. The caller must close the returned body; reading
+// it past MaxResponseBytes fails with ErrResponseTooLarge.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if c.cfg.MaxRequestBytes > 0 && req.ContentLength > c.cfg.MaxRequestBytes {
+		return nil, fmt.Errorf("%s %s: %w (%d > %d bytes)", req.Method, req.URL.Host, ErrRequestTooLarge, req.ContentLength, c.cfg.MaxRequestBytes)
+	}
+	ctx := req.Context()
+	if req.Header.Get(RequestIDHeader) == "" {
+		id := RequestIDFromContext(ctx)
+		if id == "" {
+			id = newRequestID()
+		}
+		req.Header.Set(RequestIDHeader, id)
+	}
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		attemptReq, cancel, err := c.attemptRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		resp, err := c.http.Do(attemptReq)
+		info := Attempt{Host: req.URL.Host, Method: req.Method, Attempt: attempt, Err: err, Latency: time.Since(start)}
+		if resp != nil {
+			info.Status = resp.StatusCode
+		}
+
+		retryable, wait := c.classify(resp, err)
+		if wait <= 0 {
+			wait = c.backoff(attempt)
+		}
+		last := !retryable || !replayable || attempt >= c.cfg.MaxAttempts || !fitsDeadline(ctx, wait)
+		switch {
+		case err == nil && resp.StatusCode < 400:
+			info.Outcome = OutcomeSuccess
+		case err == nil && !retryable:
+			info.Outcome = OutcomeRejected
+		case last:
+			info.Outcome = OutcomeFailed
+		default:
+			info.Outcome = OutcomeRetried
+		}
+		c.observe(info)
+
+		if last {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			if c.cfg.MaxResponseBytes > 0 && resp.ContentLength > c.cfg.MaxResponseBytes {
+				resp.Body.Close()
+				cancel()
+				return nil, fmt.Errorf("%s %s: %w (%d > %d bytes)", req.Method, req.URL.Host, ErrResponseTooLarge, resp.ContentLength, c.cfg.MaxResponseBytes)
+			}
+			resp.Body = &limitedBody{body: resp.Body, remaining: c.cfg.MaxResponseBytes, limited: c.cfg.MaxResponseBytes > 0, cancel: cancel}
+			return resp, nil
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		cancel()
+		if err := c.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attemptRequest clones req for one attempt under the per-attempt timeout
+func (c *Client) attemptRequest(req *http.Request, attempt int) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), c.cfg.Timeout)
+	attemptReq := req.Clone(ctx)
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		attemptReq.Body = body
+	}
+	return attemptReq, cancel, nil
+}
+
+// classify reports whether an attempt may be retried and the Retry-After it
+// asked for. A Retry-After beyond MaxRetryAfter makes the response final.
+func (c *Client) classify(resp *http.Response, err error) (bool, time.Duration) {
+	if err != nil {
+		return true, 0
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+	default:
+		return false, 0
+	}
+	wait := RetryAfter(resp, time.Now())
+	if wait > c.cfg.MaxRetryAfter {
+		return false, wait
+	}
+	return true, wait
+}
+
+// backoff is the delay after the given failed attempt: the doubled initial
+// backoff capped at MaxBackoff, of which the upper half is random
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.cfg.InitialBackoff
+	for i := 1; i < attempt && d < c.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, c.cfg.MaxBackoff)
+	half := d / 2
+	return half + time.Duration(mathrand.Int63n(int64(half)+1))
+}
+
+// fitsDeadline reports whether a retry after wait can still start before the
+// caller's deadline; otherwise the last response or error is returned at once
+func fitsDeadline(ctx context.Context, wait time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Now().Add(wait).Before(deadline)
+}
+
+func (c *Client) observe(info Attempt) {
+	if c.cfg.Hooks.OnAttempt != nil {
+		c.cfg.Hooks.OnAttempt(info)
+	}
+}
+
+// RetryAfter returns the delay a response's Retry-After header asks for, in
+// seconds or as an HTTP date, or 0 without one
+func RetryAfter(resp *http.Response, now time.Time) time.Duration {
+	raw := resp.Header.Get("Retry-After")
+	if raw == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(raw); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(raw); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID stores the ID outbound requests made with ctx carry
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limitedBody fails reads past the response limit and releases the attempt's
+// timeout when closed
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	limited   bool
+	cancel    context.CancelFunc
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if !b.limited {
+		return b.body.Read(p)
+	}
+	if b.remaining <= 0 {
+		// Anything left beyond the limit is an oversized body
+		var probe [1]byte
+		n, err := b.body.Read(probe[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	err := b.body.Close()
+	b.cancel()
+	return err
+}
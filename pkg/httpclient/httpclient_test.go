@@ -0,0 +1,334 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// roundTripFunc lets a test fail attempts before they reach the server
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// scriptedServer answers the nth request with the nth status, repeating the
+// last one, and records the bodies it received
+type scriptedServer struct {
+	*httptest.Server
+	statuses   []int
+	retryAfter string
+
+	mu     sync.Mutex
+	bodies []string
+}
+
+func newScriptedServer(t *testing.T, statuses ...int) *scriptedServer {
+	t.Helper()
+	s := &scriptedServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, string(body))
+		status := s.statuses[min(len(s.bodies), len(s.statuses))-1]
+		s.mu.Unlock()
+		if s.retryAfter != "" {
+			w.Header().Set("Retry-After", s.retryAfter)
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, strconv.Itoa(status))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *scriptedServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+// newTestClient returns a client that records its waits instead of sleeping
+// and the outcome of every attempt
+func newTestClient(cfg Config) (*Client, *[]time.Duration, *[]Outcome) {
+	var waits []time.Duration
+	var outcomes []Outcome
+	cfg.Hooks.OnAttempt = func(attempt Attempt) { outcomes = append(outcomes, attempt.Outcome) }
+	c := New(cfg)
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	return c, &waits, &outcomes
+}
+
+// 408, 429 and 5xx responses and network errors are retried; other 4xx
+// responses and 501 are returned at once
+func TestDoRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		statuses     []int
+		networkFails int
+		wantStatus   int
+		wantOutcomes []Outcome
+	}{
+		{name: "success", statuses: []int{200}, wantStatus: 200, wantOutcomes: []Outcome{OutcomeSuccess}},
+		{name: "429", statuses: []int{429, 200}, wantStatus: 200, wantOutcomes: []Outcome{OutcomeRetried, OutcomeSuccess}},
+		{name: "408", statuses: []int{408, 200}, wantStatus: 200, wantOutcomes: []Outcome{OutcomeRetried, OutcomeSuccess}},
+		{name: "5xx", statuses: []int{500, 502, 200}, wantStatus: 200, wantOutcomes: []Outcome{OutcomeRetried, OutcomeRetried, OutcomeSuccess}},
+		{name: "network error", statuses: []int{200}, networkFails: 1, wantStatus: 200, wantOutcomes: []Outcome{OutcomeRetried, OutcomeSuccess}},
+		{name: "400", statuses: []int{400, 200}, wantStatus: 400, wantOutcomes: []Outcome{OutcomeRejected}},
+		{name: "404", statuses: []int{404, 200}, wantStatus: 404, wantOutcomes: []Outcome{OutcomeRejected}},
+		{name: "401", statuses: []int{401, 200}, wantStatus: 401, wantOutcomes: []Outcome{OutcomeRejected}},
+		{name: "501", statuses: []int{501, 200}, wantStatus: 501, wantOutcomes: []Outcome{OutcomeRejected}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newScriptedServer(t, tc.statuses...)
+			fails := tc.networkFails
+			transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				if fails > 0 {
+					fails--
+					return nil, errors.New("connection reset by peer")
+				}
+				return http.DefaultTransport.RoundTrip(req)
+			})
+			c, waits, outcomes := newTestClient(Config{MaxAttempts: 3, Transport: transport})
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus || string(body) != strconv.Itoa(tc.wantStatus) {
+				t.Errorf("got %d %q, want %d", resp.StatusCode, body, tc.wantStatus)
+			}
+			if len(*outcomes) != len(tc.wantOutcomes) {
+				t.Fatalf("got outcomes %v, want %v", *outcomes, tc.wantOutcomes)
+			}
+			for i := range tc.wantOutcomes {
+				if (*outcomes)[i] != tc.wantOutcomes[i] {
+					t.Errorf("got outcome %d %s, want %s", i+1, (*outcomes)[i], tc.wantOutcomes[i])
+				}
+			}
+			if len(*waits) != len(tc.wantOutcomes)-1 {
+				t.Errorf("got waits %v, want one per retry", *waits)
+			}
+		})
+	}
+}
+
+// A request is tried MaxAttempts times at most; the last failure is returned
+// and the backoff doubles, jittered within its upper half, up to MaxBackoff
+func TestDoMaxAttempts(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		networkErr  bool
+		maxAttempts int
+	}{
+		{name: "5xx", maxAttempts: 4},
+		{name: "network error", networkErr: true, maxAttempts: 3},
+		{name: "no retries", maxAttempts: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newScriptedServer(t, http.StatusServiceUnavailable)
+			attempts := 0
+			transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				attempts++
+				if tc.networkErr {
+					return nil, errors.New("connection refused")
+				}
+				return http.DefaultTransport.RoundTrip(req)
+			})
+			c, waits, outcomes := newTestClient(Config{
+				MaxAttempts:    tc.maxAttempts,
+				InitialBackoff: 100 * time.Millisecond,
+				MaxBackoff:     300 * time.Millisecond,
+				Transport:      transport,
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			resp, err := c.Do(req)
+			if tc.networkErr {
+				if err == nil {
+					t.Fatal("got no error, want the last network error")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusServiceUnavailable {
+					t.Errorf("got status %d, want 503", resp.StatusCode)
+				}
+			}
+			if attempts != tc.maxAttempts {
+				t.Errorf("got %d attempts, want %d", attempts, tc.maxAttempts)
+			}
+			if last := (*outcomes)[len(*outcomes)-1]; last != OutcomeFailed {
+				t.Errorf("got last outcome %s, want failed", last)
+			}
+
+			// after attempt n the backoff is 100ms * 2^(n-1), capped at 300ms
+			caps := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}
+			if len(*waits) != tc.maxAttempts-1 {
+				t.Fatalf("got waits %v, want %d", *waits, tc.maxAttempts-1)
+			}
+			for i, wait := range *waits {
+				if limit := caps[i]; wait < limit/2 || wait > limit {
+					t.Errorf("got wait %d of %s, want between %s and %s", i+1, wait, limit/2, limit)
+				}
+			}
+		})
+	}
+}
+
+// Retry-After is read in seconds and as an HTTP date
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		header string
+		want   time.Duration
+	}{
+		{header: "", want: 0},
+		{header: "3", want: 3 * time.Second},
+		{header: "0", want: 0},
+		{header: "-5", want: 0},
+		{header: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second},
+		{header: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{header: "soon", want: 0},
+	} {
+		resp := &http.Response{Header: http.Header{}}
+		if tc.header != "" {
+			resp.Header.Set("Retry-After", tc.header)
+		}
+		if got := RetryAfter(resp, now); got != tc.want {
+			t.Errorf("Retry-After %q: got %s, want %s", tc.header, got, tc.want)
+		}
+	}
+}
+
+// A retried response waits what its Retry-After asks for, in either form; one
+// asking for more than MaxRetryAfter is returned to the caller
+func TestDoRetryAfter(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		retryAfter   string
+		wantAttempts int
+		wantWait     time.Duration
+	}{
+		{name: "seconds", retryAfter: "2", wantAttempts: 2, wantWait: 2 * time.Second},
+		{name: "HTTP date", retryAfter: time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), wantAttempts: 2, wantWait: 10 * time.Second},
+		{name: "over the max", retryAfter: "120", wantAttempts: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newScriptedServer(t, http.StatusTooManyRequests, http.StatusOK)
+			server.retryAfter = tc.retryAfter
+			c, waits, _ := newTestClient(Config{MaxAttempts: 3, MaxRetryAfter: time.Minute})
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got := len(server.received()); got != tc.wantAttempts {
+				t.Fatalf("got %d attempts, want %d", got, tc.wantAttempts)
+			}
+			if tc.wantAttempts == 1 {
+				if resp.StatusCode != http.StatusTooManyRequests || len(*waits) != 0 {
+					t.Errorf("got %d after waiting %v, want the 429 at once", resp.StatusCode, *waits)
+				}
+				return
+			}
+			// an HTTP date has whole seconds, so up to one less is waited
+			if len(*waits) != 1 || (*waits)[0] > tc.wantWait || (*waits)[0] < tc.wantWait-time.Second {
+				t.Errorf("got waits %v, want %s", *waits, tc.wantWait)
+			}
+		})
+	}
+}
+
+// Every retry sends the whole body again; a body that cannot be replayed is
+// tried once
+func TestDoReplaysBody(t *testing.T) {
+	const payload = `{"symbol":"GP","price":"350.10"}`
+
+	server := newScriptedServer(t, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusCreated)
+	c, _, _ := newTestClient(Config{MaxAttempts: 3})
+	req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader([]byte(payload)))
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	bodies := server.received()
+	if resp.StatusCode != http.StatusCreated || len(bodies) != 3 {
+		t.Fatalf("got %d after %d attempts, want 201 after 3", resp.StatusCode, len(bodies))
+	}
+	for i, body := range bodies {
+		if body != payload {
+			t.Errorf("attempt %d sent %q, want %q", i+1, body, payload)
+		}
+	}
+
+	server = newScriptedServer(t, http.StatusBadGateway, http.StatusCreated)
+	req, _ = http.NewRequest(http.MethodPost, server.URL, io.NopCloser(strings.NewReader(payload)))
+	resp, err = c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := len(server.received()); resp.StatusCode != http.StatusBadGateway || got != 1 {
+		t.Errorf("got %d after %d attempts, want the 502 after 1", resp.StatusCode, got)
+	}
+}
+
+// Bodies over the limits fail with the size errors, and requests carry the
+// request ID of their context
+func TestDoLimitsAndRequestID(t *testing.T) {
+	var gotID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = r.Header.Get(RequestIDHeader)
+		if r.URL.Query().Get("chunked") != "" {
+			// flushing first leaves the length unknown until the body is read
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, strings.Repeat("x", 64))
+	}))
+	defer server.Close()
+	c := New(Config{MaxRequestBytes: 16, MaxResponseBytes: 32})
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(strings.Repeat("y", 17)))
+	if _, err := c.Do(req); !errors.Is(err, ErrRequestTooLarge) {
+		t.Errorf("got %v, want ErrRequestTooLarge", err)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := c.Do(req); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("got %v for a 64 byte Content-Length, want ErrResponseTooLarge", err)
+	}
+
+	req, _ = http.NewRequestWithContext(ContextWithRequestID(context.Background(), "req-42"), http.MethodGet, server.URL+"?chunked=1", nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("got %v reading 64 bytes, want ErrResponseTooLarge", err)
+	}
+	if gotID != "req-42" {
+		t.Errorf("got request ID %q, want req-42", gotID)
+	}
+}
@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/hello-api/pkg/httpclient"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = httpclient.RequestIDHeader
+
+// statusWriter captures the status code and counts the bytes written by the handler
+type statusWriter struct {
+	http.ResponseWriter
+	status   int
+	written  int64
+	hijacked bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Hijack lets WebSocket upgrades through the writer
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// countingBody counts the request body bytes the handler reads, e.g. through
+// http.MaxBytesReader, without buffering them
+type countingBody struct {
+	io.ReadCloser
+	read int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+// Middleware assigns every request an ID (reusing an incoming X-Request-ID),
+// stores it and a logger carrying it in the request context and logs the outcome with
+// the route template and the request and response sizes. With slow set, requests
+// over its threshold are also logged at Warn level and every request is added to
+// its per-route timings; upgraded WebSocket connections are left out.
+func Middleware(logger *slog.Logger, slow *SlowRequests) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			reqLogger := logger.With("request_id", requestID)
+			recorder := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			body := &countingBody{ReadCloser: r.Body}
+			// Outbound calls made while handling the request carry its ID
+			ctx := httpclient.ContextWithRequestID(WithContext(r.Context(), reqLogger), requestID)
+			req := r.WithContext(ctx)
+			req.Body = body
+			next.ServeHTTP(recorder, req)
+			duration := time.Since(start)
+
+			route := routeTemplate(r)
+			level := slog.LevelInfo
+			if recorder.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			reqLogger.Log(r.Context(), level, "request completed",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"route", route,
+				"status", recorder.status,
+				"duration_ms", duration.Milliseconds(),
+				"request_bytes", body.read,
+				"response_bytes", recorder.written,
+			)
+
+			if slow == nil || recorder.hijacked {
+				return
+			}
+			if slow.observe(r, route, duration) {
+				reqLogger.Warn("slow request",
+					"method", r.Method,
+					"route", route,
+					"status", recorder.status,
+					"duration_ms", duration.Milliseconds(),
+					"threshold_ms", slow.threshold.Milliseconds(),
+					"request_bytes", body.read,
+					"response_bytes", recorder.written,
+				)
+			}
+		})
+	}
+}
+
+// routeTemplate returns the template of the matched route, e.g. "/alerts/{id}"
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,204 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// logLines decodes the JSON log lines written to buf
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if raw == "" {
+			continue
+		}
+		var line map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &line); err != nil {
+			t.Fatalf("undecodable log line %q: %v", raw, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// The request log carries the route template and the bytes read and written,
+// counted on the wrapped body and writer
+func TestMiddlewareSizes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mux.NewRouter()
+	r.Use(Middleware(logger, nil))
+	r.HandleFunc("/alerts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// the handler reads through MaxBytesReader as the alert handlers do
+		body, _ := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<10))
+		w.Write(append(body, body...))
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/alerts/42", strings.NewReader(`{"price":1}`))
+	req.Header.Set(RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "req-1" {
+		t.Errorf("got request ID %q, want req-1", got)
+	}
+	lines := logLines(t, &buf)
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines, want 1: %s", len(lines), buf.String())
+	}
+	line := lines[0]
+	for key, want := range map[string]interface{}{
+		"msg":            "request completed",
+		"request_id":     "req-1",
+		"route":          "/alerts/{id}",
+		"path":           "/alerts/42",
+		"status":         float64(http.StatusOK),
+		"request_bytes":  float64(11),
+		"response_bytes": float64(22),
+	} {
+		if line[key] != want {
+			t.Errorf("got %s %v, want %v", key, line[key], want)
+		}
+	}
+}
+
+// Requests over the threshold get a Warn-level "slow request" line and all
+// of them are added to the route's timings, except on ignored routes
+func TestMiddlewareSlowRequests(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow := NewSlowRequests(10 * time.Millisecond)
+	r := mux.NewRouter()
+	r.Use(Middleware(logger, slow))
+	sleep := func(w http.ResponseWriter, r *http.Request) {
+		d, _ := time.ParseDuration(r.URL.Query().Get("sleep"))
+		time.Sleep(d)
+	}
+	r.HandleFunc("/quotes/{symbol}", sleep)
+	slow.Ignore(r.HandleFunc("/alerts/changes", sleep))
+
+	for _, target := range []string{
+		"/quotes/ACI",
+		"/quotes/GP?sleep=20ms",
+		"/alerts/changes?sleep=20ms",
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	var warnings []map[string]interface{}
+	for _, line := range logLines(t, &buf) {
+		if line["msg"] == "slow request" {
+			warnings = append(warnings, line)
+		}
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("got %d slow request lines, want 1: %s", len(warnings), buf.String())
+	}
+	if warnings[0]["level"] != "WARN" || warnings[0]["route"] != "/quotes/{symbol}" {
+		t.Errorf("got slow request line %v, want WARN on /quotes/{symbol}", warnings[0])
+	}
+
+	snapshot := slow.Snapshot()
+	if len(snapshot.Routes) != 1 {
+		t.Fatalf("got routes %+v, want only /quotes/{symbol}", snapshot.Routes)
+	}
+	timing := snapshot.Routes[0]
+	if timing.Method != http.MethodGet || timing.Requests != 2 || timing.SlowRequests != 1 {
+		t.Errorf("got %+v, want 2 GET requests with 1 slow", timing)
+	}
+	if timing.MaxDurationMs < 20 {
+		t.Errorf("got max %dms, want at least 20ms", timing.MaxDurationMs)
+	}
+}
+
+// The snapshot lists the slowest routes first, at most ten, and starts over
+// on Reset and when the UTC day changes
+func TestSlowRequestsSnapshot(t *testing.T) {
+	slow := NewSlowRequests(time.Second)
+	now := time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC)
+	slow.now = func() time.Time { return now }
+	get := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for i := 1; i <= 12; i++ {
+		route := "/route/" + string(rune('a'+i-1))
+		slow.observe(get, route, time.Duration(i)*100*time.Millisecond)
+	}
+	slow.observe(get, "/route/a", 250*time.Millisecond)
+
+	snapshot := slow.Snapshot()
+	if snapshot.Day != "2026-10-16" || snapshot.Threshold != "1s" {
+		t.Errorf("got day %s threshold %s, want 2026-10-16 and 1s", snapshot.Day, snapshot.Threshold)
+	}
+	if len(snapshot.Routes) != slowestRoutes {
+		t.Fatalf("got %d routes, want %d", len(snapshot.Routes), slowestRoutes)
+	}
+	first := snapshot.Routes[0]
+	if first.Route != "/route/l" || first.MaxDurationMs != 1200 || first.SlowRequests != 1 {
+		t.Errorf("got first %+v, want /route/l at 1200ms, slow", first)
+	}
+	// /route/a (100ms and 250ms) is cut, /route/c (300ms) is last
+	if last := snapshot.Routes[len(snapshot.Routes)-1]; last.Route != "/route/c" {
+		t.Errorf("got last %+v, want /route/c", last)
+	}
+
+	slow.Reset()
+	if got := slow.Snapshot().Routes; len(got) != 0 {
+		t.Errorf("got %d routes after Reset, want 0", len(got))
+	}
+
+	slow.observe(get, "/route/a", 100*time.Millisecond)
+	slow.observe(get, "/route/a", 300*time.Millisecond)
+	if got := slow.Snapshot().Routes[0].AvgDurationMs; got != 200 {
+		t.Errorf("got average %dms, want 200ms", got)
+	}
+	now = now.Add(2 * time.Hour)
+	snapshot = slow.Snapshot()
+	if snapshot.Day != "2026-10-17" || len(snapshot.Routes) != 0 {
+		t.Errorf("got day %s with %d routes, want 2026-10-17 with none", snapshot.Day, len(snapshot.Routes))
+	}
+}
+
+// Concurrent requests and snapshots do not race and lose no requests
+func TestSlowRequestsConcurrent(t *testing.T) {
+	logger, err := New(io.Discard, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow := NewSlowRequests(time.Second)
+	r := mux.NewRouter()
+	r.Use(Middleware(logger, slow))
+	r.HandleFunc("/alerts", func(w http.ResponseWriter, r *http.Request) {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/alerts", nil))
+				slow.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := slow.Snapshot().Routes[0].Requests; got != 400 {
+		t.Errorf("got %d requests, want 400", got)
+	}
+}
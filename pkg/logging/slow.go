@@ -0,0 +1,154 @@
+package logging
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// DefaultSlowRequestThreshold is the duration above which a request is logged as slow
+	DefaultSlowRequestThreshold = 2 * time.Second
+	// slowestRoutes is how many routes a snapshot lists
+	slowestRoutes = 10
+)
+
+// RouteTiming is the daily timing of one route and method
+type RouteTiming struct {
+	Route         string `json:"route"`
+	Method        string `json:"method"`
+	Requests      int64  `json:"requests"`
+	SlowRequests  int64  `json:"slowRequests"`
+	MaxDurationMs int64  `json:"maxDurationMs"`
+	AvgDurationMs int64  `json:"avgDurationMs"`
+
+	totalDuration time.Duration
+	maxDuration   time.Duration
+}
+
+// SlowRoutesSnapshot lists the slowest routes of the day by their slowest request
+type SlowRoutesSnapshot struct {
+	// Day is the UTC date the timings were collected on
+	Day       string        `json:"day"`
+	Threshold string        `json:"threshold"`
+	Routes    []RouteTiming `json:"routes"`
+}
+
+// SlowRequests flags requests slower than a threshold and keeps per-route
+// timings for the current UTC day in memory. Timings start over at midnight
+// UTC or on Reset. Routes are keyed by their template, so the set stays small.
+type SlowRequests struct {
+	threshold time.Duration
+	// ignored routes, e.g. long polls, are slow by design; set while the router is built
+	ignored map[*mux.Route]bool
+	now     func() time.Time
+
+	mu     sync.Mutex
+	day    string
+	routes map[string]*RouteTiming
+}
+
+func NewSlowRequests(threshold time.Duration) *SlowRequests {
+	if threshold <= 0 {
+		threshold = DefaultSlowRequestThreshold
+	}
+	return &SlowRequests{
+		threshold: threshold,
+		ignored:   make(map[*mux.Route]bool),
+		now:       time.Now,
+		routes:    make(map[string]*RouteTiming),
+	}
+}
+
+// SlowRequestsFromEnv creates SlowRequests with the threshold in SLOW_REQUEST_THRESHOLD (e.g. "2s")
+func SlowRequestsFromEnv() (*SlowRequests, error) {
+	threshold := DefaultSlowRequestThreshold
+	if raw := os.Getenv("SLOW_REQUEST_THRESHOLD"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("SLOW_REQUEST_THRESHOLD must be a positive duration, got %q", raw)
+		}
+		threshold = parsed
+	}
+	return NewSlowRequests(threshold), nil
+}
+
+// Ignore keeps routes out of the slow request log and the timings
+func (s *SlowRequests) Ignore(routes ...*mux.Route) {
+	for _, route := range routes {
+		s.ignored[route] = true
+	}
+}
+
+// observe records a completed request and reports whether it was slow
+func (s *SlowRequests) observe(r *http.Request, route string, duration time.Duration) bool {
+	if current := mux.CurrentRoute(r); current != nil && s.ignored[current] {
+		return false
+	}
+	slow := duration > s.threshold
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollLocked()
+	key := r.Method + " " + route
+	timing, ok := s.routes[key]
+	if !ok {
+		timing = &RouteTiming{Route: route, Method: r.Method}
+		s.routes[key] = timing
+	}
+	timing.Requests++
+	timing.totalDuration += duration
+	if duration > timing.maxDuration {
+		timing.maxDuration = duration
+	}
+	if slow {
+		timing.SlowRequests++
+	}
+	return slow
+}
+
+// Snapshot returns the slowest routes of the day, slowest first
+func (s *SlowRequests) Snapshot() SlowRoutesSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollLocked()
+
+	routes := make([]RouteTiming, 0, len(s.routes))
+	for _, timing := range s.routes {
+		copied := *timing
+		copied.MaxDurationMs = timing.maxDuration.Milliseconds()
+		copied.AvgDurationMs = (timing.totalDuration / time.Duration(timing.Requests)).Milliseconds()
+		routes = append(routes, copied)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].maxDuration != routes[j].maxDuration {
+			return routes[i].maxDuration > routes[j].maxDuration
+		}
+		return routes[i].Method+routes[i].Route < routes[j].Method+routes[j].Route
+	})
+	if len(routes) > slowestRoutes {
+		routes = routes[:slowestRoutes]
+	}
+	return SlowRoutesSnapshot{Day: s.day, Threshold: s.threshold.String(), Routes: routes}
+}
+
+// Reset drops the timings collected so far
+func (s *SlowRequests) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = make(map[string]*RouteTiming)
+}
+
+// rollLocked starts the timings over when the UTC day changed; it assumes s.mu is held
+func (s *SlowRequests) rollLocked() {
+	day := s.now().UTC().Format("2006-01-02")
+	if day != s.day {
+		s.day = day
+		s.routes = make(map[string]*RouteTiming)
+	}
+}
@@ -0,0 +1,291 @@
+// Package metrics is a small in-process registry of counters, gauges and
+// histograms exposed in the Prometheus text format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Labels are the label pairs identifying one series of a metric
+type Labels map[string]string
+
+// DefaultBuckets are histogram upper bounds in seconds suited to request latencies
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default is the registry used by the application
+var Default = NewRegistry()
+
+// Registry holds every registered series
+type Registry struct {
+	mu         sync.RWMutex
+	help       map[string]string
+	counters   map[string]*Counter
+	gauges     map[string]*Gauge
+	histograms map[string]*Histogram
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		help:       make(map[string]string),
+		counters:   make(map[string]*Counter),
+		gauges:     make(map[string]*Gauge),
+		histograms: make(map[string]*Histogram),
+	}
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	name   string
+	labels string
+	value  int64
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	atomic.AddInt64(&c.value, 1)
+}
+
+// Add adds n to the counter
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.value, n)
+}
+
+// Value returns the current count
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	name   string
+	labels string
+	value  int64
+}
+
+// Inc adds one to the gauge
+func (g *Gauge) Inc() {
+	atomic.AddInt64(&g.value, 1)
+}
+
+// Dec subtracts one from the gauge
+func (g *Gauge) Dec() {
+	atomic.AddInt64(&g.value, -1)
+}
+
+// Set replaces the gauge value
+func (g *Gauge) Set(v int64) {
+	atomic.StoreInt64(&g.value, v)
+}
+
+// Value returns the current value
+func (g *Gauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	name    string
+	labels  string
+	mu      sync.Mutex
+	bounds  []float64
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// Observe records one observation
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// Describe sets the help text of a metric name
+func (r *Registry) Describe(name, help string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.help[name] = help
+}
+
+// Counter returns the counter series for name and labels, creating it on first use
+func (r *Registry) Counter(name string, labels Labels) *Counter {
+	key := name + formatLabels(labels)
+
+	r.mu.RLock()
+	c, ok := r.counters[key]
+	r.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.counters[key]; ok {
+		return c
+	}
+	c = &Counter{name: name, labels: formatLabels(labels)}
+	r.counters[key] = c
+	return c
+}
+
+// Gauge returns the gauge series for name and labels, creating it on first use
+func (r *Registry) Gauge(name string, labels Labels) *Gauge {
+	key := name + formatLabels(labels)
+
+	r.mu.RLock()
+	g, ok := r.gauges[key]
+	r.mu.RUnlock()
+	if ok {
+		return g
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if g, ok := r.gauges[key]; ok {
+		return g
+	}
+	g = &Gauge{name: name, labels: formatLabels(labels)}
+	r.gauges[key] = g
+	return g
+}
+
+// Histogram returns the histogram series for name and labels, creating it with
+// DefaultBuckets on first use
+func (r *Registry) Histogram(name string, labels Labels) *Histogram {
+	key := name + formatLabels(labels)
+
+	r.mu.RLock()
+	h, ok := r.histograms[key]
+	r.mu.RUnlock()
+	if ok {
+		return h
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h, ok := r.histograms[key]; ok {
+		return h
+	}
+	h = &Histogram{
+		name:    name,
+		labels:  formatLabels(labels),
+		bounds:  DefaultBuckets,
+		buckets: make([]uint64, len(DefaultBuckets)),
+	}
+	r.histograms[key] = h
+	return h
+}
+
+// WritePrometheus writes every series in the Prometheus text exposition format
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	written := make(map[string]bool)
+	header := func(name, kind string) {
+		if written[name] {
+			return
+		}
+		written[name] = true
+		if help, ok := r.help[name]; ok {
+			fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+		}
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+	}
+
+	for _, key := range sortedKeys(r.counters) {
+		c := r.counters[key]
+		header(c.name, "counter")
+		fmt.Fprintf(w, "%s%s %d\n", c.name, c.labels, c.Value())
+	}
+
+	for _, key := range sortedKeys(r.gauges) {
+		g := r.gauges[key]
+		header(g.name, "gauge")
+		fmt.Fprintf(w, "%s%s %d\n", g.name, g.labels, g.Value())
+	}
+
+	for _, key := range sortedKeys(r.histograms) {
+		h := r.histograms[key]
+		header(h.name, "histogram")
+
+		h.mu.Lock()
+		for i, bound := range h.bounds {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLabel(h.labels, "le", formatFloat(bound)), h.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLabel(h.labels, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %g\n", h.name, h.labels, h.sum)
+		_, err := fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labels, h.count)
+		h.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the registry in the Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := r.WritePrometheus(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// formatLabels renders labels as {k="v",...} in key order
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func withLabel(labels, key, value string) string {
+	pair := fmt.Sprintf("%s=%q", key, value)
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
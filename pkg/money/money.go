@@ -0,0 +1,164 @@
+// Package money represents prices exactly. An Amount counts paisa, hundredths
+// of a taka, so prices compare and sum without the drift of float64 (a stored
+// 123.45000000000001, or two equal prices that are not ==). JSON carries an
+// Amount as a number with at most two decimal places and MongoDB as a
+// Decimal128, which orders and aggregates correctly next to older doubles.
+package money
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// Amount is a price in paisa
+type Amount int64
+
+// Scale is the number of paisa in a taka
+const Scale = 100
+
+// ErrPrecision is returned for a price with more than two decimal places
+var ErrPrecision = errors.New("has more than two decimal places")
+
+// FromFloat rounds a float to the nearest paisa, half away from zero
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * Scale))
+}
+
+// Parse reads a decimal such as "123.45", "-0.5" or "1e2". More than two
+// significant decimal places is an error wrapping ErrPrecision, so a price is
+// never rounded silently.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	// Exponents are rare in prices; the float round trip is exact for them as
+	// long as the result has two decimals, which the check below confirms
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid price %q", s)
+		}
+		amount := FromFloat(f)
+		if amount.Float() != f {
+			return 0, fmt.Errorf("price %s %w", s, ErrPrecision)
+		}
+		return amount, nil
+	}
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return 0, fmt.Errorf("invalid price %q", s)
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > 2 {
+		return 0, fmt.Errorf("price %s %w", s, ErrPrecision)
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+	if whole == "" {
+		whole = "0"
+	}
+	paisa, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil || paisa > math.MaxInt64/Scale {
+		return 0, fmt.Errorf("price %s is out of range", s)
+	}
+	if negative {
+		paisa = -paisa
+	}
+	return Amount(paisa), nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Float returns the amount in taka, for ratios and display
+func (a Amount) Float() float64 {
+	return float64(a) / Scale
+}
+
+// String formats the amount with two decimal places, e.g. "123.40"
+func (a Amount) String() string {
+	sign := ""
+	paisa := int64(a)
+	if paisa < 0 {
+		sign = "-"
+		paisa = -paisa
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, paisa/Scale, paisa%Scale)
+}
+
+// Ptr returns a pointer to the amount, for optional fields
+func (a Amount) Ptr() *Amount {
+	return &a
+}
+
+// MarshalJSON writes the amount as a number with two decimal places
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number, or a string holding one, with at most
+// two decimal places
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	amount, err := Parse(text)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// MarshalBSONValue stores the amount as a Decimal128 with two decimal places
+func (a Amount) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	decimal, err := primitive.ParseDecimal128(a.String())
+	if err != nil {
+		return 0, nil, err
+	}
+	return bson.TypeDecimal128, bsoncore.AppendDecimal128(nil, decimal), nil
+}
+
+// UnmarshalBSONValue reads a Decimal128, or a double or integer stored
+// before prices were decimals; doubles are rounded to the paisa
+func (a *Amount) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bson.TypeDecimal128:
+		amount, err := Parse(value.Decimal128().String())
+		if err != nil {
+			return err
+		}
+		*a = amount
+	case bson.TypeDouble:
+		*a = FromFloat(value.Double())
+	case bson.TypeInt32:
+		*a = Amount(int64(value.Int32()) * Scale)
+	case bson.TypeInt64:
+		*a = Amount(value.Int64() * Scale)
+	case bson.TypeNull:
+		*a = 0
+	default:
+		return fmt.Errorf("cannot read a price from BSON %s", t)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Prices parse to the exact paisa; a third decimal place is refused rather
+// than rounded
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		input         string
+		want          Amount
+		wantErr       bool
+		wantPrecision bool
+	}{
+		{input: "123.45", want: 12345},
+		{input: "123.4", want: 12340},
+		{input: "123", want: 12300},
+		{input: ".5", want: 50},
+		{input: "-0.5", want: -50},
+		{input: "123.450000", want: 12345},
+		{input: "1e2", want: 10000},
+		{input: "1.2345e2", want: 12345},
+		{input: "123.449", wantErr: true, wantPrecision: true},
+		{input: "1.2345e1", wantErr: true, wantPrecision: true},
+		{input: "", wantErr: true},
+		{input: "12a", wantErr: true},
+		{input: "1.2.3", wantErr: true},
+		{input: "99999999999999999999", wantErr: true},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := Parse(tc.input)
+			if (err != nil) != tc.wantErr || errors.Is(err, ErrPrecision) != tc.wantPrecision {
+				t.Fatalf("got error %v, want error %t, precision %t", err, tc.wantErr, tc.wantPrecision)
+			}
+			if got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// Sums that drift as float64 are exact in paisa
+func TestFromFloat(t *testing.T) {
+	if FromFloat(0.1)+FromFloat(0.2) != FromFloat(0.3) {
+		t.Error("0.10 + 0.20 is not 0.30")
+	}
+	if got := FromFloat(123.45000000000001); got != 12345 {
+		t.Errorf("got %d paisa, want 12345", got)
+	}
+}
+
+// JSON carries two decimal places both ways and accepts quoted numbers
+func TestJSON(t *testing.T) {
+	var decoded struct {
+		Price    Amount  `json:"price"`
+		Quoted   Amount  `json:"quoted"`
+		Optional *Amount `json:"optional"`
+	}
+	if err := json.Unmarshal([]byte(`{"price":123.45,"quoted":"0.1","optional":null}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Price != 12345 || decoded.Quoted != 10 || decoded.Optional != nil {
+		t.Errorf("got %+v", decoded)
+	}
+	encoded, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"price":123.45,"quoted":0.10,"optional":null}`; string(encoded) != want {
+		t.Errorf("got %s, want %s", encoded, want)
+	}
+	if err := json.Unmarshal([]byte(`{"price":123.449}`), &decoded); !errors.Is(err, ErrPrecision) {
+		t.Errorf("got %v, want ErrPrecision", err)
+	}
+}
+
+// Amounts are stored as Decimal128 and read back unchanged
+func TestBSON(t *testing.T) {
+	type document struct {
+		Price Amount `bson:"price"`
+	}
+	for _, amount := range []Amount{12345, -50, 0, 1, 99999999999} {
+		raw, err := bson.Marshal(document{Price: amount})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bson.Raw(raw).Lookup("price").Type; got != bson.TypeDecimal128 {
+			t.Errorf("%s is stored as %s, want a Decimal128", amount, got)
+		}
+		var read document
+		if err := bson.Unmarshal(raw, &read); err != nil {
+			t.Fatal(err)
+		}
+		if read.Price != amount {
+			t.Errorf("got %s back, want %s", read.Price, amount)
+		}
+	}
+}
+
+// Doubles and integers stored before prices were decimals are read rounded
+// to the paisa
+func TestBSONLegacy(t *testing.T) {
+	for _, tc := range []struct {
+		stored any
+		want   Amount
+	}{
+		{stored: 123.45000000000001, want: 12345},
+		{stored: 0.30000000000000004, want: 30},
+		{stored: int32(123), want: 12300},
+		{stored: int64(123), want: 12300},
+		{stored: nil, want: 0},
+	} {
+		raw, err := bson.Marshal(bson.M{"price": tc.stored})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var read struct {
+			Price Amount `bson:"price"`
+		}
+		if err := bson.Unmarshal(raw, &read); err != nil {
+			t.Fatal(err)
+		}
+		if read.Price != tc.want {
+			t.Errorf("read %v as %s, want %s", tc.stored, read.Price, tc.want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package mongo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+const defaultURI = "mongodb://localhost:27017/dev_db"
+
+// Config holds the connection settings that cannot be expressed by a host-only
+// URI, e.g. when the URI and the credentials come from separate secrets
+type Config struct {
+	URI string
+
+	Username   string
+	Password   string
+	AuthSource string
+
+	// TLS forces TLS on; it is implied by CAFile and by mongodb+srv URIs
+	TLS    bool
+	CAFile string
+
+	Compressors  []string
+	ReadConcern  string
+	WriteConcern string
+
+	// SlowQueryThreshold logs commands slower than this; zero disables slow query logging
+	SlowQueryThreshold time.Duration
+}
+
+// supportedCompressors are the wire compressors understood by the driver
+var supportedCompressors = map[string]bool{"snappy": true, "zlib": true, "zstd": true}
+
+// LoadConfig reads the MongoDB settings from the environment.
+// Credentials may be given directly (MONGO_USERNAME, MONGO_PASSWORD) or as paths
+// to mounted secret files (MONGO_USERNAME_FILE, MONGO_PASSWORD_FILE).
+func LoadConfig() (Config, error) {
+	cfg := Config{
+		URI:          os.Getenv("MONGO_URI"),
+		AuthSource:   os.Getenv("MONGO_AUTH_SOURCE"),
+		CAFile:       os.Getenv("MONGO_TLS_CA_FILE"),
+		ReadConcern:  os.Getenv("MONGO_READ_CONCERN"),
+		WriteConcern: os.Getenv("MONGO_WRITE_CONCERN"),
+	}
+	if cfg.URI == "" {
+		cfg.URI = defaultURI
+	}
+
+	var err error
+	if cfg.Username, err = envOrFile("MONGO_USERNAME"); err != nil {
+		return cfg, err
+	}
+	if cfg.Password, err = envOrFile("MONGO_PASSWORD"); err != nil {
+		return cfg, err
+	}
+
+	if raw := os.Getenv("MONGO_TLS"); raw != "" {
+		if cfg.TLS, err = strconv.ParseBool(raw); err != nil {
+			return cfg, fmt.Errorf("MONGO_TLS must be true or false, got %q", raw)
+		}
+	}
+
+	if raw := os.Getenv("MONGO_SLOW_QUERY_MS"); raw != "" {
+		ms, err := strconv.Atoi(raw)
+		if err != nil || ms < 0 {
+			return cfg, fmt.Errorf("MONGO_SLOW_QUERY_MS must be a non-negative number of milliseconds, got %q", raw)
+		}
+		cfg.SlowQueryThreshold = time.Duration(ms) * time.Millisecond
+	}
+
+	if raw := os.Getenv("MONGO_COMPRESSORS"); raw != "" {
+		for _, c := range strings.Split(raw, ",") {
+			if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+				cfg.Compressors = append(cfg.Compressors, c)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// ClientOptions validates the config and merges it into driver options on top
+// of the URI. The returned TLSState records the negotiated handshake.
+func (c Config) ClientOptions() (*options.ClientOptions, *TLSState, error) {
+	opts := options.Client().ApplyURI(c.URI)
+	if err := opts.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid MONGO_URI: %w", err)
+	}
+
+	if c.Password != "" && c.Username == "" {
+		return nil, nil, fmt.Errorf("MONGO_PASSWORD is set but MONGO_USERNAME is empty")
+	}
+	if c.Username != "" {
+		cred := options.Credential{Username: c.Username, Password: c.Password, AuthSource: c.AuthSource}
+		if opts.Auth != nil {
+			// Keep the mechanism and source configured in the URI
+			cred.AuthMechanism = opts.Auth.AuthMechanism
+			cred.AuthMechanismProperties = opts.Auth.AuthMechanismProperties
+			if cred.AuthSource == "" {
+				cred.AuthSource = opts.Auth.AuthSource
+			}
+		}
+		opts.SetAuth(cred)
+	} else if c.AuthSource != "" {
+		return nil, nil, fmt.Errorf("MONGO_AUTH_SOURCE is set but no credentials were provided")
+	}
+
+	var state *TLSState
+	if c.TLS || c.CAFile != "" || opts.TLSConfig != nil {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if opts.TLSConfig != nil {
+			tlsConfig = opts.TLSConfig.Clone()
+		}
+		if c.CAFile != "" {
+			pool, err := loadCAFile(c.CAFile)
+			if err != nil {
+				return nil, nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+		state = &TLSState{}
+		tlsConfig.VerifyConnection = state.record
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	if len(c.Compressors) > 0 {
+		for _, comp := range c.Compressors {
+			if !supportedCompressors[comp] {
+				return nil, nil, fmt.Errorf("MONGO_COMPRESSORS: unsupported compressor %q (use snappy, zlib or zstd)", comp)
+			}
+		}
+		opts.SetCompressors(c.Compressors)
+	}
+
+	if c.ReadConcern != "" {
+		level := strings.ToLower(c.ReadConcern)
+		switch level {
+		case "local", "available", "majority", "linearizable", "snapshot":
+			opts.SetReadConcern(&readconcern.ReadConcern{Level: level})
+		default:
+			return nil, nil, fmt.Errorf("MONGO_READ_CONCERN: unsupported level %q", c.ReadConcern)
+		}
+	}
+
+	if c.WriteConcern != "" {
+		wc, err := parseWriteConcern(c.WriteConcern)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.SetWriteConcern(wc)
+	}
+
+	return opts, state, nil
+}
+
+// TLSState captures the TLS handshake negotiated with the server
+type TLSState struct {
+	mu      sync.Mutex
+	done    bool
+	version uint16
+}
+
+// record is the VerifyConnection callback. It runs once the server's
+// certificate is verified, before HandshakeComplete is set, so reaching it is
+// what marks the handshake as done.
+func (s *TLSState) record(cs tls.ConnectionState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	s.version = cs.Version
+	return nil
+}
+
+// Negotiated returns the TLS version name and whether a handshake completed
+func (s *TLSState) Negotiated() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return tls.VersionName(s.version), s.done
+}
+
+// envOrFile returns the value of name, or the trimmed contents of the file named by name_FILE
+func envOrFile(name string) (string, error) {
+	value := os.Getenv(name)
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("both %s and %s_FILE are set; use only one", name, name)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: cannot read %q: %w", name, path, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func loadCAFile(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("MONGO_TLS_CA_FILE: cannot read %q: %w", path, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("MONGO_TLS_CA_FILE: %q contains no PEM encoded certificates", path)
+	}
+	return pool, nil
+}
+
+// parseWriteConcern accepts "majority", a node count such as "1", or a tag set name
+func parseWriteConcern(raw string) (*writeconcern.WriteConcern, error) {
+	if strings.EqualFold(raw, "majority") {
+		return writeconcern.Majority(), nil
+	}
+	if n, err := strconv.Atoi(raw); err == nil {
+		if n < 0 {
+			return nil, fmt.Errorf("MONGO_WRITE_CONCERN: node count must not be negative, got %d", n)
+		}
+		return &writeconcern.WriteConcern{W: n}, nil
+	}
+	return writeconcern.Custom(raw), nil
+}
@@ -0,0 +1,114 @@
+package mongo
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Misconfigured combinations fail with an error naming the setting to fix
+func TestClientOptionsErrors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"missing CA file", Config{URI: defaultURI, CAFile: filepath.Join(dir, "missing.pem")}, "MONGO_TLS_CA_FILE: cannot read"},
+		{"unreadable CA file", Config{URI: defaultURI, CAFile: dir}, "MONGO_TLS_CA_FILE: cannot read"},
+		{"CA file without certificates", Config{URI: defaultURI, CAFile: notPEM}, "contains no PEM encoded certificates"},
+		{"password without username", Config{URI: defaultURI, Password: "secret"}, "MONGO_USERNAME is empty"},
+		{"auth source without credentials", Config{URI: defaultURI, AuthSource: "admin"}, "MONGO_AUTH_SOURCE is set"},
+		{"unknown compressor", Config{URI: defaultURI, Compressors: []string{"lz4"}}, `unsupported compressor "lz4"`},
+		{"unknown read concern", Config{URI: defaultURI, ReadConcern: "eventual"}, "MONGO_READ_CONCERN"},
+		{"negative write concern", Config{URI: defaultURI, WriteConcern: "-1"}, "MONGO_WRITE_CONCERN"},
+		{"bad URI", Config{URI: "postgres://localhost"}, "invalid MONGO_URI"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := tc.cfg.ClientOptions()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got %v, want an error containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+// A CA file turns TLS on with the CA as the only root, and the TLS state
+// records the handshake negotiated with a server
+func TestClientOptionsTLS(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, state, err := Config{URI: defaultURI, CAFile: caFile, Username: "app", Password: "secret"}.ClientOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.TLSConfig == nil || opts.TLSConfig.RootCAs == nil || state == nil {
+		t.Fatal("CA file did not enable TLS with its roots")
+	}
+	if opts.Auth == nil || opts.Auth.Username != "app" {
+		t.Errorf("credentials are %+v, want the configured username", opts.Auth)
+	}
+	if _, done := state.Negotiated(); done {
+		t.Error("handshake reported before any connection")
+	}
+
+	tlsConfig := opts.TLSConfig.Clone()
+	tlsConfig.ServerName = "example.com"
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), tlsConfig)
+	if err != nil {
+		t.Fatalf("handshake with the configured CA failed: %v", err)
+	}
+	conn.Close()
+	if version, done := state.Negotiated(); !done || !strings.HasPrefix(version, "TLS 1.") {
+		t.Errorf("negotiated %q (complete %v), want a completed TLS handshake", version, done)
+	}
+
+	if opts, state, err := (Config{URI: defaultURI}).ClientOptions(); err != nil || opts.TLSConfig != nil || state != nil {
+		t.Errorf("plain URI enabled TLS (%v)", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(secret, []byte(" s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MONGO_URI", "")
+	t.Setenv("MONGO_USERNAME", "app")
+	t.Setenv("MONGO_PASSWORD_FILE", secret)
+	t.Setenv("MONGO_TLS", "true")
+	t.Setenv("MONGO_COMPRESSORS", "Zstd, snappy,")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.URI != defaultURI || cfg.Password != "s3cret" || !cfg.TLS || strings.Join(cfg.Compressors, ",") != "zstd,snappy" {
+		t.Errorf("loaded %+v", cfg)
+	}
+
+	t.Setenv("MONGO_PASSWORD", "inline")
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "use only one") {
+		t.Errorf("password set twice returned %v", err)
+	}
+	t.Setenv("MONGO_PASSWORD", "")
+	t.Setenv("MONGO_TLS", "yes please")
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "MONGO_TLS") {
+		t.Errorf("bad MONGO_TLS returned %v", err)
+	}
+}
@@ -2,33 +2,64 @@ package mongo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"os"
 
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/hello-api/pkg/logging"
+	"github.com/hello-api/pkg/metrics"
 )
 
 func ConnectMongo() *mongo.Client {
-	// Hardcoded MongoDB URI for development
-	mongoURI := os.Getenv("MONGO_URI")
-	if mongoURI == "" {
-		mongoURI = "mongodb://localhost:27017/dev_db"
+	client, err := Connect(context.Background())
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
+	return client
+}
 
-	clientOptions := options.Client().ApplyURI(mongoURI)
+// Connect connects with the configuration from the environment and pings the
+// server, failing instead of exiting so callers such as the self-check can
+// report the error. ctx bounds the ping.
+func Connect(ctx context.Context) (*mongo.Client, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid MongoDB configuration: %w", err)
+	}
 
-	client, err := mongo.Connect(context.Background(), clientOptions)
+	clientOptions, tlsState, err := cfg.ClientOptions()
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		return nil, fmt.Errorf("invalid MongoDB configuration: %w", err)
 	}
 
-	if err := client.Ping(context.Background(), nil); err != nil {
-		log.Fatalf("Failed to ping MongoDB: %v", err)
+	monitor := NewMonitor(metrics.Default, cfg.SlowQueryThreshold)
+	clientOptions.SetMonitor(monitor.CommandMonitor())
+	clientOptions.SetPoolMonitor(monitor.PoolMonitor())
+
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
 	}
 
-	log.Println("Connected to MongoDB")
-	return client
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+
+	if tlsState != nil {
+		version, ok := tlsState.Negotiated()
+		if !ok {
+			client.Disconnect(context.Background())
+			return nil, errors.New("MongoDB TLS is enabled but no TLS handshake was completed")
+		}
+		logging.FromContext(ctx).Info("Connected to MongoDB", "tls", version)
+		return client, nil
+	}
+
+	logging.FromContext(ctx).Info("Connected to MongoDB")
+	return client, nil
 }
 
 func CreateDatabase(client *mongo.Client) *mongo.Database {
@@ -0,0 +1,174 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/event"
+
+	"github.com/hello-api/pkg/metrics"
+)
+
+// Monitor records driver command and connection pool events into a metrics
+// registry and logs commands slower than a threshold. It only ever logs the
+// shape of a command (field names and value types) and sizes, never values.
+type Monitor struct {
+	registry      *metrics.Registry
+	slowThreshold time.Duration
+
+	// Started commands kept for slow query logging, keyed by request id.
+	// Only populated when slow query logging is enabled.
+	started sync.Map
+}
+
+// startedCommand is the part of a started event needed to describe a slow command
+type startedCommand struct {
+	database string
+	command  bson.Raw
+}
+
+// NewMonitor creates a monitor. A zero slowThreshold disables slow query logging.
+func NewMonitor(registry *metrics.Registry, slowThreshold time.Duration) *Monitor {
+	registry.Describe("mongo_commands_total", "MongoDB commands completed, by command name")
+	registry.Describe("mongo_command_failures_total", "MongoDB commands that failed, by command name")
+	registry.Describe("mongo_command_duration_seconds", "MongoDB command round trip duration, by command name")
+	registry.Describe("mongo_pool_checkouts_total", "Connections checked out of the MongoDB pool")
+	registry.Describe("mongo_pool_checkout_failures_total", "Failed MongoDB pool checkouts, by reason")
+	registry.Describe("mongo_pool_connections_created_total", "Connections opened by the MongoDB pool")
+	registry.Describe("mongo_pool_connections_closed_total", "Connections closed by the MongoDB pool")
+
+	return &Monitor{registry: registry, slowThreshold: slowThreshold}
+}
+
+// CommandMonitor returns the driver command monitor
+func (m *Monitor) CommandMonitor() *event.CommandMonitor {
+	cm := &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			m.finished(e.CommandFinishedEvent, len(e.Reply), "")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			m.registry.Counter("mongo_command_failures_total", metrics.Labels{"command": e.CommandName}).Inc()
+			m.finished(e.CommandFinishedEvent, 0, e.Failure)
+		},
+	}
+	if m.slowThreshold > 0 {
+		cm.Started = func(_ context.Context, e *event.CommandStartedEvent) {
+			// The driver hands monitors their own copy of the command
+			m.started.Store(e.RequestID, startedCommand{database: e.DatabaseName, command: e.Command})
+		}
+	}
+	return cm
+}
+
+// PoolMonitor returns the driver connection pool monitor
+func (m *Monitor) PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.GetSucceeded:
+				m.registry.Counter("mongo_pool_checkouts_total", nil).Inc()
+			case event.GetFailed:
+				m.registry.Counter("mongo_pool_checkout_failures_total", metrics.Labels{"reason": e.Reason}).Inc()
+			case event.ConnectionCreated:
+				m.registry.Counter("mongo_pool_connections_created_total", nil).Inc()
+			case event.ConnectionClosed:
+				m.registry.Counter("mongo_pool_connections_closed_total", nil).Inc()
+			}
+		},
+	}
+}
+
+func (m *Monitor) finished(e event.CommandFinishedEvent, replySize int, failure string) {
+	labels := metrics.Labels{"command": e.CommandName}
+	m.registry.Counter("mongo_commands_total", labels).Inc()
+	m.registry.Histogram("mongo_command_duration_seconds", labels).Observe(e.Duration.Seconds())
+
+	if m.slowThreshold <= 0 {
+		return
+	}
+	value, ok := m.started.LoadAndDelete(e.RequestID)
+	if !ok || e.Duration < m.slowThreshold {
+		return
+	}
+
+	started := value.(startedCommand)
+	status := "ok"
+	if failure != "" {
+		status = "failed"
+	}
+	slog.Warn("Slow MongoDB command",
+		"command", e.CommandName,
+		"database", started.database,
+		"collection", commandCollection(started.command, e.CommandName),
+		"duration_ms", e.Duration.Milliseconds(),
+		"status", status,
+		"command_bytes", len(started.command),
+		"reply_bytes", replySize,
+		"filter_shape", commandFilterShape(started.command),
+	)
+}
+
+// commandCollection returns the collection a command targets, which is the
+// value of its first element (e.g. {"find": "alerts", ...})
+func commandCollection(command bson.Raw, commandName string) string {
+	value, err := command.LookupErr(commandName)
+	if err != nil {
+		return "?"
+	}
+	if name, ok := value.StringValueOK(); ok {
+		return name
+	}
+	return "?"
+}
+
+// commandFilterShape describes the command's filter or query without values
+func commandFilterShape(command bson.Raw) string {
+	for _, key := range []string{"filter", "q", "query"} {
+		if value, err := command.LookupErr(key); err == nil {
+			return shape(value, 0)
+		}
+	}
+	// Write commands carry their filters inside an array of statements
+	for _, key := range []string{"updates", "deletes"} {
+		if value, err := command.LookupErr(key); err == nil {
+			return key + ":" + shape(value, 0)
+		}
+	}
+	return "{}"
+}
+
+// shape renders field names and value types, never the values themselves
+func shape(value bson.RawValue, depth int) string {
+	if depth > 4 {
+		return "…"
+	}
+	switch value.Type {
+	case bsontype.EmbeddedDocument:
+		elements, err := value.Document().Elements()
+		if err != nil {
+			return "document"
+		}
+		fields := make([]string, 0, len(elements))
+		for _, element := range elements {
+			fields = append(fields, element.Key()+":"+shape(element.Value(), depth+1))
+		}
+		return "{" + strings.Join(fields, ",") + "}"
+	case bsontype.Array:
+		values, err := value.Array().Values()
+		if err != nil {
+			return "array"
+		}
+		if len(values) > 0 && values[0].Type == bsontype.EmbeddedDocument {
+			return fmt.Sprintf("[%d x %s]", len(values), shape(values[0], depth+1))
+		}
+		return fmt.Sprintf("array(%d)", len(values))
+	default:
+		return value.Type.String()
+	}
+}